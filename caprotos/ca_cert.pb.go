@@ -0,0 +1,324 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.26.0
+// 	protoc        v3.15.8
+// source: ca_cert.proto
+
+package caprotos
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetCACertificatesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Common request header including protocol version & request identifier.
+	Header *CaRequestHeader `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	// Version of the GetCACertificatesRequest message.
+	Version string `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	// Unique identifier for the tenant (Tenant ID). Optional - if specified, the
+	// chain of certificates used to sign device certificates within the tenant
+	// is returned. Otherwise, the chain containing the common signing
+	// certificate is returned.
+	Tid string `protobuf:"bytes,3,opt,name=tid,proto3" json:"tid,omitempty"`
+	// Format in which the certificates are to be returned.
+	Format CertificateFormat `protobuf:"varint,4,opt,name=format,proto3,enum=caprotos.CertificateFormat" json:"format,omitempty"`
+}
+
+func (x *GetCACertificatesRequest) Reset() {
+	*x = GetCACertificatesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ca_cert_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetCACertificatesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCACertificatesRequest) ProtoMessage() {}
+
+func (x *GetCACertificatesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ca_cert_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCACertificatesRequest.ProtoReflect.Descriptor instead.
+func (*GetCACertificatesRequest) Descriptor() ([]byte, []int) {
+	return file_ca_cert_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetCACertificatesRequest) GetHeader() *CaRequestHeader {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *GetCACertificatesRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *GetCACertificatesRequest) GetTid() string {
+	if x != nil {
+		return x.Tid
+	}
+	return ""
+}
+
+func (x *GetCACertificatesRequest) GetFormat() CertificateFormat {
+	if x != nil {
+		return x.Format
+	}
+	return CertificateFormat_CERTIFICATE_FORMAT_UNSPECIFIED
+}
+
+type GetCACertificatesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Common response header including protocol version & request identifier.
+	Header *CaResponseHeader `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	// Root CA certificate.
+	RootCertificate []byte `protobuf:"bytes,2,opt,name=root_certificate,json=rootCertificate,proto3" json:"root_certificate,omitempty"`
+	// Common signing certificate, used to sign device certificates within
+	// tenants which do not have a tenant signing certificate.
+	CommonSigningCertificate []byte `protobuf:"bytes,3,opt,name=common_signing_certificate,json=commonSigningCertificate,proto3" json:"common_signing_certificate,omitempty"`
+	// Tenant signing certificate. This is only returned if a tenant was
+	// specified and device certificates within the tenant are signed using a
+	// tenant signing certificate.
+	TenantSigningCertificate []byte `protobuf:"bytes,4,opt,name=tenant_signing_certificate,json=tenantSigningCertificate,proto3" json:"tenant_signing_certificate,omitempty"`
+	// Signing certificate followed by the root CA certificate, in leaf to root
+	// order. Each certificate above and within the chain is PEM encoded if a
+	// PEM format was requested, and DER encoded otherwise.
+	Chain [][]byte `protobuf:"bytes,5,rep,name=chain,proto3" json:"chain,omitempty"`
+	// The chain as a PKCS#7 degenerate "certs only" structure or a bundle of
+	// PEM encoded certificates, if the PKCS7 or PEM_CHAIN format was requested.
+	Bundle []byte `protobuf:"bytes,6,opt,name=bundle,proto3" json:"bundle,omitempty"`
+}
+
+func (x *GetCACertificatesResponse) Reset() {
+	*x = GetCACertificatesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ca_cert_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetCACertificatesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCACertificatesResponse) ProtoMessage() {}
+
+func (x *GetCACertificatesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ca_cert_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCACertificatesResponse.ProtoReflect.Descriptor instead.
+func (*GetCACertificatesResponse) Descriptor() ([]byte, []int) {
+	return file_ca_cert_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetCACertificatesResponse) GetHeader() *CaResponseHeader {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *GetCACertificatesResponse) GetRootCertificate() []byte {
+	if x != nil {
+		return x.RootCertificate
+	}
+	return nil
+}
+
+func (x *GetCACertificatesResponse) GetCommonSigningCertificate() []byte {
+	if x != nil {
+		return x.CommonSigningCertificate
+	}
+	return nil
+}
+
+func (x *GetCACertificatesResponse) GetTenantSigningCertificate() []byte {
+	if x != nil {
+		return x.TenantSigningCertificate
+	}
+	return nil
+}
+
+func (x *GetCACertificatesResponse) GetChain() [][]byte {
+	if x != nil {
+		return x.Chain
+	}
+	return nil
+}
+
+func (x *GetCACertificatesResponse) GetBundle() []byte {
+	if x != nil {
+		return x.Bundle
+	}
+	return nil
+}
+
+var File_ca_cert_proto protoreflect.FileDescriptor
+
+var file_ca_cert_proto_rawDesc = []byte{
+	0x0a, 0x0d, 0x63, 0x61, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
+	0x08, 0x63, 0x61, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x1a, 0x0f, 0x63, 0x61, 0x5f, 0x63, 0x6f,
+	0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x11, 0x64, 0x65, 0x76, 0x69,
+	0x63, 0x65, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xae, 0x01,
+	0x0a, 0x18, 0x47, 0x65, 0x74, 0x43, 0x41, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61,
+	0x74, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x31, 0x0a, 0x06, 0x68, 0x65,
+	0x61, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x63, 0x61, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48,
+	0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x18, 0x0a,
+	0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x69, 0x64, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x74, 0x69, 0x64, 0x12, 0x33, 0x0a, 0x06, 0x66, 0x6f, 0x72,
+	0x6d, 0x61, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x63, 0x61, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65,
+	0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x22, 0xa4,
+	0x02, 0x0a, 0x19, 0x47, 0x65, 0x74, 0x43, 0x41, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x32, 0x0a, 0x06,
+	0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63,
+	0x61, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x61, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72,
+	0x12, 0x29, 0x0a, 0x10, 0x72, 0x6f, 0x6f, 0x74, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69,
+	0x63, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0f, 0x72, 0x6f, 0x6f, 0x74,
+	0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x12, 0x3c, 0x0a, 0x1a, 0x63,
+	0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x5f, 0x73, 0x69, 0x67, 0x6e, 0x69, 0x6e, 0x67, 0x5f, 0x63, 0x65,
+	0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x18, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x53, 0x69, 0x67, 0x6e, 0x69, 0x6e, 0x67, 0x43, 0x65,
+	0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x12, 0x3c, 0x0a, 0x1a, 0x74, 0x65, 0x6e,
+	0x61, 0x6e, 0x74, 0x5f, 0x73, 0x69, 0x67, 0x6e, 0x69, 0x6e, 0x67, 0x5f, 0x63, 0x65, 0x72, 0x74,
+	0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x18, 0x74,
+	0x65, 0x6e, 0x61, 0x6e, 0x74, 0x53, 0x69, 0x67, 0x6e, 0x69, 0x6e, 0x67, 0x43, 0x65, 0x72, 0x74,
+	0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x68, 0x61, 0x69, 0x6e,
+	0x18, 0x05, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x05, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x12, 0x16, 0x0a,
+	0x06, 0x62, 0x75, 0x6e, 0x64, 0x6c, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x62,
+	0x75, 0x6e, 0x64, 0x6c, 0x65, 0x42, 0x26, 0x5a, 0x24, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2f, 0x48, 0x50, 0x49, 0x6e, 0x63, 0x2f, 0x6b, 0x72, 0x79, 0x70, 0x74, 0x6f,
+	0x6e, 0x2d, 0x63, 0x61, 0x2f, 0x63, 0x61, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_ca_cert_proto_rawDescOnce sync.Once
+	file_ca_cert_proto_rawDescData = file_ca_cert_proto_rawDesc
+)
+
+func file_ca_cert_proto_rawDescGZIP() []byte {
+	file_ca_cert_proto_rawDescOnce.Do(func() {
+		file_ca_cert_proto_rawDescData = protoimpl.X.CompressGZIP(file_ca_cert_proto_rawDescData)
+	})
+	return file_ca_cert_proto_rawDescData
+}
+
+var file_ca_cert_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_ca_cert_proto_goTypes = []interface{}{
+	(*GetCACertificatesRequest)(nil),  // 0: caprotos.GetCACertificatesRequest
+	(*GetCACertificatesResponse)(nil), // 1: caprotos.GetCACertificatesResponse
+	(*CaRequestHeader)(nil),           // 2: caprotos.CaRequestHeader
+	(CertificateFormat)(0),            // 3: caprotos.CertificateFormat
+	(*CaResponseHeader)(nil),          // 4: caprotos.CaResponseHeader
+}
+var file_ca_cert_proto_depIdxs = []int32{
+	2, // 0: caprotos.GetCACertificatesRequest.header:type_name -> caprotos.CaRequestHeader
+	3, // 1: caprotos.GetCACertificatesRequest.format:type_name -> caprotos.CertificateFormat
+	4, // 2: caprotos.GetCACertificatesResponse.header:type_name -> caprotos.CaResponseHeader
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_ca_cert_proto_init() }
+func file_ca_cert_proto_init() {
+	if File_ca_cert_proto != nil {
+		return
+	}
+	file_ca_common_proto_init()
+	file_device_cert_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_ca_cert_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetCACertificatesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ca_cert_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetCACertificatesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_ca_cert_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_ca_cert_proto_goTypes,
+		DependencyIndexes: file_ca_cert_proto_depIdxs,
+		MessageInfos:      file_ca_cert_proto_msgTypes,
+	}.Build()
+	File_ca_cert_proto = out.File
+	file_ca_cert_proto_rawDesc = nil
+	file_ca_cert_proto_goTypes = nil
+	file_ca_cert_proto_depIdxs = nil
+}
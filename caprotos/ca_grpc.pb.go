@@ -29,6 +29,16 @@ type CertificateAuthorityClient interface {
 	// Device certificate lifecycle management RPCs.
 	CreateDeviceCertificate(ctx context.Context, in *CreateDeviceCertificateRequest, opts ...grpc.CallOption) (*CreateDeviceCertificateResponse, error)
 	RenewDeviceCertificate(ctx context.Context, in *RenewDeviceCertificateRequest, opts ...grpc.CallOption) (*RenewDeviceCertificateResponse, error)
+	// Batch device certificate issuance RPCs, used to enroll many devices
+	// within a tenant at once. The streaming variant accepts the batch over
+	// several messages, and returns the results once the stream is closed.
+	CreateDeviceCertificates(ctx context.Context, in *CreateDeviceCertificatesRequest, opts ...grpc.CallOption) (*CreateDeviceCertificatesResponse, error)
+	StreamDeviceCertificates(ctx context.Context, opts ...grpc.CallOption) (CertificateAuthority_StreamDeviceCertificatesClient, error)
+	// Tenant quota management RPCs.
+	GetTenantQuota(ctx context.Context, in *GetTenantQuotaRequest, opts ...grpc.CallOption) (*GetTenantQuotaResponse, error)
+	SetTenantQuota(ctx context.Context, in *SetTenantQuotaRequest, opts ...grpc.CallOption) (*SetTenantQuotaResponse, error)
+	// CA certificate distribution RPCs.
+	GetCACertificates(ctx context.Context, in *GetCACertificatesRequest, opts ...grpc.CallOption) (*GetCACertificatesResponse, error)
 	// Health check/uptime check RPC.
 	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
 }
@@ -86,6 +96,76 @@ func (c *certificateAuthorityClient) RenewDeviceCertificate(ctx context.Context,
 	return out, nil
 }
 
+func (c *certificateAuthorityClient) CreateDeviceCertificates(ctx context.Context, in *CreateDeviceCertificatesRequest, opts ...grpc.CallOption) (*CreateDeviceCertificatesResponse, error) {
+	out := new(CreateDeviceCertificatesResponse)
+	err := c.cc.Invoke(ctx, "/caprotos.CertificateAuthority/CreateDeviceCertificates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certificateAuthorityClient) StreamDeviceCertificates(ctx context.Context, opts ...grpc.CallOption) (CertificateAuthority_StreamDeviceCertificatesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CertificateAuthority_ServiceDesc.Streams[0], "/caprotos.CertificateAuthority/StreamDeviceCertificates", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &certificateAuthorityStreamDeviceCertificatesClient{stream}
+	return x, nil
+}
+
+type CertificateAuthority_StreamDeviceCertificatesClient interface {
+	Send(*CreateDeviceCertificatesRequest) error
+	CloseAndRecv() (*CreateDeviceCertificatesResponse, error)
+	grpc.ClientStream
+}
+
+type certificateAuthorityStreamDeviceCertificatesClient struct {
+	grpc.ClientStream
+}
+
+func (x *certificateAuthorityStreamDeviceCertificatesClient) Send(m *CreateDeviceCertificatesRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *certificateAuthorityStreamDeviceCertificatesClient) CloseAndRecv() (*CreateDeviceCertificatesResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(CreateDeviceCertificatesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *certificateAuthorityClient) GetTenantQuota(ctx context.Context, in *GetTenantQuotaRequest, opts ...grpc.CallOption) (*GetTenantQuotaResponse, error) {
+	out := new(GetTenantQuotaResponse)
+	err := c.cc.Invoke(ctx, "/caprotos.CertificateAuthority/GetTenantQuota", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certificateAuthorityClient) SetTenantQuota(ctx context.Context, in *SetTenantQuotaRequest, opts ...grpc.CallOption) (*SetTenantQuotaResponse, error) {
+	out := new(SetTenantQuotaResponse)
+	err := c.cc.Invoke(ctx, "/caprotos.CertificateAuthority/SetTenantQuota", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certificateAuthorityClient) GetCACertificates(ctx context.Context, in *GetCACertificatesRequest, opts ...grpc.CallOption) (*GetCACertificatesResponse, error) {
+	out := new(GetCACertificatesResponse)
+	err := c.cc.Invoke(ctx, "/caprotos.CertificateAuthority/GetCACertificates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *certificateAuthorityClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
 	out := new(PingResponse)
 	err := c.cc.Invoke(ctx, "/caprotos.CertificateAuthority/Ping", in, out, opts...)
@@ -106,6 +186,16 @@ type CertificateAuthorityServer interface {
 	// Device certificate lifecycle management RPCs.
 	CreateDeviceCertificate(context.Context, *CreateDeviceCertificateRequest) (*CreateDeviceCertificateResponse, error)
 	RenewDeviceCertificate(context.Context, *RenewDeviceCertificateRequest) (*RenewDeviceCertificateResponse, error)
+	// Batch device certificate issuance RPCs, used to enroll many devices
+	// within a tenant at once. The streaming variant accepts the batch over
+	// several messages, and returns the results once the stream is closed.
+	CreateDeviceCertificates(context.Context, *CreateDeviceCertificatesRequest) (*CreateDeviceCertificatesResponse, error)
+	StreamDeviceCertificates(CertificateAuthority_StreamDeviceCertificatesServer) error
+	// Tenant quota management RPCs.
+	GetTenantQuota(context.Context, *GetTenantQuotaRequest) (*GetTenantQuotaResponse, error)
+	SetTenantQuota(context.Context, *SetTenantQuotaRequest) (*SetTenantQuotaResponse, error)
+	// CA certificate distribution RPCs.
+	GetCACertificates(context.Context, *GetCACertificatesRequest) (*GetCACertificatesResponse, error)
 	// Health check/uptime check RPC.
 	Ping(context.Context, *PingRequest) (*PingResponse, error)
 	mustEmbedUnimplementedCertificateAuthorityServer()
@@ -130,6 +220,21 @@ func (UnimplementedCertificateAuthorityServer) CreateDeviceCertificate(context.C
 func (UnimplementedCertificateAuthorityServer) RenewDeviceCertificate(context.Context, *RenewDeviceCertificateRequest) (*RenewDeviceCertificateResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RenewDeviceCertificate not implemented")
 }
+func (UnimplementedCertificateAuthorityServer) CreateDeviceCertificates(context.Context, *CreateDeviceCertificatesRequest) (*CreateDeviceCertificatesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateDeviceCertificates not implemented")
+}
+func (UnimplementedCertificateAuthorityServer) StreamDeviceCertificates(CertificateAuthority_StreamDeviceCertificatesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamDeviceCertificates not implemented")
+}
+func (UnimplementedCertificateAuthorityServer) GetTenantQuota(context.Context, *GetTenantQuotaRequest) (*GetTenantQuotaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTenantQuota not implemented")
+}
+func (UnimplementedCertificateAuthorityServer) SetTenantQuota(context.Context, *SetTenantQuotaRequest) (*SetTenantQuotaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetTenantQuota not implemented")
+}
+func (UnimplementedCertificateAuthorityServer) GetCACertificates(context.Context, *GetCACertificatesRequest) (*GetCACertificatesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCACertificates not implemented")
+}
 func (UnimplementedCertificateAuthorityServer) Ping(context.Context, *PingRequest) (*PingResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
 }
@@ -236,6 +341,104 @@ func _CertificateAuthority_RenewDeviceCertificate_Handler(srv interface{}, ctx c
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CertificateAuthority_CreateDeviceCertificates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateDeviceCertificatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertificateAuthorityServer).CreateDeviceCertificates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/caprotos.CertificateAuthority/CreateDeviceCertificates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertificateAuthorityServer).CreateDeviceCertificates(ctx, req.(*CreateDeviceCertificatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CertificateAuthority_StreamDeviceCertificates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CertificateAuthorityServer).StreamDeviceCertificates(&certificateAuthorityStreamDeviceCertificatesServer{stream})
+}
+
+type CertificateAuthority_StreamDeviceCertificatesServer interface {
+	SendAndClose(*CreateDeviceCertificatesResponse) error
+	Recv() (*CreateDeviceCertificatesRequest, error)
+	grpc.ServerStream
+}
+
+type certificateAuthorityStreamDeviceCertificatesServer struct {
+	grpc.ServerStream
+}
+
+func (x *certificateAuthorityStreamDeviceCertificatesServer) SendAndClose(m *CreateDeviceCertificatesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *certificateAuthorityStreamDeviceCertificatesServer) Recv() (*CreateDeviceCertificatesRequest, error) {
+	m := new(CreateDeviceCertificatesRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _CertificateAuthority_GetTenantQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTenantQuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertificateAuthorityServer).GetTenantQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/caprotos.CertificateAuthority/GetTenantQuota",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertificateAuthorityServer).GetTenantQuota(ctx, req.(*GetTenantQuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CertificateAuthority_SetTenantQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetTenantQuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertificateAuthorityServer).SetTenantQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/caprotos.CertificateAuthority/SetTenantQuota",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertificateAuthorityServer).SetTenantQuota(ctx, req.(*SetTenantQuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CertificateAuthority_GetCACertificates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCACertificatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertificateAuthorityServer).GetCACertificates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/caprotos.CertificateAuthority/GetCACertificates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertificateAuthorityServer).GetCACertificates(ctx, req.(*GetCACertificatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _CertificateAuthority_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(PingRequest)
 	if err := dec(in); err != nil {
@@ -281,11 +484,33 @@ var CertificateAuthority_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RenewDeviceCertificate",
 			Handler:    _CertificateAuthority_RenewDeviceCertificate_Handler,
 		},
+		{
+			MethodName: "CreateDeviceCertificates",
+			Handler:    _CertificateAuthority_CreateDeviceCertificates_Handler,
+		},
+		{
+			MethodName: "GetTenantQuota",
+			Handler:    _CertificateAuthority_GetTenantQuota_Handler,
+		},
+		{
+			MethodName: "SetTenantQuota",
+			Handler:    _CertificateAuthority_SetTenantQuota_Handler,
+		},
+		{
+			MethodName: "GetCACertificates",
+			Handler:    _CertificateAuthority_GetCACertificates_Handler,
+		},
 		{
 			MethodName: "Ping",
 			Handler:    _CertificateAuthority_Ping_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamDeviceCertificates",
+			Handler:       _CertificateAuthority_StreamDeviceCertificates_Handler,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "ca.proto",
 }
@@ -21,6 +21,69 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Format in which issued certificates are returned.
+type CertificateFormat int32
+
+const (
+	// Not specified - the device certificate is returned as DER bytes.
+	CertificateFormat_CERTIFICATE_FORMAT_UNSPECIFIED CertificateFormat = 0
+	// The device certificate is returned as DER bytes.
+	CertificateFormat_CERTIFICATE_FORMAT_DER CertificateFormat = 1
+	// The device certificate is returned PEM encoded.
+	CertificateFormat_CERTIFICATE_FORMAT_PEM CertificateFormat = 2
+	// The device certificate and its parent certificates are returned as a
+	// PKCS#7 degenerate "certs only" structure, in leaf to root order.
+	CertificateFormat_CERTIFICATE_FORMAT_PKCS7 CertificateFormat = 3
+	// The device certificate and its parent certificates are returned as a
+	// bundle of PEM encoded certificates, in leaf to root order.
+	CertificateFormat_CERTIFICATE_FORMAT_PEM_CHAIN CertificateFormat = 4
+)
+
+// Enum value maps for CertificateFormat.
+var (
+	CertificateFormat_name = map[int32]string{
+		0: "CERTIFICATE_FORMAT_UNSPECIFIED",
+		1: "CERTIFICATE_FORMAT_DER",
+		2: "CERTIFICATE_FORMAT_PEM",
+		3: "CERTIFICATE_FORMAT_PKCS7",
+		4: "CERTIFICATE_FORMAT_PEM_CHAIN",
+	}
+	CertificateFormat_value = map[string]int32{
+		"CERTIFICATE_FORMAT_UNSPECIFIED": 0,
+		"CERTIFICATE_FORMAT_DER":         1,
+		"CERTIFICATE_FORMAT_PEM":         2,
+		"CERTIFICATE_FORMAT_PKCS7":       3,
+		"CERTIFICATE_FORMAT_PEM_CHAIN":   4,
+	}
+)
+
+func (x CertificateFormat) Enum() *CertificateFormat {
+	p := new(CertificateFormat)
+	*p = x
+	return p
+}
+
+func (x CertificateFormat) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (CertificateFormat) Descriptor() protoreflect.EnumDescriptor {
+	return file_device_cert_proto_enumTypes[0].Descriptor()
+}
+
+func (CertificateFormat) Type() protoreflect.EnumType {
+	return &file_device_cert_proto_enumTypes[0]
+}
+
+func (x CertificateFormat) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use CertificateFormat.Descriptor instead.
+func (CertificateFormat) EnumDescriptor() ([]byte, []int) {
+	return file_device_cert_proto_rawDescGZIP(), []int{0}
+}
+
 type CreateDeviceCertificateRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -34,6 +97,8 @@ type CreateDeviceCertificateRequest struct {
 	Tid string `protobuf:"bytes,3,opt,name=tid,proto3" json:"tid,omitempty"`
 	// Certificate signing request (CSR).
 	Csr []byte `protobuf:"bytes,4,opt,name=csr,proto3" json:"csr,omitempty"`
+	// Format in which the device certificate is to be returned.
+	Format CertificateFormat `protobuf:"varint,5,opt,name=format,proto3,enum=caprotos.CertificateFormat" json:"format,omitempty"`
 }
 
 func (x *CreateDeviceCertificateRequest) Reset() {
@@ -96,6 +161,13 @@ func (x *CreateDeviceCertificateRequest) GetCsr() []byte {
 	return nil
 }
 
+func (x *CreateDeviceCertificateRequest) GetFormat() CertificateFormat {
+	if x != nil {
+		return x.Format
+	}
+	return CertificateFormat_CERTIFICATE_FORMAT_UNSPECIFIED
+}
+
 type CreateDeviceCertificateResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -109,11 +181,16 @@ type CreateDeviceCertificateResponse struct {
 	ExpiryTime *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=expiry_time,json=expiryTime,proto3" json:"expiry_time,omitempty"`
 	// Unique identifier issued to the device.
 	DeviceId string `protobuf:"bytes,4,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
-	// Device certificate (DER bytes).
+	// Device certificate, encoded in the requested format (DER bytes if no
+	// format was requested).
 	DeviceCertificate []byte `protobuf:"bytes,5,opt,name=device_certificate,json=deviceCertificate,proto3" json:"device_certificate,omitempty"`
 	// Parent certificates - tenant signing certificate and
 	// the CA certificate.
 	ParentCertificates []byte `protobuf:"bytes,6,opt,name=parent_certificates,json=parentCertificates,proto3" json:"parent_certificates,omitempty"`
+	// Device certificate followed by its parent certificates, in leaf to root
+	// order. Each certificate is PEM encoded if a PEM format was requested, and
+	// DER encoded otherwise.
+	Chain [][]byte `protobuf:"bytes,7,rep,name=chain,proto3" json:"chain,omitempty"`
 }
 
 func (x *CreateDeviceCertificateResponse) Reset() {
@@ -190,6 +267,13 @@ func (x *CreateDeviceCertificateResponse) GetParentCertificates() []byte {
 	return nil
 }
 
+func (x *CreateDeviceCertificateResponse) GetChain() [][]byte {
+	if x != nil {
+		return x.Chain
+	}
+	return nil
+}
+
 type RenewDeviceCertificateRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -205,6 +289,8 @@ type RenewDeviceCertificateRequest struct {
 	DeviceId string `protobuf:"bytes,4,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
 	// Certificate signing request (CSR).
 	Csr []byte `protobuf:"bytes,5,opt,name=csr,proto3" json:"csr,omitempty"`
+	// Format in which the device certificate is to be returned.
+	Format CertificateFormat `protobuf:"varint,6,opt,name=format,proto3,enum=caprotos.CertificateFormat" json:"format,omitempty"`
 }
 
 func (x *RenewDeviceCertificateRequest) Reset() {
@@ -274,6 +360,13 @@ func (x *RenewDeviceCertificateRequest) GetCsr() []byte {
 	return nil
 }
 
+func (x *RenewDeviceCertificateRequest) GetFormat() CertificateFormat {
+	if x != nil {
+		return x.Format
+	}
+	return CertificateFormat_CERTIFICATE_FORMAT_UNSPECIFIED
+}
+
 type RenewDeviceCertificateResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -287,11 +380,16 @@ type RenewDeviceCertificateResponse struct {
 	ExpiryTime *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=expiry_time,json=expiryTime,proto3" json:"expiry_time,omitempty"`
 	// Unique identifier issued to the device.
 	DeviceId string `protobuf:"bytes,4,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
-	// Device certificate (DER bytes).
+	// Device certificate, encoded in the requested format (DER bytes if no
+	// format was requested).
 	DeviceCertificate []byte `protobuf:"bytes,5,opt,name=device_certificate,json=deviceCertificate,proto3" json:"device_certificate,omitempty"`
 	// Parent certificates - tenant signing certificate and
 	// the CA certificate.
 	ParentCertificates []byte `protobuf:"bytes,6,opt,name=parent_certificates,json=parentCertificates,proto3" json:"parent_certificates,omitempty"`
+	// Device certificate followed by its parent certificates, in leaf to root
+	// order. Each certificate is PEM encoded if a PEM format was requested, and
+	// DER encoded otherwise.
+	Chain [][]byte `protobuf:"bytes,7,rep,name=chain,proto3" json:"chain,omitempty"`
 }
 
 func (x *RenewDeviceCertificateResponse) Reset() {
@@ -368,6 +466,382 @@ func (x *RenewDeviceCertificateResponse) GetParentCertificates() []byte {
 	return nil
 }
 
+func (x *RenewDeviceCertificateResponse) GetChain() [][]byte {
+	if x != nil {
+		return x.Chain
+	}
+	return nil
+}
+
+// A certificate signing request within a batch of device certificate
+// requests.
+type DeviceCertificateRequestItem struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Request ID of the item. Retries of an item specifying the same request ID
+	// return the device certificate issued in response to the original item.
+	// Items without a request ID are not retried idempotently.
+	RequestId string `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	// Certificate signing request (CSR).
+	Csr []byte `protobuf:"bytes,2,opt,name=csr,proto3" json:"csr,omitempty"`
+}
+
+func (x *DeviceCertificateRequestItem) Reset() {
+	*x = DeviceCertificateRequestItem{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_device_cert_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeviceCertificateRequestItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceCertificateRequestItem) ProtoMessage() {}
+
+func (x *DeviceCertificateRequestItem) ProtoReflect() protoreflect.Message {
+	mi := &file_device_cert_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceCertificateRequestItem.ProtoReflect.Descriptor instead.
+func (*DeviceCertificateRequestItem) Descriptor() ([]byte, []int) {
+	return file_device_cert_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DeviceCertificateRequestItem) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *DeviceCertificateRequestItem) GetCsr() []byte {
+	if x != nil {
+		return x.Csr
+	}
+	return nil
+}
+
+type CreateDeviceCertificatesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Common request header including protocol version & request identifier.
+	Header *CaRequestHeader `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	// Version of the CreateDeviceCertificatesRequest message.
+	Version string `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	// Unique identifier for the tenant (Tenant ID). When streaming requests,
+	// every message in the stream must specify the same tenant.
+	Tid string `protobuf:"bytes,3,opt,name=tid,proto3" json:"tid,omitempty"`
+	// Certificate signing requests for the devices to be enrolled.
+	Items []*DeviceCertificateRequestItem `protobuf:"bytes,4,rep,name=items,proto3" json:"items,omitempty"`
+	// Format in which the device certificates are to be returned. When
+	// streaming requests, the format specified in the first message is used.
+	Format CertificateFormat `protobuf:"varint,5,opt,name=format,proto3,enum=caprotos.CertificateFormat" json:"format,omitempty"`
+}
+
+func (x *CreateDeviceCertificatesRequest) Reset() {
+	*x = CreateDeviceCertificatesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_device_cert_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateDeviceCertificatesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateDeviceCertificatesRequest) ProtoMessage() {}
+
+func (x *CreateDeviceCertificatesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_device_cert_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateDeviceCertificatesRequest.ProtoReflect.Descriptor instead.
+func (*CreateDeviceCertificatesRequest) Descriptor() ([]byte, []int) {
+	return file_device_cert_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateDeviceCertificatesRequest) GetHeader() *CaRequestHeader {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *CreateDeviceCertificatesRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *CreateDeviceCertificatesRequest) GetTid() string {
+	if x != nil {
+		return x.Tid
+	}
+	return ""
+}
+
+func (x *CreateDeviceCertificatesRequest) GetItems() []*DeviceCertificateRequestItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *CreateDeviceCertificatesRequest) GetFormat() CertificateFormat {
+	if x != nil {
+		return x.Format
+	}
+	return CertificateFormat_CERTIFICATE_FORMAT_UNSPECIFIED
+}
+
+// Result of a single item within a batch of device certificate requests.
+type DeviceCertificateResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Position of the item within the batch. When streaming requests, items
+	// are numbered across all messages in the stream.
+	Index uint32 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	// Request ID specified for the item.
+	RequestId string `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	// Status code for the item. Only valid gRPC response codes are supported.
+	Status uint32 `protobuf:"varint,3,opt,name=status,proto3" json:"status,omitempty"`
+	// More information about the status of the item.
+	StatusMessage string `protobuf:"bytes,4,opt,name=status_message,json=statusMessage,proto3" json:"status_message,omitempty"`
+	// Reason code describing why the item failed, if it did. These are the
+	// reason codes reported in the ErrorInfo details of gRPC status errors.
+	Reason string `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	// Device certificate issued timestamp.
+	IssuedTime *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=issued_time,json=issuedTime,proto3" json:"issued_time,omitempty"`
+	// Device certificate expiry timestamp.
+	ExpiryTime *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=expiry_time,json=expiryTime,proto3" json:"expiry_time,omitempty"`
+	// Unique identifier issued to the device.
+	DeviceId string `protobuf:"bytes,8,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	// Device certificate, encoded in the requested format (DER bytes if no
+	// format was requested).
+	DeviceCertificate []byte `protobuf:"bytes,9,opt,name=device_certificate,json=deviceCertificate,proto3" json:"device_certificate,omitempty"`
+	// Parent certificates - tenant signing certificate and
+	// the CA certificate.
+	ParentCertificates []byte `protobuf:"bytes,10,opt,name=parent_certificates,json=parentCertificates,proto3" json:"parent_certificates,omitempty"`
+	// Device certificate followed by its parent certificates, in leaf to root
+	// order. Each certificate is PEM encoded if a PEM format was requested, and
+	// DER encoded otherwise.
+	Chain [][]byte `protobuf:"bytes,11,rep,name=chain,proto3" json:"chain,omitempty"`
+}
+
+func (x *DeviceCertificateResult) Reset() {
+	*x = DeviceCertificateResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_device_cert_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeviceCertificateResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceCertificateResult) ProtoMessage() {}
+
+func (x *DeviceCertificateResult) ProtoReflect() protoreflect.Message {
+	mi := &file_device_cert_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceCertificateResult.ProtoReflect.Descriptor instead.
+func (*DeviceCertificateResult) Descriptor() ([]byte, []int) {
+	return file_device_cert_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DeviceCertificateResult) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *DeviceCertificateResult) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *DeviceCertificateResult) GetStatus() uint32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *DeviceCertificateResult) GetStatusMessage() string {
+	if x != nil {
+		return x.StatusMessage
+	}
+	return ""
+}
+
+func (x *DeviceCertificateResult) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *DeviceCertificateResult) GetIssuedTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.IssuedTime
+	}
+	return nil
+}
+
+func (x *DeviceCertificateResult) GetExpiryTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiryTime
+	}
+	return nil
+}
+
+func (x *DeviceCertificateResult) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *DeviceCertificateResult) GetDeviceCertificate() []byte {
+	if x != nil {
+		return x.DeviceCertificate
+	}
+	return nil
+}
+
+func (x *DeviceCertificateResult) GetParentCertificates() []byte {
+	if x != nil {
+		return x.ParentCertificates
+	}
+	return nil
+}
+
+func (x *DeviceCertificateResult) GetChain() [][]byte {
+	if x != nil {
+		return x.Chain
+	}
+	return nil
+}
+
+type CreateDeviceCertificatesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Common response header including protocol version & request identifier.
+	// The status is OK if the batch was processed, even if some of the items
+	// failed - the status of each item is reported in its result.
+	Header *CaResponseHeader `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	// Results of the items, in the order in which they were requested.
+	Results []*DeviceCertificateResult `protobuf:"bytes,2,rep,name=results,proto3" json:"results,omitempty"`
+	// Number of items for which a device certificate was issued.
+	Succeeded uint32 `protobuf:"varint,3,opt,name=succeeded,proto3" json:"succeeded,omitempty"`
+	// Number of items which failed.
+	Failed uint32 `protobuf:"varint,4,opt,name=failed,proto3" json:"failed,omitempty"`
+}
+
+func (x *CreateDeviceCertificatesResponse) Reset() {
+	*x = CreateDeviceCertificatesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_device_cert_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateDeviceCertificatesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateDeviceCertificatesResponse) ProtoMessage() {}
+
+func (x *CreateDeviceCertificatesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_device_cert_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateDeviceCertificatesResponse.ProtoReflect.Descriptor instead.
+func (*CreateDeviceCertificatesResponse) Descriptor() ([]byte, []int) {
+	return file_device_cert_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CreateDeviceCertificatesResponse) GetHeader() *CaResponseHeader {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *CreateDeviceCertificatesResponse) GetResults() []*DeviceCertificateResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *CreateDeviceCertificatesResponse) GetSucceeded() uint32 {
+	if x != nil {
+		return x.Succeeded
+	}
+	return 0
+}
+
+func (x *CreateDeviceCertificatesResponse) GetFailed() uint32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
 var File_device_cert_proto protoreflect.FileDescriptor
 
 var file_device_cert_proto_rawDesc = []byte{
@@ -376,7 +850,7 @@ var file_device_cert_proto_rawDesc = []byte{
 	0x61, 0x5f, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1f,
 	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f,
 	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
-	0x91, 0x01, 0x0a, 0x1e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65,
+	0xc6, 0x01, 0x0a, 0x1e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65,
 	0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
 	0x73, 0x74, 0x12, 0x31, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01,
 	0x28, 0x0b, 0x32, 0x19, 0x2e, 0x63, 0x61, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x61,
@@ -385,39 +859,47 @@ var file_device_cert_proto_rawDesc = []byte{
 	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
 	0x10, 0x0a, 0x03, 0x74, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x74, 0x69,
 	0x64, 0x12, 0x10, 0x0a, 0x03, 0x63, 0x73, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03,
-	0x63, 0x73, 0x72, 0x22, 0xcc, 0x02, 0x0a, 0x1f, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x44, 0x65,
-	0x76, 0x69, 0x63, 0x65, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52,
-	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x32, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65,
-	0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x61, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x73, 0x2e, 0x43, 0x61, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x65, 0x61,
-	0x64, 0x65, 0x72, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x3b, 0x0a, 0x0b, 0x69,
-	0x73, 0x73, 0x75, 0x65, 0x64, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
-	0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x69, 0x73,
-	0x73, 0x75, 0x65, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x3b, 0x0a, 0x0b, 0x65, 0x78, 0x70, 0x69,
-	0x72, 0x79, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
-	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
-	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x65, 0x78, 0x70, 0x69, 0x72,
-	0x79, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f,
-	0x69, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65,
-	0x49, 0x64, 0x12, 0x2d, 0x0a, 0x12, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x63, 0x65, 0x72,
-	0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x11,
-	0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74,
-	0x65, 0x12, 0x2f, 0x0a, 0x13, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x5f, 0x63, 0x65, 0x72, 0x74,
-	0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x12,
-	0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74,
-	0x65, 0x73, 0x22, 0xad, 0x01, 0x0a, 0x1d, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x44, 0x65, 0x76, 0x69,
-	0x63, 0x65, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x12, 0x31, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x63, 0x61, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e,
-	0x43, 0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52,
-	0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69,
-	0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
-	0x6e, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
-	0x74, 0x69, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x69, 0x64,
-	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x64,
-	0x12, 0x10, 0x0a, 0x03, 0x63, 0x73, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x63,
-	0x73, 0x72, 0x22, 0xcb, 0x02, 0x0a, 0x1e, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x44, 0x65, 0x76, 0x69,
+	0x63, 0x73, 0x72, 0x12, 0x33, 0x0a, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x63, 0x61, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43,
+	0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74,
+	0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x22, 0xe2, 0x02, 0x0a, 0x1f, 0x43, 0x72, 0x65,
+	0x61, 0x74, 0x65, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69,
+	0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x32, 0x0a, 0x06,
+	0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63,
+	0x61, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x61, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72,
+	0x12, 0x3b, 0x0a, 0x0b, 0x69, 0x73, 0x73, 0x75, 0x65, 0x64, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x52, 0x0a, 0x69, 0x73, 0x73, 0x75, 0x65, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x3b, 0x0a,
+	0x0b, 0x65, 0x78, 0x70, 0x69, 0x72, 0x79, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a,
+	0x65, 0x78, 0x70, 0x69, 0x72, 0x79, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x65,
+	0x76, 0x69, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x64,
+	0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x64, 0x12, 0x2d, 0x0a, 0x12, 0x64, 0x65, 0x76, 0x69, 0x63,
+	0x65, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x11, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x43, 0x65, 0x72, 0x74, 0x69,
+	0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x12, 0x2f, 0x0a, 0x13, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74,
+	0x5f, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x73, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x12, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x69,
+	0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x68, 0x61, 0x69, 0x6e,
+	0x18, 0x07, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x05, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x22, 0xe2, 0x01,
+	0x0a, 0x1d, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x43, 0x65, 0x72,
+	0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x31, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x19, 0x2e, 0x63, 0x61, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x61, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64,
+	0x65, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x10, 0x0a, 0x03,
+	0x74, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x74, 0x69, 0x64, 0x12, 0x1b,
+	0x0a, 0x09, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x08, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x63,
+	0x73, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x63, 0x73, 0x72, 0x12, 0x33, 0x0a,
+	0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e,
+	0x63, 0x61, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69,
+	0x63, 0x61, 0x74, 0x65, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d,
+	0x61, 0x74, 0x22, 0xe1, 0x02, 0x0a, 0x1e, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x44, 0x65, 0x76, 0x69,
 	0x63, 0x65, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73,
 	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x32, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18,
 	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x61, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73,
@@ -438,10 +920,82 @@ var file_device_cert_proto_rawDesc = []byte{
 	0x2f, 0x0a, 0x13, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66,
 	0x69, 0x63, 0x61, 0x74, 0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x12, 0x70, 0x61,
 	0x72, 0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x73,
-	0x42, 0x2b, 0x5a, 0x29, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x61, 0x7a, 0x63, 0x2e, 0x65,
-	0x78, 0x74, 0x2e, 0x68, 0x70, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x4b, 0x72, 0x79, 0x70, 0x74, 0x6f,
-	0x6e, 0x2f, 0x63, 0x61, 0x2f, 0x63, 0x61, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x62, 0x06, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x12, 0x14, 0x0a, 0x05, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0c, 0x52,
+	0x05, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x22, 0x4f, 0x0a, 0x1c, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65,
+	0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x49, 0x74, 0x65, 0x6d, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x72, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x63, 0x73, 0x72, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x03, 0x63, 0x73, 0x72, 0x22, 0xf3, 0x01, 0x0a, 0x1f, 0x43, 0x72, 0x65, 0x61,
+	0x74, 0x65, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x31, 0x0a, 0x06, 0x68,
+	0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x63, 0x61,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x43, 0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x18,
+	0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x69, 0x64, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x74, 0x69, 0x64, 0x12, 0x3c, 0x0a, 0x05, 0x69, 0x74,
+	0x65, 0x6d, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x26, 0x2e, 0x63, 0x61, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x43, 0x65, 0x72, 0x74, 0x69,
+	0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x74, 0x65,
+	0x6d, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x12, 0x33, 0x0a, 0x06, 0x66, 0x6f, 0x72, 0x6d,
+	0x61, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x63, 0x61, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x73, 0x2e, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x46,
+	0x6f, 0x72, 0x6d, 0x61, 0x74, 0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x22, 0xb2, 0x03,
+	0x0a, 0x17, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64,
+	0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12,
+	0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x09, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x12, 0x16,
+	0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x16, 0x0a,
+	0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72,
+	0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x3b, 0x0a, 0x0b, 0x69, 0x73, 0x73, 0x75, 0x65, 0x64, 0x5f,
+	0x74, 0x69, 0x6d, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x69, 0x73, 0x73, 0x75, 0x65, 0x64, 0x54, 0x69,
+	0x6d, 0x65, 0x12, 0x3b, 0x0a, 0x0b, 0x65, 0x78, 0x70, 0x69, 0x72, 0x79, 0x5f, 0x74, 0x69, 0x6d,
+	0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74,
+	0x61, 0x6d, 0x70, 0x52, 0x0a, 0x65, 0x78, 0x70, 0x69, 0x72, 0x79, 0x54, 0x69, 0x6d, 0x65, 0x12,
+	0x1b, 0x0a, 0x09, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x08, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x64, 0x12, 0x2d, 0x0a, 0x12,
+	0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61,
+	0x74, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x11, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65,
+	0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x12, 0x2f, 0x0a, 0x13, 0x70,
+	0x61, 0x72, 0x65, 0x6e, 0x74, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74,
+	0x65, 0x73, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x12, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74,
+	0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x73, 0x12, 0x14, 0x0a, 0x05,
+	0x63, 0x68, 0x61, 0x69, 0x6e, 0x18, 0x0b, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x05, 0x63, 0x68, 0x61,
+	0x69, 0x6e, 0x22, 0xc9, 0x01, 0x0a, 0x20, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x44, 0x65, 0x76,
+	0x69, 0x63, 0x65, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x73, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x32, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65,
+	0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x61, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x73, 0x2e, 0x43, 0x61, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x65, 0x61,
+	0x64, 0x65, 0x72, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x3b, 0x0a, 0x07, 0x72,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x63,
+	0x61, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x43, 0x65,
+	0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52,
+	0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x75, 0x63, 0x63,
+	0x65, 0x65, 0x64, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x73, 0x75, 0x63,
+	0x63, 0x65, 0x65, 0x64, 0x65, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x66, 0x61, 0x69, 0x6c, 0x65, 0x64,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x2a, 0xaf,
+	0x01, 0x0a, 0x11, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x46, 0x6f,
+	0x72, 0x6d, 0x61, 0x74, 0x12, 0x22, 0x0a, 0x1e, 0x43, 0x45, 0x52, 0x54, 0x49, 0x46, 0x49, 0x43,
+	0x41, 0x54, 0x45, 0x5f, 0x46, 0x4f, 0x52, 0x4d, 0x41, 0x54, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45,
+	0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x1a, 0x0a, 0x16, 0x43, 0x45, 0x52, 0x54,
+	0x49, 0x46, 0x49, 0x43, 0x41, 0x54, 0x45, 0x5f, 0x46, 0x4f, 0x52, 0x4d, 0x41, 0x54, 0x5f, 0x44,
+	0x45, 0x52, 0x10, 0x01, 0x12, 0x1a, 0x0a, 0x16, 0x43, 0x45, 0x52, 0x54, 0x49, 0x46, 0x49, 0x43,
+	0x41, 0x54, 0x45, 0x5f, 0x46, 0x4f, 0x52, 0x4d, 0x41, 0x54, 0x5f, 0x50, 0x45, 0x4d, 0x10, 0x02,
+	0x12, 0x1c, 0x0a, 0x18, 0x43, 0x45, 0x52, 0x54, 0x49, 0x46, 0x49, 0x43, 0x41, 0x54, 0x45, 0x5f,
+	0x46, 0x4f, 0x52, 0x4d, 0x41, 0x54, 0x5f, 0x50, 0x4b, 0x43, 0x53, 0x37, 0x10, 0x03, 0x12, 0x20,
+	0x0a, 0x1c, 0x43, 0x45, 0x52, 0x54, 0x49, 0x46, 0x49, 0x43, 0x41, 0x54, 0x45, 0x5f, 0x46, 0x4f,
+	0x52, 0x4d, 0x41, 0x54, 0x5f, 0x50, 0x45, 0x4d, 0x5f, 0x43, 0x48, 0x41, 0x49, 0x4e, 0x10, 0x04,
+	0x42, 0x26, 0x5a, 0x24, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x48,
+	0x50, 0x49, 0x6e, 0x63, 0x2f, 0x6b, 0x72, 0x79, 0x70, 0x74, 0x6f, 0x6e, 0x2d, 0x63, 0x61, 0x2f,
+	0x63, 0x61, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -456,30 +1010,45 @@ func file_device_cert_proto_rawDescGZIP() []byte {
 	return file_device_cert_proto_rawDescData
 }
 
-var file_device_cert_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_device_cert_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_device_cert_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_device_cert_proto_goTypes = []interface{}{
-	(*CreateDeviceCertificateRequest)(nil),  // 0: caprotos.CreateDeviceCertificateRequest
-	(*CreateDeviceCertificateResponse)(nil), // 1: caprotos.CreateDeviceCertificateResponse
-	(*RenewDeviceCertificateRequest)(nil),   // 2: caprotos.RenewDeviceCertificateRequest
-	(*RenewDeviceCertificateResponse)(nil),  // 3: caprotos.RenewDeviceCertificateResponse
-	(*CaRequestHeader)(nil),                 // 4: caprotos.CaRequestHeader
-	(*CaResponseHeader)(nil),                // 5: caprotos.CaResponseHeader
-	(*timestamppb.Timestamp)(nil),           // 6: google.protobuf.Timestamp
+	(CertificateFormat)(0),                   // 0: caprotos.CertificateFormat
+	(*CreateDeviceCertificateRequest)(nil),   // 1: caprotos.CreateDeviceCertificateRequest
+	(*CreateDeviceCertificateResponse)(nil),  // 2: caprotos.CreateDeviceCertificateResponse
+	(*RenewDeviceCertificateRequest)(nil),    // 3: caprotos.RenewDeviceCertificateRequest
+	(*RenewDeviceCertificateResponse)(nil),   // 4: caprotos.RenewDeviceCertificateResponse
+	(*DeviceCertificateRequestItem)(nil),     // 5: caprotos.DeviceCertificateRequestItem
+	(*CreateDeviceCertificatesRequest)(nil),  // 6: caprotos.CreateDeviceCertificatesRequest
+	(*DeviceCertificateResult)(nil),          // 7: caprotos.DeviceCertificateResult
+	(*CreateDeviceCertificatesResponse)(nil), // 8: caprotos.CreateDeviceCertificatesResponse
+	(*CaRequestHeader)(nil),                  // 9: caprotos.CaRequestHeader
+	(*CaResponseHeader)(nil),                 // 10: caprotos.CaResponseHeader
+	(*timestamppb.Timestamp)(nil),            // 11: google.protobuf.Timestamp
 }
 var file_device_cert_proto_depIdxs = []int32{
-	4, // 0: caprotos.CreateDeviceCertificateRequest.header:type_name -> caprotos.CaRequestHeader
-	5, // 1: caprotos.CreateDeviceCertificateResponse.header:type_name -> caprotos.CaResponseHeader
-	6, // 2: caprotos.CreateDeviceCertificateResponse.issued_time:type_name -> google.protobuf.Timestamp
-	6, // 3: caprotos.CreateDeviceCertificateResponse.expiry_time:type_name -> google.protobuf.Timestamp
-	4, // 4: caprotos.RenewDeviceCertificateRequest.header:type_name -> caprotos.CaRequestHeader
-	5, // 5: caprotos.RenewDeviceCertificateResponse.header:type_name -> caprotos.CaResponseHeader
-	6, // 6: caprotos.RenewDeviceCertificateResponse.issued_time:type_name -> google.protobuf.Timestamp
-	6, // 7: caprotos.RenewDeviceCertificateResponse.expiry_time:type_name -> google.protobuf.Timestamp
-	8, // [8:8] is the sub-list for method output_type
-	8, // [8:8] is the sub-list for method input_type
-	8, // [8:8] is the sub-list for extension type_name
-	8, // [8:8] is the sub-list for extension extendee
-	0, // [0:8] is the sub-list for field type_name
+	9,  // 0: caprotos.CreateDeviceCertificateRequest.header:type_name -> caprotos.CaRequestHeader
+	0,  // 1: caprotos.CreateDeviceCertificateRequest.format:type_name -> caprotos.CertificateFormat
+	10, // 2: caprotos.CreateDeviceCertificateResponse.header:type_name -> caprotos.CaResponseHeader
+	11, // 3: caprotos.CreateDeviceCertificateResponse.issued_time:type_name -> google.protobuf.Timestamp
+	11, // 4: caprotos.CreateDeviceCertificateResponse.expiry_time:type_name -> google.protobuf.Timestamp
+	9,  // 5: caprotos.RenewDeviceCertificateRequest.header:type_name -> caprotos.CaRequestHeader
+	0,  // 6: caprotos.RenewDeviceCertificateRequest.format:type_name -> caprotos.CertificateFormat
+	10, // 7: caprotos.RenewDeviceCertificateResponse.header:type_name -> caprotos.CaResponseHeader
+	11, // 8: caprotos.RenewDeviceCertificateResponse.issued_time:type_name -> google.protobuf.Timestamp
+	11, // 9: caprotos.RenewDeviceCertificateResponse.expiry_time:type_name -> google.protobuf.Timestamp
+	9,  // 10: caprotos.CreateDeviceCertificatesRequest.header:type_name -> caprotos.CaRequestHeader
+	5,  // 11: caprotos.CreateDeviceCertificatesRequest.items:type_name -> caprotos.DeviceCertificateRequestItem
+	0,  // 12: caprotos.CreateDeviceCertificatesRequest.format:type_name -> caprotos.CertificateFormat
+	11, // 13: caprotos.DeviceCertificateResult.issued_time:type_name -> google.protobuf.Timestamp
+	11, // 14: caprotos.DeviceCertificateResult.expiry_time:type_name -> google.protobuf.Timestamp
+	10, // 15: caprotos.CreateDeviceCertificatesResponse.header:type_name -> caprotos.CaResponseHeader
+	7,  // 16: caprotos.CreateDeviceCertificatesResponse.results:type_name -> caprotos.DeviceCertificateResult
+	17, // [17:17] is the sub-list for method output_type
+	17, // [17:17] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
 }
 
 func init() { file_device_cert_proto_init() }
@@ -537,19 +1106,68 @@ func file_device_cert_proto_init() {
 				return nil
 			}
 		}
+		file_device_cert_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeviceCertificateRequestItem); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_device_cert_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CreateDeviceCertificatesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_device_cert_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeviceCertificateResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_device_cert_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CreateDeviceCertificatesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_device_cert_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      1,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_device_cert_proto_goTypes,
 		DependencyIndexes: file_device_cert_proto_depIdxs,
+		EnumInfos:         file_device_cert_proto_enumTypes,
 		MessageInfos:      file_device_cert_proto_msgTypes,
 	}.Build()
 	File_device_cert_proto = out.File
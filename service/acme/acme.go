@@ -0,0 +1,271 @@
+// package github.com/HPInc/krypton-ca/service/acme
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements an ACME (RFC 8555) server, which allows internal services and
+// agents using off-the-shelf ACME clients to obtain certificates from the
+// Krypton hierarchy. Each tenant is served by a separate ACME directory, and
+// certificates are issued using the tenant's signing key through the KMS
+// provider. Control of identifiers is proven using pluggable challenge
+// validators. Accounts, orders, authorizations and used nonces are persisted
+// in the certificate store.
+package acme
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
+	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+var (
+	caLogger *zap.Logger
+)
+
+const (
+	// Names of the path variables used by ACME routes.
+	pathVarTenant        = "tenant"
+	pathVarID            = "id"
+	pathVarAuthorization = "authz"
+
+	// Resource paths served by the ACME server, relative to the tenant's
+	// ACME directory.
+	pathDirectory     = "/directory"
+	pathNewNonce      = "/new-nonce"
+	pathNewAccount    = "/new-account"
+	pathAccount       = "/account/"
+	pathNewOrder      = "/new-order"
+	pathOrder         = "/order/"
+	pathFinalize      = "/finalize"
+	pathAuthorization = "/authz/"
+	pathChallenge     = "/chall/"
+	pathCertificate   = "/cert/"
+
+	// Prefix of the path of each tenant's ACME directory.
+	acmePathPrefix = "/acme/"
+
+	// Content types used by the ACME server.
+	headerContentType          = "Content-Type"
+	headerReplayNonce          = "Replay-Nonce"
+	headerLocation             = "Location"
+	headerLink                 = "Link"
+	headerCacheControl         = "Cache-Control"
+	headerRetryAfter           = "Retry-After"
+	contentTypeJose            = "application/jose+json"
+	contentTypeJson            = "application/json"
+	contentTypeProblem         = "application/problem+json"
+	contentTypePemCertificates = "application/pem-certificate-chain"
+
+	// Lifetime of orders and authorizations which have not been completed.
+	orderLifetime = 7 * 24 * time.Hour
+
+	// Lifetime of nonces issued by the ACME server.
+	nonceLifetime = time.Hour
+
+	// Maximum size of the body of ACME requests.
+	maxRequestSize = 64 * 1024
+
+	// Operations subject to the rate limits of the CA, used to report
+	// throttled requests.
+	rateLimitNewAccount = "acme/new-account"
+	rateLimitNewOrder   = "acme/new-order"
+	rateLimitChallenge  = "acme/challenge"
+	rateLimitFinalize   = "acme/finalize"
+)
+
+// Route - describes a route served by the ACME server.
+type Route struct {
+	Name        string           // Name of the route
+	Method      string           // REST method
+	Path        string           // Resource path
+	HandlerFunc http.HandlerFunc // Request handler function.
+}
+
+// RateLimiter - limits the rate of requests received on behalf of each tenant,
+// and by each caller.
+type RateLimiter interface {
+	// AllowRequest - checks whether the request to perform the specified
+	// operation on behalf of the tenant is permitted. If not, the time after
+	// which the caller may retry is returned.
+	AllowRequest(r *http.Request, operation string,
+		tenantID string) (bool, time.Duration)
+}
+
+// Server - the ACME server. Certificates are issued using the KMS provider
+// once the account has proven control of the identifiers in the order.
+type Server struct {
+	// KMS provider used to issue certificates.
+	kmsProvider kms_providers.KmsProvider
+
+	// Certificate store used to persist the state of the ACME server.
+	store certstore.CertStore
+
+	// Quota manager used to enforce per-tenant device caps.
+	quotaManager *quota.Manager
+
+	// Rate limiter applied to requests which create accounts and orders, and
+	// which validate challenges and finalize orders. This is nil if requests
+	// are not rate limited.
+	rateLimiter RateLimiter
+
+	// ACME server settings from the configuration file.
+	settings *config.Acme
+
+	// Challenge validators, keyed by the type of challenge they validate.
+	validators map[string]ChallengeValidator
+
+	// Key used to authenticate the nonces issued by the server.
+	nonceKey []byte
+}
+
+// NewServer - initialize a new ACME server which issues certificates using the
+// specified KMS provider. Requests are subject to the specified rate limiter,
+// if any. Challenges of the types validated by the specified challenge
+// validators are offered to clients.
+func NewServer(logger *zap.Logger, provider kms_providers.KmsProvider,
+	store certstore.CertStore, quotaManager *quota.Manager,
+	rateLimiter RateLimiter, settings *config.Acme,
+	validators ...ChallengeValidator) *Server {
+	caLogger = logger
+
+	s := &Server{
+		kmsProvider:  provider,
+		store:        store,
+		quotaManager: quotaManager,
+		rateLimiter:  rateLimiter,
+		settings:     settings,
+		validators:   map[string]ChallengeValidator{},
+		nonceKey:     deriveNonceKey(settings.TokenSecret),
+	}
+	for _, validator := range validators {
+		s.validators[validator.Type()] = validator
+	}
+	return s
+}
+
+// Routes - returns the routes served by the ACME server, which are mounted on
+// the REST server's request router.
+func (s *Server) Routes() []Route {
+	prefix := acmePathPrefix + "{" + pathVarTenant + "}"
+	return []Route{
+		{"GetAcmeDirectory", http.MethodGet, prefix + pathDirectory,
+			s.GetDirectoryHandler},
+		{"HeadAcmeNewNonce", http.MethodHead, prefix + pathNewNonce,
+			s.NewNonceHandler},
+		{"GetAcmeNewNonce", http.MethodGet, prefix + pathNewNonce,
+			s.NewNonceHandler},
+		{"AcmeNewAccount", http.MethodPost, prefix + pathNewAccount,
+			s.NewAccountHandler},
+		{"AcmeAccount", http.MethodPost,
+			prefix + pathAccount + "{" + pathVarID + "}", s.AccountHandler},
+		{"AcmeNewOrder", http.MethodPost, prefix + pathNewOrder,
+			s.NewOrderHandler},
+		{"AcmeOrder", http.MethodPost,
+			prefix + pathOrder + "{" + pathVarID + "}", s.OrderHandler},
+		{"AcmeFinalizeOrder", http.MethodPost,
+			prefix + pathOrder + "{" + pathVarID + "}" + pathFinalize,
+			s.FinalizeOrderHandler},
+		{"AcmeAuthorization", http.MethodPost,
+			prefix + pathAuthorization + "{" + pathVarID + "}",
+			s.AuthorizationHandler},
+		{"AcmeChallenge", http.MethodPost,
+			prefix + pathChallenge + "{" + pathVarAuthorization + "}/{" + pathVarID + "}",
+			s.ChallengeHandler},
+		{"AcmeCertificate", http.MethodPost,
+			prefix + pathCertificate + "{" + pathVarID + "}",
+			s.CertificateHandler},
+	}
+}
+
+// Returns the URL at which clients reach the REST server. Unless configured,
+// this is determined from the request.
+func (s *Server) baseURL(r *http.Request) string {
+	baseURL := strings.TrimSuffix(s.settings.BaseUrl, "/")
+	if baseURL == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		baseURL = scheme + "://" + r.Host
+	}
+	return baseURL
+}
+
+// Returns the URL of the tenant's ACME directory, which all other resource
+// URLs are relative to.
+func (s *Server) tenantURL(r *http.Request, tenantID string) string {
+	return s.baseURL(r) + acmePathPrefix + tenantID
+}
+
+// Returns the URL of the resource at the specified path within the tenant's
+// ACME directory.
+func (s *Server) resourceURL(r *http.Request, tenantID string,
+	path string) string {
+	return s.tenantURL(r, tenantID) + path
+}
+
+// Returns the tenant specified in the request path.
+func tenantFromRequest(r *http.Request) string {
+	return mux.Vars(r)[pathVarTenant]
+}
+
+// Write a JSON response. A fresh nonce is returned with every response, so
+// that clients don't need to request a nonce before their next request.
+func (s *Server) writeResponse(w http.ResponseWriter, r *http.Request,
+	statusCode int, location string, body interface{}) {
+	s.addNonce(w)
+	w.Header().Set(headerLink, fmt.Sprintf("<%s>;rel=\"index\"",
+		s.resourceURL(r, tenantFromRequest(r), pathDirectory)))
+	if location != "" {
+		w.Header().Set(headerLocation, location)
+	}
+
+	w.Header().Set(headerContentType, contentTypeJson)
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		caLogger.Error("ACME: Failed to encode the response!",
+			zap.Error(err),
+		)
+	}
+}
+
+// Check whether the request to perform the specified operation is permitted by
+// the rate limiter. If not, the client is asked to retry later and a problem
+// document describing the failure is returned.
+func (s *Server) checkRateLimit(w http.ResponseWriter, r *http.Request,
+	operation string) error {
+	if s.rateLimiter == nil {
+		return nil
+	}
+
+	ok, delay := s.rateLimiter.AllowRequest(r, operation, tenantFromRequest(r))
+	if ok {
+		return nil
+	}
+	w.Header().Set(headerRetryAfter,
+		strconv.Itoa(int(math.Max(1, math.Ceil(delay.Seconds())))))
+	return newProblem(problemRateLimited, http.StatusTooManyRequests,
+		"request rate limit exceeded")
+}
+
+// Add a fresh nonce to the response.
+func (s *Server) addNonce(w http.ResponseWriter) {
+	nonce, err := s.newNonce()
+	if err != nil {
+		return
+	}
+	w.Header().Set(headerReplayNonce, nonce)
+	w.Header().Set(headerCacheControl, "no-store")
+}
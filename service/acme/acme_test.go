@@ -0,0 +1,512 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/certmgr"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const testTokenSecret = "acme-test-token-secret"
+
+var (
+	gTestServer   *httptest.Server
+	gTestTenantID string
+)
+
+// A minimal ACME client used to exercise the ACME server.
+type testClient struct {
+	t          *testing.T
+	key        *ecdsa.PrivateKey
+	accountURL string
+	nonce      string
+}
+
+func newTestClient(t *testing.T) *testClient {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate the account key: %v", err)
+	}
+	return &testClient{t: t, key: key}
+}
+
+func (c *testClient) url(path string) string {
+	return fmt.Sprintf("%s/acme/%s%s", gTestServer.URL, gTestTenantID, path)
+}
+
+// Nonce - returns the nonce to be used for the next request.
+func (c *testClient) Nonce() (string, error) {
+	if c.nonce != "" {
+		nonce := c.nonce
+		c.nonce = ""
+		return nonce, nil
+	}
+
+	response, err := http.Head(c.url(pathNewNonce))
+	if err != nil {
+		return "", err
+	}
+	response.Body.Close()
+	return response.Header.Get(headerReplayNonce), nil
+}
+
+// Sign the payload and post it to the specified URL. A nil payload results in
+// a POST-as-GET request.
+func (c *testClient) post(url string, payload interface{}) (*http.Response, []byte) {
+	options := &jose.SignerOptions{
+		NonceSource:  c,
+		ExtraHeaders: map[jose.HeaderKey]interface{}{headerUrl: url},
+	}
+	if c.accountURL == "" {
+		options.EmbedJWK = true
+	} else {
+		options.ExtraHeaders["kid"] = c.accountURL
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256,
+		Key: c.key}, options)
+	if err != nil {
+		c.t.Fatalf("Failed to create the signer: %v", err)
+	}
+
+	data := []byte{}
+	if payload != nil {
+		data, err = json.Marshal(payload)
+		if err != nil {
+			c.t.Fatalf("Failed to encode the payload: %v", err)
+		}
+	}
+	jws, err := signer.Sign(data)
+	if err != nil {
+		c.t.Fatalf("Failed to sign the request: %v", err)
+	}
+
+	response, err := http.Post(url, contentTypeJose,
+		bytes.NewReader([]byte(jws.FullSerialize())))
+	if err != nil {
+		c.t.Fatalf("ACME request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		c.t.Fatalf("Failed to read the response: %v", err)
+	}
+	c.nonce = response.Header.Get(headerReplayNonce)
+	return response, body
+}
+
+// Post the payload and decode the response, which is expected to have the
+// specified status.
+func (c *testClient) postExpect(url string, payload interface{},
+	expectedStatus int, result interface{}) *http.Response {
+	response, body := c.post(url, payload)
+	if response.StatusCode != expectedStatus {
+		c.t.Fatalf("Expected status %d from %s, got %d: %s", expectedStatus,
+			url, response.StatusCode, body)
+	}
+	if result != nil {
+		err := json.Unmarshal(body, result)
+		if err != nil {
+			c.t.Fatalf("Failed to decode the response: %v", err)
+		}
+	}
+	return response
+}
+
+// Post the payload and check that the request fails with the specified
+// problem type.
+func (c *testClient) postExpectProblem(url string, payload interface{},
+	problemType string) {
+	response, body := c.post(url, payload)
+	var p Problem
+	err := json.Unmarshal(body, &p)
+	if err != nil {
+		c.t.Fatalf("Failed to decode the problem document: %v", err)
+	}
+	if (response.Header.Get(headerContentType) != contentTypeProblem) ||
+		(p.Type != problemType) {
+		c.t.Errorf("Expected problem %s, got %d %s", problemType,
+			response.StatusCode, body)
+	}
+}
+
+func (c *testClient) register() {
+	response := c.postExpect(c.url(pathNewAccount),
+		map[string]interface{}{"termsOfServiceAgreed": true},
+		http.StatusCreated, nil)
+	c.accountURL = response.Header.Get(headerLocation)
+	if c.accountURL == "" {
+		c.t.Fatalf("New account response did not specify the account URL")
+	}
+}
+
+func (c *testClient) newOrder(identifier Identifier) (string, *orderResponse) {
+	var o orderResponse
+	response := c.postExpect(c.url(pathNewOrder),
+		&newOrderRequest{Identifiers: []Identifier{identifier}},
+		http.StatusCreated, &o)
+	if len(o.Authorizations) != 1 {
+		c.t.Fatalf("Expected one authorization, got %d", len(o.Authorizations))
+	}
+	return response.Header.Get(headerLocation), &o
+}
+
+// Respond to the pre-shared token challenge of the order's authorization.
+func (c *testClient) respond(o *orderResponse, token string) *challengeResponse {
+	return c.respondWithKey(o, token, &c.key.PublicKey)
+}
+
+// Respond to the pre-shared token challenge of the order's authorization,
+// binding the token to the key authorization computed using the specified
+// account key.
+func (c *testClient) respondWithKey(o *orderResponse, token string,
+	accountKey *ecdsa.PublicKey) *challengeResponse {
+	var authz authorizationResponse
+	c.postExpect(o.Authorizations[0], nil, http.StatusOK, &authz)
+	if (len(authz.Challenges) != 1) ||
+		(authz.Challenges[0].Type != ChallengeTypePreSharedToken) {
+		c.t.Fatalf("Expected a pre-shared token challenge: %+v", authz.Challenges)
+	}
+
+	// Bind the token to the key authorization of the challenge.
+	thumbprint, err := accountIDFromKey(&jose.JSONWebKey{Key: accountKey})
+	if err != nil {
+		c.t.Fatalf("Failed to compute the account key thumbprint: %v", err)
+	}
+	mac, err := NewPreSharedTokenResponse(token,
+		authz.Challenges[0].Token+"."+thumbprint)
+	if err != nil {
+		c.t.Fatalf("Failed to compute the challenge response: %v", err)
+	}
+
+	var ch challengeResponse
+	c.postExpect(authz.Challenges[0].URL,
+		&preSharedTokenResponse{KeyAuthorizationMac: mac}, http.StatusOK, &ch)
+	return &ch
+}
+
+func newTestCsr(t *testing.T, commonName string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate the certificate key: %v", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader,
+		&x509.CertificateRequest{
+			Subject:            pkix.Name{CommonName: commonName},
+			SignatureAlgorithm: x509.SHA256WithRSA,
+		}, key)
+	if err != nil {
+		t.Fatalf("Failed to create the CSR: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(csr)
+}
+
+func newTestIdentifier() Identifier {
+	return Identifier{Type: IdentifierTypePermanentIdentifier,
+		Value: uuid.NewString()}
+}
+
+func TestAcmeDirectory(t *testing.T) {
+	response, err := http.Get(newTestClient(t).url(pathDirectory))
+	if err != nil {
+		t.Fatalf("Directory request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	var directory directoryResponse
+	err = json.NewDecoder(response.Body).Decode(&directory)
+	if err != nil {
+		t.Fatalf("Failed to decode the directory: %v", err)
+	}
+	if (directory.NewNonce == "") || (directory.NewAccount == "") ||
+		(directory.NewOrder == "") {
+		t.Errorf("Directory is missing resources: %+v", directory)
+	}
+}
+
+func TestAcmeIssuance(t *testing.T) {
+	client := newTestClient(t)
+	client.register()
+
+	// Registering the same key again returns the existing account.
+	accountURL := client.accountURL
+	client.accountURL = ""
+	response := client.postExpect(client.url(pathNewAccount),
+		map[string]interface{}{"onlyReturnExisting": true}, http.StatusOK, nil)
+	if response.Header.Get(headerLocation) != accountURL {
+		t.Errorf("Expected the existing account %s, got %s", accountURL,
+			response.Header.Get(headerLocation))
+	}
+	client.accountURL = accountURL
+
+	identifier := newTestIdentifier()
+	orderURL, o := client.newOrder(identifier)
+	if o.Status != statusPending {
+		t.Errorf("Expected a pending order, got %s", o.Status)
+	}
+
+	ch := client.respond(o, NewPreSharedToken(testTokenSecret, gTestTenantID,
+		identifier))
+	if ch.Status != statusValid {
+		t.Fatalf("Expected the challenge to be valid: %+v", ch)
+	}
+	client.postExpect(orderURL, nil, http.StatusOK, o)
+	if o.Status != statusReady {
+		t.Fatalf("Expected the order to be ready, got %s", o.Status)
+	}
+
+	client.postExpect(o.Finalize,
+		&finalizeRequest{Csr: newTestCsr(t, identifier.Value)},
+		http.StatusOK, o)
+	if (o.Status != statusValid) || (o.Certificate == "") {
+		t.Fatalf("Expected the order to be valid: %+v", o)
+	}
+
+	response, body := client.post(o.Certificate, nil)
+	if (response.StatusCode != http.StatusOK) ||
+		(response.Header.Get(headerContentType) != contentTypePemCertificates) {
+		t.Fatalf("Failed to download the certificate: %d %s",
+			response.StatusCode, body)
+	}
+
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(body); block != nil; block, rest = pem.Decode(rest) {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("Failed to parse the certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) != 3 {
+		t.Fatalf("Expected the certificate and its chain, got %d certificates",
+			len(certs))
+	}
+	if certs[0].Subject.CommonName != identifier.Value {
+		t.Errorf("Certificate was not issued for the identifier: %s",
+			certs[0].Subject.CommonName)
+	}
+	if (len(certs[0].Subject.Organization) == 0) ||
+		(certs[0].Subject.Organization[0] != gTestTenantID) {
+		t.Errorf("Certificate was not issued within the tenant: %v",
+			certs[0].Subject)
+	}
+}
+
+func TestAcmeIssuance_DNSIdentifier(t *testing.T) {
+	client := newTestClient(t)
+	client.register()
+
+	identifier := Identifier{Type: IdentifierTypeDNS,
+		Value: uuid.NewString() + ".devices.example.com"}
+	_, o := client.newOrder(identifier)
+	client.respond(o, NewPreSharedToken(testTokenSecret, gTestTenantID,
+		identifier))
+	client.postExpect(o.Finalize,
+		&finalizeRequest{Csr: newTestCsr(t, identifier.Value)},
+		http.StatusOK, o)
+	if o.Status != statusValid {
+		t.Fatalf("Expected the order to be valid: %+v", o)
+	}
+
+	// The DNS identifier is included as a subject alternative name.
+	response, body := client.post(o.Certificate, nil)
+	block, _ := pem.Decode(body)
+	if (response.StatusCode != http.StatusOK) || (block == nil) {
+		t.Fatalf("Failed to download the certificate: %d %s",
+			response.StatusCode, body)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse the certificate: %v", err)
+	}
+	if (len(cert.DNSNames) != 1) || (cert.DNSNames[0] != identifier.Value) {
+		t.Errorf("Expected the DNS name %s, got %v", identifier.Value,
+			cert.DNSNames)
+	}
+}
+
+func TestAcmeChallenge_OtherAccountKey(t *testing.T) {
+	client := newTestClient(t)
+	client.register()
+
+	identifier := newTestIdentifier()
+	_, o := client.newOrder(identifier)
+
+	// A response bound to the key of another account must be rejected.
+	other := newTestClient(t)
+	ch := client.respondWithKey(o, NewPreSharedToken(testTokenSecret,
+		gTestTenantID, identifier), &other.key.PublicKey)
+	if (ch.Status != statusInvalid) || (ch.Error == nil) ||
+		(ch.Error.Type != problemIncorrectResponse) {
+		t.Errorf("Expected the challenge to be invalid: %+v", ch)
+	}
+}
+
+func TestAcmeIncorrectToken(t *testing.T) {
+	client := newTestClient(t)
+	client.register()
+
+	identifier := newTestIdentifier()
+	orderURL, o := client.newOrder(identifier)
+
+	// A token provisioned for another identifier must be rejected.
+	ch := client.respond(o, NewPreSharedToken(testTokenSecret, gTestTenantID,
+		newTestIdentifier()))
+	if (ch.Status != statusInvalid) || (ch.Error == nil) ||
+		(ch.Error.Type != problemIncorrectResponse) {
+		t.Errorf("Expected the challenge to be invalid: %+v", ch)
+	}
+
+	client.postExpect(orderURL, nil, http.StatusOK, o)
+	if o.Status != statusInvalid {
+		t.Errorf("Expected the order to be invalid, got %s", o.Status)
+	}
+	client.postExpectProblem(o.Finalize,
+		&finalizeRequest{Csr: newTestCsr(t, identifier.Value)},
+		problemOrderNotReady)
+}
+
+func TestAcmeFinalize_CsrMismatch(t *testing.T) {
+	client := newTestClient(t)
+	client.register()
+
+	identifier := newTestIdentifier()
+	_, o := client.newOrder(identifier)
+	client.respond(o, NewPreSharedToken(testTokenSecret, gTestTenantID,
+		identifier))
+
+	client.postExpectProblem(o.Finalize,
+		&finalizeRequest{Csr: newTestCsr(t, uuid.NewString())}, problemBadCSR)
+}
+
+func TestAcmeBadNonce(t *testing.T) {
+	client := newTestClient(t)
+	client.register()
+
+	// Replaying a nonce which was already used must be rejected.
+	nonce, err := client.Nonce()
+	if err != nil {
+		t.Fatalf("Failed to get a nonce: %v", err)
+	}
+	client.nonce = nonce
+	client.postExpect(client.accountURL, nil, http.StatusOK, nil)
+	client.nonce = nonce
+	client.postExpectProblem(client.accountURL, nil, problemBadNonce)
+
+	// Nonces which were not issued by the server must be rejected.
+	forged := make([]byte, nonceSize)
+	_, _ = rand.Read(forged)
+	client.nonce = base64.RawURLEncoding.EncodeToString(forged)
+	client.postExpectProblem(client.accountURL, nil, problemBadNonce)
+}
+
+func TestAcmeOrder_OtherAccount(t *testing.T) {
+	client := newTestClient(t)
+	client.register()
+	orderURL, _ := client.newOrder(newTestIdentifier())
+
+	other := newTestClient(t)
+	other.register()
+	other.postExpectProblem(orderURL, nil, problemUnauthorized)
+}
+
+func TestAcmeNewOrder_UnsupportedIdentifier(t *testing.T) {
+	client := newTestClient(t)
+	client.register()
+	client.postExpectProblem(client.url(pathNewOrder),
+		&newOrderRequest{Identifiers: []Identifier{{Type: "ip",
+			Value: "10.0.0.1"}}}, problemUnsupportedIdentifier)
+}
+
+// A rate limiter which rejects all requests.
+type rejectingRateLimiter struct{}
+
+func (rejectingRateLimiter) AllowRequest(r *http.Request, operation string,
+	tenantID string) (bool, time.Duration) {
+	return false, 1500 * time.Millisecond
+}
+
+func TestAcmeRateLimited(t *testing.T) {
+	s := &Server{rateLimiter: rejectingRateLimiter{}}
+	w := httptest.NewRecorder()
+	err := s.checkRateLimit(w, httptest.NewRequest(http.MethodPost,
+		"/acme/tenant/new-order", nil), rateLimitNewOrder)
+	p := problemFromErr(err)
+	if (p.Type != problemRateLimited) ||
+		(p.Status != http.StatusTooManyRequests) {
+		t.Errorf("Expected a rate limited problem, got %v", err)
+	}
+	if w.Header().Get(headerRetryAfter) != "2" {
+		t.Errorf("Unexpected Retry-After header: %s",
+			w.Header().Get(headerRetryAfter))
+	}
+
+	// Requests are not limited without a rate limiter.
+	s = &Server{}
+	err = s.checkRateLimit(w, httptest.NewRequest(http.MethodPost,
+		"/acme/tenant/new-order", nil), rateLimitNewOrder)
+	if err != nil {
+		t.Errorf("Expected the request to be permitted, got %v", err)
+	}
+}
+
+func TestMain(m *testing.M) {
+	logger, err := zap.NewProduction(zap.AddCaller())
+	if err != nil {
+		fmt.Println("Failed to intialize structured logging for the ACME tests!")
+		os.Exit(2)
+	}
+	caLogger = logger
+
+	cfgMgr := config.NewConfigMgr(caLogger, common.ServiceName)
+	if !cfgMgr.Load(true) {
+		caLogger.Error("Failed to load configuration. Exiting!")
+		os.Exit(2)
+	}
+
+	provider, store, err := certmgr.Init(caLogger, cfgMgr)
+	if err != nil {
+		caLogger.Error("Failed to initialize the certificate authority!",
+			zap.Error(err),
+		)
+		os.Exit(2)
+	}
+	gTestTenantID = uuid.NewString()
+
+	server := NewServer(caLogger, provider, store,
+		quota.NewManager(caLogger, store, &config.DeviceQuota{}), nil,
+		&config.Acme{Enabled: true, TokenSecret: testTokenSecret},
+		NewPreSharedTokenValidator(testTokenSecret))
+	router := mux.NewRouter()
+	for _, route := range server.Routes() {
+		router.Methods(route.Method).Path(route.Path).Handler(route.HandlerFunc)
+	}
+	gTestServer = httptest.NewServer(router)
+
+	retCode := m.Run()
+	gTestServer.Close()
+	store.Shutdown()
+	os.Exit(retCode)
+}
@@ -0,0 +1,136 @@
+// package github.com/HPInc/krypton-ca/service/acme
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines the interface implemented by challenge validators, which verify
+// that an ACME client controls the identifier it requested a certificate for.
+// A pre-shared token challenge is provided for devices which are offline or
+// otherwise unreachable by the CA, and cannot complete the http-01 or dns-01
+// challenges. Responses to pre-shared token challenges are bound to the
+// account key, so a response observed in transit cannot be replayed by
+// another account.
+package acme
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	// Identifier types accepted in orders.
+	IdentifierTypeDNS                 = "dns"
+	IdentifierTypePermanentIdentifier = "permanent-identifier"
+
+	// Type of the pre-shared token challenge.
+	ChallengeTypePreSharedToken = "pre-shared-token-01"
+)
+
+// ChallengeValidator - defines an interface that must be implemented by
+// challenge validators. Each validator validates challenges of a single type.
+type ChallengeValidator interface {
+	// Type - returns the type of challenge validated by the validator.
+	Type() string
+
+	// Supports - checks whether challenges of this type can be offered for
+	// the specified identifier.
+	Supports(identifier Identifier) bool
+
+	// Validate - validates the response to a challenge for the specified
+	// identifier within the tenant. The key authorization binds the
+	// challenge token to the account key, and the payload is the payload
+	// of the client's request to the challenge URL. A problem describing
+	// why the response was rejected is returned on failure.
+	Validate(tenantID string, identifier Identifier, keyAuthorization string,
+		payload []byte) error
+}
+
+// Payload of responses to pre-shared token challenges.
+type preSharedTokenResponse struct {
+	// HMAC-SHA256 of the key authorization of the challenge, keyed using the
+	// token provisioned for the identifier (base64url encoded).
+	KeyAuthorizationMac string `json:"key_authorization_mac"`
+}
+
+// PreSharedTokenValidator - validates pre-shared token challenges. The token
+// for an identifier is derived from a secret known to the CA and the service
+// which provisions devices, so devices can be provisioned with their token
+// ahead of time and complete the challenge without the CA contacting them.
+type PreSharedTokenValidator struct {
+	secret []byte
+}
+
+// NewPreSharedTokenValidator - initialize a validator for pre-shared token
+// challenges, using tokens derived from the specified secret.
+func NewPreSharedTokenValidator(secret string) *PreSharedTokenValidator {
+	return &PreSharedTokenValidator{secret: []byte(secret)}
+}
+
+// NewPreSharedToken - returns the token to be provisioned for the specified
+// identifier within the tenant.
+func NewPreSharedToken(secret string, tenantID string,
+	identifier Identifier) string {
+	return base64.RawURLEncoding.EncodeToString(
+		derivePreSharedToken([]byte(secret), tenantID, identifier))
+}
+
+// NewPreSharedTokenResponse - returns the response to a pre-shared token
+// challenge, proving possession of the token provisioned for the identifier
+// (as returned by NewPreSharedToken) and binding it to the key authorization
+// of the challenge. The key authorization is the challenge token followed by
+// a period and the base64url encoded JWK thumbprint of the account key.
+func NewPreSharedTokenResponse(token string, keyAuthorization string) (string,
+	error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(
+		computeKeyAuthorizationMac(decoded, keyAuthorization)), nil
+}
+
+func computeKeyAuthorizationMac(token []byte, keyAuthorization string) []byte {
+	mac := hmac.New(sha256.New, token)
+	mac.Write([]byte(keyAuthorization))
+	return mac.Sum(nil)
+}
+
+func derivePreSharedToken(secret []byte, tenantID string,
+	identifier Identifier) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(tenantID + "\n" + identifier.Type + "\n" +
+		identifier.Value))
+	return mac.Sum(nil)
+}
+
+// Type - returns the type of challenge validated by the validator.
+func (v *PreSharedTokenValidator) Type() string {
+	return ChallengeTypePreSharedToken
+}
+
+// Supports - pre-shared token challenges can be offered for all identifiers.
+func (v *PreSharedTokenValidator) Supports(identifier Identifier) bool {
+	return true
+}
+
+// Validate - checks that the client responded with the MAC of the key
+// authorization, keyed using the token provisioned for the identifier.
+func (v *PreSharedTokenValidator) Validate(tenantID string,
+	identifier Identifier, keyAuthorization string, payload []byte) error {
+	var response preSharedTokenResponse
+	err := json.Unmarshal(payload, &response)
+	if err != nil || (response.KeyAuthorizationMac == "") {
+		return malformedProblem("the response must specify the key authorization MAC")
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(response.KeyAuthorizationMac)
+	if err != nil || !hmac.Equal(mac, computeKeyAuthorizationMac(
+		derivePreSharedToken(v.secret, tenantID, identifier), keyAuthorization)) {
+		return newProblem(problemIncorrectResponse, http.StatusForbidden,
+			"the pre-shared token is incorrect")
+	}
+	return nil
+}
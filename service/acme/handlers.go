@@ -0,0 +1,830 @@
+// package github.com/HPInc/krypton-ca/service/acme
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the handlers for the resources served by the ACME server.
+// Challenges are validated synchronously when the client responds to them,
+// and certificates are issued synchronously when an order is finalized.
+package acme
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/gorilla/mux"
+	"go.mozilla.org/pkcs7"
+	"go.uber.org/zap"
+)
+
+// The directory object, which lists the URLs of the ACME resources.
+type directoryResponse struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type accountRequest struct {
+	Contact              []string `json:"contact"`
+	TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+	OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+	Status               string   `json:"status"`
+}
+
+type accountResponse struct {
+	Status  string   `json:"status"`
+	Contact []string `json:"contact,omitempty"`
+}
+
+type newOrderRequest struct {
+	Identifiers []Identifier `json:"identifiers"`
+	NotBefore   string       `json:"notBefore"`
+	NotAfter    string       `json:"notAfter"`
+}
+
+type orderResponse struct {
+	Status         string       `json:"status"`
+	Expires        time.Time    `json:"expires"`
+	Identifiers    []Identifier `json:"identifiers"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate,omitempty"`
+	Error          *Problem     `json:"error,omitempty"`
+}
+
+type authorizationRequest struct {
+	Status string `json:"status"`
+}
+
+type authorizationResponse struct {
+	Identifier Identifier          `json:"identifier"`
+	Status     string              `json:"status"`
+	Expires    time.Time           `json:"expires"`
+	Challenges []challengeResponse `json:"challenges"`
+}
+
+type challengeResponse struct {
+	Type      string     `json:"type"`
+	URL       string     `json:"url"`
+	Token     string     `json:"token"`
+	Status    string     `json:"status"`
+	Validated *time.Time `json:"validated,omitempty"`
+	Error     *Problem   `json:"error,omitempty"`
+}
+
+type finalizeRequest struct {
+	Csr string `json:"csr"`
+}
+
+// Requests without a payload are POST-as-GET requests, which retrieve the
+// current state of a resource.
+func isPostAsGet(request *acmeRequest) bool {
+	return len(request.payload) == 0
+}
+
+func decodePayload(request *acmeRequest, payload interface{}) error {
+	err := json.Unmarshal(request.payload, payload)
+	if err != nil {
+		return malformedProblem("failed to decode the request payload")
+	}
+	return nil
+}
+
+func (s *Server) accountURL(r *http.Request, tenantID string, id string) string {
+	return s.resourceURL(r, tenantID, pathAccount+id)
+}
+
+func (s *Server) orderURL(r *http.Request, tenantID string, id string) string {
+	return s.resourceURL(r, tenantID, pathOrder+id)
+}
+
+func (s *Server) authorizationURL(r *http.Request, tenantID string,
+	id string) string {
+	return s.resourceURL(r, tenantID, pathAuthorization+id)
+}
+
+func (s *Server) newOrderResponse(r *http.Request, tenantID string,
+	o *order) *orderResponse {
+	response := &orderResponse{
+		Status:         o.Status,
+		Expires:        o.Expires,
+		Identifiers:    o.Identifiers,
+		Authorizations: []string{},
+		Finalize:       s.orderURL(r, tenantID, o.ID) + pathFinalize,
+		Error:          o.Error,
+	}
+	for _, id := range o.AuthorizationIDs {
+		response.Authorizations = append(response.Authorizations,
+			s.authorizationURL(r, tenantID, id))
+	}
+	if o.Status == statusValid {
+		response.Certificate = s.resourceURL(r, tenantID,
+			pathCertificate+o.ID)
+	}
+	return response
+}
+
+func (s *Server) newChallengeResponse(r *http.Request, tenantID string,
+	authzID string, ch *challenge) challengeResponse {
+	response := challengeResponse{
+		Type:   ch.Type,
+		URL:    s.resourceURL(r, tenantID, pathChallenge+authzID+"/"+ch.ID),
+		Token:  ch.Token,
+		Status: ch.Status,
+		Error:  ch.Error,
+	}
+	if !ch.Validated.IsZero() {
+		validated := ch.Validated
+		response.Validated = &validated
+	}
+	return response
+}
+
+func (s *Server) newAuthorizationResponse(r *http.Request, tenantID string,
+	authz *authorization) *authorizationResponse {
+	response := &authorizationResponse{
+		Identifier: authz.Identifier,
+		Status:     authz.Status,
+		Expires:    authz.Expires,
+		Challenges: []challengeResponse{},
+	}
+	for _, ch := range authz.Challenges {
+		response.Challenges = append(response.Challenges,
+			s.newChallengeResponse(r, tenantID, authz.ID, ch))
+	}
+	return response
+}
+
+// GetDirectoryHandler - returns the directory object for the tenant.
+func (s *Server) GetDirectoryHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID := tenantFromRequest(r)
+	s.writeResponse(w, r, http.StatusOK, "", &directoryResponse{
+		NewNonce:   s.resourceURL(r, tenantID, pathNewNonce),
+		NewAccount: s.resourceURL(r, tenantID, pathNewAccount),
+		NewOrder:   s.resourceURL(r, tenantID, pathNewOrder),
+	})
+}
+
+// NewNonceHandler - returns a fresh nonce in the Replay-Nonce header.
+func (s *Server) NewNonceHandler(w http.ResponseWriter, r *http.Request) {
+	nonce, err := s.newNonce()
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set(headerReplayNonce, nonce)
+	w.Header().Set(headerCacheControl, "no-store")
+	w.Header().Set(headerLink, fmt.Sprintf("<%s>;rel=\"index\"",
+		s.resourceURL(r, tenantFromRequest(r), pathDirectory)))
+	if r.Method == http.MethodGet {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// NewAccountHandler - registers a new account, or returns the existing
+// account registered using the key which signed the request.
+func (s *Server) NewAccountHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.checkRateLimit(w, r, rateLimitNewAccount)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	request, err := s.verifyRequest(r, true)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	var payload accountRequest
+	err = decodePayload(request, &payload)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	accountID, err := accountIDFromKey(request.key)
+	if err != nil {
+		s.writeProblem(w, r, malformedProblem("the jwk header is invalid"))
+		return
+	}
+
+	// Return the existing account registered using the key.
+	acct, err := s.loadAccount(r.Context(), request.tenantID, accountID)
+	if err == nil {
+		s.writeResponse(w, r, http.StatusOK,
+			s.accountURL(r, request.tenantID, acct.ID),
+			&accountResponse{Status: acct.Status, Contact: acct.Contact})
+		return
+	}
+	var p *Problem
+	if !errors.As(err, &p) || payload.OnlyReturnExisting {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	for _, contact := range payload.Contact {
+		if !strings.HasPrefix(contact, "mailto:") {
+			s.writeProblem(w, r, newProblem(problemUnsupportedContactValue,
+				http.StatusBadRequest, "only mailto contacts are supported"))
+			return
+		}
+	}
+
+	acct = &account{
+		ID:      accountID,
+		Status:  statusValid,
+		Contact: payload.Contact,
+		Key:     request.key,
+	}
+	err = s.saveAccount(r.Context(), request.tenantID, acct)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	caLogger.Info("ACME: Registered a new account.",
+		zap.String("Tenant ID:", request.tenantID),
+		zap.String("Account ID:", accountID),
+	)
+	s.writeResponse(w, r, http.StatusCreated,
+		s.accountURL(r, request.tenantID, acct.ID),
+		&accountResponse{Status: acct.Status, Contact: acct.Contact})
+}
+
+// AccountHandler - returns or updates the account. Accounts can update their
+// contacts or deactivate themselves.
+func (s *Server) AccountHandler(w http.ResponseWriter, r *http.Request) {
+	request, err := s.verifyRequest(r, false)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+	acct := request.account
+	if acct.ID != mux.Vars(r)[pathVarID] {
+		s.writeProblem(w, r, unauthorizedProblem(
+			"the account does not match the key which signed the request"))
+		return
+	}
+
+	if !isPostAsGet(request) {
+		var payload accountRequest
+		err = decodePayload(request, &payload)
+		if err != nil {
+			s.writeProblem(w, r, err)
+			return
+		}
+
+		switch payload.Status {
+		case "":
+		case statusDeactivated:
+			acct.Status = statusDeactivated
+		default:
+			s.writeProblem(w, r, malformedProblem(
+				"accounts may only be deactivated"))
+			return
+		}
+		if payload.Contact != nil {
+			acct.Contact = payload.Contact
+		}
+
+		err = s.saveAccount(r.Context(), request.tenantID, acct)
+		if err != nil {
+			s.writeProblem(w, r, err)
+			return
+		}
+	}
+
+	s.writeResponse(w, r, http.StatusOK, "",
+		&accountResponse{Status: acct.Status, Contact: acct.Contact})
+}
+
+// Check whether the identifier may be included in an order.
+func validateIdentifier(identifier Identifier) error {
+	switch identifier.Type {
+	case IdentifierTypeDNS, IdentifierTypePermanentIdentifier:
+	default:
+		return newProblem(problemUnsupportedIdentifier, http.StatusBadRequest,
+			"identifiers of type %q are not supported", identifier.Type)
+	}
+
+	if (identifier.Value == "") || (len(identifier.Value) > 255) ||
+		strings.ContainsAny(identifier.Value, "/\\* \t\r\n") {
+		return newProblem(problemRejectedIdentifier, http.StatusBadRequest,
+			"the identifier %q is not valid", identifier.Value)
+	}
+	return nil
+}
+
+// NewOrderHandler - places an order for a certificate. An authorization is
+// created for the identifier in the order, offering a challenge of each type
+// supported for the identifier.
+func (s *Server) NewOrderHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.checkRateLimit(w, r, rateLimitNewOrder)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	request, err := s.verifyRequest(r, false)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	var payload newOrderRequest
+	err = decodePayload(request, &payload)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	// The certificate issued for an order binds its identifier as the
+	// device ID, so each order must specify exactly one identifier.
+	if len(payload.Identifiers) != 1 {
+		s.writeProblem(w, r, newProblem(problemRejectedIdentifier,
+			http.StatusBadRequest, "orders must specify exactly one identifier"))
+		return
+	}
+	if (payload.NotBefore != "") || (payload.NotAfter != "") {
+		s.writeProblem(w, r, malformedProblem(
+			"the validity period of certificates cannot be specified"))
+		return
+	}
+	identifier := payload.Identifiers[0]
+	err = validateIdentifier(identifier)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	orderID, err := newRandomValue()
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+	authzID, err := newRandomValue()
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	expires := time.Now().Add(orderLifetime).UTC().Truncate(time.Second)
+	authz := &authorization{
+		ID:         authzID,
+		AccountID:  request.account.ID,
+		OrderID:    orderID,
+		Identifier: identifier,
+		Status:     statusPending,
+		Expires:    expires,
+		Challenges: []*challenge{},
+	}
+	for challengeType, validator := range s.validators {
+		if !validator.Supports(identifier) {
+			continue
+		}
+
+		ch := &challenge{Type: challengeType, Status: statusPending}
+		ch.ID, err = newRandomValue()
+		if err == nil {
+			ch.Token, err = newRandomValue()
+		}
+		if err != nil {
+			s.writeProblem(w, r, err)
+			return
+		}
+		authz.Challenges = append(authz.Challenges, ch)
+	}
+	if len(authz.Challenges) == 0 {
+		s.writeProblem(w, r, newProblem(problemRejectedIdentifier,
+			http.StatusBadRequest,
+			"no challenges are supported for the identifier"))
+		return
+	}
+
+	o := &order{
+		ID:               orderID,
+		AccountID:        request.account.ID,
+		Status:           statusPending,
+		Expires:          expires,
+		Identifiers:      payload.Identifiers,
+		AuthorizationIDs: []string{authzID},
+	}
+	err = s.saveAuthorization(r.Context(), request.tenantID, authz)
+	if err == nil {
+		err = s.saveOrder(r.Context(), request.tenantID, o)
+	}
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	caLogger.Info("ACME: Created a new order.",
+		zap.String("Tenant ID:", request.tenantID),
+		zap.String("Account ID:", request.account.ID),
+		zap.String("Order ID:", orderID),
+		zap.String("Identifier:", identifier.Value),
+	)
+	s.writeResponse(w, r, http.StatusCreated,
+		s.orderURL(r, request.tenantID, orderID),
+		s.newOrderResponse(r, request.tenantID, o))
+}
+
+// Load the order specified in the request path, which must belong to the
+// account which signed the request.
+func (s *Server) loadRequestOrder(r *http.Request,
+	request *acmeRequest) (*order, error) {
+	o, err := s.loadOrder(r.Context(), request.tenantID, mux.Vars(r)[pathVarID])
+	if err != nil {
+		return nil, err
+	}
+	if o.AccountID != request.account.ID {
+		return nil, unauthorizedProblem("the order belongs to another account")
+	}
+	return o, nil
+}
+
+// OrderHandler - returns the current state of the order.
+func (s *Server) OrderHandler(w http.ResponseWriter, r *http.Request) {
+	request, err := s.verifyRequest(r, false)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	o, err := s.loadRequestOrder(r, request)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+	s.writeResponse(w, r, http.StatusOK, "",
+		s.newOrderResponse(r, request.tenantID, o))
+}
+
+// Load the authorization with the specified ID, which must belong to the
+// account which signed the request.
+func (s *Server) loadRequestAuthorization(ctx context.Context,
+	request *acmeRequest, id string) (*authorization, error) {
+	authz, err := s.loadAuthorization(ctx, request.tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if authz.AccountID != request.account.ID {
+		return nil, unauthorizedProblem(
+			"the authorization belongs to another account")
+	}
+	return authz, nil
+}
+
+// Update the status of the order once the status of one of its authorizations
+// changes. The order is ready to be finalized once all its authorizations are
+// valid, and is invalid if any of its authorizations are invalid.
+func (s *Server) updateOrderStatus(ctx context.Context,
+	tenantID string, authz *authorization) error {
+	o, err := s.loadOrder(ctx, tenantID, authz.OrderID)
+	if err != nil {
+		return err
+	}
+	if o.Status != statusPending {
+		return nil
+	}
+
+	status := statusReady
+	for _, id := range o.AuthorizationIDs {
+		orderAuthz := authz
+		if id != authz.ID {
+			orderAuthz, err = s.loadAuthorization(ctx, tenantID, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		switch orderAuthz.Status {
+		case statusValid:
+		case statusPending:
+			status = statusPending
+		default:
+			o.Status = statusInvalid
+			o.Error = newProblem(problemUnauthorized, http.StatusForbidden,
+				"the authorization for %q is %s", orderAuthz.Identifier.Value,
+				orderAuthz.Status)
+			return s.saveOrder(ctx, tenantID, o)
+		}
+	}
+
+	o.Status = status
+	return s.saveOrder(ctx, tenantID, o)
+}
+
+// AuthorizationHandler - returns the current state of the authorization, or
+// deactivates it.
+func (s *Server) AuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	request, err := s.verifyRequest(r, false)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	authz, err := s.loadRequestAuthorization(r.Context(), request,
+		mux.Vars(r)[pathVarID])
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	if !isPostAsGet(request) {
+		var payload authorizationRequest
+		err = decodePayload(request, &payload)
+		if err != nil {
+			s.writeProblem(w, r, err)
+			return
+		}
+		if payload.Status != statusDeactivated {
+			s.writeProblem(w, r, malformedProblem(
+				"authorizations may only be deactivated"))
+			return
+		}
+
+		authz.Status = statusDeactivated
+		err = s.saveAuthorization(r.Context(), request.tenantID, authz)
+		if err == nil {
+			err = s.updateOrderStatus(r.Context(), request.tenantID, authz)
+		}
+		if err != nil {
+			s.writeProblem(w, r, err)
+			return
+		}
+	}
+
+	s.writeResponse(w, r, http.StatusOK, "",
+		s.newAuthorizationResponse(r, request.tenantID, authz))
+}
+
+// ChallengeHandler - returns the current state of the challenge, or validates
+// the client's response to the challenge.
+func (s *Server) ChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.checkRateLimit(w, r, rateLimitChallenge)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	request, err := s.verifyRequest(r, false)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	authz, err := s.loadRequestAuthorization(r.Context(), request,
+		vars[pathVarAuthorization])
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	var ch *challenge
+	for _, candidate := range authz.Challenges {
+		if candidate.ID == vars[pathVarID] {
+			ch = candidate
+			break
+		}
+	}
+	if ch == nil {
+		s.writeProblem(w, r, notFoundProblem("the challenge does not exist"))
+		return
+	}
+	w.Header().Add(headerLink, fmt.Sprintf("<%s>;rel=\"up\"",
+		s.authorizationURL(r, request.tenantID, authz.ID)))
+
+	// Validate the response if the challenge has not been completed yet.
+	if !isPostAsGet(request) && (authz.Status == statusPending) &&
+		(ch.Status == statusPending) {
+		validator, ok := s.validators[ch.Type]
+		if !ok {
+			s.writeProblem(w, r, internalProblem())
+			return
+		}
+
+		keyAuthorization := ch.Token + "." + request.account.ID
+		err = validator.Validate(request.tenantID, authz.Identifier,
+			keyAuthorization, request.payload)
+		if err != nil {
+			caLogger.Info("ACME: Challenge validation failed!",
+				zap.String("Tenant ID:", request.tenantID),
+				zap.String("Authorization ID:", authz.ID),
+				zap.String("Challenge type:", ch.Type),
+				zap.Error(err),
+			)
+			ch.Status = statusInvalid
+			ch.Error = problemFromErr(err)
+			authz.Status = statusInvalid
+		} else {
+			ch.Status = statusValid
+			ch.Validated = time.Now().UTC().Truncate(time.Second)
+			authz.Status = statusValid
+		}
+
+		err = s.saveAuthorization(r.Context(), request.tenantID, authz)
+		if err == nil {
+			err = s.updateOrderStatus(r.Context(), request.tenantID, authz)
+		}
+		if err != nil {
+			s.writeProblem(w, r, err)
+			return
+		}
+	}
+
+	s.writeResponse(w, r, http.StatusOK, "",
+		s.newChallengeResponse(r, request.tenantID, authz.ID, ch))
+}
+
+// Check that the CSR only requests the identifier in the order.
+func validateCsrIdentifier(csr []byte, identifier Identifier) error {
+	parsedCsr, err := common.ParseDeviceCertificateSigningRequest(caLogger, csr)
+	if err != nil {
+		return newProblem(problemBadCSR, http.StatusBadRequest, "%v", err)
+	}
+
+	if (parsedCsr.Subject.CommonName != "") &&
+		(parsedCsr.Subject.CommonName != identifier.Value) {
+		return newProblem(problemBadCSR, http.StatusBadRequest,
+			"the CSR common name does not match the identifier in the order")
+	}
+	for _, name := range parsedCsr.DNSNames {
+		if (identifier.Type != IdentifierTypeDNS) || (name != identifier.Value) {
+			return newProblem(problemBadCSR, http.StatusBadRequest,
+				"the CSR requests names not included in the order")
+		}
+	}
+	if (len(parsedCsr.IPAddresses) != 0) || (len(parsedCsr.EmailAddresses) != 0) ||
+		(len(parsedCsr.URIs) != 0) {
+		return newProblem(problemBadCSR, http.StatusBadRequest,
+			"the CSR requests names not included in the order")
+	}
+	return nil
+}
+
+// Returns the PEM encoded certificate chain for the issued certificate.
+func newPemCertificateChain(deviceCert []byte, parentCerts []byte) ([]byte, error) {
+	parsedParents, err := pkcs7.Parse(parentCerts)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: deviceCert})
+	for _, cert := range parsedParents.Certificates {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: cert.Raw,
+		})...)
+	}
+	return chain, nil
+}
+
+// FinalizeOrderHandler - issues the certificate for an order which is ready.
+// The identifier in the order is used as the device ID of the certificate,
+// which is signed using the tenant's signing key.
+func (s *Server) FinalizeOrderHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.checkRateLimit(w, r, rateLimitFinalize)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	request, err := s.verifyRequest(r, false)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	o, err := s.loadRequestOrder(r, request)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+	if o.Status != statusReady {
+		s.writeProblem(w, r, newProblem(problemOrderNotReady,
+			http.StatusForbidden, "the order is %s", o.Status))
+		return
+	}
+
+	var payload finalizeRequest
+	err = decodePayload(request, &payload)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+	csr, err := base64.RawURLEncoding.DecodeString(payload.Csr)
+	if err != nil {
+		s.writeProblem(w, r, newProblem(problemBadCSR, http.StatusBadRequest,
+			"the CSR is not base64url encoded"))
+		return
+	}
+
+	deviceID := o.Identifiers[0].Value
+	err = validateCsrIdentifier(csr, o.Identifiers[0])
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	// Devices which already hold a certificate are counted against the
+	// tenant's device cap, so capacity is only reserved for new devices.
+	reservation, err := s.quotaManager.ReserveRenewal(r.Context(),
+		request.tenantID, deviceID)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+	defer reservation.Release()
+
+	// Identifiers of type "dns" are included in the certificate as DNS names.
+	var dnsNames []string
+	for _, identifier := range o.Identifiers {
+		if identifier.Type == IdentifierTypeDNS {
+			dnsNames = append(dnsNames, identifier.Value)
+		}
+	}
+
+	issuer, err := s.kmsProvider.NewDeviceCertificateIssuer(r.Context(),
+		request.tenantID)
+	var deviceCert, parentCerts []byte
+	var expiresAt time.Time
+	if err == nil {
+		deviceCert, parentCerts, expiresAt, err = issuer.IssueDeviceCertificate(
+			deviceID, csr, dnsNames)
+	}
+	if err != nil {
+		caLogger.Error("ACME: Failed to issue the certificate!",
+			zap.String("Tenant ID:", request.tenantID),
+			zap.String("Order ID:", o.ID),
+			zap.Error(err),
+		)
+		s.writeProblem(w, r, err)
+		return
+	}
+	_ = reservation.RecordDevice(r.Context(), deviceID, expiresAt)
+
+	o.Certificate, err = newPemCertificateChain(deviceCert, parentCerts)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	// Retain valid orders until the certificate expires, so that the
+	// certificate can be downloaded.
+	o.Status = statusValid
+	o.Expires = expiresAt
+	err = s.saveOrder(r.Context(), request.tenantID, o)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	metrics.MetricAcmeCertificatesIssued.Inc()
+	caLogger.Info("ACME: Issued a certificate.",
+		zap.String("Tenant ID:", request.tenantID),
+		zap.String("Order ID:", o.ID),
+		zap.String("Device ID:", deviceID),
+	)
+	s.writeResponse(w, r, http.StatusOK, s.orderURL(r, request.tenantID, o.ID),
+		s.newOrderResponse(r, request.tenantID, o))
+}
+
+// CertificateHandler - returns the PEM encoded certificate chain issued for
+// an order. Certificates are identified by the order they were issued for.
+func (s *Server) CertificateHandler(w http.ResponseWriter, r *http.Request) {
+	request, err := s.verifyRequest(r, false)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+
+	o, err := s.loadRequestOrder(r, request)
+	if err != nil {
+		s.writeProblem(w, r, err)
+		return
+	}
+	if o.Status != statusValid {
+		s.writeProblem(w, r, notFoundProblem("the certificate does not exist"))
+		return
+	}
+
+	s.addNonce(w)
+	w.Header().Set(headerContentType, contentTypePemCertificates)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(o.Certificate)
+}
@@ -0,0 +1,155 @@
+// package github.com/HPInc/krypton-ca/service/acme
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements verification of the JWS signed requests sent by ACME clients.
+// Each request must be signed by the account key (or, for new accounts, the
+// key being registered), specify a nonce issued by the server and specify the
+// URL it was sent to.
+package acme
+
+import (
+	"crypto"
+	"encoding/base64"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// Signature algorithms accepted in ACME requests.
+var supportedSignatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256,
+	jose.PS256,
+	jose.ES256,
+	jose.ES384,
+	jose.EdDSA,
+}
+
+// Name of the protected header specifying the URL of the request.
+const headerUrl = "url"
+
+// An ACME request whose signature, nonce and URL have been verified.
+type acmeRequest struct {
+	// The tenant whose ACME directory the request was sent to.
+	tenantID string
+
+	// The verified payload of the request. This is empty for POST-as-GET
+	// requests.
+	payload []byte
+
+	// The account that signed the request. This is nil for new account
+	// requests, which are signed by the key being registered.
+	account *account
+
+	// The key which signed the request.
+	key *jose.JSONWebKey
+}
+
+// Returns the URL at which the request was received.
+func (s *Server) requestURL(r *http.Request) string {
+	return s.baseURL(r) + r.URL.Path
+}
+
+// Returns the ID of an account, which is the JWK thumbprint of its key.
+func accountIDFromKey(key *jose.JSONWebKey) (string, error) {
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+// Verify the JWS signed request. Requests to create accounts must be signed by
+// the key being registered, which is specified in the jwk header. All other
+// requests must be signed by the key of an existing account, which is
+// identified by the kid header.
+func (s *Server) verifyRequest(r *http.Request,
+	newAccount bool) (*acmeRequest, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get(headerContentType))
+	if (err != nil) || (mediaType != contentTypeJose) {
+		return nil, newProblem(problemMalformed,
+			http.StatusUnsupportedMediaType,
+			"requests must specify the %s content type", contentTypeJose)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize+1))
+	if err != nil {
+		return nil, malformedProblem("failed to read the request")
+	}
+	if len(body) > maxRequestSize {
+		return nil, malformedProblem("the request is too large")
+	}
+
+	jws, err := jose.ParseSigned(string(body), supportedSignatureAlgorithms)
+	if err != nil {
+		return nil, newProblem(problemBadSignatureAlgorithm,
+			http.StatusBadRequest, "failed to parse the JWS: %v", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, malformedProblem("the JWS must have exactly one signature")
+	}
+	header := jws.Signatures[0].Protected
+
+	// The nonce is checked before the signature is verified, but is only
+	// recorded as used once the signature has been verified, so that
+	// requests which are not signed by the client do not write to the
+	// certificate store.
+	nonceExpiresAt, err := s.checkNonce(header.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	requestURL, ok := header.ExtraHeaders[headerUrl].(string)
+	if !ok || (requestURL != s.requestURL(r)) {
+		return nil, unauthorizedProblem(
+			"the url header does not match the URL of the request")
+	}
+
+	request := &acmeRequest{tenantID: tenantFromRequest(r)}
+	if newAccount {
+		if (header.JSONWebKey == nil) || (header.KeyID != "") {
+			return nil, malformedProblem(
+				"new account requests must specify the jwk header")
+		}
+		if !header.JSONWebKey.Valid() || !header.JSONWebKey.IsPublic() {
+			return nil, malformedProblem("the jwk header is not a valid public key")
+		}
+		request.key = header.JSONWebKey
+	} else {
+		if (header.JSONWebKey != nil) || (header.KeyID == "") {
+			return nil, malformedProblem("requests must specify the kid header")
+		}
+
+		accountPrefix := s.resourceURL(r, request.tenantID, pathAccount)
+		if !strings.HasPrefix(header.KeyID, accountPrefix) {
+			return nil, newProblem(problemAccountDoesNotExist,
+				http.StatusBadRequest, "the account does not exist")
+		}
+		request.account, err = s.loadAccount(r.Context(), request.tenantID,
+			strings.TrimPrefix(header.KeyID, accountPrefix))
+		if err != nil {
+			return nil, err
+		}
+		if request.account.Status != statusValid {
+			return nil, unauthorizedProblem("the account is %s",
+				request.account.Status)
+		}
+		request.key = request.account.Key
+	}
+
+	request.payload, err = jws.Verify(request.key)
+	if err != nil {
+		return nil, malformedProblem("failed to verify the JWS signature")
+	}
+
+	// Reject replayed requests.
+	err = s.consumeNonce(r.Context(), header.Nonce, nonceExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
@@ -0,0 +1,118 @@
+// package github.com/HPInc/krypton-ca/service/acme
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the anti-replay nonces issued by the ACME server. Nonces are
+// stateless - each nonce carries the time it was issued and is authenticated
+// using a key derived from the token secret, so issuing a nonce does not
+// write to the certificate store, and nonces issued by any instance of the CA
+// are accepted by all instances. A nonce is recorded in the certificate store
+// once it is used in a request whose signature was verified, and is rejected
+// if used again before it expires.
+package acme
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	"go.uber.org/zap"
+)
+
+const (
+	// Sizes of the issue time, random value and MAC encoded in a nonce.
+	nonceTimeSize   = 8
+	nonceRandomSize = 16
+	nonceMacSize    = 16
+	nonceSize       = nonceTimeSize + nonceRandomSize + nonceMacSize
+
+	// Label used to derive the key used to authenticate nonces.
+	nonceKeyLabel = "acme-nonce-key"
+)
+
+// Derive the key used to authenticate nonces from the token secret, which is
+// shared by all instances of the CA. If no secret is configured, a random key
+// is used, and nonces are only accepted by the instance which issued them.
+func deriveNonceKey(secret string) []byte {
+	if secret == "" {
+		key := make([]byte, sha256.Size)
+		_, _ = rand.Read(key)
+		return key
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(nonceKeyLabel))
+	return mac.Sum(nil)
+}
+
+// Compute the MAC authenticating the issue time and random value of a nonce.
+func (s *Server) nonceMac(value []byte) []byte {
+	mac := hmac.New(sha256.New, s.nonceKey)
+	mac.Write(value)
+	return mac.Sum(nil)[:nonceMacSize]
+}
+
+// Issue a new nonce. Nonces are shared by the ACME directories of all tenants.
+func (s *Server) newNonce() (string, error) {
+	nonce := make([]byte, nonceTimeSize+nonceRandomSize, nonceSize)
+	binary.BigEndian.PutUint64(nonce, uint64(time.Now().Unix()))
+	_, err := rand.Read(nonce[nonceTimeSize:])
+	if err != nil {
+		caLogger.Error("ACME: Failed to generate a nonce!",
+			zap.Error(err),
+		)
+		return "", err
+	}
+	nonce = append(nonce, s.nonceMac(nonce)...)
+	return base64.RawURLEncoding.EncodeToString(nonce), nil
+}
+
+// Check that the specified nonce was issued by the CA and has not expired,
+// and return the time at which it expires. A badNonce problem is returned
+// otherwise. This does not check whether the nonce was already used.
+func (s *Server) checkNonce(nonce string) (time.Time, error) {
+	if nonce == "" {
+		return time.Time{}, newProblem(problemBadNonce, http.StatusBadRequest,
+			"the request does not specify a nonce")
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(nonce)
+	if (err != nil) || (len(decoded) != nonceSize) ||
+		!hmac.Equal(decoded[nonceTimeSize+nonceRandomSize:],
+			s.nonceMac(decoded[:nonceTimeSize+nonceRandomSize])) {
+		return time.Time{}, newProblem(problemBadNonce, http.StatusBadRequest,
+			"the specified nonce is invalid")
+	}
+
+	issuedAt := time.Unix(int64(binary.BigEndian.Uint64(decoded)), 0)
+	expiresAt := issuedAt.Add(nonceLifetime)
+	if time.Now().After(expiresAt) {
+		return time.Time{}, newProblem(problemBadNonce, http.StatusBadRequest,
+			"the specified nonce has expired")
+	}
+	return expiresAt, nil
+}
+
+// Consume the specified nonce, which was checked using checkNonce. The nonce
+// is recorded until it expires, and a badNonce problem is returned if it was
+// already used.
+func (s *Server) consumeNonce(ctx context.Context, nonce string,
+	expiresAt time.Time) error {
+	err := s.store.AddRecord(ctx, &common.Record{
+		Kind:      common.RecordKindAcmeNonce,
+		ID:        nonce,
+		ExpiresAt: expiresAt,
+	})
+	if errors.Is(err, common.ErrRecordExists) {
+		return newProblem(problemBadNonce, http.StatusBadRequest,
+			"the specified nonce was already used")
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+// package github.com/HPInc/krypton-ca/service/acme
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the problem documents (RFC 7807) returned to ACME clients when
+// requests fail.
+package acme
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// Namespace of the error types defined by the ACME specification.
+	problemNamespace = "urn:ietf:params:acme:error:"
+
+	// Problem types returned by the ACME server.
+	problemAccountDoesNotExist     = problemNamespace + "accountDoesNotExist"
+	problemBadCSR                  = problemNamespace + "badCSR"
+	problemBadNonce                = problemNamespace + "badNonce"
+	problemBadSignatureAlgorithm   = problemNamespace + "badSignatureAlgorithm"
+	problemIncorrectResponse       = problemNamespace + "incorrectResponse"
+	problemMalformed               = problemNamespace + "malformed"
+	problemOrderNotReady           = problemNamespace + "orderNotReady"
+	problemRateLimited             = problemNamespace + "rateLimited"
+	problemRejectedIdentifier      = problemNamespace + "rejectedIdentifier"
+	problemServerInternal          = problemNamespace + "serverInternal"
+	problemUnauthorized            = problemNamespace + "unauthorized"
+	problemUnsupportedIdentifier   = problemNamespace + "unsupportedIdentifier"
+	problemUnsupportedContactValue = problemNamespace + "unsupportedContact"
+
+	// HTTP status code returned when the client cancelled the request.
+	httpStatusClientClosedRequest = 499
+)
+
+// Problem - a problem document describing why an ACME request failed.
+type Problem struct {
+	// The type of problem.
+	Type string `json:"type"`
+
+	// A human readable description of the problem.
+	Detail string `json:"detail,omitempty"`
+
+	// The HTTP status code returned for the problem.
+	Status int `json:"status,omitempty"`
+}
+
+// Error - returns a description of the problem.
+func (p *Problem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Type, p.Detail)
+}
+
+func newProblem(problemType string, status int, format string,
+	args ...interface{}) *Problem {
+	return &Problem{
+		Type:   problemType,
+		Detail: fmt.Sprintf(format, args...),
+		Status: status,
+	}
+}
+
+func malformedProblem(format string, args ...interface{}) *Problem {
+	return newProblem(problemMalformed, http.StatusBadRequest, format, args...)
+}
+
+func unauthorizedProblem(format string, args ...interface{}) *Problem {
+	return newProblem(problemUnauthorized, http.StatusForbidden, format, args...)
+}
+
+func notFoundProblem(format string, args ...interface{}) *Problem {
+	return newProblem(problemMalformed, http.StatusNotFound, format, args...)
+}
+
+func internalProblem() *Problem {
+	return newProblem(problemServerInternal, http.StatusInternalServerError,
+		"the server experienced an internal error")
+}
+
+// problemFromErr maps an error returned by the KMS provider, certificate store
+// or quota manager to a problem document based on its category. Details of
+// internal errors are only logged.
+func problemFromErr(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+
+	switch {
+	case errors.Is(err, quota.ErrDeviceCapReached):
+		return newProblem(problemRateLimited, http.StatusForbidden, "%s",
+			caerrors.MessageOf(err))
+	}
+
+	switch caerrors.CategoryOf(err) {
+	case caerrors.InvalidInput:
+		return newProblem(problemBadCSR, http.StatusBadRequest, "%s",
+			caerrors.MessageOf(err))
+	case caerrors.NotFound:
+		return notFoundProblem("%s", caerrors.MessageOf(err))
+	case caerrors.PolicyViolation:
+		return unauthorizedProblem("%s", caerrors.MessageOf(err))
+	case caerrors.DependencyUnavailable:
+		return newProblem(problemServerInternal, http.StatusServiceUnavailable,
+			"%s", caerrors.MessageOf(err))
+	case caerrors.Canceled:
+		return newProblem(problemServerInternal, httpStatusClientClosedRequest,
+			"the request was cancelled")
+	}
+	return internalProblem()
+}
+
+// Write a problem document in response to a failed request.
+func (s *Server) writeProblem(w http.ResponseWriter, r *http.Request, err error) {
+	p := problemFromErr(err)
+	metrics.MetricAcmeProblems.WithLabelValues(p.Type).Inc()
+	caLogger.Info("ACME: Request failed!",
+		zap.String("Tenant ID:", tenantFromRequest(r)),
+		zap.String("Path:", r.URL.Path),
+		zap.Error(err),
+	)
+
+	s.addNonce(w)
+	w.Header().Set(headerContentType, contentTypeProblem)
+	w.WriteHeader(p.Status)
+	err = json.NewEncoder(w).Encode(p)
+	if err != nil {
+		caLogger.Error("ACME: Failed to encode the problem document!",
+			zap.Error(err),
+		)
+	}
+}
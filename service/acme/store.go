@@ -0,0 +1,206 @@
+// package github.com/HPInc/krypton-ca/service/acme
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Persists the accounts, orders and authorizations of the ACME server as
+// records in the certificate store. Accounts, orders and authorizations are
+// stored within the scope of the tenant they were created in.
+package acme
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/go-jose/go-jose/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	// Status values of ACME objects.
+	statusPending     = "pending"
+	statusReady       = "ready"
+	statusValid       = "valid"
+	statusInvalid     = "invalid"
+	statusDeactivated = "deactivated"
+)
+
+// Identifier - an identifier for which a certificate is requested.
+type Identifier struct {
+	// The type of identifier (eg. dns).
+	Type string `json:"type"`
+
+	// The value of the identifier.
+	Value string `json:"value"`
+}
+
+// An ACME account. Accounts are identified by the thumbprint of their key.
+type account struct {
+	ID      string           `json:"id"`
+	Status  string           `json:"status"`
+	Contact []string         `json:"contact,omitempty"`
+	Key     *jose.JSONWebKey `json:"key"`
+}
+
+// An order for a certificate placed by an ACME account.
+type order struct {
+	ID               string       `json:"id"`
+	AccountID        string       `json:"account_id"`
+	Status           string       `json:"status"`
+	Expires          time.Time    `json:"expires"`
+	Identifiers      []Identifier `json:"identifiers"`
+	AuthorizationIDs []string     `json:"authorization_ids"`
+	Certificate      []byte       `json:"certificate,omitempty"`
+	Error            *Problem     `json:"error,omitempty"`
+}
+
+// A challenge offered to prove control of an identifier.
+type challenge struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Token     string    `json:"token"`
+	Status    string    `json:"status"`
+	Validated time.Time `json:"validated,omitempty"`
+	Error     *Problem  `json:"error,omitempty"`
+}
+
+// An authorization of an ACME account for an identifier.
+type authorization struct {
+	ID         string       `json:"id"`
+	AccountID  string       `json:"account_id"`
+	OrderID    string       `json:"order_id"`
+	Identifier Identifier   `json:"identifier"`
+	Status     string       `json:"status"`
+	Expires    time.Time    `json:"expires"`
+	Challenges []*challenge `json:"challenges"`
+}
+
+// Returns a random value encoded for use in URLs, used for nonces, tokens and
+// the IDs of ACME objects.
+func newRandomValue() (string, error) {
+	value := make([]byte, 16)
+	_, err := rand.Read(value)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(value), nil
+}
+
+// Retrieve the ACME object of the specified kind and ID within the tenant. A
+// problem is returned if the object does not exist.
+func (s *Server) loadObject(ctx context.Context,
+	kind string, tenantID string, id string, object interface{}) error {
+	record, err := s.store.GetRecord(ctx, kind, tenantID, id)
+	if err != nil {
+		if errors.Is(err, common.ErrRecordNotFound) {
+			return notFoundProblem("the requested resource does not exist")
+		}
+		caLogger.Error("ACME: Failed to retrieve the object from the store!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Kind:", kind),
+			zap.String("ID:", id),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	err = json.Unmarshal(record.Data, object)
+	if err != nil {
+		caLogger.Error("ACME: Failed to decode the object!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Kind:", kind),
+			zap.String("ID:", id),
+			zap.Error(err),
+		)
+		return err
+	}
+	return nil
+}
+
+// Persist the ACME object of the specified kind and ID within the tenant.
+func (s *Server) saveObject(ctx context.Context,
+	kind string, tenantID string, id string,
+	object interface{}, expiresAt time.Time) error {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.PutRecord(ctx, &common.Record{
+		Kind:      kind,
+		Scope:     tenantID,
+		ID:        id,
+		Data:      data,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		caLogger.Error("ACME: Failed to persist the object in the store!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Kind:", kind),
+			zap.String("ID:", id),
+			zap.Error(err),
+		)
+		return err
+	}
+	return nil
+}
+
+func (s *Server) loadAccount(ctx context.Context,
+	tenantID string, id string) (*account, error) {
+	var acct account
+	err := s.loadObject(ctx, common.RecordKindAcmeAccount, tenantID, id, &acct)
+	if err != nil {
+		var p *Problem
+		if errors.As(err, &p) {
+			return nil, newProblem(problemAccountDoesNotExist,
+				http.StatusBadRequest, "the account does not exist")
+		}
+		return nil, err
+	}
+	return &acct, nil
+}
+
+func (s *Server) saveAccount(ctx context.Context,
+	tenantID string, acct *account) error {
+	return s.saveObject(ctx, common.RecordKindAcmeAccount, tenantID, acct.ID, acct,
+		time.Time{})
+}
+
+func (s *Server) loadOrder(ctx context.Context,
+	tenantID string, id string) (*order, error) {
+	var o order
+	err := s.loadObject(ctx, common.RecordKindAcmeOrder, tenantID, id, &o)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (s *Server) saveOrder(ctx context.Context,
+	tenantID string, o *order) error {
+	return s.saveObject(ctx, common.RecordKindAcmeOrder, tenantID, o.ID, o,
+		o.Expires)
+}
+
+func (s *Server) loadAuthorization(ctx context.Context, tenantID string,
+	id string) (*authorization, error) {
+	var authz authorization
+	err := s.loadObject(ctx, common.RecordKindAcmeAuthorization, tenantID, id,
+		&authz)
+	if err != nil {
+		return nil, err
+	}
+	return &authz, nil
+}
+
+func (s *Server) saveAuthorization(ctx context.Context,
+	tenantID string, authz *authorization) error {
+	return s.saveObject(ctx, common.RecordKindAcmeAuthorization, tenantID, authz.ID,
+		authz, authz.Expires)
+}
@@ -0,0 +1,119 @@
+// package github.com/HPInc/krypton-ca/service
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the -export and -import commands, used to export the contents of
+// the configured certificate store to a signed archive and to import an
+// archive into the configured certificate store. Signing certificates in the
+// archive must chain to the configured root CA certificate before anything is
+// imported. The -dry_run flag verifies an archive without importing it.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/backup"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"go.uber.org/zap"
+)
+
+// Export the contents of the configured certificate store to the specified
+// archive file.
+func runExport(filename string) error {
+	settings := cfgMgr.GetBackupConfig()
+	if settings.SigningKey == "" {
+		return errors.New("the key used to sign archives must be specified using CA_BACKUP_SIGNING_KEY")
+	}
+
+	store, err := certstore.Init(caLogger, cfgMgr.GetCertStoreProvider())
+	if err != nil {
+		return err
+	}
+	defer store.Shutdown()
+
+	archive, err := backup.Export(caLogger, store, cfgMgr.GetCertStoreProvider())
+	if err != nil {
+		return err
+	}
+
+	fh, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = backup.WriteArchive(fh, archive, []byte(settings.SigningKey))
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d signing certificates and %d records to %s\n",
+		len(archive.SigningCertificates), len(archive.Records), filename)
+	return nil
+}
+
+// Import the specified archive file into the configured certificate store. If
+// dryRun is set, the archive is only verified.
+func runImport(filename string, dryRun bool) error {
+	settings := cfgMgr.GetBackupConfig()
+	if settings.SigningKey == "" {
+		return errors.New("the key used to verify archives must be specified using CA_BACKUP_SIGNING_KEY")
+	}
+	if settings.RootCertFile == "" {
+		return errors.New("the root CA certificate must be specified using backup/root_cert_file")
+	}
+
+	root, err := backup.LoadRootCertificate(settings.RootCertFile)
+	if err != nil {
+		return err
+	}
+
+	fh, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	archive, err := backup.ReadArchive(fh, []byte(settings.SigningKey))
+	_ = fh.Close()
+	if err != nil {
+		return err
+	}
+	caLogger.Info("Read the archive.",
+		zap.String("Archive:", filename),
+		zap.String("Exported from:", archive.CertStore),
+		zap.Time("Exported at:", archive.CreatedAt),
+	)
+
+	store, err := certstore.Init(caLogger, cfgMgr.GetCertStoreProvider())
+	if err != nil {
+		return err
+	}
+	defer store.Shutdown()
+
+	report, err := backup.Import(context.Background(), caLogger, store,
+		archive, root, dryRun)
+	for _, failure := range report.Failures {
+		fmt.Printf("FAILED: %s: %v\n", failure.CertID, failure.Err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("Verified %d signing certificates and %d records in %s (dry run)\n",
+			report.SigningCertificates, report.Records, filename)
+	} else {
+		fmt.Printf("Imported %d signing certificates and %d records from %s\n",
+			report.SigningCertificates-report.ExistingSigningCertificates,
+			report.Records, filename)
+		if report.ExistingSigningCertificates != 0 {
+			fmt.Printf("Skipped %d signing certificates which already exist\n",
+				report.ExistingSigningCertificates)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// package github.com/HPInc/krypton-ca/service/caerrors
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines the structured errors returned by the KMS providers and certificate
+// stores used by the CA. Each error is assigned a category, which allows the
+// RPC layer to choose the status code and status message reported to callers
+// without inspecting error strings.
+package caerrors
+
+import (
+	"context"
+	"errors"
+)
+
+// Category - classifies why an operation failed.
+type Category int
+
+const (
+	// An unexpected failure within the CA. This is the category of errors
+	// that have not been assigned a category.
+	Internal Category = iota
+
+	// The caller specified an invalid or malformed input (eg. a CSR).
+	InvalidInput
+
+	// The requested entity (eg. tenant signing certificate) was not found.
+	NotFound
+
+	// The operation conflicts with the current state of an entity (eg. it
+	// already exists).
+	Conflict
+
+	// A dependency of the CA (eg. the KMS or the database) is unavailable or
+	// throttled the request. The operation may succeed if it is retried.
+	DependencyUnavailable
+
+	// The operation is not permitted by a policy configured for the CA (eg.
+	// the tenant has reached its device cap).
+	PolicyViolation
+
+	// The caller cancelled the request before the operation completed.
+	Canceled
+)
+
+var categoryNames = map[Category]string{
+	Internal:              "internal",
+	InvalidInput:          "invalid_input",
+	NotFound:              "not_found",
+	Conflict:              "conflict",
+	DependencyUnavailable: "dependency_unavailable",
+	PolicyViolation:       "policy_violation",
+	Canceled:              "canceled",
+}
+
+// String - returns the name of the category.
+func (c Category) String() string {
+	if name, ok := categoryNames[c]; ok {
+		return name
+	}
+	return categoryNames[Internal]
+}
+
+// Error - an error that has been assigned a category. The message describes
+// the failure and is suitable to be returned to callers of the CA, while the
+// underlying error (if any) is retained for logging and for errors.Is/As.
+type Error struct {
+	// The category of the failure.
+	Category Category
+
+	// Description of the failure.
+	Message string
+
+	// The underlying error that caused the failure, if any.
+	Err error
+}
+
+// Error - returns the description of the error, including the underlying
+// error.
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+// Unwrap - returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New - returns a new error with the specified category and message.
+func New(category Category, message string) error {
+	return &Error{
+		Category: category,
+		Message:  message,
+	}
+}
+
+// Wrap - wraps the specified error into an error with the specified category
+// and message. If err is nil, nil is returned.
+func Wrap(category Category, message string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{
+		Category: category,
+		Message:  message,
+		Err:      err,
+	}
+}
+
+// WrapIfUncategorized - wraps the specified error into an error with the
+// specified category and message, unless it has already been assigned a
+// category. If err is nil, nil is returned.
+func WrapIfUncategorized(category Category, message string, err error) error {
+	var e *Error
+	if (err == nil) || errors.As(err, &e) {
+		return err
+	}
+	return Wrap(category, message, err)
+}
+
+// CategoryOf - returns the category of the specified error. Errors caused by
+// the cancellation of the request are categorized as Canceled, even if they
+// were assigned another category when a call to a dependency was abandoned.
+// Errors that have not been assigned a category are treated as internal
+// errors, except for context deadline errors which indicate that a dependency
+// did not respond in time.
+func CategoryOf(err error) Category {
+	if errors.Is(err, context.Canceled) {
+		return Canceled
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Category
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return DependencyUnavailable
+	}
+	return Internal
+}
+
+// MessageOf - returns the message of the outermost categorized error, or an
+// empty string if the error has not been assigned a category.
+func MessageOf(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Message
+	}
+	return ""
+}
+
+// Is - checks whether the specified error belongs to the specified category.
+func Is(err error, category Category) bool {
+	return (err != nil) && (CategoryOf(err) == category)
+}
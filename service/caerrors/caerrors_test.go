@@ -0,0 +1,49 @@
+package caerrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var errTestNotFound = New(NotFound, "entity not found")
+
+func TestCategoryOf(t *testing.T) {
+	tests := []struct {
+		err      error
+		category Category
+	}{
+		{errTestNotFound, NotFound},
+		{fmt.Errorf("lookup failed: %w", errTestNotFound), NotFound},
+		{Wrap(Conflict, "already exists", errTestNotFound), Conflict},
+		{errors.New("uncategorized"), Internal},
+		{fmt.Errorf("call failed: %w", context.DeadlineExceeded),
+			DependencyUnavailable},
+		{fmt.Errorf("call failed: %w", context.Canceled), Canceled},
+		{Wrap(DependencyUnavailable, "call failed", context.Canceled), Canceled},
+	}
+
+	for _, test := range tests {
+		if category := CategoryOf(test.err); category != test.category {
+			t.Errorf("CategoryOf(%v) = %v, expected %v", test.err, category,
+				test.category)
+		}
+	}
+}
+
+func TestWrapIfUncategorized(t *testing.T) {
+	err := WrapIfUncategorized(Internal, "store failure", errTestNotFound)
+	if !errors.Is(err, errTestNotFound) || !Is(err, NotFound) {
+		t.Errorf("categorized error was re-wrapped: %v", err)
+	}
+
+	err = WrapIfUncategorized(Internal, "store failure", errors.New("io error"))
+	if !Is(err, Internal) || (MessageOf(err) != "store failure") {
+		t.Errorf("uncategorized error was not wrapped: %v", err)
+	}
+
+	if WrapIfUncategorized(Internal, "store failure", nil) != nil {
+		t.Errorf("nil error was wrapped")
+	}
+}
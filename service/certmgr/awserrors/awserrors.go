@@ -0,0 +1,76 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/awserrors
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Assigns categories to errors returned by AWS services (KMS, Dynamo DB) used
+// by the CA, and determines which of them may be retried.
+package awserrors
+
+import (
+	"context"
+	"errors"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+)
+
+// IsThrottlingError - checks whether the specified error indicates that the
+// request was throttled by an AWS service.
+func IsThrottlingError(err error) bool {
+	return (err != nil) &&
+		(retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary)
+}
+
+// IsRetryableError - checks whether the specified error returned by an AWS
+// SDK call is transient, so that the request may be retried. Throttling
+// errors, server side faults, timeouts and failures to connect to the AWS
+// service are retryable. Cancelled requests and other errors returned by the
+// AWS service are not.
+func IsRetryableError(err error) bool {
+	if (err == nil) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if IsThrottlingError(err) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && (apiErr.ErrorFault() == smithy.FaultServer) {
+		return true
+	}
+	return retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) ==
+		aws.TrueTernary
+}
+
+// WrapError - wraps an error returned by an AWS SDK call into an error with
+// the appropriate category. Throttling errors, server side faults and failures
+// to reach the AWS service are reported as DependencyUnavailable. Other
+// errors returned by the AWS service indicate a problem with the request made
+// by the CA and are reported as Internal.
+func WrapError(message string, err error) error {
+	var (
+		e      *caerrors.Error
+		apiErr smithy.APIError
+	)
+	if (err == nil) || errors.As(err, &e) {
+		return err
+	}
+
+	if IsThrottlingError(err) {
+		return caerrors.Wrap(caerrors.DependencyUnavailable, message, err)
+	}
+
+	if errors.As(err, &apiErr) {
+		if apiErr.ErrorFault() == smithy.FaultServer {
+			return caerrors.Wrap(caerrors.DependencyUnavailable, message, err)
+		}
+		return caerrors.Wrap(caerrors.Internal, message, err)
+	}
+
+	// The AWS service did not return a response (eg. network failures and
+	// timeouts).
+	return caerrors.Wrap(caerrors.DependencyUnavailable, message, err)
+}
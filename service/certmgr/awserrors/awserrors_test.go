@@ -0,0 +1,60 @@
+package awserrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/aws/smithy-go"
+)
+
+func TestWrapError(t *testing.T) {
+	tests := []struct {
+		err      error
+		category caerrors.Category
+	}{
+		{&smithy.GenericAPIError{Code: "ThrottlingException",
+			Fault: smithy.FaultClient}, caerrors.DependencyUnavailable},
+		{&smithy.GenericAPIError{Code: "InternalFailure",
+			Fault: smithy.FaultServer}, caerrors.DependencyUnavailable},
+		{&smithy.GenericAPIError{Code: "ValidationException",
+			Fault: smithy.FaultClient}, caerrors.Internal},
+		{errors.New("connection refused"), caerrors.DependencyUnavailable},
+		{context.Canceled, caerrors.Canceled},
+	}
+
+	for _, test := range tests {
+		err := WrapError("aws call failed", test.err)
+		if category := caerrors.CategoryOf(err); category != test.category {
+			t.Errorf("WrapError(%v) category = %v, expected %v", test.err,
+				category, test.category)
+		}
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		err       error
+		retryable bool
+	}{
+		{&smithy.GenericAPIError{Code: "ThrottlingException",
+			Fault: smithy.FaultClient}, true},
+		{&smithy.GenericAPIError{Code: "InternalFailure",
+			Fault: smithy.FaultServer}, true},
+		{&smithy.GenericAPIError{Code: "ValidationException",
+			Fault: smithy.FaultClient}, false},
+		{fmt.Errorf("call failed: %w", context.DeadlineExceeded), true},
+		{fmt.Errorf("call failed: %w", context.Canceled), false},
+		{errors.New("validation failed"), false},
+		{nil, false},
+	}
+
+	for _, test := range tests {
+		if retryable := IsRetryableError(test.err); retryable != test.retryable {
+			t.Errorf("IsRetryableError(%v) = %v, expected %v", test.err,
+				retryable, test.retryable)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/backup
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the export and import of the contents of the certificate store to
+// and from archives. Archives contain the signing certificates and unexpired
+// records held within the certificate store, and are independent of the
+// certificate store they were exported from, so they can be used to back up
+// the certificate store and to move between certificate stores (eg. from
+// localdb to dynamodb). Archives are versioned, and signed using HMAC-SHA256
+// so that tampered archives are rejected when they are imported.
+package backup
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/common"
+	"go.uber.org/zap"
+)
+
+var (
+	caLogger *zap.Logger
+
+	// ErrBackupNotSupported is returned if the certificate store does not
+	// support enumerating its contents.
+	ErrBackupNotSupported = caerrors.New(caerrors.Internal,
+		"certificate store does not support backups")
+
+	// ErrInvalidArchive is returned if the archive could not be parsed.
+	ErrInvalidArchive = caerrors.New(caerrors.InvalidInput,
+		"invalid archive")
+
+	// ErrUnsupportedArchiveVersion is returned if the archive was written
+	// using a newer version of the archive format.
+	ErrUnsupportedArchiveVersion = caerrors.New(caerrors.InvalidInput,
+		"unsupported archive format version")
+
+	// ErrInvalidArchiveSignature is returned if the signature of the archive
+	// could not be verified.
+	ErrInvalidArchiveSignature = caerrors.New(caerrors.InvalidInput,
+		"archive signature verification failed")
+)
+
+const (
+	// Version of the format used to write archives.
+	ArchiveFormatVersion = 1
+
+	// Algorithm used to sign archives.
+	archiveSignatureAlgorithm = "HMAC-SHA256"
+)
+
+// Archive - represents the contents of a certificate store exported to an
+// archive.
+type Archive struct {
+	// Version of the format used to write the archive.
+	FormatVersion int `json:"format_version"`
+
+	// Time at which the archive was exported.
+	CreatedAt time.Time `json:"created_at"`
+
+	// Name of the certificate store the archive was exported from.
+	CertStore string `json:"cert_store"`
+
+	// Signing certificates held within the certificate store.
+	SigningCertificates []SigningCertificateEntry `json:"signing_certificates"`
+
+	// Unexpired records held within the certificate store.
+	Records []RecordEntry `json:"records"`
+}
+
+// SigningCertificateEntry - represents a signing certificate within an archive.
+type SigningCertificateEntry struct {
+	CertID      string `json:"cert_id"`
+	KmsKeyID    string `json:"kms_key_id"`
+	Certificate []byte `json:"certificate"`
+}
+
+// RecordEntry - represents a record within an archive.
+type RecordEntry struct {
+	Kind      string     `json:"kind"`
+	Scope     string     `json:"scope"`
+	ID        string     `json:"id"`
+	Data      []byte     `json:"data"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// signedArchive - the envelope in which archives are written. The payload is
+// the JSON encoded archive, and the signature is computed over the payload.
+type signedArchive struct {
+	FormatVersion      int    `json:"format_version"`
+	SignatureAlgorithm string `json:"signature_algorithm"`
+	Payload            []byte `json:"payload"`
+	Signature          []byte `json:"signature"`
+}
+
+// Export - exports the contents of the specified certificate store to a new
+// archive. The name of the certificate store is recorded within the archive.
+func Export(logger *zap.Logger, store certstore.CertStore,
+	storeName string) (*Archive, error) {
+	caLogger = logger
+
+	enumerator, ok := certstore.Unwrap(store).(certstore.Enumerator)
+	if !ok {
+		caLogger.Error("The certificate store does not support exporting its contents!",
+			zap.String("Certificate store:", storeName),
+		)
+		return nil, ErrBackupNotSupported
+	}
+
+	archive := &Archive{
+		FormatVersion:       ArchiveFormatVersion,
+		CreatedAt:           time.Now().UTC(),
+		CertStore:           storeName,
+		SigningCertificates: []SigningCertificateEntry{},
+		Records:             []RecordEntry{},
+	}
+	err := enumerator.Enumerate(func(entry *common.SigningCertificate) error {
+		archive.SigningCertificates = append(archive.SigningCertificates,
+			SigningCertificateEntry{
+				CertID:      entry.TenantID,
+				KmsKeyID:    entry.KmsKeyID,
+				Certificate: entry.Certificate,
+			})
+		return nil
+	}, func(record *common.Record) error {
+		entry := RecordEntry{
+			Kind:  record.Kind,
+			Scope: record.Scope,
+			ID:    record.ID,
+			Data:  record.Data,
+		}
+		if !record.ExpiresAt.IsZero() {
+			expiresAt := record.ExpiresAt.UTC()
+			entry.ExpiresAt = &expiresAt
+		}
+		archive.Records = append(archive.Records, entry)
+		return nil
+	})
+	if err != nil {
+		caLogger.Error("Failed to enumerate the contents of the certificate store!",
+			zap.String("Certificate store:", storeName),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	caLogger.Info("Exported the contents of the certificate store.",
+		zap.String("Certificate store:", storeName),
+		zap.Int("Signing certificates:", len(archive.SigningCertificates)),
+		zap.Int("Records:", len(archive.Records)),
+	)
+	return archive, nil
+}
+
+// Returns the signature of the specified payload.
+func signPayload(payload []byte, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// WriteArchive - writes the archive to the specified writer, signed using the
+// specified key.
+func WriteArchive(w io.Writer, archive *Archive, key []byte) error {
+	payload, err := json.Marshal(archive)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(signedArchive{
+		FormatVersion:      archive.FormatVersion,
+		SignatureAlgorithm: archiveSignatureAlgorithm,
+		Payload:            payload,
+		Signature:          signPayload(payload, key),
+	})
+}
+
+// ReadArchive - reads an archive from the specified reader, after verifying
+// its signature using the specified key.
+func ReadArchive(r io.Reader, key []byte) (*Archive, error) {
+	var envelope signedArchive
+	err := json.NewDecoder(r).Decode(&envelope)
+	if err != nil {
+		return nil, caerrors.Wrap(caerrors.InvalidInput,
+			"failed to parse the archive", err)
+	}
+	if envelope.FormatVersion > ArchiveFormatVersion {
+		return nil, ErrUnsupportedArchiveVersion
+	}
+	if (envelope.FormatVersion <= 0) ||
+		(envelope.SignatureAlgorithm != archiveSignatureAlgorithm) {
+		return nil, ErrInvalidArchive
+	}
+	if !hmac.Equal(envelope.Signature, signPayload(envelope.Payload, key)) {
+		return nil, ErrInvalidArchiveSignature
+	}
+
+	var archive Archive
+	err = json.Unmarshal(envelope.Payload, &archive)
+	if err != nil {
+		return nil, caerrors.Wrap(caerrors.InvalidInput,
+			"failed to parse the archive", err)
+	}
+	if archive.FormatVersion != envelope.FormatVersion {
+		return nil, ErrInvalidArchive
+	}
+	return &archive, nil
+}
@@ -0,0 +1,353 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	_ "github.com/HPInc/krypton-ca/service/certmgr/certstore/localdb"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+var testSigningKey = []byte("archive-signing-key")
+
+// Issue a certificate with the specified subject, signed by the specified
+// issuer. If no issuer is specified, a self-signed root is returned.
+func newTestCertificate(t *testing.T, subject string, issuer *x509.Certificate,
+	issuerKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate a key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: subject},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if issuer == nil {
+		issuer, issuerKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer,
+		&key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatalf("Failed to create the certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse the certificate: %v", err)
+	}
+	return cert, key
+}
+
+// Initialize a local certificate store within a temporary directory.
+func newTestStore(t *testing.T) certstore.CertStore {
+	t.Chdir(t.TempDir())
+	store, err := certstore.Init(zap.NewNop(), common.CertStoreLocalDb)
+	if err != nil {
+		t.Fatalf("Failed to initialize the certificate store: %v", err)
+	}
+	t.Cleanup(store.Shutdown)
+	return store
+}
+
+// Returns a signed archive containing the specified signing certificates and
+// a device record.
+func newTestArchive(certs ...*x509.Certificate) *Archive {
+	archive := &Archive{
+		FormatVersion: ArchiveFormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		CertStore:     common.CertStoreLocalDb,
+	}
+	for _, cert := range certs {
+		archive.SigningCertificates = append(archive.SigningCertificates,
+			SigningCertificateEntry{
+				CertID:      cert.Subject.CommonName,
+				Certificate: cert.Raw,
+			})
+	}
+	expired := time.Now().Add(-time.Minute)
+	archive.Records = []RecordEntry{
+		{Kind: common.RecordKindDevice, Scope: "tenant-1", ID: "device-1",
+			Data: []byte("data")},
+		{Kind: common.RecordKindDevice, Scope: "tenant-1", ID: "device-2",
+			Data: []byte("expired"), ExpiresAt: &expired},
+	}
+	return archive
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	root, rootKey := newTestCertificate(t, "root", nil, nil)
+	tenant, _ := newTestCertificate(t, "tenant-1", root, rootKey)
+
+	store := newTestStore(t)
+	for _, cert := range []*x509.Certificate{root, tenant} {
+		err := store.AddCertificate(ctx, &common.SigningCertificate{
+			TenantID:    cert.Subject.CommonName,
+			KmsKeyID:    "key-" + cert.Subject.CommonName,
+			Certificate: cert.Raw,
+		})
+		if err != nil {
+			t.Fatalf("Failed to add the signing certificate: %v", err)
+		}
+	}
+	err := store.PutRecord(ctx, &common.Record{Kind: common.RecordKindTenantQuota,
+		Scope: "tenant-1", ID: "quota", Data: []byte("quota")})
+	if err != nil {
+		t.Fatalf("Failed to add the record: %v", err)
+	}
+
+	archive, err := Export(zap.NewNop(), store, common.CertStoreLocalDb)
+	if err != nil {
+		t.Fatalf("Failed to export the certificate store: %v", err)
+	}
+	if (len(archive.SigningCertificates) != 2) || (len(archive.Records) != 1) {
+		t.Fatalf("Unexpected archive contents: %+v", archive)
+	}
+
+	buffer := &bytes.Buffer{}
+	if err = WriteArchive(buffer, archive, testSigningKey); err != nil {
+		t.Fatalf("Failed to write the archive: %v", err)
+	}
+	imported, err := ReadArchive(bytes.NewReader(buffer.Bytes()), testSigningKey)
+	if err != nil {
+		t.Fatalf("Failed to read the archive: %v", err)
+	}
+
+	// Import the archive into a new certificate store.
+	destination := newTestStore(t)
+	report, err := Import(ctx, zap.NewNop(), destination, imported, root, false)
+	if err != nil {
+		t.Fatalf("Failed to import the archive: %v", err)
+	}
+	if (report.SigningCertificates != 2) || (report.Records != 1) {
+		t.Errorf("Unexpected import report: %+v", report)
+	}
+
+	entry, err := destination.GetCertificate(ctx, tenant.Subject.CommonName)
+	if err != nil {
+		t.Fatalf("Failed to get the imported signing certificate: %v", err)
+	}
+	if (entry.KmsKeyID != "key-tenant-1") || !bytes.Equal(entry.Certificate, tenant.Raw) {
+		t.Errorf("Unexpected signing certificate imported: %+v", entry)
+	}
+	record, err := destination.GetRecord(ctx, common.RecordKindTenantQuota,
+		"tenant-1", "quota")
+	if (err != nil) || !bytes.Equal(record.Data, []byte("quota")) {
+		t.Errorf("Expected the record to be imported (error: %v)", err)
+	}
+
+	// Signing certificates which already exist are not replaced.
+	report, err = Import(ctx, zap.NewNop(), destination, imported, root, false)
+	if (err != nil) || (report.ExistingSigningCertificates != 2) {
+		t.Errorf("Expected existing signing certificates to be skipped, got %+v, %v",
+			report, err)
+	}
+}
+
+func TestReadArchive_Rejected(t *testing.T) {
+	root, _ := newTestCertificate(t, "root", nil, nil)
+	buffer := &bytes.Buffer{}
+	err := WriteArchive(buffer, newTestArchive(root), testSigningKey)
+	if err != nil {
+		t.Fatalf("Failed to write the archive: %v", err)
+	}
+
+	// An archive signed using a different key is rejected.
+	_, err = ReadArchive(bytes.NewReader(buffer.Bytes()), []byte("another-key"))
+	if !errors.Is(err, ErrInvalidArchiveSignature) {
+		t.Errorf("Expected the signature to be rejected, got %v", err)
+	}
+
+	// A tampered archive is rejected.
+	var envelope signedArchive
+	if err = json.Unmarshal(buffer.Bytes(), &envelope); err != nil {
+		t.Fatalf("Failed to parse the archive: %v", err)
+	}
+	envelope.Payload = bytes.Replace(envelope.Payload, []byte("device-1"),
+		[]byte("device-9"), 1)
+	tampered, _ := json.Marshal(envelope)
+	_, err = ReadArchive(bytes.NewReader(tampered), testSigningKey)
+	if !errors.Is(err, ErrInvalidArchiveSignature) {
+		t.Errorf("Expected the tampered archive to be rejected, got %v", err)
+	}
+
+	// An archive written using a newer format is rejected.
+	envelope.FormatVersion = ArchiveFormatVersion + 1
+	newer, _ := json.Marshal(envelope)
+	_, err = ReadArchive(bytes.NewReader(newer), testSigningKey)
+	if !errors.Is(err, ErrUnsupportedArchiveVersion) {
+		t.Errorf("Expected the newer archive to be rejected, got %v", err)
+	}
+}
+
+func TestImport_VerificationFailure(t *testing.T) {
+	ctx := context.Background()
+	root, rootKey := newTestCertificate(t, "root", nil, nil)
+	tenant, _ := newTestCertificate(t, "tenant-1", root, rootKey)
+	otherRoot, otherKey := newTestCertificate(t, "other-root", nil, nil)
+	otherTenant, _ := newTestCertificate(t, "tenant-2", otherRoot, otherKey)
+
+	store := newTestStore(t)
+	archive := newTestArchive(root, tenant, otherTenant)
+	report, err := Import(ctx, zap.NewNop(), store, archive, root, false)
+	if !errors.Is(err, ErrVerificationFailed) {
+		t.Fatalf("Expected the archive to fail verification, got %v", err)
+	}
+	if (len(report.Failures) != 1) || (report.Failures[0].CertID != "tenant-2") {
+		t.Errorf("Unexpected verification failures: %+v", report.Failures)
+	}
+
+	// Nothing is imported if verification fails.
+	_, err = store.GetCertificate(ctx, tenant.Subject.CommonName)
+	if !errors.Is(err, common.ErrCertStoreNotFound) {
+		t.Errorf("Expected nothing to be imported, got %v", err)
+	}
+}
+
+func TestImport_DryRun(t *testing.T) {
+	ctx := context.Background()
+	root, rootKey := newTestCertificate(t, "root", nil, nil)
+	tenant, _ := newTestCertificate(t, "tenant-1", root, rootKey)
+
+	store := newTestStore(t)
+	report, err := Import(ctx, zap.NewNop(), store, newTestArchive(root, tenant),
+		root, true)
+	if err != nil {
+		t.Fatalf("Failed to verify the archive: %v", err)
+	}
+	if !report.DryRun || (report.SigningCertificates != 2) ||
+		(report.Records != 1) || (report.ExpiredRecords != 1) {
+		t.Errorf("Unexpected import report: %+v", report)
+	}
+
+	_, err = store.GetCertificate(ctx, tenant.Subject.CommonName)
+	if !errors.Is(err, common.ErrCertStoreNotFound) {
+		t.Errorf("Expected nothing to be imported in a dry run, got %v", err)
+	}
+}
+
+func TestImport_ExistingTenant(t *testing.T) {
+	ctx := context.Background()
+	root, rootKey := newTestCertificate(t, "root", nil, nil)
+	existing, _ := newTestCertificate(t, "tenant-1", root, rootKey)
+	archived, _ := newTestCertificate(t, "tenant-1", root, rootKey)
+
+	// The store already contains a signing certificate for the tenant and a
+	// record with the same ID as the record in the archive.
+	store := newTestStore(t)
+	err := store.AddCertificate(ctx, &common.SigningCertificate{
+		TenantID:    existing.Subject.CommonName,
+		KmsKeyID:    "key-existing",
+		Certificate: existing.Raw,
+	})
+	if err != nil {
+		t.Fatalf("Failed to add the signing certificate: %v", err)
+	}
+	err = store.PutRecord(ctx, &common.Record{Kind: common.RecordKindDevice,
+		Scope: "tenant-1", ID: "device-1", Data: []byte("existing")})
+	if err != nil {
+		t.Fatalf("Failed to add the record: %v", err)
+	}
+
+	report, err := Import(ctx, zap.NewNop(), store, newTestArchive(archived),
+		root, false)
+	if err != nil {
+		t.Fatalf("Failed to import the archive: %v", err)
+	}
+	if report.ExistingSigningCertificates != 1 {
+		t.Errorf("Expected the existing signing certificate to be skipped, got %+v",
+			report)
+	}
+
+	// The existing signing certificate is kept.
+	entry, err := store.GetCertificate(ctx, existing.Subject.CommonName)
+	if err != nil {
+		t.Fatalf("Failed to get the signing certificate: %v", err)
+	}
+	if (entry.KmsKeyID != "key-existing") || !bytes.Equal(entry.Certificate, existing.Raw) {
+		t.Errorf("Expected the existing signing certificate to be kept, got %+v", entry)
+	}
+
+	// The existing record is overwritten.
+	record, err := store.GetRecord(ctx, common.RecordKindDevice, "tenant-1",
+		"device-1")
+	if (err != nil) || !bytes.Equal(record.Data, []byte("data")) {
+		t.Errorf("Expected the record to be overwritten, got %+v (error: %v)",
+			record, err)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	err := store.AddCertificate(ctx, &common.SigningCertificate{
+		TenantID: "tenant-1", Certificate: []byte("certificate")})
+	if err != nil {
+		t.Fatalf("Failed to add the signing certificate: %v", err)
+	}
+
+	settings := &config.Backup{
+		SnapshotDirectory: t.TempDir(),
+		SnapshotsRetained: 2,
+	}
+	s := StartSnapshots(zap.NewNop(), store, settings)
+	if s == nil {
+		t.Fatalf("Expected snapshots to be enabled")
+	}
+	defer s.Stop()
+
+	var filename string
+	for i := 0; i < 3; i++ {
+		filename, err = s.WriteSnapshot()
+		if err != nil {
+			t.Fatalf("Failed to write the snapshot: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	// Only the most recent snapshots are retained.
+	entries, err := os.ReadDir(settings.SnapshotDirectory)
+	if err != nil {
+		t.Fatalf("Failed to read the snapshot directory: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 snapshots to be retained, found %d", len(entries))
+	}
+
+	// The snapshot is a usable copy of the database.
+	db, err := bolt.Open(filename, 0600,
+		&bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to open the snapshot: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte("SigningCertificates")).Get([]byte("tenant-1")) == nil {
+			t.Errorf("Expected the signing certificate to be in the snapshot")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read the snapshot: %v", err)
+	}
+}
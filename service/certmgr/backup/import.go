@@ -0,0 +1,231 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/backup
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the import of archives into the certificate store. Before any
+// entries are written to the certificate store, each signing certificate in
+// the archive is verified to chain to the root CA certificate. Imports can be
+// run in dry-run mode, in which the archive is only verified.
+package backup
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/common"
+	"go.uber.org/zap"
+)
+
+// ErrVerificationFailed is returned if signing certificates in the archive do
+// not chain to the root CA certificate.
+var ErrVerificationFailed = caerrors.New(caerrors.InvalidInput,
+	"archive contains signing certificates which do not chain to the root CA certificate")
+
+// VerificationFailure - describes a signing certificate in the archive which
+// failed verification.
+type VerificationFailure struct {
+	// ID of the signing certificate within the certificate store.
+	CertID string
+
+	// Reason the signing certificate failed verification.
+	Err error
+}
+
+// ImportReport - summarizes the result of importing an archive.
+type ImportReport struct {
+	// Whether the archive was only verified, and not imported.
+	DryRun bool
+
+	// Number of signing certificates verified (and imported).
+	SigningCertificates int
+
+	// Number of signing certificates which were not imported because a
+	// signing certificate with the same ID already exists in the certificate
+	// store. Existing signing certificates are never replaced.
+	ExistingSigningCertificates int
+
+	// Number of records imported. Records which expired after the archive
+	// was exported are skipped.
+	Records        int
+	ExpiredRecords int
+
+	// Signing certificates which failed verification.
+	Failures []VerificationFailure
+}
+
+// LoadRootCertificate - loads the PEM encoded root CA certificate from the
+// specified file.
+func LoadRootCertificate(filename string) (*x509.Certificate, error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(contents)
+	if (block == nil) || (block.Type != "CERTIFICATE") {
+		return nil, fmt.Errorf("no PEM encoded certificate found in %s", filename)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// Verify - verifies that each signing certificate in the archive chains to the
+// specified root CA certificate, and returns the signing certificates which
+// failed verification. Signing certificates are verified as of the time they
+// became valid, so signing certificates which have since expired are not
+// reported.
+func Verify(archive *Archive, root *x509.Certificate) []VerificationFailure {
+	var failures []VerificationFailure
+
+	// Parse the signing certificates. Signing certificates which are CA
+	// certificates may be used as intermediates by other entries.
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	intermediates := x509.NewCertPool()
+	certs := make([]*x509.Certificate, len(archive.SigningCertificates))
+	for i, entry := range archive.SigningCertificates {
+		cert, err := x509.ParseCertificate(entry.Certificate)
+		if err != nil {
+			failures = append(failures, VerificationFailure{
+				CertID: entry.CertID,
+				Err:    fmt.Errorf("failed to parse the certificate: %w", err),
+			})
+			continue
+		}
+		certs[i] = cert
+		if cert.IsCA {
+			intermediates.AddCert(cert)
+		}
+	}
+
+	for i, cert := range certs {
+		if (cert == nil) || cert.Equal(root) {
+			continue
+		}
+
+		verifyAt := cert.NotBefore
+		if verifyAt.Before(root.NotBefore) {
+			verifyAt = root.NotBefore
+		}
+		_, err := cert.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   verifyAt,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			failures = append(failures, VerificationFailure{
+				CertID: archive.SigningCertificates[i].CertID,
+				Err:    err,
+			})
+		}
+	}
+	return failures
+}
+
+// Import - verifies the archive using the specified root CA certificate and
+// imports its contents into the specified certificate store. Signing
+// certificates in the store with the same IDs as entries in the archive are
+// kept, and the entries are skipped. Records in the store with the same IDs as
+// entries in the archive are overwritten. If any signing certificate fails
+// verification, nothing is imported. If dryRun is set, the archive is only
+// verified.
+func Import(ctx context.Context, logger *zap.Logger,
+	store certstore.CertStore, archive *Archive,
+	root *x509.Certificate, dryRun bool) (*ImportReport, error) {
+	caLogger = logger
+	report := &ImportReport{
+		DryRun:              dryRun,
+		SigningCertificates: len(archive.SigningCertificates),
+	}
+
+	report.Failures = Verify(archive, root)
+	for _, failure := range report.Failures {
+		caLogger.Error("Signing certificate does not chain to the root CA certificate!",
+			zap.String("Certificate ID:", failure.CertID),
+			zap.Error(failure.Err),
+		)
+	}
+	if len(report.Failures) != 0 {
+		return report, ErrVerificationFailed
+	}
+
+	now := time.Now()
+	for _, entry := range archive.Records {
+		if (entry.ExpiresAt != nil) && !now.Before(*entry.ExpiresAt) {
+			report.ExpiredRecords++
+		} else {
+			report.Records++
+		}
+	}
+	if dryRun {
+		caLogger.Info("Verified the archive. No entries were imported (dry run).",
+			zap.Int("Signing certificates:", report.SigningCertificates),
+			zap.Int("Records:", report.Records),
+		)
+		return report, nil
+	}
+
+	for _, entry := range archive.SigningCertificates {
+		err := store.AddCertificate(ctx, &common.SigningCertificate{
+			TenantID:    entry.CertID,
+			KmsKeyID:    entry.KmsKeyID,
+			Certificate: entry.Certificate,
+		})
+		if errors.Is(err, common.ErrTenantExists) {
+			caLogger.Info("Signing certificate already exists in the store. Skipping!",
+				zap.String("Certificate ID:", entry.CertID),
+			)
+			report.ExistingSigningCertificates++
+			continue
+		}
+		if err != nil {
+			caLogger.Error("Failed to import the signing certificate!",
+				zap.String("Certificate ID:", entry.CertID),
+				zap.Error(err),
+			)
+			return report, err
+		}
+	}
+
+	for _, entry := range archive.Records {
+		record := &common.Record{
+			Kind:  entry.Kind,
+			Scope: entry.Scope,
+			ID:    entry.ID,
+			Data:  entry.Data,
+		}
+		if entry.ExpiresAt != nil {
+			record.ExpiresAt = *entry.ExpiresAt
+		}
+		if record.IsExpired(now) {
+			continue
+		}
+
+		err := store.PutRecord(ctx, record)
+		if err != nil {
+			caLogger.Error("Failed to import the record!",
+				zap.String("Kind:", record.Kind),
+				zap.String("Record ID:", record.ID),
+				zap.Error(err),
+			)
+			return report, err
+		}
+	}
+
+	caLogger.Info("Imported the archive into the certificate store.",
+		zap.Int("Signing certificates:", report.SigningCertificates),
+		zap.Int("Existing signing certificates skipped:",
+			report.ExistingSigningCertificates),
+		zap.Int("Records:", report.Records),
+		zap.Int("Expired records skipped:", report.ExpiredRecords),
+	)
+	return report, nil
+}
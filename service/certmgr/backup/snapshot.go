@@ -0,0 +1,210 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/backup
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Writes snapshots of the certificate store to the configured snapshot
+// directory while the CA is running. Snapshots are written whenever the CA
+// receives SIGUSR1, and periodically if a snapshot interval is configured.
+// Each snapshot is written to a temporary file which is renamed once the
+// snapshot is complete, so the snapshot directory only ever contains complete
+// snapshots. Only the configured number of the most recent snapshots are
+// retained.
+package backup
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/config"
+	"go.uber.org/zap"
+)
+
+const (
+	// Snapshots are named using the time at which they were written, so
+	// that they sort in the order in which they were written.
+	snapshotFilePrefix = "certs-"
+	snapshotFileSuffix = ".db"
+	snapshotTimeFormat = "20060102T150405.000Z"
+)
+
+// SnapshotScheduler - writes snapshots of the certificate store.
+type SnapshotScheduler struct {
+	// Certificate store being snapshotted.
+	store certstore.Snapshotter
+
+	// Backup settings from the configuration file.
+	settings *config.Backup
+
+	// Signals requesting a snapshot, and channels used to stop the
+	// scheduler and to wait for it to stop.
+	signals chan os.Signal
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// StartSnapshots - starts writing snapshots of the specified certificate store
+// as configured by the backup settings. Returns nil if snapshots are disabled,
+// or if the certificate store does not support snapshots.
+func StartSnapshots(logger *zap.Logger, store certstore.CertStore,
+	settings *config.Backup) *SnapshotScheduler {
+	caLogger = logger
+	if settings.SnapshotDirectory == "" {
+		return nil
+	}
+
+	snapshotter, ok := certstore.Unwrap(store).(certstore.Snapshotter)
+	if !ok {
+		caLogger.Warn("The certificate store does not support snapshots. Snapshots are disabled!")
+		return nil
+	}
+
+	s := &SnapshotScheduler{
+		store:    snapshotter,
+		settings: settings,
+		signals:  make(chan os.Signal, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	signal.Notify(s.signals, syscall.SIGUSR1)
+	go s.run()
+
+	caLogger.Info("Started writing snapshots of the certificate store.",
+		zap.String("Snapshot directory:", settings.SnapshotDirectory),
+		zap.Int("Snapshot interval (minutes):", settings.SnapshotIntervalMinutes),
+	)
+	return s
+}
+
+// Stop - stops writing snapshots of the certificate store, and waits for any
+// snapshot being written to complete.
+func (s *SnapshotScheduler) Stop() {
+	if s == nil {
+		return
+	}
+	signal.Stop(s.signals)
+	close(s.stop)
+	<-s.done
+}
+
+// Writes snapshots when requested using SIGUSR1 or when the snapshot interval
+// elapses, until the scheduler is stopped.
+func (s *SnapshotScheduler) run() {
+	defer close(s.done)
+
+	var tick <-chan time.Time
+	if s.settings.SnapshotIntervalMinutes > 0 {
+		ticker := time.NewTicker(
+			time.Duration(s.settings.SnapshotIntervalMinutes) * time.Minute)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.signals:
+		case <-tick:
+		}
+
+		// Failures are logged, and the next snapshot is attempted as
+		// scheduled.
+		_, _ = s.WriteSnapshot()
+	}
+}
+
+// WriteSnapshot - writes a snapshot of the certificate store to the snapshot
+// directory, and removes snapshots which are no longer retained. Returns the
+// path to the snapshot.
+func (s *SnapshotScheduler) WriteSnapshot() (string, error) {
+	start := time.Now()
+	filename := filepath.Join(s.settings.SnapshotDirectory, snapshotFilePrefix+
+		start.UTC().Format(snapshotTimeFormat)+snapshotFileSuffix)
+
+	size, err := s.writeSnapshotFile(filename)
+	if err != nil {
+		caLogger.Error("Failed to write a snapshot of the certificate store!",
+			zap.String("Snapshot:", filename),
+			zap.Error(err),
+		)
+		return "", err
+	}
+	caLogger.Info("Wrote a snapshot of the certificate store.",
+		zap.String("Snapshot:", filename),
+		zap.Int64("Size:", size),
+		zap.Duration("Duration:", time.Since(start)),
+	)
+
+	err = s.pruneSnapshots()
+	if err != nil {
+		caLogger.Error("Failed to remove snapshots which are no longer retained!",
+			zap.String("Snapshot directory:", s.settings.SnapshotDirectory),
+			zap.Error(err),
+		)
+	}
+	return filename, nil
+}
+
+// Writes the snapshot to a temporary file within the snapshot directory, which
+// is renamed to the specified filename once the snapshot is complete.
+func (s *SnapshotScheduler) writeSnapshotFile(filename string) (int64, error) {
+	fh, err := os.CreateTemp(s.settings.SnapshotDirectory, ".snapshot-*")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		// The temporary file no longer exists once it has been renamed.
+		_ = os.Remove(fh.Name())
+	}()
+
+	size, err := s.store.Snapshot(fh)
+	if err == nil {
+		err = fh.Sync()
+	}
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return size, os.Rename(fh.Name(), filename)
+}
+
+// Removes all but the configured number of the most recent snapshots from the
+// snapshot directory.
+func (s *SnapshotScheduler) pruneSnapshots() error {
+	entries, err := os.ReadDir(s.settings.SnapshotDirectory)
+	if err != nil {
+		return err
+	}
+
+	var snapshots []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() &&
+			strings.HasPrefix(name, snapshotFilePrefix) &&
+			strings.HasSuffix(name, snapshotFileSuffix) {
+			snapshots = append(snapshots, name)
+		}
+	}
+	if len(snapshots) <= s.settings.SnapshotsRetained {
+		return nil
+	}
+
+	sort.Strings(snapshots)
+	for _, name := range snapshots[:len(snapshots)-s.settings.SnapshotsRetained] {
+		err = os.Remove(filepath.Join(s.settings.SnapshotDirectory, name))
+		if err != nil {
+			return fmt.Errorf("failed to remove snapshot %s: %w", name, err)
+		}
+	}
+	return nil
+}
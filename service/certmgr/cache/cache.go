@@ -0,0 +1,150 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/cache
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements a size bounded, in-memory cache whose entries expire after a
+// time-to-live (TTL). The least recently used entry is evicted when the cache
+// is full. The cache is used to avoid round trips to the certificate store and
+// the KMS when issuing certificates. Hits, misses and evictions are reported
+// using metrics labelled with the name of the cache.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/metrics"
+)
+
+// Cache - a size bounded cache with expiring entries. A nil cache caches
+// nothing, so callers can disable caching by not creating the cache. The
+// cache is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	// Name of the cache, used to label metrics.
+	name string
+
+	// Maximum number of entries in the cache.
+	maxEntries int
+
+	lock sync.Mutex
+
+	// Entries, ordered from the most to the least recently used.
+	entries *list.List
+	index   map[K]*list.Element
+
+	// Returns the current time. Replaced by tests.
+	now func() time.Time
+}
+
+// An entry within the cache.
+type cacheEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// New - returns a new cache with the specified name, holding at most the
+// specified number of entries.
+func New[K comparable, V any](name string, maxEntries int) *Cache[K, V] {
+	return &Cache[K, V]{
+		name:       name,
+		maxEntries: maxEntries,
+		entries:    list.New(),
+		index:      map[K]*list.Element{},
+		now:        time.Now,
+	}
+}
+
+// Get - returns the value cached for the specified key, if one exists and has
+// not expired.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	var value V
+	if c == nil {
+		return value, false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, ok := c.index[key]
+	if !ok {
+		metrics.MetricCacheMisses.WithLabelValues(c.name).Inc()
+		return value, false
+	}
+
+	entry := element.Value.(*cacheEntry[K, V])
+	if !c.now().Before(entry.expiresAt) {
+		c.removeElement(element)
+		metrics.MetricCacheMisses.WithLabelValues(c.name).Inc()
+		return value, false
+	}
+
+	c.entries.MoveToFront(element)
+	metrics.MetricCacheHits.WithLabelValues(c.name).Inc()
+	return entry.value, true
+}
+
+// Add - caches the value for the specified key until the TTL elapses,
+// replacing any value already cached for the key. If the cache is full, the
+// least recently used entry is evicted.
+func (c *Cache[K, V]) Add(key K, value V, ttl time.Duration) {
+	if (c == nil) || (ttl <= 0) {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	expiresAt := c.now().Add(ttl)
+	if element, ok := c.index[key]; ok {
+		entry := element.Value.(*cacheEntry[K, V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.entries.MoveToFront(element)
+		return
+	}
+
+	c.index[key] = c.entries.PushFront(&cacheEntry[K, V]{
+		key:       key,
+		value:     value,
+		expiresAt: expiresAt,
+	})
+	for c.entries.Len() > c.maxEntries {
+		c.removeElement(c.entries.Back())
+		metrics.MetricCacheEvictions.WithLabelValues(c.name).Inc()
+	}
+}
+
+// Remove - removes the value cached for the specified key, if any.
+func (c *Cache[K, V]) Remove(key K) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.index[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+// Len - returns the number of entries in the cache, including entries which
+// have expired but have not yet been removed.
+func (c *Cache[K, V]) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.entries.Len()
+}
+
+// Removes the specified element from the cache. The lock must be held.
+func (c *Cache[K, V]) removeElement(element *list.Element) {
+	c.entries.Remove(element)
+	delete(c.index, element.Value.(*cacheEntry[K, V]).key)
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache_Expiry(t *testing.T) {
+	now := time.Now()
+	c := New[string, int]("test", 10)
+	c.now = func() time.Time { return now }
+
+	c.Add("a", 1, time.Minute)
+	if value, ok := c.Get("a"); !ok || (value != 1) {
+		t.Errorf("Expected the cached value, got %d (found: %v)", value, ok)
+	}
+
+	// Entries are not returned once their TTL elapses.
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected the entry to have expired")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Expected the expired entry to be removed")
+	}
+
+	// Entries added with no TTL are not cached.
+	c.Add("b", 2, 0)
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected the entry to not be cached")
+	}
+}
+
+func TestCache_Eviction(t *testing.T) {
+	c := New[string, int]("test", 2)
+	c.Add("a", 1, time.Minute)
+	c.Add("b", 2, time.Minute)
+
+	// Looking up "a" makes "b" the least recently used entry.
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("Expected the entry to be cached")
+	}
+	c.Add("c", 3, time.Minute)
+
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected the least recently used entry to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("Expected %q to be cached", key)
+		}
+	}
+
+	c.Remove("a")
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected the entry to be removed")
+	}
+}
+
+func TestCache_Nil(t *testing.T) {
+	var c *Cache[string, int]
+	c.Add("a", 1, time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected a nil cache to cache nothing")
+	}
+	c.Remove("a")
+}
@@ -6,16 +6,14 @@
 // Implements the Certificate Manager component within the CA. The certificate
 // manager initializes the certificate template configuration based on the
 // information parsed from the configuration YAML file. It also selects and
-// initializes the key management service (KMS) provider to be used for actual
-// certificate issuance based on the configuration.
+// initializes the certificate store and the key management service (KMS)
+// provider to be used for actual certificate issuance based on the
+// configuration.
 package certmgr
 
 import (
-	"errors"
-
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
 	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
-	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers/aws_kms"
-	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers/local_kms"
 	"github.com/HPInc/krypton-ca/service/common"
 	"github.com/HPInc/krypton-ca/service/config"
 	"go.uber.org/zap"
@@ -25,51 +23,66 @@ var (
 	caLogger *zap.Logger
 )
 
-// Init is used to initialize the certificate manager and select the right KMS
-// provider to use for issuing certificates, depending on the CA's configuration.
+// Init is used to initialize the certificate manager and select the right
+// certificate store and KMS provider to use for issuing certificates, depending
+// on the CA's configuration.
 func Init(logger *zap.Logger,
-	cfgMgr *config.ConfigMgr) (kms_providers.KmsProvider, error) {
+	cfgMgr *config.ConfigMgr) (kms_providers.KmsProvider, certstore.CertStore, error) {
 	caLogger = logger
 
 	// Initialize the certificate template with configuration information
 	// parsed from the configuration file.
 	common.InitTemplateConfiguration(cfgMgr.GetCertificateTemplateConfig())
 
-	// Determine the KMS provider to use, based on input from the
-	// configuration file.
-	switch cfgMgr.GetKmsProvider() {
-	case common.KmsProviderAws:
-		// Use AWS Key Management Service (KMS) as the provider.
-		provider := aws_kms.AwsKmsProvider{}
-		err := provider.Init(caLogger, cfgMgr)
-		if err != nil {
-			caLogger.Error("Failed to initialize certificate authority with AWS KMS provider!",
-				zap.Error(err),
-			)
-			return nil, err
-		}
+	// Initialize the certificate store provider. The certificate store is
+	// shared by the KMS provider and other components of the CA.
+	store, err := certstore.Init(caLogger, cfgMgr.GetCertStoreProvider())
+	if err != nil {
+		caLogger.Error("Failed to initialize certificate store provider!",
+			zap.String("Provider name: ", cfgMgr.GetCertStoreProvider()),
+			zap.Error(err),
+		)
+		return nil, nil, err
+	}
 
-		caLogger.Info("Successfully initialized the certificate authority with AWS KMS provider.")
-		return &provider, nil
+	// Cache signing certificates read from the certificate store, if
+	// configured.
+	if cfgMgr.GetCacheConfig().Enabled {
+		store = certstore.NewCachingStore(store, cfgMgr.GetCacheConfig())
+	}
 
-	case common.KmsProviderLocal:
-		// The local certificate store provider is only recommended for use in
-		// test mode. For production use a proper KMS provider.
-		provider := local_kms.LocalProvider{}
-		err := provider.Init(caLogger, cfgMgr)
-		if err != nil {
-			caLogger.Error("Failed to initialize certificate authority with local KMS provider!",
-				zap.Error(err),
-			)
-			return nil, err
-		}
-		caLogger.Info("Successfully initialized the certificate authority with local KMS provider.")
-		return &provider, nil
+	provider, err := initKmsProvider(cfgMgr, store)
+	if err != nil {
+		store.Shutdown()
+		return nil, nil, err
+	}
+	return provider, store, nil
+}
 
-	default:
+// Select and initialize the KMS provider requested by the configuration.
+func initKmsProvider(cfgMgr *config.ConfigMgr,
+	store certstore.CertStore) (kms_providers.KmsProvider, error) {
+	// Determine the KMS provider to use, based on input from the
+	// configuration file.
+	provider, ok := kms_providers.New(cfgMgr.GetKmsProvider())
+	if !ok {
 		caLogger.Error("Invalid KMS provider requested!",
 			zap.String("Requested provider:", cfgMgr.GetKmsProvider()),
 		)
+		return nil, common.ErrInvalidKmsProvider
 	}
-	return nil, errors.New("unsupported kms provider requested")
+
+	err := provider.Init(caLogger, cfgMgr, store)
+	if err != nil {
+		caLogger.Error("Failed to initialize certificate authority with KMS provider!",
+			zap.String("Provider name:", cfgMgr.GetKmsProvider()),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	caLogger.Info("Successfully initialized the certificate authority with KMS provider.",
+		zap.String("Provider name:", cfgMgr.GetKmsProvider()),
+	)
+	return provider, nil
 }
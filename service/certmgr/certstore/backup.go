@@ -0,0 +1,37 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/certstore
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines optional interfaces implemented by certificate store providers which
+// support backing up their contents. Enumerating the contents of a store is
+// used to export them to an archive, which can be imported into another
+// certificate store. Snapshots are used to back up the underlying database of
+// the store while the CA is running.
+package certstore
+
+import (
+	"io"
+
+	"github.com/HPInc/krypton-ca/service/common"
+)
+
+// Enumerator - implemented by certificate store providers which are able to
+// enumerate their contents.
+type Enumerator interface {
+	// Invoke certFn for each signing certificate in the store and recordFn
+	// for each unexpired record in the store. Enumeration stops at the first
+	// error returned by either function. Where supported by the store, the
+	// contents are enumerated from a consistent view of the store.
+	Enumerate(certFn func(*common.SigningCertificate) error,
+		recordFn func(*common.Record) error) error
+}
+
+// Snapshotter - implemented by certificate store providers which are able to
+// write a consistent snapshot of their underlying database while the store is
+// in use.
+type Snapshotter interface {
+	// Write a snapshot of the database to the specified writer, and return
+	// the number of bytes written.
+	Snapshot(w io.Writer) (int64, error)
+}
@@ -0,0 +1,164 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/certstore
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements a read-through cache of signing certificates in front of a
+// certificate store. Signing certificates are read from the certificate store
+// whenever a certificate is issued, so caching them avoids a round trip to the
+// certificate store for each issuance. Lookups of tenants which do not have a
+// signing certificate are also cached, for a shorter period. Cached entries
+// are invalidated when signing certificates are added or removed using the
+// cache. Entries are only invalidated within the instance of the CA which
+// added or removed the signing certificate - other instances sharing the
+// certificate store continue to use their cached entries until they expire.
+// Checks for existing signing certificates, which decide whether a signing
+// certificate is created, bypass the cache using GetCertificateUncached.
+// Records are not cached.
+package certstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/cache"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+)
+
+// Name of the signing certificate cache, used to label metrics.
+const signingCertificateCacheName = "signing_certificates"
+
+// CachingStore - a certificate store which caches signing certificates read
+// from the wrapped certificate store.
+type CachingStore struct {
+	CertStore
+
+	// Cached signing certificates, keyed by certificate ID. A nil entry
+	// indicates the signing certificate was not found in the store.
+	certs *cache.Cache[string, *common.SigningCertificate]
+
+	// Time for which signing certificates, and lookups of signing
+	// certificates which were not found, are cached.
+	ttl         time.Duration
+	negativeTTL time.Duration
+}
+
+// NewCachingStore - returns a certificate store which caches signing
+// certificates read from the specified certificate store, as configured by the
+// specified cache settings.
+func NewCachingStore(store CertStore, settings *config.Cache) *CachingStore {
+	return &CachingStore{
+		CertStore: store,
+		certs: cache.New[string, *common.SigningCertificate](
+			signingCertificateCacheName, settings.MaxEntries),
+		ttl:         time.Duration(settings.TTLSeconds) * time.Second,
+		negativeTTL: time.Duration(settings.NegativeTTLSeconds) * time.Second,
+	}
+}
+
+// Unwrap - returns the wrapped certificate store.
+func (s *CachingStore) Unwrap() CertStore {
+	return s.CertStore
+}
+
+// GetCertificate - returns the signing certificate for the specified ID from
+// the cache, or from the wrapped certificate store if it is not cached.
+func (s *CachingStore) GetCertificate(ctx context.Context,
+	certID string) (*common.SigningCertificate, error) {
+	if entry, ok := s.certs.Get(certID); ok {
+		if entry == nil {
+			return nil, common.ErrCertStoreNotFound
+		}
+		// Callers may modify the returned entry.
+		cached := *entry
+		return &cached, nil
+	}
+
+	entry, err := s.CertStore.GetCertificate(ctx, certID)
+	if err != nil {
+		if errors.Is(err, common.ErrCertStoreNotFound) {
+			s.certs.Add(certID, nil, s.negativeTTL)
+		}
+		return nil, err
+	}
+
+	cached := *entry
+	s.certs.Add(certID, &cached, s.ttl)
+	return entry, nil
+}
+
+// GetUncachedCertificate - returns the signing certificate for the specified
+// ID from the wrapped certificate store, bypassing the cache. The cached entry
+// is refreshed using the signing certificate read from the store.
+func (s *CachingStore) GetUncachedCertificate(ctx context.Context,
+	certID string) (*common.SigningCertificate, error) {
+	entry, err := GetCertificateUncached(ctx, s.CertStore, certID)
+	if err != nil {
+		if errors.Is(err, common.ErrCertStoreNotFound) {
+			s.certs.Add(certID, nil, s.negativeTTL)
+		} else {
+			s.certs.Remove(certID)
+		}
+		return nil, err
+	}
+
+	cached := *entry
+	s.certs.Add(certID, &cached, s.ttl)
+	return entry, nil
+}
+
+// AddCertificate - adds the signing certificate to the wrapped certificate
+// store, and invalidates the cached entry for it. The signing certificate is
+// always added using the wrapped certificate store, so the check for an
+// existing signing certificate with the same ID is never answered from the
+// cache.
+func (s *CachingStore) AddCertificate(ctx context.Context,
+	entry *common.SigningCertificate) error {
+	// The cached entry is invalidated both before and after the certificate
+	// store is updated, so that entries read concurrently while the store is
+	// being updated are not retained. It is invalidated even if the
+	// certificate store reports an error, since the certificate may have been
+	// added.
+	s.certs.Remove(entry.TenantID)
+	defer s.certs.Remove(entry.TenantID)
+	return s.CertStore.AddCertificate(ctx, entry)
+}
+
+// DeleteCertificate - removes the signing certificate from the wrapped
+// certificate store, and invalidates the cached entry for it.
+func (s *CachingStore) DeleteCertificate(ctx context.Context, certID string) error {
+	s.certs.Remove(certID)
+	defer s.certs.Remove(certID)
+	return s.CertStore.DeleteCertificate(ctx, certID)
+}
+
+// GetCertificateUncached - returns the signing certificate for the specified
+// ID from the specified certificate store, bypassing any cache of signing
+// certificates. Used to check whether a signing certificate exists before
+// creating one, since cached entries may be stale if the signing certificate
+// was added or removed by another instance of the CA.
+func GetCertificateUncached(ctx context.Context, store CertStore,
+	certID string) (*common.SigningCertificate, error) {
+	if cachingStore, ok := store.(interface {
+		GetUncachedCertificate(context.Context, string) (*common.SigningCertificate, error)
+	}); ok {
+		return cachingStore.GetUncachedCertificate(ctx, certID)
+	}
+	return store.GetCertificate(ctx, certID)
+}
+
+// Unwrap - returns the certificate store provider underlying the specified
+// certificate store, if it wraps another certificate store (eg. to cache
+// signing certificates). Optional interfaces such as Enumerator and
+// Snapshotter are implemented by the underlying provider.
+func Unwrap(store CertStore) CertStore {
+	for {
+		wrapper, ok := store.(interface{ Unwrap() CertStore })
+		if !ok {
+			return store
+		}
+		store = wrapper.Unwrap()
+	}
+}
@@ -0,0 +1,162 @@
+package certstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+)
+
+// A certificate store holding signing certificates in memory, which counts
+// the signing certificates read from it.
+type countingStore struct {
+	CertStore
+	certs map[string]common.SigningCertificate
+	reads int
+}
+
+func (s *countingStore) GetCertificate(ctx context.Context,
+	certID string) (*common.SigningCertificate, error) {
+	s.reads++
+	entry, ok := s.certs[certID]
+	if !ok {
+		return nil, common.ErrCertStoreNotFound
+	}
+	return &entry, nil
+}
+
+func (s *countingStore) AddCertificate(ctx context.Context,
+	entry *common.SigningCertificate) error {
+	s.certs[entry.TenantID] = *entry
+	return nil
+}
+
+func (s *countingStore) DeleteCertificate(ctx context.Context, certID string) error {
+	delete(s.certs, certID)
+	return nil
+}
+
+func newTestCachingStore(negativeTTLSeconds int) (*CachingStore, *countingStore) {
+	store := &countingStore{certs: map[string]common.SigningCertificate{}}
+	return NewCachingStore(store, &config.Cache{
+		Enabled:            true,
+		MaxEntries:         10,
+		TTLSeconds:         60,
+		NegativeTTLSeconds: negativeTTLSeconds,
+	}), store
+}
+
+func TestCachingStore_ReadThrough(t *testing.T) {
+	cachingStore, store := newTestCachingStore(60)
+	store.certs["tenant-1"] = common.SigningCertificate{TenantID: "tenant-1",
+		KmsKeyID: "key-1"}
+
+	for i := 0; i < 3; i++ {
+		entry, err := cachingStore.GetCertificate(context.Background(), "tenant-1")
+		if (err != nil) || (entry.KmsKeyID != "key-1") {
+			t.Fatalf("Unexpected signing certificate: %+v (error: %v)", entry, err)
+		}
+		// Modifying the returned entry does not modify the cached entry.
+		entry.KmsKeyID = "modified"
+	}
+	if store.reads != 1 {
+		t.Errorf("Expected 1 read from the store, got %d", store.reads)
+	}
+
+	// Tenants without a signing certificate are also cached.
+	for i := 0; i < 3; i++ {
+		_, err := cachingStore.GetCertificate(context.Background(), "tenant-2")
+		if !errors.Is(err, common.ErrCertStoreNotFound) {
+			t.Fatalf("Expected the certificate to not be found, got %v", err)
+		}
+	}
+	if store.reads != 2 {
+		t.Errorf("Expected 2 reads from the store, got %d", store.reads)
+	}
+}
+
+func TestCachingStore_Invalidation(t *testing.T) {
+	cachingStore, store := newTestCachingStore(60)
+
+	// Adding a signing certificate invalidates the cached lookup.
+	_, err := cachingStore.GetCertificate(context.Background(), "tenant-1")
+	if !errors.Is(err, common.ErrCertStoreNotFound) {
+		t.Fatalf("Expected the certificate to not be found, got %v", err)
+	}
+	err = cachingStore.AddCertificate(context.Background(), &common.SigningCertificate{
+		TenantID: "tenant-1", KmsKeyID: "key-1"})
+	if err != nil {
+		t.Fatalf("Failed to add the certificate: %v", err)
+	}
+	entry, err := cachingStore.GetCertificate(context.Background(), "tenant-1")
+	if (err != nil) || (entry.KmsKeyID != "key-1") {
+		t.Fatalf("Expected the added certificate, got %+v (error: %v)", entry, err)
+	}
+
+	// Deleting a signing certificate invalidates the cached entry.
+	if err = cachingStore.DeleteCertificate(context.Background(), "tenant-1"); err != nil {
+		t.Fatalf("Failed to delete the certificate: %v", err)
+	}
+	_, err = cachingStore.GetCertificate(context.Background(), "tenant-1")
+	if !errors.Is(err, common.ErrCertStoreNotFound) {
+		t.Errorf("Expected the deleted certificate to not be found, got %v", err)
+	}
+	if store.reads != 3 {
+		t.Errorf("Expected 3 reads from the store, got %d", store.reads)
+	}
+
+	if Unwrap(cachingStore) != CertStore(store) {
+		t.Errorf("Expected the wrapped store to be returned")
+	}
+}
+
+func TestCachingStore_NoNegativeCaching(t *testing.T) {
+	cachingStore, store := newTestCachingStore(0)
+	for i := 0; i < 2; i++ {
+		_, _ = cachingStore.GetCertificate(context.Background(), "tenant-1")
+	}
+	if store.reads != 2 {
+		t.Errorf("Expected lookups of missing certificates to not be cached")
+	}
+}
+
+func TestCachingStore_Uncached(t *testing.T) {
+	cachingStore, store := newTestCachingStore(60)
+
+	// A signing certificate added by another instance of the CA is not seen
+	// by cached lookups until the cached entry expires, but is seen by
+	// uncached lookups.
+	_, err := cachingStore.GetCertificate(context.Background(), "tenant-1")
+	if !errors.Is(err, common.ErrCertStoreNotFound) {
+		t.Fatalf("Expected the certificate to not be found, got %v", err)
+	}
+	store.certs["tenant-1"] = common.SigningCertificate{TenantID: "tenant-1",
+		KmsKeyID: "key-1"}
+	_, err = cachingStore.GetCertificate(context.Background(), "tenant-1")
+	if !errors.Is(err, common.ErrCertStoreNotFound) {
+		t.Fatalf("Expected the cached lookup to be used, got %v", err)
+	}
+	entry, err := GetCertificateUncached(context.Background(), cachingStore,
+		"tenant-1")
+	if (err != nil) || (entry.KmsKeyID != "key-1") {
+		t.Fatalf("Expected the added certificate, got %+v (error: %v)", entry, err)
+	}
+
+	// The uncached lookup refreshes the cached entry.
+	entry, err = cachingStore.GetCertificate(context.Background(), "tenant-1")
+	if (err != nil) || (entry.KmsKeyID != "key-1") {
+		t.Fatalf("Expected the added certificate, got %+v (error: %v)", entry, err)
+	}
+	if store.reads != 2 {
+		t.Errorf("Expected 2 reads from the store, got %d", store.reads)
+	}
+
+	// Stores which do not cache signing certificates are read directly.
+	entry, err = GetCertificateUncached(context.Background(), store, "tenant-1")
+	if (err != nil) || (entry.KmsKeyID != "key-1") || (store.reads != 3) {
+		t.Errorf("Expected the certificate to be read from the store, got %+v (error: %v)",
+			entry, err)
+	}
+}
@@ -6,12 +6,17 @@
 // Implements the CertStore interface which is used to plug in various
 // certificate stores. The certificate stores are used to store signing
 // certificates used by the KMS providers configured for the CA service.
+// Certificate stores register themselves with this package, and are selected
+// by name using the configuration.
 package certstore
 
 import (
-	"github.com/HPInc/krypton-ca/service/certmgr/certstore/dynamodb"
-	"github.com/HPInc/krypton-ca/service/certmgr/certstore/localdb"
+	"context"
+	"fmt"
+	"sync"
+
 	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
 	"go.uber.org/zap"
 )
 
@@ -21,7 +26,9 @@ var (
 
 // CertStore - defines an interface that must be implemented by certificate store
 // providers. Certificate store providers store the tenant signing key which is
-// used to sign device certificates.
+// used to sign device certificates. Requests made to the store are bounded by
+// the deadline of the specified context, and are abandoned if the context is
+// cancelled.
 type CertStore interface {
 	// Initialize the provider.
 	Init(*zap.Logger) error
@@ -29,21 +36,84 @@ type CertStore interface {
 	// Shutdown the provider and free up resources.
 	Shutdown()
 
+	// Check that the store is reachable and able to serve requests. Used to
+	// report the readiness of the CA.
+	Ping(ctx context.Context) error
+
 	// Add a certificate to the store. This API can be used to store
 	// the following types of signing certificates in the store:
 	// - CA certificate: used to sign tenant signing certificates
 	// - Tenant signing certificate: used to sign device certificates
 	//                               issued within the tenant.
-	AddCertificate(entry *common.SigningCertificate) error
+	// Existing certificates are never replaced - if a certificate with the
+	// same ID is already stored, common.ErrTenantExists is returned.
+	AddCertificate(ctx context.Context, entry *common.SigningCertificate) error
 
 	// Get the signing certificate for the specified ID from the store.
 	// Possible values of ID:
 	//  - alias/CAKey: returns the CA certificate.
 	//  - tenantID: returns the signing certificate for the tenant.
-	GetCertificate(certID string) (*common.SigningCertificate, error)
+	GetCertificate(ctx context.Context,
+		certID string) (*common.SigningCertificate, error)
 
 	// Remove the signing certificate for the specified tenant ID from the store.
-	DeleteCertificate(certID string) error
+	DeleteCertificate(ctx context.Context, certID string) error
+
+	// Add a record to the store. If a record with the same kind, scope and
+	// ID already exists, ErrRecordExists is returned.
+	AddRecord(ctx context.Context, record *common.Record) error
+
+	// Add or replace a record in the store.
+	PutRecord(ctx context.Context, record *common.Record) error
+
+	// Get the record with the specified kind, scope and ID from the store.
+	// Expired records are reported as not found.
+	GetRecord(ctx context.Context, kind string, scope string,
+		id string) (*common.Record, error)
+
+	// Remove the record with the specified kind, scope and ID from the store.
+	DeleteRecord(ctx context.Context, kind string, scope string,
+		id string) error
+
+	// List all unexpired records of the specified kind within the scope.
+	ListRecords(ctx context.Context, kind string,
+		scope string) ([]*common.Record, error)
+
+	// Count the unexpired records of the specified kind within the scope.
+	CountRecords(ctx context.Context, kind string, scope string) (int, error)
+}
+
+// Factory - returns a new, uninitialized instance of a certificate store
+// provider. The CA initializes the instance by invoking its Init method.
+type Factory func() CertStore
+
+var (
+	registryLock sync.RWMutex
+	factories    = map[string]Factory{}
+)
+
+// Register - register the certificate store provider with the specified
+// name, along with the factory used to create instances of the provider and
+// the schema of the provider's configuration settings. The name is used to
+// select the provider using the cert_store configuration setting. Certificate
+// store providers register themselves from an init function, so that a
+// provider is compiled into the CA by importing the package implementing it.
+// Register panics if it is invoked twice with the same name.
+func Register(name string, factory Factory, schema config.ProviderSchema) {
+	if factory == nil {
+		panic(fmt.Sprintf("nil factory registered for certificate store %q",
+			name))
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("duplicate registration of certificate store %q",
+			name))
+	}
+	config.RegisterCertStore(name, schema)
+	factories[name] = factory
 }
 
 // Initialize the certificate store interface and determine which certificate
@@ -51,39 +121,27 @@ type CertStore interface {
 func Init(logger *zap.Logger, certStoreProviderName string) (CertStore, error) {
 	caLogger = logger
 
-	switch certStoreProviderName {
-	case common.CertStoreDynamoDb:
-		// Use Dynamo DB as the certificate store provider.
-		provider := dynamodb.DynamoDbProvider{}
-		err := provider.Init(caLogger)
-		if err != nil {
-			caLogger.Error("Failed to initialize the Dynamo DB database certificate store!",
-				zap.Error(err),
-			)
-			return nil, err
-		}
-		caLogger.Info("Successfully initialized the Dynamo DB certificate store.")
-		return &provider, nil
-
-	case common.CertStoreLocalDb:
-		// Use a local Bolt DB instance as the certificate store provider. Only
-		// recommended for use in test mode. For production use a proper
-		// certificate store provider.
-		provider := localdb.LocalDbProvider{}
-		err := provider.Init(caLogger)
-		if err != nil {
-			caLogger.Error("Failed to initialize the local database certificate store!",
-				zap.Error(err),
-			)
-			return nil, err
-		}
-		caLogger.Info("Successfully initialized the local database certificate store.")
-		return &provider, nil
-
-	default:
+	registryLock.RLock()
+	factory, ok := factories[certStoreProviderName]
+	registryLock.RUnlock()
+	if !ok {
 		caLogger.Error("Invalid certificate store provider requested!",
 			zap.String("Requested provider:", certStoreProviderName),
 		)
+		return nil, common.ErrInvalidCertStore
+	}
+
+	provider := factory()
+	err := provider.Init(caLogger)
+	if err != nil {
+		caLogger.Error("Failed to initialize the certificate store!",
+			zap.String("Provider name:", certStoreProviderName),
+			zap.Error(err),
+		)
+		return nil, err
 	}
-	return nil, common.ErrInvalidCertStore
+	caLogger.Info("Successfully initialized the certificate store.",
+		zap.String("Provider name:", certStoreProviderName),
+	)
+	return provider, nil
 }
@@ -9,8 +9,11 @@ package dynamodb
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/HPInc/krypton-ca/service/certmgr/awserrors"
 	"github.com/HPInc/krypton-ca/service/common"
 	"github.com/HPInc/krypton-ca/service/metrics"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -20,9 +23,61 @@ import (
 	"go.uber.org/zap"
 )
 
+// DynamoEntry - represents a signing certificate stored in the signing
+// certificates table. Signing certificates are stored using native attributes
+// tagged with the schema version. Entries written by earlier versions of the
+// CA have no schema version, and store a GOB encoded signing certificate in
+// the cert attribute instead. The cert attribute continues to be written
+// alongside the native attributes, so that earlier versions of the CA are
+// able to read the signing certificates if the CA is rolled back.
 type DynamoEntry struct {
-	CertID           string `dynamodbav:"cert_id"`
-	SigningCertBytes []byte `dynamodbav:"cert"`
+	CertID        string `dynamodbav:"cert_id"`
+	SchemaVersion int    `dynamodbav:"schema_version,omitempty"`
+	KmsKeyID      string `dynamodbav:"kms_key_id,omitempty"`
+	Certificate   []byte `dynamodbav:"certificate,omitempty"`
+
+	// Legacy GOB encoded signing certificate, read by earlier versions of
+	// the CA.
+	SigningCertBytes []byte `dynamodbav:"cert,omitempty"`
+}
+
+// newDynamoEntry - returns the Dynamo DB entry used to store the specified
+// signing certificate.
+func newDynamoEntry(entry *common.SigningCertificate) (DynamoEntry, error) {
+	signingCertBytes, err := common.EncodeLegacySigningCertificate(entry)
+	if err != nil {
+		return DynamoEntry{}, err
+	}
+
+	return DynamoEntry{
+		CertID:           entry.TenantID,
+		SchemaVersion:    common.SigningCertificateSchemaVersion,
+		KmsKeyID:         entry.KmsKeyID,
+		Certificate:      entry.Certificate,
+		SigningCertBytes: signingCertBytes,
+	}, nil
+}
+
+// isLegacy - checks whether the entry was written using the legacy encoding.
+func (entry DynamoEntry) isLegacy() bool {
+	return entry.SchemaVersion <= 0
+}
+
+// toSigningCertificate - returns the signing certificate stored in the entry.
+func (entry DynamoEntry) toSigningCertificate() (*common.SigningCertificate, error) {
+	if entry.isLegacy() {
+		return common.DecodeSigningCertificate(entry.SigningCertBytes)
+	}
+	if entry.SchemaVersion > common.SigningCertificateSchemaVersion {
+		return nil, fmt.Errorf("unsupported signing certificate schema version %d",
+			entry.SchemaVersion)
+	}
+
+	return &common.SigningCertificate{
+		TenantID:    entry.CertID,
+		KmsKeyID:    entry.KmsKeyID,
+		Certificate: entry.Certificate,
+	}, nil
 }
 
 func (entry DynamoEntry) GetKey() (map[string]types.AttributeValue, error) {
@@ -38,21 +93,19 @@ func (entry DynamoEntry) GetKey() (map[string]types.AttributeValue, error) {
 }
 
 // AddCertificate - Adds the specified tenant signing certificate to the Dynamo
-// DB certificate store.
-func (p *DynamoDbProvider) AddCertificate(entry *common.SigningCertificate) error {
-	// Encode the tenant signing certificate entry.
-	encodedEntry, err := common.EncodeSigningCertificate(entry)
+// DB certificate store, if a signing certificate with the same ID doesn't
+// already exist.
+func (p *DynamoDbProvider) AddCertificate(ctx context.Context,
+	entry *common.SigningCertificate) error {
+	dynamoEntry, err := newDynamoEntry(entry)
 	if err != nil {
-		caLogger.Error("Failed to encode the signing certificate entry!",
+		caLogger.Error("Failed to encode the signing certificate!",
+			zap.String("Tenant ID:", entry.TenantID),
 			zap.Error(err),
 		)
 		return err
 	}
-
-	item, err := attributevalue.MarshalMap(DynamoEntry{
-		CertID:           entry.TenantID,
-		SigningCertBytes: encodedEntry,
-	})
+	item, err := attributevalue.MarshalMap(dynamoEntry)
 	if err != nil {
 		caLogger.Error("Failed to marshal dynamo DB entry!",
 			zap.Error(err),
@@ -62,22 +115,28 @@ func (p *DynamoDbProvider) AddCertificate(entry *common.SigningCertificate) erro
 
 	// Add the tenant signing certificate to the Dynamo DB table.
 	start := time.Now()
-	ctx, cancelFunc := context.WithTimeout(p.ctx, dynamoDbCallTimeout)
-	defer cancelFunc()
-
 	_, err = p.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(certsTableName),
-		Item:      item,
+		TableName:           aws.String(certsTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(cert_id)"),
 	})
 	metrics.ReportLatencyMetric(metrics.MetricAwsDynamoDbRequestLatency, start,
 		awsDynamoDbOpPutItem)
 	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			caLogger.Error("A signing certificate already exists for the tenant!",
+				zap.String("Tenant ID: ", entry.TenantID),
+			)
+			return common.ErrTenantExists
+		}
+
 		caLogger.Error("Error while adding the signing key entry to the database!",
 			zap.String("Tenant ID: ", entry.TenantID),
 			zap.Error(err),
 		)
 		metrics.MetricAwsDynamoDbNonAwsErrors.Inc()
-		return err
+		return awserrors.WrapError("failed to add the signing certificate to the store", err)
 	}
 
 	return nil
@@ -0,0 +1,93 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/certstore/dynamodb
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the APIs used to back up the Dynamo DB certificate store. The
+// contents of the store are enumerated using strongly consistent scans of the
+// signing certificates and records tables. Dynamo DB does not provide a
+// consistent view across scans, so entries modified while the store is being
+// enumerated may or may not be included. Point-in-time recovery should be
+// used to back up the tables themselves.
+package dynamodb
+
+import (
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/awserrors"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+// Enumerate - Invokes the specified functions for each signing certificate and
+// each unexpired record in the Dynamo DB certificate store.
+func (p *DynamoDbProvider) Enumerate(certFn func(*common.SigningCertificate) error,
+	recordFn func(*common.Record) error) error {
+	err := p.scanTable(certsTableName, func(item map[string]types.AttributeValue) error {
+		var entry DynamoEntry
+		err := attributevalue.UnmarshalMap(item, &entry)
+		if err != nil {
+			return err
+		}
+		cert, err := entry.toSigningCertificate()
+		if err != nil {
+			return err
+		}
+		return certFn(cert)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Dynamo DB purges expired records lazily, so expired records are
+	// skipped.
+	now := time.Now()
+	return p.scanTable(recordsTableName, func(item map[string]types.AttributeValue) error {
+		var entry DynamoRecord
+		err := attributevalue.UnmarshalMap(item, &entry)
+		if err != nil {
+			return err
+		}
+		record := entry.toRecord()
+		if record.IsExpired(now) {
+			return nil
+		}
+		return recordFn(record)
+	})
+}
+
+// scanTable - Invokes the specified function for each item in the table.
+func (p *DynamoDbProvider) scanTable(tableName string,
+	itemFn func(map[string]types.AttributeValue) error) error {
+	paginator := dynamodb.NewScanPaginator(p.client, &dynamodb.ScanInput{
+		TableName:      aws.String(tableName),
+		ConsistentRead: aws.Bool(true),
+	})
+
+	for paginator.HasMorePages() {
+		start := time.Now()
+		page, err := paginator.NextPage(p.ctx)
+		metrics.ReportLatencyMetric(metrics.MetricAwsDynamoDbRequestLatency, start,
+			awsDynamoDbOpScan)
+		if err != nil {
+			caLogger.Error("Failed to scan the table!",
+				zap.String("Table name: ", tableName),
+				zap.Error(err),
+			)
+			metrics.MetricAwsDynamoDbOtherAwsErrors.Inc()
+			return awserrors.WrapError("failed to scan the certificate store", err)
+		}
+
+		for _, item := range page.Items {
+			if err = itemFn(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
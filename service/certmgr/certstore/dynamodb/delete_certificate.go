@@ -11,6 +11,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/HPInc/krypton-ca/service/certmgr/awserrors"
 	"github.com/HPInc/krypton-ca/service/metrics"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -19,12 +20,9 @@ import (
 
 // DeleteCertificate - Removes the signing certificate for the specified tenant
 // from the Dynamo DB certificate store.
-func (p *DynamoDbProvider) DeleteCertificate(certID string) error {
+func (p *DynamoDbProvider) DeleteCertificate(ctx context.Context, certID string) error {
 
 	start := time.Now()
-	ctx, cancelFunc := context.WithTimeout(p.ctx, dynamoDbCallTimeout)
-	defer cancelFunc()
-
 	entry := DynamoEntry{CertID: certID}
 	key, err := entry.GetKey()
 	if err != nil {
@@ -46,7 +44,7 @@ func (p *DynamoDbProvider) DeleteCertificate(certID string) error {
 			zap.Error(err),
 		)
 		metrics.MetricAwsDynamoDbNonAwsErrors.Inc()
-		return err
+		return awserrors.WrapError("failed to delete the signing certificate from the store", err)
 	}
 
 	return nil
@@ -0,0 +1,244 @@
+package dynamodb
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"testing"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/resilience"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+)
+
+var testSigningCertificate = common.SigningCertificate{
+	TenantID:    "tenant-1",
+	KmsKeyID:    "key-1",
+	Certificate: []byte("certificate"),
+}
+
+// Round trips the entry through its Dynamo DB item representation, and returns
+// the signing certificate stored in the entry.
+func roundTripEntry(t *testing.T, entry DynamoEntry) *common.SigningCertificate {
+	item, err := attributevalue.MarshalMap(entry)
+	if err != nil {
+		t.Fatalf("Failed to marshal the entry: %v", err)
+	}
+
+	var stored DynamoEntry
+	if err = attributevalue.UnmarshalMap(item, &stored); err != nil {
+		t.Fatalf("Failed to unmarshal the entry: %v", err)
+	}
+	cert, err := stored.toSigningCertificate()
+	if err != nil {
+		t.Fatalf("Failed to decode the signing certificate: %v", err)
+	}
+	return cert
+}
+
+// Returns the Dynamo DB entry used to store the test signing certificate.
+func newTestDynamoEntry(t *testing.T) DynamoEntry {
+	entry, err := newDynamoEntry(&testSigningCertificate)
+	if err != nil {
+		t.Fatalf("Failed to create the entry: %v", err)
+	}
+	return entry
+}
+
+func TestDynamoEntry_Versioned(t *testing.T) {
+	entry := newTestDynamoEntry(t)
+	if entry.isLegacy() {
+		t.Errorf("Expected the entry to use native attributes: %+v", entry)
+	}
+
+	// The legacy encoding is retained for earlier versions of the CA.
+	legacy, err := common.DecodeSigningCertificate(entry.SigningCertBytes)
+	if (err != nil) || (legacy.KmsKeyID != testSigningCertificate.KmsKeyID) ||
+		!bytes.Equal(legacy.Certificate, testSigningCertificate.Certificate) {
+		t.Errorf("Unexpected legacy signing certificate: %+v, %v", legacy, err)
+	}
+
+	cert := roundTripEntry(t, entry)
+	if (cert.TenantID != testSigningCertificate.TenantID) ||
+		(cert.KmsKeyID != testSigningCertificate.KmsKeyID) ||
+		!bytes.Equal(cert.Certificate, testSigningCertificate.Certificate) {
+		t.Errorf("Unexpected signing certificate returned: %+v", cert)
+	}
+}
+
+func TestDynamoEntry_Legacy(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	if err := gob.NewEncoder(buffer).Encode(&testSigningCertificate); err != nil {
+		t.Fatalf("Failed to GOB encode the signing certificate: %v", err)
+	}
+	entry := DynamoEntry{
+		CertID:           testSigningCertificate.TenantID,
+		SigningCertBytes: buffer.Bytes(),
+	}
+	if !entry.isLegacy() {
+		t.Errorf("Expected the entry to be reported as legacy")
+	}
+
+	cert := roundTripEntry(t, entry)
+	if (cert.KmsKeyID != testSigningCertificate.KmsKeyID) ||
+		!bytes.Equal(cert.Certificate, testSigningCertificate.Certificate) {
+		t.Errorf("Unexpected signing certificate returned: %+v", cert)
+	}
+}
+
+func TestDynamoEntry_UnsupportedVersion(t *testing.T) {
+	entry := newTestDynamoEntry(t)
+	entry.SchemaVersion = common.SigningCertificateSchemaVersion + 1
+	if _, err := entry.toSigningCertificate(); err == nil {
+		t.Errorf("Expected a newer schema version to be rejected")
+	}
+}
+
+// A fake Dynamo DB client which throttles the specified number of calls to
+// get an item, before returning the item.
+type throttlingDynamoDbClient struct {
+	DynamoDbClient
+	item      map[string]types.AttributeValue
+	throttled int
+	calls     int
+}
+
+func (c *throttlingDynamoDbClient) GetItem(context.Context, *dynamodb.GetItemInput,
+	...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	c.calls++
+	if c.calls <= c.throttled {
+		return nil, &smithy.GenericAPIError{
+			Code: "ProvisionedThroughputExceededException"}
+	}
+	return &dynamodb.GetItemOutput{Item: c.item}, nil
+}
+
+func TestGetCertificate_Throttling(t *testing.T) {
+	ctx := context.Background()
+	caLogger = zap.NewNop()
+	item, err := attributevalue.MarshalMap(newTestDynamoEntry(t))
+	if err != nil {
+		t.Fatalf("Failed to marshal the entry: %v", err)
+	}
+	client := &throttlingDynamoDbClient{item: item,
+		throttled: awsDynamoDbMaxAttempts - 1}
+	p := &DynamoDbProvider{client: newResilientDynamoDbClient(client)}
+
+	// Throttled calls are retried.
+	cert, err := p.GetCertificate(ctx, testSigningCertificate.TenantID)
+	if (err != nil) || (cert.KmsKeyID != testSigningCertificate.KmsKeyID) {
+		t.Fatalf("Expected the throttled call to be retried, got %v", err)
+	}
+
+	// Calls fail once retries are exhausted, and fail fast once the circuit
+	// breaker opens.
+	client.calls = 0
+	client.throttled = awsDynamoDbMaxAttempts * awsDynamoDbCircuitBreakerThreshold
+	for i := 0; i < awsDynamoDbCircuitBreakerThreshold; i++ {
+		_, err = p.GetCertificate(ctx, testSigningCertificate.TenantID)
+		if !caerrors.Is(err, caerrors.DependencyUnavailable) {
+			t.Fatalf("Expected the call to be throttled, got %v", err)
+		}
+	}
+	_, err = p.GetCertificate(ctx, testSigningCertificate.TenantID)
+	if !errors.Is(err, resilience.ErrCircuitOpen) ||
+		(client.calls != client.throttled) {
+		t.Errorf("Expected the call to be rejected, got %v after %d calls",
+			err, client.calls)
+	}
+}
+
+// A Dynamo DB client which records the tables created.
+type tableCreatingDynamoDbClient struct {
+	DynamoDbClient
+	created    *dynamodb.CreateTableInput
+	ttlEnabled bool
+}
+
+func (c *tableCreatingDynamoDbClient) CreateTable(_ context.Context,
+	params *dynamodb.CreateTableInput,
+	_ ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	c.created = params
+	return &dynamodb.CreateTableOutput{}, nil
+}
+
+func (c *tableCreatingDynamoDbClient) DescribeTable(_ context.Context,
+	params *dynamodb.DescribeTableInput,
+	_ ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	if c.created == nil {
+		return nil, &types.ResourceNotFoundException{}
+	}
+	return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{
+		TableName:   params.TableName,
+		TableStatus: types.TableStatusActive,
+	}}, nil
+}
+
+func (c *tableCreatingDynamoDbClient) UpdateTimeToLive(_ context.Context,
+	params *dynamodb.UpdateTimeToLiveInput,
+	_ ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	c.ttlEnabled = *params.TimeToLiveSpecification.Enabled &&
+		(*params.TimeToLiveSpecification.AttributeName == "expires_at")
+	return &dynamodb.UpdateTimeToLiveOutput{}, nil
+}
+
+func TestCreateRecordsTable(t *testing.T) {
+	caLogger = zap.NewNop()
+	client := &tableCreatingDynamoDbClient{}
+	p := &DynamoDbProvider{client: client, ctx: context.Background()}
+
+	if err := p.checkTableExists(recordsTableName); err == nil {
+		t.Fatalf("Expected the records table to not exist")
+	}
+	if err := p.createRecordsTable(); err != nil {
+		t.Fatalf("Failed to create the records table: %v", err)
+	}
+	if (client.created == nil) ||
+		(*client.created.TableName != recordsTableName) ||
+		(*client.created.KeySchema[0].AttributeName != "record_key") ||
+		(*client.created.KeySchema[1].AttributeName != "record_id") {
+		t.Errorf("Unexpected definition of the records table: %+v",
+			client.created)
+	}
+	if !client.ttlEnabled {
+		t.Errorf("Expected TTL to be enabled on the records table")
+	}
+	if err := p.checkTableExists(recordsTableName); err != nil {
+		t.Errorf("Expected the records table to exist, got %v", err)
+	}
+}
+
+// A Dynamo DB client whose conditional writes fail.
+type conflictingDynamoDbClient struct {
+	DynamoDbClient
+	input *dynamodb.PutItemInput
+}
+
+func (c *conflictingDynamoDbClient) PutItem(_ context.Context,
+	params *dynamodb.PutItemInput,
+	_ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	c.input = params
+	return nil, &types.ConditionalCheckFailedException{}
+}
+
+func TestAddCertificate_Exists(t *testing.T) {
+	caLogger = zap.NewNop()
+	client := &conflictingDynamoDbClient{}
+	p := &DynamoDbProvider{client: client}
+
+	// Signing certificates are only added if they don't already exist.
+	err := p.AddCertificate(context.Background(), &testSigningCertificate)
+	if !errors.Is(err, common.ErrTenantExists) {
+		t.Errorf("Expected the signing certificate to exist, got %v", err)
+	}
+	if (client.input == nil) || (client.input.ConditionExpression == nil) ||
+		(*client.input.ConditionExpression != "attribute_not_exists(cert_id)") {
+		t.Errorf("Expected the signing certificate to be added conditionally")
+	}
+}
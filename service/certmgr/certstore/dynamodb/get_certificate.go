@@ -11,6 +11,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/HPInc/krypton-ca/service/certmgr/awserrors"
 	"github.com/HPInc/krypton-ca/service/common"
 	"github.com/HPInc/krypton-ca/service/metrics"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -21,13 +22,10 @@ import (
 
 // GetCertificate - Returns the signing certificate for the specified tenant ID
 // from the Dynamo DB certificate store.
-func (p *DynamoDbProvider) GetCertificate(
+func (p *DynamoDbProvider) GetCertificate(ctx context.Context,
 	certID string) (*common.SigningCertificate, error) {
 
 	start := time.Now()
-	ctx, cancelFunc := context.WithTimeout(p.ctx, dynamoDbCallTimeout)
-	defer cancelFunc()
-
 	item := DynamoEntry{CertID: certID}
 	key, err := item.GetKey()
 	if err != nil {
@@ -51,7 +49,7 @@ func (p *DynamoDbProvider) GetCertificate(
 			zap.Error(err),
 		)
 		metrics.MetricAwsDynamoDbOtherAwsErrors.Inc()
-		return nil, err
+		return nil, awserrors.WrapError("failed to get the signing certificate from the store", err)
 	}
 
 	if result.Item == nil {
@@ -72,7 +70,7 @@ func (p *DynamoDbProvider) GetCertificate(
 		return nil, err
 	}
 
-	entry, err := common.DecodeSigningCertificate(item.SigningCertBytes)
+	entry, err := item.toSigningCertificate()
 	if err != nil {
 		caLogger.Error("Failed to decode the signing certificate entry!",
 			zap.String("Certificate ID: ", certID),
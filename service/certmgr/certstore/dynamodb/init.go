@@ -9,8 +9,16 @@ package dynamodb
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"time"
 
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/awserrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/common"
+	cacfg "github.com/HPInc/krypton-ca/service/config"
+	"github.com/HPInc/krypton-ca/service/metrics"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -26,24 +34,52 @@ var (
 // signing certificates.
 var certsTableName = "SigningCertificates"
 
+// Name of the table in the Dynamo DB instance which is used to store records
+// such as issued devices and tenant quotas.
+var recordsTableName = "Records"
+
 const (
-	// Timeout for calls to Dynamo DB.
+	// Timeout for each attempt of a call to Dynamo DB. Calls made for a
+	// request are also bounded by the deadline of the request.
 	dynamoDbCallTimeout = (time.Second * 10)
 
+	// Maximum time to wait for the records table to become active, if it is
+	// created by the certificate store.
+	recordsTableCreationTimeout = (time.Minute * 2)
+
 	// Dynamo DB operation names.
-	awsDynamoDbOpGetItem    = "GetItem"
-	awsDynamoDbOpPutItem    = "PutItem"
-	awsDynamoDbOpDeleteItem = "DeleteItem"
+	awsDynamoDbOpGetItem          = "GetItem"
+	awsDynamoDbOpPutItem          = "PutItem"
+	awsDynamoDbOpDeleteItem       = "DeleteItem"
+	awsDynamoDbOpQuery            = "Query"
+	awsDynamoDbOpScan             = "Scan"
+	awsDynamoDbOpDescribeTable    = "DescribeTable"
+	awsDynamoDbOpCreateTable      = "CreateTable"
+	awsDynamoDbOpUpdateTimeToLive = "UpdateTimeToLive"
 )
 
+// Register the Dynamo DB certificate store.
+func init() {
+	certstore.Register(common.CertStoreDynamoDb, func() certstore.CertStore {
+		return &DynamoDbProvider{}
+	}, cacfg.ProviderSchema{})
+}
+
 // Implements a signing certificate store provider backed by a Dynamo DB
 // instance.
 type DynamoDbProvider struct {
-	// Instance of the Dynamo DB client.
-	client *dynamodb.Client
+	// Instance of the Dynamo DB client. Calls made using the client are
+	// retried if they fail with a transient error, and fail fast while Dynamo
+	// DB is unavailable.
+	client DynamoDbClient
 
-	// Context used for calls to Dynamo DB.
-	ctx context.Context
+	// Context used for calls to Dynamo DB. The context is cancelled when
+	// the certificate store is shut down.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Tracks the background migration of legacy signing certificates.
+	migration sync.WaitGroup
 }
 
 // Init - initialize the connection to the Dynamo DB database instance used to
@@ -52,7 +88,7 @@ func (p *DynamoDbProvider) Init(logger *zap.Logger) error {
 	caLogger = logger
 
 	// Initialize the context used for calls to Dynamo DB.
-	p.ctx = context.Background()
+	p.ctx, p.cancel = context.WithCancel(context.Background())
 
 	// Load the default AWS configuration and initialize a client to the
 	// AWS KMS service.
@@ -61,27 +97,61 @@ func (p *DynamoDbProvider) Init(logger *zap.Logger) error {
 		caLogger.Error("Failed to load the default AWS configuration!",
 			zap.Error(err),
 		)
+		p.cancel()
 		return err
 	}
 
-	// Create a new instance of the Dynamo DB client.
-	p.client = dynamodb.NewFromConfig(awsConfig)
+	// Create a new instance of the Dynamo DB client. Calls are retried by the
+	// certificate store, so retries by the AWS SDK are disabled.
+	p.client = newResilientDynamoDbClient(dynamodb.NewFromConfig(awsConfig,
+		func(o *dynamodb.Options) {
+			o.Retryer = aws.NopRetryer{}
+		}))
 
 	// Check if the table to store signing certificates exists.
-	ctx, cancelFunc := context.WithTimeout(p.ctx, dynamoDbCallTimeout)
-	defer cancelFunc()
+	err = p.checkTableExists(certsTableName)
+	if err != nil {
+		p.cancel()
+		return err
+	}
+
+	// Check if the table to store records exists. The records table was
+	// introduced after the signing certificates table, so it is created if
+	// it does not exist yet.
+	err = p.checkTableExists(recordsTableName)
+	if err != nil {
+		var notFoundEx *types.ResourceNotFoundException
+		if errors.As(err, &notFoundEx) {
+			err = p.createRecordsTable()
+		}
+		if err != nil {
+			p.cancel()
+			return err
+		}
+	}
+
+	// Rewrite signing certificates stored using the legacy encoding.
+	p.migration.Add(1)
+	go p.migrateSigningCertificates()
+
+	caLogger.Info("Successfully initialized the Dynamo DB certificate database!")
+	return nil
+}
 
-	result, err := p.client.DescribeTable(ctx,
+// Check if the specified table exists in the Dynamo DB database instance.
+func (p *DynamoDbProvider) checkTableExists(tableName string) error {
+	result, err := p.client.DescribeTable(p.ctx,
 		&dynamodb.DescribeTableInput{
-			TableName: aws.String(certsTableName),
+			TableName: aws.String(tableName),
 		})
 	if err != nil {
 		var notFoundEx *types.ResourceNotFoundException
 		if errors.As(err, &notFoundEx) {
 			caLogger.Error("Table does not exist!",
-				zap.String("Table name", certsTableName))
+				zap.String("Table name", tableName))
 		} else {
-			caLogger.Error("Error while checking if the signing key table exists!",
+			caLogger.Error("Error while checking if the table exists!",
+				zap.String("Table name", tableName),
 				zap.Error(err),
 			)
 
@@ -89,16 +159,126 @@ func (p *DynamoDbProvider) Init(logger *zap.Logger) error {
 		return err
 	}
 
-	caLogger.Info("Successfully initialized the Dynamo DB certificate database!",
+	caLogger.Info("Found the Dynamo DB table.",
 		zap.String("Table name: ", aws.ToString(result.Table.TableName)),
 		zap.String("Table status: ", string(result.Table.TableStatus)),
 	)
 	return nil
 }
 
+// Create the table used to store records, and wait for it to become active.
+// Records are partitioned by their kind and scope, and sorted by their ID. The
+// expiry time of each record is used as the TTL attribute of the table, so
+// expired records are purged by Dynamo DB.
+func (p *DynamoDbProvider) createRecordsTable() error {
+	ctx, cancelFunc := context.WithTimeout(p.ctx, recordsTableCreationTimeout)
+	defer cancelFunc()
+
+	caLogger.Info("Creating the Dynamo DB table.",
+		zap.String("Table name: ", recordsTableName),
+	)
+	_, err := p.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(recordsTableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("record_key"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("record_id"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("record_key"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("record_id"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		// The table may have been created concurrently by another instance
+		// of the CA.
+		var inUseEx *types.ResourceInUseException
+		if !errors.As(err, &inUseEx) {
+			caLogger.Error("Failed to create the Dynamo DB table!",
+				zap.String("Table name", recordsTableName),
+				zap.Error(err),
+			)
+			return err
+		}
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(p.client,
+		func(o *dynamodb.TableExistsWaiterOptions) {
+			o.MinDelay = time.Second
+			o.MaxDelay = (time.Second * 5)
+		})
+	err = waiter.Wait(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(recordsTableName),
+	}, recordsTableCreationTimeout)
+	if err != nil {
+		caLogger.Error("Dynamo DB table did not become active!",
+			zap.String("Table name", recordsTableName),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	_, err = p.client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(recordsTableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String("expires_at"),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		// Expired records are treated as absent when they are read, so the
+		// table can be used even if expired records are not purged.
+		caLogger.Error("Failed to enable TTL on the Dynamo DB table!",
+			zap.String("Table name", recordsTableName),
+			zap.Error(err),
+		)
+	}
+
+	caLogger.Info("Created the Dynamo DB table.",
+		zap.String("Table name: ", recordsTableName),
+	)
+	return nil
+}
+
+// Ping - check that the tables used to store signing certificates and records
+// are available.
+func (p *DynamoDbProvider) Ping(ctx context.Context) error {
+	for _, tableName := range []string{certsTableName, recordsTableName} {
+		start := time.Now()
+		result, err := p.client.DescribeTable(ctx,
+			&dynamodb.DescribeTableInput{
+				TableName: aws.String(tableName),
+			})
+		metrics.ReportLatencyMetric(metrics.MetricAwsDynamoDbRequestLatency,
+			start, awsDynamoDbOpDescribeTable)
+		if err != nil {
+			return awserrors.WrapError("failed to describe the table", err)
+		}
+		if result.Table.TableStatus != types.TableStatusActive {
+			return caerrors.New(caerrors.DependencyUnavailable,
+				fmt.Sprintf("table %s is %s", tableName,
+					result.Table.TableStatus))
+		}
+	}
+	return nil
+}
+
 // Shutdown - shutdown the connection to the Dynamo DB database used to store
 // signing certificates.
 func (p *DynamoDbProvider) Shutdown() {
-	p.ctx.Done()
+	p.cancel()
+	p.migration.Wait()
 	caLogger.Info("Successfully shut down the Dynamo DB certificate database!")
 }
@@ -0,0 +1,131 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/certstore/dynamodb
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Rewrites signing certificates stored in the Dynamo DB certificate store by
+// earlier versions of the CA using the legacy GOB encoding. The migration is
+// performed online in the background, since legacy entries continue to be
+// readable while it is in progress. Migrated entries retain the legacy GOB
+// encoding, so earlier versions of the CA are able to read them if the CA is
+// rolled back.
+package dynamodb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+// Matches signing certificate entries written using the legacy encoding.
+const legacyEntryExpression = "attribute_exists(cert) AND attribute_not_exists(schema_version)"
+
+// migrateSigningCertificates - scans the signing certificates table and
+// rewrites entries stored using the legacy encoding. Failures are logged, and
+// the affected entries are migrated the next time the CA is started.
+func (p *DynamoDbProvider) migrateSigningCertificates() {
+	defer p.migration.Done()
+
+	paginator := dynamodb.NewScanPaginator(p.client, &dynamodb.ScanInput{
+		TableName:        aws.String(certsTableName),
+		FilterExpression: aws.String(legacyEntryExpression),
+	})
+
+	migrated := 0
+	for paginator.HasMorePages() {
+		start := time.Now()
+		page, err := paginator.NextPage(p.ctx)
+		metrics.ReportLatencyMetric(metrics.MetricAwsDynamoDbRequestLatency, start,
+			awsDynamoDbOpScan)
+		if err != nil {
+			if p.ctx.Err() == nil {
+				caLogger.Error("Failed to scan for signing certificates to migrate!",
+					zap.Error(err),
+				)
+				metrics.MetricAwsDynamoDbOtherAwsErrors.Inc()
+			}
+			return
+		}
+
+		for _, item := range page.Items {
+			if p.migrateSigningCertificate(item) == nil {
+				migrated++
+			}
+		}
+	}
+
+	if migrated != 0 {
+		caLogger.Info("Migrated signing certificates to the versioned encoding.",
+			zap.Int("Signing certificates:", migrated),
+		)
+	}
+}
+
+// migrateSigningCertificate - rewrites the specified signing certificate entry
+// using native attributes. The entry is only replaced if it has not been
+// rewritten or removed since it was read.
+func (p *DynamoDbProvider) migrateSigningCertificate(
+	item map[string]types.AttributeValue) error {
+	var legacy DynamoEntry
+	err := attributevalue.UnmarshalMap(item, &legacy)
+	if err != nil {
+		caLogger.Error("Failed to unmarshal the signing certificate entry!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	entry, err := legacy.toSigningCertificate()
+	if err != nil {
+		caLogger.Error("Failed to decode the signing certificate entry!",
+			zap.String("Certificate ID: ", legacy.CertID),
+			zap.Error(err),
+		)
+		return err
+	}
+	// The certificate ID is the key of the entry, and is retained as is.
+	entry.TenantID = legacy.CertID
+
+	migratedEntry, err := newDynamoEntry(entry)
+	if err != nil {
+		caLogger.Error("Failed to encode the signing certificate entry!",
+			zap.String("Certificate ID: ", legacy.CertID),
+			zap.Error(err),
+		)
+		return err
+	}
+	migratedItem, err := attributevalue.MarshalMap(migratedEntry)
+	if err != nil {
+		caLogger.Error("Failed to marshal dynamo DB entry!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	start := time.Now()
+	_, err = p.client.PutItem(p.ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(certsTableName),
+		Item:                migratedItem,
+		ConditionExpression: aws.String(legacyEntryExpression),
+	})
+	metrics.ReportLatencyMetric(metrics.MetricAwsDynamoDbRequestLatency, start,
+		awsDynamoDbOpPutItem)
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if !errors.As(err, &conditionFailed) {
+			caLogger.Error("Failed to migrate the signing certificate entry!",
+				zap.String("Certificate ID: ", legacy.CertID),
+				zap.Error(err),
+			)
+			metrics.MetricAwsDynamoDbOtherAwsErrors.Inc()
+		}
+		return err
+	}
+	return nil
+}
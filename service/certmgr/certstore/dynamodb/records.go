@@ -0,0 +1,293 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/certstore/dynamodb
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the APIs used to manage records stored in the Dynamo DB
+// certificate store. Records are stored in a separate table, partitioned by
+// their kind and scope and sorted by their ID. The expiry time of each record
+// is stored as a Dynamo DB TTL attribute so expired records are purged.
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/awserrors"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+// DynamoRecord - represents a record as stored within the records table.
+type DynamoRecord struct {
+	// Partition key - the kind and scope of the record.
+	RecordKey string `dynamodbav:"record_key"`
+
+	// Sort key - the ID of the record within its kind and scope.
+	RecordID string `dynamodbav:"record_id"`
+
+	Kind  string `dynamodbav:"kind"`
+	Scope string `dynamodbav:"scope"`
+	Data  []byte `dynamodbav:"data"`
+
+	// Expiry time in seconds since the epoch (0 if the record never expires).
+	ExpiresAt int64 `dynamodbav:"expires_at"`
+}
+
+// Returns the partition key for records in the specified kind and scope.
+func recordPartitionKey(kind string, scope string) string {
+	return kind + "#" + scope
+}
+
+func newDynamoRecord(record *common.Record) DynamoRecord {
+	entry := DynamoRecord{
+		RecordKey: recordPartitionKey(record.Kind, record.Scope),
+		RecordID:  record.ID,
+		Kind:      record.Kind,
+		Scope:     record.Scope,
+		Data:      record.Data,
+	}
+	if !record.ExpiresAt.IsZero() {
+		entry.ExpiresAt = record.ExpiresAt.Unix()
+	}
+	return entry
+}
+
+func (entry *DynamoRecord) toRecord() *common.Record {
+	record := &common.Record{
+		Kind:  entry.Kind,
+		Scope: entry.Scope,
+		ID:    entry.RecordID,
+		Data:  entry.Data,
+	}
+	if entry.ExpiresAt != 0 {
+		record.ExpiresAt = time.Unix(entry.ExpiresAt, 0)
+	}
+	return record
+}
+
+func recordItemKey(kind string, scope string,
+	id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"record_key": &types.AttributeValueMemberS{
+			Value: recordPartitionKey(kind, scope)},
+		"record_id": &types.AttributeValueMemberS{Value: id},
+	}
+}
+
+// Adds the specified record to the records table. If conditional is set, the
+// record is only added if no unexpired record with the same key exists.
+func (p *DynamoDbProvider) putRecord(ctx context.Context,
+	record *common.Record, conditional bool) error {
+	item, err := attributevalue.MarshalMap(newDynamoRecord(record))
+	if err != nil {
+		caLogger.Error("Failed to marshal dynamo DB record!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(recordsTableName),
+		Item:      item,
+	}
+	if conditional {
+		// Dynamo DB purges expired items lazily, so treat existing items that
+		// have expired as absent.
+		input.ConditionExpression = aws.String(
+			"attribute_not_exists(record_id) OR (expires_at <> :never AND expires_at <= :now)")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":never": &types.AttributeValueMemberN{Value: "0"},
+			":now": &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		}
+	}
+
+	start := time.Now()
+	_, err = p.client.PutItem(ctx, input)
+	metrics.ReportLatencyMetric(metrics.MetricAwsDynamoDbRequestLatency, start,
+		awsDynamoDbOpPutItem)
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return common.ErrRecordExists
+		}
+
+		caLogger.Error("Error while adding the record to the database!",
+			zap.String("Kind: ", record.Kind),
+			zap.String("Record ID: ", record.ID),
+			zap.Error(err),
+		)
+		metrics.MetricAwsDynamoDbOtherAwsErrors.Inc()
+		return awserrors.WrapError("failed to add the record to the store", err)
+	}
+
+	return nil
+}
+
+// AddRecord - Adds the specified record to the Dynamo DB certificate store, if
+// a record with the same key doesn't already exist.
+func (p *DynamoDbProvider) AddRecord(ctx context.Context,
+	record *common.Record) error {
+	return p.putRecord(ctx, record, true)
+}
+
+// PutRecord - Adds or replaces the specified record in the Dynamo DB
+// certificate store.
+func (p *DynamoDbProvider) PutRecord(ctx context.Context,
+	record *common.Record) error {
+	return p.putRecord(ctx, record, false)
+}
+
+// GetRecord - Returns the specified record from the Dynamo DB certificate
+// store.
+func (p *DynamoDbProvider) GetRecord(ctx context.Context, kind string,
+	scope string, id string) (*common.Record, error) {
+	start := time.Now()
+	result, err := p.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(recordsTableName),
+		Key:       recordItemKey(kind, scope, id),
+	})
+	metrics.ReportLatencyMetric(metrics.MetricAwsDynamoDbRequestLatency, start,
+		awsDynamoDbOpGetItem)
+	if err != nil {
+		caLogger.Error("Failed to query for the record!",
+			zap.String("Kind: ", kind),
+			zap.String("Record ID: ", id),
+			zap.Error(err),
+		)
+		metrics.MetricAwsDynamoDbOtherAwsErrors.Inc()
+		return nil, awserrors.WrapError("failed to get the record from the store", err)
+	}
+
+	if result.Item == nil {
+		metrics.MetricAwsDynamoDbNotFoundErrors.Inc()
+		return nil, common.ErrRecordNotFound
+	}
+
+	var entry DynamoRecord
+	err = attributevalue.UnmarshalMap(result.Item, &entry)
+	if err != nil {
+		caLogger.Error("Failed to unmarshal record from Dynamo DB",
+			zap.String("Record ID: ", id),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	record := entry.toRecord()
+	if record.IsExpired(time.Now()) {
+		return nil, common.ErrRecordNotFound
+	}
+	return record, nil
+}
+
+// DeleteRecord - Removes the specified record from the Dynamo DB certificate
+// store.
+func (p *DynamoDbProvider) DeleteRecord(ctx context.Context, kind string,
+	scope string, id string) error {
+	start := time.Now()
+	_, err := p.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(recordsTableName),
+		Key:       recordItemKey(kind, scope, id),
+	})
+	metrics.ReportLatencyMetric(metrics.MetricAwsDynamoDbRequestLatency, start,
+		awsDynamoDbOpDeleteItem)
+	if err != nil {
+		caLogger.Error("Failed to delete the specified record!",
+			zap.String("Kind: ", kind),
+			zap.String("Record ID: ", id),
+			zap.Error(err),
+		)
+		metrics.MetricAwsDynamoDbOtherAwsErrors.Inc()
+		return awserrors.WrapError("failed to delete the record from the store", err)
+	}
+
+	return nil
+}
+
+// Queries all unexpired records of the specified kind within the scope. If
+// countOnly is set, only the number of matching records is returned.
+func (p *DynamoDbProvider) queryRecords(ctx context.Context, kind string,
+	scope string, countOnly bool) ([]*common.Record, int, error) {
+	var (
+		records []*common.Record
+		count   int
+	)
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(recordsTableName),
+		KeyConditionExpression: aws.String("record_key = :key"),
+		FilterExpression:       aws.String("expires_at = :never OR expires_at > :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":key": &types.AttributeValueMemberS{
+				Value: recordPartitionKey(kind, scope)},
+			":never": &types.AttributeValueMemberN{Value: "0"},
+			":now": &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+	}
+	if countOnly {
+		input.Select = types.SelectCount
+	}
+
+	paginator := dynamodb.NewQueryPaginator(p.client, input)
+	for paginator.HasMorePages() {
+		start := time.Now()
+		page, err := paginator.NextPage(ctx)
+		metrics.ReportLatencyMetric(metrics.MetricAwsDynamoDbRequestLatency,
+			start, awsDynamoDbOpQuery)
+		if err != nil {
+			caLogger.Error("Failed to query records from the database!",
+				zap.String("Kind: ", kind),
+				zap.String("Scope: ", scope),
+				zap.Error(err),
+			)
+			metrics.MetricAwsDynamoDbOtherAwsErrors.Inc()
+			return nil, 0, awserrors.WrapError("failed to query records from the store", err)
+		}
+
+		count += int(page.Count)
+		if countOnly {
+			continue
+		}
+
+		var entries []DynamoRecord
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &entries)
+		if err != nil {
+			caLogger.Error("Failed to unmarshal records from Dynamo DB",
+				zap.String("Kind: ", kind),
+				zap.Error(err),
+			)
+			return nil, 0, err
+		}
+		for i := range entries {
+			records = append(records, entries[i].toRecord())
+		}
+	}
+
+	return records, count, nil
+}
+
+// ListRecords - Returns all unexpired records of the specified kind within
+// the specified scope.
+func (p *DynamoDbProvider) ListRecords(ctx context.Context, kind string,
+	scope string) ([]*common.Record, error) {
+	records, _, err := p.queryRecords(ctx, kind, scope, false)
+	return records, err
+}
+
+// CountRecords - Returns the number of unexpired records of the specified
+// kind within the specified scope.
+func (p *DynamoDbProvider) CountRecords(ctx context.Context, kind string,
+	scope string) (int, error) {
+	_, count, err := p.queryRecords(ctx, kind, scope, true)
+	return count, err
+}
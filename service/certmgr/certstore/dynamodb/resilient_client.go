@@ -0,0 +1,133 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/certstore/dynamodb
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements a Dynamo DB client which retries calls failing with transient
+// errors (eg. throttling, server side faults and timeouts) and fails calls
+// fast while Dynamo DB is unavailable.
+package dynamodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/awserrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/resilience"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+const (
+	// Name of the Dynamo DB dependency, used to label metrics.
+	awsDynamoDbDependencyName = "aws_dynamodb"
+
+	// Calls failing with a transient error are attempted up to these many
+	// times, with a jittered exponential backoff between attempts.
+	awsDynamoDbMaxAttempts = 3
+	awsDynamoDbBaseBackoff = 50 * time.Millisecond
+	awsDynamoDbMaxBackoff  = 1 * time.Second
+
+	// The circuit breaker opens after these many consecutive calls fail with
+	// a transient error, and stays open for the specified duration.
+	awsDynamoDbCircuitBreakerThreshold    = 5
+	awsDynamoDbCircuitBreakerOpenDuration = 30 * time.Second
+)
+
+// DynamoDbClient - an interface exposing Dynamo DB methods consumed by the
+// certificate store.
+type DynamoDbClient interface {
+	DescribeTable(context.Context, *dynamodb.DescribeTableInput, ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	CreateTable(context.Context, *dynamodb.CreateTableInput, ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	UpdateTimeToLive(context.Context, *dynamodb.UpdateTimeToLiveInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+	GetItem(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(context.Context, *dynamodb.DeleteItemInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(context.Context, *dynamodb.QueryInput, ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(context.Context, *dynamodb.ScanInput, ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// resilientDynamoDbClient - a Dynamo DB client which retries calls made using
+// the wrapped client and fails calls fast while Dynamo DB is unavailable.
+type resilientDynamoDbClient struct {
+	client   DynamoDbClient
+	executor *resilience.Executor
+}
+
+// newResilientDynamoDbClient - wrap the specified Dynamo DB client.
+func newResilientDynamoDbClient(client DynamoDbClient) *resilientDynamoDbClient {
+	return &resilientDynamoDbClient{
+		client: client,
+		executor: resilience.New(awsDynamoDbDependencyName, resilience.Policy{
+			MaxAttempts:      awsDynamoDbMaxAttempts,
+			AttemptTimeout:   dynamoDbCallTimeout,
+			BaseBackoff:      awsDynamoDbBaseBackoff,
+			MaxBackoff:       awsDynamoDbMaxBackoff,
+			FailureThreshold: awsDynamoDbCircuitBreakerThreshold,
+			OpenDuration:     awsDynamoDbCircuitBreakerOpenDuration,
+		}, awserrors.IsRetryableError),
+	}
+}
+
+func (c *resilientDynamoDbClient) DescribeTable(ctx context.Context,
+	params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return resilience.Call(ctx, c.executor, awsDynamoDbOpDescribeTable,
+		func(ctx context.Context) (*dynamodb.DescribeTableOutput, error) {
+			return c.client.DescribeTable(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientDynamoDbClient) CreateTable(ctx context.Context,
+	params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	return resilience.Call(ctx, c.executor, awsDynamoDbOpCreateTable,
+		func(ctx context.Context) (*dynamodb.CreateTableOutput, error) {
+			return c.client.CreateTable(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientDynamoDbClient) UpdateTimeToLive(ctx context.Context,
+	params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	return resilience.Call(ctx, c.executor, awsDynamoDbOpUpdateTimeToLive,
+		func(ctx context.Context) (*dynamodb.UpdateTimeToLiveOutput, error) {
+			return c.client.UpdateTimeToLive(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientDynamoDbClient) GetItem(ctx context.Context,
+	params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return resilience.Call(ctx, c.executor, awsDynamoDbOpGetItem,
+		func(ctx context.Context) (*dynamodb.GetItemOutput, error) {
+			return c.client.GetItem(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientDynamoDbClient) PutItem(ctx context.Context,
+	params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return resilience.Call(ctx, c.executor, awsDynamoDbOpPutItem,
+		func(ctx context.Context) (*dynamodb.PutItemOutput, error) {
+			return c.client.PutItem(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientDynamoDbClient) DeleteItem(ctx context.Context,
+	params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	return resilience.Call(ctx, c.executor, awsDynamoDbOpDeleteItem,
+		func(ctx context.Context) (*dynamodb.DeleteItemOutput, error) {
+			return c.client.DeleteItem(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientDynamoDbClient) Query(ctx context.Context,
+	params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	return resilience.Call(ctx, c.executor, awsDynamoDbOpQuery,
+		func(ctx context.Context) (*dynamodb.QueryOutput, error) {
+			return c.client.Query(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientDynamoDbClient) Scan(ctx context.Context,
+	params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return resilience.Call(ctx, c.executor, awsDynamoDbOpScan,
+		func(ctx context.Context) (*dynamodb.ScanOutput, error) {
+			return c.client.Scan(ctx, params, optFns...)
+		})
+}
@@ -0,0 +1,70 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/certstore/localdb
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the APIs used to back up the local certificate store. Both the
+// enumeration of the store and snapshots of the database are served from a
+// single read-only transaction, so they reflect a consistent view of the store
+// while the CA continues to serve requests.
+package localdb
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// Enumerate - Invokes the specified functions for each signing certificate and
+// each unexpired record in the local certificate store.
+func (p *LocalDbProvider) Enumerate(certFn func(*common.SigningCertificate) error,
+	recordFn func(*common.Record) error) error {
+	return p.dbHandle.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket([]byte(certsBucketName)).ForEach(func(k []byte, v []byte) error {
+			entry, err := common.DecodeSigningCertificate(v)
+			if err != nil {
+				return fmt.Errorf("failed to decode signing certificate %q: %w",
+					string(k), err)
+			}
+			return certFn(entry)
+		})
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Bucket([]byte(recordsBucketName)).ForEach(func(k []byte, v []byte) error {
+			record, err := common.DecodeRecord(v)
+			if err != nil {
+				return fmt.Errorf("failed to decode record %q: %w", string(k), err)
+			}
+			if record.IsExpired(now) {
+				return nil
+			}
+			return recordFn(record)
+		})
+	})
+}
+
+// Snapshot - Writes a consistent snapshot of the local certificate database to
+// the specified writer. The snapshot is a Bolt DB database file, which can be
+// used by the local certificate store in place of the original database.
+func (p *LocalDbProvider) Snapshot(w io.Writer) (int64, error) {
+	var written int64
+	err := p.dbHandle.View(func(tx *bolt.Tx) error {
+		var err error
+		written, err = tx.WriteTo(w)
+		return err
+	})
+	if err != nil {
+		caLogger.Error("Failed to write a snapshot of the local certificate database!",
+			zap.Error(err),
+		)
+		return written, err
+	}
+	return written, nil
+}
@@ -7,30 +7,75 @@
 package localdb
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
 	bolt "go.etcd.io/bbolt"
 	"go.uber.org/zap"
 )
 
 var (
 	caLogger *zap.Logger
+
+	// Settings of the local certificate store, read from the localdb section
+	// under certificate_authority/providers in the configuration file.
+	settings = Settings{
+		Path: certDBName,
+	}
 )
 
 const (
-	// Name of the Bolt DB database file.
+	// Default name of the Bolt DB database file.
 	certDBName = "certs.db"
 
 	// Bucket within the database where signing certificates are stored.
 	certsBucketName = "SigningCertificates"
+
+	// Bucket within the database where records are stored.
+	recordsBucketName = "Records"
 )
 
+// Register the local certificate store. The local certificate store is only
+// recommended for use in test mode. For production use a proper certificate
+// store provider.
+func init() {
+	certstore.Register(common.CertStoreLocalDb, func() certstore.CertStore {
+		return &LocalDbProvider{}
+	}, config.ProviderSchema{
+		Settings: &settings,
+		Env: map[string]config.EnvOverride{
+			"CA_LOCALDB_PATH": {Value: &settings.Path},
+		},
+		Validate: func(*config.ConfigMgr) error {
+			if settings.Path == "" {
+				return errors.New("the path of the local certificate database must be specified")
+			}
+			return nil
+		},
+	})
+}
+
+// Settings represents configuration settings for the local certificate store.
+type Settings struct {
+	// Path to the Bolt DB database file. A snapshot of the database may be
+	// exported by pointing the store at the snapshot.
+	Path string `yaml:"path"`
+}
+
 // Implements a local signing certificate store provider using a local
 // Bolt DB instance.
 type LocalDbProvider struct {
 	// Handle to the Bolt database used to store the signing certificates.
 	dbHandle *bolt.DB
+
+	// Closed to stop purging expired records, and closed once stopped.
+	stopSweep chan struct{}
+	sweepDone chan struct{}
 }
 
 // Init - intialize a BoltDB based local database used to store signing
@@ -39,7 +84,7 @@ func (p *LocalDbProvider) Init(logger *zap.Logger) error {
 	var err error
 	caLogger = logger
 
-	p.dbHandle, err = bolt.Open(certDBName, 0600,
+	p.dbHandle, err = bolt.Open(settings.Path, 0600,
 		&bolt.Options{Timeout: 1 * time.Second})
 	if err != nil {
 		caLogger.Error("Failed to open the local cert database!",
@@ -48,12 +93,14 @@ func (p *LocalDbProvider) Init(logger *zap.Logger) error {
 		return err
 	}
 
-	// Create a bucket to store signing certificates , if it doesn't already
-	// exist.
+	// Create the buckets to store signing certificates and records, if they
+	// don't already exist.
 	err = p.dbHandle.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(certsBucketName))
-		if err != nil {
-			return fmt.Errorf("create bucket failed with error: %s", err)
+		for _, bucketName := range []string{certsBucketName, recordsBucketName} {
+			_, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+			if err != nil {
+				return fmt.Errorf("create bucket failed with error: %s", err)
+			}
 		}
 		return nil
 	})
@@ -65,6 +112,24 @@ func (p *LocalDbProvider) Init(logger *zap.Logger) error {
 		return err
 	}
 
+	// Rewrite signing certificates stored by earlier versions of the CA using
+	// the legacy encoding. This cannot be undone, see
+	// migrateSigningCertificates.
+	err = p.migrateSigningCertificates()
+	if err != nil {
+		caLogger.Error("Failed to migrate signing certificates to the versioned encoding!",
+			zap.Error(err),
+		)
+		_ = p.dbHandle.Close()
+		return err
+	}
+
+	// Purge expired records periodically, since records are otherwise only
+	// purged when they are listed.
+	p.stopSweep = make(chan struct{})
+	p.sweepDone = make(chan struct{})
+	go p.sweepExpiredRecords()
+
 	caLogger.Info("Successfully initialized the local certificate database!")
 	return nil
 }
@@ -72,6 +137,9 @@ func (p *LocalDbProvider) Init(logger *zap.Logger) error {
 // Shutdown - shutdown the local BoltDB instance used to store signing
 // certificates.
 func (p *LocalDbProvider) Shutdown() {
+	close(p.stopSweep)
+	<-p.sweepDone
+
 	err := p.dbHandle.Close()
 	if err != nil {
 		caLogger.Error("Failed to shut down the local certificate database!",
@@ -82,3 +150,15 @@ func (p *LocalDbProvider) Shutdown() {
 
 	caLogger.Info("Successfully shut down the local certificate database!")
 }
+
+// Ping - check that the local certificate database can be read.
+func (p *LocalDbProvider) Ping(ctx context.Context) error {
+	return p.dbHandle.View(func(tx *bolt.Tx) error {
+		for _, bucketName := range []string{certsBucketName, recordsBucketName} {
+			if tx.Bucket([]byte(bucketName)) == nil {
+				return fmt.Errorf("bucket %s does not exist", bucketName)
+			}
+		}
+		return nil
+	})
+}
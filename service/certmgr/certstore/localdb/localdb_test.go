@@ -0,0 +1,148 @@
+package localdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+func TestLocalDbProvider_MigrateSigningCertificates(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// Store a signing certificate using the encoding used by earlier versions
+	// of the CA.
+	legacy := common.SigningCertificate{
+		TenantID:    "tenant-1",
+		KmsKeyID:    "key-1",
+		Certificate: []byte("certificate"),
+	}
+	buffer := &bytes.Buffer{}
+	if err := gob.NewEncoder(buffer).Encode(&legacy); err != nil {
+		t.Fatalf("Failed to GOB encode the signing certificate: %v", err)
+	}
+
+	db, err := bolt.Open(certDBName, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to open the certificate database: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(certsBucketName))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(legacy.TenantID), buffer.Bytes())
+	})
+	_ = db.Close()
+	if err != nil {
+		t.Fatalf("Failed to store the legacy signing certificate: %v", err)
+	}
+
+	provider := &LocalDbProvider{}
+	if err = provider.Init(zap.NewNop()); err != nil {
+		t.Fatalf("Failed to initialize the local certificate store: %v", err)
+	}
+	defer provider.Shutdown()
+
+	// The signing certificate is rewritten using the versioned encoding.
+	err = provider.dbHandle.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(certsBucketName)).Get([]byte(legacy.TenantID))
+		if common.IsLegacySigningCertificate(v) {
+			t.Errorf("Expected the signing certificate to be migrated")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read the signing certificate: %v", err)
+	}
+
+	entry, err := provider.GetCertificate(context.Background(), legacy.TenantID)
+	if err != nil {
+		t.Fatalf("Failed to get the signing certificate: %v", err)
+	}
+	if (entry.KmsKeyID != legacy.KmsKeyID) ||
+		!bytes.Equal(entry.Certificate, legacy.Certificate) {
+		t.Errorf("Unexpected signing certificate returned: %+v", entry)
+	}
+}
+
+func TestLocalDbProvider_AddCertificateExists(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ctx := context.Background()
+
+	provider := &LocalDbProvider{}
+	if err := provider.Init(zap.NewNop()); err != nil {
+		t.Fatalf("Failed to initialize the local certificate store: %v", err)
+	}
+	defer provider.Shutdown()
+
+	entry := &common.SigningCertificate{
+		TenantID:    "tenant-1",
+		Certificate: []byte("certificate"),
+	}
+	if err := provider.AddCertificate(ctx, entry); err != nil {
+		t.Fatalf("Failed to add the signing certificate: %v", err)
+	}
+
+	// An existing signing certificate is not replaced.
+	replacement := &common.SigningCertificate{
+		TenantID:    "tenant-1",
+		Certificate: []byte("replacement"),
+	}
+	if err := provider.AddCertificate(ctx, replacement); !errors.Is(err,
+		common.ErrTenantExists) {
+		t.Fatalf("Expected the signing certificate to exist, got %v", err)
+	}
+	stored, err := provider.GetCertificate(ctx, "tenant-1")
+	if (err != nil) || !bytes.Equal(stored.Certificate, entry.Certificate) {
+		t.Errorf("Expected the signing certificate to be retained, got %+v, %v",
+			stored, err)
+	}
+}
+
+func TestLocalDbProvider_PurgeExpiredRecords(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ctx := context.Background()
+
+	provider := &LocalDbProvider{}
+	if err := provider.Init(zap.NewNop()); err != nil {
+		t.Fatalf("Failed to initialize the local certificate store: %v", err)
+	}
+	defer provider.Shutdown()
+
+	now := time.Now()
+	for _, record := range []*common.Record{
+		{Kind: common.RecordKindDevice, Scope: "tenant-1", ID: "expired",
+			ExpiresAt: now.Add(-time.Minute)},
+		{Kind: common.RecordKindDevice, Scope: "tenant-1", ID: "live",
+			ExpiresAt: now.Add(time.Hour)},
+		{Kind: common.RecordKindDevice, Scope: "tenant-2", ID: "expired",
+			ExpiresAt: now.Add(-time.Minute)},
+		{Kind: common.RecordKindTenantQuota, ID: "tenant-1"},
+	} {
+		if err := provider.PutRecord(ctx, record); err != nil {
+			t.Fatalf("Failed to put the record: %v", err)
+		}
+	}
+
+	// Expired records are purged across all kinds and scopes.
+	purged, err := provider.purgeExpiredRecords()
+	if (err != nil) || (purged != 2) {
+		t.Fatalf("Expected 2 records to be purged, got %d, %v", purged, err)
+	}
+	err = provider.dbHandle.View(func(tx *bolt.Tx) error {
+		if n := tx.Bucket([]byte(recordsBucketName)).Stats().KeyN; n != 2 {
+			t.Errorf("Expected 2 records to be retained, got %d", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read the records: %v", err)
+	}
+}
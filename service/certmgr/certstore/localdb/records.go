@@ -0,0 +1,307 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/certstore/localdb
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the APIs used to manage records stored in the localdb
+// certificate store. Records are stored in a separate bucket and keyed by
+// their kind, scope and ID so that all records within a scope can be listed
+// using a prefix scan. Expired records are purged periodically while the store
+// is open.
+package localdb
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/common"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+const (
+	// Separator used between the kind, scope and ID of record keys.
+	recordKeySeparator = "\x00"
+
+	// Interval at which expired records are purged from the store.
+	recordSweepInterval = 10 * time.Minute
+)
+
+// Returns the prefix shared by keys of all records in the specified scope.
+func recordKeyPrefix(kind string, scope string) []byte {
+	return []byte(kind + recordKeySeparator + scope + recordKeySeparator)
+}
+
+// Returns the key used to store the specified record.
+func recordKey(kind string, scope string, id string) []byte {
+	return append(recordKeyPrefix(kind, scope), []byte(id)...)
+}
+
+// Returns whether an unexpired record exists for the specified key.
+func isLiveRecord(b *bolt.Bucket, key []byte) (bool, error) {
+	encodedRecord := b.Get(key)
+	if encodedRecord == nil {
+		return false, nil
+	}
+
+	record, err := common.DecodeRecord(encodedRecord)
+	if err != nil {
+		return false, err
+	}
+	return !record.IsExpired(time.Now()), nil
+}
+
+// AddRecord - Adds the specified record to the local certificate store, if a
+// record with the same key doesn't already exist.
+func (p *LocalDbProvider) AddRecord(ctx context.Context, record *common.Record) error {
+	err := p.dbHandle.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(recordsBucketName))
+		key := recordKey(record.Kind, record.Scope, record.ID)
+
+		exists, err := isLiveRecord(b, key)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return common.ErrRecordExists
+		}
+
+		encodedRecord, err := common.EncodeRecord(record)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, encodedRecord)
+	})
+	if err != nil {
+		if err != common.ErrRecordExists {
+			caLogger.Error("Failed to add the record to the store!",
+				zap.String("Kind:", record.Kind),
+				zap.String("Record ID:", record.ID),
+				zap.Error(err),
+			)
+		}
+		return caerrors.WrapIfUncategorized(caerrors.Internal,
+			"failed to add the record to the store", err)
+	}
+	return nil
+}
+
+// PutRecord - Adds or replaces the specified record in the local certificate
+// store.
+func (p *LocalDbProvider) PutRecord(ctx context.Context, record *common.Record) error {
+	err := p.dbHandle.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(recordsBucketName))
+		encodedRecord, err := common.EncodeRecord(record)
+		if err != nil {
+			return err
+		}
+		return b.Put(recordKey(record.Kind, record.Scope, record.ID),
+			encodedRecord)
+	})
+	if err != nil {
+		caLogger.Error("Failed to put the record into the store!",
+			zap.String("Kind:", record.Kind),
+			zap.String("Record ID:", record.ID),
+			zap.Error(err),
+		)
+		return caerrors.WrapIfUncategorized(caerrors.Internal,
+			"failed to put the record into the store", err)
+	}
+	return nil
+}
+
+// GetRecord - Returns the specified record from the local certificate store.
+func (p *LocalDbProvider) GetRecord(ctx context.Context, kind string,
+	scope string, id string) (*common.Record, error) {
+	var record *common.Record
+
+	err := p.dbHandle.View(func(tx *bolt.Tx) error {
+		var err error
+		b := tx.Bucket([]byte(recordsBucketName))
+		encodedRecord := b.Get(recordKey(kind, scope, id))
+		if encodedRecord == nil {
+			return common.ErrRecordNotFound
+		}
+
+		record, err = common.DecodeRecord(encodedRecord)
+		if err != nil {
+			return err
+		}
+		if record.IsExpired(time.Now()) {
+			return common.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, caerrors.WrapIfUncategorized(caerrors.Internal,
+			"failed to get the record from the store", err)
+	}
+	return record, nil
+}
+
+// DeleteRecord - Removes the specified record from the local certificate
+// store.
+func (p *LocalDbProvider) DeleteRecord(ctx context.Context, kind string,
+	scope string, id string) error {
+	err := p.dbHandle.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(recordsBucketName))
+		return b.Delete(recordKey(kind, scope, id))
+	})
+	if err != nil {
+		caLogger.Error("Failed to remove the record from the store!",
+			zap.String("Kind:", kind),
+			zap.String("Record ID:", id),
+			zap.Error(err),
+		)
+		return caerrors.WrapIfUncategorized(caerrors.Internal,
+			"failed to remove the record from the store", err)
+	}
+	return nil
+}
+
+// ListRecords - Returns all unexpired records of the specified kind within
+// the specified scope. Expired records encountered are purged.
+func (p *LocalDbProvider) ListRecords(ctx context.Context, kind string,
+	scope string) ([]*common.Record, error) {
+	var records []*common.Record
+
+	err := p.dbHandle.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(recordsBucketName))
+		prefix := recordKeyPrefix(kind, scope)
+		now := time.Now()
+		var expired [][]byte
+
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			record, err := common.DecodeRecord(v)
+			if err != nil {
+				return err
+			}
+			if record.IsExpired(now) {
+				expired = append(expired, append([]byte{}, k...))
+				continue
+			}
+			records = append(records, record)
+		}
+
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		caLogger.Error("Failed to list records in the store!",
+			zap.String("Kind:", kind),
+			zap.String("Scope:", scope),
+			zap.Error(err),
+		)
+		return nil, caerrors.WrapIfUncategorized(caerrors.Internal,
+			"failed to list records in the store", err)
+	}
+	return records, nil
+}
+
+// CountRecords - Returns the number of unexpired records of the specified
+// kind within the specified scope. Unlike ListRecords, expired records are not
+// purged, so records are counted within a read-only transaction.
+func (p *LocalDbProvider) CountRecords(ctx context.Context, kind string,
+	scope string) (int, error) {
+	var count int
+
+	err := p.dbHandle.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(recordsBucketName))
+		prefix := recordKeyPrefix(kind, scope)
+		now := time.Now()
+
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			record, err := common.DecodeRecord(v)
+			if err != nil {
+				return err
+			}
+			if !record.IsExpired(now) {
+				count++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		caLogger.Error("Failed to count records in the store!",
+			zap.String("Kind:", kind),
+			zap.String("Scope:", scope),
+			zap.Error(err),
+		)
+		return 0, caerrors.WrapIfUncategorized(caerrors.Internal,
+			"failed to count records in the store", err)
+	}
+	return count, nil
+}
+
+// purgeExpiredRecords - Removes all expired records from the local certificate
+// store, and returns the number of records removed.
+func (p *LocalDbProvider) purgeExpiredRecords() (int, error) {
+	var purged int
+
+	err := p.dbHandle.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(recordsBucketName))
+		now := time.Now()
+
+		var expired [][]byte
+
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			record, err := common.DecodeRecord(v)
+			if err != nil {
+				return err
+			}
+			if record.IsExpired(now) {
+				expired = append(expired, append([]byte{}, k...))
+			}
+		}
+
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		purged = len(expired)
+		return nil
+	})
+	if err != nil {
+		caLogger.Error("Failed to purge expired records from the store!",
+			zap.Error(err),
+		)
+		return 0, caerrors.WrapIfUncategorized(caerrors.Internal,
+			"failed to purge expired records from the store", err)
+	}
+	return purged, nil
+}
+
+// sweepExpiredRecords - Purges expired records from the store each time the
+// sweep interval elapses, until the store is shut down.
+func (p *LocalDbProvider) sweepExpiredRecords() {
+	defer close(p.sweepDone)
+
+	ticker := time.NewTicker(recordSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopSweep:
+			return
+		case <-ticker.C:
+		}
+
+		// Failures are logged, and the next sweep is attempted as scheduled.
+		purged, err := p.purgeExpiredRecords()
+		if (err == nil) && (purged > 0) {
+			caLogger.Info("Purged expired records from the store.",
+				zap.Int("Records purged:", purged),
+			)
+		}
+	}
+}
@@ -4,20 +4,32 @@
 // (C) HP Development Company, LP
 // Purpose:
 // Implements the APIs used to manage the lifetime of certificates stored in the
-// localdb certificate store.
+// localdb certificate store. Requests are served from the local database file
+// without blocking on the network, so the contexts specified for requests are
+// not used.
 package localdb
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
 	"github.com/HPInc/krypton-ca/service/common"
 	bolt "go.etcd.io/bbolt"
 	"go.uber.org/zap"
 )
 
 // AddCertificate - Adds the specified signing certificate to the local
-// certificate store (bolt instance).
-func (p *LocalDbProvider) AddCertificate(entry *common.SigningCertificate) error {
+// certificate store (bolt instance), if a signing certificate with the same ID
+// doesn't already exist.
+func (p *LocalDbProvider) AddCertificate(ctx context.Context,
+	entry *common.SigningCertificate) error {
 	err := p.dbHandle.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(certsBucketName))
+		if b.Get([]byte(entry.TenantID)) != nil {
+			return common.ErrTenantExists
+		}
+
 		encodedEntry, err := common.EncodeSigningCertificate(entry)
 		if err != nil {
 			return err
@@ -27,9 +39,11 @@ func (p *LocalDbProvider) AddCertificate(entry *common.SigningCertificate) error
 	})
 	if err != nil {
 		caLogger.Error("Failed to add the certificate to the store!",
+			zap.String("Tenant ID:", entry.TenantID),
 			zap.Error(err),
 		)
-		return err
+		return caerrors.WrapIfUncategorized(caerrors.Internal,
+			"failed to add the signing certificate to the store", err)
 	}
 
 	caLogger.Debug("Added the certificate to the store!",
@@ -40,7 +54,7 @@ func (p *LocalDbProvider) AddCertificate(entry *common.SigningCertificate) error
 
 // GetCertificate - Returns the signing certificate for the specified ID
 // from the local certificate store.
-func (p *LocalDbProvider) GetCertificate(
+func (p *LocalDbProvider) GetCertificate(ctx context.Context,
 	certID string) (*common.SigningCertificate, error) {
 	var entry *common.SigningCertificate
 
@@ -59,13 +73,16 @@ func (p *LocalDbProvider) GetCertificate(
 		entry, err = common.DecodeSigningCertificate(encodedEntry)
 		return err
 	})
-
-	return entry, err
+	if err != nil {
+		return nil, caerrors.WrapIfUncategorized(caerrors.Internal,
+			"failed to get the signing certificate from the store", err)
+	}
+	return entry, nil
 }
 
 // DeleteCertificate - Removes the specified signing certificate
 // from the local certificate store.
-func (p *LocalDbProvider) DeleteCertificate(certID string) error {
+func (p *LocalDbProvider) DeleteCertificate(ctx context.Context, certID string) error {
 	err := p.dbHandle.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(certsBucketName))
 
@@ -78,10 +95,67 @@ func (p *LocalDbProvider) DeleteCertificate(certID string) error {
 			zap.String("Certificate ID:", certID),
 			zap.Error(err),
 		)
-		return err
+		return caerrors.WrapIfUncategorized(caerrors.Internal,
+			"failed to remove the signing certificate from the store", err)
 	}
 	caLogger.Debug("Successfully removed the signing certificate from the store!",
 		zap.String("Certificate ID:", certID),
 	)
 	return nil
 }
+
+// migrateSigningCertificates - Rewrites signing certificates encoded using the
+// legacy GOB encoding using the versioned encoding. Entries are rewritten
+// within a single transaction, so either all or none of them are migrated.
+// The migration is one-way: earlier versions of the CA cannot decode entries
+// using the versioned encoding, so the database must be backed up before
+// upgrading in order to be able to roll back.
+func (p *LocalDbProvider) migrateSigningCertificates() error {
+	migrated := 0
+	err := p.dbHandle.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(certsBucketName))
+
+		// Collect the entries to be rewritten, since the bucket must not be
+		// modified while iterating over it.
+		rewrites := map[string][]byte{}
+		err := b.ForEach(func(k []byte, v []byte) error {
+			if !common.IsLegacySigningCertificate(v) {
+				return nil
+			}
+
+			entry, err := common.DecodeSigningCertificate(v)
+			if err != nil {
+				return fmt.Errorf("failed to decode signing certificate %q: %w",
+					string(k), err)
+			}
+			encodedEntry, err := common.EncodeSigningCertificate(entry)
+			if err != nil {
+				return err
+			}
+			rewrites[string(k)] = encodedEntry
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for k, v := range rewrites {
+			if err = b.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		migrated = len(rewrites)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if migrated > 0 {
+		caLogger.Warn("Migrated signing certificates to the versioned encoding. Earlier versions of the CA cannot read the migrated database!",
+			zap.Int("Signing certificates:", migrated),
+			zap.String("Database:", settings.Path),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/certstore/postgres
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the APIs used to back up the PostgreSQL certificate store. The
+// contents of the store are enumerated within a single read-only, repeatable
+// read transaction, so they reflect a consistent view of the store.
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// Enumerate - Invokes the specified functions for each signing certificate and
+// each unexpired record in the PostgreSQL certificate store. Enumeration is
+// not subject to the query timeout, since it scans the entire store.
+func (p *PostgresProvider) Enumerate(certFn func(*common.SigningCertificate) error,
+	recordFn func(*common.Record) error) error {
+	start := time.Now()
+	err := pgx.BeginTxFunc(p.ctx, p.pool, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	}, func(tx pgx.Tx) error {
+		err := enumerateCertificates(p.ctx, tx, certFn)
+		if err != nil {
+			return err
+		}
+		return enumerateRecords(p.ctx, tx, recordFn)
+	})
+	metrics.ReportLatencyMetric(metrics.MetricPostgresRequestLatency, start,
+		postgresOpEnumerate)
+	if err != nil {
+		caLogger.Error("Failed to enumerate the contents of the store!",
+			zap.Error(err),
+		)
+		metrics.MetricPostgresQueryFailures.Inc()
+		return wrapPostgresError(
+			"failed to enumerate the contents of the store", err)
+	}
+	return nil
+}
+
+// Invokes the specified function for each signing certificate in the store.
+func enumerateCertificates(ctx context.Context, tx pgx.Tx,
+	certFn func(*common.SigningCertificate) error) error {
+	rows, err := tx.Query(ctx, `SELECT cert_id, kms_key_id, certificate
+		FROM signing_certificates ORDER BY cert_id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := &common.SigningCertificate{}
+		err = rows.Scan(&entry.TenantID, &entry.KmsKeyID, &entry.Certificate)
+		if err != nil {
+			return err
+		}
+		if err = certFn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Invokes the specified function for each unexpired record in the store.
+func enumerateRecords(ctx context.Context, tx pgx.Tx,
+	recordFn func(*common.Record) error) error {
+	rows, err := tx.Query(ctx, `SELECT kind, scope, id, data, expires_at
+		FROM records WHERE expires_at IS NULL OR expires_at > $1
+		ORDER BY kind, scope, id`, time.Now())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		record := &common.Record{}
+		var expiresAt *time.Time
+		err = rows.Scan(&record.Kind, &record.Scope, &record.ID, &record.Data,
+			&expiresAt)
+		if err != nil {
+			return err
+		}
+		if expiresAt != nil {
+			record.ExpiresAt = *expiresAt
+		}
+		if err = recordFn(record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,54 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/certstore/postgres
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Assigns categories to errors returned by PostgreSQL, when PostgreSQL is used
+// as the certificate store.
+package postgres
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// wrapPostgresError - wraps an error returned by a PostgreSQL query into an
+// error with the appropriate category. Connection failures, resource
+// exhaustion, server shutdowns and transaction conflicts which can be retried
+// are reported as DependencyUnavailable. Unique constraint violations are
+// reported as Conflict. Other errors returned by the server indicate a
+// problem with the query issued by the CA and are reported as Internal.
+func wrapPostgresError(message string, err error) error {
+	var (
+		e     *caerrors.Error
+		pgErr *pgconn.PgError
+	)
+	if (err == nil) || errors.As(err, &e) {
+		return err
+	}
+
+	if !errors.As(err, &pgErr) {
+		// The server did not return an error response (eg. network failures,
+		// timeouts and exhausted connection pools).
+		return caerrors.Wrap(caerrors.DependencyUnavailable, message, err)
+	}
+
+	switch {
+	case pgErr.Code == "23505":
+		// unique_violation
+		return caerrors.Wrap(caerrors.Conflict, message, err)
+	case (pgErr.Code == "40001") || (pgErr.Code == "40P01"):
+		// serialization_failure, deadlock_detected
+		return caerrors.Wrap(caerrors.DependencyUnavailable, message, err)
+	case strings.HasPrefix(pgErr.Code, "08") ||
+		strings.HasPrefix(pgErr.Code, "53") ||
+		strings.HasPrefix(pgErr.Code, "57P"):
+		// Connection exceptions, insufficient resources and operator
+		// intervention (eg. server shutdown).
+		return caerrors.Wrap(caerrors.DependencyUnavailable, message, err)
+	}
+	return caerrors.Wrap(caerrors.Internal, message, err)
+}
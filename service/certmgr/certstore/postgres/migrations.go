@@ -0,0 +1,114 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/certstore/postgres
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements schema migrations for the PostgreSQL certificate store. The
+// version of the schema is tracked within the schema_migrations table, and
+// pending migrations are applied when the certificate store is initialized.
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// Key of the advisory lock used to serialize schema migrations across CA
+// instances sharing the database.
+const migrationLockKey = 0x6b727970746f6e // "krypton"
+
+// Schema migrations, in order. Migrations must never be modified once
+// released; changes to the schema are made by appending a new migration.
+var migrations = []string{
+	// Version 1: signing certificates, records, and tables used to track
+	// issued and revoked certificates.
+	`CREATE TABLE signing_certificates (
+		cert_id     TEXT PRIMARY KEY,
+		kms_key_id  TEXT NOT NULL,
+		certificate BYTEA NOT NULL,
+		updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+
+	CREATE TABLE records (
+		kind       TEXT NOT NULL,
+		scope      TEXT NOT NULL,
+		id         TEXT NOT NULL,
+		data       BYTEA NOT NULL,
+		expires_at TIMESTAMPTZ,
+		PRIMARY KEY (kind, scope, id)
+	);
+	CREATE INDEX records_expires_at_idx ON records (expires_at)
+		WHERE expires_at IS NOT NULL;
+
+	CREATE TABLE issued_certificates (
+		serial_number TEXT PRIMARY KEY,
+		tenant_id     TEXT NOT NULL,
+		device_id     TEXT NOT NULL,
+		certificate   BYTEA NOT NULL,
+		not_before    TIMESTAMPTZ NOT NULL,
+		not_after     TIMESTAMPTZ NOT NULL,
+		issued_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE INDEX issued_certificates_device_idx
+		ON issued_certificates (tenant_id, device_id);
+
+	CREATE TABLE revoked_certificates (
+		serial_number TEXT PRIMARY KEY,
+		tenant_id     TEXT NOT NULL,
+		reason        INTEGER NOT NULL DEFAULT 0,
+		revoked_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE INDEX revoked_certificates_tenant_idx
+		ON revoked_certificates (tenant_id, revoked_at);`,
+}
+
+// migrate - apply pending schema migrations to the database. Migrations are
+// applied within a single transaction, holding an advisory lock so that CA
+// instances starting concurrently do not apply the same migration twice.
+func (p *PostgresProvider) migrate(ctx context.Context) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)",
+			migrationLockKey)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`)
+		if err != nil {
+			return err
+		}
+
+		var version int
+		err = tx.QueryRow(ctx,
+			"SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+		if err != nil {
+			return err
+		}
+		if version > len(migrations) {
+			return fmt.Errorf("database schema version %d is newer than the supported version %d",
+				version, len(migrations))
+		}
+
+		for ; version < len(migrations); version++ {
+			_, err = tx.Exec(ctx, migrations[version])
+			if err != nil {
+				return fmt.Errorf("schema migration %d failed: %w", version+1, err)
+			}
+			_, err = tx.Exec(ctx,
+				"INSERT INTO schema_migrations (version) VALUES ($1)", version+1)
+			if err != nil {
+				return err
+			}
+			caLogger.Info("Applied schema migration to the PostgreSQL database.",
+				zap.Int("Version:", version+1),
+			)
+		}
+		return nil
+	})
+}
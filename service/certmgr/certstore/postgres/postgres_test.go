@@ -0,0 +1,749 @@
+package postgres
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// The tests run against an in-memory fake of the PostgreSQL database, which
+// supports the statements issued by the certificate store. To run them
+// against a PostgreSQL database instead, specify it using CA_POSTGRES_TEST_DSN
+// (eg. a locally started instance). The tables used by the certificate store
+// are dropped once the tests complete, so the database must be a disposable
+// one.
+const testDSNVariable = "CA_POSTGRES_TEST_DSN"
+
+// fakeRecordKey - the primary key of the records table.
+type fakeRecordKey struct {
+	kind  string
+	scope string
+	id    string
+}
+
+// fakeDatabase - the tables held by the fake database.
+type fakeDatabase struct {
+	// Whether the schema_migrations table and the tables created by the
+	// schema migration exist.
+	migrationsCreated bool
+	tablesCreated     bool
+
+	versions     map[int]bool
+	certificates map[string]common.SigningCertificate
+	records      map[fakeRecordKey]common.Record
+}
+
+func (db *fakeDatabase) clone() *fakeDatabase {
+	clone := *db
+	clone.versions = maps.Clone(db.versions)
+	clone.certificates = maps.Clone(db.certificates)
+	clone.records = maps.Clone(db.records)
+	return &clone
+}
+
+// fakePostgresPool - an in-memory fake of the connection pool to the
+// database. Statements are matched against those issued by the certificate
+// store. Transactions hold the lock until they are committed or rolled back,
+// so they are serializable.
+type fakePostgresPool struct {
+	lock sync.Mutex
+	db   *fakeDatabase
+
+	// Error returned by the next call, if any.
+	failNext error
+}
+
+func newFakePostgresPool() *fakePostgresPool {
+	return &fakePostgresPool{db: &fakeDatabase{
+		versions:     map[int]bool{},
+		certificates: map[string]common.SigningCertificate{},
+		records:      map[fakeRecordKey]common.Record{},
+	}}
+}
+
+func (f *fakePostgresPool) injectedError() error {
+	err := f.failNext
+	f.failNext = nil
+	return err
+}
+
+func (f *fakePostgresPool) Exec(ctx context.Context, sql string,
+	args ...any) (pgconn.CommandTag, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return f.db.exec(sql, args)
+}
+
+func (f *fakePostgresPool) QueryRow(ctx context.Context, sql string,
+	args ...any) pgx.Row {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return &fakeRows{err: err}
+	}
+	rows, err := f.db.query(sql, args)
+	return &fakeRows{rows: rows, err: err}
+}
+
+func (f *fakePostgresPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	return f.BeginTx(ctx, pgx.TxOptions{})
+}
+
+func (f *fakePostgresPool) BeginTx(ctx context.Context,
+	txOptions pgx.TxOptions) (pgx.Tx, error) {
+	f.lock.Lock()
+	if err := f.injectedError(); err != nil {
+		f.lock.Unlock()
+		return nil, err
+	}
+	return &fakeTx{pool: f, db: f.db.clone(),
+		readOnly: txOptions.AccessMode == pgx.ReadOnly}, nil
+}
+
+func (f *fakePostgresPool) Ping(ctx context.Context) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.injectedError()
+}
+
+func (f *fakePostgresPool) Close() {
+}
+
+// fakeTx - a transaction on the fake database. Statements are applied to a
+// copy of the database, which replaces the database once committed.
+type fakeTx struct {
+	pgx.Tx
+
+	pool     *fakePostgresPool
+	db       *fakeDatabase
+	readOnly bool
+	done     bool
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string,
+	args ...any) (pgconn.CommandTag, error) {
+	if tx.readOnly {
+		return pgconn.CommandTag{}, &pgconn.PgError{Code: "25006"}
+	}
+	return tx.db.exec(sql, args)
+}
+
+func (tx *fakeTx) Query(ctx context.Context, sql string,
+	args ...any) (pgx.Rows, error) {
+	rows, err := tx.db.query(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{rows: rows, next: -1}, nil
+}
+
+func (tx *fakeTx) QueryRow(ctx context.Context, sql string,
+	args ...any) pgx.Row {
+	rows, err := tx.db.query(sql, args)
+	return &fakeRows{rows: rows, err: err}
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	tx.done = true
+	tx.pool.db = tx.db
+	tx.pool.lock.Unlock()
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	tx.done = true
+	tx.pool.lock.Unlock()
+	return nil
+}
+
+// fakeRows - rows returned by a query on the fake database. When used as a
+// single row, the first row is scanned.
+type fakeRows struct {
+	pgx.Rows
+
+	rows [][]any
+	next int
+	err  error
+}
+
+func (r *fakeRows) Next() bool {
+	r.next++
+	return r.next < len(r.rows)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.rows) == 0 {
+		return pgx.ErrNoRows
+	}
+	row := r.rows[max(r.next, 0)]
+	if len(row) != len(dest) {
+		return fmt.Errorf("expected %d columns, got %d", len(row), len(dest))
+	}
+	for i, value := range row {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(value))
+	}
+	return nil
+}
+
+func (r *fakeRows) Err() error {
+	return r.err
+}
+
+func (r *fakeRows) Close() {
+}
+
+// Returns the expiry time of the record as returned by the database.
+func fakeExpiry(record common.Record) *time.Time {
+	if record.ExpiresAt.IsZero() {
+		return nil
+	}
+	return &record.ExpiresAt
+}
+
+// Returns true if the record has not expired at the specified time.
+func fakeUnexpired(record common.Record, now time.Time) bool {
+	return record.ExpiresAt.IsZero() || record.ExpiresAt.After(now)
+}
+
+// exec - apply the specified statement to the database.
+func (db *fakeDatabase) exec(sql string, args []any) (pgconn.CommandTag, error) {
+	sql = strings.Join(strings.Fields(sql), " ")
+	switch {
+	case sql == "SELECT pg_advisory_xact_lock($1)":
+		return pgconn.NewCommandTag("SELECT 1"), nil
+
+	case strings.HasPrefix(sql, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		db.migrationsCreated = true
+		return pgconn.NewCommandTag("CREATE TABLE"), nil
+
+	case sql == strings.Join(strings.Fields(migrations[0]), " "):
+		if db.tablesCreated {
+			return pgconn.CommandTag{}, &pgconn.PgError{Code: "42P07"}
+		}
+		db.tablesCreated = true
+		return pgconn.NewCommandTag("CREATE INDEX"), nil
+
+	case sql == "INSERT INTO schema_migrations (version) VALUES ($1)":
+		if !db.migrationsCreated {
+			return pgconn.CommandTag{}, &pgconn.PgError{Code: "42P01"}
+		}
+		if db.versions[args[0].(int)] {
+			return pgconn.CommandTag{}, &pgconn.PgError{Code: "23505"}
+		}
+		db.versions[args[0].(int)] = true
+		return pgconn.NewCommandTag("INSERT 0 1"), nil
+	}
+
+	if !db.tablesCreated {
+		return pgconn.CommandTag{}, &pgconn.PgError{Code: "42P01"}
+	}
+	switch {
+	case strings.HasPrefix(sql, "INSERT INTO signing_certificates"):
+		certID := args[0].(string)
+		if _, ok := db.certificates[certID]; ok {
+			return pgconn.NewCommandTag("INSERT 0 0"), nil
+		}
+		db.certificates[certID] = common.SigningCertificate{TenantID: certID,
+			KmsKeyID: args[1].(string), Certificate: args[2].([]byte)}
+		return pgconn.NewCommandTag("INSERT 0 1"), nil
+
+	case strings.HasPrefix(sql, "INSERT INTO records"):
+		record := common.Record{Kind: args[0].(string),
+			Scope: args[1].(string), ID: args[2].(string),
+			Data: args[3].([]byte)}
+		if expiresAt := args[4].(*time.Time); expiresAt != nil {
+			record.ExpiresAt = expiresAt.Truncate(time.Microsecond)
+		}
+		key := fakeRecordKey{record.Kind, record.Scope, record.ID}
+
+		// Unexpired records are only replaced if the statement does so
+		// unconditionally.
+		existing, ok := db.records[key]
+		if ok && strings.Contains(sql, "WHERE records.expires_at IS NOT NULL") &&
+			fakeUnexpired(existing, args[5].(time.Time)) {
+			return pgconn.NewCommandTag("INSERT 0 0"), nil
+		}
+		db.records[key] = record
+		return pgconn.NewCommandTag("INSERT 0 1"), nil
+
+	case sql == "DELETE FROM signing_certificates WHERE cert_id = $1":
+		delete(db.certificates, args[0].(string))
+		return pgconn.NewCommandTag("DELETE 1"), nil
+
+	case sql == "DELETE FROM records WHERE kind = $1 AND scope = $2 AND id = $3":
+		delete(db.records, fakeRecordKey{args[0].(string), args[1].(string),
+			args[2].(string)})
+		return pgconn.NewCommandTag("DELETE 1"), nil
+
+	case sql == "DELETE FROM records WHERE expires_at <= $1":
+		purged := 0
+		for key, record := range db.records {
+			if !fakeUnexpired(record, args[0].(time.Time)) {
+				delete(db.records, key)
+				purged++
+			}
+		}
+		return pgconn.NewCommandTag(fmt.Sprintf("DELETE %d", purged)), nil
+
+	case sql == "DELETE FROM records WHERE kind = $1 AND scope = $2 AND expires_at <= $3":
+		for key, record := range db.records {
+			if (key.kind == args[0]) && (key.scope == args[1]) &&
+				!fakeUnexpired(record, args[2].(time.Time)) {
+				delete(db.records, key)
+			}
+		}
+		return pgconn.NewCommandTag("DELETE 1"), nil
+	}
+	return pgconn.CommandTag{}, fmt.Errorf("unsupported statement: %s", sql)
+}
+
+// query - return the rows selected by the specified query.
+func (db *fakeDatabase) query(sql string, args []any) ([][]any, error) {
+	sql = strings.Join(strings.Fields(sql), " ")
+	if strings.HasSuffix(sql, "FROM schema_migrations") {
+		if !db.migrationsCreated {
+			return nil, &pgconn.PgError{Code: "42P01"}
+		}
+		version := 0
+		for v := range db.versions {
+			version = max(version, v)
+		}
+		return [][]any{{version}}, nil
+	}
+
+	if !db.tablesCreated {
+		return nil, &pgconn.PgError{Code: "42P01"}
+	}
+	var rows [][]any
+	switch {
+	case sql == "SELECT kms_key_id, certificate FROM signing_certificates WHERE cert_id = $1":
+		if entry, ok := db.certificates[args[0].(string)]; ok {
+			rows = append(rows, []any{entry.KmsKeyID, entry.Certificate})
+		}
+
+	case sql == "SELECT cert_id, kms_key_id, certificate FROM signing_certificates ORDER BY cert_id":
+		for _, certID := range slices.Sorted(maps.Keys(db.certificates)) {
+			entry := db.certificates[certID]
+			rows = append(rows, []any{certID, entry.KmsKeyID, entry.Certificate})
+		}
+
+	case strings.HasPrefix(sql, "SELECT data, expires_at FROM records"):
+		record, ok := db.records[fakeRecordKey{args[0].(string),
+			args[1].(string), args[2].(string)}]
+		if ok && fakeUnexpired(record, args[3].(time.Time)) {
+			rows = append(rows, []any{record.Data, fakeExpiry(record)})
+		}
+
+	case sql == "SELECT COUNT(*) FROM records":
+		rows = append(rows, []any{len(db.records)})
+
+	case strings.HasPrefix(sql, "SELECT COUNT(*) FROM records"):
+		count := 0
+		for key, record := range db.records {
+			if (key.kind == args[0]) && (key.scope == args[1]) &&
+				fakeUnexpired(record, args[2].(time.Time)) {
+				count++
+			}
+		}
+		rows = append(rows, []any{count})
+
+	case strings.HasPrefix(sql, "SELECT id, data, expires_at FROM records"):
+		for _, record := range db.sortedRecords() {
+			if (record.Kind == args[0]) && (record.Scope == args[1]) {
+				rows = append(rows, []any{record.ID, record.Data,
+					fakeExpiry(record)})
+			}
+		}
+
+	case strings.HasPrefix(sql, "SELECT kind, scope, id, data, expires_at FROM records"):
+		for _, record := range db.sortedRecords() {
+			if fakeUnexpired(record, args[0].(time.Time)) {
+				rows = append(rows, []any{record.Kind, record.Scope, record.ID,
+					record.Data, fakeExpiry(record)})
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported query: %s", sql)
+	}
+	return rows, nil
+}
+
+// Returns the records ordered by their kind, scope and ID.
+func (db *fakeDatabase) sortedRecords() []common.Record {
+	records := slices.Collect(maps.Values(db.records))
+	slices.SortFunc(records, func(a, b common.Record) int {
+		return cmp.Or(cmp.Compare(a.Kind, b.Kind),
+			cmp.Compare(a.Scope, b.Scope), cmp.Compare(a.ID, b.ID))
+	})
+	return records
+}
+
+// Initialize a PostgreSQL certificate store. The store uses the test database
+// specified using CA_POSTGRES_TEST_DSN, if any, else the specified fake
+// database.
+func newTestProvider(t *testing.T, pool *fakePostgresPool) *PostgresProvider {
+	dsn := os.Getenv(testDSNVariable)
+	if dsn == "" {
+		caLogger = zap.NewNop()
+		provider := &PostgresProvider{}
+		err := provider.initProvider(pool, &Settings{QueryTimeoutSeconds: 10})
+		if err != nil {
+			t.Fatalf("Failed to initialize the PostgreSQL certificate store: %v", err)
+		}
+		t.Cleanup(provider.Shutdown)
+		return provider
+	}
+
+	saved := settings
+	settings.DSN = dsn
+	t.Cleanup(func() { settings = saved })
+
+	provider := &PostgresProvider{}
+	if err := provider.Init(zap.NewNop()); err != nil {
+		t.Fatalf("Failed to initialize the PostgreSQL certificate store: %v", err)
+	}
+	t.Cleanup(func() {
+		_, err := provider.pool.Exec(context.Background(), `DROP TABLE IF EXISTS
+			schema_migrations, signing_certificates, records,
+			issued_certificates, revoked_certificates`)
+		if err != nil {
+			t.Errorf("Failed to drop the certificate store tables: %v", err)
+		}
+		provider.Shutdown()
+	})
+	return provider
+}
+
+// Skip tests that depend on the behaviour of the fake database when running
+// against a test database.
+func skipIfTestDatabase(t *testing.T) {
+	if os.Getenv(testDSNVariable) != "" {
+		t.Skipf("%s set, skipping test using the fake database", testDSNVariable)
+	}
+}
+
+func TestNewPoolConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		valid    bool
+	}{
+		{"missing DSN", Settings{QueryTimeoutSeconds: 10}, false},
+		{"invalid DSN", Settings{DSN: "postgres://:invalid", QueryTimeoutSeconds: 10}, false},
+		{"negative pool size", Settings{DSN: "postgres://localhost/ca",
+			MaxConns: -1, QueryTimeoutSeconds: 10}, false},
+		{"min exceeds max", Settings{DSN: "postgres://localhost/ca",
+			MaxConns: 2, MinConns: 4, QueryTimeoutSeconds: 10}, false},
+		{"missing query timeout", Settings{DSN: "postgres://localhost/ca"}, false},
+		{"valid", Settings{DSN: "postgres://localhost/ca", MaxConns: 8,
+			MinConns: 2, MaxConnLifetimeMinutes: 30, QueryTimeoutSeconds: 10}, true},
+	}
+
+	for _, test := range tests {
+		poolConfig, err := newPoolConfig(&test.settings)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: newPoolConfig() error = %v, expected valid = %v",
+				test.name, err, test.valid)
+			continue
+		}
+		if test.valid && ((poolConfig.MaxConns != 8) || (poolConfig.MinConns != 2) ||
+			(poolConfig.MaxConnLifetime != 30*time.Minute)) {
+			t.Errorf("%s: connection pool settings were not applied", test.name)
+		}
+	}
+}
+
+func TestPostgresProvider_Certificates(t *testing.T) {
+	provider := newTestProvider(t, newFakePostgresPool())
+
+	entry := &common.SigningCertificate{
+		TenantID:    "tenant-1",
+		KmsKeyID:    "key-1",
+		Certificate: []byte("certificate-1"),
+	}
+	if err := provider.AddCertificate(context.Background(), entry); err != nil {
+		t.Fatalf("Failed to add the certificate: %v", err)
+	}
+
+	// Existing certificates are never replaced.
+	replacement := &common.SigningCertificate{
+		TenantID:    entry.TenantID,
+		KmsKeyID:    "key-2",
+		Certificate: []byte("certificate-2"),
+	}
+	err := provider.AddCertificate(context.Background(), replacement)
+	if !errors.Is(err, common.ErrTenantExists) {
+		t.Fatalf("Expected the certificate to exist, got %v", err)
+	}
+
+	stored, err := provider.GetCertificate(context.Background(), entry.TenantID)
+	if err != nil {
+		t.Fatalf("Failed to get the certificate: %v", err)
+	}
+	if (stored.KmsKeyID != "key-1") ||
+		!bytes.Equal(stored.Certificate, entry.Certificate) {
+		t.Errorf("Unexpected certificate returned: %+v", stored)
+	}
+
+	if err = provider.DeleteCertificate(context.Background(), entry.TenantID); err != nil {
+		t.Fatalf("Failed to delete the certificate: %v", err)
+	}
+	_, err = provider.GetCertificate(context.Background(), entry.TenantID)
+	if !errors.Is(err, common.ErrCertStoreNotFound) {
+		t.Errorf("Expected the certificate to be deleted, got %v", err)
+	}
+}
+
+func TestPostgresProvider_Records(t *testing.T) {
+	provider := newTestProvider(t, newFakePostgresPool())
+
+	record := &common.Record{Kind: common.RecordKindDevice, Scope: "tenant-1",
+		ID: "device-1", Data: []byte("data")}
+	if err := provider.AddRecord(context.Background(), record); err != nil {
+		t.Fatalf("Failed to add the record: %v", err)
+	}
+	if err := provider.AddRecord(context.Background(), record); !errors.Is(err, common.ErrRecordExists) {
+		t.Errorf("Expected the duplicate record to be rejected, got %v", err)
+	}
+
+	// Expired records are not returned, and are replaced by AddRecord.
+	expired := &common.Record{Kind: common.RecordKindDevice, Scope: "tenant-1",
+		ID: "device-2", Data: []byte("expired"),
+		ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := provider.PutRecord(context.Background(), expired); err != nil {
+		t.Fatalf("Failed to put the record: %v", err)
+	}
+	_, err := provider.GetRecord(context.Background(), expired.Kind, expired.Scope, expired.ID)
+	if !errors.Is(err, common.ErrRecordNotFound) {
+		t.Errorf("Expected the expired record to not be found, got %v", err)
+	}
+	count, err := provider.CountRecords(context.Background(), common.RecordKindDevice, "tenant-1")
+	if (err != nil) || (count != 1) {
+		t.Errorf("Expected 1 record, got %d (error: %v)", count, err)
+	}
+
+	replacement := *expired
+	replacement.Data = []byte("replacement")
+	replacement.ExpiresAt = time.Now().Add(time.Hour)
+	if err = provider.AddRecord(context.Background(), &replacement); err != nil {
+		t.Fatalf("Failed to replace the expired record: %v", err)
+	}
+	stored, err := provider.GetRecord(context.Background(), expired.Kind, expired.Scope, expired.ID)
+	if err != nil {
+		t.Fatalf("Failed to get the record: %v", err)
+	}
+	if !bytes.Equal(stored.Data, replacement.Data) ||
+		!stored.ExpiresAt.Equal(replacement.ExpiresAt.Truncate(time.Microsecond)) {
+		t.Errorf("Unexpected record returned: %+v", stored)
+	}
+
+	records, err := provider.ListRecords(context.Background(), common.RecordKindDevice, "tenant-1")
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if (len(records) != 2) || (records[0].ID != "device-1") ||
+		(records[1].ID != "device-2") {
+		t.Errorf("Unexpected records listed: %+v", records)
+	}
+
+	if err = provider.DeleteRecord(context.Background(), record.Kind, record.Scope, record.ID); err != nil {
+		t.Fatalf("Failed to delete the record: %v", err)
+	}
+	_, err = provider.GetRecord(context.Background(), record.Kind, record.Scope, record.ID)
+	if !errors.Is(err, common.ErrRecordNotFound) {
+		t.Errorf("Expected the record to be deleted, got %v", err)
+	}
+}
+
+func TestPostgresProvider_Migrations(t *testing.T) {
+	provider := newTestProvider(t, newFakePostgresPool())
+
+	// Migrations which have already been applied are not applied again.
+	ctx := context.Background()
+	if err := provider.migrate(ctx); err != nil {
+		t.Fatalf("Failed to re-apply migrations: %v", err)
+	}
+	var version int
+	err := provider.pool.QueryRow(ctx,
+		"SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if (err != nil) || (version != len(migrations)) {
+		t.Errorf("Expected schema version %d, got %d (error: %v)",
+			len(migrations), version, err)
+	}
+
+	// A database schema newer than the CA is rejected.
+	_, err = provider.pool.Exec(ctx,
+		"INSERT INTO schema_migrations (version) VALUES ($1)", len(migrations)+1)
+	if err != nil {
+		t.Fatalf("Failed to bump the schema version: %v", err)
+	}
+	if err = provider.migrate(ctx); err == nil {
+		t.Errorf("Expected a newer schema version to be rejected")
+	}
+}
+
+func TestPostgresProvider_Enumerate(t *testing.T) {
+	ctx := context.Background()
+	provider := newTestProvider(t, newFakePostgresPool())
+
+	for _, certID := range []string{"tenant-2", "tenant-1"} {
+		err := provider.AddCertificate(ctx, &common.SigningCertificate{
+			TenantID: certID, KmsKeyID: "key", Certificate: []byte(certID)})
+		if err != nil {
+			t.Fatalf("Failed to add the certificate: %v", err)
+		}
+	}
+	for _, record := range []*common.Record{
+		{Kind: common.RecordKindDevice, Scope: "tenant-1", ID: "device-2",
+			Data: []byte("data")},
+		{Kind: common.RecordKindDevice, Scope: "tenant-1", ID: "device-1",
+			Data: []byte("data"), ExpiresAt: time.Now().Add(time.Hour)},
+		{Kind: common.RecordKindDevice, Scope: "tenant-1", ID: "device-3",
+			Data: []byte("expired"), ExpiresAt: time.Now().Add(-time.Minute)},
+	} {
+		if err := provider.PutRecord(ctx, record); err != nil {
+			t.Fatalf("Failed to put the record: %v", err)
+		}
+	}
+
+	// Signing certificates and unexpired records are enumerated in order.
+	var certIDs, recordIDs []string
+	err := provider.Enumerate(func(entry *common.SigningCertificate) error {
+		certIDs = append(certIDs, entry.TenantID)
+		return nil
+	}, func(record *common.Record) error {
+		recordIDs = append(recordIDs, record.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to enumerate the store: %v", err)
+	}
+	if !slices.Equal(certIDs, []string{"tenant-1", "tenant-2"}) ||
+		!slices.Equal(recordIDs, []string{"device-1", "device-2"}) {
+		t.Errorf("Unexpected contents enumerated: %v, %v", certIDs, recordIDs)
+	}
+}
+
+func TestPostgresProvider_Unavailable(t *testing.T) {
+	skipIfTestDatabase(t)
+	pool := newFakePostgresPool()
+
+	// The store fails to initialize while the database is unavailable.
+	pool.failNext = &pgconn.PgError{Code: "57P03"}
+	caLogger = zap.NewNop()
+	err := (&PostgresProvider{}).initProvider(pool,
+		&Settings{QueryTimeoutSeconds: 10})
+	if !caerrors.Is(err, caerrors.DependencyUnavailable) {
+		t.Errorf("Expected a dependency unavailable error, got %v", err)
+	}
+
+	provider := newTestProvider(t, pool)
+	pool.lock.Lock()
+	pool.failNext = errors.New("connection reset by peer")
+	pool.lock.Unlock()
+	_, err = provider.GetCertificate(context.Background(), "tenant-1")
+	if !caerrors.Is(err, caerrors.DependencyUnavailable) {
+		t.Errorf("Expected a dependency unavailable error, got %v", err)
+	}
+}
+
+func TestPostgresProvider_MigrationFailure(t *testing.T) {
+	skipIfTestDatabase(t)
+	pool := newFakePostgresPool()
+
+	// Migrations are applied within a single transaction, so a failed
+	// migration leaves the schema unchanged.
+	pool.db.tablesCreated = true
+	caLogger = zap.NewNop()
+	err := (&PostgresProvider{}).initProvider(pool,
+		&Settings{QueryTimeoutSeconds: 10})
+	if !caerrors.Is(err, caerrors.Internal) {
+		t.Errorf("Expected the migration to fail, got %v", err)
+	}
+	if pool.db.migrationsCreated || (len(pool.db.versions) != 0) {
+		t.Errorf("Expected the failed migration to be rolled back")
+	}
+}
+
+func TestPostgresProvider_PurgeExpiredRecords(t *testing.T) {
+	ctx := context.Background()
+	provider := newTestProvider(t, newFakePostgresPool())
+
+	now := time.Now()
+	for _, record := range []*common.Record{
+		{Kind: common.RecordKindDevice, Scope: "tenant-1", ID: "expired",
+			ExpiresAt: now.Add(-time.Minute)},
+		{Kind: common.RecordKindDevice, Scope: "tenant-1", ID: "live",
+			ExpiresAt: now.Add(time.Hour)},
+		{Kind: common.RecordKindDevice, Scope: "tenant-2", ID: "expired",
+			ExpiresAt: now.Add(-time.Minute)},
+		{Kind: common.RecordKindTenantQuota, ID: "tenant-1"},
+	} {
+		if err := provider.PutRecord(ctx, record); err != nil {
+			t.Fatalf("Failed to put the record: %v", err)
+		}
+	}
+
+	// Expired records are purged across all kinds and scopes.
+	purged, err := provider.purgeExpiredRecords(ctx)
+	if (err != nil) || (purged != 2) {
+		t.Fatalf("Expected 2 records to be purged, got %d, %v", purged, err)
+	}
+	var count int
+	err = provider.pool.QueryRow(ctx, "SELECT COUNT(*) FROM records").Scan(&count)
+	if (err != nil) || (count != 2) {
+		t.Errorf("Expected 2 records to be retained, got %d, %v", count, err)
+	}
+}
+
+func TestWrapPostgresError(t *testing.T) {
+	tests := []struct {
+		err      error
+		category caerrors.Category
+	}{
+		{&pgconn.PgError{Code: "23505"}, caerrors.Conflict},
+		{&pgconn.PgError{Code: "40001"}, caerrors.DependencyUnavailable},
+		{&pgconn.PgError{Code: "08006"}, caerrors.DependencyUnavailable},
+		{&pgconn.PgError{Code: "53300"}, caerrors.DependencyUnavailable},
+		{&pgconn.PgError{Code: "57P01"}, caerrors.DependencyUnavailable},
+		{&pgconn.PgError{Code: "42P01"}, caerrors.Internal},
+		{errors.New("connection refused"), caerrors.DependencyUnavailable},
+	}
+
+	for _, test := range tests {
+		err := wrapPostgresError("postgres query failed", test.err)
+		if category := caerrors.CategoryOf(err); category != test.category {
+			t.Errorf("wrapPostgresError(%v) category = %v, expected %v",
+				test.err, category, test.category)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/certstore/postgres
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the APIs used to manage records stored in the PostgreSQL
+// certificate store. Records are stored in the records table, keyed by their
+// kind, scope and ID. Records which never expire have a NULL expiry time.
+// Expired records are purged periodically while the store is open.
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// Returns the expiry time of the record as stored in the database.
+func recordExpiry(record *common.Record) *time.Time {
+	if record.ExpiresAt.IsZero() {
+		return nil
+	}
+	return &record.ExpiresAt
+}
+
+// AddRecord - Adds the specified record to the PostgreSQL certificate store, if
+// a record with the same key doesn't already exist. An existing record which
+// has expired is replaced.
+func (p *PostgresProvider) AddRecord(ctx context.Context, record *common.Record) error {
+	ctx, cancel := p.queryContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	result, err := p.pool.Exec(ctx, `INSERT INTO records
+		(kind, scope, id, data, expires_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (kind, scope, id) DO UPDATE SET
+			data = EXCLUDED.data,
+			expires_at = EXCLUDED.expires_at
+		WHERE records.expires_at IS NOT NULL AND records.expires_at <= $6`,
+		record.Kind, record.Scope, record.ID, record.Data,
+		recordExpiry(record), time.Now())
+	metrics.ReportLatencyMetric(metrics.MetricPostgresRequestLatency, start,
+		postgresOpAddRecord)
+	if err != nil {
+		caLogger.Error("Failed to add the record to the store!",
+			zap.String("Kind:", record.Kind),
+			zap.String("Record ID:", record.ID),
+			zap.Error(err),
+		)
+		metrics.MetricPostgresQueryFailures.Inc()
+		return wrapPostgresError(
+			"failed to add the record to the store", err)
+	}
+
+	// No row is affected if an unexpired record with the same key exists.
+	if result.RowsAffected() == 0 {
+		return common.ErrRecordExists
+	}
+	return nil
+}
+
+// PutRecord - Adds or replaces the specified record in the PostgreSQL
+// certificate store.
+func (p *PostgresProvider) PutRecord(ctx context.Context, record *common.Record) error {
+	ctx, cancel := p.queryContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	_, err := p.pool.Exec(ctx, `INSERT INTO records
+		(kind, scope, id, data, expires_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (kind, scope, id) DO UPDATE SET
+			data = EXCLUDED.data,
+			expires_at = EXCLUDED.expires_at`,
+		record.Kind, record.Scope, record.ID, record.Data,
+		recordExpiry(record))
+	metrics.ReportLatencyMetric(metrics.MetricPostgresRequestLatency, start,
+		postgresOpPutRecord)
+	if err != nil {
+		caLogger.Error("Failed to put the record into the store!",
+			zap.String("Kind:", record.Kind),
+			zap.String("Record ID:", record.ID),
+			zap.Error(err),
+		)
+		metrics.MetricPostgresQueryFailures.Inc()
+		return wrapPostgresError(
+			"failed to put the record into the store", err)
+	}
+	return nil
+}
+
+// GetRecord - Returns the specified record from the PostgreSQL certificate
+// store.
+func (p *PostgresProvider) GetRecord(ctx context.Context, kind string,
+	scope string, id string) (*common.Record, error) {
+	ctx, cancel := p.queryContext(ctx)
+	defer cancel()
+
+	record := common.Record{Kind: kind, Scope: scope, ID: id}
+	var expiresAt *time.Time
+
+	start := time.Now()
+	err := p.pool.QueryRow(ctx, `SELECT data, expires_at FROM records
+		WHERE kind = $1 AND scope = $2 AND id = $3
+		AND (expires_at IS NULL OR expires_at > $4)`,
+		kind, scope, id, time.Now()).Scan(&record.Data, &expiresAt)
+	metrics.ReportLatencyMetric(metrics.MetricPostgresRequestLatency, start,
+		postgresOpGetRecord)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, common.ErrRecordNotFound
+		}
+		caLogger.Error("Failed to get the record from the store!",
+			zap.String("Kind:", kind),
+			zap.String("Record ID:", id),
+			zap.Error(err),
+		)
+		metrics.MetricPostgresQueryFailures.Inc()
+		return nil, wrapPostgresError(
+			"failed to get the record from the store", err)
+	}
+	if expiresAt != nil {
+		record.ExpiresAt = *expiresAt
+	}
+	return &record, nil
+}
+
+// DeleteRecord - Removes the specified record from the PostgreSQL certificate
+// store.
+func (p *PostgresProvider) DeleteRecord(ctx context.Context, kind string,
+	scope string, id string) error {
+	ctx, cancel := p.queryContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	_, err := p.pool.Exec(ctx,
+		"DELETE FROM records WHERE kind = $1 AND scope = $2 AND id = $3",
+		kind, scope, id)
+	metrics.ReportLatencyMetric(metrics.MetricPostgresRequestLatency, start,
+		postgresOpDeleteRecord)
+	if err != nil {
+		caLogger.Error("Failed to remove the record from the store!",
+			zap.String("Kind:", kind),
+			zap.String("Record ID:", id),
+			zap.Error(err),
+		)
+		metrics.MetricPostgresQueryFailures.Inc()
+		return wrapPostgresError(
+			"failed to remove the record from the store", err)
+	}
+	return nil
+}
+
+// ListRecords - Returns all unexpired records of the specified kind within
+// the specified scope. Expired records within the scope are purged in the
+// same transaction.
+func (p *PostgresProvider) ListRecords(ctx context.Context, kind string,
+	scope string) ([]*common.Record, error) {
+	var records []*common.Record
+
+	ctx, cancel := p.queryContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		now := time.Now()
+		_, err := tx.Exec(ctx, `DELETE FROM records
+			WHERE kind = $1 AND scope = $2 AND expires_at <= $3`,
+			kind, scope, now)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(ctx, `SELECT id, data, expires_at FROM records
+			WHERE kind = $1 AND scope = $2 ORDER BY id`, kind, scope)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			record := &common.Record{Kind: kind, Scope: scope}
+			var expiresAt *time.Time
+			err = rows.Scan(&record.ID, &record.Data, &expiresAt)
+			if err != nil {
+				return err
+			}
+			if expiresAt != nil {
+				record.ExpiresAt = *expiresAt
+			}
+			records = append(records, record)
+		}
+		return rows.Err()
+	})
+	metrics.ReportLatencyMetric(metrics.MetricPostgresRequestLatency, start,
+		postgresOpListRecords)
+	if err != nil {
+		caLogger.Error("Failed to list records in the store!",
+			zap.String("Kind:", kind),
+			zap.String("Scope:", scope),
+			zap.Error(err),
+		)
+		metrics.MetricPostgresQueryFailures.Inc()
+		return nil, wrapPostgresError(
+			"failed to list records in the store", err)
+	}
+	return records, nil
+}
+
+// CountRecords - Returns the number of unexpired records of the specified
+// kind within the specified scope.
+func (p *PostgresProvider) CountRecords(ctx context.Context, kind string,
+	scope string) (int, error) {
+	ctx, cancel := p.queryContext(ctx)
+	defer cancel()
+
+	var count int
+	start := time.Now()
+	err := p.pool.QueryRow(ctx, `SELECT COUNT(*) FROM records
+		WHERE kind = $1 AND scope = $2
+		AND (expires_at IS NULL OR expires_at > $3)`,
+		kind, scope, time.Now()).Scan(&count)
+	metrics.ReportLatencyMetric(metrics.MetricPostgresRequestLatency, start,
+		postgresOpCountRecords)
+	if err != nil {
+		caLogger.Error("Failed to count records in the store!",
+			zap.String("Kind:", kind),
+			zap.String("Scope:", scope),
+			zap.Error(err),
+		)
+		metrics.MetricPostgresQueryFailures.Inc()
+		return 0, wrapPostgresError(
+			"failed to count records in the store", err)
+	}
+	return count, nil
+}
+
+// purgeExpiredRecords - Removes all expired records from the PostgreSQL
+// certificate store, and returns the number of records removed.
+func (p *PostgresProvider) purgeExpiredRecords(ctx context.Context) (int64, error) {
+	ctx, cancel := p.queryContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	tag, err := p.pool.Exec(ctx,
+		"DELETE FROM records WHERE expires_at <= $1", time.Now())
+	metrics.ReportLatencyMetric(metrics.MetricPostgresRequestLatency, start,
+		postgresOpPurgeRecords)
+	if err != nil {
+		caLogger.Error("Failed to purge expired records from the store!",
+			zap.Error(err),
+		)
+		metrics.MetricPostgresQueryFailures.Inc()
+		return 0, wrapPostgresError(
+			"failed to purge expired records from the store", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// sweepExpiredRecords - Purges expired records from the store each time the
+// sweep interval elapses, until the store is shut down. Each instance of the
+// CA sharing the database sweeps it, which is harmless since the sweep only
+// removes records which have expired.
+func (p *PostgresProvider) sweepExpiredRecords() {
+	defer close(p.sweepDone)
+
+	ticker := time.NewTicker(recordSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Failures are logged, and the next sweep is attempted as scheduled.
+		purged, err := p.purgeExpiredRecords(p.ctx)
+		if (err == nil) && (purged > 0) {
+			caLogger.Info("Purged expired records from the store.",
+				zap.Int64("Records purged:", purged),
+			)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/certstore/postgres
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the APIs used to manage the lifetime of certificates stored in the
+// PostgreSQL certificate store.
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// Certificate store operation names
+const (
+	postgresOpAddCertificate    = "AddCertificate"
+	postgresOpGetCertificate    = "GetCertificate"
+	postgresOpDeleteCertificate = "DeleteCertificate"
+	postgresOpAddRecord         = "AddRecord"
+	postgresOpPutRecord         = "PutRecord"
+	postgresOpGetRecord         = "GetRecord"
+	postgresOpDeleteRecord      = "DeleteRecord"
+	postgresOpListRecords       = "ListRecords"
+	postgresOpCountRecords      = "CountRecords"
+	postgresOpEnumerate         = "Enumerate"
+	postgresOpPurgeRecords      = "PurgeRecords"
+)
+
+// AddCertificate - Adds the specified signing certificate to the PostgreSQL
+// certificate store, if a signing certificate with the same ID doesn't
+// already exist.
+func (p *PostgresProvider) AddCertificate(ctx context.Context,
+	entry *common.SigningCertificate) error {
+	ctx, cancel := p.queryContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	tag, err := p.pool.Exec(ctx, `INSERT INTO signing_certificates
+		(cert_id, kms_key_id, certificate) VALUES ($1, $2, $3)
+		ON CONFLICT (cert_id) DO NOTHING`,
+		entry.TenantID, entry.KmsKeyID, entry.Certificate)
+	metrics.ReportLatencyMetric(metrics.MetricPostgresRequestLatency, start,
+		postgresOpAddCertificate)
+	if err != nil {
+		caLogger.Error("Failed to add the certificate to the store!",
+			zap.String("Tenant ID:", entry.TenantID),
+			zap.Error(err),
+		)
+		metrics.MetricPostgresQueryFailures.Inc()
+		return wrapPostgresError(
+			"failed to add the signing certificate to the store", err)
+	}
+	if tag.RowsAffected() == 0 {
+		caLogger.Error("A signing certificate with the same ID already exists in the store!",
+			zap.String("Tenant ID:", entry.TenantID),
+		)
+		return common.ErrTenantExists
+	}
+
+	caLogger.Debug("Added the certificate to the store!",
+		zap.String("Tenant ID:", entry.TenantID),
+	)
+	return nil
+}
+
+// GetCertificate - Returns the signing certificate for the specified ID
+// from the PostgreSQL certificate store.
+func (p *PostgresProvider) GetCertificate(ctx context.Context,
+	certID string) (*common.SigningCertificate, error) {
+	ctx, cancel := p.queryContext(ctx)
+	defer cancel()
+
+	entry := common.SigningCertificate{TenantID: certID}
+	start := time.Now()
+	err := p.pool.QueryRow(ctx, `SELECT kms_key_id, certificate
+		FROM signing_certificates WHERE cert_id = $1`,
+		certID).Scan(&entry.KmsKeyID, &entry.Certificate)
+	metrics.ReportLatencyMetric(metrics.MetricPostgresRequestLatency, start,
+		postgresOpGetCertificate)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			caLogger.Error("Specified signing certificate was not found in the store!",
+				zap.String("Certificate ID:", certID),
+			)
+			return nil, common.ErrCertStoreNotFound
+		}
+		caLogger.Error("Failed to get the signing certificate from the store!",
+			zap.String("Certificate ID:", certID),
+			zap.Error(err),
+		)
+		metrics.MetricPostgresQueryFailures.Inc()
+		return nil, wrapPostgresError(
+			"failed to get the signing certificate from the store", err)
+	}
+	return &entry, nil
+}
+
+// DeleteCertificate - Removes the specified signing certificate
+// from the PostgreSQL certificate store.
+func (p *PostgresProvider) DeleteCertificate(ctx context.Context, certID string) error {
+	ctx, cancel := p.queryContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	_, err := p.pool.Exec(ctx,
+		"DELETE FROM signing_certificates WHERE cert_id = $1", certID)
+	metrics.ReportLatencyMetric(metrics.MetricPostgresRequestLatency, start,
+		postgresOpDeleteCertificate)
+	if err != nil {
+		caLogger.Error("Failed to remove the signing certificate from the store!",
+			zap.String("Certificate ID:", certID),
+			zap.Error(err),
+		)
+		metrics.MetricPostgresQueryFailures.Inc()
+		return wrapPostgresError(
+			"failed to remove the signing certificate from the store", err)
+	}
+	caLogger.Debug("Successfully removed the signing certificate from the store!",
+		zap.String("Certificate ID:", certID),
+	)
+	return nil
+}
@@ -0,0 +1,242 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/idempotency
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements idempotent issuance of device certificates. Create device
+// certificate requests are keyed by the tenant and the request ID specified by
+// the caller. The caller's network address is not part of the key, so that
+// retries from a different address (eg. after reconnecting through a NAT or
+// load balancer) are recognized. The certificate issued in response to a request is
+// retained for the configured window, and returned if the request is retried,
+// so that retries do not result in a second device being enrolled.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"go.uber.org/zap"
+)
+
+var (
+	caLogger *zap.Logger
+
+	// ErrRequestMismatch is returned when a request ID is reused for a
+	// request specifying a different certificate signing request.
+	ErrRequestMismatch = caerrors.New(caerrors.Conflict,
+		"request ID was already used for a different request")
+
+	// ErrRequestInProgress is returned when a request with the same request
+	// ID is still being processed.
+	ErrRequestInProgress = caerrors.New(caerrors.Conflict,
+		"a request with the same request ID is being processed")
+)
+
+// Duration for which a request is reserved while the device certificate is
+// being issued. If the CA fails to complete the request within this time (eg.
+// it is restarted), retries of the request are processed afresh.
+const pendingRequestTimeout = time.Minute
+
+// IssuedCertificate - the device certificate issued in response to a create
+// device certificate request.
+type IssuedCertificate struct {
+	// The device ID assigned to the device.
+	DeviceID string `json:"device_id"`
+
+	// The issued device certificate.
+	DeviceCertificate []byte `json:"device_certificate"`
+
+	// The certificate chain of the issued device certificate.
+	ParentCertificates []byte `json:"parent_certificates"`
+
+	// Time at which the device certificate was issued.
+	IssuedAt time.Time `json:"issued_at"`
+
+	// Time at which the device certificate expires.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Request state persisted in the certificate store for each request ID.
+type requestEntry struct {
+	// Hash of the certificate signing request specified in the request.
+	CsrHash string `json:"csr_hash"`
+
+	// The issued device certificate. This is nil while the request is being
+	// processed.
+	Response *IssuedCertificate `json:"response,omitempty"`
+}
+
+// Manager - detects retried create device certificate requests and returns
+// the previously issued device certificate.
+type Manager struct {
+	// Certificate store used to persist the state of requests.
+	store certstore.CertStore
+
+	// Idempotency settings from the configuration file.
+	settings *config.Idempotency
+}
+
+// NewManager - initialize a new idempotency manager using the specified
+// certificate store and idempotency settings.
+func NewManager(logger *zap.Logger, store certstore.CertStore,
+	settings *config.Idempotency) *Manager {
+	caLogger = logger
+	return &Manager{
+		store:    store,
+		settings: settings,
+	}
+}
+
+func hashCsr(csr []byte) string {
+	hash := sha256.Sum256(csr)
+	return hex.EncodeToString(hash[:])
+}
+
+// Begin - checks whether the specified request is a retry of a previously
+// completed request. If so, the previously issued device certificate is
+// returned. Otherwise, the request ID is reserved and nil is returned - the
+// caller must then invoke Complete or Abandon once the request is processed.
+// Requests without a request ID are not tracked.
+func (m *Manager) Begin(ctx context.Context, tenantID string,
+	requestID string, csr []byte) (*IssuedCertificate, error) {
+	if !m.settings.Enabled || (requestID == "") {
+		return nil, nil
+	}
+
+	csrHash := hashCsr(csr)
+	data, err := json.Marshal(&requestEntry{CsrHash: csrHash})
+	if err != nil {
+		return nil, err
+	}
+
+	// Requests are stored within the scope of the tenant and identified by
+	// the request ID.
+	err = m.store.AddRecord(ctx, &common.Record{
+		Kind:      common.RecordKindIssuanceRequest,
+		Scope:     tenantID,
+		ID:        requestID,
+		Data:      data,
+		ExpiresAt: time.Now().Add(pendingRequestTimeout),
+	})
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, common.ErrRecordExists) {
+		caLogger.Error("Failed to reserve the request ID!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	// A request with the same request ID was received earlier.
+	record, err := m.store.GetRecord(ctx, common.RecordKindIssuanceRequest,
+		tenantID, requestID)
+	if err != nil {
+		if errors.Is(err, common.ErrRecordNotFound) {
+			// The earlier request was abandoned after the reservation was
+			// attempted. Have the caller retry the request.
+			return nil, ErrRequestInProgress
+		}
+		caLogger.Error("Failed to retrieve the state of the request!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	var entry requestEntry
+	err = json.Unmarshal(record.Data, &entry)
+	if err != nil {
+		caLogger.Error("Failed to decode the state of the request!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	if entry.CsrHash != csrHash {
+		caLogger.Error("Request ID was reused with a different CSR!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+		)
+		return nil, ErrRequestMismatch
+	}
+	if entry.Response == nil {
+		return nil, ErrRequestInProgress
+	}
+
+	caLogger.Info("Returning the device certificate issued for the retried request.",
+		zap.String("Tenant ID:", tenantID),
+		zap.String("Request ID:", requestID),
+		zap.String("Device ID:", entry.Response.DeviceID),
+	)
+	return entry.Response, nil
+}
+
+// Complete - records the device certificate issued in response to the
+// request, so that it can be returned if the request is retried within the
+// configured window.
+func (m *Manager) Complete(ctx context.Context, tenantID string,
+	requestID string, csr []byte, issued *IssuedCertificate) error {
+	if !m.settings.Enabled || (requestID == "") {
+		return nil
+	}
+
+	data, err := json.Marshal(&requestEntry{
+		CsrHash:  hashCsr(csr),
+		Response: issued,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = m.store.PutRecord(ctx, &common.Record{
+		Kind:  common.RecordKindIssuanceRequest,
+		Scope: tenantID,
+		ID:    requestID,
+		Data:  data,
+		ExpiresAt: time.Now().Add(
+			time.Duration(m.settings.WindowSeconds) * time.Second),
+	})
+	if err != nil {
+		caLogger.Error("Failed to store the response to the request!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		return err
+	}
+	return nil
+}
+
+// Abandon - releases the request ID reserved for a request which failed, so
+// that the request can be retried.
+func (m *Manager) Abandon(ctx context.Context, tenantID string,
+	requestID string) {
+	if !m.settings.Enabled || (requestID == "") {
+		return
+	}
+
+	err := m.store.DeleteRecord(ctx, common.RecordKindIssuanceRequest, tenantID,
+		requestID)
+	if err != nil {
+		caLogger.Error("Failed to release the request ID!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+	}
+}
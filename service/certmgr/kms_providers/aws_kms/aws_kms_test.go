@@ -0,0 +1,556 @@
+package aws_kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/cache"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	_ "github.com/HPInc/krypton-ca/service/certmgr/certstore/localdb"
+	"github.com/HPInc/krypton-ca/service/certmgr/resilience"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	"github.com/google/uuid"
+	"go.mozilla.org/pkcs7"
+	"go.uber.org/zap"
+)
+
+// Path of the configuration file, resolved before the tests change the working
+// directory.
+var testConfigFile, _ = filepath.Abs("../../../config/config.yaml")
+
+const (
+	// Size of the keys created by the fake KMS client. Smaller than the keys
+	// created by AWS KMS, to keep the tests fast.
+	testKeySize = 2048
+
+	// Number of goroutines issuing requests concurrently, and the number of
+	// device certificates issued by each of them.
+	testWorkers    = 8
+	testIterations = 5
+)
+
+// A KMS client which counts requests for public keys.
+type countingKMSClient struct {
+	KMSClient
+	publicKey      []byte
+	publicKeyCalls int
+}
+
+func (c *countingKMSClient) GetPublicKey(context.Context, *kms.GetPublicKeyInput,
+	...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	c.publicKeyCalls++
+	return &kms.GetPublicKeyOutput{PublicKey: c.publicKey}, nil
+}
+
+func TestGetKMSSigner_Cached(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate a key: %v", err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal the public key: %v", err)
+	}
+
+	client := &countingKMSClient{publicKey: publicKey}
+	p := &AwsKmsProvider{
+		logger:    zap.NewNop(),
+		client:    client,
+		signers:   cache.New[string, *KMSSigner](awsKmsSignerCacheName, 10),
+		signerTTL: time.Minute,
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err = p.getKMSSigner(context.Background(), "key-1"); err != nil {
+			t.Fatalf("Failed to get the signer: %v", err)
+		}
+	}
+	if client.publicKeyCalls != 1 {
+		t.Errorf("Expected 1 public key request, got %d", client.publicKeyCalls)
+	}
+
+	// Signers are not cached if caching is disabled.
+	p.signers = nil
+	for i := 0; i < 2; i++ {
+		if _, err = p.getKMSSigner(context.Background(), "key-1"); err != nil {
+			t.Fatalf("Failed to get the signer: %v", err)
+		}
+	}
+	if client.publicKeyCalls != 3 {
+		t.Errorf("Expected 3 public key requests, got %d", client.publicKeyCalls)
+	}
+}
+
+// fakeKMSClient - an in-memory fake of the AWS KMS methods consumed by the
+// provider. Keys are identified using their key ID or their alias.
+type fakeKMSClient struct {
+	lock sync.Mutex
+
+	// Private keys keyed by key ID, and key IDs keyed by alias.
+	keys    map[string]*rsa.PrivateKey
+	aliases map[string]string
+}
+
+func newFakeKMSClient() *fakeKMSClient {
+	return &fakeKMSClient{
+		keys:    map[string]*rsa.PrivateKey{},
+		aliases: map[string]string{},
+	}
+}
+
+// Returns the key ID and private key for the specified key ID or alias.
+func (f *fakeKMSClient) findKey(keyID string) (string, *rsa.PrivateKey, error) {
+	if id, ok := f.aliases[keyID]; ok {
+		keyID = id
+	}
+	key, ok := f.keys[keyID]
+	if !ok {
+		return "", nil, &types.NotFoundException{Message: aws.String(keyID)}
+	}
+	return keyID, key, nil
+}
+
+func (f *fakeKMSClient) CreateKey(ctx context.Context, input *kms.CreateKeyInput,
+	opts ...func(*kms.Options)) (*kms.CreateKeyOutput, error) {
+	key, err := rsa.GenerateKey(rand.Reader, testKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	keyID := uuid.NewString()
+	f.keys[keyID] = key
+	return &kms.CreateKeyOutput{
+		KeyMetadata: &types.KeyMetadata{KeyId: aws.String(keyID)},
+	}, nil
+}
+
+func (f *fakeKMSClient) CreateAlias(ctx context.Context,
+	input *kms.CreateAliasInput,
+	opts ...func(*kms.Options)) (*kms.CreateAliasOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, ok := f.aliases[aws.ToString(input.AliasName)]; ok {
+		return nil, &types.AlreadyExistsException{}
+	}
+	f.aliases[aws.ToString(input.AliasName)] = aws.ToString(input.TargetKeyId)
+	return &kms.CreateAliasOutput{}, nil
+}
+
+func (f *fakeKMSClient) DeleteAlias(ctx context.Context,
+	input *kms.DeleteAliasInput,
+	opts ...func(*kms.Options)) (*kms.DeleteAliasOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.aliases, aws.ToString(input.AliasName))
+	return &kms.DeleteAliasOutput{}, nil
+}
+
+func (f *fakeKMSClient) DescribeKey(ctx context.Context,
+	input *kms.DescribeKeyInput,
+	opts ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	keyID, _, err := f.findKey(aws.ToString(input.KeyId))
+	if err != nil {
+		return nil, err
+	}
+	return &kms.DescribeKeyOutput{
+		KeyMetadata: &types.KeyMetadata{KeyId: aws.String(keyID)},
+	}, nil
+}
+
+func (f *fakeKMSClient) ListResourceTags(ctx context.Context,
+	input *kms.ListResourceTagsInput,
+	opts ...func(*kms.Options)) (*kms.ListResourceTagsOutput, error) {
+	return &kms.ListResourceTagsOutput{}, nil
+}
+
+func (f *fakeKMSClient) ScheduleKeyDeletion(ctx context.Context,
+	input *kms.ScheduleKeyDeletionInput,
+	opts ...func(*kms.Options)) (*kms.ScheduleKeyDeletionOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	keyID, _, err := f.findKey(aws.ToString(input.KeyId))
+	if err != nil {
+		return nil, err
+	}
+	delete(f.keys, keyID)
+	return &kms.ScheduleKeyDeletionOutput{KeyId: aws.String(keyID)}, nil
+}
+
+func (f *fakeKMSClient) GetPublicKey(ctx context.Context,
+	input *kms.GetPublicKeyInput,
+	opts ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	keyID, key, err := f.findKey(aws.ToString(input.KeyId))
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.GetPublicKeyOutput{
+		KeyId:     aws.String(keyID),
+		PublicKey: publicKey,
+	}, nil
+}
+
+func (f *fakeKMSClient) Sign(ctx context.Context, input *kms.SignInput,
+	opts ...func(*kms.Options)) (*kms.SignOutput, error) {
+	f.lock.Lock()
+	keyID, key, err := f.findKey(aws.ToString(input.KeyId))
+	f.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if input.SigningAlgorithm != types.SigningAlgorithmSpecRsassaPkcs1V15Sha256 {
+		return nil, fmt.Errorf("unsupported signing algorithm %s",
+			input.SigningAlgorithm)
+	}
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256,
+		input.Message)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.SignOutput{
+		KeyId:     aws.String(keyID),
+		Signature: signature,
+	}, nil
+}
+
+// A fake KMS client which, when blocked, does not respond to signing requests
+// until the request is abandoned.
+type blockingKMSClient struct {
+	*fakeKMSClient
+	blocked atomic.Bool
+}
+
+func (c *blockingKMSClient) Sign(ctx context.Context, input *kms.SignInput,
+	opts ...func(*kms.Options)) (*kms.SignOutput, error) {
+	if c.blocked.Load() {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return c.fakeKMSClient.Sign(ctx, input, opts...)
+}
+
+// A fake KMS client which throttles the specified number of signing requests.
+type throttlingKMSClient struct {
+	*fakeKMSClient
+	throttled atomic.Int32
+	signCalls atomic.Int32
+}
+
+func (c *throttlingKMSClient) Sign(ctx context.Context, input *kms.SignInput,
+	opts ...func(*kms.Options)) (*kms.SignOutput, error) {
+	c.signCalls.Add(1)
+	if c.throttled.Add(-1) >= 0 {
+		return nil, &smithy.GenericAPIError{Code: "ThrottlingException",
+			Message: "Rate exceeded"}
+	}
+	return c.fakeKMSClient.Sign(ctx, input, opts...)
+}
+
+// A fake KMS client which fails requests to create keys with a server side
+// fault, once failures are enabled.
+type failingCreateKeyKMSClient struct {
+	*fakeKMSClient
+	fail           atomic.Bool
+	createKeyCalls atomic.Int32
+}
+
+func (c *failingCreateKeyKMSClient) CreateKey(ctx context.Context,
+	input *kms.CreateKeyInput, opts ...func(*kms.Options)) (*kms.CreateKeyOutput, error) {
+	if !c.fail.Load() {
+		return c.fakeKMSClient.CreateKey(ctx, input, opts...)
+	}
+	c.createKeyCalls.Add(1)
+	return nil, &smithy.GenericAPIError{Code: "KMSInternalException",
+		Message: "Internal error", Fault: smithy.FaultServer}
+}
+
+// Initialize an AWS KMS provider in test mode using the specified fake KMS
+// client, with a certificate store in the specified directory.
+func newTestProvider(t *testing.T, dir string,
+	client KMSClient) (*AwsKmsProvider, certstore.CertStore) {
+	t.Setenv("DSTS_CONFIG_LOCATION", testConfigFile)
+	t.Setenv("CA_KMS_PROVIDER", common.KmsProviderAws)
+	t.Setenv("CA_CERT_STORE_PROVIDER", common.CertStoreLocalDb)
+	t.Setenv("CA_TEST_MODE", "true")
+	t.Chdir(dir)
+
+	logger := zap.NewNop()
+	cfgMgr := config.NewConfigMgr(logger, common.ServiceName)
+	if !cfgMgr.Load(true) {
+		t.Fatalf("Failed to load the configuration")
+	}
+	common.InitTemplateConfiguration(cfgMgr.GetCertificateTemplateConfig())
+
+	store, err := certstore.Init(logger, cfgMgr.GetCertStoreProvider())
+	if err != nil {
+		t.Fatalf("Failed to initialize the certificate store: %v", err)
+	}
+
+	provider := &AwsKmsProvider{logger: logger}
+	err = provider.initProvider(client, cfgMgr, store)
+	if err != nil {
+		store.Shutdown()
+		t.Fatalf("Failed to initialize the AWS KMS provider: %v", err)
+	}
+	return provider, store
+}
+
+// Check that the device certificate was issued by the first of the parent
+// certificates, and that it was issued by the CA certificate.
+func checkDeviceCertificate(t *testing.T, deviceCert []byte,
+	parentCerts []byte) {
+	cert, err := x509.ParseCertificate(deviceCert)
+	if err != nil {
+		t.Errorf("Failed to parse the device certificate: %v", err)
+		return
+	}
+	parents, err := pkcs7.Parse(parentCerts)
+	if err != nil {
+		t.Errorf("Failed to parse the parent certificates: %v", err)
+		return
+	}
+	if len(parents.Certificates) != 2 {
+		t.Errorf("Expected 2 parent certificates, got %d",
+			len(parents.Certificates))
+		return
+	}
+	if err = cert.CheckSignatureFrom(parents.Certificates[0]); err != nil {
+		t.Errorf("Device certificate not issued by the signing certificate: %v",
+			err)
+	}
+	if err = parents.Certificates[0].CheckSignatureFrom(
+		parents.Certificates[1]); err != nil {
+		t.Errorf("Signing certificate not issued by the CA certificate: %v", err)
+	}
+}
+
+// Create a tenant signing certificate, issue and renew device certificates
+// within the tenant and within a tenant without a signing certificate, then
+// delete the tenant signing certificate.
+func exerciseTenant(t *testing.T, provider *AwsKmsProvider, csr []byte) {
+	ctx := context.Background()
+	tenantID := uuid.NewString()
+	_, err := provider.CreateTenantSigningCertificate(ctx, tenantID, "AWS tenant")
+	if err != nil {
+		t.Errorf("Failed to create the tenant signing certificate: %v", err)
+		return
+	}
+
+	for i := 0; i < testIterations; i++ {
+		for _, tid := range []string{tenantID, uuid.NewString()} {
+			deviceID, deviceCert, parentCerts, _, err :=
+				provider.CreateDeviceCertificate(ctx, tid, csr)
+			if err != nil {
+				t.Errorf("Failed to create the device certificate: %v", err)
+				continue
+			}
+			checkDeviceCertificate(t, deviceCert, parentCerts)
+
+			_, deviceCert, parentCerts, _, err =
+				provider.RenewDeviceCertificate(ctx, tid, deviceID, csr)
+			if err != nil {
+				t.Errorf("Failed to renew the device certificate: %v", err)
+				continue
+			}
+			checkDeviceCertificate(t, deviceCert, parentCerts)
+		}
+
+		issuer, err := provider.NewDeviceCertificateIssuer(ctx, tenantID)
+		if err != nil {
+			t.Errorf("Failed to create the device certificate issuer: %v", err)
+			continue
+		}
+		_, deviceCert, parentCerts, _, err := issuer.CreateDeviceCertificate(csr)
+		if err != nil {
+			t.Errorf("Failed to issue the device certificate: %v", err)
+			continue
+		}
+		checkDeviceCertificate(t, deviceCert, parentCerts)
+	}
+
+	err = provider.DeleteTenantSigningCertificate(ctx, tenantID)
+	if err != nil {
+		t.Errorf("Failed to delete the tenant signing certificate: %v", err)
+	}
+}
+
+func TestAwsKmsProvider_ConcurrentOperations(t *testing.T) {
+	provider, store := newTestProvider(t, t.TempDir(), newFakeKMSClient())
+	defer store.Shutdown()
+
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+
+	// Replace the provider state while requests are in flight.
+	done := make(chan struct{})
+	var swapper sync.WaitGroup
+	swapper.Add(1)
+	go func() {
+		defer swapper.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			state := *provider.state.Load()
+			provider.state.Store(&state)
+			if _, _, err := provider.GetCACertificates(context.Background()); err != nil {
+				t.Errorf("Failed to get the CA certificates: %v", err)
+			}
+		}
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < testWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			exerciseTenant(t, provider, csr)
+		}()
+	}
+	workers.Wait()
+	close(done)
+	swapper.Wait()
+}
+
+func TestAwsKmsProvider_RequestContext(t *testing.T) {
+	client := &blockingKMSClient{fakeKMSClient: newFakeKMSClient()}
+	provider, store := newTestProvider(t, t.TempDir(), client)
+	defer store.Shutdown()
+
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+	tenantID := uuid.NewString()
+	_, _, _, _, err = provider.CreateDeviceCertificate(context.Background(),
+		tenantID, csr)
+	if err != nil {
+		t.Fatalf("Failed to create the device certificate: %v", err)
+	}
+
+	// Requests are not made to KMS on behalf of a cancelled request.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, _, _, err = provider.CreateDeviceCertificate(ctx, tenantID, csr)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancelled request to fail, got %v", err)
+	}
+	_, err = provider.CreateTenantSigningCertificate(ctx, tenantID, "AWS tenant")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancelled request to fail, got %v", err)
+	}
+
+	// Signing requests are bounded by the deadline of the request, rather
+	// than by the timeout for KMS requests.
+	client.blocked.Store(true)
+	ctx, cancel = context.WithTimeout(context.Background(),
+		100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, _, _, err = provider.CreateDeviceCertificate(ctx, tenantID, csr)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the request to exceed its deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= awsKmsRequestTimeout {
+		t.Errorf("Expected the request to be abandoned at its deadline, took %v",
+			elapsed)
+	}
+
+	// The cached signer is not bound to the context of earlier requests.
+	client.blocked.Store(false)
+	_, _, _, _, err = provider.CreateDeviceCertificate(context.Background(),
+		tenantID, csr)
+	if err != nil {
+		t.Errorf("Failed to create the device certificate: %v", err)
+	}
+}
+
+func TestAwsKmsProvider_Throttling(t *testing.T) {
+	ctx := context.Background()
+	client := &throttlingKMSClient{fakeKMSClient: newFakeKMSClient()}
+	provider, store := newTestProvider(t, t.TempDir(), client)
+	defer store.Shutdown()
+
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+	tenantID := uuid.NewString()
+
+	// Throttled signing requests are retried.
+	client.throttled.Store(awsKmsMaxAttempts - 1)
+	_, _, _, _, err = provider.CreateDeviceCertificate(ctx, tenantID, csr)
+	if err != nil {
+		t.Fatalf("Expected the throttled request to be retried, got %v", err)
+	}
+
+	// Requests fail once retries are exhausted, and the circuit breaker opens
+	// if requests keep failing.
+	client.throttled.Store(awsKmsMaxAttempts * awsKmsCircuitBreakerThreshold)
+	for i := 0; i < awsKmsCircuitBreakerThreshold; i++ {
+		_, _, _, _, err = provider.CreateDeviceCertificate(ctx, tenantID, csr)
+		if !errors.Is(err, common.ErrKmsThrottled) {
+			t.Fatalf("Expected the request to be throttled, got %v", err)
+		}
+	}
+
+	// Requests fail fast while the circuit breaker is open.
+	client.signCalls.Store(0)
+	_, _, _, _, err = provider.CreateDeviceCertificate(ctx, tenantID, csr)
+	if !errors.Is(err, resilience.ErrCircuitOpen) ||
+		!caerrors.Is(err, caerrors.DependencyUnavailable) {
+		t.Errorf("Expected the request to be rejected, got %v", err)
+	}
+	if calls := client.signCalls.Load(); calls != 0 {
+		t.Errorf("Expected no signing requests to be made to KMS, got %d", calls)
+	}
+}
+
+func TestAwsKmsProvider_CreateKeyNotRetried(t *testing.T) {
+	ctx := context.Background()
+	client := &failingCreateKeyKMSClient{fakeKMSClient: newFakeKMSClient()}
+	provider, store := newTestProvider(t, t.TempDir(), client)
+	defer store.Shutdown()
+
+	// Requests to create keys are not retried, since a request which reached
+	// KMS may have created a key.
+	client.fail.Store(true)
+	_, err := provider.CreateTenantSigningCertificate(ctx, uuid.NewString(), "")
+	if !caerrors.Is(err, caerrors.DependencyUnavailable) {
+		t.Errorf("Expected a dependency unavailable error, got %v", err)
+	}
+	if calls := client.createKeyCalls.Load(); calls != 1 {
+		t.Errorf("Expected a single request to create the key, got %d", calls)
+	}
+}
@@ -8,13 +8,13 @@
 package aws_kms
 
 import (
+	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
-	"errors"
-	"fmt"
 
+	"github.com/HPInc/krypton-ca/service/caerrors"
 	"github.com/HPInc/krypton-ca/service/common"
 	"github.com/HPInc/krypton-ca/service/metrics"
 	"go.uber.org/zap"
@@ -23,44 +23,49 @@ import (
 // getCACertificate - Retrieve the CA certificate from the certificate store
 // and check to see if its public key matches the corresponding CA key stored
 // in KMS.
-func (p *AwsKmsProvider) getCACertificate() error {
+func (p *AwsKmsProvider) getCACertificate(
+	ctx context.Context) (*providerState, error) {
+	state := &providerState{caKeyID: awsKmsCAKeyAlias}
+
 	// Retrieve the public key associated with the CA key from KMS.
-	caPublicKey, err := p.getCAKey()
+	caPublicKey, err := p.getCAKey(ctx, state.caKeyID)
 	if err != nil {
-		caLogger.Error("Failed to get public key associated with CA key in KMS",
+		p.logger.Error("Failed to get public key associated with CA key in KMS",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
 	// Retrieve the CA certificate from the certificate store.
-	certEntry, err := p.store.GetCertificate(p.caKeyID)
+	certEntry, err := p.store.GetCertificate(ctx, state.caKeyID)
 	if err != nil {
-		caLogger.Error("Failed to get the CA certificate from the cert store",
+		p.logger.Error("Failed to get the CA certificate from the cert store",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
-	p.caCert, err = x509.ParseCertificate(certEntry.Certificate)
+	state.caCert, err = x509.ParseCertificate(certEntry.Certificate)
 	if err != nil {
-		caLogger.Error("Failed to parse the CA certificate!",
+		p.logger.Error("Failed to parse the CA certificate!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
 	// Check if the public key within the CA certificate matches that retrieved
 	// from KMS. These must match in order to use the CA certificate successfully
 	// for signing purposes.
-	if !p.caCert.PublicKey.(*rsa.PublicKey).Equal(caPublicKey) {
-		caLogger.Error("CA certificate public key doesn't match CA key stored in KMS!",
+	if !state.caCert.PublicKey.(*rsa.PublicKey).Equal(caPublicKey) {
+		p.logger.Error("CA certificate public key doesn't match CA key stored in KMS!",
 			zap.Error(err),
 		)
-		return fmt.Errorf("key mismatch: CA certificate public key doesn't match CA key in KMS")
+		return nil, caerrors.New(caerrors.Internal,
+			"key mismatch: CA certificate public key doesn't match CA key in KMS")
 	}
 
-	return nil
+	state.caCertBytes = certEntry.Certificate
+	return state, nil
 }
 
 // ///////////////////// *** IN TEST MODE only *** ///////////////////////////
@@ -69,83 +74,88 @@ func (p *AwsKmsProvider) getCACertificate() error {
 // the CA certificate should already be present within the KMS store and the
 // KMS key ID (alias) for the certificate should be provided to the service.
 // ///////////////////////////////////////////////////////////////////////////
-func (p *AwsKmsProvider) generateCACertificate(issuerName string) error {
+func (p *AwsKmsProvider) generateCACertificate(ctx context.Context,
+	issuerName string) (*providerState, error) {
+	state := &providerState{caKeyID: awsKmsCAKeyAlias}
+
 	// Instantiate a new CA certificate template.
 	caCertTpl, err := common.NewCACertificateTemplate()
 	if err != nil {
-		caLogger.Error("Failed to initialize the CA certificate template!",
+		p.logger.Error("Failed to initialize the CA certificate template!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
 	// Check if the CA key exists in KMS. If so, use that to generate a CA
 	// certificate. Else, create a new CA key and use the new key to
 	// generate the CA certificate.
-	_, err = p.getCAKey()
+	_, err = p.getCAKey(ctx, state.caKeyID)
 	if err != nil {
 		// Generate a new CA key within KMS to use for the CA certificate.
-		p.caKeyID, err = p.generateCAKey(issuerName)
+		state.caKeyID, err = p.generateCAKey(ctx, issuerName, state.caKeyID)
 		if err != nil {
-			caLogger.Error("Failed to generate the CA key in KMS!",
+			p.logger.Error("Failed to generate the CA key in KMS!",
 				zap.Error(err),
 			)
-			return err
+			return nil, err
 		}
 	}
 
 	// Initialize a crypto signer that will be used to sign the CA
 	// certificate.
-	caSigner, err := newKMSSigner(p.ctx, p.client, p.caKeyID)
+	caSigner, err := newKMSSigner(ctx, p.logger, p.client, state.caKeyID)
 	if err != nil {
-		caLogger.Error("Failed to initialize a crypto signer for the CA!",
+		p.logger.Error("Failed to initialize a crypto signer for the CA!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
 	caPublicKey := caSigner.Public()
 	if caPublicKey == nil {
-		caLogger.Error("Failed to get public key associated with CA key in KMS",
-			zap.String("CA Key ID: ", p.caKeyID),
+		p.logger.Error("Failed to get public key associated with CA key in KMS",
+			zap.String("CA Key ID: ", state.caKeyID),
 		)
-		return errors.New("cannot get CA public key")
+		return nil, caerrors.New(caerrors.Internal, "cannot get CA public key")
 	}
 
 	// Generate the CA certificate and sign it using the crypto signer.
 	// This will cause the certificate to be signed using the CA key stored
 	// within KMS.
-	p.caCertBytes, err = x509.CreateCertificate(rand.Reader, caCertTpl,
-		caCertTpl, caPublicKey, caSigner)
+	state.caCertBytes, err = x509.CreateCertificate(rand.Reader, caCertTpl,
+		caCertTpl, caPublicKey, p.signingPool.Signer(ctx, caSigner))
 	if err != nil {
-		caLogger.Error("Failed to sign the CA certificate using AWS KMS!",
+		p.logger.Error("Failed to sign the CA certificate using AWS KMS!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
 	// Parse and store the signed CA certificate in memory.
-	p.caCert, err = x509.ParseCertificate(p.caCertBytes)
+	state.caCert, err = x509.ParseCertificate(state.caCertBytes)
 	if err != nil {
-		caLogger.Error("Failed to parse the signed CA certificate!",
+		p.logger.Error("Failed to parse the signed CA certificate!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
-	p.caCert.PublicKey = caPublicKey
-	return nil
+	state.caCert.PublicKey = caPublicKey
+	return state, nil
 }
 
-func (p *AwsKmsProvider) generateCAKey(issuerName string) (string, error) {
-	return p.newKmsKey(issuerName, p.caKeyID)
+func (p *AwsKmsProvider) generateCAKey(ctx context.Context, issuerName string,
+	keyAlias string) (string, error) {
+	return p.newKmsKey(ctx, issuerName, keyAlias)
 }
 
-func (p *AwsKmsProvider) getCAKey() (crypto.PublicKey, error) {
+func (p *AwsKmsProvider) getCAKey(ctx context.Context,
+	keyID string) (crypto.PublicKey, error) {
 	// Retrieve the public key associated with the CA key from KMS.
-	caPublicKey, err := p.getKmsPublicKey(p.caKeyID)
+	caPublicKey, err := p.getKmsPublicKey(ctx, keyID)
 	if err != nil {
-		caLogger.Error("Failed to get public key associated with CA key in KMS",
+		p.logger.Error("Failed to get public key associated with CA key in KMS",
 			zap.Error(err),
 		)
 		metrics.MetricAwsKmsCAKeyRetrievalFailures.Inc()
@@ -155,3 +165,11 @@ func (p *AwsKmsProvider) getCAKey() (crypto.PublicKey, error) {
 	metrics.MetricAwsKmsCAKeyRetrieved.Inc()
 	return caPublicKey, nil
 }
+
+// GetCACertificates - get the CA certificate and the common signing
+// certificate.
+func (p *AwsKmsProvider) GetCACertificates(
+	ctx context.Context) ([]byte, []byte, error) {
+	state := p.state.Load()
+	return state.caCert.Raw, state.commonSigningCert.Certificate, nil
+}
@@ -9,30 +9,59 @@ package aws_kms
 import (
 	"context"
 	"crypto/x509"
+	"sync/atomic"
+	"time"
 
+	"github.com/HPInc/krypton-ca/service/certmgr/cache"
 	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
 	"github.com/HPInc/krypton-ca/service/common"
 	cacfg "github.com/HPInc/krypton-ca/service/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"go.uber.org/zap"
 )
 
-var (
-	caLogger *zap.Logger
-)
+// Register the AWS KMS provider.
+func init() {
+	kms_providers.Register(common.KmsProviderAws, func() kms_providers.KmsProvider {
+		return &AwsKmsProvider{}
+	}, cacfg.ProviderSchema{})
+}
 
 // AwsKmsProvider - uses the AWS KMS (Key Management Service) for cryptographic
 // operations and provides a more secure option for production. Private keys
 // are bound to the HSM within the AWS KMS service and do not leave the KMS
 // service when consumed for signing operations.
 type AwsKmsProvider struct {
+	logger *zap.Logger
+
 	// KMS methods exposed by the AWS KMS provider.
 	client KMSClient
 
-	// Parent context for the AWS KMS provider.
-	ctx context.Context
+	// The CA certificate and common signing certificate used by the provider.
+	state atomic.Pointer[providerState]
+
+	// Certificate store used to persist tenant signing certificates.
+	store certstore.CertStore
+
+	// Cached signers, keyed by KMS key ID. Constructing a signer retrieves
+	// the public key of the signing key from KMS, so signers are cached to
+	// avoid a round trip to KMS for each certificate issued. Nil if caching
+	// is disabled.
+	signers   *cache.Cache[string, *KMSSigner]
+	signerTTL time.Duration
 
+	// Bounds the number of signing operations performed concurrently.
+	signingPool *kms_providers.SigningPool
+}
+
+// providerState - the CA certificate and the common signing certificate used
+// by the AWS KMS provider. The state is not modified once initialized, and is
+// replaced as a whole, so that requests in flight while the state is replaced
+// continue to use a consistent set of certificates and keys.
+type providerState struct {
 	// The CA root certificate.
 	caKeyID     string
 	caCert      *x509.Certificate
@@ -40,37 +69,66 @@ type AwsKmsProvider struct {
 
 	// The common signing certificate.
 	commonSigningCert *common.SigningCertificate
-
-	// Certificate store used to persist tenant signing certificates.
-	store certstore.CertStore
 }
 
 // Init - initialize the AWS KMS provider.
-func (p *AwsKmsProvider) Init(logger *zap.Logger, cfgMgr *cacfg.ConfigMgr) error {
-	caLogger = logger
-	p.ctx = context.Background()
-	p.caKeyID = awsKmsCAKeyAlias
+func (p *AwsKmsProvider) Init(logger *zap.Logger, cfgMgr *cacfg.ConfigMgr,
+	store certstore.CertStore) error {
+	p.logger = logger
 
 	// Load the default AWS configuration and initialize a client to the
-	// AWS KMS service.
-	awsConfig, err := config.LoadDefaultConfig(p.ctx)
+	// AWS KMS service. Requests are retried by the provider, so retries by
+	// the AWS SDK are disabled.
+	awsConfig, err := config.LoadDefaultConfig(context.Background())
 	if err != nil {
-		caLogger.Error("Failed to load the default AWS configuration!",
+		p.logger.Error("Failed to load the default AWS configuration!",
 			zap.Error(err),
 		)
 		return err
 	}
-	p.client = kms.NewFromConfig(awsConfig)
 
-	// Initialize the certificate store provider.
-	p.store, err = certstore.Init(caLogger, cfgMgr.GetCertStoreProvider())
+	client := kms.NewFromConfig(awsConfig, func(o *kms.Options) {
+		o.Retryer = aws.NopRetryer{}
+	})
+	return p.initProvider(client, cfgMgr, store)
+}
+
+// initProvider - initialize the AWS KMS provider using the specified client to
+// AWS KMS. Requests made using the client are retried if they fail with a
+// transient error, and fail fast while AWS KMS is unavailable.
+func (p *AwsKmsProvider) initProvider(client KMSClient,
+	cfgMgr *cacfg.ConfigMgr, store certstore.CertStore) error {
+	p.client = newResilientKMSClient(client)
+
+	// Use the specified certificate store to persist signing certificates.
+	p.store = store
+
+	// Cache signers, if configured.
+	if cacheSettings := cfgMgr.GetCacheConfig(); cacheSettings.Enabled {
+		p.signers = cache.New[string, *KMSSigner](awsKmsSignerCacheName,
+			cacheSettings.MaxEntries)
+		p.signerTTL = time.Duration(cacheSettings.TTLSeconds) * time.Second
+	}
+	p.signingPool = kms_providers.NewSigningPool(
+		cfgMgr.GetSigningConfig().MaxConcurrentOperations)
+
+	state, err := p.newProviderState(context.Background(), cfgMgr)
 	if err != nil {
-		caLogger.Error("Failed to initialize certificate store provider!",
-			zap.String("Provider name: ", cfgMgr.GetCertStoreProvider()),
-			zap.Error(err),
-		)
 		return err
 	}
+	p.state.Store(state)
+
+	p.logger.Info("AWS KMS provider initialized successfully!")
+	return nil
+}
+
+// newProviderState - initialize the CA certificate and retrieve the common
+// signing certificate issued by it, creating the common signing certificate if
+// required.
+func (p *AwsKmsProvider) newProviderState(ctx context.Context,
+	cfgMgr *cacfg.ConfigMgr) (*providerState, error) {
+	var state *providerState
+	var err error
 
 	// Initialize the CA certificate.
 	if cfgMgr.IsTestModeEnabled() {
@@ -79,45 +137,47 @@ func (p *AwsKmsProvider) Init(logger *zap.Logger, cfgMgr *cacfg.ConfigMgr) error
 		// generate the CA certificate, which will be used for signing tenant
 		// signing certificates.
 		///////////////////////////////////////////////////////////////////////
-		err = p.generateCACertificate(cfgMgr.GetIssuerName())
+		state, err = p.generateCACertificate(ctx, cfgMgr.GetIssuerName())
 		if err != nil {
-			caLogger.Error("Test Mode: Failed to generate CA certificate!",
+			p.logger.Error("Test Mode: Failed to generate CA certificate!",
 				zap.Error(err),
 			)
-			return err
+			return nil, err
 		}
 	} else {
 		///////////////////////////// Production mode /////////////////////////
 		// Retrieve the CA certificate from the certificate store and check if
 		// it matches the CA key retrieved from KMS.
 		///////////////////////////////////////////////////////////////////////
-		err = p.getCACertificate()
+		state, err = p.getCACertificate(ctx)
 		if err != nil {
-			caLogger.Error("Production Mode: Failed to initialize CA certificate!",
+			p.logger.Error("Production Mode: Failed to initialize CA certificate!",
 				zap.Error(err),
 			)
-			return err
+			return nil, err
 		}
 	}
 
 	// Initialize the common signing certificate.
-	p.commonSigningCert, err = p.getCommonSigningCertificate()
+	state.commonSigningCert, err = p.getCommonSigningCertificate(ctx, state)
 	if err != nil {
-		caLogger.Error("Failed to get the common signing certificate from certificate store!",
+		p.logger.Error("Failed to get the common signing certificate from certificate store!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
-	caLogger.Info("AWS KMS provider initialized successfully!")
-	return nil
+	return state, nil
+}
+
+// Ping - check that AWS KMS is reachable, by retrieving the public key of the
+// CA key.
+func (p *AwsKmsProvider) Ping(ctx context.Context) error {
+	_, err := p.getKmsPublicKey(ctx, p.state.Load().caKeyID)
+	return err
 }
 
 // Shutdown - clean up and shutdown the AWS KMS provider.
 func (p *AwsKmsProvider) Shutdown() {
-	p.ctx.Done()
-
-	// Shutdown the certificate store provider.
-	p.store.Shutdown()
-	caLogger.Info("AWS KMS provider shutdown!")
+	p.logger.Info("AWS KMS provider shutdown!")
 }
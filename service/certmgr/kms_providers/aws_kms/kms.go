@@ -13,8 +13,12 @@ import (
 	"crypto"
 	"crypto/x509"
 	"errors"
+	"fmt"
 	"time"
 
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/awserrors"
+	"github.com/HPInc/krypton-ca/service/common"
 	"github.com/HPInc/krypton-ca/service/metrics"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
@@ -23,7 +27,8 @@ import (
 )
 
 const (
-	// Timeouts that apply to requests made to the KMS.
+	// Timeout for each attempt of a request made to the KMS. Requests are
+	// also bounded by the deadline of the request made to the CA.
 	awsKmsRequestTimeout = 5 * time.Second
 
 	// Schedule deletion of the key from KMS after these many days:
@@ -44,6 +49,7 @@ const (
 	awsKmsOpCreateAlias         = "CreateAlias"
 	awsKmsOpDeleteAlias         = "DeleteAlias"
 	awsKmsOpDescribeKey         = "DescribeKey"
+	awsKmsOpListResourceTags    = "ListResourceTags"
 	awsKmsOpScheduleKeyDeletion = "ScheduleKeyDeletion"
 	awsKmsOpGetPublicKey        = "GetPublicKey"
 	awsKmsOpSign                = "Sign"
@@ -61,20 +67,38 @@ type KMSClient interface {
 	Sign(context.Context, *kms.SignInput, ...func(*kms.Options)) (*kms.SignOutput, error)
 }
 
+// wrapKmsError - wraps an error returned by AWS KMS into an error with the
+// appropriate category. Throttling errors are wrapped with
+// common.ErrKmsThrottled so that callers can report them as transient
+// failures.
+func wrapKmsError(message string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if awserrors.IsThrottlingError(err) {
+		metrics.MetricAwsKmsThrottledRequests.Inc()
+		return caerrors.Wrap(caerrors.DependencyUnavailable, message,
+			fmt.Errorf("%w: %v", common.ErrKmsThrottled, err))
+	}
+
+	var nfe *types.NotFoundException
+	if errors.As(err, &nfe) {
+		return caerrors.Wrap(caerrors.NotFound, message, err)
+	}
+	return awserrors.WrapError(message, err)
+}
+
 // newKmsKey - Generate a new key in AWS KMS, associate it with the
 // requested key alias and return the KMS key ID of the key.
-func (p *AwsKmsProvider) newKmsKey(keyDescription string,
+func (p *AwsKmsProvider) newKmsKey(ctx context.Context, keyDescription string,
 	keyAlias string) (string, error) {
 	select {
-	case <-p.ctx.Done():
-		return "", p.ctx.Err()
+	case <-ctx.Done():
+		return "", ctx.Err()
 	default:
 	}
 
-	// Generate a context and specify the timeout for the KMS call.
-	ctx, cancel := context.WithTimeout(p.ctx, awsKmsRequestTimeout)
-	defer cancel()
-
 	// Check if the requested key already exists in KMS
 	start := time.Now()
 	response, err := p.client.DescribeKey(ctx, &kms.DescribeKeyInput{
@@ -83,7 +107,7 @@ func (p *AwsKmsProvider) newKmsKey(keyDescription string,
 	metrics.ReportLatencyMetric(metrics.MetricAwsKmsRequestLatency, start,
 		awsKmsOpDescribeKey)
 	if err == nil {
-		caLogger.Info("Requested key already exists in KMS!",
+		p.logger.Info("Requested key already exists in KMS!",
 			zap.String("Key alias: ", keyAlias),
 			zap.String("Existing key ID: ", aws.ToString(response.KeyMetadata.KeyId)),
 		)
@@ -93,11 +117,11 @@ func (p *AwsKmsProvider) newKmsKey(keyDescription string,
 	// if the error returned isn't a NotFoundException, then raise it and bail.
 	var nsk *types.NotFoundException
 	if !errors.As(err, &nsk) {
-		caLogger.Error("Encountered an error checking if key exists in KMS!",
+		p.logger.Error("Encountered an error checking if key exists in KMS!",
 			zap.String("Key alias: ", keyAlias),
 			zap.Error(err),
 		)
-		return "", err
+		return "", wrapKmsError("failed to describe key in KMS", err)
 	}
 
 	// Generate a new key in KMS.
@@ -110,12 +134,12 @@ func (p *AwsKmsProvider) newKmsKey(keyDescription string,
 	metrics.ReportLatencyMetric(metrics.MetricAwsKmsRequestLatency, start,
 		awsKmsOpCreateKey)
 	if err != nil {
-		caLogger.Error("Failed to create the requested key in KMS",
+		p.logger.Error("Failed to create the requested key in KMS",
 			zap.String("Key alias: ", keyAlias),
 			zap.Error(err),
 		)
 		metrics.MetricAwsKmsKeyCreationFailures.Inc()
-		return "", err
+		return "", wrapKmsError("failed to create key in KMS", err)
 	}
 	metrics.MetricAwsKmsKeyCreated.Inc()
 
@@ -128,18 +152,18 @@ func (p *AwsKmsProvider) newKmsKey(keyDescription string,
 	metrics.ReportLatencyMetric(metrics.MetricAwsKmsRequestLatency, start,
 		awsKmsOpCreateAlias)
 	if err != nil {
-		caLogger.Error("Failed to create an alias for the key in KMS",
+		p.logger.Error("Failed to create an alias for the key in KMS",
 			zap.String("Key alias: ", keyAlias),
 			zap.Error(err),
 		)
 		metrics.MetricAwsKmsAliasCreationFailures.Inc()
-		return "", err
+		return "", wrapKmsError("failed to create key alias in KMS", err)
 	}
 	metrics.MetricAwsKmsAliasCreated.Inc()
 
 	// Return the KMS key ID assigned to the newly created key.
 	keyID := aws.ToString(createdKey.KeyMetadata.KeyId)
-	caLogger.Info("Created the requested key in AWS KMS",
+	p.logger.Info("Created the requested key in AWS KMS",
 		zap.String("Key ID:", keyID),
 		zap.String("Key alias: ", keyAlias),
 	)
@@ -148,17 +172,14 @@ func (p *AwsKmsProvider) newKmsKey(keyDescription string,
 
 // deleteKmsKey - Schedule deletion of the key mapped to the specified alias from
 // KMS. Also delete the alias for the key in KMS.
-func (p *AwsKmsProvider) deleteKmsKey(keyAlias string) error {
+func (p *AwsKmsProvider) deleteKmsKey(ctx context.Context,
+	keyAlias string) error {
 	select {
-	case <-p.ctx.Done():
-		return p.ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
 	default:
 	}
 
-	// Generate a context and specify the timeout for the KMS call.
-	ctx, cancel := context.WithTimeout(p.ctx, awsKmsRequestTimeout)
-	defer cancel()
-
 	// Retrieve the key ID for the key from KMS.
 	start := time.Now()
 	response, err := p.client.DescribeKey(ctx, &kms.DescribeKeyInput{
@@ -167,11 +188,11 @@ func (p *AwsKmsProvider) deleteKmsKey(keyAlias string) error {
 	metrics.ReportLatencyMetric(metrics.MetricAwsKmsRequestLatency, start,
 		awsKmsOpDescribeKey)
 	if err != nil {
-		caLogger.Error("Failed to get information about the key from KMS!",
+		p.logger.Error("Failed to get information about the key from KMS!",
 			zap.String("Key Alias:", keyAlias),
 			zap.Error(err),
 		)
-		return err
+		return wrapKmsError("failed to describe key in KMS", err)
 	}
 
 	// Schedule deletion of the specified key in KMS.
@@ -183,12 +204,12 @@ func (p *AwsKmsProvider) deleteKmsKey(keyAlias string) error {
 	metrics.ReportLatencyMetric(metrics.MetricAwsKmsRequestLatency, start,
 		awsKmsOpScheduleKeyDeletion)
 	if err != nil {
-		caLogger.Error("Failed to schedule key deletion in KMS!",
+		p.logger.Error("Failed to schedule key deletion in KMS!",
 			zap.String("Key ID:", aws.ToString(response.KeyMetadata.KeyId)),
 			zap.Error(err),
 		)
 		metrics.MetricAwsKmsKeyDeletionFailures.Inc()
-		return err
+		return wrapKmsError("failed to schedule key deletion in KMS", err)
 	}
 	metrics.MetricAwsKmsKeyDeleted.Inc()
 
@@ -200,29 +221,26 @@ func (p *AwsKmsProvider) deleteKmsKey(keyAlias string) error {
 	metrics.ReportLatencyMetric(metrics.MetricAwsKmsRequestLatency, start,
 		awsKmsOpDeleteAlias)
 	if err != nil {
-		caLogger.Error("Failed to delete the alias for the specified key in KMS!",
+		p.logger.Error("Failed to delete the alias for the specified key in KMS!",
 			zap.String("Key Alias:", keyAlias),
 			zap.Error(err),
 		)
 		metrics.MetricAwsKmsAliasDeletionFailures.Inc()
-		return err
+		return wrapKmsError("failed to delete key alias in KMS", err)
 	}
 	metrics.MetricAwsKmsAliasDeleted.Inc()
 	return nil
 }
 
 // getKmsPublicKey - retrieve the public key associated with the specified KMS key.
-func (p *AwsKmsProvider) getKmsPublicKey(keyID string) (crypto.PublicKey, error) {
+func (p *AwsKmsProvider) getKmsPublicKey(ctx context.Context,
+	keyID string) (crypto.PublicKey, error) {
 	select {
-	case <-p.ctx.Done():
-		return nil, p.ctx.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	default:
 	}
 
-	// Generate a context and specify the timeout for the KMS call.
-	ctx, cancel := context.WithTimeout(p.ctx, awsKmsRequestTimeout)
-	defer cancel()
-
 	// Retrieve the public key from KMS.
 	start := time.Now()
 	response, err := p.client.GetPublicKey(ctx, &kms.GetPublicKeyInput{
@@ -231,21 +249,22 @@ func (p *AwsKmsProvider) getKmsPublicKey(keyID string) (crypto.PublicKey, error)
 	metrics.ReportLatencyMetric(metrics.MetricAwsKmsRequestLatency, start,
 		awsKmsOpGetPublicKey)
 	if err != nil {
-		caLogger.Error("Failed to get public key associated with key in KMS",
+		p.logger.Error("Failed to get public key associated with key in KMS",
 			zap.String("Key ID: ", keyID),
 		)
 		metrics.MetricAwsKmsKeyRetrievalFailures.Inc()
-		return "", errors.New("cannot get public key")
+		return "", wrapKmsError("cannot get public key", err)
 	}
 	metrics.MetricAwsKmsKeyRetrieved.Inc()
 
 	// Parse the public key retrieved from KMS.
 	publicKey, err := x509.ParsePKIXPublicKey(response.PublicKey)
 	if err != nil {
-		caLogger.Error("Failed to parse public key associated with key in KMS",
+		p.logger.Error("Failed to parse public key associated with key in KMS",
 			zap.String("Tenant Key ID: ", keyID),
 		)
-		return "", errors.New("cannot parse tenant public key")
+		return "", caerrors.Wrap(caerrors.Internal,
+			"cannot parse tenant public key", err)
 	}
 
 	return publicKey, nil
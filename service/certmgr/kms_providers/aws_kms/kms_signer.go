@@ -20,9 +20,14 @@ import (
 	"go.uber.org/zap"
 )
 
+// Name of the signer cache, used to label metrics.
+const awsKmsSignerCacheName = "aws_kms_signers"
+
 // KMSSigner implements the crypto/Signer interface that can be used for signing operations
 // using an AWS KMS key. see https://golang.org/pkg/crypto/#Signer
 type KMSSigner struct {
+	logger *zap.Logger
+
 	// An instance of the KMS Client.
 	client KMSClient
 
@@ -32,12 +37,13 @@ type KMSSigner struct {
 	// Public key.
 	publicKey crypto.PublicKey
 
-	// Parent context.
+	// Context of the request on behalf of which signing operations are
+	// performed. Signing requests made to KMS are bounded by its deadline.
 	ctx context.Context
 }
 
 // Initializes a new instance of the KMS signer using the requested key ID.
-func newKMSSigner(ctx context.Context, client KMSClient,
+func newKMSSigner(ctx context.Context, logger *zap.Logger, client KMSClient,
 	keyID string) (*KMSSigner, error) {
 	select {
 	case <-ctx.Done():
@@ -45,36 +51,33 @@ func newKMSSigner(ctx context.Context, client KMSClient,
 	default:
 	}
 
-	// Generate a context and specify the timeout for the KMS call.
-	newCtx, cancel := context.WithTimeout(ctx, awsKmsRequestTimeout)
-	defer cancel()
-
 	// Request the public key corresponding to the specified key ID from AWS KMS.
 	start := time.Now()
-	response, err := client.GetPublicKey(newCtx, &kms.GetPublicKeyInput{
+	response, err := client.GetPublicKey(ctx, &kms.GetPublicKeyInput{
 		KeyId: &keyID,
 	})
 	metrics.ReportLatencyMetric(metrics.MetricAwsKmsRequestLatency, start,
 		awsKmsOpGetPublicKey)
 	if err != nil {
-		caLogger.Error("Failed to get the public key from AWS KMS!",
+		logger.Error("Failed to get the public key from AWS KMS!",
 			zap.Error(err),
 		)
 		metrics.MetricAwsKmsKeyRetrievalFailures.Inc()
-		return nil, err
+		return nil, wrapKmsError("failed to get the public key from KMS", err)
 	}
 	metrics.MetricAwsKmsKeyRetrieved.Inc()
 
 	// Parse the KMS response and extract the public key.
 	key, err := x509.ParsePKIXPublicKey(response.PublicKey)
 	if err != nil {
-		caLogger.Error("Failed to parse the public key from AWS KMS response!",
+		logger.Error("Failed to parse the public key from AWS KMS response!",
 			zap.Error(err),
 		)
 		return nil, err
 	}
 
 	return &KMSSigner{
+		logger:    logger,
 		client:    client,
 		keyID:     keyID,
 		publicKey: key,
@@ -82,6 +85,32 @@ func newKMSSigner(ctx context.Context, client KMSClient,
 	}, nil
 }
 
+// getKMSSigner - returns a signer for the requested key ID from the signer
+// cache, or initializes a new signer if one is not cached. The signer performs
+// signing operations on behalf of the request with the specified context.
+func (p *AwsKmsProvider) getKMSSigner(ctx context.Context,
+	keyID string) (*KMSSigner, error) {
+	signer, ok := p.signers.Get(keyID)
+	if !ok {
+		var err error
+		signer, err = newKMSSigner(ctx, p.logger, p.client, keyID)
+		if err != nil {
+			return nil, err
+		}
+		p.signers.Add(keyID, signer, p.signerTTL)
+	}
+	return signer.withContext(ctx), nil
+}
+
+// withContext - returns a copy of the signer which performs signing operations
+// on behalf of the request with the specified context. Cached signers are
+// shared by requests, so they are not modified.
+func (s *KMSSigner) withContext(ctx context.Context) *KMSSigner {
+	signer := *s
+	signer.ctx = ctx
+	return &signer
+}
+
 // Public returns the public key used by the signer.
 func (s *KMSSigner) Public() crypto.PublicKey {
 	return s.publicKey
@@ -96,12 +125,8 @@ func (s *KMSSigner) Sign(rand io.Reader, digest []byte,
 	default:
 	}
 
-	// Generate a context and specify the timeout for the KMS call.
-	ctx, cancel := context.WithTimeout(s.ctx, awsKmsRequestTimeout)
-	defer cancel()
-
 	start := time.Now()
-	response, err := s.client.Sign(ctx, &kms.SignInput{
+	response, err := s.client.Sign(s.ctx, &kms.SignInput{
 		KeyId:            &s.keyID,
 		Message:          digest,
 		MessageType:      types.MessageTypeDigest,
@@ -110,11 +135,11 @@ func (s *KMSSigner) Sign(rand io.Reader, digest []byte,
 	metrics.ReportLatencyMetric(metrics.MetricAwsKmsRequestLatency, start,
 		awsKmsOpSign)
 	if err != nil {
-		caLogger.Error("Failed to sign using AWS KMS!",
+		s.logger.Error("Failed to sign using AWS KMS!",
 			zap.Error(err),
 		)
 		metrics.MetricAwsKmsSignatureFailures.Inc()
-		return nil, err
+		return nil, wrapKmsError("failed to sign using KMS", err)
 	}
 
 	metrics.MetricAwsKmsSignatureSuccess.Inc()
@@ -0,0 +1,125 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/aws_kms
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements a KMS client which retries requests failing with transient
+// errors (eg. throttling, server side faults and timeouts) and fails requests
+// fast while AWS KMS is unavailable.
+package aws_kms
+
+import (
+	"context"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/awserrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/resilience"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+const (
+	// Name of the AWS KMS dependency, used to label metrics.
+	awsKmsDependencyName = "aws_kms"
+
+	// Requests failing with a transient error are attempted up to these many
+	// times, with a jittered exponential backoff between attempts. Requests
+	// creating keys are attempted once, since retrying a request which reached
+	// KMS would create another key.
+	awsKmsMaxAttempts = 3
+	awsKmsBaseBackoff = 100 * time.Millisecond
+	awsKmsMaxBackoff  = 2 * time.Second
+
+	// The circuit breaker opens after these many consecutive requests fail
+	// with a transient error, and stays open for the specified duration.
+	awsKmsCircuitBreakerThreshold    = 5
+	awsKmsCircuitBreakerOpenDuration = 30 * time.Second
+)
+
+// resilientKMSClient - a KMS client which retries requests made using the
+// wrapped client and fails requests fast while AWS KMS is unavailable.
+type resilientKMSClient struct {
+	client   KMSClient
+	executor *resilience.Executor
+}
+
+// newResilientKMSClient - wrap the specified KMS client.
+func newResilientKMSClient(client KMSClient) *resilientKMSClient {
+	return &resilientKMSClient{
+		client: client,
+		executor: resilience.New(awsKmsDependencyName, resilience.Policy{
+			MaxAttempts:      awsKmsMaxAttempts,
+			AttemptTimeout:   awsKmsRequestTimeout,
+			BaseBackoff:      awsKmsBaseBackoff,
+			MaxBackoff:       awsKmsMaxBackoff,
+			FailureThreshold: awsKmsCircuitBreakerThreshold,
+			OpenDuration:     awsKmsCircuitBreakerOpenDuration,
+			NonIdempotent: map[string]bool{
+				awsKmsOpCreateKey: true,
+			},
+		}, awserrors.IsRetryableError),
+	}
+}
+
+func (c *resilientKMSClient) CreateKey(ctx context.Context,
+	params *kms.CreateKeyInput, optFns ...func(*kms.Options)) (*kms.CreateKeyOutput, error) {
+	return resilience.Call(ctx, c.executor, awsKmsOpCreateKey,
+		func(ctx context.Context) (*kms.CreateKeyOutput, error) {
+			return c.client.CreateKey(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientKMSClient) CreateAlias(ctx context.Context,
+	params *kms.CreateAliasInput, optFns ...func(*kms.Options)) (*kms.CreateAliasOutput, error) {
+	return resilience.Call(ctx, c.executor, awsKmsOpCreateAlias,
+		func(ctx context.Context) (*kms.CreateAliasOutput, error) {
+			return c.client.CreateAlias(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientKMSClient) DeleteAlias(ctx context.Context,
+	params *kms.DeleteAliasInput, optFns ...func(*kms.Options)) (*kms.DeleteAliasOutput, error) {
+	return resilience.Call(ctx, c.executor, awsKmsOpDeleteAlias,
+		func(ctx context.Context) (*kms.DeleteAliasOutput, error) {
+			return c.client.DeleteAlias(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientKMSClient) DescribeKey(ctx context.Context,
+	params *kms.DescribeKeyInput, optFns ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	return resilience.Call(ctx, c.executor, awsKmsOpDescribeKey,
+		func(ctx context.Context) (*kms.DescribeKeyOutput, error) {
+			return c.client.DescribeKey(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientKMSClient) ListResourceTags(ctx context.Context,
+	params *kms.ListResourceTagsInput, optFns ...func(*kms.Options)) (*kms.ListResourceTagsOutput, error) {
+	return resilience.Call(ctx, c.executor, awsKmsOpListResourceTags,
+		func(ctx context.Context) (*kms.ListResourceTagsOutput, error) {
+			return c.client.ListResourceTags(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientKMSClient) ScheduleKeyDeletion(ctx context.Context,
+	params *kms.ScheduleKeyDeletionInput, optFns ...func(*kms.Options)) (*kms.ScheduleKeyDeletionOutput, error) {
+	return resilience.Call(ctx, c.executor, awsKmsOpScheduleKeyDeletion,
+		func(ctx context.Context) (*kms.ScheduleKeyDeletionOutput, error) {
+			return c.client.ScheduleKeyDeletion(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientKMSClient) GetPublicKey(ctx context.Context,
+	params *kms.GetPublicKeyInput, optFns ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	return resilience.Call(ctx, c.executor, awsKmsOpGetPublicKey,
+		func(ctx context.Context) (*kms.GetPublicKeyOutput, error) {
+			return c.client.GetPublicKey(ctx, params, optFns...)
+		})
+}
+
+func (c *resilientKMSClient) Sign(ctx context.Context,
+	params *kms.SignInput, optFns ...func(*kms.Options)) (*kms.SignOutput, error) {
+	return resilience.Call(ctx, c.executor, awsKmsOpSign,
+		func(ctx context.Context) (*kms.SignOutput, error) {
+			return c.client.Sign(ctx, params, optFns...)
+		})
+}
@@ -9,11 +9,15 @@
 package aws_kms
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/x509"
+	"errors"
 	"fmt"
 
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
 	"github.com/HPInc/krypton-ca/service/common"
+	"go.mozilla.org/pkcs7"
 	"go.uber.org/zap"
 )
 
@@ -26,40 +30,44 @@ var (
 // is used to sign device certificates for tenants that do not have a
 // distinct/separate tenant signing certificate. This common signing
 // certificate also helps us save on the costs associated with KMS.
-func (p *AwsKmsProvider) createCommonSigningCertificate() (string, error) {
+func (p *AwsKmsProvider) createCommonSigningCertificate(ctx context.Context,
+	state *providerState) (string, error) {
 	// For the common signing certificate, we do not provide a tenant name
 	// to the certificate template. This certificate will have only the issuer
 	// name.
-	return p.CreateTenantSigningCertificate(common.CommonSigningKeyId, "")
+	return p.createTenantSigningCertificate(ctx, state, common.CommonSigningKeyId, "")
 }
 
 // getCommonSigningCertificate - Get the common signing certificate which
 // is used to sign device certificates for tenants that do not have a
 // distinct/separate tenant signing certificate. If one doesn't already exist
-// in the certificate store, a new common signing certificate is created.
-func (p *AwsKmsProvider) getCommonSigningCertificate() (*common.SigningCertificate, error) {
+// in the certificate store, a new common signing certificate is created using
+// the CA certificate within the specified provider state.
+func (p *AwsKmsProvider) getCommonSigningCertificate(ctx context.Context,
+	state *providerState) (*common.SigningCertificate, error) {
 	// Check to see if the common tenant signing certificate exists within the
 	// certificate store. If it is not found, attempt to create it.
 	// Retrieve the tenant signing certificate for the specified tenant.
-	tenantCert, err := p.store.GetCertificate(common.CommonSigningKeyId)
+	tenantCert, err := p.store.GetCertificate(ctx, common.CommonSigningKeyId)
 	if err != nil {
-		caLogger.Error("Failed to get the common signing certificate from certificate store!",
+		p.logger.Error("Failed to get the common signing certificate from certificate store!",
 			zap.Error(err),
 		)
 
-		// Attempt to create the common signing certificate.
-		_, err := p.createCommonSigningCertificate()
-		if err != nil {
-			caLogger.Error("Failed to create the common signing certificate!",
+		// Attempt to create the common signing certificate. It may have been
+		// created concurrently by another request, in which case it is used.
+		_, err := p.createCommonSigningCertificate(ctx, state)
+		if (err != nil) && !errors.Is(err, common.ErrTenantExists) {
+			p.logger.Error("Failed to create the common signing certificate!",
 				zap.Error(err),
 			)
 			return nil, err
 		}
 
 		// Now, return the newly created common signing certificate.
-		tenantCert, err = p.store.GetCertificate(common.CommonSigningKeyId)
+		tenantCert, err = p.store.GetCertificate(ctx, common.CommonSigningKeyId)
 		if err != nil {
-			caLogger.Error("Failed to get the common signing certificate from certificate store!",
+			p.logger.Error("Failed to get the common signing certificate from certificate store!",
 				zap.Error(err),
 			)
 			return nil, err
@@ -70,18 +78,47 @@ func (p *AwsKmsProvider) getCommonSigningCertificate() (*common.SigningCertifica
 }
 
 // CreateTenantSigningCertificate - create a new tenant signing certificate for
-// the specified tenant.
-func (p *AwsKmsProvider) CreateTenantSigningCertificate(tenantID string,
-	tenantName string) (string, error) {
+// the specified tenant. ErrTenantExists is returned if the tenant already has
+// a signing certificate.
+func (p *AwsKmsProvider) CreateTenantSigningCertificate(ctx context.Context,
+	tenantID string, tenantName string) (string, error) {
+	return p.createTenantSigningCertificate(ctx, p.state.Load(), tenantID,
+		tenantName)
+}
+
+// createTenantSigningCertificate - create a new tenant signing certificate for
+// the specified tenant, issued by the CA certificate within the specified
+// provider state.
+func (p *AwsKmsProvider) createTenantSigningCertificate(ctx context.Context,
+	state *providerState, tenantID string, tenantName string) (string, error) {
 	var certEntry common.SigningCertificate
 
+	// Ensure that a signing certificate doesn't already exist for the tenant,
+	// so that it is not silently replaced. The check bypasses the cache, since
+	// the signing certificate may have been created or deleted by another
+	// instance of the CA.
+	_, err := certstore.GetCertificateUncached(ctx, p.store, tenantID)
+	if err == nil {
+		p.logger.Error("A signing certificate already exists for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+		)
+		return "", common.ErrTenantExists
+	}
+	if !errors.Is(err, common.ErrCertStoreNotFound) {
+		p.logger.Error("Failed to check for an existing tenant signing certificate!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
 	// Generate a new private key within KMS for the tenant signing certificate.
 	// The KMS alias for this key is the tenant ID.
-	tenantKeyID, err := p.newKmsKey(
+	tenantKeyID, err := p.newKmsKey(ctx,
 		fmt.Sprintf("Signing key: %s", tenantID),
 		fmt.Sprintf(keyAliasFormat, tenantID))
 	if err != nil {
-		caLogger.Error("Failed to generate a signing key in KMS!",
+		p.logger.Error("Failed to generate a signing key in KMS!",
 			zap.String("Tenant ID: ", tenantID),
 			zap.Error(err),
 		)
@@ -92,7 +129,7 @@ func (p *AwsKmsProvider) CreateTenantSigningCertificate(tenantID string,
 	tenantCertTpl, err := common.NewTenantSigningCertificateTemplate(tenantID,
 		tenantName)
 	if err != nil {
-		caLogger.Error("Failed to initialize a new tenant signing certificate template!",
+		p.logger.Error("Failed to initialize a new tenant signing certificate template!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
@@ -101,9 +138,9 @@ func (p *AwsKmsProvider) CreateTenantSigningCertificate(tenantID string,
 
 	// Initialize a crypto signer that will be used to sign the tenant
 	// signing certificate.
-	tenantSigner, err := newKMSSigner(p.ctx, p.client, p.caKeyID)
+	tenantSigner, err := p.getKMSSigner(ctx, state.caKeyID)
 	if err != nil {
-		caLogger.Error("Failed to initialize a crypto signer for the signing key!",
+		p.logger.Error("Failed to initialize a crypto signer for the signing key!",
 			zap.String("Tenant ID: ", tenantID),
 			zap.String("Tenant Key ID: ", tenantKeyID),
 			zap.Error(err),
@@ -112,9 +149,9 @@ func (p *AwsKmsProvider) CreateTenantSigningCertificate(tenantID string,
 	}
 
 	// Get the public key associated with the newly created tenant key from KMS.
-	tenantPublicKey, err := p.getKmsPublicKey(tenantKeyID)
+	tenantPublicKey, err := p.getKmsPublicKey(ctx, tenantKeyID)
 	if err != nil {
-		caLogger.Error("Failed to get public key associated with signing key in KMS",
+		p.logger.Error("Failed to get public key associated with signing key in KMS",
 			zap.String("Tenant ID: ", tenantID),
 			zap.String("Tenant Key ID: ", tenantKeyID),
 		)
@@ -125,9 +162,9 @@ func (p *AwsKmsProvider) CreateTenantSigningCertificate(tenantID string,
 	// signer. This will cause the certificate to be signed using the CA
 	// certificate.
 	tenantCertBytes, err := x509.CreateCertificate(rand.Reader, tenantCertTpl,
-		p.caCert, tenantPublicKey, tenantSigner)
+		state.caCert, tenantPublicKey, p.signingPool.Signer(ctx, tenantSigner))
 	if err != nil {
-		caLogger.Error("Failed to generate the signing certificate!",
+		p.logger.Error("Failed to generate the signing certificate!",
 			zap.String("Tenant ID: ", tenantID),
 			zap.String("Tenant Key ID: ", tenantKeyID),
 			zap.Error(err),
@@ -140,16 +177,16 @@ func (p *AwsKmsProvider) CreateTenantSigningCertificate(tenantID string,
 	certEntry.TenantID = tenantID
 	certEntry.KmsKeyID = tenantKeyID
 
-	err = p.store.AddCertificate(&certEntry)
+	err = p.store.AddCertificate(ctx, &certEntry)
 	if err != nil {
-		caLogger.Error("Failed to add the tenant signing certificate to the store!",
+		p.logger.Error("Failed to add the tenant signing certificate to the store!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
 		return "", err
 	}
 
-	caLogger.Info("Successfully generated the tenant signing certificate!",
+	p.logger.Info("Successfully generated the tenant signing certificate!",
 		zap.String("Tenant Key ID:", tenantKeyID),
 	)
 	return string(tenantCertTpl.SubjectKeyId), nil
@@ -157,13 +194,13 @@ func (p *AwsKmsProvider) CreateTenantSigningCertificate(tenantID string,
 
 // GetTenantSigningCertificate - get the tenant signing certificate for
 // the specified tenant.
-func (p *AwsKmsProvider) GetTenantSigningCertificate(
+func (p *AwsKmsProvider) GetTenantSigningCertificate(ctx context.Context,
 	tenantID string) ([]byte, error) {
 
 	// Retrieve the tenant signing certificate for the specified tenant.
-	tenantCert, err := p.store.GetCertificate(tenantID)
+	tenantCert, err := p.store.GetCertificate(ctx, tenantID)
 	if err != nil {
-		caLogger.Error("Failed to retrieve the tenant signing certificate for the tenant.",
+		p.logger.Error("Failed to retrieve the tenant signing certificate for the tenant.",
 			zap.String("Tenant ID:", tenantID),
 		)
 		return nil, err
@@ -172,14 +209,56 @@ func (p *AwsKmsProvider) GetTenantSigningCertificate(
 	return tenantCert.Certificate, nil
 }
 
+// GetSigningCertificateChain - get the chain of certificates used to sign
+// device certificates issued within the specified tenant.
+func (p *AwsKmsProvider) GetSigningCertificateChain(ctx context.Context,
+	tenantID string) ([]byte, error) {
+	state := p.state.Load()
+
+	// Use the tenant signing certificate if one exists for the tenant, else
+	// the common signing certificate.
+	certEntry, err := p.store.GetCertificate(ctx, tenantID)
+	if err != nil {
+		if !errors.Is(err, common.ErrCertStoreNotFound) {
+			p.logger.Error("Failed to retrieve the tenant signing certificate",
+				zap.String("Tenant ID:", tenantID),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+		certEntry = state.commonSigningCert
+	}
+
+	chain := []byte{}
+	chain = append(chain, certEntry.Certificate...)
+	chain = append(chain, state.caCert.Raw...)
+
+	chain, err = pkcs7.DegenerateCertificate(chain)
+	if err != nil {
+		p.logger.Error("Failed to create degenerate PKCS7 object!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	return chain, nil
+}
+
 // DeleteTenantSigningCertificate - delete the tenant signing certificate for
 // the specified tenant.
-func (p *AwsKmsProvider) DeleteTenantSigningCertificate(tenantID string) error {
+func (p *AwsKmsProvider) DeleteTenantSigningCertificate(ctx context.Context,
+	tenantID string) error {
+	// Remove the cached signer for the tenant's signing key, which is
+	// scheduled for deletion.
+	certEntry, err := p.store.GetCertificate(ctx, tenantID)
+	if err == nil {
+		p.signers.Remove(certEntry.KmsKeyID)
+	}
 
 	// Delete the tenant signing certificate for the specified tenant.
-	err := p.store.DeleteCertificate(tenantID)
+	err = p.store.DeleteCertificate(ctx, tenantID)
 	if err != nil {
-		caLogger.Error("Failed to delete the tenant signing certificate!",
+		p.logger.Error("Failed to delete the tenant signing certificate!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
@@ -187,9 +266,9 @@ func (p *AwsKmsProvider) DeleteTenantSigningCertificate(tenantID string) error {
 	}
 
 	// Delete the key for the tenant from KMS.
-	err = p.deleteKmsKey(fmt.Sprintf(keyAliasFormat, tenantID))
+	err = p.deleteKmsKey(ctx, fmt.Sprintf(keyAliasFormat, tenantID))
 	if err != nil {
-		caLogger.Error("Failed to delete the tenant key from KMS!",
+		p.logger.Error("Failed to delete the tenant key from KMS!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
@@ -0,0 +1,291 @@
+package azure_keyvault
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers/kmstest"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	testVaultURL = "https://fake.vault.azure.net/"
+)
+
+// fakeKeyVaultKey - a key held by the fake key vault, along with its versions.
+type fakeKeyVaultKey struct {
+	versions []*rsa.PrivateKey
+	deleted  bool
+}
+
+// fakeKeyVaultClient - an in-memory fake of the Azure Key Vault methods
+// consumed by the provider. Deleted keys are retained (soft-deleted) and
+// cannot be re-created, as is the case with Azure Key Vault.
+type fakeKeyVaultClient struct {
+	lock sync.Mutex
+
+	// Keys, keyed by key name.
+	keys map[string]*fakeKeyVaultKey
+
+	// Error returned by the next call, if any.
+	failNext error
+}
+
+func newFakeKeyVaultClient() *fakeKeyVaultClient {
+	return &fakeKeyVaultClient{
+		keys: map[string]*fakeKeyVaultKey{},
+	}
+}
+
+func (f *fakeKeyVaultClient) injectedError() error {
+	err := f.failNext
+	f.failNext = nil
+	return err
+}
+
+func responseError(statusCode int, code string) error {
+	return &azcore.ResponseError{StatusCode: statusCode, ErrorCode: code}
+}
+
+// Returns the JSON web key for the specified version of the key.
+func keyBundle(name string, version int, key *rsa.PrivateKey) *azkeys.JSONWebKey {
+	return &azkeys.JSONWebKey{
+		KID: to.Ptr(azkeys.ID(fmt.Sprintf("%skeys/%s/%d", testVaultURL, name,
+			version))),
+		Kty: to.Ptr(azkeys.KeyTypeRSAHSM),
+		N:   key.N.Bytes(),
+		E:   big.NewInt(int64(key.E)).Bytes(),
+	}
+}
+
+// Returns the private key for the specified version of the key. The latest
+// version is returned if no version is specified.
+func (f *fakeKeyVaultClient) findKey(name string,
+	version string) (int, *rsa.PrivateKey, error) {
+	key, ok := f.keys[name]
+	if !ok || key.deleted {
+		return 0, nil, responseError(http.StatusNotFound, "KeyNotFound")
+	}
+	if version == "" {
+		return len(key.versions), key.versions[len(key.versions)-1], nil
+	}
+	for i, private := range key.versions {
+		if fmt.Sprint(i+1) == version {
+			return i + 1, private, nil
+		}
+	}
+	return 0, nil, responseError(http.StatusNotFound, "KeyNotFound")
+}
+
+func (f *fakeKeyVaultClient) CreateKey(ctx context.Context, name string,
+	parameters azkeys.CreateKeyParameters,
+	options *azkeys.CreateKeyOptions) (azkeys.CreateKeyResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return azkeys.CreateKeyResponse{}, err
+	}
+	if (*parameters.Kty != azkeys.KeyTypeRSAHSM) ||
+		(*parameters.KeySize != int32(common.KeySize)) {
+		return azkeys.CreateKeyResponse{},
+			responseError(http.StatusBadRequest, "BadParameter")
+	}
+
+	key, ok := f.keys[name]
+	if ok && key.deleted {
+		return azkeys.CreateKeyResponse{},
+			responseError(http.StatusConflict, "Conflict")
+	}
+	if !ok {
+		key = &fakeKeyVaultKey{}
+		f.keys[name] = key
+	}
+	private, err := rsa.GenerateKey(rand.Reader, common.KeySize)
+	if err != nil {
+		return azkeys.CreateKeyResponse{}, err
+	}
+	key.versions = append(key.versions, private)
+
+	var response azkeys.CreateKeyResponse
+	response.Key = keyBundle(name, len(key.versions), private)
+	return response, nil
+}
+
+func (f *fakeKeyVaultClient) GetKey(ctx context.Context, name string,
+	version string, options *azkeys.GetKeyOptions) (azkeys.GetKeyResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return azkeys.GetKeyResponse{}, err
+	}
+	number, private, err := f.findKey(name, version)
+	if err != nil {
+		return azkeys.GetKeyResponse{}, err
+	}
+
+	var response azkeys.GetKeyResponse
+	response.Key = keyBundle(name, number, private)
+	return response, nil
+}
+
+func (f *fakeKeyVaultClient) DeleteKey(ctx context.Context, name string,
+	options *azkeys.DeleteKeyOptions) (azkeys.DeleteKeyResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return azkeys.DeleteKeyResponse{}, err
+	}
+	if _, _, err := f.findKey(name, ""); err != nil {
+		return azkeys.DeleteKeyResponse{}, err
+	}
+	f.keys[name].deleted = true
+	return azkeys.DeleteKeyResponse{}, nil
+}
+
+func (f *fakeKeyVaultClient) Sign(ctx context.Context, name string,
+	version string, parameters azkeys.SignParameters,
+	options *azkeys.SignOptions) (azkeys.SignResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return azkeys.SignResponse{}, err
+	}
+	if *parameters.Algorithm != azkeys.SignatureAlgorithmRS256 {
+		return azkeys.SignResponse{},
+			responseError(http.StatusBadRequest, "BadParameter")
+	}
+	_, private, err := f.findKey(name, version)
+	if err != nil {
+		return azkeys.SignResponse{}, err
+	}
+	signature, err := rsa.SignPKCS1v15(rand.Reader, private, crypto.SHA256,
+		parameters.Value)
+	if err != nil {
+		return azkeys.SignResponse{},
+			responseError(http.StatusBadRequest, "BadParameter")
+	}
+
+	var response azkeys.SignResponse
+	response.Result = signature
+	return response, nil
+}
+
+// Initialize an Azure Key Vault provider using the specified fake client, with
+// a certificate store in the specified directory.
+func newTestProvider(t *testing.T, dir string,
+	client *fakeKeyVaultClient) (*AzureKeyVaultProvider, certstore.CertStore) {
+	_, store := kmstest.NewConfig(t, dir, common.KmsProviderAzure,
+		map[string]string{"CA_AZURE_KEYVAULT_URL": testVaultURL})
+
+	provider := &AzureKeyVaultProvider{logger: zap.NewNop()}
+	err := provider.initProvider(client, store, nil)
+	if err != nil {
+		store.Shutdown()
+		t.Fatalf("Failed to initialize the Azure Key Vault provider: %v", err)
+	}
+	return provider, store
+}
+
+func TestAzureKeyVaultProvider_DeviceCertificate(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeKeyVaultClient()
+	provider, store := newTestProvider(t, t.TempDir(), client)
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	// Tenant signing keys are named using the tenant ID.
+	tenantID := uuid.NewString()
+	_, err := provider.CreateTenantSigningCertificate(ctx, tenantID, "Azure tenant")
+	if err != nil {
+		t.Fatalf("Failed to create the tenant signing certificate: %v", err)
+	}
+	if _, ok := client.keys[tenantID]; !ok {
+		t.Fatalf("Expected the tenant key %s to be created", tenantID)
+	}
+
+	kmstest.CheckDeviceCertificates(t, provider, tenantID)
+
+	// Deleting the tenant deletes the tenant key.
+	err = provider.DeleteTenantSigningCertificate(ctx, tenantID)
+	if err != nil {
+		t.Fatalf("Failed to delete the tenant signing certificate: %v", err)
+	}
+	if !client.keys[tenantID].deleted {
+		t.Errorf("Expected the tenant key to be deleted")
+	}
+
+	// The deleted tenant key is retained by the key vault until it is purged,
+	// so the tenant cannot be re-created until then.
+	_, err = provider.CreateTenantSigningCertificate(ctx, tenantID, "Azure tenant")
+	if !caerrors.Is(err, caerrors.Conflict) {
+		t.Errorf("Expected a conflict error, got %v", err)
+	}
+}
+
+func TestAzureKeyVaultProvider_Restart(t *testing.T) {
+	dir := t.TempDir()
+	client := newFakeKeyVaultClient()
+	kmstest.CheckRestart(t, func() (kms_providers.KmsProvider,
+		certstore.CertStore) {
+		return newTestProvider(t, dir, client)
+	})
+}
+
+func TestAzureKeyVaultProvider_Unavailable(t *testing.T) {
+	client := newFakeKeyVaultClient()
+	provider, store := newTestProvider(t, t.TempDir(), client)
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	kmstest.CheckUnavailable(t, provider, func() {
+		client.lock.Lock()
+		client.failNext = responseError(http.StatusServiceUnavailable,
+			"ServiceUnavailable")
+		client.lock.Unlock()
+	})
+}
+
+func TestKeyVaultSigner_UnsupportedOptions(t *testing.T) {
+	kmstest.CheckUnsupportedOptions(t, &KeyVaultSigner{keyID: "unused"},
+		crypto.SHA384)
+}
+
+func TestWrapAzureError(t *testing.T) {
+	tests := []struct {
+		err      error
+		category caerrors.Category
+	}{
+		{&azcore.ResponseError{StatusCode: http.StatusNotFound}, caerrors.NotFound},
+		{&azcore.ResponseError{StatusCode: http.StatusConflict}, caerrors.Conflict},
+		{&azcore.ResponseError{StatusCode: http.StatusTooManyRequests},
+			caerrors.DependencyUnavailable},
+		{&azcore.ResponseError{StatusCode: http.StatusServiceUnavailable},
+			caerrors.DependencyUnavailable},
+		{&azcore.ResponseError{StatusCode: http.StatusForbidden}, caerrors.Internal},
+		{errors.New("connection refused"), caerrors.DependencyUnavailable},
+	}
+
+	for _, test := range tests {
+		err := wrapAzureError("azure call failed", test.err)
+		if category := caerrors.CategoryOf(err); category != test.category {
+			t.Errorf("wrapAzureError(%v) category = %v, expected %v", test.err,
+				category, test.category)
+		}
+	}
+}
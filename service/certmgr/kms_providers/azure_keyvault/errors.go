@@ -0,0 +1,48 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/azure_keyvault
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Assigns categories to errors returned by Azure services (Key Vault) used by
+// the CA.
+package azure_keyvault
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/HPInc/krypton-ca/service/caerrors"
+)
+
+// wrapAzureError - wraps an error returned by an Azure SDK call into an error
+// with the appropriate category. Throttling errors, server side faults and
+// failures to reach the Azure service are reported as DependencyUnavailable.
+// Other errors returned by the Azure service indicate a problem with the
+// request made by the CA and are reported as Internal.
+func wrapAzureError(message string, err error) error {
+	var (
+		e       *caerrors.Error
+		respErr *azcore.ResponseError
+	)
+	if (err == nil) || errors.As(err, &e) {
+		return err
+	}
+
+	if !errors.As(err, &respErr) {
+		// The Azure service did not return a response (eg. network failures
+		// and timeouts).
+		return caerrors.Wrap(caerrors.DependencyUnavailable, message, err)
+	}
+
+	switch {
+	case respErr.StatusCode == http.StatusNotFound:
+		return caerrors.Wrap(caerrors.NotFound, message, err)
+	case respErr.StatusCode == http.StatusConflict:
+		return caerrors.Wrap(caerrors.Conflict, message, err)
+	case (respErr.StatusCode == http.StatusTooManyRequests) ||
+		(respErr.StatusCode >= http.StatusInternalServerError):
+		return caerrors.Wrap(caerrors.DependencyUnavailable, message, err)
+	}
+	return caerrors.Wrap(caerrors.Internal, message, err)
+}
@@ -0,0 +1,129 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/azure_keyvault
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Initializes the Azure Key Vault KMS provider.
+package azure_keyvault
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"go.uber.org/zap"
+)
+
+var (
+	// Settings of the Azure Key Vault KMS provider, read from the
+	// azure_keyvault section under certificate_authority/providers in the
+	// configuration file.
+	settings Settings
+)
+
+// Settings represents configuration settings for the Azure Key Vault KMS
+// provider, which generates and uses the CA's signing keys within an Azure Key
+// Vault. Credentials are obtained using the default Azure credential chain
+// (eg. environment variables or a managed identity).
+type Settings struct {
+	// URL of the key vault (eg. https://myvault.vault.azure.net/).
+	VaultURL string `yaml:"vault_url"`
+}
+
+// Register the Azure Key Vault KMS provider.
+func init() {
+	kms_providers.Register(common.KmsProviderAzure, func() kms_providers.KmsProvider {
+		return &AzureKeyVaultProvider{}
+	}, config.ProviderSchema{
+		Settings: &settings,
+		Env: map[string]config.EnvOverride{
+			"CA_AZURE_KEYVAULT_URL": {Value: &settings.VaultURL},
+		},
+		Validate: func(*config.ConfigMgr) error {
+			if settings.VaultURL == "" {
+				return errors.New("the URL of the key vault must be specified")
+			}
+			return nil
+		},
+	})
+}
+
+// AzureKeyVaultProvider - uses Azure Key Vault for cryptographic operations.
+// The root and signing keys are generated as HSM-protected keys and do not
+// leave Azure Key Vault when consumed for signing operations. Keys are created
+// within the configured key vault, and are named using the tenant ID.
+type AzureKeyVaultProvider struct {
+	logger *zap.Logger
+
+	// Issues and manages certificates using the keys held within Azure Key
+	// Vault. The key ID of each key identifies its key version.
+	kms_providers.KeyBackendProvider
+}
+
+// Init - initialize the Azure Key Vault KMS provider.
+func (p *AzureKeyVaultProvider) Init(logger *zap.Logger,
+	cfgMgr *config.ConfigMgr, store certstore.CertStore) error {
+	p.logger = logger
+	p.logger.Info("Azure Key Vault KMS provider settings",
+		zap.String(" - Vault URL:", settings.VaultURL),
+	)
+
+	// Initialize a client to the configured key vault using the default Azure
+	// credential chain.
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		p.logger.Error("Failed to obtain Azure credentials!",
+			zap.Error(err),
+		)
+		return caerrors.Wrap(caerrors.Internal,
+			"failed to obtain Azure credentials", err)
+	}
+	client, err := azkeys.NewClient(settings.VaultURL,
+		credential, nil)
+	if err != nil {
+		p.logger.Error("Failed to initialize the Azure Key Vault client!",
+			zap.Error(err),
+		)
+		return caerrors.Wrap(caerrors.Internal,
+			"failed to initialize the Azure Key Vault client", err)
+	}
+
+	return p.initProvider(client, store, kms_providers.NewSigningPool(
+		cfgMgr.GetSigningConfig().MaxConcurrentOperations))
+}
+
+// initProvider - initialize the Azure Key Vault KMS provider using the
+// specified client to Azure Key Vault. Signing operations are bounded by the
+// specified signing pool.
+func (p *AzureKeyVaultProvider) initProvider(client KeyVaultClient,
+	store certstore.CertStore, signingPool *kms_providers.SigningPool) error {
+	backend := &keyVaultKeyBackend{
+		logger: p.logger,
+		client: client,
+	}
+
+	// Initialize the CA certificate and the common signing certificate, and
+	// use the specified certificate store to persist signing certificates. If
+	// the CA has not been initialized yet, the CA key is created within
+	// Azure Key Vault and used to issue the CA certificate.
+	err := p.KeyBackendProvider.Init(context.Background(), p.logger, backend,
+		store, signingPool, keyVaultCACertID, keyVaultCAKeyName)
+	if err != nil {
+		return err
+	}
+
+	p.logger.Info("Azure Key Vault KMS provider initialized successfully!")
+	return nil
+}
+
+// Shutdown - clean up and shutdown the Azure Key Vault KMS provider.
+func (p *AzureKeyVaultProvider) Shutdown() {
+	p.KeyBackendProvider.Shutdown()
+	p.logger.Info("Azure Key Vault KMS provider shutdown!")
+}
@@ -0,0 +1,61 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/azure_keyvault
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Exposes the keys held within Azure Key Vault to the implementation of the
+// KMS provider shared with other providers holding their keys within a key
+// management service.
+package azure_keyvault
+
+import (
+	"context"
+	"crypto"
+
+	"go.uber.org/zap"
+)
+
+// keyVaultKeyBackend - creates and uses keys held within the key vault. Keys
+// are named using the tenant ID, and are identified using the key ID of their
+// key version.
+type keyVaultKeyBackend struct {
+	logger *zap.Logger
+
+	// Azure Key Vault methods consumed by the provider.
+	client KeyVaultClient
+}
+
+func (b *keyVaultKeyBackend) Name() string {
+	return "Azure Key Vault"
+}
+
+func (b *keyVaultKeyBackend) TenantKeyName(tenantID string) string {
+	return tenantID
+}
+
+func (b *keyVaultKeyBackend) CreateKey(ctx context.Context,
+	keyName string) (string, error) {
+	return b.newKeyVaultKey(ctx, keyName)
+}
+
+func (b *keyVaultKeyBackend) PublicKey(ctx context.Context,
+	keyID string) (crypto.PublicKey, error) {
+	return b.getKeyVaultPublicKey(ctx, keyID)
+}
+
+func (b *keyVaultKeyBackend) Signer(ctx context.Context,
+	keyID string) (crypto.Signer, error) {
+	signer, err := newKeyVaultSigner(ctx, b, keyID)
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+func (b *keyVaultKeyBackend) DeleteKey(ctx context.Context, keyName string) error {
+	return b.deleteKeyVaultKey(ctx, keyName)
+}
+
+func (b *keyVaultKeyBackend) Close() {
+	// The client to Azure Key Vault holds no resources to be released.
+}
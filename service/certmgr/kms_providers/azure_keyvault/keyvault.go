@@ -0,0 +1,236 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/azure_keyvault
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements various operations exposed by the Azure Key Vault service. These
+// capabilities are used by the Azure Key Vault provider to create and manage
+// keys in Azure Key Vault and sign certificates using the right keys.
+package azure_keyvault
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"math/big"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// Timeouts that apply to requests made to Azure Key Vault.
+	keyVaultRequestTimeout = 5 * time.Second
+
+	// The name of the key used as the CA key. The CA key can be found in the
+	// configured key vault using this name. Tenant signing keys are named
+	// using the tenant ID, and the common signing key is named
+	// "SharedTenantSigningKey".
+	keyVaultCAKeyName = "CAKey"
+
+	// ID under which the CA certificate is persisted in the certificate
+	// store.
+	keyVaultCACertID = "azure_keyvault/CAKey"
+
+	// Key Vault operation names
+	keyVaultOpCreateKey = "CreateKey"
+	keyVaultOpGetKey    = "GetKey"
+	keyVaultOpDeleteKey = "DeleteKey"
+	keyVaultOpSign      = "Sign"
+)
+
+// An interface exposing Azure Key Vault methods consumed by the provider.
+type KeyVaultClient interface {
+	CreateKey(context.Context, string, azkeys.CreateKeyParameters, *azkeys.CreateKeyOptions) (azkeys.CreateKeyResponse, error)
+	GetKey(context.Context, string, string, *azkeys.GetKeyOptions) (azkeys.GetKeyResponse, error)
+	DeleteKey(context.Context, string, *azkeys.DeleteKeyOptions) (azkeys.DeleteKeyResponse, error)
+	Sign(context.Context, string, string, azkeys.SignParameters, *azkeys.SignOptions) (azkeys.SignResponse, error)
+}
+
+// newKeyVaultKey - Create a new RSA key in Azure Key Vault with the requested
+// key name, and return the key ID of the key. The key ID identifies the
+// version of the key used for signing. If a key with the requested name
+// already exists, its current version is used. Keys are protected by an HSM
+// and cannot be exported.
+func (b *keyVaultKeyBackend) newKeyVaultKey(ctx context.Context,
+	keyName string) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	default:
+	}
+
+	// Generate a context and specify the timeout for the Key Vault call.
+	ctx, cancel := context.WithTimeout(ctx, keyVaultRequestTimeout)
+	defer cancel()
+
+	// Check if the requested key already exists in Azure Key Vault.
+	start := time.Now()
+	existing, err := b.client.GetKey(ctx, keyName, "", nil)
+	metrics.ReportLatencyMetric(metrics.MetricAzureKeyVaultRequestLatency, start,
+		keyVaultOpGetKey)
+	if err == nil {
+		b.logger.Info("Requested key already exists in Azure Key Vault!",
+			zap.String("Key name: ", keyName),
+		)
+		return string(*existing.Key.KID), nil
+	}
+
+	err = wrapAzureError("failed to get key from Azure Key Vault", err)
+	if !caerrors.Is(err, caerrors.NotFound) {
+		b.logger.Error("Encountered an error checking if key exists in Azure Key Vault!",
+			zap.String("Key name: ", keyName),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
+	// Create a new key in Azure Key Vault.
+	start = time.Now()
+	created, err := b.client.CreateKey(ctx, keyName, azkeys.CreateKeyParameters{
+		Kty:     to.Ptr(azkeys.KeyTypeRSAHSM),
+		KeySize: to.Ptr(int32(common.KeySize)),
+		KeyOps: []*azkeys.KeyOperation{
+			to.Ptr(azkeys.KeyOperationSign),
+			to.Ptr(azkeys.KeyOperationVerify),
+		},
+	}, nil)
+	metrics.ReportLatencyMetric(metrics.MetricAzureKeyVaultRequestLatency, start,
+		keyVaultOpCreateKey)
+	if err != nil {
+		b.logger.Error("Failed to create the requested key in Azure Key Vault",
+			zap.String("Key name: ", keyName),
+			zap.Error(err),
+		)
+		metrics.MetricAzureKeyVaultKeyCreationFailures.Inc()
+		return "", wrapAzureError(
+			"failed to create key in Azure Key Vault", err)
+	}
+	metrics.MetricAzureKeyVaultKeyCreated.Inc()
+
+	keyID := string(*created.Key.KID)
+	b.logger.Info("Created the requested key in Azure Key Vault",
+		zap.String("Key ID:", keyID),
+		zap.String("Key name: ", keyName),
+	)
+	return keyID, nil
+}
+
+// deleteKeyVaultKey - Delete the key with the specified name from Azure Key
+// Vault. Key vaults retain deleted keys for the retention period configured for
+// the vault, after which they are purged. A key with the same name cannot be
+// created until the deleted key has been purged.
+func (b *keyVaultKeyBackend) deleteKeyVaultKey(ctx context.Context,
+	keyName string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	// Generate a context and specify the timeout for the Key Vault call.
+	ctx, cancel := context.WithTimeout(ctx, keyVaultRequestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err := b.client.DeleteKey(ctx, keyName, nil)
+	metrics.ReportLatencyMetric(metrics.MetricAzureKeyVaultRequestLatency, start,
+		keyVaultOpDeleteKey)
+	if err != nil {
+		b.logger.Error("Failed to delete the key from Azure Key Vault!",
+			zap.String("Key name:", keyName),
+			zap.Error(err),
+		)
+		metrics.MetricAzureKeyVaultKeyDeletionFailures.Inc()
+		return wrapAzureError(
+			"failed to delete key in Azure Key Vault", err)
+	}
+
+	metrics.MetricAzureKeyVaultKeyDeleted.Inc()
+	return nil
+}
+
+// getKeyVaultPublicKey - retrieve the public key of the key version with the
+// specified key ID.
+func (b *keyVaultKeyBackend) getKeyVaultPublicKey(ctx context.Context,
+	keyID string) (crypto.PublicKey, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	// Generate a context and specify the timeout for the Key Vault call.
+	ctx, cancel := context.WithTimeout(ctx, keyVaultRequestTimeout)
+	defer cancel()
+
+	id := azkeys.ID(keyID)
+	start := time.Now()
+	response, err := b.client.GetKey(ctx, id.Name(), id.Version(), nil)
+	metrics.ReportLatencyMetric(metrics.MetricAzureKeyVaultRequestLatency, start,
+		keyVaultOpGetKey)
+	if err != nil {
+		b.logger.Error("Failed to get public key associated with key in Azure Key Vault",
+			zap.String("Key ID: ", keyID),
+			zap.Error(err),
+		)
+		metrics.MetricAzureKeyVaultKeyRetrievalFailures.Inc()
+		return nil, wrapAzureError("cannot get public key", err)
+	}
+	metrics.MetricAzureKeyVaultKeyRetrieved.Inc()
+
+	// Build the RSA public key from the modulus and public exponent of the
+	// JSON web key returned by Azure Key Vault.
+	key := response.Key
+	if (key == nil) || (len(key.N) == 0) || (len(key.E) == 0) {
+		b.logger.Error("No RSA public key returned by Azure Key Vault for the key!",
+			zap.String("Key ID: ", keyID),
+		)
+		return nil, caerrors.New(caerrors.Internal, "cannot parse public key")
+	}
+	exponent := new(big.Int).SetBytes(key.E)
+	if !exponent.IsInt64() || (exponent.Int64() > (1<<31 - 1)) {
+		return nil, caerrors.New(caerrors.Internal,
+			"invalid public exponent returned by Azure Key Vault")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(key.N),
+		E: int(exponent.Int64()),
+	}, nil
+}
+
+// signKeyVault - sign the specified SHA-256 digest using the key version with
+// the specified key ID.
+func (b *keyVaultKeyBackend) signKeyVault(ctx context.Context, keyID string,
+	digest []byte) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	// Generate a context and specify the timeout for the Key Vault call.
+	ctx, cancel := context.WithTimeout(ctx, keyVaultRequestTimeout)
+	defer cancel()
+
+	id := azkeys.ID(keyID)
+	start := time.Now()
+	response, err := b.client.Sign(ctx, id.Name(), id.Version(),
+		azkeys.SignParameters{
+			Algorithm: to.Ptr(azkeys.SignatureAlgorithmRS256),
+			Value:     digest,
+		}, nil)
+	metrics.ReportLatencyMetric(metrics.MetricAzureKeyVaultRequestLatency, start,
+		keyVaultOpSign)
+	if err != nil {
+		return nil, wrapAzureError(
+			"failed to sign using Azure Key Vault", err)
+	}
+	return response.Result, nil
+}
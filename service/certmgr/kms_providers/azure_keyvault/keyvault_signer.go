@@ -0,0 +1,95 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/azure_keyvault
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements a crypto signer interface that is used to sign certificates using
+// the Azure Key Vault service.
+package azure_keyvault
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"io"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"go.uber.org/zap"
+)
+
+// KeyVaultSigner implements the crypto/Signer interface that can be used for
+// signing operations using an Azure Key Vault key.
+// see https://golang.org/pkg/crypto/#Signer
+type KeyVaultSigner struct {
+	// The key backend holding the client to Azure Key Vault.
+	backend *keyVaultKeyBackend
+
+	// Key ID of the key version used for signing.
+	keyID string
+
+	// Public key.
+	publicKey crypto.PublicKey
+
+	// Context of the request on behalf of which signing operations are
+	// performed. Signing requests made to Azure Key Vault are bounded by its
+	// deadline.
+	ctx context.Context
+}
+
+// Initializes a new instance of the Key Vault signer using the requested key
+// ID, which signs on behalf of the request with the specified context.
+func newKeyVaultSigner(ctx context.Context, backend *keyVaultKeyBackend,
+	keyID string) (*KeyVaultSigner, error) {
+	key, err := backend.getKeyVaultPublicKey(ctx, keyID)
+	if err != nil {
+		backend.logger.Error("Failed to get the public key from Azure Key Vault!",
+			zap.String("Key ID:", keyID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return &KeyVaultSigner{
+		backend:   backend,
+		keyID:     keyID,
+		publicKey: key,
+		ctx:       ctx,
+	}, nil
+}
+
+// Public returns the public key used by the signer.
+func (s *KeyVaultSigner) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign the requested digest using Azure Key Vault. Only RSA PKCS #1 v1.5
+// signatures over SHA-256 digests (RS256) are supported.
+func (s *KeyVaultSigner) Sign(rand io.Reader, digest []byte,
+	opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		metrics.MetricAzureKeyVaultSignatureFailures.Inc()
+		return nil, caerrors.New(caerrors.Internal,
+			"RSA-PSS signatures are not supported by the Azure Key Vault signer")
+	}
+
+	if (opts.HashFunc() != crypto.SHA256) ||
+		(len(digest) != crypto.SHA256.Size()) {
+		metrics.MetricAzureKeyVaultSignatureFailures.Inc()
+		return nil, caerrors.New(caerrors.Internal,
+			"unsupported digest for the Azure Key Vault signer")
+	}
+
+	signature, err := s.backend.signKeyVault(s.ctx, s.keyID, digest)
+	if err != nil {
+		s.backend.logger.Error("Failed to sign using Azure Key Vault!",
+			zap.String("Key ID:", s.keyID),
+			zap.Error(err),
+		)
+		metrics.MetricAzureKeyVaultSignatureFailures.Inc()
+		return nil, err
+	}
+
+	metrics.MetricAzureKeyVaultSignatureSuccess.Inc()
+	return signature, nil
+}
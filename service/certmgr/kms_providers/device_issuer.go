@@ -0,0 +1,142 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the device certificate issuer used by the KMS providers to issue
+// device certificates within a tenant, both individually and in batches. The
+// signing certificate and signing key used within the tenant are resolved once
+// when the issuer is created, rather than for each device certificate issued
+// in a batch.
+package kms_providers
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/google/uuid"
+	"go.mozilla.org/pkcs7"
+	"go.uber.org/zap"
+)
+
+// DeviceCertificateIssuer - issues device certificates within a tenant, signed
+// using the signing certificate and key resolved for the tenant by the KMS
+// provider. An issuer may be used to issue device certificates concurrently.
+type DeviceCertificateIssuer struct {
+	logger *zap.Logger
+
+	// The unique identifier for the tenant.
+	tenantID string
+
+	// Signing certificate used to sign device certificates within the tenant.
+	signingCert *x509.Certificate
+
+	// Signer used to sign device certificates using the signing key.
+	signer crypto.Signer
+
+	// PKCS#7 degenerate "certs only" structure containing the signing
+	// certificate followed by the CA certificate.
+	parentCerts []byte
+}
+
+// NewDeviceCertificateIssuer - initialize an issuer which issues device
+// certificates within the specified tenant. The device certificates are signed
+// by the specified signing certificate using the specified signer, and chain to
+// the specified CA certificate (DER bytes).
+func NewDeviceCertificateIssuer(logger *zap.Logger, tenantID string,
+	signingCert *x509.Certificate, signer crypto.Signer,
+	caCertBytes []byte) (*DeviceCertificateIssuer, error) {
+	// Return the tenant signing certificate and the CA certificate.
+	parentCerts := []byte{}
+	parentCerts = append(parentCerts, signingCert.Raw...)
+	parentCerts = append(parentCerts, caCertBytes...)
+
+	// Build a PKCS#7 degenerate "certs only" structure from
+	// that ASN.1 certificates data.
+	parentCerts, err := pkcs7.DegenerateCertificate(parentCerts)
+	if err != nil {
+		logger.Error("Failed to create degenerate PKCS7 object!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return &DeviceCertificateIssuer{
+		logger:      logger,
+		tenantID:    tenantID,
+		signingCert: signingCert,
+		signer:      signer,
+		parentCerts: parentCerts,
+	}, nil
+}
+
+// CreateDeviceCertificate - Register a new device ID and issue a device
+// certificate in exchange for the specified CSR. The device ID, the device
+// certificate, the parent certificates and the expiry time of the device
+// certificate are returned.
+func (i *DeviceCertificateIssuer) CreateDeviceCertificate(
+	deviceCSR []byte) (string, []byte, []byte, time.Time, error) {
+	// Generate a device ID for the device.
+	deviceID := uuid.New().String()
+
+	deviceCertBytes, parentCerts, expiresAt, err := i.IssueDeviceCertificate(
+		deviceID, deviceCSR, nil)
+	if err != nil {
+		return "", nil, nil, time.Now(), err
+	}
+	return deviceID, deviceCertBytes, parentCerts, expiresAt, nil
+}
+
+// IssueDeviceCertificate - issue a device certificate to the specified device
+// in exchange for the specified CSR. The specified DNS names, which the caller
+// must have verified are controlled by the device, are included in the subject
+// alternative name extension of the certificate. The device certificate, the
+// parent certificates and the expiry time of the device certificate are
+// returned.
+func (i *DeviceCertificateIssuer) IssueDeviceCertificate(deviceID string,
+	deviceCSR []byte, dnsNames []string) ([]byte, []byte, time.Time, error) {
+	if (deviceCSR == nil) || (deviceID == "") {
+		i.logger.Error("Invalid CSR or device ID!")
+		return nil, nil, time.Now(), common.ErrInvalidParameter
+	}
+
+	// Parse and validate the device CSR received from the caller.
+	parsedCSR, err := common.ParseDeviceCertificateSigningRequest(i.logger,
+		deviceCSR)
+	if err != nil {
+		i.logger.Error("Failed to parse and validate the CSR!",
+			zap.String("Tenant ID:", i.tenantID),
+			zap.Error(err),
+		)
+		return nil, nil, time.Now(), err
+	}
+
+	// Initialize the device certificate template.
+	deviceCertTpl, err := common.NewDeviceCertificateTemplate(i.tenantID,
+		deviceID, parsedCSR)
+	if err != nil {
+		i.logger.Error("Failed to initialize a device certificate template!",
+			zap.String("Tenant ID:", i.tenantID),
+			zap.Error(err),
+		)
+		return nil, nil, time.Now(), err
+	}
+	deviceCertTpl.DNSNames = dnsNames
+
+	// Generate and sign the device certificate.
+	deviceCertBytes, err := x509.CreateCertificate(rand.Reader, deviceCertTpl,
+		i.signingCert, parsedCSR.PublicKey, i.signer)
+	if err != nil {
+		i.logger.Error("Failed to generate the device certificate!",
+			zap.String("Tenant ID:", i.tenantID),
+			zap.Error(err),
+		)
+		return nil, nil, time.Now(), err
+	}
+
+	return deviceCertBytes, i.parentCerts, deviceCertTpl.NotAfter, nil
+}
@@ -0,0 +1,47 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/gcp_kms
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Assigns categories to errors returned by Google Cloud services (Cloud KMS)
+// used by the CA.
+package gcp_kms
+
+import (
+	"errors"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// wrapGcpError - wraps an error returned by a Google Cloud client library call
+// into an error with the appropriate category. Throttling errors, server side
+// faults and failures to reach the Google Cloud service are reported as
+// DependencyUnavailable. Other errors returned by the Google Cloud service
+// indicate a problem with the request made by the CA and are reported as
+// Internal.
+func wrapGcpError(message string, err error) error {
+	var e *caerrors.Error
+	if (err == nil) || errors.As(err, &e) {
+		return err
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		// The Google Cloud service did not return a response (eg. network
+		// failures).
+		return caerrors.Wrap(caerrors.DependencyUnavailable, message, err)
+	}
+
+	switch s.Code() {
+	case codes.NotFound:
+		return caerrors.Wrap(caerrors.NotFound, message, err)
+	case codes.AlreadyExists:
+		return caerrors.Wrap(caerrors.Conflict, message, err)
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded,
+		codes.Aborted, codes.Internal, codes.Unknown:
+		return caerrors.Wrap(caerrors.DependencyUnavailable, message, err)
+	}
+	return caerrors.Wrap(caerrors.Internal, message, err)
+}
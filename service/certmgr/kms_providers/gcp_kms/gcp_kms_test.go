@@ -0,0 +1,373 @@
+package gcp_kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers/kmstest"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/google/uuid"
+	"github.com/googleapis/gax-go/v2"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	testProjectID = "test-project"
+	testLocation  = "us-west1"
+	testKeyRing   = "test-key-ring"
+)
+
+// fakeKMSClient - an in-memory fake of the Google Cloud KMS methods consumed by
+// the provider. Key versions are created in the PENDING_GENERATION state and
+// are enabled once their state has been polled.
+type fakeKMSClient struct {
+	lock sync.Mutex
+
+	// Crypto keys, keyed by resource name, and their key versions.
+	keys     map[string]*kmspb.CryptoKey
+	versions map[string][]*kmspb.CryptoKeyVersion
+	private  map[string]*rsa.PrivateKey
+
+	// Error returned by the next call, if any.
+	failNext error
+}
+
+func newFakeKMSClient() *fakeKMSClient {
+	return &fakeKMSClient{
+		keys:     map[string]*kmspb.CryptoKey{},
+		versions: map[string][]*kmspb.CryptoKeyVersion{},
+		private:  map[string]*rsa.PrivateKey{},
+	}
+}
+
+func (f *fakeKMSClient) injectedError() error {
+	err := f.failNext
+	f.failNext = nil
+	return err
+}
+
+func (f *fakeKMSClient) GetCryptoKey(ctx context.Context,
+	req *kmspb.GetCryptoKeyRequest,
+	opts ...gax.CallOption) (*kmspb.CryptoKey, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return nil, err
+	}
+	key, ok := f.keys[req.GetName()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "crypto key not found")
+	}
+	return key, nil
+}
+
+func (f *fakeKMSClient) CreateCryptoKey(ctx context.Context,
+	req *kmspb.CreateCryptoKeyRequest,
+	opts ...gax.CallOption) (*kmspb.CryptoKey, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return nil, err
+	}
+	key := req.GetCryptoKey()
+	if (key.GetPurpose() != kmspb.CryptoKey_ASYMMETRIC_SIGN) ||
+		(key.GetVersionTemplate().GetAlgorithm() != gcpKmsKeyAlgorithm) ||
+		(key.GetVersionTemplate().GetProtectionLevel() != kmspb.ProtectionLevel_HSM) {
+		return nil, status.Error(codes.InvalidArgument, "invalid crypto key")
+	}
+
+	name := req.GetParent() + "/cryptoKeys/" + req.GetCryptoKeyId()
+	if _, ok := f.keys[name]; ok {
+		return nil, status.Error(codes.AlreadyExists, "crypto key exists")
+	}
+	f.keys[name] = &kmspb.CryptoKey{Name: name, Purpose: key.GetPurpose()}
+	f.addVersion(name)
+	return f.keys[name], nil
+}
+
+// Adds a new key version being generated to the specified crypto key.
+func (f *fakeKMSClient) addVersion(keyName string) *kmspb.CryptoKeyVersion {
+	version := &kmspb.CryptoKeyVersion{
+		Name: fmt.Sprintf("%s/cryptoKeyVersions/%d", keyName,
+			len(f.versions[keyName])+1),
+		State:      kmspb.CryptoKeyVersion_PENDING_GENERATION,
+		Algorithm:  gcpKmsKeyAlgorithm,
+		CreateTime: timestamppb.New(time.Now()),
+	}
+	f.versions[keyName] = append(f.versions[keyName], version)
+	f.private[version.Name], _ = rsa.GenerateKey(rand.Reader, common.KeySize)
+	return version
+}
+
+// Returns the key version with the specified resource name.
+func (f *fakeKMSClient) findVersion(name string) *kmspb.CryptoKeyVersion {
+	keyName, _, _ := strings.Cut(name, "/cryptoKeyVersions/")
+	for _, version := range f.versions[keyName] {
+		if version.GetName() == name {
+			return version
+		}
+	}
+	return nil
+}
+
+func (f *fakeKMSClient) ListCryptoKeyVersions(ctx context.Context,
+	req *kmspb.ListCryptoKeyVersionsRequest,
+	opts ...gax.CallOption) ([]*kmspb.CryptoKeyVersion, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return nil, err
+	}
+	if _, ok := f.keys[req.GetParent()]; !ok {
+		return nil, status.Error(codes.NotFound, "crypto key not found")
+	}
+	return f.versions[req.GetParent()], nil
+}
+
+func (f *fakeKMSClient) GetCryptoKeyVersion(ctx context.Context,
+	req *kmspb.GetCryptoKeyVersionRequest,
+	opts ...gax.CallOption) (*kmspb.CryptoKeyVersion, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return nil, err
+	}
+	version := f.findVersion(req.GetName())
+	if version == nil {
+		return nil, status.Error(codes.NotFound, "key version not found")
+	}
+	if version.State == kmspb.CryptoKeyVersion_PENDING_GENERATION {
+		version.State = kmspb.CryptoKeyVersion_ENABLED
+	}
+	return version, nil
+}
+
+func (f *fakeKMSClient) CreateCryptoKeyVersion(ctx context.Context,
+	req *kmspb.CreateCryptoKeyVersionRequest,
+	opts ...gax.CallOption) (*kmspb.CryptoKeyVersion, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return nil, err
+	}
+	if _, ok := f.keys[req.GetParent()]; !ok {
+		return nil, status.Error(codes.NotFound, "crypto key not found")
+	}
+	return f.addVersion(req.GetParent()), nil
+}
+
+func (f *fakeKMSClient) DestroyCryptoKeyVersion(ctx context.Context,
+	req *kmspb.DestroyCryptoKeyVersionRequest,
+	opts ...gax.CallOption) (*kmspb.CryptoKeyVersion, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return nil, err
+	}
+	version := f.findVersion(req.GetName())
+	if version == nil {
+		return nil, status.Error(codes.NotFound, "key version not found")
+	}
+	version.State = kmspb.CryptoKeyVersion_DESTROY_SCHEDULED
+	return version, nil
+}
+
+// Returns the private key of the specified key version, if it is enabled.
+func (f *fakeKMSClient) enabledKey(name string) (*rsa.PrivateKey, error) {
+	version := f.findVersion(name)
+	if version == nil {
+		return nil, status.Error(codes.NotFound, "key version not found")
+	}
+	if version.State != kmspb.CryptoKeyVersion_ENABLED {
+		return nil, status.Error(codes.FailedPrecondition,
+			"key version is not enabled")
+	}
+	return f.private[name], nil
+}
+
+func (f *fakeKMSClient) GetPublicKey(ctx context.Context,
+	req *kmspb.GetPublicKeyRequest,
+	opts ...gax.CallOption) (*kmspb.PublicKey, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return nil, err
+	}
+	key, err := f.enabledKey(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return &kmspb.PublicKey{
+		Pem:       string(encoded),
+		PemCrc32C: wrapperspb.Int64(int64(crc32.Checksum(encoded, crc32cTable))),
+		Name:      req.GetName(),
+	}, nil
+}
+
+func (f *fakeKMSClient) AsymmetricSign(ctx context.Context,
+	req *kmspb.AsymmetricSignRequest,
+	opts ...gax.CallOption) (*kmspb.AsymmetricSignResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return nil, err
+	}
+	key, err := f.enabledKey(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	digest := req.GetDigest().GetSha256()
+	if int64(crc32.Checksum(digest, crc32cTable)) != req.GetDigestCrc32C().GetValue() {
+		return nil, status.Error(codes.InvalidArgument, "digest corrupted")
+	}
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &kmspb.AsymmetricSignResponse{
+		Signature: signature,
+		SignatureCrc32C: wrapperspb.Int64(
+			int64(crc32.Checksum(signature, crc32cTable))),
+		VerifiedDigestCrc32C: true,
+		Name:                 req.GetName(),
+	}, nil
+}
+
+func (f *fakeKMSClient) Close() error {
+	return nil
+}
+
+// Initialize a Google Cloud KMS provider using the specified fake client, with
+// a certificate store in the specified directory.
+func newTestProvider(t *testing.T, dir string,
+	client *fakeKMSClient) (*GcpKmsProvider, certstore.CertStore) {
+	_, store := kmstest.NewConfig(t, dir, common.KmsProviderGcp,
+		map[string]string{
+			"CA_GCP_KMS_PROJECT_ID": testProjectID,
+			"CA_GCP_KMS_LOCATION":   testLocation,
+			"CA_GCP_KMS_KEY_RING":   testKeyRing,
+		})
+
+	provider := &GcpKmsProvider{logger: zap.NewNop()}
+	err := provider.initProvider(client, &settings, store, nil)
+	if err != nil {
+		store.Shutdown()
+		t.Fatalf("Failed to initialize the Google Cloud KMS provider: %v", err)
+	}
+	return provider, store
+}
+
+func TestGcpKmsProvider_DeviceCertificate(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeKMSClient()
+	provider, store := newTestProvider(t, t.TempDir(), client)
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	// Tenant signing keys are identified using the tenant ID.
+	tenantID := uuid.NewString()
+	_, err := provider.CreateTenantSigningCertificate(ctx, tenantID, "GCP tenant")
+	if err != nil {
+		t.Fatalf("Failed to create the tenant signing certificate: %v", err)
+	}
+	keyName := fmt.Sprintf("projects/%s/locations/%s/keyRings/%s/cryptoKeys/%s",
+		testProjectID, testLocation, testKeyRing, tenantID)
+	if _, ok := client.keys[keyName]; !ok {
+		t.Fatalf("Expected the tenant key %s to be created", keyName)
+	}
+
+	kmstest.CheckDeviceCertificates(t, provider, tenantID)
+
+	// Deleting the tenant schedules destruction of the tenant key version.
+	err = provider.DeleteTenantSigningCertificate(ctx, tenantID)
+	if err != nil {
+		t.Fatalf("Failed to delete the tenant signing certificate: %v", err)
+	}
+	if state := client.versions[keyName][0].State; state !=
+		kmspb.CryptoKeyVersion_DESTROY_SCHEDULED {
+		t.Errorf("Expected the tenant key version to be destroyed, got %v",
+			state)
+	}
+
+	// Re-creating the tenant creates a new key version of the tenant key.
+	_, err = provider.CreateTenantSigningCertificate(ctx, tenantID, "GCP tenant")
+	if err != nil {
+		t.Fatalf("Failed to re-create the tenant signing certificate: %v", err)
+	}
+	if len(client.versions[keyName]) != 2 {
+		t.Errorf("Expected a new key version to be created, got %d versions",
+			len(client.versions[keyName]))
+	}
+}
+
+func TestGcpKmsProvider_Restart(t *testing.T) {
+	dir := t.TempDir()
+	client := newFakeKMSClient()
+	kmstest.CheckRestart(t, func() (kms_providers.KmsProvider,
+		certstore.CertStore) {
+		return newTestProvider(t, dir, client)
+	})
+}
+
+func TestGcpKmsProvider_Unavailable(t *testing.T) {
+	client := newFakeKMSClient()
+	provider, store := newTestProvider(t, t.TempDir(), client)
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	kmstest.CheckUnavailable(t, provider, func() {
+		client.lock.Lock()
+		client.failNext = status.Error(codes.Unavailable, "service unavailable")
+		client.lock.Unlock()
+	})
+}
+
+func TestKMSSigner_UnsupportedOptions(t *testing.T) {
+	kmstest.CheckUnsupportedOptions(t, &KMSSigner{keyVersion: "unused"},
+		crypto.SHA384)
+}
+
+func TestWrapGcpError(t *testing.T) {
+	tests := []struct {
+		err      error
+		category caerrors.Category
+	}{
+		{status.Error(codes.NotFound, "key not found"), caerrors.NotFound},
+		{status.Error(codes.AlreadyExists, "key exists"), caerrors.Conflict},
+		{status.Error(codes.ResourceExhausted, "quota exceeded"),
+			caerrors.DependencyUnavailable},
+		{status.Error(codes.Unavailable, "service unavailable"),
+			caerrors.DependencyUnavailable},
+		{status.Error(codes.PermissionDenied, "permission denied"), caerrors.Internal},
+		{errors.New("connection refused"), caerrors.DependencyUnavailable},
+	}
+
+	for _, test := range tests {
+		err := wrapGcpError("gcp call failed", test.err)
+		if category := caerrors.CategoryOf(err); category != test.category {
+			t.Errorf("wrapGcpError(%v) category = %v, expected %v", test.err,
+				category, test.category)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/gcp_kms
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Initializes the Google Cloud KMS provider.
+package gcp_kms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"go.uber.org/zap"
+)
+
+var (
+	// Settings of the Google Cloud KMS provider, read from the gcp_kms
+	// section under certificate_authority/providers in the configuration
+	// file.
+	settings Settings
+)
+
+// Settings represents configuration settings for the Google Cloud KMS
+// provider, which generates and uses the CA's signing keys within a key ring
+// in Google Cloud KMS. Credentials are obtained using Application Default
+// Credentials.
+type Settings struct {
+	// ID of the Google Cloud project containing the key ring.
+	ProjectID string `yaml:"project_id"`
+
+	// Location of the key ring (eg. us-west1 or global).
+	Location string `yaml:"location"`
+
+	// Name of the key ring holding the CA's signing keys.
+	KeyRing string `yaml:"key_ring"`
+}
+
+// Register the Google Cloud KMS provider.
+func init() {
+	kms_providers.Register(common.KmsProviderGcp, func() kms_providers.KmsProvider {
+		return &GcpKmsProvider{}
+	}, config.ProviderSchema{
+		Settings: &settings,
+		Env: map[string]config.EnvOverride{
+			"CA_GCP_KMS_PROJECT_ID": {Value: &settings.ProjectID},
+			"CA_GCP_KMS_LOCATION":   {Value: &settings.Location},
+			"CA_GCP_KMS_KEY_RING":   {Value: &settings.KeyRing},
+		},
+		Validate: func(*config.ConfigMgr) error {
+			if (settings.ProjectID == "") || (settings.Location == "") ||
+				(settings.KeyRing == "") {
+				return errors.New("the project, location and key ring of the Google Cloud KMS provider must be specified")
+			}
+			return nil
+		},
+	})
+}
+
+// GcpKmsProvider - uses Google Cloud KMS for cryptographic operations. The root
+// and signing keys are generated within the Cloud HSM and do not leave Google
+// Cloud KMS when consumed for signing operations. Keys are created within the
+// configured key ring, and are identified using the tenant ID.
+type GcpKmsProvider struct {
+	logger *zap.Logger
+
+	// Issues and manages certificates using the keys held within Google
+	// Cloud KMS. The key ID of each key is the resource name of its key
+	// version.
+	kms_providers.KeyBackendProvider
+}
+
+// Init - initialize the Google Cloud KMS provider.
+func (p *GcpKmsProvider) Init(logger *zap.Logger, cfgMgr *config.ConfigMgr,
+	store certstore.CertStore) error {
+	p.logger = logger
+	p.logger.Info("Google Cloud KMS provider settings",
+		zap.String(" - Project ID:", settings.ProjectID),
+		zap.String(" - Location:", settings.Location),
+		zap.String(" - Key ring:", settings.KeyRing),
+	)
+
+	// Initialize a client to Google Cloud KMS using Application Default
+	// Credentials.
+	client, err := kms.NewKeyManagementClient(context.Background())
+	if err != nil {
+		p.logger.Error("Failed to initialize the Google Cloud KMS client!",
+			zap.Error(err),
+		)
+		return wrapGcpError(
+			"failed to initialize the Google Cloud KMS client", err)
+	}
+
+	return p.initProvider(&cloudKmsClient{client}, &settings,
+		store, kms_providers.NewSigningPool(
+			cfgMgr.GetSigningConfig().MaxConcurrentOperations))
+}
+
+// initProvider - initialize the Google Cloud KMS provider using the specified
+// client to Google Cloud KMS. Signing operations are bounded by the specified
+// signing pool.
+func (p *GcpKmsProvider) initProvider(client GcpKMSClient, cfg *Settings,
+	store certstore.CertStore, signingPool *kms_providers.SigningPool) error {
+	backend := &gcpKeyBackend{
+		logger: p.logger,
+		client: client,
+		keyRing: fmt.Sprintf("projects/%s/locations/%s/keyRings/%s",
+			cfg.ProjectID, cfg.Location, cfg.KeyRing),
+	}
+
+	// Initialize the CA certificate and the common signing certificate, and
+	// use the specified certificate store to persist signing certificates. If
+	// the CA has not been initialized yet, the CA key is created within
+	// Google Cloud KMS and used to issue the CA certificate.
+	err := p.KeyBackendProvider.Init(context.Background(), p.logger, backend,
+		store, signingPool, gcpKmsCACertID, gcpKmsCAKeyID)
+	if err != nil {
+		backend.Close()
+		return err
+	}
+
+	p.logger.Info("Google Cloud KMS provider initialized successfully!",
+		zap.String("Key ring:", backend.keyRing),
+	)
+	return nil
+}
+
+// Shutdown - clean up and shutdown the Google Cloud KMS provider.
+func (p *GcpKmsProvider) Shutdown() {
+	p.KeyBackendProvider.Shutdown()
+	p.logger.Info("Google Cloud KMS provider shutdown!")
+}
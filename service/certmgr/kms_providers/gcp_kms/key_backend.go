@@ -0,0 +1,69 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/gcp_kms
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Exposes the keys held within Google Cloud KMS to the implementation of the
+// KMS provider shared with other providers holding their keys within a key
+// management service.
+package gcp_kms
+
+import (
+	"context"
+	"crypto"
+
+	"go.uber.org/zap"
+)
+
+// gcpKeyBackend - creates and uses keys held within the key ring in Google
+// Cloud KMS. Keys are named using the tenant ID, and are identified using the
+// resource name of their key version.
+type gcpKeyBackend struct {
+	logger *zap.Logger
+
+	// Google Cloud KMS methods consumed by the provider.
+	client GcpKMSClient
+
+	// Resource name of the key ring holding the keys.
+	keyRing string
+}
+
+func (b *gcpKeyBackend) Name() string {
+	return "Google Cloud KMS"
+}
+
+func (b *gcpKeyBackend) TenantKeyName(tenantID string) string {
+	return tenantID
+}
+
+func (b *gcpKeyBackend) CreateKey(ctx context.Context,
+	keyID string) (string, error) {
+	return b.newKmsKey(ctx, keyID)
+}
+
+func (b *gcpKeyBackend) PublicKey(ctx context.Context,
+	keyVersion string) (crypto.PublicKey, error) {
+	return b.getKmsPublicKey(ctx, keyVersion)
+}
+
+func (b *gcpKeyBackend) Signer(ctx context.Context,
+	keyVersion string) (crypto.Signer, error) {
+	signer, err := newKMSSigner(ctx, b, keyVersion)
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+func (b *gcpKeyBackend) DeleteKey(ctx context.Context, keyID string) error {
+	return b.deleteKmsKey(ctx, keyID)
+}
+
+func (b *gcpKeyBackend) Close() {
+	err := b.client.Close()
+	if err != nil {
+		b.logger.Error("Failed to close the Google Cloud KMS client!",
+			zap.Error(err),
+		)
+	}
+}
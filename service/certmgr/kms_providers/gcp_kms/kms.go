@@ -0,0 +1,454 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/gcp_kms
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements various operations exposed by the Google Cloud KMS service. These
+// capabilities are used by the Google Cloud KMS provider to create and manage
+// keys in Google Cloud KMS and sign certificates using the right keys.
+package gcp_kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/googleapis/gax-go/v2"
+	"go.uber.org/zap"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	// Timeouts that apply to requests made to Google Cloud KMS.
+	gcpKmsRequestTimeout = 5 * time.Second
+
+	// Key versions of asymmetric keys are generated asynchronously by Google
+	// Cloud KMS. Wait this long for a key version to be generated, polling
+	// its state at the specified interval.
+	gcpKmsKeyGenerationTimeout      = 60 * time.Second
+	gcpKmsKeyGenerationPollInterval = 250 * time.Millisecond
+
+	// The ID of the crypto key used as the CA key. The CA key can be found in
+	// the configured key ring using this ID. Tenant signing keys are
+	// identified using the tenant ID, and the common signing key using the
+	// ID "SharedTenantSigningKey".
+	gcpKmsCAKeyID = "CAKey"
+
+	// ID under which the CA certificate is persisted in the certificate
+	// store.
+	gcpKmsCACertID = "gcp_kms/CAKey"
+
+	// Algorithm used by keys created in Google Cloud KMS.
+	gcpKmsKeyAlgorithm = kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA256
+
+	// Google Cloud KMS operation names
+	gcpKmsOpGetCryptoKey            = "GetCryptoKey"
+	gcpKmsOpCreateCryptoKey         = "CreateCryptoKey"
+	gcpKmsOpListCryptoKeyVersions   = "ListCryptoKeyVersions"
+	gcpKmsOpGetCryptoKeyVersion     = "GetCryptoKeyVersion"
+	gcpKmsOpCreateCryptoKeyVersion  = "CreateCryptoKeyVersion"
+	gcpKmsOpDestroyCryptoKeyVersion = "DestroyCryptoKeyVersion"
+	gcpKmsOpGetPublicKey            = "GetPublicKey"
+	gcpKmsOpAsymmetricSign          = "AsymmetricSign"
+)
+
+// Table used to compute CRC32C checksums, which are used to verify the
+// integrity of data exchanged with Google Cloud KMS.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// An interface exposing Google Cloud KMS methods consumed by the provider.
+type GcpKMSClient interface {
+	GetCryptoKey(context.Context, *kmspb.GetCryptoKeyRequest, ...gax.CallOption) (*kmspb.CryptoKey, error)
+	CreateCryptoKey(context.Context, *kmspb.CreateCryptoKeyRequest, ...gax.CallOption) (*kmspb.CryptoKey, error)
+	ListCryptoKeyVersions(context.Context, *kmspb.ListCryptoKeyVersionsRequest, ...gax.CallOption) ([]*kmspb.CryptoKeyVersion, error)
+	GetCryptoKeyVersion(context.Context, *kmspb.GetCryptoKeyVersionRequest, ...gax.CallOption) (*kmspb.CryptoKeyVersion, error)
+	CreateCryptoKeyVersion(context.Context, *kmspb.CreateCryptoKeyVersionRequest, ...gax.CallOption) (*kmspb.CryptoKeyVersion, error)
+	DestroyCryptoKeyVersion(context.Context, *kmspb.DestroyCryptoKeyVersionRequest, ...gax.CallOption) (*kmspb.CryptoKeyVersion, error)
+	GetPublicKey(context.Context, *kmspb.GetPublicKeyRequest, ...gax.CallOption) (*kmspb.PublicKey, error)
+	AsymmetricSign(context.Context, *kmspb.AsymmetricSignRequest, ...gax.CallOption) (*kmspb.AsymmetricSignResponse, error)
+	Close() error
+}
+
+// cloudKmsClient - adapts the Google Cloud KMS client to the GcpKMSClient
+// interface. Key versions are listed using an iterator, which is drained so
+// that the interface can be implemented by fakes in unit tests.
+type cloudKmsClient struct {
+	*kms.KeyManagementClient
+}
+
+// ListCryptoKeyVersions - list all key versions of the specified crypto key.
+func (c *cloudKmsClient) ListCryptoKeyVersions(ctx context.Context,
+	req *kmspb.ListCryptoKeyVersionsRequest,
+	opts ...gax.CallOption) ([]*kmspb.CryptoKeyVersion, error) {
+	var versions []*kmspb.CryptoKeyVersion
+	it := c.KeyManagementClient.ListCryptoKeyVersions(ctx, req, opts...)
+	for {
+		version, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return versions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+}
+
+// Returns the resource name of the crypto key with the specified ID in the
+// configured key ring.
+func (b *gcpKeyBackend) cryptoKeyName(keyID string) string {
+	return fmt.Sprintf("%s/cryptoKeys/%s", b.keyRing, keyID)
+}
+
+// newKmsKey - Create a new asymmetric signing key in Google Cloud KMS with the
+// requested key ID, and return the resource name of the key version to use
+// for signing. If the requested key already exists and has a usable key
+// version, that key version is used. Keys are protected by the Cloud HSM and
+// cannot be exported.
+func (b *gcpKeyBackend) newKmsKey(ctx context.Context,
+	keyID string) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	default:
+	}
+
+	// Generate a context and specify the timeout for creating the key, which
+	// includes waiting for the key version to be generated.
+	ctx, cancel := context.WithTimeout(ctx, gcpKmsKeyGenerationTimeout)
+	defer cancel()
+	keyName := b.cryptoKeyName(keyID)
+
+	// Check if the requested key already exists in Google Cloud KMS.
+	start := time.Now()
+	_, err := b.client.GetCryptoKey(ctx, &kmspb.GetCryptoKeyRequest{
+		Name: keyName,
+	})
+	metrics.ReportLatencyMetric(metrics.MetricGcpKmsRequestLatency, start,
+		gcpKmsOpGetCryptoKey)
+	err = wrapGcpError("failed to get key from Google Cloud KMS", err)
+	if caerrors.Is(err, caerrors.NotFound) {
+		// Create the key in Google Cloud KMS. The first key version of the
+		// key is generated along with the key.
+		start = time.Now()
+		_, err = b.client.CreateCryptoKey(ctx, &kmspb.CreateCryptoKeyRequest{
+			Parent:      b.keyRing,
+			CryptoKeyId: keyID,
+			CryptoKey: &kmspb.CryptoKey{
+				Purpose: kmspb.CryptoKey_ASYMMETRIC_SIGN,
+				VersionTemplate: &kmspb.CryptoKeyVersionTemplate{
+					Algorithm:       gcpKmsKeyAlgorithm,
+					ProtectionLevel: kmspb.ProtectionLevel_HSM,
+				},
+			},
+		})
+		metrics.ReportLatencyMetric(metrics.MetricGcpKmsRequestLatency, start,
+			gcpKmsOpCreateCryptoKey)
+		if err != nil {
+			b.logger.Error("Failed to create the requested key in Google Cloud KMS",
+				zap.String("Key ID: ", keyID),
+				zap.Error(err),
+			)
+			metrics.MetricGcpKmsKeyCreationFailures.Inc()
+			return "", wrapGcpError(
+				"failed to create key in Google Cloud KMS", err)
+		}
+		metrics.MetricGcpKmsKeyCreated.Inc()
+		b.logger.Info("Created the requested key in Google Cloud KMS",
+			zap.String("Key ID: ", keyID),
+		)
+	} else if err != nil {
+		b.logger.Error("Encountered an error checking if key exists in Google Cloud KMS!",
+			zap.String("Key ID: ", keyID),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
+	// Find the key version to use. If the key versions of an existing key
+	// have all been destroyed (eg. the tenant was deleted earlier), a new
+	// key version is created.
+	version, err := b.getKmsKeyVersion(ctx, keyName)
+	if caerrors.Is(err, caerrors.NotFound) {
+		start = time.Now()
+		version, err = b.client.CreateCryptoKeyVersion(ctx,
+			&kmspb.CreateCryptoKeyVersionRequest{
+				Parent:           keyName,
+				CryptoKeyVersion: &kmspb.CryptoKeyVersion{},
+			})
+		metrics.ReportLatencyMetric(metrics.MetricGcpKmsRequestLatency, start,
+			gcpKmsOpCreateCryptoKeyVersion)
+		if err != nil {
+			b.logger.Error("Failed to create a key version in Google Cloud KMS",
+				zap.String("Key ID: ", keyID),
+				zap.Error(err),
+			)
+			metrics.MetricGcpKmsKeyCreationFailures.Inc()
+			return "", wrapGcpError(
+				"failed to create key version in Google Cloud KMS", err)
+		}
+		metrics.MetricGcpKmsKeyCreated.Inc()
+	} else if err != nil {
+		return "", err
+	}
+
+	// Wait for the key version to be generated.
+	err = b.waitForKmsKeyVersion(ctx, version)
+	if err != nil {
+		b.logger.Error("Key version was not generated in Google Cloud KMS!",
+			zap.String("Key version: ", version.GetName()),
+			zap.Error(err),
+		)
+		return "", err
+	}
+	return version.GetName(), nil
+}
+
+// getKmsKeyVersion - get the most recently created key version of the
+// specified key that is either enabled or being generated. An error with the
+// category NotFound is returned if the key has no such key version.
+func (b *gcpKeyBackend) getKmsKeyVersion(ctx context.Context,
+	keyName string) (*kmspb.CryptoKeyVersion, error) {
+	start := time.Now()
+	versions, err := b.client.ListCryptoKeyVersions(ctx,
+		&kmspb.ListCryptoKeyVersionsRequest{
+			Parent: keyName,
+		})
+	metrics.ReportLatencyMetric(metrics.MetricGcpKmsRequestLatency, start,
+		gcpKmsOpListCryptoKeyVersions)
+	if err != nil {
+		b.logger.Error("Failed to list the key versions in Google Cloud KMS!",
+			zap.String("Key name: ", keyName),
+			zap.Error(err),
+		)
+		return nil, wrapGcpError(
+			"failed to list key versions in Google Cloud KMS", err)
+	}
+
+	var latest *kmspb.CryptoKeyVersion
+	for _, version := range versions {
+		if (version.GetState() != kmspb.CryptoKeyVersion_ENABLED) &&
+			(version.GetState() != kmspb.CryptoKeyVersion_PENDING_GENERATION) {
+			continue
+		}
+		if (latest == nil) || latest.GetCreateTime().AsTime().Before(
+			version.GetCreateTime().AsTime()) {
+			latest = version
+		}
+	}
+	if latest == nil {
+		return nil, caerrors.New(caerrors.NotFound,
+			"no usable key version found in Google Cloud KMS")
+	}
+	return latest, nil
+}
+
+// waitForKmsKeyVersion - wait for the specified key version to be generated
+// and enabled.
+func (b *gcpKeyBackend) waitForKmsKeyVersion(ctx context.Context,
+	version *kmspb.CryptoKeyVersion) error {
+	for version.GetState() == kmspb.CryptoKeyVersion_PENDING_GENERATION {
+		select {
+		case <-ctx.Done():
+			return caerrors.Wrap(caerrors.DependencyUnavailable,
+				"timed out waiting for key generation in Google Cloud KMS",
+				ctx.Err())
+		case <-time.After(gcpKmsKeyGenerationPollInterval):
+		}
+
+		var err error
+		start := time.Now()
+		version, err = b.client.GetCryptoKeyVersion(ctx,
+			&kmspb.GetCryptoKeyVersionRequest{
+				Name: version.GetName(),
+			})
+		metrics.ReportLatencyMetric(metrics.MetricGcpKmsRequestLatency, start,
+			gcpKmsOpGetCryptoKeyVersion)
+		if err != nil {
+			return wrapGcpError(
+				"failed to get key version from Google Cloud KMS", err)
+		}
+	}
+
+	if version.GetState() != kmspb.CryptoKeyVersion_ENABLED {
+		return caerrors.New(caerrors.Internal,
+			fmt.Sprintf("key version is in state %s in Google Cloud KMS",
+				version.GetState()))
+	}
+	return nil
+}
+
+// deleteKmsKey - Schedule destruction of the key versions of the key with the
+// specified key ID in Google Cloud KMS. Keys cannot be deleted from Google
+// Cloud KMS, so the key itself is retained and a new key version is created
+// if the key is used again.
+func (b *gcpKeyBackend) deleteKmsKey(ctx context.Context, keyID string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	// Generate a context and specify the timeout for the KMS calls.
+	ctx, cancel := context.WithTimeout(ctx, gcpKmsRequestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	versions, err := b.client.ListCryptoKeyVersions(ctx,
+		&kmspb.ListCryptoKeyVersionsRequest{
+			Parent: b.cryptoKeyName(keyID),
+		})
+	metrics.ReportLatencyMetric(metrics.MetricGcpKmsRequestLatency, start,
+		gcpKmsOpListCryptoKeyVersions)
+	if err != nil {
+		b.logger.Error("Failed to list the key versions in Google Cloud KMS!",
+			zap.String("Key ID:", keyID),
+			zap.Error(err),
+		)
+		metrics.MetricGcpKmsKeyDeletionFailures.Inc()
+		return wrapGcpError(
+			"failed to list key versions in Google Cloud KMS", err)
+	}
+
+	// Schedule destruction of each key version that hasn't already been
+	// destroyed.
+	for _, version := range versions {
+		if (version.GetState() != kmspb.CryptoKeyVersion_ENABLED) &&
+			(version.GetState() != kmspb.CryptoKeyVersion_DISABLED) {
+			continue
+		}
+
+		start = time.Now()
+		_, err = b.client.DestroyCryptoKeyVersion(ctx,
+			&kmspb.DestroyCryptoKeyVersionRequest{
+				Name: version.GetName(),
+			})
+		metrics.ReportLatencyMetric(metrics.MetricGcpKmsRequestLatency, start,
+			gcpKmsOpDestroyCryptoKeyVersion)
+		if err != nil {
+			b.logger.Error("Failed to schedule key version destruction in Google Cloud KMS!",
+				zap.String("Key version:", version.GetName()),
+				zap.Error(err),
+			)
+			metrics.MetricGcpKmsKeyDeletionFailures.Inc()
+			return wrapGcpError(
+				"failed to destroy key version in Google Cloud KMS", err)
+		}
+	}
+
+	metrics.MetricGcpKmsKeyDeleted.Inc()
+	return nil
+}
+
+// getKmsPublicKey - retrieve the public key of the specified key version.
+func (b *gcpKeyBackend) getKmsPublicKey(ctx context.Context,
+	keyVersion string) (crypto.PublicKey, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	// Generate a context and specify the timeout for the KMS call.
+	ctx, cancel := context.WithTimeout(ctx, gcpKmsRequestTimeout)
+	defer cancel()
+
+	// Retrieve the public key from Google Cloud KMS.
+	start := time.Now()
+	response, err := b.client.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{
+		Name: keyVersion,
+	})
+	metrics.ReportLatencyMetric(metrics.MetricGcpKmsRequestLatency, start,
+		gcpKmsOpGetPublicKey)
+	if err != nil {
+		b.logger.Error("Failed to get public key associated with key in Google Cloud KMS",
+			zap.String("Key version: ", keyVersion),
+			zap.Error(err),
+		)
+		metrics.MetricGcpKmsKeyRetrievalFailures.Inc()
+		return nil, wrapGcpError("cannot get public key", err)
+	}
+
+	// Verify the integrity of the public key retrieved from Google Cloud KMS.
+	if (response.GetPemCrc32C() != nil) &&
+		(int64(crc32.Checksum([]byte(response.GetPem()), crc32cTable)) !=
+			response.GetPemCrc32C().GetValue()) {
+		b.logger.Error("Public key retrieved from Google Cloud KMS was corrupted in transit!",
+			zap.String("Key version: ", keyVersion),
+		)
+		metrics.MetricGcpKmsKeyRetrievalFailures.Inc()
+		return nil, caerrors.New(caerrors.DependencyUnavailable,
+			"public key was corrupted in transit")
+	}
+	metrics.MetricGcpKmsKeyRetrieved.Inc()
+
+	// Parse the public key retrieved from Google Cloud KMS.
+	block, _ := pem.Decode([]byte(response.GetPem()))
+	if block == nil {
+		b.logger.Error("No public key returned by Google Cloud KMS for the key!",
+			zap.String("Key version: ", keyVersion),
+		)
+		return nil, caerrors.New(caerrors.Internal, "cannot parse public key")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		b.logger.Error("Failed to parse public key associated with key in Google Cloud KMS",
+			zap.String("Key version: ", keyVersion),
+		)
+		return nil, caerrors.Wrap(caerrors.Internal,
+			"cannot parse public key", err)
+	}
+
+	return publicKey, nil
+}
+
+// signKms - sign the specified SHA-256 digest using the specified key version.
+func (b *gcpKeyBackend) signKms(ctx context.Context, keyVersion string,
+	digest []byte) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	// Generate a context and specify the timeout for the KMS call.
+	ctx, cancel := context.WithTimeout(ctx, gcpKmsRequestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	response, err := b.client.AsymmetricSign(ctx, &kmspb.AsymmetricSignRequest{
+		Name: keyVersion,
+		Digest: &kmspb.Digest{
+			Digest: &kmspb.Digest_Sha256{Sha256: digest},
+		},
+		DigestCrc32C: wrapperspb.Int64(
+			int64(crc32.Checksum(digest, crc32cTable))),
+	})
+	metrics.ReportLatencyMetric(metrics.MetricGcpKmsRequestLatency, start,
+		gcpKmsOpAsymmetricSign)
+	if err != nil {
+		return nil, wrapGcpError("failed to sign using Google Cloud KMS",
+			err)
+	}
+
+	// Verify that the digest was received intact by Google Cloud KMS, and
+	// that the signature was received intact from Google Cloud KMS.
+	if !response.GetVerifiedDigestCrc32C() ||
+		(response.GetName() != keyVersion) ||
+		(int64(crc32.Checksum(response.GetSignature(), crc32cTable)) !=
+			response.GetSignatureCrc32C().GetValue()) {
+		return nil, caerrors.New(caerrors.DependencyUnavailable,
+			"signing request was corrupted in transit")
+	}
+	return response.GetSignature(), nil
+}
@@ -0,0 +1,96 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/gcp_kms
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements a crypto signer interface that is used to sign certificates using
+// the Google Cloud KMS service.
+package gcp_kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"io"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"go.uber.org/zap"
+)
+
+// KMSSigner implements the crypto/Signer interface that can be used for signing
+// operations using a Google Cloud KMS key.
+// see https://golang.org/pkg/crypto/#Signer
+type KMSSigner struct {
+	// The key backend holding the client to Google Cloud KMS.
+	backend *gcpKeyBackend
+
+	// Resource name of the key version used for signing.
+	keyVersion string
+
+	// Public key.
+	publicKey crypto.PublicKey
+
+	// Context of the request on behalf of which signing operations are
+	// performed. Signing requests made to Google Cloud KMS are bounded by
+	// its deadline.
+	ctx context.Context
+}
+
+// Initializes a new instance of the KMS signer using the requested key
+// version, which signs on behalf of the request with the specified context.
+func newKMSSigner(ctx context.Context, backend *gcpKeyBackend,
+	keyVersion string) (*KMSSigner, error) {
+	key, err := backend.getKmsPublicKey(ctx, keyVersion)
+	if err != nil {
+		backend.logger.Error("Failed to get the public key from Google Cloud KMS!",
+			zap.String("Key version:", keyVersion),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return &KMSSigner{
+		backend:    backend,
+		keyVersion: keyVersion,
+		publicKey:  key,
+		ctx:        ctx,
+	}, nil
+}
+
+// Public returns the public key used by the signer.
+func (s *KMSSigner) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign the requested digest using Google Cloud KMS. Keys are created with the
+// RSA_SIGN_PKCS1_4096_SHA256 algorithm, so only RSA PKCS #1 v1.5 signatures
+// over SHA-256 digests are supported.
+func (s *KMSSigner) Sign(rand io.Reader, digest []byte,
+	opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		metrics.MetricGcpKmsSignatureFailures.Inc()
+		return nil, caerrors.New(caerrors.Internal,
+			"RSA-PSS signatures are not supported by the Google Cloud KMS signer")
+	}
+
+	if (opts.HashFunc() != crypto.SHA256) ||
+		(len(digest) != crypto.SHA256.Size()) {
+		metrics.MetricGcpKmsSignatureFailures.Inc()
+		return nil, caerrors.New(caerrors.Internal,
+			"unsupported digest for the Google Cloud KMS signer")
+	}
+
+	signature, err := s.backend.signKms(s.ctx, s.keyVersion, digest)
+	if err != nil {
+		s.backend.logger.Error("Failed to sign using Google Cloud KMS!",
+			zap.String("Key version:", s.keyVersion),
+			zap.Error(err),
+		)
+		metrics.MetricGcpKmsSignatureFailures.Inc()
+		return nil, err
+	}
+
+	metrics.MetricGcpKmsSignatureSuccess.Inc()
+	return signature, nil
+}
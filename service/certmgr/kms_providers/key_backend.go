@@ -0,0 +1,161 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the parts of a KMS provider which are common to providers holding
+// the CA and signing keys within a key management service (eg. a PKCS#11
+// token, Vault, Google Cloud KMS or Azure Key Vault). Such providers implement
+// the KeyBackend interface to create and use keys within the key management
+// service, and embed a KeyBackendProvider which issues and manages the CA,
+// tenant signing and device certificates using those keys.
+package kms_providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"sync/atomic"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/common"
+	"go.uber.org/zap"
+)
+
+// KeyBackend - defines an interface that must be implemented by key management
+// services holding non-exportable CA and signing keys on behalf of a
+// KeyBackendProvider. Keys are created using a key name. Each key is then
+// identified using the key ID returned when it is created, which is persisted
+// in the certificate store along with the certificate issued for the key.
+type KeyBackend interface {
+	// Name - Return the name of the key management service, used in log
+	// messages and errors.
+	Name() string
+
+	// TenantKeyName - Return the name of the signing key used by the
+	// specified tenant.
+	TenantKeyName(tenantID string) string
+
+	// CreateKey - Create a signing key with the specified key name and return
+	// its key ID. If the key already exists, it is re-used.
+	CreateKey(ctx context.Context, keyName string) (string, error)
+
+	// PublicKey - Return the public key of the key with the specified key ID.
+	PublicKey(ctx context.Context, keyID string) (crypto.PublicKey, error)
+
+	// Signer - Return a crypto signer which signs using the key with the
+	// specified key ID. Signing operations are bounded by the deadline of the
+	// specified context.
+	Signer(ctx context.Context, keyID string) (crypto.Signer, error)
+
+	// DeleteKey - Delete the key with the specified key name.
+	DeleteKey(ctx context.Context, keyName string) error
+
+	// Close - Release the resources held to access the key management
+	// service, once the provider has been shut down.
+	Close()
+}
+
+// KeyBackendProvider - implements the KmsProvider interface, except for Init
+// and Shutdown, using the keys held within a key management service.
+type KeyBackendProvider struct {
+	// The key management service, and the CA certificate and common signing
+	// certificate issued using its keys.
+	state atomic.Pointer[keyBackendState]
+}
+
+// keyBackendState - the key management service used by a KeyBackendProvider,
+// and the CA certificate and common signing certificate issued using its keys.
+// The state is not modified once initialized, and is replaced as a whole, so
+// that requests in flight while the state is replaced continue to use a
+// consistent key management service and set of certificates.
+type keyBackendState struct {
+	logger *zap.Logger
+
+	// The key management service holding the CA and signing keys.
+	backend KeyBackend
+
+	// The CA root certificate. The CA certificate is persisted in the
+	// certificate store using the specified certificate ID.
+	caCertID    string
+	caKeyName   string
+	caKeyID     string
+	caCert      *x509.Certificate
+	caCertBytes []byte
+
+	// The common signing certificate.
+	commonSigningCert *common.SigningCertificate
+
+	// Certificate store used to persist the CA certificate and tenant
+	// signing certificates.
+	store certstore.CertStore
+
+	// Bounds the number of signing operations performed concurrently.
+	signingPool *SigningPool
+}
+
+// Init - initialize the provider to issue certificates using the keys held
+// within the specified key management service, and initialize the CA
+// certificate and the common signing certificate. The CA key is created using
+// the specified key name, and the CA certificate is persisted in the specified
+// certificate store using the specified certificate ID. The CA certificate is
+// retrieved from the certificate store and checked against the CA key in the
+// key management service. If the CA has not been initialized yet, the CA key
+// is created and used to issue the CA certificate.
+func (p *KeyBackendProvider) Init(ctx context.Context, logger *zap.Logger,
+	backend KeyBackend, store certstore.CertStore, signingPool *SigningPool,
+	caCertID string, caKeyName string) error {
+	state := &keyBackendState{
+		logger:      logger,
+		backend:     backend,
+		caCertID:    caCertID,
+		caKeyName:   caKeyName,
+		store:       store,
+		signingPool: signingPool,
+	}
+
+	err := state.getCACertificate(ctx)
+	if err != nil {
+		logger.Error("Failed to initialize CA certificate!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// Initialize the common signing certificate.
+	state.commonSigningCert, err = state.getCommonSigningCertificate(ctx)
+	if err != nil {
+		logger.Error("Failed to get the common signing certificate from certificate store!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	p.state.Store(state)
+	return nil
+}
+
+// Shutdown - release the resources held to access the key management service.
+func (p *KeyBackendProvider) Shutdown() {
+	if state := p.state.Load(); state != nil {
+		state.backend.Close()
+	}
+}
+
+// Ping - check that the key management service is reachable, by retrieving
+// the public key of the CA key.
+func (p *KeyBackendProvider) Ping(ctx context.Context) error {
+	state := p.state.Load()
+	_, err := state.backend.PublicKey(ctx, state.caKeyID)
+	return err
+}
+
+// Backend - return the key management service used by the provider.
+func (p *KeyBackendProvider) Backend() KeyBackend {
+	return p.state.Load().backend
+}
+
+// CAKeyID - return the key ID of the CA key.
+func (p *KeyBackendProvider) CAKeyID() string {
+	return p.state.Load().caKeyID
+}
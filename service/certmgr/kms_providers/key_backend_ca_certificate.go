@@ -0,0 +1,166 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Generates the CA certificate used for signing certificate requests by KMS
+// providers holding their keys within a key management service.
+package kms_providers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/common"
+	"go.uber.org/zap"
+)
+
+// getCACertificate - Retrieve the CA certificate from the certificate store
+// and check to see if its public key matches the corresponding CA key stored
+// in the key management service. If the CA certificate is not present in the
+// certificate store, a new CA certificate is generated.
+func (s *keyBackendState) getCACertificate(ctx context.Context) error {
+	// Retrieve the CA certificate from the certificate store. The cache is
+	// bypassed, since a new CA certificate is generated if none is found.
+	certEntry, err := certstore.GetCertificateUncached(ctx, s.store, s.caCertID)
+	if err != nil {
+		if errors.Is(err, common.ErrCertStoreNotFound) {
+			return s.generateCACertificate(ctx)
+		}
+		s.logger.Error("Failed to get the CA certificate from the cert store",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// Retrieve the public key associated with the CA key.
+	caPublicKey, err := s.backend.PublicKey(ctx, certEntry.KmsKeyID)
+	if err != nil {
+		s.logger.Error("Failed to get public key associated with CA key",
+			zap.String("Key backend:", s.backend.Name()),
+			zap.String("CA key ID:", certEntry.KmsKeyID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	s.caCert, err = x509.ParseCertificate(certEntry.Certificate)
+	if err != nil {
+		s.logger.Error("Failed to parse the CA certificate!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// Check if the public key within the CA certificate matches that retrieved
+	// from the key management service. These must match in order to use the
+	// CA certificate successfully for signing purposes.
+	certPublicKey, ok := s.caCert.PublicKey.(*rsa.PublicKey)
+	if !ok || !certPublicKey.Equal(caPublicKey) {
+		s.logger.Error("CA certificate public key doesn't match the CA key!",
+			zap.String("Key backend:", s.backend.Name()),
+			zap.String("CA key ID:", certEntry.KmsKeyID),
+		)
+		return caerrors.New(caerrors.Internal, fmt.Sprintf(
+			"key mismatch: CA certificate public key doesn't match CA key in %s",
+			s.backend.Name()))
+	}
+
+	s.caKeyID = certEntry.KmsKeyID
+	s.caCertBytes = certEntry.Certificate
+	return nil
+}
+
+// generateCACertificate - Create the CA key within the key management
+// service, use it to issue the CA certificate and persist the CA certificate
+// in the certificate store. If the CA key already exists, it is re-used.
+func (s *keyBackendState) generateCACertificate(ctx context.Context) error {
+	// Instantiate a new CA certificate template.
+	caCertTpl, err := common.NewCACertificateTemplate()
+	if err != nil {
+		s.logger.Error("Failed to initialize the CA certificate template!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// Create a new CA key to use for the CA certificate.
+	s.caKeyID, err = s.backend.CreateKey(ctx, s.caKeyName)
+	if err != nil {
+		s.logger.Error("Failed to generate the CA key!",
+			zap.String("Key backend:", s.backend.Name()),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// Initialize a crypto signer that will be used to sign the CA
+	// certificate.
+	caSigner, err := s.backend.Signer(ctx, s.caKeyID)
+	if err != nil {
+		s.logger.Error("Failed to initialize a crypto signer for the CA!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// Generate the CA certificate and sign it using the crypto signer.
+	// This will cause the certificate to be signed using the CA key stored
+	// within the key management service.
+	s.caCertBytes, err = x509.CreateCertificate(rand.Reader, caCertTpl,
+		caCertTpl, caSigner.Public(), s.signingPool.Signer(ctx, caSigner))
+	if err != nil {
+		s.logger.Error("Failed to sign the CA certificate!",
+			zap.String("Key backend:", s.backend.Name()),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// Parse and store the signed CA certificate in memory.
+	s.caCert, err = x509.ParseCertificate(s.caCertBytes)
+	if err != nil {
+		s.logger.Error("Failed to parse the signed CA certificate!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// Persist the CA certificate in the certificate store, so that the same
+	// CA certificate is used when the CA is restarted.
+	err = s.store.AddCertificate(ctx, &common.SigningCertificate{
+		TenantID:    s.caCertID,
+		KmsKeyID:    s.caKeyID,
+		Certificate: s.caCertBytes,
+	})
+	if err != nil {
+		// The CA certificate may have been generated concurrently by another
+		// instance of the CA, in which case the stored CA certificate is used.
+		if errors.Is(err, common.ErrTenantExists) {
+			return s.getCACertificate(ctx)
+		}
+		s.logger.Error("Failed to add the CA certificate to the store!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	s.logger.Info("Successfully generated the CA certificate!",
+		zap.String("CA key ID:", s.caKeyID),
+	)
+	return nil
+}
+
+// GetCACertificates - get the CA certificate and the common signing
+// certificate.
+func (p *KeyBackendProvider) GetCACertificates(
+	ctx context.Context) ([]byte, []byte, error) {
+	state := p.state.Load()
+	return state.caCertBytes, state.commonSigningCert.Certificate, nil
+}
@@ -0,0 +1,118 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the ability to create and renew device certificates for KMS
+// providers holding their keys within a key management service.
+package kms_providers
+
+import (
+	"context"
+	"crypto/x509"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// CreateDeviceCertificate - Register a new device ID and issue a device
+// certificate.
+func (p *KeyBackendProvider) CreateDeviceCertificate(ctx context.Context,
+	tenantID string,
+	deviceCSR []byte) (string, []byte, []byte, time.Time, error) {
+	state := p.state.Load()
+
+	// Validate the specified parameters.
+	if (deviceCSR == nil) || (tenantID == "") {
+		state.logger.Error("Invalid CSR or tenant ID!")
+		return "", nil, nil, time.Now(), common.ErrInvalidParameter
+	}
+
+	// Generate a device ID for the device and issue the device certificate.
+	return state.issueDeviceCertificate(ctx, tenantID, uuid.New().String(), deviceCSR)
+}
+
+// NewDeviceCertificateIssuer - Resolve the signing certificate and signing key
+// used within the tenant, and return an issuer used to issue a batch of device
+// certificates within the tenant.
+func (p *KeyBackendProvider) NewDeviceCertificateIssuer(ctx context.Context,
+	tenantID string) (*DeviceCertificateIssuer, error) {
+	return p.state.Load().newDeviceCertificateIssuer(ctx, tenantID)
+}
+
+func (s *keyBackendState) newDeviceCertificateIssuer(ctx context.Context,
+	tenantID string) (*DeviceCertificateIssuer, error) {
+	if tenantID == "" {
+		s.logger.Error("Invalid tenant ID!")
+		return nil, common.ErrInvalidParameter
+	}
+
+	// Retrieve the signing certificate used within the tenant from the
+	// certificate store.
+	certEntry, err := s.getSigningCertificate(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantSigningCert, err := x509.ParseCertificate(certEntry.Certificate)
+	if err != nil {
+		s.logger.Error("Failed to parse the tenant signing certificate!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	// Initialize a crypto signer that will be used to sign the device
+	// certificates.
+	deviceSigner, err := s.backend.Signer(ctx, certEntry.KmsKeyID)
+	if err != nil {
+		s.logger.Error("Failed to initialize a crypto signer for the devices!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return NewDeviceCertificateIssuer(s.logger, tenantID, tenantSigningCert,
+		s.signingPool.Signer(ctx, deviceSigner), s.caCertBytes)
+}
+
+// RenewDeviceCertificate - Issue a fresh device certificate for the device with
+// the specified device ID.
+func (p *KeyBackendProvider) RenewDeviceCertificate(ctx context.Context,
+	tenantID string, deviceID string,
+	deviceCSR []byte) (string, []byte, []byte, time.Time, error) {
+	state := p.state.Load()
+
+	// Validate the specified parameters.
+	if (deviceCSR == nil) || (tenantID == "") || (deviceID == "") {
+		state.logger.Error("Invalid CSR, tenant ID or device ID!")
+		return "", nil, nil, time.Now(), common.ErrInvalidParameter
+	}
+
+	return state.issueDeviceCertificate(ctx, tenantID, deviceID, deviceCSR)
+}
+
+// issueDeviceCertificate - Issue a device certificate for the device with the
+// specified device ID in exchange for the specified CSR, signed using the
+// signing key used within the tenant. The device ID, the device certificate,
+// the parent certificates and the expiry time of the device certificate are
+// returned.
+func (s *keyBackendState) issueDeviceCertificate(ctx context.Context,
+	tenantID string,
+	deviceID string, deviceCSR []byte) (string, []byte, []byte, time.Time, error) {
+	issuer, err := s.newDeviceCertificateIssuer(ctx, tenantID)
+	if err != nil {
+		return "", nil, nil, time.Now(), err
+	}
+
+	deviceCertBytes, parentCerts, expiresAt, err := issuer.IssueDeviceCertificate(
+		deviceID, deviceCSR, nil)
+	if err != nil {
+		return "", nil, nil, time.Now(), err
+	}
+	return deviceID, deviceCertBytes, parentCerts, expiresAt, nil
+}
@@ -0,0 +1,274 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the ability to create and manage tenant signing certificates for
+// KMS providers holding their keys within a key management service. The
+// tenant signing certificate is used to sign device certificate requests. The
+// CA supports the use of both common and per-tenant signing certificates.
+package kms_providers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/common"
+	"go.mozilla.org/pkcs7"
+	"go.uber.org/zap"
+)
+
+// createCommonSigningCertificate - create a new signing certificate which
+// is used to sign device certificates for tenants that do not have a
+// distinct/separate tenant signing certificate.
+func (s *keyBackendState) createCommonSigningCertificate(
+	ctx context.Context) (string, error) {
+	// For the common signing certificate, we do not provide a tenant name
+	// to the certificate template. This certificate will have only the issuer
+	// name.
+	return s.createTenantSigningCertificate(ctx, common.CommonSigningKeyId, "")
+}
+
+// getCommonSigningCertificate - Get the common signing certificate which
+// is used to sign device certificates for tenants that do not have a
+// distinct/separate tenant signing certificate. If one doesn't already exist
+// in the certificate store, a new common signing certificate is created.
+func (s *keyBackendState) getCommonSigningCertificate(
+	ctx context.Context) (*common.SigningCertificate, error) {
+	tenantCert, err := s.store.GetCertificate(ctx, common.CommonSigningKeyId)
+	if err != nil {
+		s.logger.Error("Failed to get the common signing certificate from certificate store!",
+			zap.Error(err),
+		)
+
+		// Attempt to create the common signing certificate. It may have been
+		// created concurrently by another request, in which case it is used.
+		_, err := s.createCommonSigningCertificate(ctx)
+		if (err != nil) && !errors.Is(err, common.ErrTenantExists) {
+			s.logger.Error("Failed to create the common signing certificate!",
+				zap.Error(err),
+			)
+			return nil, err
+		}
+
+		// Now, return the newly created common signing certificate.
+		tenantCert, err = s.store.GetCertificate(ctx, common.CommonSigningKeyId)
+		if err != nil {
+			s.logger.Error("Failed to get the common signing certificate from certificate store!",
+				zap.Error(err),
+			)
+			return nil, err
+		}
+	}
+
+	return tenantCert, nil
+}
+
+// CreateTenantSigningCertificate - create a new tenant signing certificate for
+// the specified tenant. ErrTenantExists is returned if the tenant already has
+// a signing certificate.
+func (p *KeyBackendProvider) CreateTenantSigningCertificate(ctx context.Context,
+	tenantID string, tenantName string) (string, error) {
+	return p.state.Load().createTenantSigningCertificate(ctx, tenantID,
+		tenantName)
+}
+
+func (s *keyBackendState) createTenantSigningCertificate(ctx context.Context,
+	tenantID string, tenantName string) (string, error) {
+	var certEntry common.SigningCertificate
+
+	// Ensure that a signing certificate doesn't already exist for the tenant,
+	// so that it is not silently replaced. The check bypasses the cache, since
+	// the signing certificate may have been created or deleted by another
+	// instance of the CA.
+	_, err := certstore.GetCertificateUncached(ctx, s.store, tenantID)
+	if err == nil {
+		s.logger.Error("A signing certificate already exists for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+		)
+		return "", common.ErrTenantExists
+	}
+	if !errors.Is(err, common.ErrCertStoreNotFound) {
+		s.logger.Error("Failed to check for an existing tenant signing certificate!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
+	// Create a new key for the tenant signing certificate. The key is named
+	// using the tenant ID.
+	tenantKeyID, err := s.backend.CreateKey(ctx, s.backend.TenantKeyName(tenantID))
+	if err != nil {
+		s.logger.Error("Failed to generate a signing key!",
+			zap.String("Key backend:", s.backend.Name()),
+			zap.String("Tenant ID: ", tenantID),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
+	// Initialize the tenant signing certificate template.
+	tenantCertTpl, err := common.NewTenantSigningCertificateTemplate(tenantID,
+		tenantName)
+	if err != nil {
+		s.logger.Error("Failed to initialize a new tenant signing certificate template!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
+	// Initialize a crypto signer that will be used to sign the tenant
+	// signing certificate using the CA key.
+	caSigner, err := s.backend.Signer(ctx, s.caKeyID)
+	if err != nil {
+		s.logger.Error("Failed to initialize a crypto signer for the CA key!",
+			zap.String("Tenant ID: ", tenantID),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
+	// Get the public key associated with the newly created tenant key.
+	tenantPublicKey, err := s.backend.PublicKey(ctx, tenantKeyID)
+	if err != nil {
+		s.logger.Error("Failed to get public key associated with signing key",
+			zap.String("Tenant ID: ", tenantID),
+			zap.String("Tenant key ID: ", tenantKeyID),
+		)
+		return "", err
+	}
+
+	// Generate the tenant signing certificate and sign it using the crypto
+	// signer. This will cause the certificate to be signed using the CA
+	// certificate.
+	tenantCertBytes, err := x509.CreateCertificate(rand.Reader, tenantCertTpl,
+		s.caCert, tenantPublicKey, s.signingPool.Signer(ctx, caSigner))
+	if err != nil {
+		s.logger.Error("Failed to generate the signing certificate!",
+			zap.String("Tenant ID: ", tenantID),
+			zap.String("Tenant key ID: ", tenantKeyID),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
+	// Persist the tenant signing certificate in the certificate store.
+	certEntry.Certificate = tenantCertBytes
+	certEntry.TenantID = tenantID
+	certEntry.KmsKeyID = tenantKeyID
+
+	err = s.store.AddCertificate(ctx, &certEntry)
+	if err != nil {
+		s.logger.Error("Failed to add the tenant signing certificate to the store!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
+	s.logger.Info("Successfully generated the tenant signing certificate!",
+		zap.String("Tenant key ID:", tenantKeyID),
+	)
+	return string(tenantCertTpl.SubjectKeyId), nil
+}
+
+// GetTenantSigningCertificate - get the tenant signing certificate for
+// the specified tenant.
+func (p *KeyBackendProvider) GetTenantSigningCertificate(ctx context.Context,
+	tenantID string) ([]byte, error) {
+	state := p.state.Load()
+
+	// Retrieve the tenant signing certificate for the specified tenant.
+	tenantCert, err := state.store.GetCertificate(ctx, tenantID)
+	if err != nil {
+		state.logger.Error("Failed to retrieve the tenant signing certificate for the tenant.",
+			zap.String("Tenant ID:", tenantID),
+		)
+		return nil, err
+	}
+
+	return tenantCert.Certificate, nil
+}
+
+// GetSigningCertificateChain - get the chain of certificates used to sign
+// device certificates issued within the specified tenant.
+func (p *KeyBackendProvider) GetSigningCertificateChain(ctx context.Context,
+	tenantID string) ([]byte, error) {
+	state := p.state.Load()
+
+	// Use the tenant signing certificate if one exists for the tenant, else
+	// the common signing certificate.
+	certEntry, err := state.getSigningCertificate(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := []byte{}
+	chain = append(chain, certEntry.Certificate...)
+	chain = append(chain, state.caCertBytes...)
+
+	chain, err = pkcs7.DegenerateCertificate(chain)
+	if err != nil {
+		state.logger.Error("Failed to create degenerate PKCS7 object!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	return chain, nil
+}
+
+// DeleteTenantSigningCertificate - delete the tenant signing certificate for
+// the specified tenant.
+func (p *KeyBackendProvider) DeleteTenantSigningCertificate(ctx context.Context,
+	tenantID string) error {
+	state := p.state.Load()
+
+	// Delete the tenant signing certificate for the specified tenant.
+	err := state.store.DeleteCertificate(ctx, tenantID)
+	if err != nil {
+		state.logger.Error("Failed to delete the tenant signing certificate!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// Delete the key for the tenant from the key management service.
+	err = state.backend.DeleteKey(ctx, state.backend.TenantKeyName(tenantID))
+	if err != nil {
+		state.logger.Error("Failed to delete the tenant key!",
+			zap.String("Key backend:", state.backend.Name()),
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// getSigningCertificate - get the signing certificate used to sign device
+// certificates issued within the specified tenant. The tenant signing
+// certificate is used if one exists for the tenant, else the common signing
+// certificate.
+func (s *keyBackendState) getSigningCertificate(ctx context.Context,
+	tenantID string) (*common.SigningCertificate, error) {
+	certEntry, err := s.store.GetCertificate(ctx, tenantID)
+	if err != nil {
+		if !errors.Is(err, common.ErrCertStoreNotFound) {
+			s.logger.Error("Failed to retrieve the tenant signing certificate",
+				zap.String("Tenant ID:", tenantID),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+		certEntry = s.commonSigningCert
+	}
+	return certEntry, nil
+}
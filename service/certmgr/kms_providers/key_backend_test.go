@@ -0,0 +1,224 @@
+package kms_providers_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers/kmstest"
+	_ "github.com/HPInc/krypton-ca/service/certmgr/kms_providers/local_kms"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// fakeKeyBackend - an in-memory key backend. Keys are identified using their
+// key name followed by a version suffix, so that key IDs and key names differ.
+type fakeKeyBackend struct {
+	lock sync.Mutex
+	keys map[string]*rsa.PrivateKey
+
+	// Error returned by the next call, if any.
+	failNext error
+}
+
+func newFakeKeyBackend() *fakeKeyBackend {
+	return &fakeKeyBackend{keys: map[string]*rsa.PrivateKey{}}
+}
+
+func (b *fakeKeyBackend) injectedError() error {
+	err := b.failNext
+	b.failNext = nil
+	return err
+}
+
+func (b *fakeKeyBackend) Name() string {
+	return "the fake key backend"
+}
+
+func (b *fakeKeyBackend) TenantKeyName(tenantID string) string {
+	return "tenant-" + tenantID
+}
+
+func (b *fakeKeyBackend) CreateKey(ctx context.Context,
+	keyName string) (string, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err := b.injectedError(); err != nil {
+		return "", err
+	}
+	if _, ok := b.keys[keyName]; !ok {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return "", err
+		}
+		b.keys[keyName] = key
+	}
+	return keyName + "/1", nil
+}
+
+func (b *fakeKeyBackend) key(keyID string) (*rsa.PrivateKey, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err := b.injectedError(); err != nil {
+		return nil, err
+	}
+	for name, key := range b.keys {
+		if name+"/1" == keyID {
+			return key, nil
+		}
+	}
+	return nil, caerrors.New(caerrors.NotFound, "key not found")
+}
+
+func (b *fakeKeyBackend) PublicKey(ctx context.Context,
+	keyID string) (crypto.PublicKey, error) {
+	key, err := b.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return key.Public(), nil
+}
+
+func (b *fakeKeyBackend) Signer(ctx context.Context,
+	keyID string) (crypto.Signer, error) {
+	key, err := b.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (b *fakeKeyBackend) DeleteKey(ctx context.Context, keyName string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err := b.injectedError(); err != nil {
+		return err
+	}
+	delete(b.keys, keyName)
+	return nil
+}
+
+func (b *fakeKeyBackend) Close() {
+}
+
+// testProvider - a KMS provider which holds its keys in the fake key backend.
+type testProvider struct {
+	kms_providers.KeyBackendProvider
+}
+
+func (p *testProvider) Init(*zap.Logger, *config.ConfigMgr,
+	certstore.CertStore) error {
+	return nil
+}
+
+func (p *testProvider) Shutdown() {
+}
+
+// Initialize a provider holding its keys in the specified key backend, with a
+// certificate store in the specified directory.
+func newTestProvider(t *testing.T, dir string,
+	backend *fakeKeyBackend) (*testProvider, certstore.CertStore) {
+	_, store := kmstest.NewConfig(t, dir, common.KmsProviderLocal, nil)
+
+	provider := &testProvider{}
+	err := provider.KeyBackendProvider.Init(context.Background(), zap.NewNop(),
+		backend, store, nil, "test/CAKey", "CAKey")
+	if err != nil {
+		store.Shutdown()
+		t.Fatalf("Failed to initialize the provider: %v", err)
+	}
+	return provider, store
+}
+
+func TestKeyBackendProvider_DeviceCertificate(t *testing.T) {
+	ctx := context.Background()
+	backend := newFakeKeyBackend()
+	provider, store := newTestProvider(t, t.TempDir(), backend)
+	defer store.Shutdown()
+
+	if id := provider.CAKeyID(); id != "CAKey/1" {
+		t.Errorf("Expected the CA key ID to be returned by the backend, got %s",
+			id)
+	}
+
+	// Device certificates issued within tenants without a tenant signing
+	// certificate are signed using the common signing certificate.
+	kmstest.CheckDeviceCertificates(t, provider, uuid.NewString())
+
+	tenantID := uuid.NewString()
+	_, err := provider.CreateTenantSigningCertificate(ctx, tenantID, "Tenant")
+	if err != nil {
+		t.Fatalf("Failed to create the tenant signing certificate: %v", err)
+	}
+	if _, ok := backend.keys[backend.TenantKeyName(tenantID)]; !ok {
+		t.Fatalf("Expected the tenant key to be created")
+	}
+	_, err = provider.CreateTenantSigningCertificate(ctx, tenantID, "Tenant")
+	if !errors.Is(err, common.ErrTenantExists) {
+		t.Errorf("Expected the tenant to exist, got %v", err)
+	}
+	kmstest.CheckDeviceCertificates(t, provider, tenantID)
+
+	err = provider.DeleteTenantSigningCertificate(ctx, tenantID)
+	if err != nil {
+		t.Fatalf("Failed to delete the tenant signing certificate: %v", err)
+	}
+	if _, ok := backend.keys[backend.TenantKeyName(tenantID)]; ok {
+		t.Errorf("Expected the tenant key to be deleted")
+	}
+}
+
+func TestKeyBackendProvider_Restart(t *testing.T) {
+	dir := t.TempDir()
+	backend := newFakeKeyBackend()
+	kmstest.CheckRestart(t, func() (kms_providers.KmsProvider,
+		certstore.CertStore) {
+		return newTestProvider(t, dir, backend)
+	})
+}
+
+func TestKeyBackendProvider_KeyMismatch(t *testing.T) {
+	dir := t.TempDir()
+	backend := newFakeKeyBackend()
+	provider, store := newTestProvider(t, dir, backend)
+	provider.Shutdown()
+	store.Shutdown()
+
+	// The CA certificate is not used if it doesn't match the CA key.
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate a key: %v", err)
+	}
+	backend.keys["CAKey"] = key
+
+	_, store = kmstest.NewConfig(t, dir, common.KmsProviderLocal, nil)
+	defer store.Shutdown()
+	provider = &testProvider{}
+	err = provider.KeyBackendProvider.Init(context.Background(), zap.NewNop(),
+		backend, store, nil, "test/CAKey", "CAKey")
+	if !caerrors.Is(err, caerrors.Internal) {
+		t.Errorf("Expected the CA key mismatch to be detected, got %v", err)
+	}
+}
+
+func TestKeyBackendProvider_Unavailable(t *testing.T) {
+	backend := newFakeKeyBackend()
+	provider, store := newTestProvider(t, t.TempDir(), backend)
+	defer store.Shutdown()
+
+	kmstest.CheckUnavailable(t, provider, func() {
+		backend.lock.Lock()
+		backend.failNext = caerrors.New(caerrors.DependencyUnavailable,
+			"service unavailable")
+		backend.lock.Unlock()
+	})
+}
@@ -11,46 +11,96 @@
 //   - AWS KSM provider - uses the AWS Key Management Service (KMS) to issue
 //     certificates. This option is much more secure since it relies on the
 //     hardware backed HSM module to store the certificate signing private key.
+//   - PKCS#11 KMS provider - uses a PKCS#11 token (eg. an on-premises HSM) to
+//     generate and hold non-exportable CA and signing keys.
+//   - Vault transit KMS provider - uses the transit secrets engine of a
+//     HashiCorp Vault server to create and hold non-exportable CA and signing
+//     keys.
+//   - Google Cloud KMS provider - uses HSM protected asymmetric signing keys
+//     within a Google Cloud KMS key ring to sign certificates.
+//   - Azure Key Vault KMS provider - uses HSM protected RSA keys within an
+//     Azure key vault to sign certificates.
+//
+// Additional KMS providers can be compiled into the CA by registering them
+// using Register.
 package kms_providers
 
 import (
+	"context"
 	"time"
 
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
 	"github.com/HPInc/krypton-ca/service/config"
 	"go.uber.org/zap"
 )
 
 // KmsProvider - defines an interface that must be implemented by key management
-// service providers (eg. AWS KMS)
+// service providers (eg. AWS KMS). Requests made to the key management service
+// and to the certificate store are bounded by the deadline of the specified
+// context, and are abandoned if the context is cancelled.
 type KmsProvider interface {
-	// Init - Initialize the provider.
-	Init(*zap.Logger, *config.ConfigMgr) error
+	// Init - Initialize the provider. The provider persists signing
+	// certificates in the specified certificate store.
+	Init(*zap.Logger, *config.ConfigMgr, certstore.CertStore) error
+
+	// Shutdown - Release resources held by the provider. The provider is
+	// shut down once in-flight requests have completed, before the
+	// certificate store is shut down.
+	Shutdown()
+
+	// Ping - Check that the key management service is reachable and that
+	// the CA key can be used. Used to report the readiness of the CA.
+	Ping(ctx context.Context) error
 
 	// CreateTenantSigningCertificate - Initialize a new signing certificate for
-	// the specified tenant.
-	CreateTenantSigningCertificate(tenantID string,
+	// the specified tenant. If the tenant already has a signing certificate,
+	// ErrTenantExists is returned and the existing certificate is retained.
+	CreateTenantSigningCertificate(ctx context.Context, tenantID string,
 		tenantName string) (string, error)
 
 	// GetTenantSigningCertificate - Return the signing certificate for the
 	// specified tenant.
-	GetTenantSigningCertificate(tenantID string) ([]byte, error)
+	GetTenantSigningCertificate(ctx context.Context,
+		tenantID string) ([]byte, error)
 
 	// DeleteTenantSigningCertificate - Delete the signing certificate for the
 	// specified tenant.
-	DeleteTenantSigningCertificate(tenantID string) error
+	DeleteTenantSigningCertificate(ctx context.Context, tenantID string) error
+
+	// GetSigningCertificateChain - Return the chain of certificates used to
+	// sign device certificates issued within the specified tenant, as a
+	// PKCS#7 degenerate "certs only" structure. The chain contains the
+	// signing certificate used for the tenant (tenant specific or common),
+	// followed by the CA certificate.
+	GetSigningCertificateChain(ctx context.Context,
+		tenantID string) ([]byte, error)
+
+	// GetCACertificates - Return the root CA certificate and the common
+	// signing certificate, used to sign device certificates within tenants
+	// which do not have a tenant signing certificate (DER bytes).
+	GetCACertificates(ctx context.Context) ([]byte, []byte, error)
 
 	// CreateDeviceCertificate - Issue a new device certificate within the
 	// specified tenant in exchange for the specified certificate signing
 	// request (CSR). This action issues a unique device identifier for the
-	// device and persists it inside the signed device certificate.
-	CreateDeviceCertificate(tenantID string,
+	// device and persists it inside the signed device certificate. The parent
+	// certificates are returned as a PKCS#7 degenerate "certs only" structure
+	// containing the tenant signing certificate followed by the CA
+	// certificate.
+	CreateDeviceCertificate(ctx context.Context, tenantID string,
 		deviceCSR []byte) (string, []byte, []byte, time.Time, error)
 
+	// NewDeviceCertificateIssuer - Resolve the signing certificate and signing
+	// key used within the specified tenant, and return an issuer used to issue
+	// a batch of device certificates within the tenant.
+	NewDeviceCertificateIssuer(ctx context.Context,
+		tenantID string) (*DeviceCertificateIssuer, error)
+
 	// RenewDeviceCertificate - Issue a fresh device certificate within the
 	// specified tenant in exchange for the specified CSR. The existing
 	// device ID of the device is re-used and persisted within the signed
 	// device certificate. This API is invoked when the currently issued device
 	// certificate has expired.
-	RenewDeviceCertificate(tenantID string, deviceID string,
+	RenewDeviceCertificate(ctx context.Context, tenantID string, deviceID string,
 		deviceCSR []byte) (string, []byte, []byte, time.Time, error)
 }
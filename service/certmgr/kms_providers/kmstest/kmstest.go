@@ -0,0 +1,181 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/kmstest
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements helpers shared by the tests of KMS providers, which initialize the
+// configuration and certificate store used by a provider under test, and check
+// the behaviour expected of every KMS provider.
+package kmstest
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	_ "github.com/HPInc/krypton-ca/service/certmgr/certstore/localdb"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/google/uuid"
+	"go.mozilla.org/pkcs7"
+	"go.uber.org/zap"
+)
+
+// configFile - returns the path of the configuration file of the CA.
+func configFile() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "config",
+		"config.yaml")
+}
+
+// NewConfig - load the configuration of the CA for a test of the KMS provider
+// with the specified name, and initialize a certificate store in the specified
+// directory. The test changes its working directory to the specified
+// directory. Configuration settings are overridden using the specified
+// environment variables.
+func NewConfig(t *testing.T, dir string, kmsProvider string,
+	env map[string]string) (*config.ConfigMgr, certstore.CertStore) {
+	t.Setenv("DSTS_CONFIG_LOCATION", configFile())
+	t.Setenv("CA_KMS_PROVIDER", kmsProvider)
+	t.Setenv("CA_CERT_STORE_PROVIDER", common.CertStoreLocalDb)
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+	t.Chdir(dir)
+
+	logger := zap.NewNop()
+	cfgMgr := config.NewConfigMgr(logger, common.ServiceName)
+	if !cfgMgr.Load(true) {
+		t.Fatalf("Failed to load the configuration")
+	}
+	common.InitTemplateConfiguration(cfgMgr.GetCertificateTemplateConfig())
+
+	store, err := certstore.Init(logger, cfgMgr.GetCertStoreProvider())
+	if err != nil {
+		t.Fatalf("Failed to initialize the certificate store: %v", err)
+	}
+	return cfgMgr, store
+}
+
+// CheckDeviceCertificates - issue a device certificate within the specified
+// tenant, renew it, and check that both device certificates chain to the CA
+// certificate.
+func CheckDeviceCertificates(t *testing.T, provider kms_providers.KmsProvider,
+	tenantID string) {
+	ctx := context.Background()
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+	deviceID, deviceCert, parentCerts, _, err :=
+		provider.CreateDeviceCertificate(ctx, tenantID, csr)
+	if err != nil {
+		t.Fatalf("Failed to create the device certificate: %v", err)
+	}
+	CheckDeviceCertificate(t, deviceCert, parentCerts)
+
+	renewedID, renewedCert, parentCerts, _, err :=
+		provider.RenewDeviceCertificate(ctx, tenantID, deviceID, csr)
+	if err != nil {
+		t.Fatalf("Failed to renew the device certificate: %v", err)
+	}
+	if renewedID != deviceID {
+		t.Errorf("Expected device ID %s, got %s", deviceID, renewedID)
+	}
+	CheckDeviceCertificate(t, renewedCert, parentCerts)
+}
+
+// CheckDeviceCertificate - check that the device certificate was issued by the
+// first of the parent certificates, and that it was issued by the CA
+// certificate.
+func CheckDeviceCertificate(t *testing.T, deviceCert []byte,
+	parentCerts []byte) {
+	cert, err := x509.ParseCertificate(deviceCert)
+	if err != nil {
+		t.Fatalf("Failed to parse the device certificate: %v", err)
+	}
+	parents, err := pkcs7.Parse(parentCerts)
+	if err != nil {
+		t.Fatalf("Failed to parse the parent certificates: %v", err)
+	}
+	if len(parents.Certificates) != 2 {
+		t.Fatalf("Expected 2 parent certificates, got %d",
+			len(parents.Certificates))
+	}
+	if err = cert.CheckSignatureFrom(parents.Certificates[0]); err != nil {
+		t.Errorf("Device certificate not issued by the signing certificate: %v",
+			err)
+	}
+	if err = parents.Certificates[0].CheckSignatureFrom(
+		parents.Certificates[1]); err != nil {
+		t.Errorf("Signing certificate not issued by the CA certificate: %v", err)
+	}
+}
+
+// CheckRestart - check that the CA certificate and the common signing
+// certificate persisted in the certificate store are used when a provider is
+// restarted. The specified function initializes the provider, and the
+// certificate store it uses.
+func CheckRestart(t *testing.T,
+	newProvider func() (kms_providers.KmsProvider, certstore.CertStore)) {
+	ctx := context.Background()
+	provider, store := newProvider()
+	rootCert, signingCert, _ := provider.GetCACertificates(ctx)
+	provider.Shutdown()
+	store.Shutdown()
+
+	provider, store = newProvider()
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	restartedRootCert, restartedSigningCert, _ := provider.GetCACertificates(ctx)
+	if string(rootCert) != string(restartedRootCert) {
+		t.Errorf("Expected the CA certificate to be re-used")
+	}
+	if string(signingCert) != string(restartedSigningCert) {
+		t.Errorf("Expected the common signing certificate to be re-used")
+	}
+}
+
+// CheckUnavailable - check that requests fail with a dependency unavailable
+// error while the key management service is unavailable. The specified
+// function makes the key management service fail the next request made to it.
+func CheckUnavailable(t *testing.T, provider kms_providers.KmsProvider,
+	failNext func()) {
+	failNext()
+	_, err := provider.CreateTenantSigningCertificate(context.Background(),
+		uuid.NewString(), "")
+	if !caerrors.Is(err, caerrors.DependencyUnavailable) {
+		t.Errorf("Expected a dependency unavailable error, got %v", err)
+	}
+}
+
+// CheckUnsupportedOptions - check that the signer rejects RSA-PSS signatures,
+// digests computed using the specified unsupported hash function, and
+// truncated digests.
+func CheckUnsupportedOptions(t *testing.T, signer crypto.Signer,
+	unsupported crypto.Hash) {
+	digest := sha256.Sum256([]byte("message"))
+	_, err := signer.Sign(nil, digest[:], &rsa.PSSOptions{Hash: crypto.SHA256})
+	if err == nil {
+		t.Errorf("Expected RSA-PSS signatures to be rejected")
+	}
+
+	_, err = signer.Sign(nil, make([]byte, unsupported.Size()), unsupported)
+	if err == nil {
+		t.Errorf("Expected %s digests to be rejected", unsupported)
+	}
+
+	_, err = signer.Sign(nil, digest[:16], crypto.SHA256)
+	if err == nil {
+		t.Errorf("Expected truncated digests to be rejected")
+	}
+}
@@ -8,6 +8,7 @@
 package local_kms
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -21,65 +22,66 @@ import (
 // Create a local CA certificate. This provider is used only for testing
 // purposes. For production, the CA certificate will be stored in the
 // Key Management Service (KMS).
-func (p *LocalProvider) generateLocalCACertificate() error {
+func (p *LocalProvider) generateLocalCACertificate() (*providerState, error) {
 	var err error
+	state := &providerState{}
 
 	// Generate a private key for the CA certificate.
-	p.caPrivateKey, err = rsa.GenerateKey(rand.Reader, common.KeySize)
+	state.caPrivateKey, err = rsa.GenerateKey(rand.Reader, common.KeySize)
 	if err != nil {
-		caLogger.Error("Failed to generate private key for local CA certificate!",
+		p.logger.Error("Failed to generate private key for local CA certificate!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
 	// Initialize the CA certificate template.
-	p.caCert, err = common.NewCACertificateTemplate()
+	caCertTpl, err := common.NewCACertificateTemplate()
 	if err != nil {
-		caLogger.Error("Failed to initialize CA certificate template!",
+		p.logger.Error("Failed to initialize CA certificate template!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
 	// Generate the CA certificate.
-	p.caCertBytes, err = x509.CreateCertificate(rand.Reader, p.caCert, p.caCert,
-		&p.caPrivateKey.PublicKey, p.caPrivateKey)
+	state.caCertBytes, err = x509.CreateCertificate(rand.Reader, caCertTpl,
+		caCertTpl, &state.caPrivateKey.PublicKey, state.caPrivateKey)
 	if err != nil {
-		caLogger.Error("Failed to generate local CA certificate!",
+		p.logger.Error("Failed to generate local CA certificate!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
 	// Parse and store the signed CA certificate in memory.
-	p.caCert, err = x509.ParseCertificate(p.caCertBytes)
+	state.caCert, err = x509.ParseCertificate(state.caCertBytes)
 	if err != nil {
-		caLogger.Error("Failed to parse the signed CA certificate!",
+		p.logger.Error("Failed to parse the signed CA certificate!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
 	// PEM encode and store the locally generated CA certificate and
 	// its private key.
-	err = p.encodeLocalCACertificate()
+	err = p.encodeLocalCACertificate(state)
 	if err != nil {
-		caLogger.Error("Failed to encode CA certificate!",
+		p.logger.Error("Failed to encode CA certificate!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
-	return nil
+	return state, nil
 }
 
 // PEM encode and store the CA certificate to file.
-func (p *LocalProvider) encodeLocalCACertificate() error {
+func (p *LocalProvider) encodeLocalCACertificate(state *providerState) error {
 	// PEM encode the generated CA certificate and write to file.
 	certfh, err := os.Create(pemCACertificateFile)
 	if err != nil {
-		caLogger.Error("Failed to create a file to store CA certificate",
+		p.logger.Error("Failed to create a file to store CA certificate",
 			zap.Error(err),
 		)
 		return err
@@ -87,10 +89,10 @@ func (p *LocalProvider) encodeLocalCACertificate() error {
 
 	err = pem.Encode(certfh, &pem.Block{
 		Type:  "CERTIFICATE",
-		Bytes: p.caCertBytes,
+		Bytes: state.caCertBytes,
 	})
 	if err != nil {
-		caLogger.Error("Failed to PEM encode the local CA certificate",
+		p.logger.Error("Failed to PEM encode the local CA certificate",
 			zap.Error(err),
 		)
 		_ = certfh.Close()
@@ -102,7 +104,7 @@ func (p *LocalProvider) encodeLocalCACertificate() error {
 	// to file.
 	pkeyfh, err := os.Create(pemCAPrivateKeyFile)
 	if err != nil {
-		caLogger.Error("Failed to PEM encode the local CA certificate",
+		p.logger.Error("Failed to PEM encode the local CA certificate",
 			zap.Error(err),
 		)
 		return err
@@ -110,10 +112,10 @@ func (p *LocalProvider) encodeLocalCACertificate() error {
 
 	err = pem.Encode(pkeyfh, &pem.Block{
 		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(p.caPrivateKey),
+		Bytes: x509.MarshalPKCS1PrivateKey(state.caPrivateKey),
 	})
 	if err != nil {
-		caLogger.Error("Failed to PEM encode the local CA private key",
+		p.logger.Error("Failed to PEM encode the local CA private key",
 			zap.Error(err),
 		)
 		_ = pkeyfh.Close()
@@ -123,3 +125,11 @@ func (p *LocalProvider) encodeLocalCACertificate() error {
 
 	return nil
 }
+
+// GetCACertificates - get the CA certificate and the common signing
+// certificate.
+func (p *LocalProvider) GetCACertificates(
+	ctx context.Context) ([]byte, []byte, error) {
+	state := p.state.Load()
+	return state.caCertBytes, state.commonSigningCert.Raw, nil
+}
@@ -7,29 +7,57 @@
 package local_kms
 
 import (
+	"context"
 	"crypto/rsa"
 	"crypto/x509"
+	"sync/atomic"
 
 	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/common"
 	"github.com/HPInc/krypton-ca/service/config"
 	"go.uber.org/zap"
 )
 
-var (
-	caLogger *zap.Logger
-)
-
 const (
 	pemCAPrivateKeyFile  = "ca.key"
 	pemCACertificateFile = "ca.cert"
 )
 
+// Register the local KMS provider.
+func init() {
+	kms_providers.Register(common.KmsProviderLocal, func() kms_providers.KmsProvider {
+		return &LocalProvider{}
+	}, config.ProviderSchema{})
+}
+
 // LocalProvider - a local file system based key management service provider.
 // This provider is meant only for testing purposes and does not provide much
 // security guarantees for keys generated using it. In production, we expect
 // to use something like AWS KMS (Key Management Service), which can provide
 // guarantees such as hardware bound protection (HSM) for private keys.
 type LocalProvider struct {
+	logger *zap.Logger
+
+	// The CA certificate and common signing certificate used by the provider.
+	state atomic.Pointer[providerState]
+
+	// Whether to use a per-tenant signing certificate to sign device
+	// certificates issued by the CA.
+	perTenantSigningEnabled bool
+
+	// Certificate store used to persist tenant signing certificates.
+	store certstore.CertStore
+
+	// Bounds the number of signing operations performed concurrently.
+	signingPool *kms_providers.SigningPool
+}
+
+// providerState - the CA certificate and the common signing certificate used
+// by the local KMS provider. The state is not modified once initialized, and is
+// replaced as a whole, so that requests in flight while the state is replaced
+// continue to use a consistent set of certificates and keys.
+type providerState struct {
 	// The private key for the CA root certificate.
 	caPrivateKey *rsa.PrivateKey
 
@@ -40,66 +68,77 @@ type LocalProvider struct {
 	// The common signing certificate.
 	commonSigningCert     *x509.Certificate
 	commonSigningCertPkey *rsa.PrivateKey
-
-	// Whether to use a per-tenant signing certificate to sign device
-	// certificates issued by the CA.
-	perTenantSigningEnabled bool
-
-	// Certificate store used to persist tenant signing certificates.
-	store certstore.CertStore
 }
 
 // Init - initialize the local store certificate provider.
-func (p *LocalProvider) Init(logger *zap.Logger, cfgMgr *config.ConfigMgr) error {
-	var err error
-	caLogger = logger
-
+func (p *LocalProvider) Init(logger *zap.Logger, cfgMgr *config.ConfigMgr,
+	store certstore.CertStore) error {
+	p.logger = logger
 	p.perTenantSigningEnabled = cfgMgr.IsPerTenantSigningEnabled()
+	p.signingPool = kms_providers.NewSigningPool(
+		cfgMgr.GetSigningConfig().MaxConcurrentOperations)
 
-	// Initialize the certificate store provider.
-	p.store, err = certstore.Init(caLogger, cfgMgr.GetCertStoreProvider())
+	// Use the specified certificate store to persist signing certificates.
+	p.store = store
+
+	state, err := p.newProviderState(context.Background())
 	if err != nil {
-		caLogger.Error("Failed to initialize certificate store provider!",
-			zap.String("Provider name: ", cfgMgr.GetCertStoreProvider()),
-			zap.Error(err),
-		)
 		return err
 	}
+	p.state.Store(state)
+	return nil
+}
 
+// newProviderState - generate a local CA certificate and retrieve the common
+// signing certificate issued by it, creating the common signing certificate if
+// required.
+func (p *LocalProvider) newProviderState(
+	ctx context.Context) (*providerState, error) {
 	// Generate a local CA certificate and its private key. The local
 	// CA root certificate will be used for signing.
-	err = p.generateLocalCACertificate()
+	state, err := p.generateLocalCACertificate()
 	if err != nil {
-		caLogger.Error("Failed to initialize local CA certificate provider!",
+		p.logger.Error("Failed to initialize local CA certificate provider!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
 	// Initialize the common signing certificate & its signing key.
-	commonSigningCert, err := p.getCommonSigningCertificate()
+	commonSigningCert, err := p.getCommonSigningCertificate(ctx, state)
 	if err != nil {
-		caLogger.Error("Failed to get the common signing certificate from certificate store!",
+		p.logger.Error("Failed to get the common signing certificate from certificate store!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
-	p.commonSigningCert, err = x509.ParseCertificate(commonSigningCert.Certificate)
+	state.commonSigningCert, err = x509.ParseCertificate(commonSigningCert.Certificate)
 	if err != nil {
-		caLogger.Error("Failed to parse the common signing certificate!",
+		p.logger.Error("Failed to parse the common signing certificate!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
-	p.commonSigningCertPkey, err = p.getTenantPrivateKey(commonSigningCert.TenantID)
+	state.commonSigningCertPkey, err = p.getTenantPrivateKey(commonSigningCert.TenantID)
 	if err != nil {
-		caLogger.Error("Failed to retrieve the common certificate signing private key!",
+		p.logger.Error("Failed to retrieve the common certificate signing private key!",
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
+	return state, nil
+}
+
+// Ping - the local KMS provider holds its keys in memory, so it is always
+// available.
+func (p *LocalProvider) Ping(ctx context.Context) error {
 	return nil
 }
+
+// Shutdown - clean up and shutdown the local KMS provider.
+func (p *LocalProvider) Shutdown() {
+	p.logger.Info("Local KMS provider shutdown!")
+}
@@ -0,0 +1,180 @@
+package local_kms
+
+import (
+	"context"
+	"crypto/x509"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	_ "github.com/HPInc/krypton-ca/service/certmgr/certstore/localdb"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/google/uuid"
+	"go.mozilla.org/pkcs7"
+	"go.uber.org/zap"
+)
+
+// Path of the configuration file, resolved before the tests change the working
+// directory.
+var testConfigFile, _ = filepath.Abs("../../../config/config.yaml")
+
+const (
+	// Number of goroutines issuing requests concurrently, and the number of
+	// device certificates issued by each of them.
+	testWorkers    = 4
+	testIterations = 5
+)
+
+// Initialize a local KMS provider, with the CA certificate, tenant signing
+// keys and certificate store in the specified directory.
+func newTestProvider(t *testing.T, dir string) (*LocalProvider,
+	certstore.CertStore) {
+	t.Setenv("DSTS_CONFIG_LOCATION", testConfigFile)
+	t.Setenv("CA_KMS_PROVIDER", common.KmsProviderLocal)
+	t.Setenv("CA_CERT_STORE_PROVIDER", common.CertStoreLocalDb)
+	t.Setenv("CA_PER_TENANT_SIGNING_ENABLED", "true")
+	t.Chdir(dir)
+
+	logger := zap.NewNop()
+	cfgMgr := config.NewConfigMgr(logger, common.ServiceName)
+	if !cfgMgr.Load(true) {
+		t.Fatalf("Failed to load the configuration")
+	}
+	common.InitTemplateConfiguration(cfgMgr.GetCertificateTemplateConfig())
+
+	store, err := certstore.Init(logger, cfgMgr.GetCertStoreProvider())
+	if err != nil {
+		t.Fatalf("Failed to initialize the certificate store: %v", err)
+	}
+
+	provider := &LocalProvider{}
+	err = provider.Init(logger, cfgMgr, store)
+	if err != nil {
+		store.Shutdown()
+		t.Fatalf("Failed to initialize the local KMS provider: %v", err)
+	}
+	return provider, store
+}
+
+// Check that the device certificate was issued by the first of the parent
+// certificates, and that it was issued by the CA certificate.
+func checkDeviceCertificate(t *testing.T, deviceCert []byte,
+	parentCerts []byte) {
+	cert, err := x509.ParseCertificate(deviceCert)
+	if err != nil {
+		t.Errorf("Failed to parse the device certificate: %v", err)
+		return
+	}
+	parents, err := pkcs7.Parse(parentCerts)
+	if err != nil {
+		t.Errorf("Failed to parse the parent certificates: %v", err)
+		return
+	}
+	if len(parents.Certificates) != 2 {
+		t.Errorf("Expected 2 parent certificates, got %d",
+			len(parents.Certificates))
+		return
+	}
+	if err = cert.CheckSignatureFrom(parents.Certificates[0]); err != nil {
+		t.Errorf("Device certificate not issued by the signing certificate: %v",
+			err)
+	}
+	if err = parents.Certificates[0].CheckSignatureFrom(
+		parents.Certificates[1]); err != nil {
+		t.Errorf("Signing certificate not issued by the CA certificate: %v", err)
+	}
+}
+
+// Create a tenant signing certificate, issue and renew device certificates
+// within the tenant and within a tenant without a signing certificate, then
+// delete the tenant signing certificate.
+func exerciseTenant(t *testing.T, provider *LocalProvider, csr []byte) {
+	ctx := context.Background()
+	tenantID := uuid.NewString()
+	_, err := provider.CreateTenantSigningCertificate(ctx, tenantID, "Local tenant")
+	if err != nil {
+		t.Errorf("Failed to create the tenant signing certificate: %v", err)
+		return
+	}
+
+	for i := 0; i < testIterations; i++ {
+		for _, tid := range []string{tenantID, uuid.NewString()} {
+			deviceID, deviceCert, parentCerts, _, err :=
+				provider.CreateDeviceCertificate(ctx, tid, csr)
+			if err != nil {
+				t.Errorf("Failed to create the device certificate: %v", err)
+				continue
+			}
+			checkDeviceCertificate(t, deviceCert, parentCerts)
+
+			_, deviceCert, parentCerts, _, err =
+				provider.RenewDeviceCertificate(ctx, tid, deviceID, csr)
+			if err != nil {
+				t.Errorf("Failed to renew the device certificate: %v", err)
+				continue
+			}
+			checkDeviceCertificate(t, deviceCert, parentCerts)
+		}
+
+		issuer, err := provider.NewDeviceCertificateIssuer(ctx, tenantID)
+		if err != nil {
+			t.Errorf("Failed to create the device certificate issuer: %v", err)
+			continue
+		}
+		_, deviceCert, parentCerts, _, err := issuer.CreateDeviceCertificate(csr)
+		if err != nil {
+			t.Errorf("Failed to issue the device certificate: %v", err)
+			continue
+		}
+		checkDeviceCertificate(t, deviceCert, parentCerts)
+	}
+
+	err = provider.DeleteTenantSigningCertificate(ctx, tenantID)
+	if err != nil {
+		t.Errorf("Failed to delete the tenant signing certificate: %v", err)
+	}
+}
+
+func TestLocalProvider_ConcurrentOperations(t *testing.T) {
+	provider, store := newTestProvider(t, t.TempDir())
+	defer store.Shutdown()
+
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+
+	// Replace the provider state while requests are in flight.
+	done := make(chan struct{})
+	var swapper sync.WaitGroup
+	swapper.Add(1)
+	go func() {
+		defer swapper.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			state := *provider.state.Load()
+			provider.state.Store(&state)
+			if _, _, err := provider.GetCACertificates(context.Background()); err != nil {
+				t.Errorf("Failed to get the CA certificates: %v", err)
+			}
+		}
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < testWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			exerciseTenant(t, provider, csr)
+		}()
+	}
+	workers.Wait()
+	close(done)
+	swapper.Wait()
+}
@@ -9,55 +9,65 @@
 package local_kms
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
 	"github.com/HPInc/krypton-ca/service/common"
+	"go.mozilla.org/pkcs7"
 	"go.uber.org/zap"
 )
 
 // createCommonSigningCertificate - create a new signing certificate which
 // is used to sign device certificates for tenants that do not have a
 // distinct/separate tenant signing certificate.
-func (p *LocalProvider) createCommonSigningCertificate() (string, error) {
+func (p *LocalProvider) createCommonSigningCertificate(ctx context.Context,
+	state *providerState) (string, error) {
 	// For the common signing certificate, we do not provide a tenant name
 	// to the certificate template. This certificate will have only the issuer
 	// name.
-	return p.CreateTenantSigningCertificate(common.CommonSigningKeyId, "")
+	return p.createTenantSigningCertificate(ctx, state,
+		common.CommonSigningKeyId, "")
 }
 
 // getCommonSigningCertificate - Get the common signing certificate which
 // is used to sign device certificates for tenants that do not have a
 // distinct/separate tenant signing certificate. If one doesn't already exist
-// in the certificate store, a new common signing certificate is created.
-func (p *LocalProvider) getCommonSigningCertificate() (*common.SigningCertificate, error) {
+// in the certificate store, a new common signing certificate is created using
+// the CA certificate within the specified provider state.
+func (p *LocalProvider) getCommonSigningCertificate(ctx context.Context,
+	state *providerState) (*common.SigningCertificate, error) {
 	// Check to see if the common tenant signing certificate exists within the
 	// certificate store. If it is not found, attempt to create it.
 	// Retrieve the tenant signing certificate for the specified tenant.
-	tenantCert, err := p.store.GetCertificate(common.CommonSigningKeyId)
+	tenantCert, err := p.store.GetCertificate(ctx, common.CommonSigningKeyId)
 	if err != nil {
-		caLogger.Error("Failed to get the common signing certificate from certificate store!",
+		p.logger.Error("Failed to get the common signing certificate from certificate store!",
 			zap.Error(err),
 		)
 
-		// Attempt to create the common signing certificate.
-		_, err := p.createCommonSigningCertificate()
-		if err != nil {
-			caLogger.Error("Failed to create the common signing certificate!",
+		// Attempt to create the common signing certificate. It may have been
+		// created concurrently by another request, in which case it is used.
+		_, err := p.createCommonSigningCertificate(ctx, state)
+		if (err != nil) && !errors.Is(err, common.ErrTenantExists) {
+			p.logger.Error("Failed to create the common signing certificate!",
 				zap.Error(err),
 			)
 			return nil, err
 		}
 
 		// Now, return the newly created common signing certificate.
-		tenantCert, err = p.store.GetCertificate(common.CommonSigningKeyId)
+		tenantCert, err = p.store.GetCertificate(ctx, common.CommonSigningKeyId)
 		if err != nil {
-			caLogger.Error("Failed to get the common signing certificate from certificate store!",
+			p.logger.Error("Failed to get the common signing certificate from certificate store!",
 				zap.Error(err),
 			)
 			return nil, err
@@ -68,15 +78,44 @@ func (p *LocalProvider) getCommonSigningCertificate() (*common.SigningCertificat
 }
 
 // CreateTenantSigningCertificate - create a new tenant signing certificate for
-// the specified tenant.
-func (p *LocalProvider) CreateTenantSigningCertificate(tenantID string,
-	tenantName string) (string, error) {
+// the specified tenant. ErrTenantExists is returned if the tenant already has
+// a signing certificate.
+func (p *LocalProvider) CreateTenantSigningCertificate(ctx context.Context,
+	tenantID string, tenantName string) (string, error) {
+	return p.createTenantSigningCertificate(ctx, p.state.Load(), tenantID,
+		tenantName)
+}
+
+// createTenantSigningCertificate - create a new tenant signing certificate for
+// the specified tenant, issued by the CA certificate within the specified
+// provider state.
+func (p *LocalProvider) createTenantSigningCertificate(ctx context.Context,
+	state *providerState, tenantID string, tenantName string) (string, error) {
 	var certEntry common.SigningCertificate
 
+	// Ensure that a signing certificate doesn't already exist for the tenant,
+	// so that it is not silently replaced. The check bypasses the cache, since
+	// the signing certificate may have been created or deleted by another
+	// instance of the CA.
+	_, err := certstore.GetCertificateUncached(ctx, p.store, tenantID)
+	if err == nil {
+		p.logger.Error("A signing certificate already exists for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+		)
+		return "", common.ErrTenantExists
+	}
+	if !errors.Is(err, common.ErrCertStoreNotFound) {
+		p.logger.Error("Failed to check for an existing tenant signing certificate!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
 	// Generate a private key for the tenant signing certificate.
 	tenantPrivateKey, err := rsa.GenerateKey(rand.Reader, common.KeySize)
 	if err != nil {
-		caLogger.Error("Failed to generate private key for the tenant signing certificate!",
+		p.logger.Error("Failed to generate private key for the tenant signing certificate!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
@@ -87,7 +126,7 @@ func (p *LocalProvider) CreateTenantSigningCertificate(tenantID string,
 	tenantCertTpl, err := common.NewTenantSigningCertificateTemplate(tenantID,
 		tenantName)
 	if err != nil {
-		caLogger.Error("Failed to initialize a new tenant signing certificate template!",
+		p.logger.Error("Failed to initialize a new tenant signing certificate template!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
@@ -96,41 +135,47 @@ func (p *LocalProvider) CreateTenantSigningCertificate(tenantID string,
 
 	// Generate the tenant signing certificate.
 	tenantCertBytes, err := x509.CreateCertificate(rand.Reader, tenantCertTpl,
-		p.caCert, &tenantPrivateKey.PublicKey, p.caPrivateKey)
+		state.caCert, &tenantPrivateKey.PublicKey,
+		p.signingPool.Signer(ctx, state.caPrivateKey))
 	if err != nil {
-		caLogger.Error("Failed to generate the tenant signing certificate!",
+		p.logger.Error("Failed to generate the tenant signing certificate!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
 		return "", err
 	}
 
-	// PEM encode the locally generated tenant signing certificate's private key
-	// & persist locally.
-	err = storeTenantSigningCertificatePrivateKey(tenantID, tenantPrivateKey)
+	// Persist the tenant signing certificate in the certificate store. This
+	// fails with ErrTenantExists if a signing certificate was created for the
+	// tenant concurrently, in which case the private key is discarded.
+	certEntry.Certificate = tenantCertBytes
+	certEntry.TenantID = tenantID
+	certEntry.KmsKeyID = ""
+
+	err = p.store.AddCertificate(ctx, &certEntry)
 	if err != nil {
-		caLogger.Error("Failed to encode the tenant signing certificate!",
+		p.logger.Error("Failed to add the tenant signing certificate to the store!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
 		return "", err
 	}
 
-	// Persist the tenant signing certificate in the certificate store.
-	certEntry.Certificate = tenantCertBytes
-	certEntry.TenantID = tenantID
-	certEntry.KmsKeyID = ""
-
-	err = p.store.AddCertificate(&certEntry)
+	// PEM encode the locally generated tenant signing certificate's private key
+	// & persist locally. The private key is only persisted once the tenant
+	// signing certificate has been stored, so that the private key of an
+	// existing tenant signing certificate is never replaced.
+	err = p.storeTenantSigningCertificatePrivateKey(tenantID, tenantPrivateKey)
 	if err != nil {
-		caLogger.Error("Failed to add the tenant signing certificate to the store!",
+		p.logger.Error("Failed to encode the tenant signing certificate!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
+		_ = p.store.DeleteCertificate(ctx, tenantID)
 		return "", err
 	}
 
-	caLogger.Info("Successfully generated the tenant signing certificate!",
+	p.logger.Info("Successfully generated the tenant signing certificate!",
 		zap.String("Tenant ID:", tenantID),
 	)
 	return string(tenantCertTpl.SubjectKeyId), nil
@@ -138,13 +183,13 @@ func (p *LocalProvider) CreateTenantSigningCertificate(tenantID string,
 
 // GetTenantSigningCertificate - get the tenant signing certificate for
 // the specified tenant.
-func (p *LocalProvider) GetTenantSigningCertificate(
+func (p *LocalProvider) GetTenantSigningCertificate(ctx context.Context,
 	tenantID string) ([]byte, error) {
 
 	// Retrieve the tenant signing certificate for the specified tenant.
-	tenantCert, err := p.store.GetCertificate(tenantID)
+	tenantCert, err := p.store.GetCertificate(ctx, tenantID)
 	if err != nil {
-		caLogger.Error("Failed to retrieve the tenant signing certificate for the tenant.",
+		p.logger.Error("Failed to retrieve the tenant signing certificate for the tenant.",
 			zap.String("Tenant ID:", tenantID),
 		)
 		return nil, err
@@ -153,15 +198,58 @@ func (p *LocalProvider) GetTenantSigningCertificate(
 	return tenantCert.Certificate, nil
 }
 
+// GetSigningCertificateChain - get the chain of certificates used to sign
+// device certificates issued within the specified tenant.
+func (p *LocalProvider) GetSigningCertificateChain(ctx context.Context,
+	tenantID string) ([]byte, error) {
+	state := p.state.Load()
+	signingCert := state.commonSigningCert
+
+	// Use the tenant signing certificate, if one is configured for the tenant.
+	if p.perTenantSigningEnabled {
+		certEntry, err := p.store.GetCertificate(ctx, tenantID)
+		if err == nil {
+			signingCert, err = x509.ParseCertificate(certEntry.Certificate)
+			if err != nil {
+				p.logger.Error("Failed to parse the tenant signing certificate!",
+					zap.String("Tenant ID:", tenantID),
+					zap.Error(err),
+				)
+				return nil, err
+			}
+		} else if !errors.Is(err, common.ErrCertStoreNotFound) {
+			p.logger.Error("Failed to retrieve the tenant signing certificate",
+				zap.String("Tenant ID:", tenantID),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+	}
+
+	chain := []byte{}
+	chain = append(chain, signingCert.Raw...)
+	chain = append(chain, state.caCertBytes...)
+
+	chain, err := pkcs7.DegenerateCertificate(chain)
+	if err != nil {
+		p.logger.Error("Failed to create degenerate PKCS7 object!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	return chain, nil
+}
+
 // DeleteTenantSigningCertificate - delete the tenant signing certificate for
 // the specified tenant.
-func (p *LocalProvider) DeleteTenantSigningCertificate(
+func (p *LocalProvider) DeleteTenantSigningCertificate(ctx context.Context,
 	tenantID string) error {
 
 	// Delete the tenant signing certificate for the specified tenant.
-	err := p.store.DeleteCertificate(tenantID)
+	err := p.store.DeleteCertificate(ctx, tenantID)
 	if err != nil {
-		caLogger.Error("Failed to delete the tenant signing certificate!",
+		p.logger.Error("Failed to delete the tenant signing certificate!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
@@ -172,11 +260,16 @@ func (p *LocalProvider) DeleteTenantSigningCertificate(
 	// certificate store and delete it.
 	err = os.Remove(tenantID + ".key")
 	if err != nil {
-		caLogger.Error("Error deleting the private key for tenant signing certificate!",
+		p.logger.Error("Error deleting the private key for tenant signing certificate!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
-		return err
+		if errors.Is(err, fs.ErrNotExist) {
+			return caerrors.Wrap(caerrors.NotFound,
+				"tenant signing certificate not found", err)
+		}
+		return caerrors.Wrap(caerrors.Internal,
+			"failed to delete the tenant signing private key", err)
 	}
 
 	return nil
@@ -184,14 +277,14 @@ func (p *LocalProvider) DeleteTenantSigningCertificate(
 
 // storeTenantSigningCertificatePrivateKey - PEM encode the tenant signing certificate's
 // private key and save locally to file.
-func storeTenantSigningCertificatePrivateKey(tenantID string,
+func (p *LocalProvider) storeTenantSigningCertificatePrivateKey(tenantID string,
 	tenantPrivateKey *rsa.PrivateKey) error {
 
 	// PEM encode the private key for the local tenant signing certificate and
 	// write to file.
 	err := common.EncodeAndStorePrivateKey(tenantID+".key", tenantPrivateKey)
 	if err != nil {
-		caLogger.Error("Failed to store the tenant signing private key!",
+		p.logger.Error("Failed to store the tenant signing private key!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
@@ -209,24 +302,26 @@ func (p *LocalProvider) getTenantPrivateKey(
 	// certificate store and parse it.
 	pemPkey, err := os.ReadFile(filepath.Clean(tenantID + ".key"))
 	if err != nil {
-		caLogger.Error("Error reading the private key for tenant signing certificate from file!",
+		p.logger.Error("Error reading the private key for tenant signing certificate from file!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
-		return nil, err
+		return nil, caerrors.Wrap(caerrors.Internal,
+			"failed to read the tenant signing private key", err)
 	}
 
 	pemPkeyBlock, _ := pem.Decode(pemPkey)
 	if pemPkeyBlock == nil {
-		caLogger.Error("Failed to decode the private key for the tenant signing certificate!",
+		p.logger.Error("Failed to decode the private key for the tenant signing certificate!",
 			zap.String("Tenant ID:", tenantID),
 		)
-		return nil, errors.New("failed to decode private key for the tenant signing certificate")
+		return nil, caerrors.New(caerrors.Internal,
+			"failed to decode private key for the tenant signing certificate")
 	}
 
 	tenantPkey, err := x509.ParsePKCS1PrivateKey(pemPkeyBlock.Bytes)
 	if err != nil {
-		caLogger.Error("Failed to parse the private key for the tenant signing certificate from file!",
+		p.logger.Error("Failed to parse the private key for the tenant signing certificate from file!",
 			zap.String("Tenant ID:", tenantID),
 			zap.Error(err),
 		)
@@ -0,0 +1,163 @@
+//go:build cgo
+
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/pkcs11_kms
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Initializes the PKCS#11 KMS provider.
+package pkcs11_kms
+
+import (
+	"context"
+	"errors"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/miekg/pkcs11"
+	"go.uber.org/zap"
+)
+
+var (
+	// Settings of the PKCS#11 KMS provider, read from the pkcs11_kms section
+	// under certificate_authority/providers in the configuration file.
+	settings = Settings{
+		MaxSessions: 4,
+	}
+)
+
+// Settings represents configuration settings for the PKCS#11 KMS provider,
+// which generates and uses the CA's signing keys within a PKCS#11 token (eg.
+// an on-premises HSM).
+type Settings struct {
+	// Path to the PKCS#11 module (shared library) of the token.
+	ModulePath string `yaml:"module_path"`
+
+	// Label of the token to use. If not specified, the token in the slot
+	// with the specified slot ID is used.
+	TokenLabel string `yaml:"token_label"`
+
+	// ID of the slot containing the token to use.
+	Slot uint `yaml:"slot"`
+
+	// Maximum number of sessions opened to the token. Each operation on the
+	// token uses a session of its own, so this bounds the number of
+	// operations performed on the token concurrently.
+	MaxSessions int `yaml:"max_sessions"`
+
+	// Populated after reading the CA_PKCS11_PIN environment variable. The
+	// PIN is used to log in to the token as a normal user. For security
+	// reasons, this may not be specified using the configuration YAML file.
+	Pin string `yaml:"-"`
+}
+
+// Register the PKCS#11 KMS provider.
+func init() {
+	kms_providers.Register(common.KmsProviderPkcs11, func() kms_providers.KmsProvider {
+		return &Pkcs11KmsProvider{}
+	}, config.ProviderSchema{
+		Settings: &settings,
+		Env: map[string]config.EnvOverride{
+			"CA_PKCS11_MODULE_PATH":  {Value: &settings.ModulePath},
+			"CA_PKCS11_TOKEN_LABEL":  {Value: &settings.TokenLabel},
+			"CA_PKCS11_SLOT":         {Value: &settings.Slot},
+			"CA_PKCS11_MAX_SESSIONS": {Value: &settings.MaxSessions},
+			"CA_PKCS11_PIN":          {Value: &settings.Pin, Secret: true},
+		},
+		Validate: func(*config.ConfigMgr) error {
+			return validateSettings(&settings)
+		},
+	})
+}
+
+// validateSettings - validate that the PKCS#11 module and the PIN used to log
+// in to the token have been specified, and that at least one session may be
+// opened to the token.
+func validateSettings(s *Settings) error {
+	if s.ModulePath == "" {
+		return errors.New("the path of the PKCS#11 module must be specified")
+	}
+	if s.Pin == "" {
+		return errors.New("the PIN of the PKCS#11 token must be specified using CA_PKCS11_PIN")
+	}
+	if s.MaxSessions <= 0 {
+		return errors.New("the maximum number of PKCS#11 sessions must be positive")
+	}
+	return nil
+}
+
+// Pkcs11KmsProvider - uses a PKCS#11 token (eg. an on-premises HSM) for
+// cryptographic operations. The root and signing keys are generated within the
+// token as non-exportable keys and never leave the token when consumed for
+// signing operations. Keys are identified within the token using key labels.
+type Pkcs11KmsProvider struct {
+	logger *zap.Logger
+
+	// Issues and manages certificates using the keys held within the token.
+	kms_providers.KeyBackendProvider
+}
+
+// Init - initialize the PKCS#11 KMS provider.
+func (p *Pkcs11KmsProvider) Init(logger *zap.Logger, cfgMgr *config.ConfigMgr,
+	store certstore.CertStore) error {
+	p.logger = logger
+	p.logger.Info("PKCS#11 KMS provider settings",
+		zap.String(" - Module path:", settings.ModulePath),
+		zap.String(" - Token label:", settings.TokenLabel),
+		zap.Uint(" - Slot:", settings.Slot),
+		zap.Int(" - Max sessions:", settings.MaxSessions),
+	)
+
+	// Load the PKCS#11 module of the token.
+	cfg := &settings
+	module := pkcs11.New(cfg.ModulePath)
+	if module == nil {
+		p.logger.Error("Failed to load the PKCS#11 module!",
+			zap.String("Module path:", cfg.ModulePath),
+		)
+		return caerrors.New(caerrors.Internal,
+			"failed to load the PKCS#11 module")
+	}
+
+	return p.initProvider(module, cfg, store, kms_providers.NewSigningPool(
+		cfgMgr.GetSigningConfig().MaxConcurrentOperations))
+}
+
+// initProvider - initialize the PKCS#11 KMS provider using the specified
+// PKCS#11 module. Signing operations are bounded by the specified signing
+// pool.
+func (p *Pkcs11KmsProvider) initProvider(module Pkcs11Module,
+	cfg *Settings, store certstore.CertStore,
+	signingPool *kms_providers.SigningPool) error {
+	// Log in to the configured token.
+	backend, err := openToken(p.logger, module, cfg)
+	if err != nil {
+		p.logger.Error("Failed to open a session to the PKCS#11 token!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// Initialize the CA certificate and the common signing certificate, and
+	// use the specified certificate store to persist signing certificates. If
+	// the CA has not been initialized yet, the CA key is generated within the
+	// token and used to issue the CA certificate.
+	err = p.KeyBackendProvider.Init(context.Background(), p.logger, backend,
+		store, signingPool, pkcs11CACertID, pkcs11CAKeyLabel)
+	if err != nil {
+		backend.Close()
+		return err
+	}
+
+	p.logger.Info("PKCS#11 KMS provider initialized successfully!")
+	return nil
+}
+
+// Shutdown - log out of the PKCS#11 token and unload the PKCS#11 module.
+func (p *Pkcs11KmsProvider) Shutdown() {
+	p.KeyBackendProvider.Shutdown()
+	p.logger.Info("PKCS#11 KMS provider shutdown!")
+}
@@ -0,0 +1,67 @@
+//go:build cgo
+
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/pkcs11_kms
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Exposes the keys held within the PKCS#11 token to the implementation of the
+// KMS provider shared with other providers holding their keys within a key
+// management service.
+package pkcs11_kms
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// pkcs11KeyBackend - creates and uses keys held within the PKCS#11 token.
+// Keys are identified within the token using their key labels.
+type pkcs11KeyBackend struct {
+	logger *zap.Logger
+
+	// Pool of sessions used to access the token.
+	sessions *sessionPool
+
+	// Serializes the creation and deletion of keys within the token.
+	keyLock sync.Mutex
+}
+
+func (b *pkcs11KeyBackend) Name() string {
+	return "the PKCS#11 token"
+}
+
+func (b *pkcs11KeyBackend) TenantKeyName(tenantID string) string {
+	return fmt.Sprintf(keyLabelFormat, tenantID)
+}
+
+func (b *pkcs11KeyBackend) CreateKey(ctx context.Context,
+	keyLabel string) (string, error) {
+	return b.newPkcs11Key(ctx, keyLabel)
+}
+
+func (b *pkcs11KeyBackend) PublicKey(ctx context.Context,
+	keyLabel string) (crypto.PublicKey, error) {
+	return b.getPkcs11PublicKey(ctx, keyLabel)
+}
+
+func (b *pkcs11KeyBackend) Signer(ctx context.Context,
+	keyLabel string) (crypto.Signer, error) {
+	signer, err := newPkcs11Signer(ctx, b, keyLabel)
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+func (b *pkcs11KeyBackend) DeleteKey(ctx context.Context, keyLabel string) error {
+	return b.deletePkcs11Key(ctx, keyLabel)
+}
+
+func (b *pkcs11KeyBackend) Close() {
+	b.closeToken()
+}
@@ -0,0 +1,11 @@
+//go:build !cgo
+
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/pkcs11_kms
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// The PKCS#11 KMS provider requires cgo to load the PKCS#11 module of the
+// token. In builds without cgo, the provider is not registered and requests
+// to use it are rejected when the configuration is loaded.
+package pkcs11_kms
@@ -0,0 +1,429 @@
+//go:build cgo
+
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/pkcs11_kms
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements various operations on the PKCS#11 token. These capabilities are
+// used by the PKCS#11 KMS provider to generate and manage keys within the
+// token and sign certificates using the right keys. Keys are located using
+// the label assigned to them when they were generated.
+package pkcs11_kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/miekg/pkcs11"
+	"go.uber.org/zap"
+)
+
+const (
+	// The key label assigned to the CA key. The CA key can be found in the
+	// token using this key label.
+	pkcs11CAKeyLabel = "CAKey"
+
+	// ID under which the CA certificate is persisted in the certificate
+	// store.
+	pkcs11CACertID = "pkcs11/CAKey"
+
+	// Format for the key labels of signing keys. The common signing key
+	// has the label "tenant/SharedTenantSigningKey".
+	keyLabelFormat = "tenant/%s"
+
+	// Public exponent of the RSA keys generated in the token (65537).
+	rsaPublicExponent = 65537
+
+	// PKCS#11 operation names
+	pkcs11OpGenerateKeyPair   = "GenerateKeyPair"
+	pkcs11OpFindObjects       = "FindObjects"
+	pkcs11OpGetAttributeValue = "GetAttributeValue"
+	pkcs11OpDestroyObject     = "DestroyObject"
+	pkcs11OpSign              = "Sign"
+)
+
+var (
+	// Returned when the requested key is not present in the token.
+	errPkcs11KeyNotFound = caerrors.New(caerrors.NotFound,
+		"key not found in the PKCS#11 token")
+
+	// Returned when the configured token is not present in any slot.
+	errPkcs11TokenNotFound = caerrors.New(caerrors.DependencyUnavailable,
+		"PKCS#11 token not found")
+)
+
+// An interface exposing the methods of the PKCS#11 module consumed by the
+// provider.
+type Pkcs11Module interface {
+	Initialize(...pkcs11.InitializeOption) error
+	Finalize() error
+	Destroy()
+	GetSlotList(tokenPresent bool) ([]uint, error)
+	GetTokenInfo(slot uint) (pkcs11.TokenInfo, error)
+	OpenSession(slot uint, flags uint) (pkcs11.SessionHandle, error)
+	CloseSession(pkcs11.SessionHandle) error
+	CloseAllSessions(slot uint) error
+	Login(session pkcs11.SessionHandle, userType uint, pin string) error
+	Logout(pkcs11.SessionHandle) error
+	GenerateKeyPair(pkcs11.SessionHandle, []*pkcs11.Mechanism, []*pkcs11.Attribute, []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error)
+	DestroyObject(pkcs11.SessionHandle, pkcs11.ObjectHandle) error
+	GetAttributeValue(pkcs11.SessionHandle, pkcs11.ObjectHandle, []*pkcs11.Attribute) ([]*pkcs11.Attribute, error)
+	FindObjectsInit(pkcs11.SessionHandle, []*pkcs11.Attribute) error
+	FindObjects(session pkcs11.SessionHandle, max int) ([]pkcs11.ObjectHandle, bool, error)
+	FindObjectsFinal(pkcs11.SessionHandle) error
+	SignInit(pkcs11.SessionHandle, []*pkcs11.Mechanism, pkcs11.ObjectHandle) error
+	Sign(session pkcs11.SessionHandle, message []byte) ([]byte, error)
+}
+
+// wrapPkcs11Error - wraps an error returned by the PKCS#11 module into an
+// error with the appropriate category. Errors indicating that the token is
+// unavailable are reported as transient failures.
+func wrapPkcs11Error(message string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var p11err pkcs11.Error
+	if errors.As(err, &p11err) {
+		switch p11err {
+		case pkcs11.CKR_DEVICE_ERROR, pkcs11.CKR_DEVICE_MEMORY,
+			pkcs11.CKR_DEVICE_REMOVED, pkcs11.CKR_TOKEN_NOT_PRESENT,
+			pkcs11.CKR_SESSION_CLOSED, pkcs11.CKR_SESSION_HANDLE_INVALID,
+			pkcs11.CKR_FUNCTION_CANCELED:
+			return caerrors.Wrap(caerrors.DependencyUnavailable, message, err)
+		}
+	}
+	return caerrors.Wrap(caerrors.Internal, message, err)
+}
+
+// openToken - initialize the specified PKCS#11 module, log in to the
+// configured token and return a key backend using a pool of sessions to the
+// token.
+func openToken(logger *zap.Logger, module Pkcs11Module,
+	cfg *Settings) (*pkcs11KeyBackend, error) {
+	err := module.Initialize()
+	if err != nil {
+		logger.Error("Failed to initialize the PKCS#11 module!",
+			zap.String("Module path:", cfg.ModulePath),
+			zap.Error(err),
+		)
+		module.Destroy()
+		return nil, wrapPkcs11Error("failed to initialize the PKCS#11 module",
+			err)
+	}
+
+	backend := &pkcs11KeyBackend{logger: logger}
+	slot, err := findSlot(logger, module, cfg)
+	if err == nil {
+		backend.sessions, err = newSessionPool(logger, module, slot, cfg.Pin,
+			cfg.MaxSessions)
+	}
+	if err != nil {
+		_ = module.Finalize()
+		module.Destroy()
+		return nil, err
+	}
+
+	logger.Info("Opened a session to the PKCS#11 token.",
+		zap.String("Module path:", cfg.ModulePath),
+		zap.Uint("Slot:", slot),
+	)
+	return backend, nil
+}
+
+// findSlot - return the slot containing the configured token. If a token label
+// is configured, the slot containing the token with that label is returned.
+// Otherwise, the configured slot is used.
+func findSlot(logger *zap.Logger, module Pkcs11Module,
+	cfg *Settings) (uint, error) {
+	if cfg.TokenLabel == "" {
+		return cfg.Slot, nil
+	}
+
+	slots, err := module.GetSlotList(true)
+	if err != nil {
+		logger.Error("Failed to list the slots of the PKCS#11 module!",
+			zap.Error(err),
+		)
+		return 0, wrapPkcs11Error("failed to list PKCS#11 slots", err)
+	}
+
+	for _, slot := range slots {
+		info, err := module.GetTokenInfo(slot)
+		if err != nil {
+			logger.Error("Failed to get information about the PKCS#11 token!",
+				zap.Uint("Slot:", slot),
+				zap.Error(err),
+			)
+			continue
+		}
+		if strings.TrimRight(info.Label, " \x00") == cfg.TokenLabel {
+			return slot, nil
+		}
+	}
+
+	logger.Error("The configured PKCS#11 token was not found!",
+		zap.String("Token label:", cfg.TokenLabel),
+	)
+	return 0, errPkcs11TokenNotFound
+}
+
+// closeToken - log out of the token, close all sessions and unload the PKCS#11
+// module.
+func (b *pkcs11KeyBackend) closeToken() {
+	if b.sessions != nil {
+		b.sessions.close()
+	}
+}
+
+// newPkcs11Key - Generate a new RSA key pair in the token, with the requested
+// key label, and return the key label. If a key with the requested label
+// already exists in the token, it is used. The private key is generated as a
+// sensitive, non-exportable key which can only be used for signing.
+func (b *pkcs11KeyBackend) newPkcs11Key(ctx context.Context,
+	keyLabel string) (string, error) {
+	// Keys are created one at a time, so that concurrent requests for the
+	// same key label do not generate duplicate keys.
+	b.keyLock.Lock()
+	defer b.keyLock.Unlock()
+
+	err := b.sessions.withSession(ctx, func(session pkcs11.SessionHandle) error {
+		// Check if the requested key already exists in the token.
+		_, err := b.findObject(session, pkcs11.CKO_PRIVATE_KEY, keyLabel)
+		if err == nil {
+			b.logger.Info("Requested key already exists in the PKCS#11 token!",
+				zap.String("Key label: ", keyLabel),
+			)
+			return nil
+		}
+		if !errors.Is(err, errPkcs11KeyNotFound) {
+			b.logger.Error("Encountered an error checking if key exists in the PKCS#11 token!",
+				zap.String("Key label: ", keyLabel),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		publicKeyTemplate := []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, common.KeySize),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT,
+				big.NewInt(rsaPublicExponent).Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, keyLabel),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, []byte(keyLabel)),
+		}
+		privateKeyTemplate := []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, keyLabel),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, []byte(keyLabel)),
+		}
+
+		start := time.Now()
+		_, _, err = b.sessions.module.GenerateKeyPair(session,
+			[]*pkcs11.Mechanism{
+				pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil),
+			},
+			publicKeyTemplate, privateKeyTemplate)
+		metrics.ReportLatencyMetric(metrics.MetricPkcs11KmsRequestLatency, start,
+			pkcs11OpGenerateKeyPair)
+		if err != nil {
+			b.logger.Error("Failed to generate the requested key in the PKCS#11 token",
+				zap.String("Key label: ", keyLabel),
+				zap.Error(err),
+			)
+			metrics.MetricPkcs11KmsKeyCreationFailures.Inc()
+			return wrapPkcs11Error("failed to generate key in the token", err)
+		}
+		metrics.MetricPkcs11KmsKeyCreated.Inc()
+
+		b.logger.Info("Created the requested key in the PKCS#11 token",
+			zap.String("Key label: ", keyLabel),
+		)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return keyLabel, nil
+}
+
+// deletePkcs11Key - Destroy the key pair with the specified key label in the
+// token.
+func (b *pkcs11KeyBackend) deletePkcs11Key(ctx context.Context,
+	keyLabel string) error {
+	b.keyLock.Lock()
+	defer b.keyLock.Unlock()
+
+	return b.sessions.withSession(ctx, func(session pkcs11.SessionHandle) error {
+		for _, class := range []uint{pkcs11.CKO_PRIVATE_KEY, pkcs11.CKO_PUBLIC_KEY} {
+			object, err := b.findObject(session, class, keyLabel)
+			if errors.Is(err, errPkcs11KeyNotFound) {
+				continue
+			}
+			if err != nil {
+				b.logger.Error("Failed to find the key in the PKCS#11 token!",
+					zap.String("Key label:", keyLabel),
+					zap.Error(err),
+				)
+				metrics.MetricPkcs11KmsKeyDeletionFailures.Inc()
+				return err
+			}
+
+			start := time.Now()
+			err = b.sessions.module.DestroyObject(session, object)
+			metrics.ReportLatencyMetric(metrics.MetricPkcs11KmsRequestLatency,
+				start, pkcs11OpDestroyObject)
+			if err != nil {
+				b.logger.Error("Failed to destroy the key in the PKCS#11 token!",
+					zap.String("Key label:", keyLabel),
+					zap.Error(err),
+				)
+				metrics.MetricPkcs11KmsKeyDeletionFailures.Inc()
+				return wrapPkcs11Error("failed to destroy key in the token", err)
+			}
+		}
+
+		metrics.MetricPkcs11KmsKeyDeleted.Inc()
+		return nil
+	})
+}
+
+// getPkcs11PublicKey - retrieve the public key of the key pair with the
+// specified key label from the token.
+func (b *pkcs11KeyBackend) getPkcs11PublicKey(ctx context.Context,
+	keyLabel string) (crypto.PublicKey, error) {
+	var attributes []*pkcs11.Attribute
+	err := b.sessions.withSession(ctx, func(session pkcs11.SessionHandle) error {
+		object, err := b.findObject(session, pkcs11.CKO_PUBLIC_KEY, keyLabel)
+		if err != nil {
+			b.logger.Error("Failed to find the public key in the PKCS#11 token!",
+				zap.String("Key label:", keyLabel),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		start := time.Now()
+		attributes, err = b.sessions.module.GetAttributeValue(session, object,
+			[]*pkcs11.Attribute{
+				pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+				pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+			})
+		metrics.ReportLatencyMetric(metrics.MetricPkcs11KmsRequestLatency, start,
+			pkcs11OpGetAttributeValue)
+		if err != nil {
+			b.logger.Error("Failed to get the public key from the PKCS#11 token!",
+				zap.String("Key label:", keyLabel),
+				zap.Error(err),
+			)
+			return wrapPkcs11Error("failed to get the public key from the token",
+				err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey := &rsa.PublicKey{N: new(big.Int)}
+	for _, attribute := range attributes {
+		switch attribute.Type {
+		case pkcs11.CKA_MODULUS:
+			publicKey.N.SetBytes(attribute.Value)
+		case pkcs11.CKA_PUBLIC_EXPONENT:
+			publicKey.E = int(new(big.Int).SetBytes(attribute.Value).Int64())
+		}
+	}
+	if (publicKey.N.Sign() == 0) || (publicKey.E == 0) {
+		b.logger.Error("Invalid public key returned by the PKCS#11 token!",
+			zap.String("Key label:", keyLabel),
+		)
+		return nil, caerrors.New(caerrors.Internal,
+			fmt.Sprintf("invalid public key for key %s in the token", keyLabel))
+	}
+	return publicKey, nil
+}
+
+// signPkcs11 - sign the specified data using the private key with the
+// specified key label, using the specified mechanism.
+func (b *pkcs11KeyBackend) signPkcs11(ctx context.Context, keyLabel string,
+	mechanism uint, data []byte) ([]byte, error) {
+	var signature []byte
+	err := b.sessions.withSession(ctx, func(session pkcs11.SessionHandle) error {
+		object, err := b.findObject(session, pkcs11.CKO_PRIVATE_KEY, keyLabel)
+		if err != nil {
+			b.logger.Error("Failed to find the signing key in the PKCS#11 token!",
+				zap.String("Key label:", keyLabel),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		start := time.Now()
+		err = b.sessions.module.SignInit(session,
+			[]*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, object)
+		if err == nil {
+			signature, err = b.sessions.module.Sign(session, data)
+		}
+		metrics.ReportLatencyMetric(metrics.MetricPkcs11KmsRequestLatency, start,
+			pkcs11OpSign)
+		if err != nil {
+			return wrapPkcs11Error("failed to sign using the token", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// findObject - find the object of the specified class with the specified
+// label in the token, using the specified session.
+func (b *pkcs11KeyBackend) findObject(session pkcs11.SessionHandle,
+	class uint, keyLabel string) (pkcs11.ObjectHandle, error) {
+	start := time.Now()
+	defer metrics.ReportLatencyMetric(metrics.MetricPkcs11KmsRequestLatency,
+		start, pkcs11OpFindObjects)
+
+	module := b.sessions.module
+	err := module.FindObjectsInit(session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, keyLabel),
+	})
+	if err != nil {
+		return 0, wrapPkcs11Error("failed to find objects in the token", err)
+	}
+	objects, _, err := module.FindObjects(session, 1)
+	finalErr := module.FindObjectsFinal(session)
+	if err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, wrapPkcs11Error("failed to find objects in the token", err)
+	}
+
+	if len(objects) == 0 {
+		return 0, errPkcs11KeyNotFound
+	}
+	return objects[0], nil
+}
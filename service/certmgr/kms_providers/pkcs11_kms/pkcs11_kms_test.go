@@ -0,0 +1,664 @@
+//go:build cgo
+
+package pkcs11_kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers/kmstest"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/google/uuid"
+	"github.com/miekg/pkcs11"
+	"go.uber.org/zap"
+)
+
+// The provider tests run against an in-memory fake of the PKCS#11 module. To
+// run them against SoftHSMv2 instead:
+//
+//	softhsm2-util --init-token --free --label krypton-ca-test \
+//	    --pin 1234 --so-pin 1234
+//	PKCS11_TEST_MODULE=/usr/lib/softhsm/libsofthsm2.so \
+//	PKCS11_TEST_TOKEN_LABEL=krypton-ca-test PKCS11_TEST_PIN=1234 \
+//	    go test ./certmgr/kms_providers/pkcs11_kms/
+const (
+	testModuleEnv     = "PKCS11_TEST_MODULE"
+	testTokenLabelEnv = "PKCS11_TEST_TOKEN_LABEL"
+	testPinEnv        = "PKCS11_TEST_PIN"
+
+	testTokenLabel = "krypton-ca-test"
+	testPin        = "1234"
+
+	// Slot holding the fake token. The token is found using its label.
+	testSlot = 3
+)
+
+// fakeObject - a key object held by the fake token, along with the attributes
+// it was generated with.
+type fakeObject struct {
+	key        *rsa.PrivateKey
+	attributes map[uint][]byte
+}
+
+// fakeSession - the operations in progress within a session to the fake token.
+type fakeSession struct {
+	finding bool
+	found   []pkcs11.ObjectHandle
+	signKey *rsa.PrivateKey
+}
+
+// fakePkcs11Module - an in-memory fake of the PKCS#11 module methods consumed
+// by the provider. Like a real token, operations already in progress within a
+// session are rejected, so sessions used concurrently are detected.
+type fakePkcs11Module struct {
+	lock sync.Mutex
+
+	initialized bool
+	loggedIn    bool
+	sessions    map[pkcs11.SessionHandle]*fakeSession
+	objects     map[pkcs11.ObjectHandle]*fakeObject
+	nextHandle  uint
+
+	// Number of sessions opened, and the greatest number of signing
+	// operations in progress at a time.
+	opened     int
+	signing    int
+	maxSigning int
+
+	// Time taken by each signing operation.
+	signDelay time.Duration
+
+	// Error returned by the next call, if any.
+	failNext error
+}
+
+func newFakePkcs11Module() *fakePkcs11Module {
+	return &fakePkcs11Module{
+		sessions: map[pkcs11.SessionHandle]*fakeSession{},
+		objects:  map[pkcs11.ObjectHandle]*fakeObject{},
+	}
+}
+
+func (f *fakePkcs11Module) injectedError() error {
+	err := f.failNext
+	f.failNext = nil
+	return err
+}
+
+// session - returns the specified session, after checking for an injected
+// error. The caller must hold the lock.
+func (f *fakePkcs11Module) session(sh pkcs11.SessionHandle) (*fakeSession, error) {
+	if err := f.injectedError(); err != nil {
+		return nil, err
+	}
+	if !f.initialized {
+		return nil, pkcs11.Error(pkcs11.CKR_CRYPTOKI_NOT_INITIALIZED)
+	}
+	session, ok := f.sessions[sh]
+	if !ok {
+		return nil, pkcs11.Error(pkcs11.CKR_SESSION_HANDLE_INVALID)
+	}
+	return session, nil
+}
+
+func (f *fakePkcs11Module) Initialize(...pkcs11.InitializeOption) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.initialized {
+		return pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED)
+	}
+	f.initialized = true
+	return nil
+}
+
+func (f *fakePkcs11Module) Finalize() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.initialized = false
+	f.loggedIn = false
+	f.sessions = map[pkcs11.SessionHandle]*fakeSession{}
+	return nil
+}
+
+func (f *fakePkcs11Module) Destroy() {
+}
+
+func (f *fakePkcs11Module) GetSlotList(tokenPresent bool) ([]uint, error) {
+	return []uint{testSlot}, nil
+}
+
+func (f *fakePkcs11Module) GetTokenInfo(slot uint) (pkcs11.TokenInfo, error) {
+	if slot != testSlot {
+		return pkcs11.TokenInfo{}, pkcs11.Error(pkcs11.CKR_SLOT_ID_INVALID)
+	}
+	return pkcs11.TokenInfo{Label: fmt.Sprintf("%-32s", testTokenLabel)}, nil
+}
+
+func (f *fakePkcs11Module) OpenSession(slot uint,
+	flags uint) (pkcs11.SessionHandle, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.injectedError(); err != nil {
+		return 0, err
+	}
+	if slot != testSlot {
+		return 0, pkcs11.Error(pkcs11.CKR_SLOT_ID_INVALID)
+	}
+	f.nextHandle++
+	f.sessions[pkcs11.SessionHandle(f.nextHandle)] = &fakeSession{}
+	f.opened++
+	return pkcs11.SessionHandle(f.nextHandle), nil
+}
+
+func (f *fakePkcs11Module) CloseSession(sh pkcs11.SessionHandle) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.sessions, sh)
+	if len(f.sessions) == 0 {
+		f.loggedIn = false
+	}
+	return nil
+}
+
+func (f *fakePkcs11Module) CloseAllSessions(slot uint) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.sessions = map[pkcs11.SessionHandle]*fakeSession{}
+	f.loggedIn = false
+	return nil
+}
+
+func (f *fakePkcs11Module) Login(sh pkcs11.SessionHandle, userType uint,
+	pin string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, err := f.session(sh); err != nil {
+		return err
+	}
+	if pin != testPin {
+		return pkcs11.Error(pkcs11.CKR_PIN_INCORRECT)
+	}
+	if f.loggedIn {
+		return pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)
+	}
+	f.loggedIn = true
+	return nil
+}
+
+func (f *fakePkcs11Module) Logout(sh pkcs11.SessionHandle) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, err := f.session(sh); err != nil {
+		return err
+	}
+	f.loggedIn = false
+	return nil
+}
+
+func (f *fakePkcs11Module) addObject(key *rsa.PrivateKey,
+	template []*pkcs11.Attribute) pkcs11.ObjectHandle {
+	object := &fakeObject{key: key, attributes: map[uint][]byte{}}
+	for _, attribute := range template {
+		object.attributes[attribute.Type] = attribute.Value
+	}
+	f.nextHandle++
+	f.objects[pkcs11.ObjectHandle(f.nextHandle)] = object
+	return pkcs11.ObjectHandle(f.nextHandle)
+}
+
+func (f *fakePkcs11Module) GenerateKeyPair(sh pkcs11.SessionHandle,
+	mechanisms []*pkcs11.Mechanism, public []*pkcs11.Attribute,
+	private []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, err := f.session(sh); err != nil {
+		return 0, 0, err
+	}
+	if !f.loggedIn {
+		return 0, 0, pkcs11.Error(pkcs11.CKR_USER_NOT_LOGGED_IN)
+	}
+	if (len(mechanisms) != 1) ||
+		(mechanisms[0].Mechanism != pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN) {
+		return 0, 0, pkcs11.Error(pkcs11.CKR_MECHANISM_INVALID)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, common.KeySize)
+	if err != nil {
+		return 0, 0, pkcs11.Error(pkcs11.CKR_GENERAL_ERROR)
+	}
+	return f.addObject(key, public), f.addObject(key, private), nil
+}
+
+func (f *fakePkcs11Module) DestroyObject(sh pkcs11.SessionHandle,
+	oh pkcs11.ObjectHandle) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, err := f.session(sh); err != nil {
+		return err
+	}
+	if _, ok := f.objects[oh]; !ok {
+		return pkcs11.Error(pkcs11.CKR_OBJECT_HANDLE_INVALID)
+	}
+	delete(f.objects, oh)
+	return nil
+}
+
+func (f *fakePkcs11Module) GetAttributeValue(sh pkcs11.SessionHandle,
+	oh pkcs11.ObjectHandle,
+	template []*pkcs11.Attribute) ([]*pkcs11.Attribute, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, err := f.session(sh); err != nil {
+		return nil, err
+	}
+	object, ok := f.objects[oh]
+	if !ok {
+		return nil, pkcs11.Error(pkcs11.CKR_OBJECT_HANDLE_INVALID)
+	}
+
+	var attributes []*pkcs11.Attribute
+	for _, attribute := range template {
+		value, ok := object.attributes[attribute.Type]
+		switch attribute.Type {
+		case pkcs11.CKA_MODULUS:
+			value, ok = object.key.N.Bytes(), true
+		case pkcs11.CKA_PUBLIC_EXPONENT:
+			value, ok = big.NewInt(int64(object.key.E)).Bytes(), true
+		}
+		if !ok {
+			return nil, pkcs11.Error(pkcs11.CKR_ATTRIBUTE_TYPE_INVALID)
+		}
+		attributes = append(attributes, pkcs11.NewAttribute(attribute.Type, value))
+	}
+	return attributes, nil
+}
+
+func (f *fakePkcs11Module) FindObjectsInit(sh pkcs11.SessionHandle,
+	template []*pkcs11.Attribute) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	session, err := f.session(sh)
+	if err != nil {
+		return err
+	}
+	if session.finding {
+		return pkcs11.Error(pkcs11.CKR_OPERATION_ACTIVE)
+	}
+
+	session.finding = true
+	session.found = nil
+	for handle, object := range f.objects {
+		private := (len(object.attributes[pkcs11.CKA_PRIVATE]) == 1) &&
+			(object.attributes[pkcs11.CKA_PRIVATE][0] == 1)
+		matches := f.loggedIn || !private
+		for _, attribute := range template {
+			if string(object.attributes[attribute.Type]) != string(attribute.Value) {
+				matches = false
+			}
+		}
+		if matches {
+			session.found = append(session.found, handle)
+		}
+	}
+	return nil
+}
+
+func (f *fakePkcs11Module) FindObjects(sh pkcs11.SessionHandle,
+	max int) ([]pkcs11.ObjectHandle, bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	session, err := f.session(sh)
+	if err != nil {
+		return nil, false, err
+	}
+	if !session.finding {
+		return nil, false, pkcs11.Error(pkcs11.CKR_OPERATION_NOT_INITIALIZED)
+	}
+	found := session.found[:min(max, len(session.found))]
+	session.found = session.found[len(found):]
+	return found, false, nil
+}
+
+func (f *fakePkcs11Module) FindObjectsFinal(sh pkcs11.SessionHandle) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	session, err := f.session(sh)
+	if err != nil {
+		return err
+	}
+	if !session.finding {
+		return pkcs11.Error(pkcs11.CKR_OPERATION_NOT_INITIALIZED)
+	}
+	session.finding = false
+	return nil
+}
+
+func (f *fakePkcs11Module) SignInit(sh pkcs11.SessionHandle,
+	mechanisms []*pkcs11.Mechanism, oh pkcs11.ObjectHandle) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	session, err := f.session(sh)
+	if err != nil {
+		return err
+	}
+	if session.signKey != nil {
+		return pkcs11.Error(pkcs11.CKR_OPERATION_ACTIVE)
+	}
+	if !f.loggedIn {
+		return pkcs11.Error(pkcs11.CKR_USER_NOT_LOGGED_IN)
+	}
+	if (len(mechanisms) != 1) || (mechanisms[0].Mechanism != pkcs11.CKM_RSA_PKCS) {
+		return pkcs11.Error(pkcs11.CKR_MECHANISM_INVALID)
+	}
+	object, ok := f.objects[oh]
+	if !ok || (len(object.attributes[pkcs11.CKA_SIGN]) != 1) ||
+		(object.attributes[pkcs11.CKA_SIGN][0] != 1) {
+		return pkcs11.Error(pkcs11.CKR_KEY_HANDLE_INVALID)
+	}
+	session.signKey = object.key
+	return nil
+}
+
+func (f *fakePkcs11Module) Sign(sh pkcs11.SessionHandle,
+	message []byte) ([]byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	session, err := f.session(sh)
+	if err != nil {
+		return nil, err
+	}
+	key := session.signKey
+	if key == nil {
+		return nil, pkcs11.Error(pkcs11.CKR_OPERATION_NOT_INITIALIZED)
+	}
+
+	f.signing++
+	f.maxSigning = max(f.maxSigning, f.signing)
+	f.lock.Unlock()
+	time.Sleep(f.signDelay)
+	f.lock.Lock()
+	f.signing--
+	session.signKey = nil
+
+	// CKM_RSA_PKCS signs the DigestInfo structure as is.
+	signature, err := rsa.SignPKCS1v15(nil, key, 0, message)
+	if err != nil {
+		return nil, pkcs11.Error(pkcs11.CKR_DATA_INVALID)
+	}
+	return signature, nil
+}
+
+// Initialize a PKCS#11 KMS provider with a certificate store in the specified
+// directory. The provider uses the test token specified using environment
+// variables, if any, else the specified fake PKCS#11 module.
+func newTestProvider(t *testing.T, dir string,
+	module *fakePkcs11Module) (*Pkcs11KmsProvider, certstore.CertStore) {
+	modulePath := os.Getenv(testModuleEnv)
+	if modulePath == "" {
+		_, store := kmstest.NewConfig(t, dir, common.KmsProviderPkcs11,
+			map[string]string{
+				"CA_PKCS11_MODULE_PATH": "fake",
+				"CA_PKCS11_TOKEN_LABEL": testTokenLabel,
+				"CA_PKCS11_PIN":         testPin,
+			})
+
+		provider := &Pkcs11KmsProvider{logger: zap.NewNop()}
+		err := provider.initProvider(module, &settings, store, nil)
+		if err != nil {
+			store.Shutdown()
+			t.Fatalf("Failed to initialize the PKCS#11 KMS provider: %v", err)
+		}
+		return provider, store
+	}
+
+	cfgMgr, store := kmstest.NewConfig(t, dir, common.KmsProviderPkcs11,
+		map[string]string{
+			"CA_PKCS11_MODULE_PATH": modulePath,
+			"CA_PKCS11_TOKEN_LABEL": os.Getenv(testTokenLabelEnv),
+			"CA_PKCS11_PIN":         os.Getenv(testPinEnv),
+		})
+
+	provider := &Pkcs11KmsProvider{}
+	err := provider.Init(zap.NewNop(), cfgMgr, store)
+	if err != nil {
+		store.Shutdown()
+		t.Fatalf("Failed to initialize the PKCS#11 KMS provider: %v", err)
+	}
+	return provider, store
+}
+
+// Skip tests that depend on the behaviour of the fake PKCS#11 module when
+// running against a test token.
+func skipIfTestToken(t *testing.T) {
+	if os.Getenv(testModuleEnv) != "" {
+		t.Skipf("%s set, skipping test using the fake PKCS#11 module",
+			testModuleEnv)
+	}
+}
+
+func TestPkcs11KmsProvider_DeviceCertificate(t *testing.T) {
+	ctx := context.Background()
+	provider, store := newTestProvider(t, t.TempDir(), newFakePkcs11Module())
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	tenantID := uuid.NewString()
+	_, err := provider.CreateTenantSigningCertificate(ctx, tenantID, "PKCS#11 tenant")
+	if err != nil {
+		t.Fatalf("Failed to create the tenant signing certificate: %v", err)
+	}
+
+	// The tenant signing key must not be exportable from the token.
+	var attributes []*pkcs11.Attribute
+	backend := provider.Backend().(*pkcs11KeyBackend)
+	err = backend.sessions.withSession(ctx, func(session pkcs11.SessionHandle) error {
+		object, err := backend.findObject(session, pkcs11.CKO_PRIVATE_KEY,
+			fmt.Sprintf(keyLabelFormat, tenantID))
+		if err != nil {
+			return err
+		}
+		attributes, err = backend.sessions.module.GetAttributeValue(session,
+			object, []*pkcs11.Attribute{
+				pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, nil),
+				pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, nil),
+			})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to get the attributes of the tenant key: %v", err)
+	}
+	for _, attribute := range attributes {
+		sensitive := (len(attribute.Value) == 1) && (attribute.Value[0] == 1)
+		if sensitive != (attribute.Type == pkcs11.CKA_SENSITIVE) {
+			t.Errorf("Unexpected value for key attribute %#x: %v",
+				attribute.Type, attribute.Value)
+		}
+	}
+
+	kmstest.CheckDeviceCertificates(t, provider, tenantID)
+
+	err = provider.DeleteTenantSigningCertificate(ctx, tenantID)
+	if err != nil {
+		t.Fatalf("Failed to delete the tenant signing certificate: %v", err)
+	}
+	_, err = backend.getPkcs11PublicKey(ctx,
+		fmt.Sprintf(keyLabelFormat, tenantID))
+	if !errors.Is(err, errPkcs11KeyNotFound) {
+		t.Errorf("Expected the tenant key to be destroyed, got %v", err)
+	}
+}
+
+func TestPkcs11KmsProvider_Restart(t *testing.T) {
+	dir := t.TempDir()
+	module := newFakePkcs11Module()
+	kmstest.CheckRestart(t, func() (kms_providers.KmsProvider,
+		certstore.CertStore) {
+		return newTestProvider(t, dir, module)
+	})
+}
+
+func TestPkcs11KmsProvider_Unavailable(t *testing.T) {
+	skipIfTestToken(t)
+	module := newFakePkcs11Module()
+	provider, store := newTestProvider(t, t.TempDir(), module)
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	kmstest.CheckUnavailable(t, provider, func() {
+		module.lock.Lock()
+		module.failNext = pkcs11.Error(pkcs11.CKR_DEVICE_ERROR)
+		module.lock.Unlock()
+	})
+}
+
+func TestPkcs11KmsProvider_InvalidSession(t *testing.T) {
+	ctx := context.Background()
+	skipIfTestToken(t)
+	module := newFakePkcs11Module()
+	provider, store := newTestProvider(t, t.TempDir(), module)
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	module.lock.Lock()
+	module.failNext = pkcs11.Error(pkcs11.CKR_SESSION_HANDLE_INVALID)
+	opened := module.opened
+	module.lock.Unlock()
+
+	_, err := provider.CreateTenantSigningCertificate(ctx, uuid.NewString(), "")
+	if !caerrors.Is(err, caerrors.DependencyUnavailable) {
+		t.Errorf("Expected a dependency unavailable error, got %v", err)
+	}
+
+	// The invalidated session is discarded, and a new session is opened for
+	// the next request.
+	_, err = provider.CreateTenantSigningCertificate(ctx, uuid.NewString(), "")
+	if err != nil {
+		t.Fatalf("Failed to create the tenant signing certificate: %v", err)
+	}
+	module.lock.Lock()
+	defer module.lock.Unlock()
+	if module.opened != opened+1 {
+		t.Errorf("Expected a new session to be opened, got %d",
+			module.opened-opened)
+	}
+}
+
+func TestPkcs11KmsProvider_ConcurrentSessions(t *testing.T) {
+	ctx := context.Background()
+	skipIfTestToken(t)
+	t.Setenv("CA_PKCS11_MAX_SESSIONS", "2")
+	module := newFakePkcs11Module()
+	provider, store := newTestProvider(t, t.TempDir(), module)
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	module.lock.Lock()
+	module.signDelay = 20 * time.Millisecond
+	module.lock.Unlock()
+
+	// Device certificates are signed using up to the configured number of
+	// sessions at a time.
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _, _, err := provider.CreateDeviceCertificate(ctx,
+				uuid.NewString(), csr)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Failed to create the device certificate: %v", err)
+		}
+	}
+
+	module.lock.Lock()
+	defer module.lock.Unlock()
+	if module.maxSigning != 2 {
+		t.Errorf("Expected 2 concurrent signing operations, got %d",
+			module.maxSigning)
+	}
+	if module.opened > 2 {
+		t.Errorf("Expected at most 2 sessions to be opened, got %d",
+			module.opened)
+	}
+}
+
+func TestPkcs11KmsProvider_Cancelled(t *testing.T) {
+	skipIfTestToken(t)
+	t.Setenv("CA_PKCS11_MAX_SESSIONS", "1")
+	provider, store := newTestProvider(t, t.TempDir(), newFakePkcs11Module())
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	// Hold the only session, so that further operations wait for it.
+	held := make(chan struct{})
+	release := make(chan struct{})
+	backend := provider.Backend().(*pkcs11KeyBackend)
+	go func() {
+		_ = backend.sessions.withSession(context.Background(),
+			func(pkcs11.SessionHandle) error {
+				close(held)
+				<-release
+				return nil
+			})
+	}()
+	<-held
+	defer close(release)
+
+	// Operations on behalf of a cancelled request stop waiting for a session.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := backend.getPkcs11PublicKey(ctx, pkcs11CAKeyLabel)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the request to be cancelled, got %v", err)
+	}
+}
+
+func TestPkcs11KmsProvider_Shutdown(t *testing.T) {
+	skipIfTestToken(t)
+	module := newFakePkcs11Module()
+	provider, store := newTestProvider(t, t.TempDir(), module)
+	defer store.Shutdown()
+
+	// Shutting down the provider closes all sessions and finalizes the
+	// module, and further operations on the token are rejected.
+	provider.Shutdown()
+	module.lock.Lock()
+	if module.initialized || module.loggedIn || (len(module.sessions) != 0) {
+		t.Errorf("Expected the token to be closed")
+	}
+	module.lock.Unlock()
+
+	_, err := provider.Backend().(*pkcs11KeyBackend).getPkcs11PublicKey(
+		context.Background(), pkcs11CAKeyLabel)
+	if !caerrors.Is(err, caerrors.DependencyUnavailable) {
+		t.Errorf("Expected a dependency unavailable error, got %v", err)
+	}
+}
+
+func TestPkcs11Signer_UnsupportedOptions(t *testing.T) {
+	kmstest.CheckUnsupportedOptions(t, &Pkcs11Signer{keyLabel: "unused"},
+		crypto.SHA1)
+}
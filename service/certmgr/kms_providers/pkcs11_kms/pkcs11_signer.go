@@ -0,0 +1,115 @@
+//go:build cgo
+
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/pkcs11_kms
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements a crypto signer interface that is used to sign certificates using
+// keys held within a PKCS#11 token.
+package pkcs11_kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"io"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/miekg/pkcs11"
+	"go.uber.org/zap"
+)
+
+// DER encoded DigestInfo prefixes for the supported hash functions. The
+// CKM_RSA_PKCS mechanism signs the DigestInfo structure as is, so the prefix
+// identifying the hash function is prepended to the digest (RFC 8017).
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01,
+		0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01,
+		0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01,
+		0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// Pkcs11Signer implements the crypto/Signer interface that can be used for
+// signing operations using a key held within a PKCS#11 token.
+// see https://golang.org/pkg/crypto/#Signer
+type Pkcs11Signer struct {
+	// The key backend holding the sessions to the token.
+	backend *pkcs11KeyBackend
+
+	// Label of the key used for signing.
+	keyLabel string
+
+	// Public key.
+	publicKey crypto.PublicKey
+
+	// Context of the request on behalf of which signing operations are
+	// performed. Signing operations stop waiting for a session to the token
+	// once it is done.
+	ctx context.Context
+}
+
+// Initializes a new instance of the PKCS#11 signer using the requested key
+// label, which signs on behalf of the request with the specified context.
+func newPkcs11Signer(ctx context.Context, backend *pkcs11KeyBackend,
+	keyLabel string) (*Pkcs11Signer, error) {
+	key, err := backend.getPkcs11PublicKey(ctx, keyLabel)
+	if err != nil {
+		backend.logger.Error("Failed to get the public key from the PKCS#11 token!",
+			zap.String("Key label:", keyLabel),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return &Pkcs11Signer{
+		backend:   backend,
+		keyLabel:  keyLabel,
+		publicKey: key,
+		ctx:       ctx,
+	}, nil
+}
+
+// Public returns the public key used by the signer.
+func (s *Pkcs11Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign the requested digest using the PKCS#11 token. Only RSA PKCS #1 v1.5
+// signatures are supported.
+func (s *Pkcs11Signer) Sign(rand io.Reader, digest []byte,
+	opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		metrics.MetricPkcs11KmsSignatureFailures.Inc()
+		return nil, caerrors.New(caerrors.Internal,
+			"RSA-PSS signatures are not supported by the PKCS#11 signer")
+	}
+
+	prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+	if !ok || (len(digest) != opts.HashFunc().Size()) {
+		metrics.MetricPkcs11KmsSignatureFailures.Inc()
+		return nil, caerrors.New(caerrors.Internal,
+			"unsupported digest for the PKCS#11 signer")
+	}
+
+	digestInfo := make([]byte, 0, len(prefix)+len(digest))
+	digestInfo = append(digestInfo, prefix...)
+	digestInfo = append(digestInfo, digest...)
+
+	signature, err := s.backend.signPkcs11(s.ctx, s.keyLabel, pkcs11.CKM_RSA_PKCS,
+		digestInfo)
+	if err != nil {
+		s.backend.logger.Error("Failed to sign using the PKCS#11 token!",
+			zap.String("Key label:", s.keyLabel),
+			zap.Error(err),
+		)
+		metrics.MetricPkcs11KmsSignatureFailures.Inc()
+		return nil, err
+	}
+
+	metrics.MetricPkcs11KmsSignatureSuccess.Inc()
+	return signature, nil
+}
@@ -0,0 +1,179 @@
+//go:build cgo
+
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/pkcs11_kms
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the pool of sessions used to access the PKCS#11 token. A PKCS#11
+// session may only be used by one operation at a time, so each operation on
+// the token borrows a session from the pool for its duration. This allows up
+// to the configured number of operations to be performed on the token
+// concurrently. Sessions are opened on demand, and sessions invalidated by the
+// token are discarded rather than returned to the pool.
+package pkcs11_kms
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/miekg/pkcs11"
+	"go.uber.org/zap"
+)
+
+var (
+	// Returned when an operation is requested after the token is closed.
+	errPkcs11TokenClosed = caerrors.New(caerrors.DependencyUnavailable,
+		"the PKCS#11 token is not open")
+)
+
+// sessionPool - a pool of sessions to the PKCS#11 token. The pool is safe for
+// concurrent use.
+type sessionPool struct {
+	logger *zap.Logger
+	module Pkcs11Module
+	slot   uint
+	pin    string
+
+	// Sessions which are open and not in use by any operation.
+	idle chan pkcs11.SessionHandle
+
+	// Holds an entry for each session in use, bounding the number of sessions
+	// in use at a time.
+	inUse chan struct{}
+
+	// Closed when the pool is closed, so that operations waiting for a
+	// session are abandoned.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newSessionPool - open a session to the token in the specified slot and log
+// in to the token using the specified PIN. The returned pool opens up to the
+// specified number of sessions to the token.
+func newSessionPool(logger *zap.Logger, module Pkcs11Module, slot uint,
+	pin string, maxSessions int) (*sessionPool, error) {
+	sp := &sessionPool{
+		logger: logger,
+		module: module,
+		slot:   slot,
+		pin:    pin,
+		idle:   make(chan pkcs11.SessionHandle, maxSessions),
+		inUse:  make(chan struct{}, maxSessions),
+		done:   make(chan struct{}),
+	}
+
+	session, err := sp.openSession()
+	if err != nil {
+		return nil, err
+	}
+	sp.idle <- session
+	return sp, nil
+}
+
+// openSession - open a new session to the token. The token is logged in to
+// when the first session is opened, and remains logged in while any session
+// is open, so further sessions are already logged in.
+func (sp *sessionPool) openSession() (pkcs11.SessionHandle, error) {
+	session, err := sp.module.OpenSession(sp.slot,
+		pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		sp.logger.Error("Failed to open a session to the PKCS#11 token!",
+			zap.Uint("Slot:", sp.slot),
+			zap.Error(err),
+		)
+		return 0, wrapPkcs11Error("failed to open a session to the token", err)
+	}
+
+	err = sp.module.Login(session, pkcs11.CKU_USER, sp.pin)
+	if (err != nil) && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+		sp.logger.Error("Failed to log in to the PKCS#11 token!",
+			zap.Uint("Slot:", sp.slot),
+			zap.Error(err),
+		)
+		_ = sp.module.CloseSession(session)
+		return 0, wrapPkcs11Error("failed to log in to the token", err)
+	}
+	return session, nil
+}
+
+// withSession - borrow a session from the pool and call the specified function
+// with it. If all sessions are in use, the call waits for a session to be
+// returned to the pool, or for the specified context to be done.
+func (sp *sessionPool) withSession(ctx context.Context,
+	fn func(session pkcs11.SessionHandle) error) error {
+	select {
+	case sp.inUse <- struct{}{}:
+	case <-sp.done:
+		return errPkcs11TokenClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-sp.inUse }()
+
+	// The pool may have been closed while waiting for a session.
+	select {
+	case <-sp.done:
+		return errPkcs11TokenClosed
+	default:
+	}
+
+	var session pkcs11.SessionHandle
+	select {
+	case session = <-sp.idle:
+	default:
+		var err error
+		session, err = sp.openSession()
+		if err != nil {
+			return err
+		}
+	}
+
+	err := fn(session)
+	if isSessionInvalid(err) {
+		sp.logger.Error("Discarding a session invalidated by the PKCS#11 token!",
+			zap.Error(err),
+		)
+		_ = sp.module.CloseSession(session)
+		return err
+	}
+	sp.idle <- session
+	return err
+}
+
+// close - wait for the sessions in use to be returned to the pool, log out of
+// the token, close all sessions and unload the PKCS#11 module.
+func (sp *sessionPool) close() {
+	sp.closeOnce.Do(func() {
+		close(sp.done)
+		for i := 0; i < cap(sp.inUse); i++ {
+			sp.inUse <- struct{}{}
+		}
+
+		select {
+		case session := <-sp.idle:
+			_ = sp.module.Logout(session)
+		default:
+		}
+		_ = sp.module.CloseAllSessions(sp.slot)
+		_ = sp.module.Finalize()
+		sp.module.Destroy()
+	})
+}
+
+// isSessionInvalid - returns true if the specified error indicates that the
+// session it was returned for can no longer be used.
+func isSessionInvalid(err error) bool {
+	var p11err pkcs11.Error
+	if !errors.As(err, &p11err) {
+		return false
+	}
+	switch p11err {
+	case pkcs11.CKR_SESSION_CLOSED, pkcs11.CKR_SESSION_HANDLE_INVALID,
+		pkcs11.CKR_DEVICE_REMOVED, pkcs11.CKR_TOKEN_NOT_PRESENT:
+		return true
+	}
+	return false
+}
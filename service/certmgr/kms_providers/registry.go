@@ -0,0 +1,59 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Maintains the registry of KMS providers which can be used by the CA. KMS
+// providers register themselves from an init function, so that a provider is
+// compiled into the CA by importing the package implementing it (eg. using a
+// blank import).
+package kms_providers
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/HPInc/krypton-ca/service/config"
+)
+
+// Factory - returns a new, uninitialized instance of a KMS provider. The CA
+// initializes the instance by invoking its Init method.
+type Factory func() KmsProvider
+
+var (
+	registryLock sync.RWMutex
+	factories    = map[string]Factory{}
+)
+
+// Register - register the KMS provider with the specified name, along with
+// the factory used to create instances of the provider and the schema of the
+// provider's configuration settings. The name is used to select the provider
+// using the kms_provider configuration setting. Register panics if it is
+// invoked twice with the same name.
+func Register(name string, factory Factory, schema config.ProviderSchema) {
+	if factory == nil {
+		panic(fmt.Sprintf("nil factory registered for kms provider %q", name))
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("duplicate registration of kms provider %q", name))
+	}
+	config.RegisterKmsProvider(name, schema)
+	factories[name] = factory
+}
+
+// New - create a new, uninitialized instance of the KMS provider with the
+// specified name. False is returned if no such provider has been registered.
+func New(name string) (KmsProvider, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	factory, ok := factories[name]
+	if !ok {
+		return nil, false
+	}
+	return factory(), true
+}
@@ -0,0 +1,90 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the signing pool used by KMS providers to bound the number of
+// signing operations performed concurrently. Signing with a local private key
+// is CPU bound and signing with a remote KMS is subject to request quotas, so
+// signing operations beyond the configured limit wait for a slot, rather than
+// contending for the CPU or being throttled by the KMS. Signers obtained from
+// the pool stop waiting for a slot once the request they sign for is
+// cancelled.
+package kms_providers
+
+import (
+	"context"
+	"crypto"
+	"io"
+
+	"github.com/HPInc/krypton-ca/service/metrics"
+)
+
+// SigningPool - bounds the number of signing operations performed
+// concurrently using signers obtained from the pool. A nil pool does not bound
+// signing operations. The pool is safe for concurrent use.
+type SigningPool struct {
+	slots chan struct{}
+}
+
+// NewSigningPool - returns a signing pool which performs at most the specified
+// number of signing operations concurrently. If the specified number is not
+// positive, nil is returned and signing operations are not bounded.
+func NewSigningPool(maxConcurrentOperations int) *SigningPool {
+	if maxConcurrentOperations <= 0 {
+		return nil
+	}
+	return &SigningPool{
+		slots: make(chan struct{}, maxConcurrentOperations),
+	}
+}
+
+// Signer - returns a signer which signs using the specified signer, within the
+// bounds of the pool. Signing operations waiting for a slot in the pool fail
+// with the error of the specified context once it is done, so the signer must
+// only be used on behalf of the request the context belongs to.
+func (p *SigningPool) Signer(ctx context.Context,
+	signer crypto.Signer) crypto.Signer {
+	if p == nil {
+		return signer
+	}
+	return &pooledSigner{pool: p, ctx: ctx, signer: signer}
+}
+
+// A signer whose signing operations are performed within a signing pool.
+type pooledSigner struct {
+	pool *SigningPool
+
+	// Context of the request on whose behalf signing operations are
+	// performed.
+	ctx context.Context
+
+	signer crypto.Signer
+}
+
+// Public returns the public key used by the signer.
+func (s *pooledSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+// Sign the requested digest, once a slot in the signing pool is available. If
+// the request is cancelled while waiting for a slot, the error of the request
+// context is returned.
+func (s *pooledSigner) Sign(rand io.Reader, digest []byte,
+	opts crypto.SignerOpts) ([]byte, error) {
+	metrics.MetricSigningOperationsQueued.Inc()
+	select {
+	case s.pool.slots <- struct{}{}:
+		metrics.MetricSigningOperationsQueued.Dec()
+	case <-s.ctx.Done():
+		metrics.MetricSigningOperationsQueued.Dec()
+		return nil, s.ctx.Err()
+	}
+
+	metrics.MetricSigningOperationsInFlight.Inc()
+	defer func() {
+		metrics.MetricSigningOperationsInFlight.Dec()
+		<-s.pool.slots
+	}()
+	return s.signer.Sign(rand, digest, opts)
+}
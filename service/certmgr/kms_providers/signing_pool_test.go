@@ -0,0 +1,104 @@
+package kms_providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A signer which tracks the largest number of signing operations performed
+// concurrently.
+type trackingSigner struct {
+	crypto.Signer
+	lock          sync.Mutex
+	inFlight      int
+	maxConcurrent int
+}
+
+func (s *trackingSigner) Sign(rand io.Reader, digest []byte,
+	opts crypto.SignerOpts) ([]byte, error) {
+	s.lock.Lock()
+	s.inFlight++
+	if s.inFlight > s.maxConcurrent {
+		s.maxConcurrent = s.inFlight
+	}
+	s.lock.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	s.lock.Lock()
+	s.inFlight--
+	s.lock.Unlock()
+	return s.Signer.Sign(rand, digest, opts)
+}
+
+func TestSigningPool(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate a key: %v", err)
+	}
+	signer := &trackingSigner{Signer: key}
+	pooled := NewSigningPool(2).Signer(context.Background(), signer)
+	if pooled.Public() != key.Public() {
+		t.Errorf("Expected the pooled signer to use the public key of the signer")
+	}
+
+	digest := sha256.Sum256([]byte("digest"))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			signature, err := pooled.Sign(rand.Reader, digest[:], crypto.SHA256)
+			if err != nil {
+				t.Errorf("Failed to sign: %v", err)
+				return
+			}
+			if !ecdsa.VerifyASN1(&key.PublicKey, digest[:], signature) {
+				t.Errorf("Failed to verify the signature")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if signer.maxConcurrent != 2 {
+		t.Errorf("Expected at most 2 concurrent signing operations, got %d",
+			signer.maxConcurrent)
+	}
+
+	// Signing operations are not bounded without a pool.
+	if NewSigningPool(0).Signer(context.Background(), signer) != crypto.Signer(signer) {
+		t.Errorf("Expected the signer to be returned without a pool")
+	}
+}
+
+func TestSigningPool_Cancelled(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate a key: %v", err)
+	}
+	pool := NewSigningPool(1)
+
+	// Occupy the only slot in the pool.
+	pool.slots <- struct{}{}
+	defer func() { <-pool.slots }()
+
+	// Signing operations waiting for a slot fail once the request is
+	// cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	digest := sha256.Sum256([]byte("digest"))
+	_, err = pool.Signer(ctx, key).Sign(rand.Reader, digest[:], crypto.SHA256)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the signing operation to time out, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/vault_transit
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Initializes the Vault transit KMS provider.
+package vault_transit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"go.uber.org/zap"
+)
+
+var (
+	// Settings of the Vault transit KMS provider, read from the vault_transit
+	// section under certificate_authority/providers in the configuration
+	// file.
+	settings = Settings{
+		Mount:      "transit",
+		AuthMethod: vaultAuthMethodToken,
+		AuthMount:  "approle",
+	}
+)
+
+// Methods used to authenticate with Vault.
+const (
+	vaultAuthMethodToken   = "token"
+	vaultAuthMethodAppRole = "approle"
+)
+
+// Settings represents configuration settings for the Vault transit KMS
+// provider, which generates and uses the CA's signing keys within the transit
+// secrets engine of a HashiCorp Vault server.
+type Settings struct {
+	// Address of the Vault server (eg. https://vault.example.com:8200).
+	Address string `yaml:"address"`
+
+	// Path at which the transit secrets engine is mounted.
+	Mount string `yaml:"mount"`
+
+	// Vault namespace (Vault Enterprise only).
+	Namespace string `yaml:"namespace"`
+
+	// Method used to authenticate with Vault - "token" or "approle".
+	AuthMethod string `yaml:"auth_method"`
+
+	// Path at which the AppRole auth method is mounted, and the role ID
+	// used to log in when using the AppRole auth method.
+	AuthMount string `yaml:"auth_mount"`
+	RoleID    string `yaml:"role_id"`
+
+	// Populated after reading the CA_VAULT_TOKEN environment variable. The
+	// token is used to authenticate with Vault when using the token auth
+	// method. For security reasons, this may not be specified using the
+	// configuration YAML file.
+	Token string `yaml:"-"`
+
+	// Populated after reading the CA_VAULT_SECRET_ID environment variable.
+	// The secret ID is used to log in when using the AppRole auth method.
+	// For security reasons, this may not be specified using the
+	// configuration YAML file.
+	SecretID string `yaml:"-"`
+}
+
+// Register the Vault transit KMS provider.
+func init() {
+	kms_providers.Register(common.KmsProviderVault, func() kms_providers.KmsProvider {
+		return &VaultTransitProvider{}
+	}, config.ProviderSchema{
+		Settings: &settings,
+		Env: map[string]config.EnvOverride{
+			"CA_VAULT_ADDR":        {Value: &settings.Address},
+			"CA_VAULT_MOUNT":       {Value: &settings.Mount},
+			"CA_VAULT_NAMESPACE":   {Value: &settings.Namespace},
+			"CA_VAULT_AUTH_METHOD": {Value: &settings.AuthMethod},
+			"CA_VAULT_ROLE_ID":     {Value: &settings.RoleID},
+			"CA_VAULT_TOKEN":       {Value: &settings.Token, Secret: true},
+			"CA_VAULT_SECRET_ID":   {Value: &settings.SecretID, Secret: true},
+		},
+		Validate: func(*config.ConfigMgr) error {
+			return validateSettings(&settings)
+		},
+	})
+}
+
+// validateSettings - validate that the address of the Vault server, the
+// transit mount and the credentials required by the configured auth method
+// have been specified.
+func validateSettings(s *Settings) error {
+	if (s.Address == "") || (s.Mount == "") {
+		return errors.New("the address of the Vault server and the transit mount must be specified")
+	}
+	switch s.AuthMethod {
+	case vaultAuthMethodToken:
+		if s.Token == "" {
+			return errors.New("the Vault token must be specified using CA_VAULT_TOKEN")
+		}
+	case vaultAuthMethodAppRole:
+		if (s.AuthMount == "") || (s.RoleID == "") || (s.SecretID == "") {
+			return errors.New("the AppRole mount, role ID and secret ID (CA_VAULT_SECRET_ID) must be specified")
+		}
+	default:
+		return errors.New("the Vault auth method must be token or approle")
+	}
+	return nil
+}
+
+// VaultTransitProvider - uses the transit secrets engine of a HashiCorp Vault
+// server for cryptographic operations. The root and signing keys are created
+// within Vault as non-exportable keys and never leave Vault when consumed for
+// signing operations. Keys are identified within Vault using key names.
+type VaultTransitProvider struct {
+	logger *zap.Logger
+
+	// Issues and manages certificates using the keys held within Vault.
+	kms_providers.KeyBackendProvider
+}
+
+// Init - initialize the Vault transit KMS provider.
+func (p *VaultTransitProvider) Init(logger *zap.Logger, cfgMgr *config.ConfigMgr,
+	store certstore.CertStore) error {
+	p.logger = logger
+	p.logger.Info("Vault transit KMS provider settings",
+		zap.String(" - Address:", settings.Address),
+		zap.String(" - Transit mount:", settings.Mount),
+		zap.String(" - Namespace:", settings.Namespace),
+		zap.String(" - Auth method:", settings.AuthMethod),
+	)
+
+	// Initialize a client to the Vault server and authenticate with Vault
+	// using the configured auth method.
+	ctx := context.Background()
+	backend := &vaultKeyBackend{
+		logger: p.logger,
+		client: newVaultClient(p.logger, &settings),
+	}
+	err := backend.client.login(ctx)
+	if err != nil {
+		p.logger.Error("Failed to authenticate with Vault!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// Initialize the CA certificate and the common signing certificate, and
+	// use the specified certificate store to persist signing certificates. If
+	// the CA has not been initialized yet, the CA key is created within Vault
+	// and used to issue the CA certificate.
+	err = p.KeyBackendProvider.Init(ctx, p.logger, backend, store,
+		kms_providers.NewSigningPool(
+			cfgMgr.GetSigningConfig().MaxConcurrentOperations),
+		vaultCACertID, vaultCAKeyName)
+	if err != nil {
+		backend.Close()
+		return err
+	}
+
+	p.logger.Info("Vault transit KMS provider initialized successfully!")
+	return nil
+}
+
+// Shutdown - clean up and shutdown the Vault transit KMS provider.
+func (p *VaultTransitProvider) Shutdown() {
+	p.KeyBackendProvider.Shutdown()
+	p.logger.Info("Vault transit KMS provider shutdown!")
+}
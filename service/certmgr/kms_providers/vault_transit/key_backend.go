@@ -0,0 +1,62 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/vault_transit
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Exposes the keys held within Vault to the implementation of the KMS provider
+// shared with other providers holding their keys within a key management
+// service.
+package vault_transit
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// vaultKeyBackend - creates and uses keys held within the transit secrets
+// engine of Vault. Keys are identified within Vault using their key names.
+type vaultKeyBackend struct {
+	logger *zap.Logger
+
+	// Client used to send requests to Vault.
+	client *vaultClient
+}
+
+func (b *vaultKeyBackend) Name() string {
+	return "Vault"
+}
+
+func (b *vaultKeyBackend) TenantKeyName(tenantID string) string {
+	return fmt.Sprintf(keyNameFormat, tenantID)
+}
+
+func (b *vaultKeyBackend) CreateKey(ctx context.Context,
+	keyName string) (string, error) {
+	return b.newVaultKey(ctx, keyName)
+}
+
+func (b *vaultKeyBackend) PublicKey(ctx context.Context,
+	keyName string) (crypto.PublicKey, error) {
+	key, _, err := b.getVaultPublicKey(ctx, keyName)
+	return key, err
+}
+
+func (b *vaultKeyBackend) Signer(ctx context.Context,
+	keyName string) (crypto.Signer, error) {
+	signer, err := newVaultSigner(ctx, b, keyName)
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+func (b *vaultKeyBackend) DeleteKey(ctx context.Context, keyName string) error {
+	return b.deleteVaultKey(ctx, keyName)
+}
+
+func (b *vaultKeyBackend) Close() {
+	b.client.httpClient.CloseIdleConnections()
+}
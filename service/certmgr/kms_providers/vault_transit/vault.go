@@ -0,0 +1,456 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/vault_transit
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements various operations exposed by the transit secrets engine of
+// HashiCorp Vault. These capabilities are used by the Vault transit KMS
+// provider to create and manage keys in Vault and sign certificates using the
+// right keys. Requests are sent to the Vault HTTP API.
+package vault_transit
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// Timeouts that apply to requests made to Vault.
+	vaultRequestTimeout = 5 * time.Second
+
+	// Maximum size of responses read from Vault.
+	maxVaultResponseSize = 1024 * 1024
+
+	// The key name assigned to the CA key. The CA key can be found in Vault
+	// using this key name.
+	vaultCAKeyName = "CAKey"
+
+	// ID under which the CA certificate is persisted in the certificate
+	// store.
+	vaultCACertID = "vault_transit/CAKey"
+
+	// Format for the names of signing keys. The common signing key has the
+	// name "tenant-SharedTenantSigningKey".
+	keyNameFormat = "tenant-%s"
+
+	// Vault request headers.
+	headerVaultToken     = "X-Vault-Token"
+	headerVaultNamespace = "X-Vault-Namespace"
+
+	// Algorithm used by Vault to sign prehashed input.
+	vaultSignatureAlgorithm = "pkcs1v15"
+
+	// Vault operation names
+	vaultOpLogin        = "Login"
+	vaultOpCreateKey    = "CreateKey"
+	vaultOpReadKey      = "ReadKey"
+	vaultOpUpdateConfig = "UpdateKeyConfig"
+	vaultOpDeleteKey    = "DeleteKey"
+	vaultOpSign         = "Sign"
+)
+
+// Names used by Vault for the supported hash algorithms.
+var vaultHashAlgorithms = map[crypto.Hash]string{
+	crypto.SHA256: "sha2-256",
+	crypto.SHA384: "sha2-384",
+	crypto.SHA512: "sha2-512",
+}
+
+// vaultError - an error response returned by Vault.
+type vaultError struct {
+	StatusCode int
+	Errors     []string
+}
+
+func (e *vaultError) Error() string {
+	return fmt.Sprintf("vault returned status %d: %s", e.StatusCode,
+		strings.Join(e.Errors, "; "))
+}
+
+// wrapVaultError - wraps an error returned by Vault into an error with the
+// appropriate category. Failures to reach Vault and responses indicating that
+// Vault is unavailable or throttling requests are reported as transient
+// failures.
+func wrapVaultError(message string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var verr *vaultError
+	if errors.As(err, &verr) {
+		switch {
+		case verr.StatusCode == http.StatusNotFound:
+			return caerrors.Wrap(caerrors.NotFound, message, err)
+		case (verr.StatusCode == http.StatusTooManyRequests) ||
+			(verr.StatusCode == http.StatusBadGateway) ||
+			(verr.StatusCode == http.StatusServiceUnavailable) ||
+			(verr.StatusCode == http.StatusGatewayTimeout):
+			return caerrors.Wrap(caerrors.DependencyUnavailable, message, err)
+		}
+		return caerrors.Wrap(caerrors.Internal, message, err)
+	}
+
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return caerrors.Wrap(caerrors.DependencyUnavailable, message, err)
+	}
+	return caerrors.Wrap(caerrors.Internal, message, err)
+}
+
+// vaultClient - sends requests to the Vault HTTP API, authenticated using the
+// configured auth method.
+type vaultClient struct {
+	logger     *zap.Logger
+	httpClient *http.Client
+	cfg        *Settings
+
+	// Address of the Vault server and the mount path of the transit engine.
+	address string
+	mount   string
+
+	// Token used to authenticate requests. When using the AppRole auth
+	// method, a new token is obtained if the current token is rejected.
+	lock  sync.RWMutex
+	token string
+}
+
+// Initializes a new client to the Vault server.
+func newVaultClient(logger *zap.Logger, cfg *Settings) *vaultClient {
+	return &vaultClient{
+		logger:     logger,
+		httpClient: &http.Client{Timeout: vaultRequestTimeout},
+		cfg:        cfg,
+		address:    strings.TrimRight(cfg.Address, "/"),
+		mount:      strings.Trim(cfg.Mount, "/"),
+	}
+}
+
+// login - obtain the token used to authenticate requests to Vault. When using
+// the token auth method, the configured token is used. When using the AppRole
+// auth method, the client logs in using the configured role ID and secret ID.
+func (c *vaultClient) login(ctx context.Context) error {
+	if c.cfg.AuthMethod != vaultAuthMethodAppRole {
+		c.setToken(c.cfg.Token)
+		return nil
+	}
+
+	var response struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	start := time.Now()
+	err := c.send(ctx, http.MethodPost,
+		fmt.Sprintf("auth/%s/login", strings.Trim(c.cfg.AuthMount, "/")),
+		"", map[string]string{
+			"role_id":   c.cfg.RoleID,
+			"secret_id": c.cfg.SecretID,
+		}, &response)
+	metrics.ReportLatencyMetric(metrics.MetricVaultTransitRequestLatency, start,
+		vaultOpLogin)
+	if err != nil {
+		c.logger.Error("Failed to log in to Vault using AppRole!",
+			zap.String("Auth mount:", c.cfg.AuthMount),
+			zap.Error(err),
+		)
+		return wrapVaultError("failed to log in to Vault", err)
+	}
+	if response.Auth.ClientToken == "" {
+		return caerrors.New(caerrors.Internal,
+			"no token returned by Vault on login")
+	}
+
+	c.setToken(response.Auth.ClientToken)
+	return nil
+}
+
+func (c *vaultClient) setToken(token string) {
+	c.lock.Lock()
+	c.token = token
+	c.lock.Unlock()
+}
+
+func (c *vaultClient) getToken() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.token
+}
+
+// do - send an authenticated request to Vault on behalf of the request with
+// the specified context. If the token is rejected when using the AppRole auth
+// method, the client logs in again and retries the request once.
+func (c *vaultClient) do(ctx context.Context, method string, path string,
+	body interface{}, result interface{}) error {
+	err := c.send(ctx, method, path, c.getToken(), body, result)
+
+	var verr *vaultError
+	if errors.As(err, &verr) && (verr.StatusCode == http.StatusForbidden) &&
+		(c.cfg.AuthMethod == vaultAuthMethodAppRole) {
+		c.logger.Info("Vault token was rejected, logging in again.")
+		if lerr := c.login(ctx); lerr != nil {
+			return lerr
+		}
+		err = c.send(ctx, method, path, c.getToken(), body, result)
+	}
+	return err
+}
+
+// send - send a request to the Vault HTTP API and decode the JSON response
+// into the specified result, if any. The request is cancelled if the specified
+// context is done.
+func (c *vaultClient) send(ctx context.Context, method string, path string,
+	token string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method,
+		c.address+"/v1/"+path, reader)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		request.Header.Set(headerVaultToken, token)
+	}
+	if c.cfg.Namespace != "" {
+		request.Header.Set(headerVaultNamespace, c.cfg.Namespace)
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxVaultResponseSize))
+	if err != nil {
+		return err
+	}
+
+	if (response.StatusCode < http.StatusOK) ||
+		(response.StatusCode >= http.StatusMultipleChoices) {
+		verr := &vaultError{StatusCode: response.StatusCode}
+		var errorResponse struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(data, &errorResponse) == nil {
+			verr.Errors = errorResponse.Errors
+		}
+		return verr
+	}
+
+	if (result == nil) || (len(data) == 0) {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+// Returns the path of the specified transit endpoint for the specified key.
+func (c *vaultClient) transitPath(endpoint string, keyName string) string {
+	return fmt.Sprintf("%s/%s/%s", c.mount, endpoint, url.PathEscape(keyName))
+}
+
+// newVaultKey - Create a new RSA key in Vault with the requested key name, and
+// return the key name. If a key with the requested name already exists in
+// Vault, it is used. The key is created as a non-exportable key.
+func (b *vaultKeyBackend) newVaultKey(ctx context.Context,
+	keyName string) (string, error) {
+	// Check if the requested key already exists in Vault.
+	_, _, err := b.getVaultPublicKey(ctx, keyName)
+	if err == nil {
+		b.logger.Info("Requested key already exists in Vault!",
+			zap.String("Key name: ", keyName),
+		)
+		return keyName, nil
+	}
+	if !caerrors.Is(err, caerrors.NotFound) {
+		b.logger.Error("Encountered an error checking if key exists in Vault!",
+			zap.String("Key name: ", keyName),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
+	// Create a new key in Vault.
+	start := time.Now()
+	err = b.client.do(ctx, http.MethodPost, b.client.transitPath("keys", keyName),
+		map[string]interface{}{
+			"type":       fmt.Sprintf("rsa-%d", common.KeySize),
+			"exportable": false,
+		}, nil)
+	metrics.ReportLatencyMetric(metrics.MetricVaultTransitRequestLatency, start,
+		vaultOpCreateKey)
+	if err != nil {
+		b.logger.Error("Failed to create the requested key in Vault",
+			zap.String("Key name: ", keyName),
+			zap.Error(err),
+		)
+		metrics.MetricVaultTransitKeyCreationFailures.Inc()
+		return "", wrapVaultError("failed to create key in Vault", err)
+	}
+	metrics.MetricVaultTransitKeyCreated.Inc()
+
+	b.logger.Info("Created the requested key in Vault",
+		zap.String("Key name: ", keyName),
+	)
+	return keyName, nil
+}
+
+// deleteVaultKey - Delete the key with the specified key name from Vault.
+// Vault only permits keys to be deleted once deletion has been allowed in
+// the configuration of the key. Keys that do not exist in Vault are treated
+// as already deleted.
+func (b *vaultKeyBackend) deleteVaultKey(ctx context.Context,
+	keyName string) error {
+	start := time.Now()
+	err := b.client.do(ctx, http.MethodPost,
+		b.client.transitPath("keys", keyName)+"/config",
+		map[string]interface{}{
+			"deletion_allowed": true,
+		}, nil)
+	metrics.ReportLatencyMetric(metrics.MetricVaultTransitRequestLatency, start,
+		vaultOpUpdateConfig)
+	if err == nil {
+		start = time.Now()
+		err = b.client.do(ctx, http.MethodDelete,
+			b.client.transitPath("keys", keyName), nil, nil)
+		metrics.ReportLatencyMetric(metrics.MetricVaultTransitRequestLatency,
+			start, vaultOpDeleteKey)
+	}
+
+	var verr *vaultError
+	if errors.As(err, &verr) && (verr.StatusCode == http.StatusNotFound) {
+		b.logger.Info("Key to be deleted was not found in Vault.",
+			zap.String("Key name:", keyName),
+		)
+		return nil
+	}
+	if err != nil {
+		b.logger.Error("Failed to delete the key from Vault!",
+			zap.String("Key name:", keyName),
+			zap.Error(err),
+		)
+		metrics.MetricVaultTransitKeyDeletionFailures.Inc()
+		return wrapVaultError("failed to delete key in Vault", err)
+	}
+
+	metrics.MetricVaultTransitKeyDeleted.Inc()
+	return nil
+}
+
+// getVaultPublicKey - retrieve the public key of the latest version of the key
+// with the specified key name from Vault, along with the key version.
+func (b *vaultKeyBackend) getVaultPublicKey(ctx context.Context,
+	keyName string) (crypto.PublicKey, int, error) {
+	var response struct {
+		Data struct {
+			LatestVersion int `json:"latest_version"`
+			Keys          map[string]struct {
+				PublicKey string `json:"public_key"`
+			} `json:"keys"`
+		} `json:"data"`
+	}
+
+	start := time.Now()
+	err := b.client.do(ctx, http.MethodGet, b.client.transitPath("keys", keyName),
+		nil, &response)
+	metrics.ReportLatencyMetric(metrics.MetricVaultTransitRequestLatency, start,
+		vaultOpReadKey)
+	if err != nil {
+		return nil, 0, wrapVaultError("failed to read key from Vault", err)
+	}
+
+	version := response.Data.LatestVersion
+	block, _ := pem.Decode([]byte(
+		response.Data.Keys[strconv.Itoa(version)].PublicKey))
+	if block == nil {
+		b.logger.Error("No public key returned by Vault for the key!",
+			zap.String("Key name:", keyName),
+			zap.Int("Key version:", version),
+		)
+		return nil, 0, caerrors.New(caerrors.Internal,
+			fmt.Sprintf("no public key returned for key %s by Vault", keyName))
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		b.logger.Error("Failed to parse the public key returned by Vault!",
+			zap.String("Key name:", keyName),
+			zap.Error(err),
+		)
+		return nil, 0, caerrors.Wrap(caerrors.Internal,
+			"failed to parse the public key returned by Vault", err)
+	}
+	return publicKey, version, nil
+}
+
+// signVault - sign the specified digest using the specified version of the
+// key with the specified key name.
+func (b *vaultKeyBackend) signVault(ctx context.Context, keyName string,
+	version int, hash crypto.Hash, digest []byte) ([]byte, error) {
+	hashAlgorithm, ok := vaultHashAlgorithms[hash]
+	if !ok {
+		return nil, caerrors.New(caerrors.Internal,
+			"unsupported hash algorithm for Vault transit")
+	}
+
+	var response struct {
+		Data struct {
+			Signature string `json:"signature"`
+		} `json:"data"`
+	}
+	start := time.Now()
+	err := b.client.do(ctx, http.MethodPost,
+		b.client.transitPath("sign", keyName)+"/"+hashAlgorithm,
+		map[string]interface{}{
+			"input":               base64.StdEncoding.EncodeToString(digest),
+			"prehashed":           true,
+			"signature_algorithm": vaultSignatureAlgorithm,
+			"key_version":         version,
+		}, &response)
+	metrics.ReportLatencyMetric(metrics.MetricVaultTransitRequestLatency, start,
+		vaultOpSign)
+	if err != nil {
+		return nil, wrapVaultError("failed to sign using Vault", err)
+	}
+
+	// Signatures are returned in the form "vault:v<version>:<base64>".
+	parts := strings.SplitN(response.Data.Signature, ":", 3)
+	if len(parts) != 3 {
+		return nil, caerrors.New(caerrors.Internal,
+			"invalid signature returned by Vault")
+	}
+	signature, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, caerrors.Wrap(caerrors.Internal,
+			"invalid signature returned by Vault", err)
+	}
+	return signature, nil
+}
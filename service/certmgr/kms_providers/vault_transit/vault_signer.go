@@ -0,0 +1,99 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/kms_providers/vault_transit
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements a crypto signer interface that is used to sign certificates using
+// keys held within the transit secrets engine of HashiCorp Vault.
+package vault_transit
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"io"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"go.uber.org/zap"
+)
+
+// VaultSigner implements the crypto/Signer interface that can be used for
+// signing operations using a key held within Vault.
+// see https://golang.org/pkg/crypto/#Signer
+type VaultSigner struct {
+	// The key backend holding the client to Vault.
+	backend *vaultKeyBackend
+
+	// Name and version of the key used for signing. The version is pinned
+	// so that signatures match the public key, even if the key is rotated.
+	keyName    string
+	keyVersion int
+
+	// Public key.
+	publicKey crypto.PublicKey
+
+	// Context of the request on behalf of which signing operations are
+	// performed. Signing requests made to Vault are cancelled with it.
+	ctx context.Context
+}
+
+// Initializes a new instance of the Vault signer using the latest version of
+// the requested key, which signs on behalf of the request with the specified
+// context.
+func newVaultSigner(ctx context.Context, backend *vaultKeyBackend,
+	keyName string) (*VaultSigner, error) {
+	key, version, err := backend.getVaultPublicKey(ctx, keyName)
+	if err != nil {
+		backend.logger.Error("Failed to get the public key from Vault!",
+			zap.String("Key name:", keyName),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return &VaultSigner{
+		backend:    backend,
+		keyName:    keyName,
+		keyVersion: version,
+		publicKey:  key,
+		ctx:        ctx,
+	}, nil
+}
+
+// Public returns the public key used by the signer.
+func (s *VaultSigner) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign the requested digest using Vault. Only RSA PKCS #1 v1.5 signatures are
+// supported.
+func (s *VaultSigner) Sign(rand io.Reader, digest []byte,
+	opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		metrics.MetricVaultTransitSignatureFailures.Inc()
+		return nil, caerrors.New(caerrors.Internal,
+			"RSA-PSS signatures are not supported by the Vault signer")
+	}
+
+	_, ok := vaultHashAlgorithms[opts.HashFunc()]
+	if !ok || (len(digest) != opts.HashFunc().Size()) {
+		metrics.MetricVaultTransitSignatureFailures.Inc()
+		return nil, caerrors.New(caerrors.Internal,
+			"unsupported digest for the Vault signer")
+	}
+
+	signature, err := s.backend.signVault(s.ctx, s.keyName, s.keyVersion,
+		opts.HashFunc(), digest)
+	if err != nil {
+		s.backend.logger.Error("Failed to sign using Vault!",
+			zap.String("Key name:", s.keyName),
+			zap.Error(err),
+		)
+		metrics.MetricVaultTransitSignatureFailures.Inc()
+		return nil, err
+	}
+
+	metrics.MetricVaultTransitSignatureSuccess.Inc()
+	return signature, nil
+}
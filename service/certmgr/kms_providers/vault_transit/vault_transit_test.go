@@ -0,0 +1,373 @@
+package vault_transit
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers/kmstest"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// The provider tests run against an in-process stand-in for the transit
+// secrets engine of Vault. To run them against a Vault dev server instead:
+//
+//	vault server -dev -dev-root-token-id=root
+//	vault secrets enable transit
+//	VAULT_TEST_ADDR=http://127.0.0.1:8200 VAULT_TEST_TOKEN=root \
+//	    go test ./certmgr/kms_providers/vault_transit/
+const (
+	testAddrEnv  = "VAULT_TEST_ADDR"
+	testTokenEnv = "VAULT_TEST_TOKEN"
+
+	testRoleID   = "test-role-id"
+	testSecretID = "test-secret-id"
+)
+
+// fakeVault - an in-process stand-in for the transit secrets engine and the
+// AppRole auth method of Vault.
+type fakeVault struct {
+	lock sync.Mutex
+
+	// Keys held by the transit engine, and whether they can be deleted.
+	keys            map[string]*rsa.PrivateKey
+	deletionAllowed map[string]bool
+
+	// Tokens accepted by the stand-in, and the number of logins.
+	tokens map[string]bool
+	logins int
+
+	// Status code returned for the next request, if any.
+	failNext int
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	fake := &fakeVault{
+		keys:            map[string]*rsa.PrivateKey{},
+		deletionAllowed: map[string]bool{},
+		tokens:          map[string]bool{"root": true},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func writeVaultResponse(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if body != nil {
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
+
+func writeVaultError(w http.ResponseWriter, status int, message string) {
+	writeVaultResponse(w, status, map[string][]string{"errors": {message}})
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.failNext != 0 {
+		writeVaultError(w, f.failNext, "injected failure")
+		f.failNext = 0
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	if path == "auth/approle/login" {
+		f.login(w, r)
+		return
+	}
+	if !f.tokens[r.Header.Get(headerVaultToken)] {
+		writeVaultError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	switch {
+	case (len(parts) == 3) && (parts[1] == "keys"):
+		f.handleKey(w, r, parts[2])
+	case (len(parts) == 4) && (parts[1] == "keys") && (parts[3] == "config"):
+		f.deletionAllowed[parts[2]] = true
+		writeVaultResponse(w, http.StatusNoContent, nil)
+	case (len(parts) == 4) && (parts[1] == "sign"):
+		f.sign(w, r, parts[2], parts[3])
+	default:
+		writeVaultError(w, http.StatusNotFound, "unsupported path")
+	}
+}
+
+func (f *fakeVault) login(w http.ResponseWriter, r *http.Request) {
+	var request map[string]string
+	if (json.NewDecoder(r.Body).Decode(&request) != nil) ||
+		(request["role_id"] != testRoleID) ||
+		(request["secret_id"] != testSecretID) {
+		writeVaultError(w, http.StatusBadRequest, "invalid role or secret ID")
+		return
+	}
+
+	f.logins++
+	token := uuid.NewString()
+	f.tokens[token] = true
+	writeVaultResponse(w, http.StatusOK, map[string]interface{}{
+		"auth": map[string]string{"client_token": token},
+	})
+}
+
+func (f *fakeVault) handleKey(w http.ResponseWriter, r *http.Request,
+	name string) {
+	key, found := f.keys[name]
+	switch r.Method {
+	case http.MethodGet:
+		if !found {
+			writeVaultResponse(w, http.StatusNotFound,
+				map[string][]string{"errors": {}})
+			return
+		}
+		der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		writeVaultResponse(w, http.StatusOK, map[string]interface{}{
+			"data": map[string]interface{}{
+				"latest_version": 1,
+				"keys": map[string]interface{}{
+					"1": map[string]string{
+						"public_key": string(pem.EncodeToMemory(&pem.Block{
+							Type: "PUBLIC KEY", Bytes: der})),
+					},
+				},
+			},
+		})
+
+	case http.MethodPost:
+		var request map[string]interface{}
+		if (json.NewDecoder(r.Body).Decode(&request) != nil) ||
+			(request["type"] != fmt.Sprintf("rsa-%d", common.KeySize)) ||
+			(request["exportable"] != false) {
+			writeVaultError(w, http.StatusBadRequest, "invalid key parameters")
+			return
+		}
+		if !found {
+			f.keys[name], _ = rsa.GenerateKey(rand.Reader, common.KeySize)
+		}
+		writeVaultResponse(w, http.StatusNoContent, nil)
+
+	case http.MethodDelete:
+		if !f.deletionAllowed[name] {
+			writeVaultError(w, http.StatusBadRequest,
+				"deletion is not allowed for this key")
+			return
+		}
+		delete(f.keys, name)
+		writeVaultResponse(w, http.StatusNoContent, nil)
+
+	default:
+		writeVaultError(w, http.StatusMethodNotAllowed, "unsupported method")
+	}
+}
+
+func (f *fakeVault) sign(w http.ResponseWriter, r *http.Request, name string,
+	hashAlgorithm string) {
+	var request struct {
+		Input              string `json:"input"`
+		Prehashed          bool   `json:"prehashed"`
+		SignatureAlgorithm string `json:"signature_algorithm"`
+		KeyVersion         int    `json:"key_version"`
+	}
+	if json.NewDecoder(r.Body).Decode(&request) != nil {
+		writeVaultError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	key, found := f.keys[name]
+	if !found {
+		writeVaultError(w, http.StatusBadRequest, "signing key not found")
+		return
+	}
+
+	var hash crypto.Hash
+	for h, name := range vaultHashAlgorithms {
+		if name == hashAlgorithm {
+			hash = h
+		}
+	}
+	digest, err := base64.StdEncoding.DecodeString(request.Input)
+	if (hash == 0) || (err != nil) || !request.Prehashed ||
+		(request.SignatureAlgorithm != vaultSignatureAlgorithm) ||
+		(request.KeyVersion != 1) {
+		writeVaultError(w, http.StatusBadRequest, "invalid sign parameters")
+		return
+	}
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+	if err != nil {
+		writeVaultError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeVaultResponse(w, http.StatusOK, map[string]interface{}{
+		"data": map[string]string{
+			"signature": "vault:v1:" +
+				base64.StdEncoding.EncodeToString(signature),
+		},
+	})
+}
+
+// Initialize a Vault transit KMS provider with a certificate store in a
+// temporary directory. The provider uses the Vault dev server specified using
+// environment variables, if any, else the specified Vault stand-in. The
+// stand-in is authenticated with using AppRole.
+func newTestProvider(t *testing.T, dir string,
+	server *httptest.Server) (*VaultTransitProvider, certstore.CertStore) {
+	env := map[string]string{
+		"CA_VAULT_ADDR":        server.URL,
+		"CA_VAULT_AUTH_METHOD": vaultAuthMethodAppRole,
+		"CA_VAULT_ROLE_ID":     testRoleID,
+		"CA_VAULT_SECRET_ID":   testSecretID,
+	}
+	if addr := os.Getenv(testAddrEnv); addr != "" {
+		env = map[string]string{
+			"CA_VAULT_ADDR":        addr,
+			"CA_VAULT_AUTH_METHOD": vaultAuthMethodToken,
+			"CA_VAULT_TOKEN":       os.Getenv(testTokenEnv),
+		}
+	}
+	cfgMgr, store := kmstest.NewConfig(t, dir, common.KmsProviderVault, env)
+
+	provider := &VaultTransitProvider{}
+	err := provider.Init(zap.NewNop(), cfgMgr, store)
+	if err != nil {
+		store.Shutdown()
+		t.Fatalf("Failed to initialize the Vault transit KMS provider: %v", err)
+	}
+	return provider, store
+}
+
+// Skip tests that depend on the behaviour of the Vault stand-in when running
+// against a Vault dev server.
+func skipIfDevServer(t *testing.T) {
+	if os.Getenv(testAddrEnv) != "" {
+		t.Skipf("%s set, skipping test using the Vault stand-in", testAddrEnv)
+	}
+}
+
+func TestVaultTransitProvider_DeviceCertificate(t *testing.T) {
+	ctx := context.Background()
+	_, server := newFakeVault(t)
+	provider, store := newTestProvider(t, t.TempDir(), server)
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	tenantID := uuid.NewString()
+	_, err := provider.CreateTenantSigningCertificate(ctx, tenantID, "Vault tenant")
+	if err != nil {
+		t.Fatalf("Failed to create the tenant signing certificate: %v", err)
+	}
+
+	kmstest.CheckDeviceCertificates(t, provider, tenantID)
+
+	err = provider.DeleteTenantSigningCertificate(ctx, tenantID)
+	if err != nil {
+		t.Fatalf("Failed to delete the tenant signing certificate: %v", err)
+	}
+	_, _, err = provider.Backend().(*vaultKeyBackend).getVaultPublicKey(ctx,
+		fmt.Sprintf(keyNameFormat, tenantID))
+	if !caerrors.Is(err, caerrors.NotFound) {
+		t.Errorf("Expected the tenant key to be deleted, got %v", err)
+	}
+}
+
+func TestVaultTransitProvider_Restart(t *testing.T) {
+	dir := t.TempDir()
+	_, server := newFakeVault(t)
+	kmstest.CheckRestart(t, func() (kms_providers.KmsProvider,
+		certstore.CertStore) {
+		return newTestProvider(t, dir, server)
+	})
+}
+
+func TestVaultTransitProvider_Relogin(t *testing.T) {
+	ctx := context.Background()
+	skipIfDevServer(t)
+	fake, server := newFakeVault(t)
+	provider, store := newTestProvider(t, t.TempDir(), server)
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	// Revoke the token obtained on login. The provider is expected to log in
+	// again and retry the request.
+	fake.lock.Lock()
+	fake.tokens = map[string]bool{}
+	logins := fake.logins
+	fake.lock.Unlock()
+
+	_, err := provider.CreateTenantSigningCertificate(ctx, uuid.NewString(), "")
+	if err != nil {
+		t.Fatalf("Failed to create the tenant signing certificate: %v", err)
+	}
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if fake.logins != logins+1 {
+		t.Errorf("Expected the provider to log in again, got %d logins",
+			fake.logins-logins)
+	}
+}
+
+func TestVaultTransitProvider_Unavailable(t *testing.T) {
+	skipIfDevServer(t)
+	fake, server := newFakeVault(t)
+	provider, store := newTestProvider(t, t.TempDir(), server)
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	kmstest.CheckUnavailable(t, provider, func() {
+		fake.lock.Lock()
+		fake.failNext = http.StatusServiceUnavailable
+		fake.lock.Unlock()
+	})
+}
+
+func TestVaultTransitProvider_Cancelled(t *testing.T) {
+	skipIfDevServer(t)
+	fake, server := newFakeVault(t)
+	provider, store := newTestProvider(t, t.TempDir(), server)
+	defer store.Shutdown()
+	defer provider.Shutdown()
+
+	// Requests made on behalf of a cancelled caller are abandoned rather
+	// than sent to Vault.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tenantID := uuid.NewString()
+	_, err := provider.CreateTenantSigningCertificate(ctx, tenantID, "")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the request to be cancelled, got %v", err)
+	}
+
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if _, ok := fake.keys[fmt.Sprintf(keyNameFormat, tenantID)]; ok {
+		t.Errorf("Expected no key to be created for a cancelled request")
+	}
+}
+
+func TestVaultSigner_UnsupportedOptions(t *testing.T) {
+	kmstest.CheckUnsupportedOptions(t, &VaultSigner{keyName: "unused"},
+		crypto.SHA1)
+}
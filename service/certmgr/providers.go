@@ -0,0 +1,25 @@
+// package github.com/HPInc/krypton-ca/service/certmgr
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Compiles the built-in KMS providers and certificate stores into the CA.
+// Providers register themselves when their package is imported. Out-of-tree
+// providers are compiled into the CA in the same way, by adding a blank import
+// of the package implementing the provider (eg. to main.go).
+package certmgr
+
+import (
+	// Built-in certificate stores.
+	_ "github.com/HPInc/krypton-ca/service/certmgr/certstore/dynamodb"
+	_ "github.com/HPInc/krypton-ca/service/certmgr/certstore/localdb"
+	_ "github.com/HPInc/krypton-ca/service/certmgr/certstore/postgres"
+
+	// Built-in KMS providers.
+	_ "github.com/HPInc/krypton-ca/service/certmgr/kms_providers/aws_kms"
+	_ "github.com/HPInc/krypton-ca/service/certmgr/kms_providers/azure_keyvault"
+	_ "github.com/HPInc/krypton-ca/service/certmgr/kms_providers/gcp_kms"
+	_ "github.com/HPInc/krypton-ca/service/certmgr/kms_providers/local_kms"
+	_ "github.com/HPInc/krypton-ca/service/certmgr/kms_providers/pkcs11_kms"
+	_ "github.com/HPInc/krypton-ca/service/certmgr/kms_providers/vault_transit"
+)
@@ -0,0 +1,464 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/quota
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements per-tenant device quotas. The quota manager tracks the device
+// certificates issued within each tenant (until they expire) and determines
+// whether a new device may be enrolled within the tenant, based on the device
+// cap configured for the tenant. Device caps can be configured using the
+// configuration file or set for individual tenants using the admin RPCs.
+//
+// To avoid counting the devices within a tenant on every enrollment, the
+// device cap and the number of active devices are cached for each tenant and
+// refreshed from the certificate store periodically. Capacity for new devices
+// is reserved against the cached count, so concurrent enrollments handled by
+// an instance of the CA cannot exceed the cap. Enrollments handled by other
+// instances are only accounted for when the count is refreshed.
+//
+// Devices are recorded whether or not a device cap applies to the tenant, so
+// that devices enrolled while the tenant is not capped count against a cap set
+// later on. The cap is only applied when capacity is reserved for a new device.
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"go.uber.org/zap"
+)
+
+var (
+	caLogger *zap.Logger
+
+	// ErrDeviceCapReached is returned when the tenant already has the maximum
+	// number of active devices permitted by its quota.
+	ErrDeviceCapReached = caerrors.New(caerrors.PolicyViolation,
+		"device cap reached for tenant")
+
+	// ErrInvalidQuota is returned when an invalid quota is specified.
+	ErrInvalidQuota = caerrors.New(caerrors.InvalidInput,
+		"invalid quota specified")
+)
+
+// Interval after which the cached device cap and count of active devices for
+// a tenant are refreshed from the certificate store.
+const tenantRefreshInterval = time.Minute
+
+// Sources from which the device cap for a tenant was determined.
+const (
+	QuotaSourceDefault = "default"
+	QuotaSourceConfig  = "config"
+	QuotaSourceTenant  = "tenant"
+)
+
+// TenantQuota - describes the device quota applicable to a tenant and the
+// number of active devices currently enrolled within the tenant.
+type TenantQuota struct {
+	// The unique identifier for the tenant.
+	TenantID string
+
+	// Maximum number of active devices permitted within the tenant. A value
+	// of zero indicates the number of devices is not capped.
+	MaxDevices int
+
+	// Number of devices with unexpired device certificates.
+	ActiveDevices int
+
+	// Where the device cap was determined from - default, config or tenant.
+	Source string
+}
+
+// Quota settings persisted in the certificate store for a tenant.
+type tenantQuotaEntry struct {
+	MaxDevices int `json:"max_devices"`
+}
+
+// Cached device cap and device counts for a tenant.
+type tenantState struct {
+	// Device cap for the tenant and where it was determined from.
+	maxDevices int
+	source     string
+
+	// Number of active devices counted in the certificate store when the
+	// state was refreshed, plus devices recorded since.
+	activeDevices int
+
+	// Capacity reserved for devices which are being enrolled.
+	reservedDevices int
+
+	// When the state was last refreshed from the certificate store. A zero
+	// value forces a refresh.
+	refreshedAt time.Time
+}
+
+// Manager - tracks issued devices and enforces per-tenant device caps.
+type Manager struct {
+	// Certificate store used to persist device records and tenant quotas.
+	store certstore.CertStore
+
+	// Device quota settings from the configuration file.
+	settings *config.DeviceQuota
+
+	// Cached state for each tenant, protected by the lock.
+	lock    sync.Mutex
+	tenants map[string]*tenantState
+
+	// Returns the current time. Overridden by tests.
+	now func() time.Time
+}
+
+// Reservation - capacity reserved for a new device within a tenant. The
+// reservation must be released once the enrollment completes, whether or not
+// the device was recorded.
+type Reservation struct {
+	manager  *Manager
+	tenantID string
+
+	// Set if the tenant is capped, and capacity was reserved for the device.
+	capped bool
+
+	// ID of the device, if the reservation is for a device which is already
+	// recorded within the tenant and therefore counted against the device cap.
+	deviceID string
+
+	// Set once the device has been recorded or the reservation released.
+	done bool
+}
+
+// NewManager - initialize a new quota manager using the specified certificate
+// store and device quota settings.
+func NewManager(logger *zap.Logger, store certstore.CertStore,
+	settings *config.DeviceQuota) *Manager {
+	caLogger = logger
+	return &Manager{
+		store:    store,
+		settings: settings,
+		tenants:  map[string]*tenantState{},
+		now:      time.Now,
+	}
+}
+
+// getMaxDevices - determine the device cap for the specified tenant and
+// where it was determined from.
+func (m *Manager) getMaxDevices(ctx context.Context,
+	tenantID string) (int, string, error) {
+	record, err := m.store.GetRecord(ctx, common.RecordKindTenantQuota, "",
+		tenantID)
+	if err == nil {
+		var entry tenantQuotaEntry
+		err = json.Unmarshal(record.Data, &entry)
+		if err != nil {
+			caLogger.Error("Failed to decode the quota for the tenant!",
+				zap.String("Tenant ID:", tenantID),
+				zap.Error(err),
+			)
+			return 0, "", err
+		}
+		return entry.MaxDevices, QuotaSourceTenant, nil
+	}
+	if !errors.Is(err, common.ErrRecordNotFound) {
+		caLogger.Error("Failed to retrieve the quota for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return 0, "", err
+	}
+
+	if maxDevices, ok := m.settings.TenantMaxDevices[tenantID]; ok {
+		return maxDevices, QuotaSourceConfig, nil
+	}
+	return m.settings.DefaultMaxDevices, QuotaSourceDefault, nil
+}
+
+// GetTenantQuota - returns the device quota for the specified tenant, along
+// with the number of devices currently active within the tenant.
+func (m *Manager) GetTenantQuota(ctx context.Context,
+	tenantID string) (*TenantQuota, error) {
+	maxDevices, source, err := m.getMaxDevices(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	activeDevices, err := m.store.CountRecords(ctx, common.RecordKindDevice,
+		tenantID)
+	if err != nil {
+		caLogger.Error("Failed to count the active devices for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return &TenantQuota{
+		TenantID:      tenantID,
+		MaxDevices:    maxDevices,
+		ActiveDevices: activeDevices,
+		Source:        source,
+	}, nil
+}
+
+// SetTenantQuota - sets the device cap for the specified tenant. This takes
+// precedence over the device caps specified in the configuration file.
+func (m *Manager) SetTenantQuota(ctx context.Context,
+	tenantID string, maxDevices int) error {
+	if (tenantID == "") || (maxDevices < 0) {
+		return ErrInvalidQuota
+	}
+
+	data, err := json.Marshal(&tenantQuotaEntry{MaxDevices: maxDevices})
+	if err != nil {
+		return err
+	}
+
+	err = m.store.PutRecord(ctx, &common.Record{
+		Kind: common.RecordKindTenantQuota,
+		ID:   tenantID,
+		Data: data,
+	})
+	if err != nil {
+		caLogger.Error("Failed to store the quota for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	m.invalidate(tenantID)
+	caLogger.Info("Updated the device quota for the tenant.",
+		zap.String("Tenant ID:", tenantID),
+		zap.Int("Max devices:", maxDevices),
+	)
+	return nil
+}
+
+// ResetTenantQuota - removes the device cap set for the specified tenant, so
+// that the device caps specified in the configuration file apply.
+func (m *Manager) ResetTenantQuota(ctx context.Context, tenantID string) error {
+	if tenantID == "" {
+		return ErrInvalidQuota
+	}
+
+	err := m.store.DeleteRecord(ctx, common.RecordKindTenantQuota, "", tenantID)
+	if err != nil {
+		caLogger.Error("Failed to reset the quota for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	m.invalidate(tenantID)
+	return nil
+}
+
+// invalidate - forces the cached state for the specified tenant to be
+// refreshed from the certificate store when it is next used.
+func (m *Manager) invalidate(tenantID string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if state, ok := m.tenants[tenantID]; ok {
+		state.refreshedAt = time.Time{}
+	}
+}
+
+// getTenantState - returns the cached state for the specified tenant,
+// refreshing it from the certificate store if it is stale. Must be called
+// with the lock held. The lock is released while the certificate store is
+// queried.
+func (m *Manager) getTenantState(ctx context.Context,
+	tenantID string) (*tenantState, error) {
+	state, ok := m.tenants[tenantID]
+	if ok && (m.now().Sub(state.refreshedAt) < tenantRefreshInterval) {
+		return state, nil
+	}
+
+	m.lock.Unlock()
+	maxDevices, source, activeDevices, err := m.loadTenantState(ctx, tenantID)
+	m.lock.Lock()
+	if err != nil {
+		return nil, err
+	}
+
+	// The state may have been created or refreshed by a concurrent request
+	// while the lock was released. Reservations held against the state are
+	// retained.
+	state, ok = m.tenants[tenantID]
+	if !ok {
+		state = &tenantState{}
+		m.tenants[tenantID] = state
+	}
+	state.maxDevices = maxDevices
+	state.source = source
+	state.activeDevices = activeDevices
+	state.refreshedAt = m.now()
+	return state, nil
+}
+
+// loadTenantState - determines the device cap for the specified tenant and
+// counts its active devices.
+func (m *Manager) loadTenantState(ctx context.Context,
+	tenantID string) (int, string, int, error) {
+	maxDevices, source, err := m.getMaxDevices(ctx, tenantID)
+	if err != nil {
+		return 0, "", 0, err
+	}
+
+	activeDevices, err := m.store.CountRecords(ctx, common.RecordKindDevice,
+		tenantID)
+	if err != nil {
+		caLogger.Error("Failed to count the active devices for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Error(err),
+		)
+		return 0, "", 0, err
+	}
+	return maxDevices, source, activeDevices, nil
+}
+
+// ReserveDevice - reserves capacity for a new device to be enrolled within
+// the specified tenant. ErrDeviceCapReached is returned if the tenant already
+// has the maximum number of active devices permitted by its quota, including
+// devices which are being enrolled.
+func (m *Manager) ReserveDevice(ctx context.Context,
+	tenantID string) (*Reservation, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	state, err := m.getTenantState(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	reservation := &Reservation{manager: m, tenantID: tenantID}
+
+	// The number of devices within the tenant is not capped.
+	if state.maxDevices == 0 {
+		return reservation, nil
+	}
+
+	if state.activeDevices+state.reservedDevices >= state.maxDevices {
+		caLogger.Info("Device cap reached for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.Int("Max devices:", state.maxDevices),
+			zap.Int("Active devices:", state.activeDevices),
+			zap.Int("Reserved devices:", state.reservedDevices),
+		)
+		return nil, ErrDeviceCapReached
+	}
+
+	state.reservedDevices++
+	reservation.capped = true
+	return reservation, nil
+}
+
+// ReserveRenewal - reserves capacity for a device certificate to be issued to
+// the specified device within the tenant, in exchange for its existing device
+// certificate. Devices which are already recorded within the tenant are
+// already counted against the tenant's device cap, so capacity is only
+// reserved for devices which are not. ErrDeviceCapReached is returned if
+// capacity cannot be reserved for the device.
+func (m *Manager) ReserveRenewal(ctx context.Context,
+	tenantID string, deviceID string) (*Reservation, error) {
+	existingDevice, err := m.HasDevice(ctx, tenantID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if existingDevice {
+		return &Reservation{manager: m, tenantID: tenantID,
+			deviceID: deviceID}, nil
+	}
+	return m.ReserveDevice(ctx, tenantID)
+}
+
+// RecordDevice - records that a device certificate expiring at the specified
+// time was issued to the device for which capacity was reserved.
+func (r *Reservation) RecordDevice(ctx context.Context, deviceID string,
+	expiresAt time.Time) error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+
+	err := r.manager.putDeviceRecord(ctx, r.tenantID, deviceID, expiresAt)
+
+	// Renewals of devices which are already recorded do not change the number
+	// of active devices within the tenant.
+	r.manager.lock.Lock()
+	defer r.manager.lock.Unlock()
+	if state, ok := r.manager.tenants[r.tenantID]; ok {
+		if r.capped {
+			state.reservedDevices--
+		}
+		if (err == nil) && (r.deviceID != deviceID) {
+			state.activeDevices++
+		}
+	}
+	return err
+}
+
+// Release - releases the capacity reserved for the device, if the device was
+// not recorded.
+func (r *Reservation) Release() {
+	if r.done {
+		return
+	}
+	r.done = true
+	if !r.capped {
+		return
+	}
+
+	r.manager.lock.Lock()
+	defer r.manager.lock.Unlock()
+	if state, ok := r.manager.tenants[r.tenantID]; ok {
+		state.reservedDevices--
+	}
+}
+
+// HasDevice - checks whether the specified device has an unexpired device
+// certificate within the tenant, and is therefore already counted against the
+// tenant's device cap.
+func (m *Manager) HasDevice(ctx context.Context,
+	tenantID string, deviceID string) (bool, error) {
+	_, err := m.store.GetRecord(ctx, common.RecordKindDevice, tenantID, deviceID)
+	if err != nil {
+		if errors.Is(err, common.ErrRecordNotFound) {
+			return false, nil
+		}
+		caLogger.Error("Failed to look up the device within the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Device ID:", deviceID),
+			zap.Error(err),
+		)
+		return false, err
+	}
+	return true, nil
+}
+
+// putDeviceRecord - stores the record of a device issued within the tenant,
+// which expires with its device certificate.
+func (m *Manager) putDeviceRecord(ctx context.Context,
+	tenantID string, deviceID string, expiresAt time.Time) error {
+	err := m.store.PutRecord(ctx, &common.Record{
+		Kind:      common.RecordKindDevice,
+		Scope:     tenantID,
+		ID:        deviceID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		caLogger.Error("Failed to record the device issued within the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Device ID:", deviceID),
+			zap.Error(err),
+		)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"go.uber.org/zap"
+)
+
+// A certificate store which keeps records in memory, and counts the calls
+// made to it.
+type fakeStore struct {
+	certstore.CertStore
+	lock    sync.Mutex
+	records map[string]*common.Record
+	puts    int
+	counts  int
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{records: map[string]*common.Record{}}
+}
+
+func fakeRecordKey(kind string, scope string, id string) string {
+	return kind + "/" + scope + "/" + id
+}
+
+func (s *fakeStore) GetRecord(ctx context.Context, kind string, scope string,
+	id string) (*common.Record, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	record, ok := s.records[fakeRecordKey(kind, scope, id)]
+	if !ok {
+		return nil, common.ErrRecordNotFound
+	}
+	return record, nil
+}
+
+func (s *fakeStore) PutRecord(ctx context.Context, record *common.Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.puts++
+	s.records[fakeRecordKey(record.Kind, record.Scope, record.ID)] = record
+	return nil
+}
+
+func (s *fakeStore) DeleteRecord(ctx context.Context, kind string, scope string,
+	id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.records, fakeRecordKey(kind, scope, id))
+	return nil
+}
+
+func (s *fakeStore) CountRecords(ctx context.Context, kind string,
+	scope string) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.counts++
+	count := 0
+	for _, record := range s.records {
+		if (record.Kind == kind) && (record.Scope == scope) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func newTestManager(store *fakeStore, settings *config.DeviceQuota) *Manager {
+	return NewManager(zap.NewNop(), store, settings)
+}
+
+func TestManager_Uncapped(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore()
+	m := newTestManager(store, &config.DeviceQuota{})
+
+	// Devices are recorded while the tenant is not capped, but any number of
+	// devices may be enrolled.
+	for i := 0; i < 10; i++ {
+		reservation, err := m.ReserveDevice(ctx, "tenant-1")
+		if err != nil {
+			t.Fatalf("Failed to reserve a device: %v", err)
+		}
+		err = reservation.RecordDevice(ctx, fmt.Sprintf("device-%d", i),
+			time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to record the device: %v", err)
+		}
+		reservation.Release()
+	}
+	quota, err := m.GetTenantQuota(ctx, "tenant-1")
+	if (err != nil) || (quota.ActiveDevices != 10) || (quota.MaxDevices != 0) {
+		t.Errorf("Unexpected quota for the tenant: %+v, %v", quota, err)
+	}
+
+	// Devices enrolled before the tenant was capped count against the cap.
+	if err = m.SetTenantQuota(ctx, "tenant-1", 10); err != nil {
+		t.Fatalf("Failed to set the quota for the tenant: %v", err)
+	}
+	if _, err = m.ReserveDevice(ctx, "tenant-1"); !errors.Is(err,
+		ErrDeviceCapReached) {
+		t.Errorf("Expected the device cap to be reached, got %v", err)
+	}
+}
+
+func TestManager_DeviceCap(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore()
+	m := newTestManager(store, &config.DeviceQuota{
+		TenantMaxDevices: map[string]int{"tenant-1": 2},
+	})
+
+	// Capacity is reserved for devices being enrolled, so concurrent
+	// enrollments cannot exceed the cap.
+	first, err := m.ReserveDevice(ctx, "tenant-1")
+	if err != nil {
+		t.Fatalf("Failed to reserve a device: %v", err)
+	}
+	second, err := m.ReserveDevice(ctx, "tenant-1")
+	if err != nil {
+		t.Fatalf("Failed to reserve a device: %v", err)
+	}
+	if _, err = m.ReserveDevice(ctx, "tenant-1"); !errors.Is(err,
+		ErrDeviceCapReached) {
+		t.Fatalf("Expected the device cap to be reached, got %v", err)
+	}
+
+	// Released reservations return their capacity.
+	second.Release()
+	second, err = m.ReserveDevice(ctx, "tenant-1")
+	if err != nil {
+		t.Fatalf("Failed to reserve a device: %v", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	for i, reservation := range []*Reservation{first, second} {
+		err = reservation.RecordDevice(ctx, []string{"d1", "d2"}[i], expiresAt)
+		if err != nil {
+			t.Fatalf("Failed to record the device: %v", err)
+		}
+		reservation.Release()
+	}
+	if _, err = m.ReserveDevice(ctx, "tenant-1"); !errors.Is(err,
+		ErrDeviceCapReached) {
+		t.Fatalf("Expected the device cap to be reached, got %v", err)
+	}
+
+	// The devices are counted once, when the state of the tenant is loaded.
+	if (store.puts != 2) || (store.counts != 1) {
+		t.Errorf("Expected 2 puts and 1 count, got %d puts and %d counts",
+			store.puts, store.counts)
+	}
+
+	// Renewals of recorded devices do not count against the cap, but renewals
+	// of unknown devices do.
+	renewal, err := m.ReserveRenewal(ctx, "tenant-1", "d1")
+	if err != nil {
+		t.Fatalf("Failed to reserve the renewal: %v", err)
+	}
+	if err = renewal.RecordDevice(ctx, "d1", expiresAt); err != nil {
+		t.Fatalf("Failed to renew the device: %v", err)
+	}
+	if _, err = m.ReserveRenewal(ctx, "tenant-1", "d3"); !errors.Is(err,
+		ErrDeviceCapReached) {
+		t.Fatalf("Expected the device cap to be reached, got %v", err)
+	}
+	quota, err := m.GetTenantQuota(ctx, "tenant-1")
+	if (err != nil) || (quota.ActiveDevices != 2) ||
+		(quota.Source != QuotaSourceConfig) {
+		t.Errorf("Unexpected quota for the tenant: %+v, %v", quota, err)
+	}
+}
+
+func TestManager_Refresh(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := newFakeStore()
+	m := newTestManager(store, &config.DeviceQuota{DefaultMaxDevices: 1})
+	m.now = func() time.Time { return now }
+
+	reservation, err := m.ReserveDevice(ctx, "tenant-1")
+	if err != nil {
+		t.Fatalf("Failed to reserve a device: %v", err)
+	}
+	_ = reservation.RecordDevice(ctx, "d1", now.Add(time.Hour))
+	if _, err = m.ReserveDevice(ctx, "tenant-1"); !errors.Is(err,
+		ErrDeviceCapReached) {
+		t.Fatalf("Expected the device cap to be reached, got %v", err)
+	}
+
+	// Raising the cap for the tenant takes effect immediately.
+	if err = m.SetTenantQuota(ctx, "tenant-1", 2); err != nil {
+		t.Fatalf("Failed to set the quota for the tenant: %v", err)
+	}
+	if _, err = m.ReserveDevice(ctx, "tenant-1"); err != nil {
+		t.Fatalf("Failed to reserve a device: %v", err)
+	}
+
+	// Devices which expire (or are enrolled by other instances) are
+	// accounted for when the device count is refreshed.
+	_ = store.DeleteRecord(ctx, common.RecordKindDevice, "tenant-1", "d1")
+	counts := store.counts
+	now = now.Add(tenantRefreshInterval)
+	if _, err = m.ReserveDevice(ctx, "tenant-1"); err != nil {
+		t.Fatalf("Failed to reserve a device: %v", err)
+	}
+	if store.counts != counts+1 {
+		t.Errorf("Expected the device count to be refreshed")
+	}
+}
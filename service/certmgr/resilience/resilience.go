@@ -0,0 +1,277 @@
+// package github.com/HPInc/krypton-ca/service/certmgr/resilience
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements retries and circuit breaking for calls made by the CA to its
+// dependencies (eg. AWS KMS, Dynamo DB). Calls which fail with a transient
+// error are retried using jittered exponential backoff. If calls to a
+// dependency keep failing, the circuit breaker for the dependency opens and
+// calls fail fast, until a call is allowed through to probe the dependency.
+// Retries, failures and rejected calls are reported using metrics labelled
+// with the name of the dependency and the method invoked.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/metrics"
+)
+
+// ErrCircuitOpen - returned for calls which are rejected without being
+// attempted, because the circuit breaker for the dependency is open.
+var ErrCircuitOpen = errors.New("the circuit breaker is open")
+
+// Policy - settings which control how calls to a dependency are retried, and
+// when the circuit breaker for the dependency opens.
+type Policy struct {
+	// Maximum number of attempts made for a call, including the first
+	// attempt.
+	MaxAttempts int
+
+	// Methods which are not idempotent, and are attempted once. Retrying
+	// such a call after an attempt which reached the dependency could
+	// repeat its side effect (eg. create a second key).
+	NonIdempotent map[string]bool
+
+	// Maximum duration of each attempt. An attempt which times out fails
+	// with a transient error, so that calls which hang are retried and count
+	// towards opening the circuit breaker. Attempts are not timed out if
+	// zero.
+	AttemptTimeout time.Duration
+
+	// Backoff before the first retry of a call. The backoff is doubled for
+	// each subsequent retry, up to MaxBackoff. A random duration up to the
+	// backoff is waited before each retry, so that callers which failed
+	// together do not retry together.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// Number of consecutive calls failing with a transient error, after
+	// which the circuit breaker opens. The circuit breaker is disabled if
+	// zero.
+	FailureThreshold int
+
+	// Duration for which the circuit breaker stays open, before a call is
+	// allowed through to probe the dependency.
+	OpenDuration time.Duration
+}
+
+// Executor - retries calls made to a dependency and fails calls fast while the
+// circuit breaker for the dependency is open. An executor is safe for
+// concurrent use.
+type Executor struct {
+	// Name of the dependency, used to label metrics.
+	name string
+
+	policy Policy
+
+	// Classifies errors returned by the dependency as transient.
+	retryable func(error) bool
+
+	lock sync.Mutex
+
+	// Number of consecutive calls which failed with a transient error.
+	failures int
+
+	// Whether the circuit breaker is open, when it was opened and whether a
+	// call probing the dependency is in flight.
+	open     bool
+	openedAt time.Time
+	probing  bool
+
+	// Returns the current time. Replaced by tests.
+	now func() time.Time
+}
+
+// New - returns an executor for calls made to the dependency with the
+// specified name. Calls failing with errors classified as transient by the
+// specified function are retried, and count towards opening the circuit
+// breaker.
+func New(name string, policy Policy, retryable func(error) bool) *Executor {
+	metrics.MetricCircuitBreakerOpen.WithLabelValues(name).Set(0)
+	return &Executor{
+		name:      name,
+		policy:    policy,
+		retryable: retryable,
+		now:       time.Now,
+	}
+}
+
+// Call - invokes the specified method of the dependency using the executor,
+// and returns its result.
+func Call[T any](ctx context.Context, e *Executor, method string,
+	fn func(context.Context) (T, error)) (T, error) {
+	var result T
+	err := e.Do(ctx, method, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	})
+	return result, err
+}
+
+// Do - invokes the specified method of the dependency, retrying it while it
+// fails with a transient error. Each attempt is passed a context bounded by
+// the attempt timeout. Retries stop once the maximum number of attempts has
+// been made, or the specified context is done. The call is rejected with
+// ErrCircuitOpen while the circuit breaker is open.
+func (e *Executor) Do(ctx context.Context, method string,
+	fn func(context.Context) error) error {
+	if !e.allow() {
+		metrics.MetricCircuitBreakerRejections.WithLabelValues(e.name,
+			method).Inc()
+		return caerrors.Wrap(caerrors.DependencyUnavailable,
+			fmt.Sprintf("%s is unavailable", e.name), ErrCircuitOpen)
+	}
+
+	maxAttempts := e.policy.MaxAttempts
+	if e.policy.NonIdempotent[method] {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var transient bool
+		transient, err = e.attempt(ctx, fn)
+		if (err == nil) || !transient {
+			// The dependency is available, even if the call failed.
+			e.record(false)
+			return err
+		}
+		if (attempt >= maxAttempts) || (ctx.Err() != nil) {
+			break
+		}
+
+		metrics.MetricDependencyRetries.WithLabelValues(e.name, method).Inc()
+		if !sleep(ctx, e.backoff(attempt)) {
+			break
+		}
+	}
+
+	if ctx.Err() != nil {
+		// The caller abandoned the call, which says nothing about the
+		// availability of the dependency.
+		e.abandon()
+		return err
+	}
+	metrics.MetricDependencyFailures.WithLabelValues(e.name, method).Inc()
+	e.record(true)
+	return err
+}
+
+// attempt - makes a single attempt of a call, bounded by the attempt timeout.
+// Returns whether the attempt failed with a transient error. Attempts which
+// time out, rather than being abandoned by the caller, fail with a transient
+// error.
+func (e *Executor) attempt(ctx context.Context,
+	fn func(context.Context) error) (bool, error) {
+	attemptCtx := ctx
+	if e.policy.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, e.policy.AttemptTimeout)
+		defer cancel()
+	}
+
+	err := fn(attemptCtx)
+	if err == nil {
+		return false, nil
+	}
+	timedOut := (ctx.Err() == nil) &&
+		errors.Is(attemptCtx.Err(), context.DeadlineExceeded)
+	return timedOut || e.retryable(err), err
+}
+
+// allow - checks whether a call may be made to the dependency. While the
+// circuit breaker is open, a single call is allowed through to probe the
+// dependency once the circuit breaker has been open for the configured
+// duration.
+func (e *Executor) allow() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if !e.open {
+		return true
+	}
+	if e.probing || (e.now().Sub(e.openedAt) < e.policy.OpenDuration) {
+		return false
+	}
+	e.probing = true
+	return true
+}
+
+// record - records the outcome of a call to the dependency. A successful call
+// closes the circuit breaker. The circuit breaker opens once the configured
+// number of consecutive calls have failed, or if a call probing the dependency
+// fails.
+func (e *Executor) record(failed bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if !failed {
+		e.failures = 0
+		if e.open {
+			e.open = false
+			metrics.MetricCircuitBreakerOpen.WithLabelValues(e.name).Set(0)
+		}
+		e.probing = false
+		return
+	}
+
+	e.failures++
+	if e.policy.FailureThreshold <= 0 {
+		return
+	}
+	if e.probing || (!e.open && (e.failures >= e.policy.FailureThreshold)) {
+		if !e.open {
+			metrics.MetricCircuitBreakerTrips.WithLabelValues(e.name).Inc()
+			metrics.MetricCircuitBreakerOpen.WithLabelValues(e.name).Set(1)
+		}
+		e.open = true
+		e.openedAt = e.now()
+		e.probing = false
+	}
+}
+
+// abandon - records that a call was abandoned by the caller. If the call was
+// probing the dependency, another call is allowed to probe it.
+func (e *Executor) abandon() {
+	e.lock.Lock()
+	e.probing = false
+	e.lock.Unlock()
+}
+
+// backoff - returns the time to wait before retrying a call which failed on
+// the specified attempt.
+func (e *Executor) backoff(attempt int) time.Duration {
+	backoff := e.policy.MaxBackoff
+	if attempt <= 32 {
+		if b := e.policy.BaseBackoff << (attempt - 1); (b > 0) && (b < backoff) {
+			backoff = b
+		}
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return rand.N(backoff) + 1
+}
+
+// sleep - waits for the specified duration. Returns false if the specified
+// context is done before the duration elapses.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
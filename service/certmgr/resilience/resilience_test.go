@@ -0,0 +1,197 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+)
+
+var (
+	errTransient = errors.New("transient error")
+	errPermanent = errors.New("permanent error")
+)
+
+func isTransient(err error) bool {
+	return errors.Is(err, errTransient)
+}
+
+func newTestExecutor() *Executor {
+	return New("test", Policy{
+		MaxAttempts:      3,
+		BaseBackoff:      time.Millisecond,
+		MaxBackoff:       2 * time.Millisecond,
+		FailureThreshold: 2,
+		OpenDuration:     time.Minute,
+	}, isTransient)
+}
+
+func TestExecutor_Retries(t *testing.T) {
+	ctx := context.Background()
+	e := newTestExecutor()
+
+	// Transient errors are retried until the call succeeds.
+	attempts := 0
+	result, err := Call(ctx, e, "Get", func(context.Context) (int, error) {
+		attempts++
+		if attempts < 3 {
+			return 0, errTransient
+		}
+		return 42, nil
+	})
+	if (err != nil) || (result != 42) || (attempts != 3) {
+		t.Errorf("Expected the call to succeed after 3 attempts, got %d, %v after %d attempts",
+			result, err, attempts)
+	}
+
+	// Other errors are not retried.
+	attempts = 0
+	err = e.Do(ctx, "Get", func(context.Context) error {
+		attempts++
+		return errPermanent
+	})
+	if !errors.Is(err, errPermanent) || (attempts != 1) {
+		t.Errorf("Expected the call to fail without retries, got %v after %d attempts",
+			err, attempts)
+	}
+
+	// Retries stop once the maximum number of attempts has been made.
+	attempts = 0
+	err = e.Do(ctx, "Get", func(context.Context) error {
+		attempts++
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) || (attempts != 3) {
+		t.Errorf("Expected the call to fail after 3 attempts, got %v after %d attempts",
+			err, attempts)
+	}
+}
+
+func TestExecutor_CircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	e := newTestExecutor()
+	e.now = func() time.Time { return now }
+
+	fail := func(context.Context) error { return errTransient }
+	for i := 0; i < 2; i++ {
+		if err := e.Do(ctx, "Get", fail); !errors.Is(err, errTransient) {
+			t.Fatalf("Expected the call to fail, got %v", err)
+		}
+	}
+
+	// Calls fail fast while the circuit breaker is open.
+	attempts := 0
+	err := e.Do(ctx, "Get", func(context.Context) error {
+		attempts++
+		return nil
+	})
+	if !errors.Is(err, ErrCircuitOpen) || (attempts != 0) {
+		t.Fatalf("Expected the call to be rejected, got %v after %d attempts",
+			err, attempts)
+	}
+	if caerrors.CategoryOf(err) != caerrors.DependencyUnavailable {
+		t.Errorf("Expected the rejection to be categorized as dependency unavailable")
+	}
+
+	// A failed probe keeps the circuit breaker open.
+	now = now.Add(time.Minute)
+	if err = e.Do(ctx, "Get", fail); !errors.Is(err, errTransient) {
+		t.Fatalf("Expected the probe to fail, got %v", err)
+	}
+	if err = e.Do(ctx, "Get", func(context.Context) error { return nil }); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected the call to be rejected, got %v", err)
+	}
+
+	// A successful probe closes the circuit breaker.
+	now = now.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		if err = e.Do(ctx, "Get", func(context.Context) error { return nil }); err != nil {
+			t.Errorf("Expected the call to succeed, got %v", err)
+		}
+	}
+}
+
+func TestExecutor_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := New("test", Policy{
+		MaxAttempts:      5,
+		BaseBackoff:      time.Hour,
+		MaxBackoff:       time.Hour,
+		FailureThreshold: 1,
+		OpenDuration:     time.Minute,
+	}, isTransient)
+
+	// Retries stop once the context is done.
+	attempts := 0
+	err := e.Do(ctx, "Get", func(context.Context) error {
+		attempts++
+		cancel()
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) || (attempts != 1) {
+		t.Errorf("Expected the call to stop after 1 attempt, got %v after %d attempts",
+			err, attempts)
+	}
+
+	// Abandoned calls do not open the circuit breaker.
+	if err = e.Do(context.Background(), "Get", func(context.Context) error { return nil }); err != nil {
+		t.Errorf("Expected the call to succeed, got %v", err)
+	}
+}
+
+func TestExecutor_AttemptTimeout(t *testing.T) {
+	ctx := context.Background()
+	e := New("test", Policy{
+		MaxAttempts:      2,
+		AttemptTimeout:   time.Millisecond,
+		BaseBackoff:      time.Millisecond,
+		MaxBackoff:       time.Millisecond,
+		FailureThreshold: 1,
+		OpenDuration:     time.Minute,
+	}, isTransient)
+
+	// Attempts which hang are timed out and retried.
+	attempts := 0
+	err := e.Do(ctx, "Get", func(ctx context.Context) error {
+		attempts++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || (attempts != 2) {
+		t.Errorf("Expected the call to time out after 2 attempts, got %v after %d attempts",
+			err, attempts)
+	}
+
+	// Calls which time out open the circuit breaker.
+	if err = e.Do(ctx, "Get", func(context.Context) error { return nil }); !errors.Is(err,
+		ErrCircuitOpen) {
+		t.Errorf("Expected the call to be rejected, got %v", err)
+	}
+}
+
+func TestExecutor_NonIdempotent(t *testing.T) {
+	ctx := context.Background()
+	e := newTestExecutor()
+	e.policy.NonIdempotent = map[string]bool{"Create": true}
+
+	// Calls to methods which are not idempotent are not retried, but count
+	// towards opening the circuit breaker.
+	for i := 0; i < 2; i++ {
+		attempts := 0
+		err := e.Do(ctx, "Create", func(context.Context) error {
+			attempts++
+			return errTransient
+		})
+		if !errors.Is(err, errTransient) || (attempts != 1) {
+			t.Errorf("Expected the call to fail without retries, got %v after %d attempts",
+				err, attempts)
+		}
+	}
+	if err := e.Do(ctx, "Get", func(context.Context) error { return nil }); !errors.Is(err,
+		ErrCircuitOpen) {
+		t.Errorf("Expected the call to be rejected, got %v", err)
+	}
+}
@@ -23,10 +23,15 @@ const (
 	TenantDeviceCertificateIssuer       = "Device Certificate Issuer: %s"
 
 	// Key Management Service (KMS) provider types.
-	KmsProviderLocal = "local_kms"
-	KmsProviderAws   = "aws_kms"
+	KmsProviderLocal  = "local_kms"
+	KmsProviderAws    = "aws_kms"
+	KmsProviderPkcs11 = "pkcs11_kms"
+	KmsProviderVault  = "vault_transit"
+	KmsProviderGcp    = "gcp_kms"
+	KmsProviderAzure  = "azure_keyvault"
 
 	// Certificate store provider types
 	CertStoreLocalDb  = "localdb"
 	CertStoreDynamoDb = "dynamodb"
+	CertStorePostgres = "postgres"
 )
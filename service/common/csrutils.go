@@ -12,6 +12,7 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"errors"
+	"fmt"
 
 	"go.uber.org/zap"
 )
@@ -47,20 +48,20 @@ func ParseDeviceCertificateSigningRequest(caLogger *zap.Logger,
 	parsedCSR, err := x509.ParseCertificateRequest(deviceCSR)
 	if err != nil {
 		caLogger.Error("Failed to parse the specified CSR.")
-		return nil, errors.New("failed to parse csr")
+		return nil, fmt.Errorf("%w: failed to parse csr", ErrInvalidCSR)
 	}
 
 	// Check the signature of the specified CSR.
 	err = parsedCSR.CheckSignature()
 	if err != nil {
 		caLogger.Error("Failed to check the signature of the specified CSR.")
-		return nil, errors.New("failed to check csr signature")
+		return nil, fmt.Errorf("%w: failed to check csr signature", ErrInvalidCSR)
 	}
 
 	err = validateCertificateSigningRequest(caLogger, parsedCSR)
 	if err != nil {
 		caLogger.Error("Validation checks failed for the specified CSR.")
-		return nil, errors.New("failed to validate csr")
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSR, err)
 	}
 
 	return parsedCSR, nil
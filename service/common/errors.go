@@ -3,20 +3,50 @@
 // Component: Krypton Certificate Authority
 // (C) HP Development Company, LP
 // Purpose:
-// Errors returned by various components of the CA.
+// Errors returned by various components of the CA. Each error is assigned a
+// category, which is used to determine the status reported to callers.
 package common
 
-import "errors"
+import "github.com/HPInc/krypton-ca/service/caerrors"
 
 var (
 	// The requested signing certificate was not found in the certificate store
-	ErrCertStoreNotFound = errors.New("certificate not found in store")
+	ErrCertStoreNotFound = caerrors.New(caerrors.NotFound,
+		"certificate not found in store")
+
+	// The requested record was not found in the certificate store.
+	ErrRecordNotFound = caerrors.New(caerrors.NotFound,
+		"record not found in store")
+
+	// A record with the specified ID already exists in the certificate store.
+	ErrRecordExists = caerrors.New(caerrors.Conflict,
+		"record already exists in store")
+
+	// A signing certificate already exists for the specified tenant.
+	ErrTenantExists = caerrors.New(caerrors.Conflict,
+		"tenant signing certificate already exists")
+
+	// A required parameter was not specified or was invalid.
+	ErrInvalidParameter = caerrors.New(caerrors.InvalidInput,
+		"invalid parameter")
+
+	// The specified certificate signing request (CSR) could not be parsed or
+	// failed validation checks.
+	ErrInvalidCSR = caerrors.New(caerrors.InvalidInput,
+		"invalid certificate signing request")
+
+	// The KMS throttled the request made by the CA. The request may succeed
+	// if it is retried later.
+	ErrKmsThrottled = caerrors.New(caerrors.DependencyUnavailable,
+		"request throttled by the KMS")
 
 	// The configuration for the CA has requested the use of an invalid or
 	// unsupported certificate store.
-	ErrInvalidCertStore = errors.New("unsupported certificate store provider requested")
+	ErrInvalidCertStore = caerrors.New(caerrors.Internal,
+		"unsupported certificate store provider requested")
 
 	// The configuration for the CA has requested the user of an invalid or
 	// unsupported KMS provider.
-	ErrInvalidKmsProvider = errors.New("unsupported KMS provider requested")
+	ErrInvalidKmsProvider = caerrors.New(caerrors.Internal,
+		"unsupported KMS provider requested")
 )
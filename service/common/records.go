@@ -0,0 +1,141 @@
+// package github.com/HPInc/krypton-ca/service/common
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines the records persisted within the certificate store alongside the
+// signing certificates. Records are used to track state such as the device
+// certificates issued within a tenant, per-tenant quotas and responses to
+// certificate issuance requests retained to detect retried requests, and the
+// accounts, orders and nonces of the ACME server. Records are encoded as JSON
+// objects tagged with the version of the schema used to encode them. Records
+// written by earlier versions of the CA using the GOB encoding are decoded
+// transparently, and are rewritten using the versioned encoding when they are
+// next updated.
+package common
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// Version of the schema used to encode records.
+	RecordSchemaVersion = 1
+
+	// Record kinds stored within the certificate store.
+	RecordKindDevice          = "device"
+	RecordKindTenantQuota     = "tenant_quota"
+	RecordKindIssuanceRequest = "issuance_request"
+
+	// Record kinds used to persist the state of the ACME server.
+	RecordKindAcmeAccount       = "acme_account"
+	RecordKindAcmeOrder         = "acme_order"
+	RecordKindAcmeAuthorization = "acme_authz"
+	RecordKindAcmeNonce         = "acme_nonce"
+)
+
+// Record - represents an entry stored within the certificate store. Records
+// are grouped by kind and scope (eg. device records are scoped to a tenant)
+// and are uniquely identified by their ID within the scope.
+type Record struct {
+	// The kind of record (eg. device).
+	Kind string
+
+	// The scope within which the record is stored (eg. tenant ID).
+	Scope string
+
+	// Unique identifier of the record within its kind and scope.
+	ID string
+
+	// Opaque data stored within the record.
+	Data []byte
+
+	// Time at which the record expires. Expired records are not returned
+	// by the certificate store. A zero value indicates the record does not
+	// expire.
+	ExpiresAt time.Time
+}
+
+// IsExpired checks whether the record has expired at the specified time.
+func (r *Record) IsExpired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && !now.Before(r.ExpiresAt)
+}
+
+// recordEntry - the versioned encoding of a record. The data is encoded using
+// base64.
+type recordEntry struct {
+	SchemaVersion int       `json:"schema_version"`
+	Kind          string    `json:"kind"`
+	Scope         string    `json:"scope"`
+	ID            string    `json:"id"`
+	Data          []byte    `json:"data"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// EncodeRecord - returns the versioned encoding of a record to be stored in
+// the certificate store.
+func EncodeRecord(record *Record) ([]byte, error) {
+	return json.Marshal(recordEntry{
+		SchemaVersion: RecordSchemaVersion,
+		Kind:          record.Kind,
+		Scope:         record.Scope,
+		ID:            record.ID,
+		Data:          record.Data,
+		ExpiresAt:     record.ExpiresAt,
+	})
+}
+
+// DecodeRecord - decodes the encoded entry and returns the record. Both the
+// versioned encoding and the legacy GOB encoding are supported.
+func DecodeRecord(encodedRecord []byte) (*Record, error) {
+	if IsLegacyRecord(encodedRecord) {
+		return decodeLegacyRecord(encodedRecord)
+	}
+
+	var entry recordEntry
+	err := json.Unmarshal(encodedRecord, &entry)
+	if err != nil {
+		return nil, err
+	}
+	if entry.SchemaVersion > RecordSchemaVersion {
+		return nil, fmt.Errorf("unsupported record schema version %d",
+			entry.SchemaVersion)
+	}
+
+	return &Record{
+		Kind:      entry.Kind,
+		Scope:     entry.Scope,
+		ID:        entry.ID,
+		Data:      entry.Data,
+		ExpiresAt: entry.ExpiresAt,
+	}, nil
+}
+
+// IsLegacyRecord - checks whether the encoded record was encoded using the
+// legacy GOB encoding.
+func IsLegacyRecord(encodedRecord []byte) bool {
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	err := json.Unmarshal(encodedRecord, &header)
+	return (err != nil) || (header.SchemaVersion <= 0)
+}
+
+// decodeLegacyRecord - decodes a GOB encoded record written by earlier versions
+// of the CA.
+func decodeLegacyRecord(encodedRecord []byte) (*Record, error) {
+	buffer := bytes.NewReader(encodedRecord)
+	decoder := gob.NewDecoder(buffer)
+
+	record := Record{}
+	err := decoder.Decode(&record)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
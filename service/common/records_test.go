@@ -0,0 +1,80 @@
+package common
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"testing"
+	"time"
+)
+
+var testRecord = Record{
+	Kind:      RecordKindDevice,
+	Scope:     "tenant-1",
+	ID:        "device-1",
+	Data:      []byte("data"),
+	ExpiresAt: time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC),
+}
+
+// Checks that the decoded record matches the test record.
+func checkRecord(t *testing.T, record *Record) {
+	if (record.Kind != testRecord.Kind) || (record.Scope != testRecord.Scope) ||
+		(record.ID != testRecord.ID) ||
+		!bytes.Equal(record.Data, testRecord.Data) ||
+		!record.ExpiresAt.Equal(testRecord.ExpiresAt) {
+		t.Errorf("Unexpected record decoded: %+v", record)
+	}
+}
+
+func TestRecord_RoundTrip(t *testing.T) {
+	encodedRecord, err := EncodeRecord(&testRecord)
+	if err != nil {
+		t.Fatalf("Failed to encode the record: %v", err)
+	}
+	if IsLegacyRecord(encodedRecord) {
+		t.Errorf("Expected the versioned encoding to not be reported as legacy")
+	}
+
+	record, err := DecodeRecord(encodedRecord)
+	if err != nil {
+		t.Fatalf("Failed to decode the record: %v", err)
+	}
+	checkRecord(t, record)
+
+	// Records which do not expire are decoded with a zero expiry time.
+	encodedRecord, err = EncodeRecord(&Record{Kind: RecordKindTenantQuota,
+		Scope: "tenant-1", ID: "quota"})
+	if err != nil {
+		t.Fatalf("Failed to encode the record: %v", err)
+	}
+	record, err = DecodeRecord(encodedRecord)
+	if (err != nil) || !record.ExpiresAt.IsZero() {
+		t.Errorf("Expected the record to not expire, got %+v (error: %v)",
+			record, err)
+	}
+}
+
+func TestRecord_Legacy(t *testing.T) {
+	// Encode the record as earlier versions of the CA did.
+	buffer := &bytes.Buffer{}
+	if err := gob.NewEncoder(buffer).Encode(&testRecord); err != nil {
+		t.Fatalf("Failed to GOB encode the record: %v", err)
+	}
+	if !IsLegacyRecord(buffer.Bytes()) {
+		t.Errorf("Expected the GOB encoding to be reported as legacy")
+	}
+
+	record, err := DecodeRecord(buffer.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode the legacy record: %v", err)
+	}
+	checkRecord(t, record)
+}
+
+func TestRecord_UnsupportedVersion(t *testing.T) {
+	encodedRecord := fmt.Appendf(nil, `{"schema_version":%d,"kind":"device"}`,
+		RecordSchemaVersion+1)
+	if _, err := DecodeRecord(encodedRecord); err == nil {
+		t.Errorf("Expected a newer schema version to be rejected")
+	}
+}
@@ -3,14 +3,23 @@
 // Component: Krypton Certificate Authority
 // (C) HP Development Company, LP
 // Purpose:
-// Utility functions to GOB encode and decode signing certificate entries. The
-// signing certificates are GOB encoded and stored within the certificate store.
-// The certificates read back from the certificate store are GOB decoded.
+// Utility functions to encode and decode signing certificate entries stored
+// within the certificate store. Entries are encoded as JSON objects tagged with
+// the version of the schema used to encode them. Entries written by earlier
+// versions of the CA using the GOB encoding are decoded transparently, and
+// are rewritten by the certificate stores using the versioned encoding.
 package common
 
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// Version of the schema used to encode signing certificate entries.
+	SigningCertificateSchemaVersion = 1
 )
 
 // SigningCertificate - represents a signing certificate stored within the
@@ -26,23 +35,76 @@ type SigningCertificate struct {
 	Certificate []byte
 }
 
-// EncodeSigningCertificate - returns a gob encoded byte array representation of a
-// signing certificate to be stored in the Bolt DB instance.
+// signingCertificateRecord - the versioned encoding of a signing certificate
+// entry. The certificate is encoded using base64.
+type signingCertificateRecord struct {
+	SchemaVersion int    `json:"schema_version"`
+	TenantID      string `json:"tenant_id"`
+	KmsKeyID      string `json:"kms_key_id"`
+	Certificate   []byte `json:"certificate"`
+}
+
+// EncodeSigningCertificate - returns the versioned encoding of a signing
+// certificate entry to be stored in the certificate store.
 func EncodeSigningCertificate(entry *SigningCertificate) ([]byte, error) {
-	buffer := &bytes.Buffer{}
-	encoder := gob.NewEncoder(buffer)
+	return json.Marshal(signingCertificateRecord{
+		SchemaVersion: SigningCertificateSchemaVersion,
+		TenantID:      entry.TenantID,
+		KmsKeyID:      entry.KmsKeyID,
+		Certificate:   entry.Certificate,
+	})
+}
 
-	err := encoder.Encode(entry)
+// DecodeSigningCertificate - decodes the encoded entry and returns an entry
+// containing information about the tenant's signing certificate. Both the
+// versioned encoding and the legacy GOB encoding are supported.
+func DecodeSigningCertificate(encodedEntry []byte) (*SigningCertificate, error) {
+	if IsLegacySigningCertificate(encodedEntry) {
+		return decodeLegacySigningCertificate(encodedEntry)
+	}
+
+	var record signingCertificateRecord
+	err := json.Unmarshal(encodedEntry, &record)
 	if err != nil {
 		return nil, err
 	}
+	if record.SchemaVersion > SigningCertificateSchemaVersion {
+		return nil, fmt.Errorf("unsupported signing certificate schema version %d",
+			record.SchemaVersion)
+	}
 
+	return &SigningCertificate{
+		TenantID:    record.TenantID,
+		KmsKeyID:    record.KmsKeyID,
+		Certificate: record.Certificate,
+	}, nil
+}
+
+// IsLegacySigningCertificate - checks whether the encoded entry was encoded
+// using the legacy GOB encoding, and needs to be rewritten using the versioned
+// encoding.
+func IsLegacySigningCertificate(encodedEntry []byte) bool {
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	err := json.Unmarshal(encodedEntry, &header)
+	return (err != nil) || (header.SchemaVersion <= 0)
+}
+
+// EncodeLegacySigningCertificate - returns the legacy GOB encoding of a signing
+// certificate entry, which is readable by earlier versions of the CA.
+func EncodeLegacySigningCertificate(entry *SigningCertificate) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	err := gob.NewEncoder(buffer).Encode(entry)
+	if err != nil {
+		return nil, err
+	}
 	return buffer.Bytes(), nil
 }
 
-// DecodeSigningCertificate - decodes the gob encoded entry and returns an entry
-// containing information about the tenant's signing certificate.
-func DecodeSigningCertificate(encodedEntry []byte) (*SigningCertificate, error) {
+// decodeLegacySigningCertificate - decodes a GOB encoded entry written by
+// earlier versions of the CA.
+func decodeLegacySigningCertificate(encodedEntry []byte) (*SigningCertificate, error) {
 	buffer := bytes.NewReader(encodedEntry)
 	decoder := gob.NewDecoder(buffer)
 
@@ -0,0 +1,70 @@
+package common
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"testing"
+)
+
+var testSigningCertificate = SigningCertificate{
+	TenantID:    "tenant-1",
+	KmsKeyID:    "key-1",
+	Certificate: []byte("certificate"),
+}
+
+// Returns the signing certificate encoded as earlier versions of the CA did.
+func encodeLegacySigningCertificate(t *testing.T, entry *SigningCertificate) []byte {
+	buffer := &bytes.Buffer{}
+	if err := gob.NewEncoder(buffer).Encode(entry); err != nil {
+		t.Fatalf("Failed to GOB encode the signing certificate: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+// Checks that the decoded signing certificate matches the test certificate.
+func checkSigningCertificate(t *testing.T, entry *SigningCertificate) {
+	if (entry.TenantID != testSigningCertificate.TenantID) ||
+		(entry.KmsKeyID != testSigningCertificate.KmsKeyID) ||
+		!bytes.Equal(entry.Certificate, testSigningCertificate.Certificate) {
+		t.Errorf("Unexpected signing certificate decoded: %+v", entry)
+	}
+}
+
+func TestSigningCertificate_RoundTrip(t *testing.T) {
+	encodedEntry, err := EncodeSigningCertificate(&testSigningCertificate)
+	if err != nil {
+		t.Fatalf("Failed to encode the signing certificate: %v", err)
+	}
+	if IsLegacySigningCertificate(encodedEntry) {
+		t.Errorf("Expected the versioned encoding to not be reported as legacy")
+	}
+
+	entry, err := DecodeSigningCertificate(encodedEntry)
+	if err != nil {
+		t.Fatalf("Failed to decode the signing certificate: %v", err)
+	}
+	checkSigningCertificate(t, entry)
+}
+
+func TestSigningCertificate_Legacy(t *testing.T) {
+	encodedEntry := encodeLegacySigningCertificate(t, &testSigningCertificate)
+	if !IsLegacySigningCertificate(encodedEntry) {
+		t.Errorf("Expected the GOB encoding to be reported as legacy")
+	}
+
+	entry, err := DecodeSigningCertificate(encodedEntry)
+	if err != nil {
+		t.Fatalf("Failed to decode the legacy signing certificate: %v", err)
+	}
+	checkSigningCertificate(t, entry)
+}
+
+func TestSigningCertificate_UnsupportedVersion(t *testing.T) {
+	encodedEntry := fmt.Appendf(nil,
+		`{"schema_version":%d,"tenant_id":"tenant-1"}`,
+		SigningCertificateSchemaVersion+1)
+	if _, err := DecodeSigningCertificate(encodedEntry); err == nil {
+		t.Errorf("Expected a newer schema version to be rejected")
+	}
+}
@@ -22,6 +22,209 @@ type Server struct {
 
 	// Specifies whether to log all incoming REST requests to the debug log.
 	DebugLogRestRequests bool `yaml:"log_rest_requests"`
+
+	// Paths to the TLS certificate and private key used by the REST server.
+	// If specified, the REST server serves requests over HTTPS and requests
+	// client certificates from callers.
+	RestTlsCertFile string `yaml:"rest_tls_cert_file"`
+	RestTlsKeyFile  string `yaml:"rest_tls_key_file"`
+
+	// Path to a PEM file containing the CA certificates used to verify the
+	// client certificates of administrators calling the REST/JSON gateway
+	// routes which create or delete tenants and set their quotas. These
+	// routes are only served over TLS, and only if this is specified.
+	RestClientCAFile string `yaml:"rest_client_ca_file"`
+}
+
+// Est represents configuration settings for the EST (RFC 7030) enrollment
+// endpoints served by the REST server.
+type Est struct {
+	// Whether the EST enrollment endpoints are enabled.
+	Enabled bool `yaml:"enabled"`
+
+	// Populated after reading the CA_EST_ENROLLMENT_SECRET environment
+	// variable. This secret is used to derive the enrollment password of
+	// each tenant, which devices present using HTTP basic authentication to
+	// enroll. For security reasons, this may not be specified using the
+	// configuration YAML file.
+	EnrollmentSecret string `yaml:"-"`
+}
+
+// Gateway represents configuration settings for the REST/JSON gateway, which
+// exposes the RPCs served by the CA gRPC server at the REST endpoint.
+type Gateway struct {
+	// Whether the REST/JSON gateway is enabled.
+	Enabled bool `yaml:"enabled"`
+}
+
+// RateLimitQuota represents a token bucket quota - the sustained rate at which
+// requests are permitted and the maximum burst of requests allowed at once.
+type RateLimitQuota struct {
+	// Number of requests permitted per second.
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+
+	// Maximum number of requests permitted in a single burst.
+	Burst int `yaml:"burst"`
+}
+
+// RateLimit represents configuration settings used to rate limit requests
+// received by the CA gRPC server. Separate token buckets are maintained for
+// each tenant and for each caller identity.
+type RateLimit struct {
+	// Whether rate limiting of RPC requests is enabled.
+	Enabled bool `yaml:"enabled"`
+
+	// Default quota applied to each tenant.
+	Tenant RateLimitQuota `yaml:"tenant"`
+
+	// Default quota applied to each caller identity.
+	Caller RateLimitQuota `yaml:"caller"`
+
+	// Per-tenant quotas that override the default tenant quota.
+	TenantOverrides map[string]RateLimitQuota `yaml:"tenant_overrides"`
+}
+
+// DeviceQuota represents configuration settings used to cap the number of
+// active device certificates issued within each tenant. Caps set for a tenant
+// using the SetTenantQuota RPC take precedence over these settings.
+type DeviceQuota struct {
+	// Default maximum number of active devices per tenant. A value of zero
+	// indicates that the number of devices is not capped.
+	DefaultMaxDevices int `yaml:"default_max_devices"`
+
+	// Per-tenant device caps that override the default, keyed by tenant ID.
+	TenantMaxDevices map[string]int `yaml:"tenant_max_devices"`
+}
+
+// Idempotency represents configuration settings used to make issuance of
+// device certificates idempotent. Responses to create device certificate
+// requests are retained for the configured window, keyed by the request ID
+// specified by the caller, and returned if the request is retried.
+type Idempotency struct {
+	// Whether retried create device certificate requests are detected.
+	Enabled bool `yaml:"enabled"`
+
+	// Number of seconds for which responses are retained.
+	WindowSeconds int `yaml:"window_seconds"`
+}
+
+// Defaults used for batch issuance and signing settings which are not specified
+// in the configuration file.
+const (
+	DefaultMaxBatchSize            = 100
+	DefaultBatchMaxConcurrency     = 8
+	DefaultMaxConcurrentOperations = 16
+)
+
+// BatchIssuance represents configuration settings for batch issuance of
+// device certificates using the CreateDeviceCertificates and
+// StreamDeviceCertificates RPCs.
+type BatchIssuance struct {
+	// Maximum number of certificate signing requests in a batch. For streamed
+	// batches, this applies to the total across all messages in the stream.
+	// Batches are also bounded by the burst of the rate limits, since each
+	// item is charged against them. Defaults to DefaultMaxBatchSize.
+	MaxBatchSize int `yaml:"max_batch_size"`
+
+	// Maximum number of device certificates signed concurrently for a batch.
+	// Defaults to DefaultBatchMaxConcurrency.
+	MaxConcurrency int `yaml:"max_concurrency"`
+}
+
+// Signing represents configuration settings for signing operations performed
+// by the KMS provider.
+type Signing struct {
+	// Maximum number of signing operations performed concurrently by the KMS
+	// provider. Further signing operations wait for one in progress to
+	// complete. Defaults to DefaultMaxConcurrentOperations.
+	MaxConcurrentOperations int `yaml:"max_concurrent_operations"`
+}
+
+// Acme represents configuration settings for the ACME (RFC 8555) server
+// served by the REST server.
+type Acme struct {
+	// Whether the ACME server is enabled.
+	Enabled bool `yaml:"enabled"`
+
+	// Base URL at which clients reach the ACME server (eg.
+	// https://ca.example.com). If not specified, the base URL is determined
+	// from the requests received by the ACME server.
+	BaseUrl string `yaml:"base_url"`
+
+	// Populated after reading the CA_ACME_TOKEN_SECRET environment variable.
+	// This secret is used to derive the pre-shared tokens that devices use
+	// to respond to token challenges. For security reasons, this may not be
+	// specified using the configuration YAML file.
+	TokenSecret string `yaml:"-"`
+}
+
+// Scep represents configuration settings for the SCEP (RFC 8894) responder
+// served by the REST server.
+type Scep struct {
+	// Whether the SCEP responder is enabled.
+	Enabled bool `yaml:"enabled"`
+
+	// Certificate and RSA private key of the registration authority (RA).
+	// Devices encrypt their certificate signing requests using the RA
+	// certificate, and responses are signed using the RA key.
+	RaCertFile string `yaml:"ra_cert_file"`
+	RaKeyFile  string `yaml:"ra_key_file"`
+
+	// Populated after reading the CA_SCEP_CHALLENGE_SECRET environment
+	// variable. This secret is used to derive the challenge password of
+	// each tenant. For security reasons, this may not be specified using the
+	// configuration YAML file.
+	ChallengeSecret string `yaml:"-"`
+}
+
+// Cache represents configuration settings for the in-memory caching of signing
+// certificates read from the certificate store, and of the signers used to
+// sign certificates using keys held within the KMS.
+type Cache struct {
+	// Whether caching is enabled.
+	Enabled bool `yaml:"enabled"`
+
+	// Maximum number of entries held within each cache.
+	MaxEntries int `yaml:"max_entries"`
+
+	// Time for which entries are cached, in seconds. Cached entries are only
+	// invalidated within the instance of the CA which creates or deletes a
+	// signing certificate, so signing certificates created or deleted by
+	// other instances of the CA sharing the certificate store may be used by
+	// this instance for up to this long.
+	TTLSeconds int `yaml:"ttl_seconds"`
+
+	// Time for which tenants found not to have a signing certificate are
+	// cached, in seconds. If zero, such lookups are not cached.
+	NegativeTTLSeconds int `yaml:"negative_ttl_seconds"`
+}
+
+// Backup represents configuration settings for backups of the certificate
+// store.
+type Backup struct {
+	// Directory to which snapshots of the certificate store are written while
+	// the CA is running. Snapshots are written whenever the CA receives
+	// SIGUSR1, and periodically if an interval is specified. Snapshots are
+	// disabled if no directory is specified, or if the certificate store
+	// does not support snapshots.
+	SnapshotDirectory string `yaml:"snapshot_directory"`
+
+	// Interval between periodic snapshots, in minutes. If zero, snapshots are
+	// only written when the CA receives SIGUSR1.
+	SnapshotIntervalMinutes int `yaml:"snapshot_interval_minutes"`
+
+	// Number of the most recent snapshots retained in the snapshot directory.
+	SnapshotsRetained int `yaml:"snapshots_retained"`
+
+	// Path to the PEM encoded root CA certificate. Signing certificates in
+	// archives being imported must chain to this certificate.
+	RootCertFile string `yaml:"root_cert_file"`
+
+	// Populated after reading the CA_BACKUP_SIGNING_KEY environment variable.
+	// This secret is used to sign exported archives and to verify the
+	// signature of archives being imported. For security reasons, this may
+	// not be specified using the configuration YAML file.
+	SigningKey string `yaml:"-"`
 }
 
 // Config represents configuration settings for the CA service.
@@ -47,6 +250,10 @@ type Config struct {
 		// Certificate template configuration settings.
 		common.CertTemplateConfig `yaml:"cert_template"`
 
+		// Configuration settings of KMS providers and certificate stores,
+		// keyed by the name of the provider. See ProviderSchema.
+		Providers map[string]interface{} `yaml:"providers"`
+
 		// Populated after reading the AWS_ACCESS_KEY_ID environment
 		// variable. For security reasons, this may not be specified using
 		// the configuration YAML file.
@@ -58,6 +265,39 @@ type Config struct {
 		AwsSecretAccessKey string
 	} `yaml:"certificate_authority"`
 
+	// Rate limiting configuration settings for the gRPC server.
+	RateLimit RateLimit `yaml:"rate_limit"`
+
+	// Per-tenant device quota configuration settings.
+	DeviceQuota DeviceQuota `yaml:"device_quota"`
+
+	// Idempotent certificate issuance configuration settings.
+	Idempotency Idempotency `yaml:"idempotency"`
+
+	// Batch device certificate issuance configuration settings.
+	BatchIssuance BatchIssuance `yaml:"batch_issuance"`
+
+	// Signing operation configuration settings.
+	Signing Signing `yaml:"signing"`
+
+	// REST/JSON gateway configuration settings.
+	Gateway Gateway `yaml:"gateway"`
+
+	// EST enrollment configuration settings.
+	Est Est `yaml:"est"`
+
+	// ACME server configuration settings.
+	Acme Acme `yaml:"acme"`
+
+	// SCEP responder configuration settings.
+	Scep Scep `yaml:"scep"`
+
+	// Certificate store backup configuration settings.
+	Backup Backup `yaml:"backup"`
+
+	// Signing certificate and signer cache configuration settings.
+	Cache Cache `yaml:"cache"`
+
 	// Whether the CA is configured to run in test mode.
 	TestMode bool `yaml:"test_mode"`
 }
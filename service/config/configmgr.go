@@ -76,9 +76,23 @@ func (c *ConfigMgr) Load(testModeEnabled bool) bool {
 	_ = fh.Close()
 	fmt.Printf("Parsed configuration from the configuration file: %s!\n", filename)
 
+	// Decode the settings of registered KMS providers and certificate stores.
+	err = c.loadProviderSettings()
+	if err != nil {
+		fmt.Printf("Failed to load provider settings from configuration file: %s. Error: %v\n!",
+			filename, err)
+		return false
+	}
+
 	// Load any configuration overrides specified using environment variables.
-	err = c.loadEnvironmentVariableOverrides()
+	c.loadEnvironmentVariableOverrides()
+
+	// Check that the requested KMS provider and certificate store have been
+	// registered, and validate their settings.
+	err = c.validateProviderSettings()
 	if err != nil {
+		fmt.Printf("Configuration settings for the KMS provider or certificate store are invalid! Error: %v\n",
+			err)
 		return false
 	}
 
@@ -88,6 +102,72 @@ func (c *ConfigMgr) Load(testModeEnabled bool) bool {
 		return false
 	}
 
+	// Validate the provided rate limiting settings.
+	if !c.validateRateLimitSettings() {
+		fmt.Printf("Configuration settings for rate limiting are invalid! Cannot continue.")
+		return false
+	}
+
+	// Validate the provided device quota settings.
+	if !c.validateDeviceQuotaSettings() {
+		fmt.Printf("Configuration settings for device quotas are invalid! Cannot continue.")
+		return false
+	}
+
+	// Validate the provided REST server TLS settings.
+	if !c.validateRestTlsSettings() {
+		fmt.Printf("Configuration settings for REST server TLS are invalid! Cannot continue.")
+		return false
+	}
+
+	// Validate the provided EST enrollment settings.
+	if !c.validateEstSettings() {
+		fmt.Printf("Configuration settings for EST enrollment are invalid! Cannot continue.")
+		return false
+	}
+
+	// Validate the provided ACME server settings.
+	if !c.validateAcmeSettings() {
+		fmt.Printf("Configuration settings for the ACME server are invalid! Cannot continue.")
+		return false
+	}
+
+	// Validate the provided SCEP responder settings.
+	if !c.validateScepSettings() {
+		fmt.Printf("Configuration settings for the SCEP responder are invalid! Cannot continue.")
+		return false
+	}
+
+	// Validate the provided idempotency settings.
+	if !c.validateIdempotencySettings() {
+		fmt.Printf("Configuration settings for idempotency are invalid! Cannot continue.")
+		return false
+	}
+
+	// Validate the provided batch issuance settings.
+	if !c.validateBatchIssuanceSettings() {
+		fmt.Printf("Configuration settings for batch issuance are invalid! Cannot continue.")
+		return false
+	}
+
+	// Validate the provided signing settings.
+	if !c.validateSigningSettings() {
+		fmt.Printf("Configuration settings for signing are invalid! Cannot continue.")
+		return false
+	}
+
+	// Validate the provided backup settings.
+	if !c.validateBackupSettings() {
+		fmt.Printf("Configuration settings for backups are invalid! Cannot continue.")
+		return false
+	}
+
+	// Validate the provided cache settings.
+	if !c.validateCacheSettings() {
+		fmt.Printf("Configuration settings for caching are invalid! Cannot continue.")
+		return false
+	}
+
 	c.Display()
 	return true
 }
@@ -119,6 +199,66 @@ func (c *ConfigMgr) IsTestModeEnabled() bool {
 	return c.config.TestMode
 }
 
+// GetRateLimitConfig returns the rate limiting configuration settings for the
+// gRPC server.
+func (c *ConfigMgr) GetRateLimitConfig() *RateLimit {
+	return &c.config.RateLimit
+}
+
+// GetDeviceQuotaConfig returns the per-tenant device quota configuration
+// settings.
+func (c *ConfigMgr) GetDeviceQuotaConfig() *DeviceQuota {
+	return &c.config.DeviceQuota
+}
+
+// GetIdempotencyConfig returns the idempotent certificate issuance
+// configuration settings.
+func (c *ConfigMgr) GetIdempotencyConfig() *Idempotency {
+	return &c.config.Idempotency
+}
+
+// GetBatchIssuanceConfig returns the batch device certificate issuance
+// configuration settings.
+func (c *ConfigMgr) GetBatchIssuanceConfig() *BatchIssuance {
+	return &c.config.BatchIssuance
+}
+
+// GetSigningConfig returns the signing operation configuration settings.
+func (c *ConfigMgr) GetSigningConfig() *Signing {
+	return &c.config.Signing
+}
+
+// GetGatewayConfig returns the REST/JSON gateway configuration settings.
+func (c *ConfigMgr) GetGatewayConfig() *Gateway {
+	return &c.config.Gateway
+}
+
+// GetEstConfig returns the EST enrollment configuration settings.
+func (c *ConfigMgr) GetEstConfig() *Est {
+	return &c.config.Est
+}
+
+// GetAcmeConfig returns the ACME server configuration settings.
+func (c *ConfigMgr) GetAcmeConfig() *Acme {
+	return &c.config.Acme
+}
+
+// GetScepConfig returns the SCEP responder configuration settings.
+func (c *ConfigMgr) GetScepConfig() *Scep {
+	return &c.config.Scep
+}
+
+// GetBackupConfig returns the certificate store backup configuration settings.
+func (c *ConfigMgr) GetBackupConfig() *Backup {
+	return &c.config.Backup
+}
+
+// GetCacheConfig returns the signing certificate and signer cache
+// configuration settings.
+func (c *ConfigMgr) GetCacheConfig() *Cache {
+	return &c.config.Cache
+}
+
 // GetIssuerName returns the certificate authority's issuer name.
 func (c *ConfigMgr) GetIssuerName() string {
 	return c.config.CertificateAuthority.IssuerName
@@ -145,6 +285,142 @@ func (c *ConfigMgr) validateCertificateTemplateSettings() bool {
 	return true
 }
 
+// Validate that the rate limiting quotas specified in the configuration file
+// are usable, if rate limiting has been enabled.
+func (c *ConfigMgr) validateRateLimitSettings() bool {
+	if !c.config.RateLimit.Enabled {
+		return true
+	}
+
+	if !isValidRateLimitQuota(&c.config.RateLimit.Tenant) ||
+		!isValidRateLimitQuota(&c.config.RateLimit.Caller) {
+		return false
+	}
+
+	for _, quota := range c.config.RateLimit.TenantOverrides {
+		quota := quota
+		if !isValidRateLimitQuota(&quota) {
+			return false
+		}
+	}
+	return true
+}
+
+func isValidRateLimitQuota(quota *RateLimitQuota) bool {
+	return (quota.RequestsPerSecond > 0) && (quota.Burst > 0)
+}
+
+// Validate that the device caps specified in the configuration file are not
+// negative.
+func (c *ConfigMgr) validateDeviceQuotaSettings() bool {
+	if c.config.DeviceQuota.DefaultMaxDevices < 0 {
+		return false
+	}
+
+	for _, maxDevices := range c.config.DeviceQuota.TenantMaxDevices {
+		if maxDevices < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate that both the TLS certificate and private key have been specified
+// for the REST server, if either is specified, and that they have been
+// specified if a client CA is configured to authenticate administrators.
+func (c *ConfigMgr) validateRestTlsSettings() bool {
+	if (c.config.Server.RestClientCAFile != "") &&
+		(c.config.Server.RestTlsCertFile == "") {
+		return false
+	}
+	return (c.config.Server.RestTlsCertFile == "") ==
+		(c.config.Server.RestTlsKeyFile == "")
+}
+
+// Validate that the secret used to derive pre-shared tokens has been
+// specified, if the ACME server has been enabled.
+func (c *ConfigMgr) validateAcmeSettings() bool {
+	if !c.config.Acme.Enabled {
+		return true
+	}
+	return c.config.Acme.TokenSecret != ""
+}
+
+// Validate that the REST server uses TLS and that the secret used to derive
+// enrollment passwords has been specified, if EST enrollment has been enabled.
+func (c *ConfigMgr) validateEstSettings() bool {
+	if !c.config.Est.Enabled {
+		return true
+	}
+	return (c.config.Server.RestTlsCertFile != "") &&
+		(c.config.Est.EnrollmentSecret != "")
+}
+
+// Validate that the RA certificate and key, and the secret used to derive
+// challenge passwords have been specified, if the SCEP responder has been
+// enabled.
+func (c *ConfigMgr) validateScepSettings() bool {
+	if !c.config.Scep.Enabled {
+		return true
+	}
+	return (c.config.Scep.RaCertFile != "") &&
+		(c.config.Scep.RaKeyFile != "") &&
+		(c.config.Scep.ChallengeSecret != "")
+}
+
+// Validate that a retention window has been specified for responses, if
+// idempotent certificate issuance has been enabled.
+func (c *ConfigMgr) validateIdempotencySettings() bool {
+	if !c.config.Idempotency.Enabled {
+		return true
+	}
+	return c.config.Idempotency.WindowSeconds > 0
+}
+
+// Validate that the batch size and the number of device certificates signed
+// concurrently for a batch are not negative. Defaults are used for settings
+// which have not been specified.
+func (c *ConfigMgr) validateBatchIssuanceSettings() bool {
+	if c.config.BatchIssuance.MaxBatchSize == 0 {
+		c.config.BatchIssuance.MaxBatchSize = DefaultMaxBatchSize
+	}
+	if c.config.BatchIssuance.MaxConcurrency == 0 {
+		c.config.BatchIssuance.MaxConcurrency = DefaultBatchMaxConcurrency
+	}
+	return (c.config.BatchIssuance.MaxBatchSize > 0) &&
+		(c.config.BatchIssuance.MaxConcurrency > 0)
+}
+
+// Validate that the number of signing operations performed concurrently is not
+// negative. The default is used if it has not been specified.
+func (c *ConfigMgr) validateSigningSettings() bool {
+	if c.config.Signing.MaxConcurrentOperations == 0 {
+		c.config.Signing.MaxConcurrentOperations = DefaultMaxConcurrentOperations
+	}
+	return c.config.Signing.MaxConcurrentOperations > 0
+}
+
+// Validate that the snapshot interval is not negative, and that at least one
+// snapshot is retained, if snapshots have been enabled.
+func (c *ConfigMgr) validateBackupSettings() bool {
+	if c.config.Backup.SnapshotDirectory == "" {
+		return true
+	}
+	return (c.config.Backup.SnapshotIntervalMinutes >= 0) &&
+		(c.config.Backup.SnapshotsRetained > 0)
+}
+
+// Validate that the cache size and TTLs are valid, if caching has been
+// enabled.
+func (c *ConfigMgr) validateCacheSettings() bool {
+	if !c.config.Cache.Enabled {
+		return true
+	}
+	return (c.config.Cache.MaxEntries > 0) &&
+		(c.config.Cache.TTLSeconds > 0) &&
+		(c.config.Cache.NegativeTTLSeconds >= 0)
+}
+
 // Display the configuration information parsed from the configuration file in
 // the structured log.
 func (c *ConfigMgr) Display() {
@@ -157,6 +433,8 @@ func (c *ConfigMgr) Display() {
 		zap.Int(" - RPC Port:", c.config.Server.RpcPort),
 		zap.Int(" - REST Port:", c.config.Server.RestPort),
 		zap.Bool(" - Request logging enabled:", c.config.DebugLogRestRequests),
+		zap.Bool(" - REST TLS enabled:", c.config.Server.RestTlsCertFile != ""),
+		zap.Bool(" - REST client CA configured:", c.config.Server.RestClientCAFile != ""),
 	)
 	caLogger.Info("Certificate authority settings",
 		zap.String(" - KMS provider:", c.config.CertificateAuthority.KmsProvider),
@@ -169,4 +447,53 @@ func (c *ConfigMgr) Display() {
 		zap.String(" - Postal code:", c.config.CertificateAuthority.CertTemplateConfig.PostalCode),
 		zap.String(" - Organization:", c.config.CertificateAuthority.CertTemplateConfig.Organization),
 	)
+	caLogger.Info("Rate limiting settings",
+		zap.Bool(" - Rate limiting enabled:", c.config.RateLimit.Enabled),
+		zap.Float64(" - Tenant requests per second:", c.config.RateLimit.Tenant.RequestsPerSecond),
+		zap.Int(" - Tenant burst:", c.config.RateLimit.Tenant.Burst),
+		zap.Float64(" - Caller requests per second:", c.config.RateLimit.Caller.RequestsPerSecond),
+		zap.Int(" - Caller burst:", c.config.RateLimit.Caller.Burst),
+		zap.Int(" - Tenant overrides:", len(c.config.RateLimit.TenantOverrides)),
+	)
+	caLogger.Info("Device quota settings",
+		zap.Int(" - Default max devices per tenant:", c.config.DeviceQuota.DefaultMaxDevices),
+		zap.Int(" - Tenant overrides:", len(c.config.DeviceQuota.TenantMaxDevices)),
+	)
+	caLogger.Info("Idempotency settings",
+		zap.Bool(" - Idempotent issuance enabled:", c.config.Idempotency.Enabled),
+		zap.Int(" - Response retention window (seconds):", c.config.Idempotency.WindowSeconds),
+	)
+	caLogger.Info("Batch issuance settings",
+		zap.Int(" - Max batch size:", c.config.BatchIssuance.MaxBatchSize),
+		zap.Int(" - Max concurrency:", c.config.BatchIssuance.MaxConcurrency),
+	)
+	caLogger.Info("Signing settings",
+		zap.Int(" - Max concurrent signing operations:", c.config.Signing.MaxConcurrentOperations),
+	)
+	caLogger.Info("Gateway settings",
+		zap.Bool(" - REST/JSON gateway enabled:", c.config.Gateway.Enabled),
+	)
+	caLogger.Info("EST settings",
+		zap.Bool(" - EST enrollment enabled:", c.config.Est.Enabled),
+	)
+	caLogger.Info("ACME settings",
+		zap.Bool(" - ACME server enabled:", c.config.Acme.Enabled),
+		zap.String(" - Base URL:", c.config.Acme.BaseUrl),
+	)
+	caLogger.Info("SCEP settings",
+		zap.Bool(" - SCEP responder enabled:", c.config.Scep.Enabled),
+		zap.String(" - RA certificate file:", c.config.Scep.RaCertFile),
+	)
+	caLogger.Info("Backup settings",
+		zap.String(" - Snapshot directory:", c.config.Backup.SnapshotDirectory),
+		zap.Int(" - Snapshot interval (minutes):", c.config.Backup.SnapshotIntervalMinutes),
+		zap.Int(" - Snapshots retained:", c.config.Backup.SnapshotsRetained),
+		zap.String(" - Root certificate file:", c.config.Backup.RootCertFile),
+	)
+	caLogger.Info("Cache settings",
+		zap.Bool(" - Caching enabled:", c.config.Cache.Enabled),
+		zap.Int(" - Maximum entries:", c.config.Cache.MaxEntries),
+		zap.Int(" - TTL (seconds):", c.config.Cache.TTLSeconds),
+		zap.Int(" - Negative TTL (seconds):", c.config.Cache.NegativeTTLSeconds),
+	)
 }
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	"go.uber.org/zap"
+)
+
+// Remove the specified top-level sections from the test configuration file.
+func removeConfigSections(t *testing.T, sections ...string) {
+	filename := os.Getenv("DSTS_CONFIG_LOCATION")
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read the configuration file: %v", err)
+	}
+
+	var updated []string
+	skipping := false
+	for _, line := range strings.SplitAfter(string(contents), "\n") {
+		if line != "" && line[0] != ' ' && line[0] != '\n' && line[0] != '#' {
+			skipping = false
+			for _, section := range sections {
+				if strings.HasPrefix(line, section+":") {
+					skipping = true
+				}
+			}
+		}
+		if !skipping {
+			updated = append(updated, line)
+		}
+	}
+
+	err = os.WriteFile(filename, []byte(strings.Join(updated, "")), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write the configuration file: %v", err)
+	}
+}
+
+func TestConfigMgr_BatchIssuanceAndSigningDefaults(t *testing.T) {
+	writeTestConfig(t, "https://kms.example.com")
+	removeConfigSections(t, "batch_issuance", "signing")
+
+	cfgMgr := NewConfigMgr(zap.NewNop(), common.ServiceName)
+	if !cfgMgr.Load(true) {
+		t.Fatalf("Failed to load a configuration without batch issuance and signing settings")
+	}
+	batch := cfgMgr.GetBatchIssuanceConfig()
+	if batch.MaxBatchSize != DefaultMaxBatchSize {
+		t.Errorf("Expected max batch size %d, got %d",
+			DefaultMaxBatchSize, batch.MaxBatchSize)
+	}
+	if batch.MaxConcurrency != DefaultBatchMaxConcurrency {
+		t.Errorf("Expected batch concurrency %d, got %d",
+			DefaultBatchMaxConcurrency, batch.MaxConcurrency)
+	}
+	signing := cfgMgr.GetSigningConfig()
+	if signing.MaxConcurrentOperations != DefaultMaxConcurrentOperations {
+		t.Errorf("Expected %d concurrent signing operations, got %d",
+			DefaultMaxConcurrentOperations, signing.MaxConcurrentOperations)
+	}
+}
+
+func TestConfigMgr_NegativeBatchIssuanceAndSigningSettings(t *testing.T) {
+	for _, env := range []string{
+		"CA_BATCH_MAX_SIZE",
+		"CA_BATCH_MAX_CONCURRENCY",
+		"CA_SIGNING_MAX_CONCURRENCY",
+	} {
+		t.Run(env, func(t *testing.T) {
+			writeTestConfig(t, "https://kms.example.com")
+			t.Setenv(env, "-1")
+
+			if NewConfigMgr(zap.NewNop(), common.ServiceName).Load(true) {
+				t.Fatalf("Expected the configuration to be rejected for %s=-1", env)
+			}
+		})
+	}
+}
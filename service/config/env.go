@@ -12,7 +12,6 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/HPInc/krypton-ca/service/common"
 	"go.uber.org/zap"
 )
 
@@ -31,16 +30,78 @@ func overrideFromEnvironment(c *Config) {
 		"CA_RPC_PORT":                {v: &c.Server.RpcPort},
 		"CA_REST_PORT":               {v: &c.Server.RestPort},
 		"CA_DEBUG_LOG_REST_REQUESTS": {v: &c.DebugLogRestRequests},
+		"CA_REST_TLS_CERT_FILE":      {v: &c.Server.RestTlsCertFile},
+		"CA_REST_TLS_KEY_FILE":       {v: &c.Server.RestTlsKeyFile},
+		"CA_REST_CLIENT_CA_FILE":     {v: &c.Server.RestClientCAFile},
 
 		// Certificate authority configuration settings
 		"CA_KMS_PROVIDER":               {v: &c.CertificateAuthority.KmsProvider},
 		"CA_CERT_STORE_PROVIDER":        {v: &c.CertificateAuthority.CertStoreProvider},
 		"CA_PER_TENANT_SIGNING_ENABLED": {v: &c.CertificateAuthority.PerTenantSigningEnabled},
 
+		// Rate limiting configuration settings
+		"CA_RATE_LIMIT_ENABLED":      {v: &c.RateLimit.Enabled},
+		"CA_RATE_LIMIT_TENANT_RPS":   {v: &c.RateLimit.Tenant.RequestsPerSecond},
+		"CA_RATE_LIMIT_TENANT_BURST": {v: &c.RateLimit.Tenant.Burst},
+		"CA_RATE_LIMIT_CALLER_RPS":   {v: &c.RateLimit.Caller.RequestsPerSecond},
+		"CA_RATE_LIMIT_CALLER_BURST": {v: &c.RateLimit.Caller.Burst},
+
+		// Device quota configuration settings
+		"CA_DEFAULT_MAX_DEVICES": {v: &c.DeviceQuota.DefaultMaxDevices},
+
+		// Idempotency configuration settings
+		"CA_IDEMPOTENCY_ENABLED":        {v: &c.Idempotency.Enabled},
+		"CA_IDEMPOTENCY_WINDOW_SECONDS": {v: &c.Idempotency.WindowSeconds},
+
+		// Batch issuance configuration settings
+		"CA_BATCH_MAX_SIZE":        {v: &c.BatchIssuance.MaxBatchSize},
+		"CA_BATCH_MAX_CONCURRENCY": {v: &c.BatchIssuance.MaxConcurrency},
+
+		// Signing configuration settings
+		"CA_SIGNING_MAX_CONCURRENCY": {v: &c.Signing.MaxConcurrentOperations},
+
+		// REST/JSON gateway configuration settings
+		"CA_GATEWAY_ENABLED": {v: &c.Gateway.Enabled},
+
+		// EST enrollment configuration settings
+		"CA_EST_ENABLED":           {v: &c.Est.Enabled},
+		"CA_EST_ENROLLMENT_SECRET": {secret: true, v: &c.Est.EnrollmentSecret},
+
+		// ACME server configuration settings
+		"CA_ACME_ENABLED":      {v: &c.Acme.Enabled},
+		"CA_ACME_BASE_URL":     {v: &c.Acme.BaseUrl},
+		"CA_ACME_TOKEN_SECRET": {secret: true, v: &c.Acme.TokenSecret},
+
+		// SCEP responder configuration settings
+		"CA_SCEP_ENABLED":          {v: &c.Scep.Enabled},
+		"CA_SCEP_RA_CERT_FILE":     {v: &c.Scep.RaCertFile},
+		"CA_SCEP_RA_KEY_FILE":      {v: &c.Scep.RaKeyFile},
+		"CA_SCEP_CHALLENGE_SECRET": {secret: true, v: &c.Scep.ChallengeSecret},
+
+		// Certificate store backup configuration settings
+		"CA_BACKUP_SNAPSHOT_DIRECTORY":        {v: &c.Backup.SnapshotDirectory},
+		"CA_BACKUP_SNAPSHOT_INTERVAL_MINUTES": {v: &c.Backup.SnapshotIntervalMinutes},
+		"CA_BACKUP_ROOT_CERT_FILE":            {v: &c.Backup.RootCertFile},
+		"CA_BACKUP_SIGNING_KEY":               {secret: true, v: &c.Backup.SigningKey},
+
+		// Cache configuration settings
+		"CA_CACHE_ENABLED":              {v: &c.Cache.Enabled},
+		"CA_CACHE_MAX_ENTRIES":          {v: &c.Cache.MaxEntries},
+		"CA_CACHE_TTL_SECONDS":          {v: &c.Cache.TTLSeconds},
+		"CA_CACHE_NEGATIVE_TTL_SECONDS": {v: &c.Cache.NegativeTTLSeconds},
+
 		// Check if test mode needs to be enabled - this may cause certain test hooks
 		// to be enabled - this must not be specified in production.
 		"CA_TEST_MODE": {v: &c.TestMode},
 	}
+
+	// Add environment variables declared by registered providers.
+	for _, schema := range registeredSchemas() {
+		for k, o := range schema.Env {
+			m[k] = value{secret: o.Secret, v: o.Value}
+		}
+	}
+
 	for k, v := range m {
 		e := os.Getenv(k)
 		if e != "" {
@@ -79,6 +140,22 @@ func replaceConfigValue(envValue string, t *value) {
 		} else {
 			*t.v.(*int) = i
 		}
+	case *uint:
+		u, err := strconv.ParseUint(envValue, 10, 0)
+		if err != nil {
+			caLogger.Error("Bad unsigned integer value in env",
+				zap.Error(err))
+		} else {
+			*t.v.(*uint) = uint(u)
+		}
+	case *float64:
+		f, err := strconv.ParseFloat(envValue, 64)
+		if err != nil {
+			caLogger.Error("Bad float value in env",
+				zap.Error(err))
+		} else {
+			*t.v.(*float64) = f
+		}
 	default:
 		caLogger.Error("There was a bad type map in env override",
 			zap.String("value", envValue))
@@ -95,26 +172,9 @@ func getLoggableValue(secret bool, value string) string {
 // loadEnvironmentVariableOverrides - check values specified for supported
 // environment variables. These can be used to override configuration settings
 // specified in the config file.
-func (c *ConfigMgr) loadEnvironmentVariableOverrides() error {
+func (c *ConfigMgr) loadEnvironmentVariableOverrides() {
 
 	// override config from environment variables
 	// note this only happens if environment variables are specified
 	overrideFromEnvironment(&c.config)
-
-	// Determine if the configuration requests a valid KMS provider.
-	switch c.config.CertificateAuthority.KmsProvider {
-	case common.KmsProviderAws, common.KmsProviderLocal:
-		break
-	default:
-		return common.ErrInvalidKmsProvider
-	}
-
-	// Determine if the configuration requests a valid cert store provider.
-	switch c.config.CertificateAuthority.CertStoreProvider {
-	case common.CertStoreDynamoDb, common.CertStoreLocalDb:
-		break
-	default:
-		return common.ErrInvalidCertStore
-	}
-	return nil
 }
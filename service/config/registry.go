@@ -0,0 +1,192 @@
+// package github.com/HPInc/krypton-ca/service/config
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Maintains the registry of KMS providers and certificate stores known to the
+// CA, along with the configuration schema of each provider. Only registered
+// providers can be selected using the configuration. Providers are registered
+// by the kms_providers and certstore packages when the package implementing
+// the provider is imported.
+package config
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	"gopkg.in/yaml.v2"
+)
+
+// ProviderSchema describes the configuration settings consumed by a KMS
+// provider or certificate store. Providers declare their own settings, which
+// are read from the provider's section under certificate_authority/providers
+// in the configuration file. Providers without settings of their own use an
+// empty schema.
+type ProviderSchema struct {
+	// Pointer to a struct into which the provider's section of the
+	// configuration file is decoded. Optional.
+	Settings interface{}
+
+	// Environment variables that can be used to override the provider's
+	// settings, keyed by the name of the environment variable. Optional.
+	Env map[string]EnvOverride
+
+	// Validates the configuration settings of the provider. Only invoked if
+	// the provider has been selected using the configuration. Optional.
+	Validate func(*ConfigMgr) error
+}
+
+// EnvOverride describes a configuration setting that can be overridden using
+// an environment variable.
+type EnvOverride struct {
+	// Pointer to the setting to be overridden. Supported types are *string,
+	// *[]string, *bool, *int, *uint and *float64.
+	Value interface{}
+
+	// Whether the value of the setting must not be logged.
+	Secret bool
+}
+
+var (
+	registryLock       sync.RWMutex
+	kmsProviderSchemas = map[string]ProviderSchema{}
+	certStoreSchemas   = map[string]ProviderSchema{}
+)
+
+// RegisterKmsProvider - register the configuration schema of the KMS provider
+// with the specified name. This is invoked by kms_providers.Register and
+// panics if a KMS provider with the same name has already been registered.
+func RegisterKmsProvider(name string, schema ProviderSchema) {
+	register(kmsProviderSchemas, "kms provider", name, schema)
+}
+
+// RegisterCertStore - register the configuration schema of the certificate
+// store with the specified name. This is invoked by certstore.Register and
+// panics if a certificate store with the same name has already been
+// registered.
+func RegisterCertStore(name string, schema ProviderSchema) {
+	register(certStoreSchemas, "certificate store", name, schema)
+}
+
+func register(schemas map[string]ProviderSchema, kind string, name string,
+	schema ProviderSchema) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := schemas[name]; ok {
+		panic(fmt.Sprintf("duplicate registration of %s %q", kind, name))
+	}
+	schemas[name] = schema
+}
+
+// KmsProviders - returns the names of the registered KMS providers.
+func KmsProviders() []string {
+	return registeredNames(kmsProviderSchemas)
+}
+
+// CertStores - returns the names of the registered certificate stores.
+func CertStores() []string {
+	return registeredNames(certStoreSchemas)
+}
+
+func registeredNames(schemas map[string]ProviderSchema) []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupSchema - returns the schema registered for the provider with the
+// specified name.
+func lookupSchema(schemas map[string]ProviderSchema,
+	name string) (ProviderSchema, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	schema, ok := schemas[name]
+	return schema, ok
+}
+
+// registeredSchemas - returns the schemas of all registered KMS providers and
+// certificate stores.
+func registeredSchemas() []ProviderSchema {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	schemas := make([]ProviderSchema, 0,
+		len(kmsProviderSchemas)+len(certStoreSchemas))
+	for _, schema := range kmsProviderSchemas {
+		schemas = append(schemas, schema)
+	}
+	for _, schema := range certStoreSchemas {
+		schemas = append(schemas, schema)
+	}
+	return schemas
+}
+
+// loadProviderSettings - decode the sections of the configuration file under
+// certificate_authority/providers into the settings of the corresponding
+// registered providers.
+func (c *ConfigMgr) loadProviderSettings() error {
+	for name, section := range c.config.CertificateAuthority.Providers {
+		schema, ok := lookupSchema(kmsProviderSchemas, name)
+		if !ok {
+			schema, ok = lookupSchema(certStoreSchemas, name)
+		}
+		if !ok || (schema.Settings == nil) {
+			// Sections for providers which are not compiled into the CA are
+			// ignored.
+			continue
+		}
+
+		encoded, err := yaml.Marshal(section)
+		if err != nil {
+			return fmt.Errorf("failed to encode settings of provider %q: %w",
+				name, err)
+		}
+		err = yaml.UnmarshalStrict(encoded, schema.Settings)
+		if err != nil {
+			return fmt.Errorf("failed to parse settings of provider %q: %w",
+				name, err)
+		}
+	}
+	return nil
+}
+
+// validateProviderSettings - check that the requested KMS provider and
+// certificate store have been registered, and validate their settings.
+func (c *ConfigMgr) validateProviderSettings() error {
+	schema, ok := lookupSchema(kmsProviderSchemas,
+		c.config.CertificateAuthority.KmsProvider)
+	if !ok {
+		return fmt.Errorf("%w: %q (registered: %v)",
+			common.ErrInvalidKmsProvider,
+			c.config.CertificateAuthority.KmsProvider, KmsProviders())
+	}
+	if schema.Validate != nil {
+		if err := schema.Validate(c); err != nil {
+			return err
+		}
+	}
+
+	schema, ok = lookupSchema(certStoreSchemas,
+		c.config.CertificateAuthority.CertStoreProvider)
+	if !ok {
+		return fmt.Errorf("%w: %q (registered: %v)",
+			common.ErrInvalidCertStore,
+			c.config.CertificateAuthority.CertStoreProvider, CertStores())
+	}
+	if schema.Validate != nil {
+		if err := schema.Validate(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	"go.uber.org/zap"
+)
+
+const (
+	testKmsProvider = "test_kms"
+	testCertStore   = "test_store"
+)
+
+// Settings of the out-of-tree KMS provider used by the tests.
+type testKmsSettings struct {
+	Endpoint string `yaml:"endpoint"`
+	Retries  int    `yaml:"retries"`
+	Token    string `yaml:"-"`
+}
+
+var (
+	testSettings    testKmsSettings
+	errTestEndpoint = errors.New("endpoint must use https")
+)
+
+func init() {
+	RegisterKmsProvider(testKmsProvider, ProviderSchema{
+		Settings: &testSettings,
+		Env: map[string]EnvOverride{
+			"CA_TEST_KMS_RETRIES": {Value: &testSettings.Retries},
+			"CA_TEST_KMS_TOKEN":   {Value: &testSettings.Token, Secret: true},
+		},
+		Validate: func(c *ConfigMgr) error {
+			if !strings.HasPrefix(testSettings.Endpoint, "https://") {
+				return errTestEndpoint
+			}
+			return nil
+		},
+	})
+	RegisterCertStore(testCertStore, ProviderSchema{})
+}
+
+// Write a configuration file selecting the test providers, with the specified
+// settings for the test KMS provider.
+func writeTestConfig(t *testing.T, endpoint string) {
+	contents, err := os.ReadFile("config.yaml")
+	if err != nil {
+		t.Fatalf("Failed to read the configuration file: %v", err)
+	}
+	// Add the settings of the test KMS provider to the providers section.
+	section := "    " + testKmsProvider + ":\n" +
+		"      endpoint: " + endpoint + "\n" +
+		"      retries: 1\n"
+	lines := strings.SplitAfter(string(contents), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "  providers:") {
+			lines[i] = line + section
+			break
+		}
+	}
+	updated := strings.Join(lines, "")
+
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	err = os.WriteFile(filename, []byte(updated), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write the configuration file: %v", err)
+	}
+	t.Setenv("DSTS_CONFIG_LOCATION", filename)
+	t.Setenv("CA_KMS_PROVIDER", testKmsProvider)
+	t.Setenv("CA_CERT_STORE_PROVIDER", testCertStore)
+}
+
+func TestRegistry_OutOfTreeProvider(t *testing.T) {
+	writeTestConfig(t, "https://kms.example.com")
+	t.Setenv("CA_TEST_KMS_RETRIES", "5")
+	t.Setenv("CA_TEST_KMS_TOKEN", "secret")
+
+	cfgMgr := NewConfigMgr(zap.NewNop(), common.ServiceName)
+	if !cfgMgr.Load(true) {
+		t.Fatalf("Failed to load the configuration")
+	}
+
+	if testSettings.Endpoint != "https://kms.example.com" {
+		t.Errorf("Expected the endpoint to be read from the configuration file, got %q",
+			testSettings.Endpoint)
+	}
+	if testSettings.Retries != 5 {
+		t.Errorf("Expected the retries to be overridden, got %d",
+			testSettings.Retries)
+	}
+	if testSettings.Token != "secret" {
+		t.Errorf("Expected the token to be read from the environment")
+	}
+}
+
+func TestRegistry_ValidationFailure(t *testing.T) {
+	writeTestConfig(t, "http://kms.example.com")
+
+	cfgMgr := NewConfigMgr(zap.NewNop(), common.ServiceName)
+	if cfgMgr.Load(true) {
+		t.Errorf("Expected the configuration to be rejected")
+	}
+	if err := cfgMgr.validateProviderSettings(); !errors.Is(err, errTestEndpoint) {
+		t.Errorf("Expected the provider's validation error, got %v", err)
+	}
+}
+
+func TestRegistry_UnregisteredProvider(t *testing.T) {
+	writeTestConfig(t, "https://kms.example.com")
+	t.Setenv("CA_KMS_PROVIDER", "unknown_kms")
+
+	cfgMgr := NewConfigMgr(zap.NewNop(), common.ServiceName)
+	if cfgMgr.Load(true) {
+		t.Errorf("Expected an unregistered KMS provider to be rejected")
+	}
+	if err := cfgMgr.validateProviderSettings(); !errors.Is(err,
+		common.ErrInvalidKmsProvider) {
+		t.Errorf("Expected an invalid KMS provider error, got %v", err)
+	}
+
+	t.Setenv("CA_KMS_PROVIDER", testKmsProvider)
+	t.Setenv("CA_CERT_STORE_PROVIDER", "unknown_store")
+	cfgMgr = NewConfigMgr(zap.NewNop(), common.ServiceName)
+	if cfgMgr.Load(true) {
+		t.Errorf("Expected an unregistered certificate store to be rejected")
+	}
+}
+
+func TestRegistry_DuplicateRegistration(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a duplicate registration to panic")
+		}
+	}()
+	RegisterKmsProvider(testKmsProvider, ProviderSchema{})
+}
@@ -0,0 +1,263 @@
+// package github.com/HPInc/krypton-ca/service/health
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Determines the readiness of the CA to serve requests. The dependencies of the
+// CA (the certificate store and the KMS provider) are probed periodically and
+// the results of the most recent probes are reported by the readiness endpoint
+// of the REST server and by the standard gRPC health service
+// (grpc.health.v1). Requests for the readiness of the CA are served from the
+// results of the most recent probes, and do not result in requests to the
+// dependencies.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"go.uber.org/zap"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// Interval at which the dependencies of the CA are probed.
+	probeInterval = 15 * time.Second
+
+	// Timeout for each probe of a dependency.
+	probeTimeout = 5 * time.Second
+
+	// Names of the dependencies of the CA, as reported by the readiness
+	// endpoint.
+	DependencyCertStore   = "cert_store"
+	DependencyKmsProvider = "kms_provider"
+)
+
+// Status - the status of the CA or of one of its dependencies.
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// DependencyStatus - the result of the most recent probe of a dependency.
+type DependencyStatus struct {
+	Status Status `json:"status"`
+
+	// Category and description of the failure, if the probe failed.
+	Error string `json:"error,omitempty"`
+
+	// Time taken by the probe, and when it was performed.
+	LatencyMs int64     `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report - the readiness of the CA and the status of each of its dependencies.
+// The CA is ready if all of its dependencies are up, and it is not shutting
+// down.
+type Report struct {
+	Status       Status                      `json:"status"`
+	ShuttingDown bool                        `json:"shutting_down,omitempty"`
+	Dependencies map[string]DependencyStatus `json:"dependencies,omitempty"`
+}
+
+// probe - a dependency of the CA, and the function used to probe it.
+type probe struct {
+	name string
+	ping func(ctx context.Context) error
+}
+
+// Checker - periodically probes the dependencies of the CA and reports the
+// readiness of the CA.
+type Checker struct {
+	logger *zap.Logger
+
+	// Dependencies probed by the checker.
+	probes []probe
+
+	// The standard gRPC health service, updated with the readiness of the
+	// CA after each round of probes.
+	grpcServer *grpchealth.Server
+
+	// The most recent report, replaced after each round of probes.
+	lock         sync.RWMutex
+	report       *Report
+	shuttingDown bool
+
+	// Channels used to stop probing and to wait for probing to stop.
+	started  bool
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewChecker - returns a checker which probes the specified KMS provider and
+// certificate store. The CA is reported as not ready until the checker is
+// started.
+func NewChecker(logger *zap.Logger, provider kms_providers.KmsProvider,
+	store certstore.CertStore) *Checker {
+	c := &Checker{
+		logger: logger,
+		probes: []probe{
+			{name: DependencyCertStore, ping: store.Ping},
+			{name: DependencyKmsProvider, ping: provider.Ping},
+		},
+		grpcServer: grpchealth.NewServer(),
+		report: &Report{
+			Status:       StatusDown,
+			Dependencies: map[string]DependencyStatus{},
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	c.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	return c
+}
+
+// Start - probe the dependencies of the CA, and continue probing them
+// periodically on a separate goroutine until the checker is shut down.
+func (c *Checker) Start() {
+	c.probeDependencies()
+
+	c.lock.Lock()
+	c.started = true
+	c.lock.Unlock()
+	go c.run()
+}
+
+// Report - returns the most recent report of the readiness of the CA. The
+// report must not be modified.
+func (c *Checker) Report() *Report {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.report
+}
+
+// GrpcHealthServer - returns the standard gRPC health service, reporting the
+// readiness of the CA for the certificate authority service and for the
+// server as a whole.
+func (c *Checker) GrpcHealthServer() healthpb.HealthServer {
+	return c.grpcServer
+}
+
+// Shutdown - report that the CA is not ready, so that no further requests are
+// routed to it while in-flight requests are drained, and stop probing the
+// dependencies of the CA.
+func (c *Checker) Shutdown() {
+	c.stopOnce.Do(func() {
+		c.lock.Lock()
+		c.shuttingDown = true
+		report := *c.report
+		report.Status = StatusDown
+		report.ShuttingDown = true
+		c.report = &report
+		started := c.started
+		c.lock.Unlock()
+
+		c.grpcServer.Shutdown()
+		close(c.stop)
+		if started {
+			<-c.done
+		}
+	})
+}
+
+// Probes the dependencies of the CA periodically, until the checker is shut
+// down.
+func (c *Checker) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.probeDependencies()
+		}
+	}
+}
+
+// Probes each of the dependencies of the CA concurrently, and replaces the
+// report with the results.
+func (c *Checker) probeDependencies() {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	results := make([]DependencyStatus, len(c.probes))
+	var wg sync.WaitGroup
+	for i, p := range c.probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.probeDependency(ctx, p)
+		}()
+	}
+	wg.Wait()
+
+	report := &Report{
+		Status:       StatusUp,
+		Dependencies: make(map[string]DependencyStatus, len(c.probes)),
+	}
+	for i, p := range c.probes {
+		report.Dependencies[p.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.shuttingDown {
+		return
+	}
+	c.report = report
+	if report.Status == StatusUp {
+		c.setServingStatus(healthpb.HealthCheckResponse_SERVING)
+	} else {
+		c.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+}
+
+// Probes the specified dependency and returns its status.
+func (c *Checker) probeDependency(ctx context.Context, p probe) DependencyStatus {
+	start := time.Now()
+	err := p.ping(ctx)
+	status := DependencyStatus{
+		Status:    StatusUp,
+		LatencyMs: time.Since(start).Milliseconds(),
+		CheckedAt: start.UTC(),
+	}
+	if err != nil {
+		c.logger.Error("Health check: dependency of the CA is unavailable!",
+			zap.String("Dependency:", p.name),
+			zap.Error(err),
+		)
+		status.Status = StatusDown
+		status.Error = caerrors.CategoryOf(err).String()
+		if message := caerrors.MessageOf(err); message != "" {
+			status.Error += ": " + message
+		}
+		metrics.MetricDependencyUp.WithLabelValues(p.name).Set(0)
+	} else {
+		metrics.MetricDependencyUp.WithLabelValues(p.name).Set(1)
+	}
+	return status
+}
+
+// Sets the serving status reported by the gRPC health service for the server
+// as a whole, and for the certificate authority service.
+func (c *Checker) setServingStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	c.grpcServer.SetServingStatus("", status)
+	c.grpcServer.SetServingStatus(pb.CertificateAuthority_ServiceDesc.ServiceName,
+		status)
+}
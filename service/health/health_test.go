@@ -0,0 +1,104 @@
+package health
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"go.uber.org/zap"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// A fake KMS provider which fails probes while it is down.
+type fakeProvider struct {
+	kms_providers.KmsProvider
+	down atomic.Bool
+}
+
+func (p *fakeProvider) Ping(ctx context.Context) error {
+	if p.down.Load() {
+		return caerrors.New(caerrors.DependencyUnavailable,
+			"cannot get public key")
+	}
+	return nil
+}
+
+// A fake certificate store which is always up.
+type fakeStore struct {
+	certstore.CertStore
+}
+
+func (s *fakeStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Returns the serving status reported by the gRPC health service for the
+// specified service.
+func getServingStatus(t *testing.T, c *Checker,
+	service string) healthpb.HealthCheckResponse_ServingStatus {
+	response, err := c.GrpcHealthServer().Check(context.Background(),
+		&healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Failed to check the serving status: %v", err)
+	}
+	return response.GetStatus()
+}
+
+func TestChecker(t *testing.T) {
+	provider := &fakeProvider{}
+	c := NewChecker(zap.NewNop(), provider, &fakeStore{})
+
+	// The CA is not ready until its dependencies have been probed.
+	if c.Report().Status != StatusDown {
+		t.Errorf("Expected the CA to not be ready before it is started")
+	}
+	c.Start()
+	defer c.Shutdown()
+
+	report := c.Report()
+	if (report.Status != StatusUp) || (len(report.Dependencies) != 2) {
+		t.Errorf("Expected the CA to be ready, got %+v", report)
+	}
+	if status := getServingStatus(t, c, ""); status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Expected the server to be serving, got %v", status)
+	}
+
+	// The CA is not ready while a dependency is down.
+	provider.down.Store(true)
+	c.probeDependencies()
+	report = c.Report()
+	dependency := report.Dependencies[DependencyKmsProvider]
+	if (report.Status != StatusDown) || (dependency.Status != StatusDown) ||
+		(dependency.Error != "dependency_unavailable: cannot get public key") {
+		t.Errorf("Expected the KMS provider to be down, got %+v", report)
+	}
+	if report.Dependencies[DependencyCertStore].Status != StatusUp {
+		t.Errorf("Expected the certificate store to be up, got %+v", report)
+	}
+	if status := getServingStatus(t, c,
+		pb.CertificateAuthority_ServiceDesc.ServiceName); status !=
+		healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected the CA service to not be serving, got %v", status)
+	}
+}
+
+func TestChecker_Shutdown(t *testing.T) {
+	c := NewChecker(zap.NewNop(), &fakeProvider{}, &fakeStore{})
+	c.Start()
+	c.Shutdown()
+
+	// The CA is not ready once it is shutting down, even if its dependencies
+	// are up.
+	c.probeDependencies()
+	report := c.Report()
+	if (report.Status != StatusDown) || !report.ShuttingDown {
+		t.Errorf("Expected the CA to not be ready, got %+v", report)
+	}
+	if status := getServingStatus(t, c, ""); status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected the server to not be serving, got %v", status)
+	}
+}
@@ -9,13 +9,22 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/HPInc/krypton-ca/service/certmgr"
+	"github.com/HPInc/krypton-ca/service/certmgr/backup"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
 	"github.com/HPInc/krypton-ca/service/common"
 	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/HPInc/krypton-ca/service/health"
 	"github.com/HPInc/krypton-ca/service/rest"
 	"github.com/HPInc/krypton-ca/service/rpc"
 	"go.uber.org/zap"
@@ -29,6 +38,19 @@ var (
 	// --log_level: specify the logging level to use.
 	logLevelFlag = flag.String("log_level", "", "Specify the logging level.")
 
+	// --export: export the certificate store to the specified archive.
+	exportFlag = flag.String("export", "",
+		"Export the certificate store to the specified archive and exit!")
+
+	// --import: import the specified archive into the certificate store.
+	importFlag = flag.String("import", "",
+		"Import the specified archive into the certificate store and exit!")
+
+	// --dry_run: verify the archive specified using --import, without
+	// importing it.
+	dryRunFlag = flag.Bool("dry_run", false,
+		"Verify the archive specified using -import without importing it.")
+
 	// Versioning information.
 	gitCommitHash string
 	builtAt       string
@@ -39,6 +61,144 @@ var (
 	cfgMgr *config.ConfigMgr
 )
 
+const (
+	// Time allowed for in-flight gRPC and REST requests to complete when the
+	// service is shut down. Requests still in flight once this elapses are
+	// cancelled.
+	shutdownTimeout = 20 * time.Second
+)
+
+// lifecycle - starts the components of the CA service, and stops them in order
+// when the service is shut down. Components which were not started are nil.
+type lifecycle struct {
+	certProvider  kms_providers.KmsProvider
+	certStore     certstore.CertStore
+	snapshots     *backup.SnapshotScheduler
+	healthChecker *health.Checker
+	restServer    *rest.CaRestService
+	rpcServer     *rpc.CertificateAuthorityServer
+}
+
+// start - initialize the certificate authority and start serving gRPC and REST
+// requests. If a component fails to start, the components already started are
+// stopped.
+func (l *lifecycle) start() error {
+	var err error
+
+	// Initialize the certificate authority.
+	l.certProvider, l.certStore, err = certmgr.Init(caLogger, cfgMgr)
+	if err != nil {
+		caLogger.Error("Failed to initialize the certificate authority!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// Start writing snapshots of the certificate store, if configured.
+	l.snapshots = backup.StartSnapshots(caLogger, l.certStore,
+		cfgMgr.GetBackupConfig())
+
+	// Start probing the certificate store and the KMS provider, to report the
+	// readiness of the CA.
+	l.healthChecker = health.NewChecker(caLogger, l.certProvider, l.certStore)
+	l.healthChecker.Start()
+
+	// Initialize the service used to process certificate authority RPCs. The
+	// service is shared by the gRPC server and the REST/JSON gateway, so that
+	// requests received by either are subject to the same rate limits and
+	// device caps.
+	caService := rpc.NewCertificateAuthorityService(caLogger, cfgMgr,
+		l.certProvider, l.certStore)
+
+	// Initialize the REST server and start listening for REST requests.
+	l.restServer, err = rest.Init(caLogger, cfgMgr, caService, l.certProvider,
+		l.certStore, l.healthChecker)
+	if err != nil {
+		caLogger.Error("Failed to initialize the REST server!",
+			zap.Error(err),
+		)
+		l.stop()
+		return err
+	}
+
+	// Initialize the gRPC server and start listening for RPC requests at the
+	// certificate authority endpoint.
+	l.rpcServer, err = rpc.Init(cfgMgr, caService, l.healthChecker)
+	if err != nil {
+		caLogger.Error("Failed to initialize the gRPC server!",
+			zap.Error(err),
+		)
+		l.stop()
+		return err
+	}
+	return nil
+}
+
+// awaitTermination - block until a signal to shut down the service is received,
+// or either server encounters a fatal error.
+func (l *lifecycle) awaitTermination() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case sig := <-signals:
+		caLogger.Info("Received an OS signal to shut down!",
+			zap.String("Signal received: ", sig.String()),
+		)
+	case err := <-l.rpcServer.Errors():
+		caLogger.Error("Shutting down due to a fatal error in the gRPC server.",
+			zap.Error(err),
+		)
+	case err := <-l.restServer.Errors():
+		caLogger.Error("Shutting down due to a fatal error in the REST server.",
+			zap.Error(err),
+		)
+	}
+}
+
+// stop - report that the CA is not ready, stop serving requests and wait for
+// in-flight requests to complete, then stop writing snapshots and shut down
+// the KMS provider and the certificate store.
+func (l *lifecycle) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if l.healthChecker != nil {
+		l.healthChecker.Shutdown()
+	}
+
+	// Drain in-flight gRPC and REST requests concurrently, so that both
+	// servers share the shutdown deadline.
+	var servers sync.WaitGroup
+	if l.rpcServer != nil {
+		servers.Add(1)
+		go func() {
+			defer servers.Done()
+			_ = l.rpcServer.Shutdown(ctx)
+		}()
+	}
+	if l.restServer != nil {
+		servers.Add(1)
+		go func() {
+			defer servers.Done()
+			_ = l.restServer.Shutdown(ctx)
+		}()
+	}
+	servers.Wait()
+
+	// Requests are no longer being served, so the KMS provider and the
+	// certificate store it uses can be shut down.
+	l.snapshots.Stop()
+	if l.certProvider != nil {
+		l.certProvider.Shutdown()
+	}
+	if l.certStore != nil {
+		l.certStore.Shutdown()
+	}
+	caLogger.Info("Krypton Certificate Authority: shutdown complete.")
+}
+
 // Display version information for the CA service's binary.
 func printVersionInformation() {
 	fmt.Println("Krypton Certificate Authority: version information")
@@ -69,30 +229,31 @@ func main() {
 	// Set the default log level.
 	setLogLevel(*logLevelFlag)
 
-	// Initialize the certificate authority.
-	certProvider, err := certmgr.Init(caLogger, cfgMgr)
-	if err != nil {
-		caLogger.Error("Failed to initialize the certificate authority!",
-			zap.Error(err),
-		)
+	// Export or import the certificate store, if requested.
+	if (*exportFlag != "") || (*importFlag != "") {
+		if *exportFlag != "" {
+			err = runExport(*exportFlag)
+		} else {
+			err = runImport(*importFlag, *dryRunFlag)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			shutdownLogger()
+			os.Exit(1)
+		}
 		shutdownLogger()
-		os.Exit(2)
+		return
 	}
 
-	// Initialize the REST server and listen for requests on a separate
-	// goroutine.
-	go rest.Init(caLogger, cfgMgr)
-
-	// Initialize the gRPC server and start listening for RPC requests at the
-	// certificate authority endpoint.
-	err = rpc.Init(caLogger, cfgMgr.GetServerConfig(), certProvider)
-	if err != nil {
-		caLogger.Error("Failed to initialize the gRPC server!",
-			zap.Error(err),
-		)
+	// Start the components of the certificate authority and serve requests
+	// until the service is shut down.
+	l := &lifecycle{}
+	if err = l.start(); err != nil {
 		shutdownLogger()
 		os.Exit(2)
 	}
+	l.awaitTermination()
+	l.stop()
 
 	shutdownLogger()
 	fmt.Println("Krypton Certificate Authority: Goodbye!")
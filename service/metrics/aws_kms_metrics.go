@@ -121,4 +121,11 @@ var (
 			Name: "ca_aws_kms_sign_failures",
 			Help: "Total number of failed signature operations using AWS KMS",
 		})
+
+	// Number of requests to AWS KMS that were throttled.
+	MetricAwsKmsThrottledRequests = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_aws_kms_throttled_requests",
+			Help: "Total number of requests to AWS KMS that were throttled",
+		})
 )
@@ -0,0 +1,81 @@
+// package github.com/HPInc/krypton-ca/service/metrics
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines prometheus metrics used for monitoring Azure Key Vault operations
+// issued by the CA.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// Latency of requests made to Azure Key Vault.
+	MetricAzureKeyVaultRequestLatency = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Name:       "ca_azure_keyvault_latency_milliseconds",
+			Help:       "A latency histogram for requests to Azure Key Vault",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		},
+		[]string{"method"},
+	)
+
+	// Number of keys created in Azure Key Vault.
+	MetricAzureKeyVaultKeyCreated = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_azure_keyvault_key_creates",
+			Help: "Total number of keys created in Azure Key Vault",
+		})
+
+	// Number of failures creating keys in Azure Key Vault.
+	MetricAzureKeyVaultKeyCreationFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_azure_keyvault_key_create_failures",
+			Help: "Total number of failures creating keys in Azure Key Vault",
+		})
+
+	// Number of keys deleted from Azure Key Vault.
+	MetricAzureKeyVaultKeyDeleted = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_azure_keyvault_key_deletes",
+			Help: "Total number of keys deleted from Azure Key Vault",
+		})
+
+	// Number of failures deleting keys from Azure Key Vault.
+	MetricAzureKeyVaultKeyDeletionFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_azure_keyvault_key_delete_failures",
+			Help: "Total number of failures deleting keys from Azure Key Vault",
+		})
+
+	// Number of keys (public keys) retrieved from Azure Key Vault.
+	MetricAzureKeyVaultKeyRetrieved = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_azure_keyvault_key_gets",
+			Help: "Total number of keys retrieved from Azure Key Vault",
+		})
+
+	// Number of failures retrieving keys (public keys) from Azure Key Vault.
+	MetricAzureKeyVaultKeyRetrievalFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_azure_keyvault_key_get_failures",
+			Help: "Total number of failures retrieving keys from Azure Key Vault",
+		})
+
+	// Number of sign operations performed using Azure Key Vault.
+	MetricAzureKeyVaultSignatureSuccess = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_azure_keyvault_sign_success",
+			Help: "Total number of successful signature operations using Azure Key Vault",
+		})
+
+	// Number of failed sign operations performed using Azure Key Vault.
+	MetricAzureKeyVaultSignatureFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_azure_keyvault_sign_failures",
+			Help: "Total number of failed signature operations using Azure Key Vault",
+		})
+)
@@ -0,0 +1,44 @@
+// package github.com/HPInc/krypton-ca/service/metrics
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines prometheus metrics used to track the effectiveness of the in-memory
+// caches used by the CA (eg. the signing certificate cache).
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// Number of lookups which found an unexpired entry in the cache,
+	// partitioned by the name of the cache.
+	MetricCacheHits = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_cache_hits",
+			Help: "Total number of cache lookups which found an entry",
+		},
+		[]string{"cache"},
+	)
+
+	// Number of lookups which did not find an entry in the cache, or found
+	// an expired entry.
+	MetricCacheMisses = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_cache_misses",
+			Help: "Total number of cache lookups which did not find an entry",
+		},
+		[]string{"cache"},
+	)
+
+	// Number of entries evicted from the cache because the cache was full.
+	MetricCacheEvictions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_cache_evictions",
+			Help: "Total number of entries evicted from the cache",
+		},
+		[]string{"cache"},
+	)
+)
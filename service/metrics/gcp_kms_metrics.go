@@ -0,0 +1,81 @@
+// package github.com/HPInc/krypton-ca/service/metrics
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines prometheus metrics used for monitoring Google Cloud KMS operations
+// issued by the CA.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// Latency of requests made to Google Cloud KMS.
+	MetricGcpKmsRequestLatency = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Name:       "ca_gcp_kms_latency_milliseconds",
+			Help:       "A latency histogram for requests to Google Cloud KMS",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		},
+		[]string{"method"},
+	)
+
+	// Number of keys created in Google Cloud KMS.
+	MetricGcpKmsKeyCreated = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_gcp_kms_key_creates",
+			Help: "Total number of keys created in Google Cloud KMS",
+		})
+
+	// Number of failures creating keys in Google Cloud KMS.
+	MetricGcpKmsKeyCreationFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_gcp_kms_key_create_failures",
+			Help: "Total number of failures creating keys in Google Cloud KMS",
+		})
+
+	// Number of keys deleted from Google Cloud KMS.
+	MetricGcpKmsKeyDeleted = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_gcp_kms_key_deletes",
+			Help: "Total number of keys deleted from Google Cloud KMS",
+		})
+
+	// Number of failures deleting keys from Google Cloud KMS.
+	MetricGcpKmsKeyDeletionFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_gcp_kms_key_delete_failures",
+			Help: "Total number of failures deleting keys from Google Cloud KMS",
+		})
+
+	// Number of keys (public keys) retrieved from Google Cloud KMS.
+	MetricGcpKmsKeyRetrieved = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_gcp_kms_key_gets",
+			Help: "Total number of keys retrieved from Google Cloud KMS",
+		})
+
+	// Number of failures retrieving keys (public keys) from Google Cloud KMS.
+	MetricGcpKmsKeyRetrievalFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_gcp_kms_key_get_failures",
+			Help: "Total number of failures retrieving keys from Google Cloud KMS",
+		})
+
+	// Number of sign operations performed using Google Cloud KMS.
+	MetricGcpKmsSignatureSuccess = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_gcp_kms_sign_success",
+			Help: "Total number of successful signature operations using Google Cloud KMS",
+		})
+
+	// Number of failed sign operations performed using Google Cloud KMS.
+	MetricGcpKmsSignatureFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_gcp_kms_sign_failures",
+			Help: "Total number of failed signature operations using Google Cloud KMS",
+		})
+)
@@ -0,0 +1,24 @@
+// package github.com/HPInc/krypton-ca/service/metrics
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines prometheus metrics used to report the health of the dependencies of
+// the CA, as determined by the readiness probes.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// Set to 1 if the most recent probe of a dependency succeeded.
+	MetricDependencyUp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ca_dependency_up",
+			Help: "Whether the most recent readiness probe of a dependency succeeded",
+		},
+		[]string{"dependency"},
+	)
+)
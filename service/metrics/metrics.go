@@ -19,7 +19,12 @@ func RegisterPrometheusMetrics() {
 	prometheus.MustRegister(MetricRPCLatency)
 	prometheus.MustRegister(MetricRestLatency)
 	prometheus.MustRegister(MetricAwsKmsRequestLatency)
+	prometheus.MustRegister(MetricPkcs11KmsRequestLatency)
+	prometheus.MustRegister(MetricVaultTransitRequestLatency)
+	prometheus.MustRegister(MetricGcpKmsRequestLatency)
+	prometheus.MustRegister(MetricAzureKeyVaultRequestLatency)
 	prometheus.MustRegister(MetricAwsDynamoDbRequestLatency)
+	prometheus.MustRegister(MetricPostgresRequestLatency)
 }
 
 // ReportLatencyMetric reports the latency of the specified operation to the
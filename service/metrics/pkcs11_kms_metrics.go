@@ -0,0 +1,67 @@
+// package github.com/HPInc/krypton-ca/service/metrics
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines prometheus metrics used for monitoring operations performed by the
+// CA using PKCS#11 tokens (HSMs).
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// Latency of requests made to the PKCS#11 token.
+	MetricPkcs11KmsRequestLatency = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Name:       "ca_pkcs11_kms_latency_milliseconds",
+			Help:       "A latency histogram for requests to the PKCS#11 token",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		},
+		[]string{"method"},
+	)
+
+	// Number of key pairs generated in the PKCS#11 token.
+	MetricPkcs11KmsKeyCreated = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_pkcs11_kms_key_creates",
+			Help: "Total number of key pairs generated in the PKCS#11 token",
+		})
+
+	// Number of failures generating key pairs in the PKCS#11 token.
+	MetricPkcs11KmsKeyCreationFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_pkcs11_kms_key_create_failures",
+			Help: "Total number of failures generating key pairs in the PKCS#11 token",
+		})
+
+	// Number of key pairs destroyed in the PKCS#11 token.
+	MetricPkcs11KmsKeyDeleted = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_pkcs11_kms_key_deletes",
+			Help: "Total number of key pairs destroyed in the PKCS#11 token",
+		})
+
+	// Number of failures destroying key pairs in the PKCS#11 token.
+	MetricPkcs11KmsKeyDeletionFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_pkcs11_kms_key_delete_failures",
+			Help: "Total number of failures destroying key pairs in the PKCS#11 token",
+		})
+
+	// Number of sign operations performed using the PKCS#11 token.
+	MetricPkcs11KmsSignatureSuccess = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_pkcs11_kms_sign_success",
+			Help: "Total number of successful signature operations using the PKCS#11 token",
+		})
+
+	// Number of failed sign operations performed using the PKCS#11 token.
+	MetricPkcs11KmsSignatureFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_pkcs11_kms_sign_failures",
+			Help: "Total number of failed signature operations using the PKCS#11 token",
+		})
+)
@@ -0,0 +1,33 @@
+// package github.com/HPInc/krypton-ca/service/metrics
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines prometheus metrics used to track PostgreSQL queries issued by the
+// CA, when using PostgreSQL as the certificate store.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// PostgreSQL query latency is partitioned by the certificate store
+	// operation.
+	MetricPostgresRequestLatency = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Name:       "ca_postgres_latency_milliseconds",
+			Help:       "A latency histogram for queries to PostgreSQL",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		},
+		[]string{"method"},
+	)
+
+	// Number of PostgreSQL queries which failed.
+	MetricPostgresQueryFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_postgres_query_failures",
+			Help: "Total number of failed queries to PostgreSQL",
+		})
+)
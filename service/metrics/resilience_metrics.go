@@ -0,0 +1,64 @@
+// package github.com/HPInc/krypton-ca/service/metrics
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines prometheus metrics used to track retries and circuit breaking for
+// calls made by the CA to its dependencies (eg. AWS KMS, Dynamo DB).
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// Number of calls to a dependency which were retried after a transient
+	// failure, partitioned by the dependency and the method.
+	MetricDependencyRetries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_dependency_retries",
+			Help: "Total number of calls to a dependency retried after a transient failure",
+		},
+		[]string{"dependency", "method"},
+	)
+
+	// Number of calls to a dependency which failed with a transient failure
+	// once retries were exhausted, partitioned by the dependency and the
+	// method.
+	MetricDependencyFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_dependency_failures",
+			Help: "Total number of calls to a dependency which failed after retries",
+		},
+		[]string{"dependency", "method"},
+	)
+
+	// Number of calls to a dependency rejected without being attempted,
+	// because the circuit breaker for the dependency was open.
+	MetricCircuitBreakerRejections = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_circuit_breaker_rejections",
+			Help: "Total number of calls to a dependency rejected by the circuit breaker",
+		},
+		[]string{"dependency", "method"},
+	)
+
+	// Number of times the circuit breaker for a dependency was opened.
+	MetricCircuitBreakerTrips = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_circuit_breaker_trips",
+			Help: "Total number of times the circuit breaker for a dependency was opened",
+		},
+		[]string{"dependency"},
+	)
+
+	// Set to 1 while the circuit breaker for a dependency is open.
+	MetricCircuitBreakerOpen = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ca_circuit_breaker_open",
+			Help: "Whether the circuit breaker for a dependency is open",
+		},
+		[]string{"dependency"},
+	)
+)
@@ -0,0 +1,74 @@
+// package github.com/HPInc/krypton-ca/service/metrics
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines prometheus metrics used to track requests served by the CA over its
+// REST server.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// Number of EST enrollment requests served by the CA. This is partitioned
+	// by the EST operation and the HTTP status code of the response.
+	MetricEstRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_rest_est_requests",
+			Help: "Total number of EST enrollment requests served by the CA",
+		},
+		[]string{"operation", "status"},
+	)
+
+	// Number of SCEP requests served by the CA. This is partitioned by the
+	// SCEP operation and the HTTP status code of the response, or "failure"
+	// for enrollment requests rejected using a CertRep message.
+	MetricScepRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_rest_scep_requests",
+			Help: "Total number of SCEP requests served by the CA",
+		},
+		[]string{"operation", "status"},
+	)
+
+	// Number of requests served by the REST/JSON gateway. This is partitioned
+	// by the RPC invoked and the HTTP status code of the response.
+	MetricGatewayRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_rest_gateway_requests",
+			Help: "Total number of requests served by the REST/JSON gateway",
+		},
+		[]string{"rpc", "status"},
+	)
+
+	// Number of requests for CA certificates served by the CA. This is
+	// partitioned by the requested resource and the HTTP status code of the
+	// response.
+	MetricCACertificateRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_rest_ca_certificate_requests",
+			Help: "Total number of requests for CA certificates served by the CA",
+		},
+		[]string{"resource", "status"},
+	)
+
+	// Number of ACME requests which failed. This is partitioned by the type
+	// of problem reported to the ACME client.
+	MetricAcmeProblems = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_rest_acme_problems",
+			Help: "Total number of ACME requests which failed",
+		},
+		[]string{"type"},
+	)
+
+	// Number of certificates issued by the ACME server.
+	MetricAcmeCertificatesIssued = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_rest_acme_certificates_issued",
+			Help: "Total number of certificates issued by the ACME server",
+		})
+)
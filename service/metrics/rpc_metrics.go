@@ -27,6 +27,56 @@ var (
 			Help: "Total number of failed RPC requests to the CA",
 		})
 
+	// Number of gRPC requests rejected by the rate limiter. This is partitioned
+	// by the limit that was exceeded (tenant or caller) and the RPC method, or
+	// the EST, SCEP or ACME operation for enrollment requests.
+	MetricRPCRequestsThrottled = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_rpc_throttled_requests",
+			Help: "Total number of RPC requests to the CA rejected by the rate limiter",
+		},
+		[]string{"limit", "method"},
+	)
+
+	// Number of create device certificate requests rejected because the
+	// tenant reached its device cap.
+	MetricDeviceCapReached = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_rpc_device_cap_reached",
+			Help: "Total number of create device certificate requests rejected due to the tenant device cap",
+		})
+
+	// Number of retried create device certificate requests for which the
+	// previously issued device certificate was returned.
+	MetricCreateDeviceCertificateReplays = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_rpc_create_cert_replays",
+			Help: "Total number of retried create device certificate requests answered with the previously issued certificate",
+		})
+
+	// Number of items within batch device certificate requests, partitioned by
+	// the status code of the item.
+	MetricBatchDeviceCertificateItems = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_rpc_batch_cert_items",
+			Help: "Total number of items processed within batch device certificate requests",
+		}, []string{"status"})
+
+	// Number of batch device certificate requests rejected as invalid.
+	MetricCreateDeviceCertificatesBadRequests = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_rpc_create_certs_bad_requests",
+			Help: "Total number of bad batch device certificate requests to the CA",
+		})
+
+	// Number of batch device certificate requests resulting in internal
+	// errors.
+	MetricCreateDeviceCertificatesInternalErrors = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_rpc_create_certs_internal_errors",
+			Help: "Total number of internal errors processing batch device certificate requests",
+		})
+
 	// RPC request processing latency is partitioned by the RPC method. It uses
 	// custom buckets based on the expected request duration.
 	MetricRPCLatency = prometheus.NewSummaryVec(
@@ -77,6 +127,13 @@ var (
 			Help: "Total number of bad delete tenant signing certificate requests to the CA",
 		})
 
+	// Number of bad/invalid tenant quota requests to the CA.
+	MetricTenantQuotaBadRequests = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_rpc_tenant_quota_bad_requests",
+			Help: "Total number of bad get/set tenant quota requests to the CA",
+		})
+
 	// Number of create certificate requests to the CA, resulting in internal
 	// errors.
 	MetricCreateDeviceCertificateInternalErrors = prometheus.NewCounter(
@@ -106,4 +163,12 @@ var (
 			Name: "ca_rpc_delete_tenant_cert_internal_errors",
 			Help: "Total number of internal errors processing delete tenant signing certificate requests",
 		})
+
+	// Number of get/set tenant quota requests to the CA, resulting in internal
+	// errors.
+	MetricTenantQuotaInternalErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_rpc_tenant_quota_internal_errors",
+			Help: "Total number of internal errors processing get/set tenant quota requests",
+		})
 )
@@ -0,0 +1,29 @@
+// package github.com/HPInc/krypton-ca/service/metrics
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines prometheus metrics used to track the signing operations performed
+// by the KMS provider, which are bounded by the signing pool.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// Number of signing operations currently being performed.
+	MetricSigningOperationsInFlight = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ca_signing_operations_in_flight",
+			Help: "Number of signing operations currently being performed",
+		})
+
+	// Number of signing operations waiting for a slot in the signing pool.
+	MetricSigningOperationsQueued = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ca_signing_operations_queued",
+			Help: "Number of signing operations waiting to be performed",
+		})
+)
@@ -0,0 +1,67 @@
+// package github.com/HPInc/krypton-ca/service/metrics
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Defines prometheus metrics used for monitoring operations performed by the
+// CA using the transit secrets engine of HashiCorp Vault.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// Latency of requests made to Vault transit.
+	MetricVaultTransitRequestLatency = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Name:       "ca_vault_transit_latency_milliseconds",
+			Help:       "A latency histogram for requests to Vault transit",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		},
+		[]string{"method"},
+	)
+
+	// Number of keys created in Vault transit.
+	MetricVaultTransitKeyCreated = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_vault_transit_key_creates",
+			Help: "Total number of keys created in Vault transit",
+		})
+
+	// Number of failures creating keys in Vault transit.
+	MetricVaultTransitKeyCreationFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_vault_transit_key_create_failures",
+			Help: "Total number of failures creating keys in Vault transit",
+		})
+
+	// Number of keys deleted from Vault transit.
+	MetricVaultTransitKeyDeleted = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_vault_transit_key_deletes",
+			Help: "Total number of keys deleted from Vault transit",
+		})
+
+	// Number of failures deleting keys from Vault transit.
+	MetricVaultTransitKeyDeletionFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_vault_transit_key_delete_failures",
+			Help: "Total number of failures deleting keys from Vault transit",
+		})
+
+	// Number of sign operations performed using Vault transit.
+	MetricVaultTransitSignatureSuccess = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_vault_transit_sign_success",
+			Help: "Total number of successful signature operations using Vault transit",
+		})
+
+	// Number of failed sign operations performed using Vault transit.
+	MetricVaultTransitSignatureFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ca_vault_transit_sign_failures",
+			Help: "Total number of failed signature operations using Vault transit",
+		})
+)
@@ -0,0 +1,232 @@
+// package github.com/HPInc/krypton-ca/service/rest
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the public CA certificate distribution endpoints served at the
+// CA's REST endpoint. Relying parties can retrieve the root CA certificate,
+// the common signing certificate and the chain of certificates used to sign
+// device certificates within a tenant, PEM encoded, DER encoded or as a PKCS#7
+// "certs only" structure. Responses carry an ETag and caching directives, so
+// that relying parties and intermediate caches can refresh their trust anchors
+// cheaply.
+package rest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/gorilla/mux"
+	"go.mozilla.org/pkcs7"
+	"go.uber.org/zap"
+)
+
+const (
+	// Names of the path variables specifying the tenant and the requested
+	// format in CA certificate requests.
+	caCertTenantVar = "tenant"
+	caCertFormatVar = "format"
+
+	// Formats in which CA certificates are returned, specified using the
+	// file extension of the requested resource.
+	caCertFormatPem   = "pem"
+	caCertFormatDer   = "der"
+	caCertFormatPkcs7 = "p7b"
+
+	// CA certificate resources, used to partition metrics.
+	caCertResourceRoot    = "root"
+	caCertResourceSigning = "signing"
+	caCertResourceChain   = "chain"
+
+	// Caching directives for CA certificates. The root and common signing
+	// certificates rarely change, while tenant signing certificates may be
+	// created or deleted at any time.
+	caCertCacheControl      = "public, max-age=86400"
+	caCertChainCacheControl = "public, max-age=300"
+
+	// PEM block type of encoded certificates.
+	pemTypeCACertificate = "CERTIFICATE"
+
+	// Special values used within the If-None-Match header.
+	ifNoneMatchAnyEntityTag = "*"
+	weakEntityTagPrefix     = "W/"
+	entityTagListSeparator  = ","
+)
+
+// GetCARootCertificateHandler - returns the root CA certificate
+// (/ca/root.{format}).
+func GetCARootCertificateHandler(w http.ResponseWriter, r *http.Request) {
+	rootCert, _, err := kmsProvider.GetCACertificates(r.Context())
+	if err != nil {
+		caLogger.Error("Failed to get the root CA certificate!",
+			zap.String("Request ID:", r.Header.Get(headerRequestID)),
+			zap.Error(err),
+		)
+		writeCACertificateError(w, caCertResourceRoot, err)
+		return
+	}
+
+	writeCACertificates(w, r, caCertResourceRoot, [][]byte{rootCert},
+		caCertCacheControl)
+}
+
+// GetCASigningCertificateHandler - returns the common signing certificate,
+// used to sign device certificates within tenants which do not have a tenant
+// signing certificate (/ca/signing.{format}).
+func GetCASigningCertificateHandler(w http.ResponseWriter, r *http.Request) {
+	_, signingCert, err := kmsProvider.GetCACertificates(r.Context())
+	if err != nil {
+		caLogger.Error("Failed to get the common signing certificate!",
+			zap.String("Request ID:", r.Header.Get(headerRequestID)),
+			zap.Error(err),
+		)
+		writeCACertificateError(w, caCertResourceSigning, err)
+		return
+	}
+
+	writeCACertificates(w, r, caCertResourceSigning, [][]byte{signingCert},
+		caCertCacheControl)
+}
+
+// GetCAChainHandler - returns the chain of certificates used to sign device
+// certificates within the tenant - the signing certificate used within the
+// tenant followed by the root CA certificate (/ca/{tenant}/chain.{format}).
+func GetCAChainHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)[caCertTenantVar]
+
+	chain, err := kmsProvider.GetSigningCertificateChain(r.Context(), tenantID)
+	if err != nil {
+		caLogger.Error("Failed to get the signing certificate chain for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", r.Header.Get(headerRequestID)),
+			zap.Error(err),
+		)
+		writeCACertificateError(w, caCertResourceChain, err)
+		return
+	}
+
+	parsed, err := pkcs7.Parse(chain)
+	if err != nil {
+		caLogger.Error("Failed to parse the signing certificate chain for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", r.Header.Get(headerRequestID)),
+			zap.Error(err),
+		)
+		writeCACertificateError(w, caCertResourceChain, err)
+		return
+	}
+
+	certs := [][]byte{}
+	for _, cert := range parsed.Certificates {
+		certs = append(certs, cert.Raw)
+	}
+	writeCACertificates(w, r, caCertResourceChain, certs,
+		caCertChainCacheControl)
+}
+
+// Encode the DER encoded certificates in the requested format and write them
+// in response to a CA certificate request. If the caller already has the
+// current version of the certificates, a Not Modified response is written.
+func writeCACertificates(w http.ResponseWriter, r *http.Request,
+	resource string, certs [][]byte, cacheControl string) {
+	var (
+		body        []byte
+		contentType string
+		err         error
+	)
+	switch mux.Vars(r)[caCertFormatVar] {
+	case caCertFormatPem:
+		for _, cert := range certs {
+			body = append(body, pem.EncodeToMemory(&pem.Block{
+				Type:  pemTypeCACertificate,
+				Bytes: cert,
+			})...)
+		}
+		contentType = contentTypePemFile
+
+	case caCertFormatDer:
+		// Only a single certificate can be returned DER encoded. The
+		// routes do not permit certificate chains to be requested in
+		// this format.
+		body = certs[0]
+		contentType = contentTypePkixCert
+
+	case caCertFormatPkcs7:
+		body, err = pkcs7.DegenerateCertificate(bytes.Join(certs, nil))
+		if err != nil {
+			caLogger.Error("Failed to create degenerate PKCS7 object!",
+				zap.String("Request ID:", r.Header.Get(headerRequestID)),
+				zap.Error(err),
+			)
+			writeCACertificateError(w, resource, err)
+			return
+		}
+		contentType = contentTypePkcs7Mime
+
+	default:
+		writeCACertificateStatus(w, resource, http.StatusNotFound)
+		return
+	}
+
+	digest := sha256.Sum256(body)
+	entityTag := strconv.Quote(hex.EncodeToString(digest[:]))
+	w.Header().Set(headerETag, entityTag)
+	w.Header().Set(headerCacheControl, cacheControl)
+
+	if entityTagMatches(r.Header.Get(headerIfNoneMatch), entityTag) {
+		writeCACertificateStatus(w, resource, http.StatusNotModified)
+		return
+	}
+
+	metrics.MetricCACertificateRequests.WithLabelValues(resource,
+		strconv.Itoa(http.StatusOK)).Inc()
+	w.Header().Set(headerContentType, contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// Checks whether the entity tag matches one of the entity tags specified in
+// the If-None-Match header, using the weak comparison function (RFC 9110).
+func entityTagMatches(ifNoneMatch string, entityTag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, entityTagListSeparator) {
+		tag = strings.TrimSpace(tag)
+		if (tag == ifNoneMatchAnyEntityTag) ||
+			(strings.TrimPrefix(tag, weakEntityTagPrefix) == entityTag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write a response with the specified status code and no body.
+func writeCACertificateStatus(w http.ResponseWriter, resource string,
+	statusCode int) {
+	metrics.MetricCACertificateRequests.WithLabelValues(resource,
+		strconv.Itoa(statusCode)).Inc()
+	w.WriteHeader(statusCode)
+}
+
+// Write an error response to a CA certificate request, with the HTTP status
+// code chosen based on the category of the error.
+func writeCACertificateError(w http.ResponseWriter, resource string, err error) {
+	category := caerrors.CategoryOf(err)
+	statusCode := categoryHttpStatus[category]
+	metrics.MetricCACertificateRequests.WithLabelValues(resource,
+		strconv.Itoa(statusCode)).Inc()
+
+	// Describe the failure to the caller, except for internal errors whose
+	// details are only logged.
+	message := http.StatusText(statusCode)
+	if detail := caerrors.MessageOf(err); (detail != "") &&
+		(category != caerrors.Internal) {
+		message = message + ": " + detail
+	}
+	http.Error(w, message, statusCode)
+}
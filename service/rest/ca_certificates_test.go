@@ -0,0 +1,185 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"testing"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/google/uuid"
+	"go.mozilla.org/pkcs7"
+)
+
+// Send a request for CA certificates and return the response, along with its
+// body.
+func doCACertificateRequest(t *testing.T, path string,
+	ifNoneMatch string) (*http.Response, []byte) {
+	request, err := http.NewRequest(http.MethodGet, gTestServer.URL+path, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if ifNoneMatch != "" {
+		request.Header.Set(headerIfNoneMatch, ifNoneMatch)
+	}
+
+	response, err := gTestServer.Client().Do(request)
+	if err != nil {
+		t.Fatalf("CA certificate request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("Failed to read the response: %v", err)
+	}
+	return response, body
+}
+
+func TestCARootCertificate(t *testing.T) {
+	response, body := doCACertificateRequest(t, "/ca/root.pem", "")
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Request failed with status %d: %s", response.StatusCode, body)
+	}
+	if response.Header.Get(headerContentType) != contentTypePemFile {
+		t.Errorf("Unexpected content type: %s",
+			response.Header.Get(headerContentType))
+	}
+	if response.Header.Get(headerCacheControl) != caCertCacheControl {
+		t.Errorf("Unexpected caching directives: %s",
+			response.Header.Get(headerCacheControl))
+	}
+
+	block, rest := pem.Decode(body)
+	if (block == nil) || (len(rest) != 0) {
+		t.Fatalf("Expected a single PEM encoded certificate: %s", body)
+	}
+	rootCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse the root CA certificate: %v", err)
+	}
+	if !rootCert.IsCA {
+		t.Errorf("Expected a CA certificate to be returned")
+	}
+
+	// The DER encoded certificate is the same certificate, but is a
+	// different representation and so has a different entity tag.
+	derResponse, derBody := doCACertificateRequest(t, "/ca/root.der", "")
+	if derResponse.StatusCode != http.StatusOK {
+		t.Fatalf("Request failed with status %d", derResponse.StatusCode)
+	}
+	if !bytes.Equal(derBody, block.Bytes) {
+		t.Errorf("Expected the DER encoded root CA certificate")
+	}
+	if derResponse.Header.Get(headerETag) == response.Header.Get(headerETag) {
+		t.Errorf("Expected different entity tags for different formats")
+	}
+}
+
+func TestCARootCertificate_NotModified(t *testing.T) {
+	response, _ := doCACertificateRequest(t, "/ca/root.pem", "")
+	entityTag := response.Header.Get(headerETag)
+	if entityTag == "" {
+		t.Fatalf("Expected an entity tag to be returned")
+	}
+
+	for _, ifNoneMatch := range []string{
+		entityTag,
+		"W/" + entityTag,
+		`"stale", ` + entityTag,
+		"*",
+	} {
+		response, body := doCACertificateRequest(t, "/ca/root.pem", ifNoneMatch)
+		if response.StatusCode != http.StatusNotModified {
+			t.Errorf("Expected status %d for %q, got %d", http.StatusNotModified,
+				ifNoneMatch, response.StatusCode)
+		}
+		if len(body) != 0 {
+			t.Errorf("Unexpected body returned for %q", ifNoneMatch)
+		}
+	}
+
+	response, _ = doCACertificateRequest(t, "/ca/root.pem", `"stale"`)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK,
+			response.StatusCode)
+	}
+}
+
+func TestCAChain(t *testing.T) {
+	tenantID := createGatewayTestTenant(t)
+
+	response, body := doCACertificateRequest(t, "/ca/"+tenantID+"/chain.p7b",
+		"")
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Request failed with status %d: %s", response.StatusCode, body)
+	}
+	if response.Header.Get(headerCacheControl) != caCertChainCacheControl {
+		t.Errorf("Unexpected caching directives: %s",
+			response.Header.Get(headerCacheControl))
+	}
+
+	parsed, err := pkcs7.Parse(body)
+	if err != nil {
+		t.Fatalf("Failed to parse the certificate chain: %v", err)
+	}
+	if len(parsed.Certificates) != 2 {
+		t.Fatalf("Expected the tenant signing and root CA certificates, got %d certificates",
+			len(parsed.Certificates))
+	}
+	if err = parsed.Certificates[0].CheckSignatureFrom(
+		parsed.Certificates[1]); err != nil {
+		t.Errorf("Expected the chain in leaf to root order: %v", err)
+	}
+
+	_, signingBody := doCACertificateRequest(t, "/ca/signing.der", "")
+	if bytes.Equal(parsed.Certificates[0].Raw, signingBody) {
+		t.Errorf("Expected the tenant signing certificate in the chain")
+	}
+
+	// The chain cannot be DER encoded.
+	response, _ = doCACertificateRequest(t, "/ca/"+tenantID+"/chain.der", "")
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound,
+			response.StatusCode)
+	}
+}
+
+func TestCAChain_UnknownTenant(t *testing.T) {
+	response, body := doCACertificateRequest(t,
+		"/ca/"+uuid.NewString()+"/chain.pem", "")
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Request failed with status %d: %s", response.StatusCode, body)
+	}
+
+	// Tenants without a tenant signing certificate use the common signing
+	// certificate.
+	_, signingBody := doCACertificateRequest(t, "/ca/signing.pem", "")
+	if !bytes.HasPrefix(body, signingBody) {
+		t.Errorf("Expected the common signing certificate in the chain")
+	}
+}
+
+func TestGatewayCACertificates(t *testing.T) {
+	tenantID := createGatewayTestTenant(t)
+
+	code, body := doGatewayRequest(t, http.MethodGet,
+		"/tenants/"+tenantID+"/ca_certificates?format=pem_chain", nil)
+	if code != http.StatusOK {
+		t.Fatalf("Request failed with status %d: %s", code, body)
+	}
+	var response pb.GetCACertificatesResponse
+	decodeGatewayResponse(t, body, &response)
+	if (len(response.TenantSigningCertificate) == 0) ||
+		(len(response.Chain) != 2) || (len(response.Bundle) == 0) {
+		t.Errorf("Expected the tenant signing certificate chain: %s", body)
+	}
+
+	code, _ = doGatewayRequest(t, http.MethodGet,
+		"/ca_certificates?format=unknown", nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, code)
+	}
+}
@@ -0,0 +1,79 @@
+// package github.com/HPInc/krypton-ca/service/rest
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Applies the rate limits of the CA service to enrollment requests received at
+// the CA's REST endpoint over EST, SCEP and ACME, so that devices enrolling
+// over these protocols share the per-tenant and per-caller quotas applied to
+// RPC requests.
+package rest
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Protocols over which enrollment requests are received, used to name the
+// operation reported when a request is throttled.
+const (
+	enrollmentProtocolEst  = "est"
+	enrollmentProtocolScep = "scep"
+)
+
+// enrollmentRateLimiter - applies the rate limits of the CA service to
+// enrollment requests received by the REST server. This is also the rate
+// limiter used by the ACME server.
+type enrollmentRateLimiter struct{}
+
+// AllowRequest - checks whether the enrollment request received on behalf of
+// the tenant is permitted by the rate limits of the CA service. If not, the
+// time after which the caller may retry is returned.
+func (enrollmentRateLimiter) AllowRequest(r *http.Request, operation string,
+	tenantID string) (bool, time.Duration) {
+	return caService.AllowRequest(newCallerContext(r), operation, tenantID)
+}
+
+// Returns a context identifying the caller of a REST request by its network
+// address and TLS client certificate, as for requests received at the gRPC
+// endpoint.
+func newCallerContext(r *http.Request) context.Context {
+	var addr net.Addr
+	if tcpAddr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		addr = tcpAddr
+	}
+	return rpc.NewCallerContext(r.Context(), addr, r.TLS)
+}
+
+// Check whether an EST or SCEP enrollment request is permitted by the rate
+// limits of the CA service. If not, the caller is asked to retry later, the
+// request is counted using the specified metric and false is returned.
+func allowEnrollmentRequest(w http.ResponseWriter, r *http.Request,
+	protocol string, operation string, tenantID string,
+	requests *prometheus.CounterVec) bool {
+	ok, delay := enrollmentRateLimiter{}.AllowRequest(r,
+		protocol+"/"+operation, tenantID)
+	if ok {
+		return true
+	}
+
+	requests.WithLabelValues(operation,
+		strconv.Itoa(http.StatusTooManyRequests)).Inc()
+	w.Header().Set(headerRetryAfter, retryAfterSeconds(delay))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests)+
+		": request rate limit exceeded", http.StatusTooManyRequests)
+	return false
+}
+
+// Returns the value of the Retry-After header asking callers to retry after
+// the specified delay, rounded up to whole seconds.
+func retryAfterSeconds(delay time.Duration) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(delay.Seconds()))))
+}
@@ -0,0 +1,512 @@
+// package github.com/HPInc/krypton-ca/service/rest
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the EST (Enrollment over Secure Transport - RFC 7030) endpoints
+// served at the CA's REST endpoint. Devices can retrieve the CA certificates
+// used within their tenant, enroll to obtain a new device certificate and
+// re-enroll to renew their device certificate. Enrollment requests are
+// authenticated using HTTP basic authentication, with the enrollment password
+// of the tenant. Re-enrollment requests are authenticated using the device's
+// current certificate, presented as the client certificate during the TLS
+// handshake. Enrollment requests are subject to the rate limits of the CA.
+package rest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/gorilla/mux"
+	"go.mozilla.org/pkcs7"
+	"go.uber.org/zap"
+)
+
+const (
+	// Name of the path variable specifying the tenant in EST requests.
+	estTenantVar = "tenant"
+
+	// EST operations, used to partition metrics.
+	estOpCACerts        = "cacerts"
+	estOpSimpleEnroll   = "simpleenroll"
+	estOpSimpleReenroll = "simplereenroll"
+	estOpCsrAttrs       = "csrattrs"
+
+	// Maximum size of the body of an EST enrollment request.
+	maxEstRequestSize = 64 * 1024
+
+	// Number of seconds after which callers are asked to retry requests
+	// which failed because a dependency of the CA was unavailable.
+	estRetryAfterSeconds = 30
+
+	// Challenge returned to devices which did not authenticate, asking them
+	// to use HTTP basic authentication.
+	estAuthenticateChallenge = `Basic realm="est"`
+)
+
+var (
+	// Returned when the device could not be authenticated using the client
+	// certificate presented during the TLS handshake.
+	errEstUnauthorized = errors.New("device certificate authentication failed")
+
+	// Returned when the device did not specify the enrollment password of
+	// the tenant.
+	errEstBadEnrollmentPassword = errors.New("enrollment password authentication failed")
+
+	// Secret used to derive the enrollment password of each tenant.
+	estEnrollmentSecret []byte
+
+	// Object identifiers reported by the csrattrs endpoint. The CA requires
+	// certificate signing requests to specify an RSA public key and to be
+	// signed using SHA256 with RSA.
+	oidRsaEncryption           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSha256WithRsaEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+)
+
+// Initializes EST enrollment using the specified settings.
+func initEst(settings *config.Est) {
+	estEnrollmentSecret = []byte(settings.EnrollmentSecret)
+}
+
+// EstEnrollmentPassword - returns the password that devices within the tenant
+// must present using HTTP basic authentication to enroll using EST. This is
+// configured in the provisioning profiles deployed to the tenant's devices.
+// The password is hex encoded, and the user name is ignored.
+func EstEnrollmentPassword(enrollmentSecret string, tenantID string) string {
+	return hex.EncodeToString(
+		deriveEstEnrollmentPassword([]byte(enrollmentSecret), tenantID))
+}
+
+func deriveEstEnrollmentPassword(enrollmentSecret []byte, tenantID string) []byte {
+	mac := hmac.New(sha256.New, enrollmentSecret)
+	mac.Write([]byte(tenantID))
+	return mac.Sum(nil)
+}
+
+// GetEstCACertsHandler - returns the certificates used to sign device
+// certificates issued within the tenant (/cacerts).
+func GetEstCACertsHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)[estTenantVar]
+
+	chain, err := kmsProvider.GetSigningCertificateChain(r.Context(), tenantID)
+	if err != nil {
+		caLogger.Error("EST: Failed to get the signing certificate chain for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", r.Header.Get(headerRequestID)),
+			zap.Error(err),
+		)
+		writeEstError(w, estOpCACerts, err)
+		return
+	}
+
+	writeEstResponse(w, estOpCACerts, contentTypePkcs7Mime, chain)
+}
+
+// EstSimpleEnrollHandler - issues a new device certificate within the tenant
+// in exchange for the certificate signing request (/simpleenroll).
+func EstSimpleEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)[estTenantVar]
+	requestID := r.Header.Get(headerRequestID)
+
+	if !allowEnrollmentRequest(w, r, enrollmentProtocolEst, estOpSimpleEnroll,
+		tenantID, metrics.MetricEstRequests) {
+		return
+	}
+
+	err := authenticateEnrollment(r, tenantID)
+	if err != nil {
+		caLogger.Error("EST: Failed to authenticate the enrollment request!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		writeEstUnauthorized(w, estOpSimpleEnroll, err)
+		return
+	}
+
+	csr, err := readEstCertificateRequest(r)
+	if err != nil {
+		caLogger.Error("EST: Invalid enrollment request!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		writeEstError(w, estOpSimpleEnroll, err)
+		return
+	}
+
+	// Ensure that the tenant has not reached its device cap.
+	reservation, err := quotaManager.ReserveDevice(r.Context(), tenantID)
+	if err != nil {
+		caLogger.Error("EST: Device cap check failed for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		writeEstError(w, estOpSimpleEnroll, err)
+		return
+	}
+	defer reservation.Release()
+
+	deviceID, deviceCert, parentCerts, expiresAt, err := kmsProvider.CreateDeviceCertificate(
+		r.Context(), tenantID, csr)
+	if err != nil {
+		caLogger.Error("EST: Failed to generate device certificate!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		writeEstError(w, estOpSimpleEnroll, err)
+		return
+	}
+
+	// Track the newly issued device against the tenant's device cap.
+	_ = reservation.RecordDevice(r.Context(), deviceID, expiresAt)
+
+	response, err := newEstCertsOnlyResponse(deviceCert, parentCerts)
+	if err != nil {
+		caLogger.Error("EST: Failed to build the enrollment response!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		writeEstError(w, estOpSimpleEnroll, err)
+		return
+	}
+
+	metrics.MetricDeviceCertificatesIssued.Inc()
+	caLogger.Info("EST: Issued a device certificate.",
+		zap.String("Tenant ID:", tenantID),
+		zap.String("Device ID:", deviceID),
+		zap.String("Request ID:", requestID),
+	)
+	writeEstResponse(w, estOpSimpleEnroll, contentTypePkcs7CertsOnly, response)
+}
+
+// EstSimpleReenrollHandler - renews the device certificate of the device
+// authenticated using its current device certificate (/simplereenroll). The
+// device ID asserted in the current certificate is retained.
+func EstSimpleReenrollHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)[estTenantVar]
+	requestID := r.Header.Get(headerRequestID)
+
+	if !allowEnrollmentRequest(w, r, enrollmentProtocolEst,
+		estOpSimpleReenroll, tenantID, metrics.MetricEstRequests) {
+		return
+	}
+
+	currentCert, err := authenticateDevice(r, tenantID)
+	if err != nil {
+		caLogger.Error("EST: Failed to authenticate the device!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		if errors.Is(err, errEstUnauthorized) {
+			writeEstUnauthorized(w, estOpSimpleReenroll, err)
+			return
+		}
+		writeEstError(w, estOpSimpleReenroll, err)
+		return
+	}
+	deviceID := currentCert.Subject.CommonName
+
+	csr, err := readEstCertificateRequest(r)
+	if err != nil {
+		caLogger.Error("EST: Invalid re-enrollment request!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Device ID:", deviceID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		writeEstError(w, estOpSimpleReenroll, err)
+		return
+	}
+
+	// Devices which are already recorded within the tenant are counted against
+	// the tenant's device cap, so capacity is only reserved for unknown
+	// devices.
+	reservation, err := quotaManager.ReserveRenewal(r.Context(), tenantID,
+		deviceID)
+	if err != nil {
+		caLogger.Error("EST: Device cap check failed for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Device ID:", deviceID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		writeEstError(w, estOpSimpleReenroll, err)
+		return
+	}
+	defer reservation.Release()
+
+	_, deviceCert, parentCerts, expiresAt, err := kmsProvider.RenewDeviceCertificate(
+		r.Context(), tenantID, deviceID, csr)
+	if err != nil {
+		caLogger.Error("EST: Failed to renew device certificate!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Device ID:", deviceID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		writeEstError(w, estOpSimpleReenroll, err)
+		return
+	}
+
+	// Extend the lifetime of the device record tracked against the tenant's
+	// device cap. The renewed device certificate is not returned unless the
+	// device is recorded, so that it is counted against the device cap.
+	err = reservation.RecordDevice(r.Context(), deviceID, expiresAt)
+	if err != nil {
+		caLogger.Error("EST: Failed to record the device within the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Device ID:", deviceID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		writeEstError(w, estOpSimpleReenroll, err)
+		return
+	}
+
+	response, err := newEstCertsOnlyResponse(deviceCert, parentCerts)
+	if err != nil {
+		caLogger.Error("EST: Failed to build the re-enrollment response!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Device ID:", deviceID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		writeEstError(w, estOpSimpleReenroll, err)
+		return
+	}
+
+	metrics.MetricDeviceCertificatesRenewed.Inc()
+	caLogger.Info("EST: Renewed a device certificate.",
+		zap.String("Tenant ID:", tenantID),
+		zap.String("Device ID:", deviceID),
+		zap.String("Request ID:", requestID),
+	)
+	writeEstResponse(w, estOpSimpleReenroll, contentTypePkcs7CertsOnly, response)
+}
+
+// GetEstCsrAttrsHandler - returns the attributes the CA expects to be used by
+// devices when generating certificate signing requests (/csrattrs).
+func GetEstCsrAttrsHandler(w http.ResponseWriter, r *http.Request) {
+	csrAttrs, err := asn1.Marshal([]asn1.ObjectIdentifier{
+		oidRsaEncryption,
+		oidSha256WithRsaEncryption,
+	})
+	if err != nil {
+		caLogger.Error("EST: Failed to encode the CSR attributes!",
+			zap.Error(err),
+		)
+		writeEstError(w, estOpCsrAttrs, err)
+		return
+	}
+
+	writeEstResponse(w, estOpCsrAttrs, contentTypeCsrAttrs, csrAttrs)
+}
+
+// Read the base64 encoded PKCS#10 certificate signing request from the body
+// of an EST enrollment request.
+func readEstCertificateRequest(r *http.Request) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get(headerContentType))
+	if (err != nil) || (mediaType != contentTypePkcs10) {
+		return nil, fmt.Errorf("%w: content type must be %s",
+			common.ErrInvalidCSR, contentTypePkcs10)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEstRequestSize+1))
+	if err != nil {
+		return nil, caerrors.Wrap(caerrors.InvalidInput,
+			"failed to read the request body", err)
+	}
+	if len(body) > maxEstRequestSize {
+		return nil, fmt.Errorf("%w: request body is too large",
+			common.ErrInvalidCSR)
+	}
+
+	// The base64 encoded CSR may be split across multiple lines.
+	csr, err := base64.StdEncoding.DecodeString(strings.Join(
+		strings.Fields(string(body)), ""))
+	if (err != nil) || (len(csr) == 0) {
+		return nil, fmt.Errorf("%w: csr must be base64 encoded",
+			common.ErrInvalidCSR)
+	}
+	return csr, nil
+}
+
+// Authenticate an enrollment request using the enrollment password of the
+// tenant, presented using HTTP basic authentication.
+func authenticateEnrollment(r *http.Request, tenantID string) error {
+	_, password, ok := r.BasicAuth()
+	if !ok {
+		return fmt.Errorf("%w: HTTP basic authentication required",
+			errEstBadEnrollmentPassword)
+	}
+
+	decoded, err := hex.DecodeString(password)
+	if (err != nil) || (len(estEnrollmentSecret) == 0) || !hmac.Equal(decoded,
+		deriveEstEnrollmentPassword(estEnrollmentSecret, tenantID)) {
+		return errEstBadEnrollmentPassword
+	}
+	return nil
+}
+
+// Authenticate the device using the client certificate presented during the
+// TLS handshake. The certificate must be a device certificate issued within
+// the specified tenant, which chains up to the CA.
+func authenticateDevice(r *http.Request,
+	tenantID string) (*x509.Certificate, error) {
+	if (r.TLS == nil) || (len(r.TLS.PeerCertificates) == 0) {
+		return nil, fmt.Errorf("%w: client certificate required",
+			errEstUnauthorized)
+	}
+
+	deviceCert := r.TLS.PeerCertificates[0]
+	if (deviceCert.Subject.CommonName == "") ||
+		(len(deviceCert.Subject.Organization) == 0) ||
+		(deviceCert.Subject.Organization[0] != tenantID) {
+		return nil, fmt.Errorf("%w: certificate was not issued within the tenant",
+			errEstUnauthorized)
+	}
+	if !isDeviceCertificate(deviceCert) {
+		return nil, fmt.Errorf("%w: not a device certificate",
+			errEstUnauthorized)
+	}
+
+	// Verify the device certificate was issued by the signing certificate
+	// used within the tenant.
+	chain, err := kmsProvider.GetSigningCertificateChain(r.Context(), tenantID)
+	if err != nil {
+		return nil, err
+	}
+	parsedChain, err := pkcs7.Parse(chain)
+	if err != nil {
+		return nil, caerrors.Wrap(caerrors.Internal,
+			"failed to parse the signing certificate chain", err)
+	}
+
+	roots := x509.NewCertPool()
+	intermediates := x509.NewCertPool()
+	for _, cert := range parsedChain.Certificates {
+		if bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+			roots.AddCert(cert)
+		} else {
+			intermediates.AddCert(cert)
+		}
+	}
+
+	_, err = deviceCert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errEstUnauthorized, err)
+	}
+	return deviceCert, nil
+}
+
+// Checks whether the certificate is a device certificate issued by the CA.
+func isDeviceCertificate(cert *x509.Certificate) bool {
+	for _, extension := range cert.Extensions {
+		if extension.Id.Equal(common.DeviceCertificateOid) {
+			return true
+		}
+	}
+	return false
+}
+
+// Build the PKCS#7 degenerate "certs only" structure returned in response to
+// enrollment requests. It contains the issued device certificate followed by
+// the certificate chain returned by the KMS provider.
+func newEstCertsOnlyResponse(deviceCert []byte,
+	parentCerts []byte) ([]byte, error) {
+	parsedParents, err := pkcs7.Parse(parentCerts)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := []byte{}
+	certs = append(certs, deviceCert...)
+	for _, cert := range parsedParents.Certificates {
+		certs = append(certs, cert.Raw...)
+	}
+	return pkcs7.DegenerateCertificate(certs)
+}
+
+// Write a successful EST response. EST responses are base64 encoded.
+func writeEstResponse(w http.ResponseWriter, operation string,
+	contentType string, body []byte) {
+	metrics.MetricEstRequests.WithLabelValues(operation,
+		strconv.Itoa(http.StatusOK)).Inc()
+
+	w.Header().Set(headerContentType, contentType)
+	w.Header().Set(headerContentTransferEncoding, transferEncodingBase64)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString(body)))
+}
+
+// HTTP status code reported when the client cancelled the request, as used by
+// the gRPC gateway for the Canceled status code.
+const httpStatusClientClosedRequest = 499
+
+// HTTP status codes reported for each category of error.
+var categoryHttpStatus = map[caerrors.Category]int{
+	caerrors.InvalidInput:          http.StatusBadRequest,
+	caerrors.NotFound:              http.StatusNotFound,
+	caerrors.Conflict:              http.StatusConflict,
+	caerrors.DependencyUnavailable: http.StatusServiceUnavailable,
+	caerrors.PolicyViolation:       http.StatusForbidden,
+	caerrors.Canceled:              httpStatusClientClosedRequest,
+	caerrors.Internal:              http.StatusInternalServerError,
+}
+
+// Write an EST error response to a request which could not be authenticated.
+// Devices are asked to authenticate using HTTP basic authentication or their
+// client certificate.
+func writeEstUnauthorized(w http.ResponseWriter, operation string, err error) {
+	metrics.MetricEstRequests.WithLabelValues(operation,
+		strconv.Itoa(http.StatusUnauthorized)).Inc()
+	w.Header().Set(headerWWWAuthenticate, estAuthenticateChallenge)
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
+
+// Write an EST error response, with the HTTP status code chosen based on the
+// category of the error.
+func writeEstError(w http.ResponseWriter, operation string, err error) {
+	category := caerrors.CategoryOf(err)
+	statusCode := categoryHttpStatus[category]
+	metrics.MetricEstRequests.WithLabelValues(operation,
+		strconv.Itoa(statusCode)).Inc()
+
+	// Describe the failure to the caller, except for internal errors whose
+	// details are only logged.
+	message := http.StatusText(statusCode)
+	if detail := caerrors.MessageOf(err); (detail != "") &&
+		(category != caerrors.Internal) {
+		message = message + ": " + detail
+	}
+
+	if category == caerrors.DependencyUnavailable {
+		w.Header().Set(headerRetryAfter, strconv.Itoa(estRetryAfterSeconds))
+	}
+	http.Error(w, message, statusCode)
+}
@@ -0,0 +1,386 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/HPInc/krypton-ca/service/certmgr"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/HPInc/krypton-ca/service/health"
+	"github.com/HPInc/krypton-ca/service/rpc"
+	"github.com/google/uuid"
+	"go.mozilla.org/pkcs7"
+	"go.uber.org/zap"
+)
+
+const testEstEnrollmentSecret = "est-test-enrollment-secret"
+
+var (
+	gTestServer   *httptest.Server
+	gTestTenantID string
+	gTestCfgMgr   *config.ConfigMgr
+)
+
+// Generate a device key and a base64 encoded CSR to be sent in EST requests.
+func newTestDeviceCsr(t *testing.T) (*rsa.PrivateKey, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate device key: %v", err)
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader,
+		&x509.CertificateRequest{SignatureAlgorithm: x509.SHA256WithRSA}, key)
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+	return key, []byte(base64.StdEncoding.EncodeToString(csr))
+}
+
+// Create an EST request, authenticated using the enrollment password of the
+// test tenant.
+func newEstRequest(t *testing.T, method string, operation string,
+	contentType string, body []byte) *http.Request {
+	request, err := http.NewRequest(method, fmt.Sprintf("%s/.well-known/est/%s/%s",
+		gTestServer.URL, gTestTenantID, operation), bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if contentType != "" {
+		request.Header.Set(headerContentType, contentType)
+	}
+	request.SetBasicAuth("device",
+		EstEnrollmentPassword(testEstEnrollmentSecret, gTestTenantID))
+	return request
+}
+
+// Send an EST request and return the response status and decoded body.
+func doEstRequest(t *testing.T, client *http.Client, method string,
+	operation string, contentType string, body []byte) (int, []byte) {
+	request := newEstRequest(t, method, operation, contentType, body)
+
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("EST request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("Failed to read the response: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return response.StatusCode, responseBody
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(responseBody))
+	if err != nil {
+		t.Fatalf("Response was not base64 encoded: %v", err)
+	}
+	return response.StatusCode, decoded
+}
+
+// Parse the certificates returned in a PKCS#7 certs-only response.
+func parseCertsOnlyResponse(t *testing.T, body []byte) []*x509.Certificate {
+	p7, err := pkcs7.Parse(body)
+	if err != nil {
+		t.Fatalf("Failed to parse the PKCS#7 response: %v", err)
+	}
+	return p7.Certificates
+}
+
+// Enroll a new device and return the device key and the issued certificate.
+func enrollTestDevice(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	key, csr := newTestDeviceCsr(t)
+	status, body := doEstRequest(t, gTestServer.Client(), http.MethodPost,
+		"simpleenroll", contentTypePkcs10, csr)
+	if status != http.StatusOK {
+		t.Fatalf("Enrollment failed with status %d: %s", status, body)
+	}
+
+	certs := parseCertsOnlyResponse(t, body)
+	if len(certs) != 3 {
+		t.Fatalf("Expected the device certificate and its chain, got %d certificates",
+			len(certs))
+	}
+	return key, certs[0]
+}
+
+func TestEstCACerts(t *testing.T) {
+	status, body := doEstRequest(t, gTestServer.Client(), http.MethodGet,
+		"cacerts", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("cacerts failed with status %d: %s", status, body)
+	}
+
+	certs := parseCertsOnlyResponse(t, body)
+	if len(certs) != 2 {
+		t.Fatalf("Expected the signing and CA certificates, got %d certificates",
+			len(certs))
+	}
+}
+
+func TestEstSimpleEnroll(t *testing.T) {
+	_, deviceCert := enrollTestDevice(t)
+	if (len(deviceCert.Subject.Organization) == 0) ||
+		(deviceCert.Subject.Organization[0] != gTestTenantID) {
+		t.Errorf("Device certificate was not issued within the tenant: %v",
+			deviceCert.Subject)
+	}
+	if deviceCert.Subject.CommonName == "" {
+		t.Errorf("Device certificate does not specify a device ID")
+	}
+}
+
+func TestEstSimpleEnroll_Unauthenticated(t *testing.T) {
+	_, csr := newTestDeviceCsr(t)
+
+	// Requests must present the enrollment password of the tenant.
+	for _, password := range []string{"",
+		EstEnrollmentPassword(testEstEnrollmentSecret, uuid.NewString())} {
+		request := newEstRequest(t, http.MethodPost, "simpleenroll",
+			contentTypePkcs10, csr)
+		if password == "" {
+			request.Header.Del("Authorization")
+		} else {
+			request.SetBasicAuth("device", password)
+		}
+
+		response, err := gTestServer.Client().Do(request)
+		if err != nil {
+			t.Fatalf("EST request failed: %v", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected status %d, got %d", http.StatusUnauthorized,
+				response.StatusCode)
+		}
+		if response.Header.Get(headerWWWAuthenticate) == "" {
+			t.Errorf("No authentication challenge was returned")
+		}
+	}
+}
+
+func TestEstSimpleEnroll_RateLimited(t *testing.T) {
+	cfgMgr := config.NewConfigMgr(caLogger, common.ServiceName)
+	if !cfgMgr.Load(true) {
+		t.Fatalf("Failed to load configuration")
+	}
+	*cfgMgr.GetRateLimitConfig() = config.RateLimit{
+		Enabled: true,
+		Tenant:  config.RateLimitQuota{RequestsPerSecond: 0.01, Burst: 1},
+		Caller:  config.RateLimitQuota{RequestsPerSecond: 0.01, Burst: 10},
+	}
+
+	// Enrollment requests are subject to the rate limits of the CA service.
+	defaultService := caService
+	caService = rpc.NewCertificateAuthorityService(caLogger, cfgMgr,
+		kmsProvider, nil)
+	defer func() { caService = defaultService }()
+
+	enrollTestDevice(t)
+	_, csr := newTestDeviceCsr(t)
+	response, err := gTestServer.Client().Do(newEstRequest(t, http.MethodPost,
+		"simpleenroll", contentTypePkcs10, csr))
+	if err != nil {
+		t.Fatalf("EST request failed: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests,
+			response.StatusCode)
+	}
+	if response.Header.Get(headerRetryAfter) == "" {
+		t.Errorf("No Retry-After header was returned")
+	}
+}
+
+func TestEstSimpleEnroll_InvalidCsr(t *testing.T) {
+	status, _ := doEstRequest(t, gTestServer.Client(), http.MethodPost,
+		"simpleenroll", contentTypePkcs10,
+		[]byte(base64.StdEncoding.EncodeToString([]byte("not a csr"))))
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, status)
+	}
+
+	// The CSR must be base64 encoded.
+	status, _ = doEstRequest(t, gTestServer.Client(), http.MethodPost,
+		"simpleenroll", contentTypePkcs10, []byte("%%%"))
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, status)
+	}
+}
+
+// Initialize a certificate authority with its CA certificate, signing keys and
+// certificate store in a temporary working directory, and use it to serve
+// requests for the duration of the test.
+func useTestCertificateAuthority(t *testing.T) {
+	t.Chdir(t.TempDir())
+	provider, store, err := certmgr.Init(caLogger, gTestCfgMgr)
+	if err != nil {
+		t.Fatalf("Failed to initialize the certificate authority: %v", err)
+	}
+
+	previousProvider, previousQuotaManager := kmsProvider, quotaManager
+	kmsProvider = provider
+	quotaManager = quota.NewManager(caLogger, store, &config.DeviceQuota{})
+	t.Cleanup(func() {
+		kmsProvider, quotaManager = previousProvider, previousQuotaManager
+		provider.Shutdown()
+		store.Shutdown()
+	})
+}
+
+func TestEstSimpleReenroll(t *testing.T) {
+	useTestCertificateAuthority(t)
+	key, deviceCert := enrollTestDevice(t)
+
+	// Authenticate using the device certificate issued during enrollment.
+	client := gTestServer.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.Certificates = []tls.Certificate{{
+		Certificate: [][]byte{deviceCert.Raw},
+		PrivateKey:  key,
+	}}
+	client = &http.Client{Transport: transport}
+
+	_, csr := newTestDeviceCsr(t)
+	status, body := doEstRequest(t, client, http.MethodPost, "simplereenroll",
+		contentTypePkcs10, csr)
+	if status != http.StatusOK {
+		t.Fatalf("Re-enrollment failed with status %d: %s", status, body)
+	}
+
+	certs := parseCertsOnlyResponse(t, body)
+	if certs[0].Subject.CommonName != deviceCert.Subject.CommonName {
+		t.Errorf("Device ID changed on re-enrollment: %s != %s",
+			certs[0].Subject.CommonName, deviceCert.Subject.CommonName)
+	}
+}
+
+func TestEstSimpleReenroll_NoClientCertificate(t *testing.T) {
+	_, csr := newTestDeviceCsr(t)
+	status, _ := doEstRequest(t, gTestServer.Client(), http.MethodPost,
+		"simplereenroll", contentTypePkcs10, csr)
+	if status != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, status)
+	}
+}
+
+func TestEstSimpleReenroll_UntrustedClientCertificate(t *testing.T) {
+	// Present a self-signed certificate claiming to be a device within the
+	// tenant.
+	key, _ := newTestDeviceCsr(t)
+	template := &x509.Certificate{}
+	template.SerialNumber, _ = common.NewSerialNumber()
+	template.Subject.CommonName = uuid.NewString()
+	template.Subject.Organization = []string{gTestTenantID}
+	template.NotAfter = template.NotBefore.AddDate(100, 0, 0)
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template,
+		&key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed certificate: %v", err)
+	}
+
+	client := gTestServer.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.Certificates = []tls.Certificate{{
+		Certificate: [][]byte{certBytes},
+		PrivateKey:  key,
+	}}
+	client = &http.Client{Transport: transport}
+
+	_, csr := newTestDeviceCsr(t)
+	status, _ := doEstRequest(t, client, http.MethodPost, "simplereenroll",
+		contentTypePkcs10, csr)
+	if status != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, status)
+	}
+}
+
+func TestEstCsrAttrs(t *testing.T) {
+	status, body := doEstRequest(t, gTestServer.Client(), http.MethodGet,
+		"csrattrs", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("csrattrs failed with status %d: %s", status, body)
+	}
+	if len(body) == 0 {
+		t.Errorf("No CSR attributes were returned")
+	}
+}
+
+func TestMain(m *testing.M) {
+	logger, err := zap.NewProduction(zap.AddCaller())
+	if err != nil {
+		fmt.Println("Failed to intialize structured logging for the REST tests!")
+		os.Exit(2)
+	}
+	caLogger = logger
+
+	cfgMgr := config.NewConfigMgr(caLogger, common.ServiceName)
+	if !cfgMgr.Load(true) {
+		caLogger.Error("Failed to load configuration. Exiting!")
+		os.Exit(2)
+	}
+	gTestCfgMgr = cfgMgr
+
+	provider, store, err := certmgr.Init(caLogger, cfgMgr)
+	if err != nil {
+		caLogger.Error("Failed to initialize the certificate authority!",
+			zap.Error(err),
+		)
+		os.Exit(2)
+	}
+	kmsProvider = provider
+	quotaManager = quota.NewManager(caLogger, store, &config.DeviceQuota{})
+	caService = rpc.NewCertificateAuthorityService(caLogger, cfgMgr, provider,
+		store)
+	gTestTenantID = uuid.NewString()
+	initEst(&config.Est{Enabled: true, EnrollmentSecret: testEstEnrollmentSecret})
+	healthChecker = health.NewChecker(caLogger, provider, store)
+	healthChecker.Start()
+
+	err = initTestScepRegistrationAuthority()
+	if err != nil {
+		caLogger.Error("Failed to create the SCEP RA certificate!",
+			zap.Error(err),
+		)
+		os.Exit(2)
+	}
+
+	// Serve the gateway, EST and SCEP routes over TLS, requesting client
+	// certificates.
+	routeList := append(routes{}, registeredRoutes...)
+	routeList = append(routeList, gatewayRoutes...)
+	routeList = append(routeList, gatewayAdminRoutes...)
+	routeList = append(routeList, estRoutes...)
+	routeList = append(routeList, scepRoutes...)
+	gTestServer = httptest.NewUnstartedServer(initRequestRouter(routeList))
+	gTestServer.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	gTestServer.StartTLS()
+
+	err = initTestGatewayAdmin()
+	if err != nil {
+		caLogger.Error("Failed to create the gateway admin client certificate!",
+			zap.Error(err),
+		)
+		os.Exit(2)
+	}
+
+	retCode := m.Run()
+	gTestServer.Close()
+	healthChecker.Shutdown()
+	store.Shutdown()
+	os.Exit(retCode)
+}
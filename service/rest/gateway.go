@@ -0,0 +1,614 @@
+// package github.com/HPInc/krypton-ca/service/rest
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the REST/JSON gateway served at the CA's REST endpoint under
+// /api/v1/. Each of the RPCs served by the CA gRPC server is exposed as a REST
+// resource, for callers such as the admin portal and scripts which are unable
+// to use gRPC. Requests are translated to RPC requests and processed in-process
+// by the RPC handlers, so they are validated in the same manner as requests
+// received at the gRPC endpoint. Responses are returned as the JSON encoding of
+// the RPC response, and failures are reported using the JSON encoding of the
+// gRPC status, with the HTTP status code chosen based on the gRPC status code.
+// Routes which create or delete tenants and set their device quotas require
+// administrators to authenticate using TLS client certificates.
+package rest
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/HPInc/krypton-ca/service/rpc"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// Names of the path variables specifying the tenant and device in
+	// gateway requests.
+	gatewayTenantVar = "tenant"
+	gatewayDeviceVar = "device"
+
+	// Name of the query parameter specifying the message in ping requests.
+	gatewayPingMessageParam = "message"
+
+	// Name of the query parameter specifying the certificate format in
+	// requests for CA certificates.
+	gatewayFormatParam = "format"
+
+	// Maximum size of the body of a gateway request.
+	maxGatewayRequestSize = 64 * 1024
+
+	// Maximum size of the body of a batch device certificate request.
+	maxGatewayBatchRequestSize = 4 * 1024 * 1024
+
+	// PEM block types accepted for certificate signing requests.
+	pemTypeCertificateRequest    = "CERTIFICATE REQUEST"
+	pemTypeNewCertificateRequest = "NEW CERTIFICATE REQUEST"
+
+	// Prefix of the names of certificate formats, which may be omitted by
+	// callers.
+	certificateFormatPrefix = "CERTIFICATE_FORMAT_"
+)
+
+var (
+	// Service used to process requests received by the gateway.
+	caService *rpc.CertificateAuthorityServer
+
+	// CA certificates used to verify the client certificates of
+	// administrators calling the gateway routes which modify tenants. This is
+	// nil if no client CA is configured, in which case those routes are not
+	// registered.
+	adminClientCAs *x509.CertPool
+
+	// Returned when no CA certificates were found in the client CA file.
+	errGatewayNoClientCAs = errors.New("no CA certificates found in the client CA file")
+
+	// Returned when the CSR specified in a gateway request is not a PEM
+	// encoded or base64 encoded DER certificate signing request.
+	errGatewayInvalidCsr = errors.New("csr must be a PEM encoded or base64 encoded DER certificate signing request")
+
+	// Returned when the certificate format specified in a gateway request is
+	// not supported.
+	errGatewayInvalidFormat = errors.New("unsupported certificate format")
+
+	// Options used to encode RPC responses and status errors.
+	gatewayMarshalOptions = protojson.MarshalOptions{UseProtoNames: true}
+)
+
+// Body of requests to create a tenant signing certificate.
+type gatewayCreateTenantSigningCertificateRequest struct {
+	Name       string `json:"name"`
+	DomainName string `json:"domain_name"`
+}
+
+// Body of requests to create or renew a device certificate. The CSR may be
+// PEM encoded, or the base64 encoding of the DER encoded CSR. The format in
+// which the device certificate is returned is specified by name (for example
+// PEM_CHAIN or CERTIFICATE_FORMAT_PEM_CHAIN).
+type gatewayDeviceCertificateRequest struct {
+	Csr    string `json:"csr"`
+	Format string `json:"format"`
+}
+
+// Body of requests to issue device certificates to a batch of devices. Each
+// CSR is encoded as in requests to create a device certificate.
+type gatewayDeviceCertificatesRequest struct {
+	Items []struct {
+		RequestID string `json:"request_id"`
+		Csr       string `json:"csr"`
+	} `json:"items"`
+	Format string `json:"format"`
+}
+
+// Body of requests to set the device quota of a tenant.
+type gatewaySetTenantQuotaRequest struct {
+	MaxDevices     int64 `json:"max_devices"`
+	ResetToDefault bool  `json:"reset_to_default"`
+}
+
+// GatewayCreateTenantSigningCertificateHandler - creates the signing
+// certificate for the tenant (CreateTenantSigningCertificate RPC).
+func GatewayCreateTenantSigningCertificateHandler(w http.ResponseWriter,
+	r *http.Request) {
+	if !authenticateGatewayAdmin(w, r, rpc.MethodCreateTenantSigningCertificate) {
+		return
+	}
+	var body gatewayCreateTenantSigningCertificateRequest
+	if !readGatewayRequest(w, r, rpc.MethodCreateTenantSigningCertificate, &body) {
+		return
+	}
+
+	serveGatewayRequest(w, r, rpc.MethodCreateTenantSigningCertificate,
+		http.StatusCreated, &pb.CreateTenantSigningCertificateRequest{
+			Header:     newGatewayRequestHeader(r),
+			Version:    rpc.CaProtocolVersion,
+			Tid:        mux.Vars(r)[gatewayTenantVar],
+			Name:       body.Name,
+			DomainName: body.DomainName,
+		}, caService.CreateTenantSigningCertificate)
+}
+
+// GatewayGetTenantSigningCertificateHandler - returns the signing certificate
+// of the tenant (GetTenantSigningCertificate RPC).
+func GatewayGetTenantSigningCertificateHandler(w http.ResponseWriter,
+	r *http.Request) {
+	serveGatewayRequest(w, r, rpc.MethodGetTenantSigningCertificate,
+		http.StatusOK, &pb.GetTenantSigningCertificateRequest{
+			Header:  newGatewayRequestHeader(r),
+			Version: rpc.CaProtocolVersion,
+			Tid:     mux.Vars(r)[gatewayTenantVar],
+		}, caService.GetTenantSigningCertificate)
+}
+
+// GatewayDeleteTenantSigningCertificateHandler - deletes the signing
+// certificate of the tenant (DeleteTenantSigningCertificate RPC).
+func GatewayDeleteTenantSigningCertificateHandler(w http.ResponseWriter,
+	r *http.Request) {
+	if !authenticateGatewayAdmin(w, r, rpc.MethodDeleteTenantSigningCertificate) {
+		return
+	}
+	serveGatewayRequest(w, r, rpc.MethodDeleteTenantSigningCertificate,
+		http.StatusOK, &pb.DeleteTenantSigningCertificateRequest{
+			Header:  newGatewayRequestHeader(r),
+			Version: rpc.CaProtocolVersion,
+			Tid:     mux.Vars(r)[gatewayTenantVar],
+		}, caService.DeleteTenantSigningCertificate)
+}
+
+// GatewayCreateDeviceCertificateHandler - issues a device certificate to a
+// new device within the tenant (CreateDeviceCertificate RPC).
+func GatewayCreateDeviceCertificateHandler(w http.ResponseWriter,
+	r *http.Request) {
+	var body gatewayDeviceCertificateRequest
+	if !readGatewayRequest(w, r, rpc.MethodCreateDeviceCertificate, &body) {
+		return
+	}
+	csr, format, ok := readGatewayDeviceCertificateRequest(w, r,
+		rpc.MethodCreateDeviceCertificate, &body)
+	if !ok {
+		return
+	}
+
+	serveGatewayRequest(w, r, rpc.MethodCreateDeviceCertificate,
+		http.StatusCreated, &pb.CreateDeviceCertificateRequest{
+			Header:  newGatewayRequestHeader(r),
+			Version: rpc.CaProtocolVersion,
+			Tid:     mux.Vars(r)[gatewayTenantVar],
+			Csr:     csr,
+			Format:  format,
+		}, caService.CreateDeviceCertificate)
+}
+
+// GatewayCreateDeviceCertificatesHandler - issues device certificates to a
+// batch of new devices within the tenant (CreateDeviceCertificates RPC).
+func GatewayCreateDeviceCertificatesHandler(w http.ResponseWriter,
+	r *http.Request) {
+	// Allow longer than other requests to read the batch and to write the
+	// response, since both are proportional to the number of CSRs.
+	controller := http.NewResponseController(w)
+	_ = controller.SetReadDeadline(time.Now().Add(batchReadTimeout))
+	_ = controller.SetWriteDeadline(time.Now().Add(batchWriteTimeout))
+
+	var body gatewayDeviceCertificatesRequest
+	if !readGatewayRequestBody(w, r, rpc.MethodCreateDeviceCertificates,
+		maxGatewayBatchRequestSize, &body) {
+		return
+	}
+
+	items := make([]*pb.DeviceCertificateRequestItem, 0, len(body.Items))
+	for i, item := range body.Items {
+		csr, err := decodeGatewayCsr(item.Csr)
+		if err != nil {
+			writeGatewayFieldError(w, r, rpc.MethodCreateDeviceCertificates,
+				"items["+strconv.Itoa(i)+"].csr", err)
+			return
+		}
+		items = append(items, &pb.DeviceCertificateRequestItem{
+			RequestId: item.RequestID,
+			Csr:       csr,
+		})
+	}
+
+	format, err := decodeGatewayCertificateFormat(body.Format)
+	if err != nil {
+		writeGatewayFieldError(w, r, rpc.MethodCreateDeviceCertificates,
+			"format", err)
+		return
+	}
+
+	serveGatewayRequest(w, r, rpc.MethodCreateDeviceCertificates,
+		http.StatusOK, &pb.CreateDeviceCertificatesRequest{
+			Header:  newGatewayRequestHeader(r),
+			Version: rpc.CaProtocolVersion,
+			Tid:     mux.Vars(r)[gatewayTenantVar],
+			Items:   items,
+			Format:  format,
+		}, caService.CreateDeviceCertificates)
+}
+
+// GatewayRenewDeviceCertificateHandler - renews the device certificate of a
+// device within the tenant (RenewDeviceCertificate RPC).
+func GatewayRenewDeviceCertificateHandler(w http.ResponseWriter,
+	r *http.Request) {
+	var body gatewayDeviceCertificateRequest
+	if !readGatewayRequest(w, r, rpc.MethodRenewDeviceCertificate, &body) {
+		return
+	}
+	csr, format, ok := readGatewayDeviceCertificateRequest(w, r,
+		rpc.MethodRenewDeviceCertificate, &body)
+	if !ok {
+		return
+	}
+
+	serveGatewayRequest(w, r, rpc.MethodRenewDeviceCertificate,
+		http.StatusOK, &pb.RenewDeviceCertificateRequest{
+			Header:   newGatewayRequestHeader(r),
+			Version:  rpc.CaProtocolVersion,
+			Tid:      mux.Vars(r)[gatewayTenantVar],
+			DeviceId: mux.Vars(r)[gatewayDeviceVar],
+			Csr:      csr,
+			Format:   format,
+		}, caService.RenewDeviceCertificate)
+}
+
+// GatewayGetTenantQuotaHandler - returns the device quota of the tenant
+// (GetTenantQuota RPC).
+func GatewayGetTenantQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	serveGatewayRequest(w, r, rpc.MethodGetTenantQuota,
+		http.StatusOK, &pb.GetTenantQuotaRequest{
+			Header:  newGatewayRequestHeader(r),
+			Version: rpc.CaProtocolVersion,
+			Tid:     mux.Vars(r)[gatewayTenantVar],
+		}, caService.GetTenantQuota)
+}
+
+// GatewaySetTenantQuotaHandler - sets the device quota of the tenant
+// (SetTenantQuota RPC).
+func GatewaySetTenantQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	if !authenticateGatewayAdmin(w, r, rpc.MethodSetTenantQuota) {
+		return
+	}
+	var body gatewaySetTenantQuotaRequest
+	if !readGatewayRequest(w, r, rpc.MethodSetTenantQuota, &body) {
+		return
+	}
+
+	serveGatewayRequest(w, r, rpc.MethodSetTenantQuota,
+		http.StatusOK, &pb.SetTenantQuotaRequest{
+			Header:         newGatewayRequestHeader(r),
+			Version:        rpc.CaProtocolVersion,
+			Tid:            mux.Vars(r)[gatewayTenantVar],
+			MaxDevices:     body.MaxDevices,
+			ResetToDefault: body.ResetToDefault,
+		}, caService.SetTenantQuota)
+}
+
+// GatewayGetCACertificatesHandler - returns the root CA certificate, the
+// common signing certificate and, if a tenant is specified, the signing
+// certificate used within the tenant (GetCACertificates RPC).
+func GatewayGetCACertificatesHandler(w http.ResponseWriter, r *http.Request) {
+	format, err := decodeGatewayCertificateFormat(
+		r.URL.Query().Get(gatewayFormatParam))
+	if err != nil {
+		writeGatewayFieldError(w, r, rpc.MethodGetCACertificates, "format", err)
+		return
+	}
+
+	serveGatewayRequest(w, r, rpc.MethodGetCACertificates,
+		http.StatusOK, &pb.GetCACertificatesRequest{
+			Header:  newGatewayRequestHeader(r),
+			Version: rpc.CaProtocolVersion,
+			Tid:     mux.Vars(r)[gatewayTenantVar],
+			Format:  format,
+		}, caService.GetCACertificates)
+}
+
+// GatewayPingHandler - echoes the specified message (Ping RPC).
+func GatewayPingHandler(w http.ResponseWriter, r *http.Request) {
+	serveGatewayRequest(w, r, rpc.MethodPing, http.StatusOK,
+		&pb.PingRequest{
+			Message: r.URL.Query().Get(gatewayPingMessageParam),
+		}, caService.Ping)
+}
+
+// Build the header of the RPC request for a gateway request. Version 2 of
+// the CA protocol is used, so that failures are reported using gRPC status
+// errors describing why the request failed.
+func newGatewayRequestHeader(r *http.Request) *pb.CaRequestHeader {
+	return &pb.CaRequestHeader{
+		ProtocolVersion: rpc.CaProtocolVersionV2,
+		RequestId:       r.Header.Get(headerRequestID),
+		RequestTime:     timestamppb.Now(),
+	}
+}
+
+// Load the CA certificates used to verify the client certificates of
+// administrators calling the gateway routes which modify tenants. No CA
+// certificates are loaded unless the REST server uses TLS and a client CA file
+// is configured.
+func initGatewayAdminClientCAs(serverConfig *config.Server) error {
+	adminClientCAs = nil
+	if (serverConfig.RestTlsCertFile == "") ||
+		(serverConfig.RestClientCAFile == "") {
+		return nil
+	}
+
+	pemCerts, err := os.ReadFile(serverConfig.RestClientCAFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemCerts) {
+		return errGatewayNoClientCAs
+	}
+	adminClientCAs = pool
+	return nil
+}
+
+// Verify that a gateway request which modifies a tenant was made by an
+// administrator, presenting a client certificate issued by one of the
+// configured client CAs. If not, an error response is written and false is
+// returned.
+func authenticateGatewayAdmin(w http.ResponseWriter, r *http.Request,
+	method string) bool {
+	if (adminClientCAs == nil) || (r.TLS == nil) ||
+		(len(r.TLS.PeerCertificates) == 0) {
+		caLogger.Error("Gateway: No client certificate was presented!",
+			zap.String("Method:", method),
+			zap.String("Request ID:", r.Header.Get(headerRequestID)),
+		)
+		writeGatewayError(w, method, status.New(codes.Unauthenticated,
+			"client certificate required"))
+		return false
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := r.TLS.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         adminClientCAs,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		caLogger.Error("Gateway: Client certificate could not be verified!",
+			zap.String("Method:", method),
+			zap.String("Request ID:", r.Header.Get(headerRequestID)),
+			zap.Error(err),
+		)
+		writeGatewayError(w, method, status.New(codes.PermissionDenied,
+			"client certificate was not issued by a trusted client CA"))
+		return false
+	}
+	return true
+}
+
+// Decode the JSON body of a gateway request. If the body is invalid, an error
+// response is written and false is returned.
+func readGatewayRequest(w http.ResponseWriter, r *http.Request, method string,
+	body interface{}) bool {
+	return readGatewayRequestBody(w, r, method, maxGatewayRequestSize, body)
+}
+
+// Decode the JSON body of a gateway request, which may be at most the
+// specified size. If the body is invalid, an error response is written and
+// false is returned.
+func readGatewayRequestBody(w http.ResponseWriter, r *http.Request,
+	method string, maxSize int64, body interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSize))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(body)
+	if err != nil {
+		caLogger.Error("Gateway: Invalid request body!",
+			zap.String("Method:", method),
+			zap.String("Request ID:", r.Header.Get(headerRequestID)),
+			zap.Error(err),
+		)
+		writeGatewayError(w, method, status.New(codes.InvalidArgument,
+			"invalid request body: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+// Decode the CSR and certificate format specified in a request to create or
+// renew a device certificate. If either is invalid, an error response is
+// written and false is returned. An empty CSR is passed on to the RPC handler,
+// which reports it as missing.
+func readGatewayDeviceCertificateRequest(w http.ResponseWriter, r *http.Request,
+	method string, body *gatewayDeviceCertificateRequest) ([]byte,
+	pb.CertificateFormat, bool) {
+	csr, err := decodeGatewayCsr(body.Csr)
+	if err != nil {
+		writeGatewayFieldError(w, r, method, "csr", err)
+		return nil, 0, false
+	}
+
+	format, err := decodeGatewayCertificateFormat(body.Format)
+	if err != nil {
+		writeGatewayFieldError(w, r, method, "format", err)
+		return nil, 0, false
+	}
+	return csr, format, true
+}
+
+// Write an error response to a gateway request specifying an invalid field.
+func writeGatewayFieldError(w http.ResponseWriter, r *http.Request,
+	method string, field string, err error) {
+	caLogger.Error("Gateway: Invalid request field specified!",
+		zap.String("Method:", method),
+		zap.String("Field:", field),
+		zap.String("Request ID:", r.Header.Get(headerRequestID)),
+		zap.Error(err),
+	)
+
+	st := status.New(codes.InvalidArgument, err.Error())
+	if detailed, derr := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+			Field:       field,
+			Description: err.Error(),
+		}},
+	}); derr == nil {
+		st = detailed
+	}
+	writeGatewayError(w, method, st)
+}
+
+// Decode the name of a certificate format. If no format is specified, the
+// device certificate is returned as DER bytes.
+func decodeGatewayCertificateFormat(value string) (pb.CertificateFormat, error) {
+	if value == "" {
+		return pb.CertificateFormat_CERTIFICATE_FORMAT_UNSPECIFIED, nil
+	}
+
+	name := strings.ToUpper(value)
+	if !strings.HasPrefix(name, certificateFormatPrefix) {
+		name = certificateFormatPrefix + name
+	}
+	format, ok := pb.CertificateFormat_value[name]
+	if !ok {
+		return 0, errGatewayInvalidFormat
+	}
+	return pb.CertificateFormat(format), nil
+}
+
+// Decode a PEM encoded or base64 encoded DER certificate signing request.
+func decodeGatewayCsr(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(value, "-----BEGIN") {
+		block, _ := pem.Decode([]byte(value))
+		if (block == nil) || ((block.Type != pemTypeCertificateRequest) &&
+			(block.Type != pemTypeNewCertificateRequest)) {
+			return nil, errGatewayInvalidCsr
+		}
+		return block.Bytes, nil
+	}
+
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding,
+		base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if csr, err := encoding.DecodeString(value); err == nil {
+			return csr, nil
+		}
+	}
+	return nil, errGatewayInvalidCsr
+}
+
+// Invoke the RPC handler for a gateway request and write the response. The
+// RPC is invoked with a context identifying the caller by its network address
+// and TLS client certificate, as for requests received at the gRPC endpoint.
+func serveGatewayRequest[Req proto.Message, Resp proto.Message](
+	w http.ResponseWriter, r *http.Request, method string, successStatus int,
+	request Req, handler func(context.Context, Req) (Resp, error)) {
+	response, err := caService.Invoke(newCallerContext(r), method, request,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return handler(ctx, req.(Req))
+		})
+	if err != nil {
+		writeGatewayError(w, method, status.Convert(err))
+		return
+	}
+
+	// Version 1 callers only receive the status of failed requests in the
+	// response header, so check it in case the RPC handler reported a
+	// failure without returning a status error.
+	if h, ok := response.(interface {
+		GetHeader() *pb.CaResponseHeader
+	}); ok && (h.GetHeader() != nil) &&
+		(codes.Code(h.GetHeader().Status) != codes.OK) {
+		writeGatewayError(w, method, status.New(
+			codes.Code(h.GetHeader().Status), h.GetHeader().StatusMessage))
+		return
+	}
+
+	writeGatewayResponse(w, method, successStatus, response.(proto.Message))
+}
+
+// Write the JSON encoding of a message in response to a gateway request.
+func writeGatewayResponse(w http.ResponseWriter, method string,
+	statusCode int, message proto.Message) {
+	body, err := gatewayMarshalOptions.Marshal(message)
+	if err != nil {
+		caLogger.Error("Gateway: Failed to encode the response!",
+			zap.String("Method:", method),
+			zap.Error(err),
+		)
+		statusCode = http.StatusInternalServerError
+		body = []byte(`{"code":13,"message":"failed to encode the response"}`)
+	}
+
+	metrics.MetricGatewayRequests.WithLabelValues(method,
+		strconv.Itoa(statusCode)).Inc()
+	w.Header().Set(headerContentType, contentTypeJson)
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body)
+}
+
+// Write the JSON encoding of a gRPC status in response to a failed gateway
+// request. If the status specifies when the request may be retried, the
+// caller is informed using the Retry-After header.
+func writeGatewayError(w http.ResponseWriter, method string, st *status.Status) {
+	for _, detail := range st.Details() {
+		if retryInfo, ok := detail.(*errdetails.RetryInfo); ok {
+			seconds := math.Ceil(retryInfo.GetRetryDelay().AsDuration().Seconds())
+			w.Header().Set(headerRetryAfter, strconv.Itoa(int(seconds)))
+		}
+	}
+	writeGatewayResponse(w, method, httpStatusFromCode(st.Code()), st.Proto())
+}
+
+// Map a gRPC status code to the HTTP status code reported to callers of the
+// gateway.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied, codes.FailedPrecondition:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
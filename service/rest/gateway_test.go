@@ -0,0 +1,503 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Client presenting an administrator's client certificate, used to send
+// gateway requests in tests.
+var gTestAdminClient *http.Client
+
+// Create a client CA and a client certificate issued to an administrator, and
+// a client presenting the client certificate to the test server.
+func initTestGatewayAdmin() error {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test admin client CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caBytes, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate,
+		&caKey.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	caCert, err := x509.ParseCertificate(caBytes)
+	if err != nil {
+		return err
+	}
+
+	adminKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	adminBytes, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test admin"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, caCert, &adminKey.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+
+	adminClientCAs = x509.NewCertPool()
+	adminClientCAs.AddCert(caCert)
+
+	transport := gTestServer.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.Certificates = []tls.Certificate{{
+		Certificate: [][]byte{adminBytes},
+		PrivateKey:  adminKey,
+	}}
+	gTestAdminClient = &http.Client{Transport: transport}
+	return nil
+}
+
+// Send a gateway request as an administrator and return the response status
+// and body.
+func doGatewayRequest(t *testing.T, method string, path string,
+	body interface{}) (int, []byte) {
+	return doGatewayRequestWithClient(t, gTestAdminClient, method, path, body)
+}
+
+// Send a gateway request using the specified client and return the response
+// status and body.
+func doGatewayRequestWithClient(t *testing.T, client *http.Client,
+	method string, path string, body interface{}) (int, []byte) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Failed to encode the request: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequest(method, gTestServer.URL+"/api/v1"+path,
+		reader)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	request.Header.Set(headerContentType, contentTypeJson)
+
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("Gateway request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.Header.Get(headerContentType) != contentTypeJson {
+		t.Errorf("Unexpected content type: %s",
+			response.Header.Get(headerContentType))
+	}
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("Failed to read the response: %v", err)
+	}
+	return response.StatusCode, responseBody
+}
+
+// Decode the JSON encoded RPC response returned by the gateway.
+func decodeGatewayResponse(t *testing.T, body []byte, response proto.Message) {
+	err := protojson.Unmarshal(body, response)
+	if err != nil {
+		t.Fatalf("Failed to decode the response %s: %v", body, err)
+	}
+}
+
+// Decode the JSON encoded status returned by the gateway for failed requests.
+func decodeGatewayError(t *testing.T, body []byte) *status.Status {
+	var st spb.Status
+	decodeGatewayResponse(t, body, &st)
+	return status.FromProto(&st)
+}
+
+// Create a new tenant using the gateway and return its tenant ID.
+func createGatewayTestTenant(t *testing.T) string {
+	tenantID := uuid.NewString()
+	code, body := doGatewayRequest(t, http.MethodPost,
+		"/tenants/"+tenantID+"/signing_certificate",
+		gatewayCreateTenantSigningCertificateRequest{
+			Name:       "Gateway test tenant",
+			DomainName: "gateway.test",
+		})
+	if code != http.StatusCreated {
+		t.Fatalf("Failed to create the tenant with status %d: %s", code, body)
+	}
+	return tenantID
+}
+
+// Create a DER encoded CSR to be sent in gateway requests.
+func newGatewayTestCsr(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate device key: %v", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader,
+		&x509.CertificateRequest{SignatureAlgorithm: x509.SHA256WithRSA}, key)
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+	return csr
+}
+
+func TestGatewayTenantSigningCertificate(t *testing.T) {
+	tenantID := createGatewayTestTenant(t)
+	path := "/tenants/" + tenantID + "/signing_certificate"
+
+	// The signing certificate is not replaced.
+	code, body := doGatewayRequest(t, http.MethodPost, path,
+		gatewayCreateTenantSigningCertificateRequest{Name: "Gateway test tenant"})
+	if code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusConflict, code, body)
+	}
+
+	code, body = doGatewayRequest(t, http.MethodGet, path, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Failed to get the signing certificate with status %d: %s",
+			code, body)
+	}
+	var getResponse pb.GetTenantSigningCertificateResponse
+	decodeGatewayResponse(t, body, &getResponse)
+	if codes.Code(getResponse.Header.Status) != codes.OK {
+		t.Errorf("Unexpected status in the response header: %d",
+			getResponse.Header.Status)
+	}
+	cert, err := x509.ParseCertificate(getResponse.SigningCertificate)
+	if err != nil {
+		t.Fatalf("Failed to parse the signing certificate: %v", err)
+	}
+	if !cert.IsCA {
+		t.Errorf("The signing certificate is not a CA certificate")
+	}
+
+	code, body = doGatewayRequest(t, http.MethodDelete, path, nil)
+	if code != http.StatusOK {
+		t.Errorf("Failed to delete the signing certificate with status %d: %s",
+			code, body)
+	}
+}
+
+func TestGatewayTenantSigningCertificate_NotAdmin(t *testing.T) {
+	path := "/tenants/" + uuid.NewString() + "/signing_certificate"
+	request := gatewayCreateTenantSigningCertificateRequest{
+		Name: "Gateway test tenant",
+	}
+
+	// Callers presenting no client certificate are not authenticated.
+	code, body := doGatewayRequestWithClient(t, gTestServer.Client(),
+		http.MethodPost, path, request)
+	if code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusUnauthorized,
+			code, body)
+	}
+
+	// Client certificates not issued by the client CA are rejected.
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	cert, err := newTestSelfSignedCertificate(key, "Untrusted admin")
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	transport := gTestServer.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.Certificates = []tls.Certificate{{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey:  key,
+	}}
+	code, body = doGatewayRequestWithClient(t,
+		&http.Client{Transport: transport}, http.MethodPut,
+		"/tenants/"+uuid.NewString()+"/quota",
+		gatewaySetTenantQuotaRequest{MaxDevices: 1})
+	if code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusForbidden,
+			code, body)
+	}
+}
+
+func TestGatewayTenantSigningCertificate_MissingName(t *testing.T) {
+	code, body := doGatewayRequest(t, http.MethodPost,
+		"/tenants/"+uuid.NewString()+"/signing_certificate",
+		gatewayCreateTenantSigningCertificateRequest{})
+	if code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusBadRequest,
+			code, body)
+	}
+
+	// The field violations reported by the RPC handler are returned.
+	st := decodeGatewayError(t, body)
+	found := false
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				found = found || (violation.Field == "name")
+			}
+		}
+	}
+	if !found {
+		t.Errorf("The missing name was not reported: %s", body)
+	}
+
+	// Unknown fields are rejected.
+	code, _ = doGatewayRequest(t, http.MethodPost,
+		"/tenants/"+uuid.NewString()+"/signing_certificate",
+		map[string]string{"name": "test", "unknown": "value"})
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestGatewayDeviceCertificate(t *testing.T) {
+	tenantID := createGatewayTestTenant(t)
+
+	// Enroll using a PEM encoded CSR.
+	csr := pem.EncodeToMemory(&pem.Block{Type: pemTypeCertificateRequest,
+		Bytes: newGatewayTestCsr(t)})
+	code, body := doGatewayRequest(t, http.MethodPost,
+		"/tenants/"+tenantID+"/devices",
+		gatewayDeviceCertificateRequest{Csr: string(csr)})
+	if code != http.StatusCreated {
+		t.Fatalf("Failed to create the device certificate with status %d: %s",
+			code, body)
+	}
+	var createResponse pb.CreateDeviceCertificateResponse
+	decodeGatewayResponse(t, body, &createResponse)
+	deviceCert, err := x509.ParseCertificate(createResponse.DeviceCertificate)
+	if err != nil {
+		t.Fatalf("Failed to parse the device certificate: %v", err)
+	}
+	if deviceCert.Subject.CommonName != createResponse.DeviceId {
+		t.Errorf("Device certificate was not issued to the device: %s != %s",
+			deviceCert.Subject.CommonName, createResponse.DeviceId)
+	}
+
+	// Renew using a base64 encoded DER CSR.
+	code, body = doGatewayRequest(t, http.MethodPost,
+		fmt.Sprintf("/tenants/%s/devices/%s/renew", tenantID,
+			createResponse.DeviceId),
+		gatewayDeviceCertificateRequest{
+			Csr: base64.StdEncoding.EncodeToString(newGatewayTestCsr(t)),
+		})
+	if code != http.StatusOK {
+		t.Fatalf("Failed to renew the device certificate with status %d: %s",
+			code, body)
+	}
+	var renewResponse pb.RenewDeviceCertificateResponse
+	decodeGatewayResponse(t, body, &renewResponse)
+	if renewResponse.DeviceId != createResponse.DeviceId {
+		t.Errorf("Device ID changed on renewal: %s != %s",
+			renewResponse.DeviceId, createResponse.DeviceId)
+	}
+}
+
+func TestGatewayDeviceCertificates(t *testing.T) {
+	tenantID := createGatewayTestTenant(t)
+
+	// A bad CSR within the batch does not fail the other items.
+	request := map[string]interface{}{
+		"items": []map[string]string{
+			{"request_id": uuid.NewString(),
+				"csr": base64.StdEncoding.EncodeToString(newGatewayTestCsr(t))},
+			{"request_id": uuid.NewString(),
+				"csr": base64.StdEncoding.EncodeToString([]byte("not a csr"))},
+		},
+	}
+	code, body := doGatewayRequest(t, http.MethodPost,
+		"/tenants/"+tenantID+"/devices/batch", request)
+	if code != http.StatusOK {
+		t.Fatalf("Failed to create the device certificates with status %d: %s",
+			code, body)
+	}
+	var response pb.CreateDeviceCertificatesResponse
+	decodeGatewayResponse(t, body, &response)
+	if (len(response.Results) != 2) || (response.Succeeded != 1) ||
+		(response.Failed != 1) {
+		t.Fatalf("Unexpected batch results: %s", body)
+	}
+	if _, err := x509.ParseCertificate(
+		response.Results[0].DeviceCertificate); err != nil {
+		t.Errorf("Failed to parse the device certificate: %v", err)
+	}
+	if (codes.Code(response.Results[1].Status) != codes.InvalidArgument) ||
+		(response.Results[1].Reason != "INVALID_CSR") {
+		t.Errorf("Expected the bad CSR to be rejected, got %+v",
+			response.Results[1])
+	}
+
+	// CSRs which cannot be decoded are reported as field violations.
+	request["items"] = []map[string]string{{"csr": "%%%"}}
+	code, body = doGatewayRequest(t, http.MethodPost,
+		"/tenants/"+tenantID+"/devices/batch", request)
+	if code != http.StatusBadRequest {
+		t.Fatalf("Expected the request to be rejected, got status %d: %s",
+			code, body)
+	}
+}
+
+func TestGatewayDeviceCertificate_PemChain(t *testing.T) {
+	tenantID := createGatewayTestTenant(t)
+	code, body := doGatewayRequest(t, http.MethodPost,
+		"/tenants/"+tenantID+"/devices",
+		gatewayDeviceCertificateRequest{
+			Csr:    base64.StdEncoding.EncodeToString(newGatewayTestCsr(t)),
+			Format: "pem_chain",
+		})
+	if code != http.StatusCreated {
+		t.Fatalf("Failed to create the device certificate with status %d: %s",
+			code, body)
+	}
+	var response pb.CreateDeviceCertificateResponse
+	decodeGatewayResponse(t, body, &response)
+
+	// The device certificate is returned as a bundle of PEM encoded
+	// certificates, in leaf to root order.
+	var certs []*x509.Certificate
+	for rest := response.DeviceCertificate; len(rest) > 0; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			t.Fatalf("Device certificate is not a PEM bundle: %s",
+				response.DeviceCertificate)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("Failed to parse a certificate in the bundle: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) != 3 {
+		t.Fatalf("Expected 3 certificates in the bundle, got %d", len(certs))
+	}
+	if certs[0].Subject.CommonName != response.DeviceId {
+		t.Errorf("The bundle does not start with the device certificate")
+	}
+	if !certs[len(certs)-1].IsCA || (len(response.Chain) != len(certs)) {
+		t.Errorf("The bundle does not end with the CA certificate")
+	}
+
+	// Unknown formats are rejected.
+	code, _ = doGatewayRequest(t, http.MethodPost,
+		"/tenants/"+tenantID+"/devices",
+		gatewayDeviceCertificateRequest{
+			Csr:    base64.StdEncoding.EncodeToString(newGatewayTestCsr(t)),
+			Format: "jks",
+		})
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestGatewayDeviceCertificate_InvalidCsr(t *testing.T) {
+	tenantID := createGatewayTestTenant(t)
+	for _, csr := range []string{"%%%", "", base64.StdEncoding.EncodeToString(
+		[]byte("not a csr")),
+		"-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n"} {
+		code, body := doGatewayRequest(t, http.MethodPost,
+			"/tenants/"+tenantID+"/devices",
+			gatewayDeviceCertificateRequest{Csr: csr})
+		if code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for CSR %q, got %d: %s",
+				http.StatusBadRequest, csr, code, body)
+			continue
+		}
+		st := decodeGatewayError(t, body)
+		if st.Code() != codes.InvalidArgument {
+			t.Errorf("Expected status code %s for CSR %q, got %s",
+				codes.InvalidArgument, csr, st.Code())
+		}
+	}
+}
+
+func TestGatewayTenantQuota(t *testing.T) {
+	tenantID := createGatewayTestTenant(t)
+	path := "/tenants/" + tenantID + "/quota"
+
+	code, body := doGatewayRequest(t, http.MethodPut, path,
+		gatewaySetTenantQuotaRequest{MaxDevices: 1})
+	if code != http.StatusOK {
+		t.Fatalf("Failed to set the quota with status %d: %s", code, body)
+	}
+
+	code, body = doGatewayRequest(t, http.MethodGet, path, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Failed to get the quota with status %d: %s", code, body)
+	}
+	var quotaResponse pb.GetTenantQuotaResponse
+	decodeGatewayResponse(t, body, &quotaResponse)
+	if quotaResponse.MaxDevices != 1 {
+		t.Errorf("Expected a device cap of 1, got %d", quotaResponse.MaxDevices)
+	}
+
+	// Devices beyond the cap are rejected.
+	for i, expected := range []int{http.StatusCreated, http.StatusTooManyRequests} {
+		code, body = doGatewayRequest(t, http.MethodPost,
+			"/tenants/"+tenantID+"/devices",
+			gatewayDeviceCertificateRequest{
+				Csr: base64.StdEncoding.EncodeToString(newGatewayTestCsr(t)),
+			})
+		if code != expected {
+			t.Errorf("Device %d: expected status %d, got %d: %s", i, expected,
+				code, body)
+		}
+	}
+
+	// Invalid device caps are rejected.
+	code, _ = doGatewayRequest(t, http.MethodPut, path,
+		gatewaySetTenantQuotaRequest{MaxDevices: -1})
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestGatewayPing(t *testing.T) {
+	code, body := doGatewayRequest(t, http.MethodGet, "/ping?message=hello", nil)
+	if code != http.StatusOK {
+		t.Fatalf("Ping failed with status %d: %s", code, body)
+	}
+	var pingResponse pb.PingResponse
+	decodeGatewayResponse(t, body, &pingResponse)
+	if pingResponse.Message != "hello" {
+		t.Errorf("Expected the ping message to be echoed, got %q",
+			pingResponse.Message)
+	}
+
+	code, _ = doGatewayRequest(t, http.MethodGet,
+		"/ping?message="+strings.Repeat("a", 100), nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, code)
+	}
+}
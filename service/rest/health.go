@@ -3,14 +3,55 @@
 // Component: Krypton Certificate Authority
 // (C) HP Development Company, LP
 // Purpose:
-// The HTTP handler function responsible for handling health requests at the
-// CA's REST endpoint.
+// The HTTP handler functions responsible for handling liveness and readiness
+// requests at the CA's REST endpoint.
 package rest
 
 import (
+	"encoding/json"
 	"net/http"
+
+	"github.com/HPInc/krypton-ca/service/health"
+	"go.uber.org/zap"
 )
 
+// Checker used to report the readiness of the CA.
+var healthChecker *health.Checker
+
+// GetHealthHandler - reports the liveness of the CA. The CA is live as long as
+// it is able to serve requests at the REST endpoint, irrespective of the
+// status of its dependencies.
 func GetHealthHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthResponse(w, http.StatusOK, &health.Report{Status: health.StatusUp})
+}
+
+// GetReadinessHandler - reports the readiness of the CA, along with the status
+// of each of its dependencies as determined by the most recent probes. If the
+// CA is not ready, the request fails with 503 (Service Unavailable) so that
+// requests are not routed to the CA.
+func GetReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := &health.Report{Status: health.StatusDown}
+	if healthChecker != nil {
+		report = healthChecker.Report()
+	}
+
+	status := http.StatusOK
+	if report.Status != health.StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealthResponse(w, status, report)
+}
 
+// Write the specified health report as a JSON response.
+func writeHealthResponse(w http.ResponseWriter, status int,
+	report *health.Report) {
+	w.Header().Set(headerContentType, contentTypeJson)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(report)
+	if err != nil {
+		caLogger.Error("Failed to write the health response!",
+			zap.Error(err),
+		)
+	}
 }
@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/HPInc/krypton-ca/service/health"
+)
+
+// Send a health request to the specified path and return the status code and
+// the health report.
+func doHealthRequest(t *testing.T, path string) (int, *health.Report) {
+	response, err := gTestServer.Client().Get(gTestServer.URL + path)
+	if err != nil {
+		t.Fatalf("Health request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if contentType := response.Header.Get(headerContentType); contentType != contentTypeJson {
+		t.Errorf("Unexpected content type: %s", contentType)
+	}
+	report := &health.Report{}
+	if err = json.NewDecoder(response.Body).Decode(report); err != nil {
+		t.Fatalf("Failed to decode the health report: %v", err)
+	}
+	return response.StatusCode, report
+}
+
+func TestHealth(t *testing.T) {
+	status, report := doHealthRequest(t, "/health")
+	if (status != http.StatusOK) || (report.Status != health.StatusUp) {
+		t.Errorf("Expected the CA to be live, got %d: %+v", status, report)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	status, report := doHealthRequest(t, "/health/ready")
+	if (status != http.StatusOK) || (report.Status != health.StatusUp) {
+		t.Fatalf("Expected the CA to be ready, got %d: %+v", status, report)
+	}
+	for _, name := range []string{health.DependencyCertStore,
+		health.DependencyKmsProvider} {
+		if report.Dependencies[name].Status != health.StatusUp {
+			t.Errorf("Expected %s to be up, got %+v", name,
+				report.Dependencies[name])
+		}
+	}
+
+	// The CA is not ready without a health checker.
+	checker := healthChecker
+	healthChecker = nil
+	defer func() { healthChecker = checker }()
+	status, report = doHealthRequest(t, "/health/ready")
+	if (status != http.StatusServiceUnavailable) || (report.Status != health.StatusDown) {
+		t.Errorf("Expected the CA to not be ready, got %d: %+v", status, report)
+	}
+}
@@ -7,14 +7,21 @@
 package rest
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
+	"github.com/HPInc/krypton-ca/service/acme"
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
 	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/HPInc/krypton-ca/service/health"
+	"github.com/HPInc/krypton-ca/service/rpc"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
@@ -23,19 +30,34 @@ import (
 var (
 	caLogger             *zap.Logger
 	debugLogRestRequests bool
+
+	// KMS provider used to issue device certificates to devices enrolling
+	// using EST.
+	kmsProvider kms_providers.KmsProvider
+
+	// Quota manager used to enforce per-tenant device caps.
+	quotaManager *quota.Manager
 )
 
 const (
 	// HTTP server timeouts for the REST endpoint.
 	readTimeout  = (time.Second * 5)
 	writeTimeout = (time.Second * 5)
+
+	// HTTP server timeouts for batch device certificate requests received by
+	// the gateway, which carry up to the maximum batch size of CSRs and take
+	// longer to process than other requests.
+	batchReadTimeout  = (time.Second * 30)
+	batchWriteTimeout = (time.Minute * 5)
 )
 
-// Represents the CA REST service.
-type caRestService struct {
-	// Signal handling to support SIGTERM and SIGINT for the service.
-	errChannel  chan error
-	stopChannel chan os.Signal
+// CaRestService - represents the CA REST service.
+type CaRestService struct {
+	// HTTP server used to serve requests at the REST endpoint.
+	server *http.Server
+
+	// Fatal errors encountered while serving REST requests.
+	errChannel chan error
 
 	// Prometheus metrics reporting.
 	metricRegistry *prometheus.Registry
@@ -45,31 +67,30 @@ type caRestService struct {
 
 	// HTTP port on which the REST server is available.
 	port int
+
+	// TLS certificate and private key files used to serve requests over
+	// HTTPS. If not specified, requests are served over HTTP.
+	tlsCertFile string
+	tlsKeyFile  string
 }
 
 // Creates a new instance of the CA REST service and initalizes the request
-// router for the CA REST endpoint.
-func newCaRestService() *caRestService {
-	s := &caRestService{}
-
-	// Initial signal handling.
-	s.errChannel = make(chan error)
-	s.stopChannel = make(chan os.Signal, 1)
-	signal.Notify(s.stopChannel, syscall.SIGINT, syscall.SIGTERM)
+// router for the CA REST endpoint with the specified routes.
+func newCaRestService(routeList routes) *CaRestService {
+	s := &CaRestService{}
+	s.errChannel = make(chan error, 1)
 
 	// Initialize the prometheus metric reporting registry.
 	s.metricRegistry = prometheus.NewRegistry()
 
-	s.router = initRequestRouter()
+	s.router = initRequestRouter(routeList)
 	return s
 }
 
 // Starts the HTTP REST server for the CA service and starts serving requests
-// at the REST endpoint.
-func (s *caRestService) startServing() {
-	// Start the HTTP REST server. http.ListenAndServe() always returns
-	// a non-nil error
-	server := &http.Server{
+// at the REST endpoint on a separate goroutine.
+func (s *CaRestService) startServing() error {
+	s.server = &http.Server{
 		Addr:           fmt.Sprintf(":%d", s.port),
 		Handler:        s.router,
 		ReadTimeout:    readTimeout,
@@ -77,8 +98,40 @@ func (s *caRestService) startServing() {
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	err := server.ListenAndServe()
-	caLogger.Error("Received a fatal error from http.ListenAndServe",
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		caLogger.Error("Failed to initialize a listener for the REST server!",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	go s.serve(listener)
+	return nil
+}
+
+// Goroutine to serve REST requests received by the specified listener.
+// Serve() always returns a non-nil error, which is http.ErrServerClosed once
+// the server is shut down.
+func (s *CaRestService) serve(listener net.Listener) {
+	var err error
+	if s.tlsCertFile != "" {
+		// Request client certificates, which are used to authenticate
+		// devices re-enrolling using EST and administrators calling the
+		// gateway routes which modify tenants. The client certificate is
+		// verified by the request handlers that require it.
+		s.server.TLSConfig = &tls.Config{
+			ClientAuth: tls.RequestClientCert,
+			MinVersion: tls.VersionTLS12,
+		}
+		err = s.server.ServeTLS(listener, s.tlsCertFile, s.tlsKeyFile)
+	} else {
+		err = s.server.Serve(listener)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return
+	}
+	caLogger.Error("Received a fatal error from http.Serve",
 		zap.Error(err),
 	)
 
@@ -86,39 +139,98 @@ func (s *caRestService) startServing() {
 	s.errChannel <- err
 }
 
-// Waits for the CA REST server to be terminated - either in response to a
-// system event received on the stop channel or a fatal error signal received
-// on the error channel.
-func (s *caRestService) awaitTermination() {
-	select {
-	case err := <-s.errChannel:
-		caLogger.Error("Shutting down due to a fatal error.",
+// Errors - returns a channel on which fatal errors encountered while serving
+// REST requests are reported.
+func (s *CaRestService) Errors() <-chan error {
+	return s.errChannel
+}
+
+// Shutdown - stop accepting REST requests and wait for in-flight requests to
+// complete, or for the specified context to be done.
+func (s *CaRestService) Shutdown(ctx context.Context) error {
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		caLogger.Warn("Timed out waiting for in-flight REST requests to complete!",
 			zap.Error(err),
 		)
-	case sig := <-s.stopChannel:
-		caLogger.Info("Received an OS signal to shut down!",
-			zap.String("Signal received: ", sig.String()),
-		)
+		_ = s.server.Close()
+		return err
 	}
+	caLogger.Info("Stopped serving REST requests.")
+	return nil
 }
 
 // Init initializes the CA REST server and starts serving REST requests at the
-// CA's REST endpoint.
-func Init(logger *zap.Logger, cfgMgr *config.ConfigMgr) {
+// CA's REST endpoint. Requests received by the REST/JSON gateway are processed
+// by the specified certificate authority service, which is shared with the
+// gRPC server. Devices enrolling using EST or SCEP and clients of the ACME
+// server are issued certificates using the specified KMS provider. Requests
+// are served on a separate goroutine until the server is shut down. The
+// readiness of the CA is reported using the specified health checker.
+func Init(logger *zap.Logger, cfgMgr *config.ConfigMgr,
+	service *rpc.CertificateAuthorityServer, provider kms_providers.KmsProvider,
+	store certstore.CertStore, checker *health.Checker) (*CaRestService, error) {
 	caLogger = logger
+	healthChecker = checker
 	debugLogRestRequests = cfgMgr.GetServerConfig().DebugLogRestRequests
+	kmsProvider = provider
+	caService = service
+	quotaManager = service.QuotaManager()
+
+	// Register the REST/JSON gateway, EST enrollment, ACME and SCEP routes if
+	// they are enabled. The gateway routes which modify tenants are only
+	// registered if administrators can be authenticated using TLS client
+	// certificates.
+	routeList := append(routes{}, registeredRoutes...)
+	if cfgMgr.GetGatewayConfig().Enabled {
+		routeList = append(routeList, gatewayRoutes...)
+		err := initGatewayAdminClientCAs(cfgMgr.GetServerConfig())
+		if err != nil {
+			caLogger.Error("Failed to load the REST client CA certificates!",
+				zap.Error(err),
+			)
+			return nil, err
+		}
+		if adminClientCAs != nil {
+			routeList = append(routeList, gatewayAdminRoutes...)
+		} else {
+			caLogger.Warn("No REST client CA is configured. Gateway routes which modify tenants are disabled!")
+		}
+	}
+	if estSettings := cfgMgr.GetEstConfig(); estSettings.Enabled {
+		initEst(estSettings)
+		routeList = append(routeList, estRoutes...)
+	}
+	if acmeSettings := cfgMgr.GetAcmeConfig(); acmeSettings.Enabled {
+		acmeServer := acme.NewServer(logger, provider, store, quotaManager,
+			enrollmentRateLimiter{}, acmeSettings,
+			acme.NewPreSharedTokenValidator(acmeSettings.TokenSecret))
+		routeList = append(routeList, newAcmeRoutes(acmeServer)...)
+	}
+	if scepSettings := cfgMgr.GetScepConfig(); scepSettings.Enabled {
+		err := initScep(scepSettings)
+		if err != nil {
+			caLogger.Error("Failed to load the SCEP RA certificate. SCEP is disabled!",
+				zap.Error(err),
+			)
+		} else {
+			routeList = append(routeList, scepRoutes...)
+		}
+	}
 
-	s := newCaRestService()
+	s := newCaRestService(routeList)
 	s.port = cfgMgr.GetServerConfig().RestPort
+	s.tlsCertFile = cfgMgr.GetServerConfig().RestTlsCertFile
+	s.tlsKeyFile = cfgMgr.GetServerConfig().RestTlsKeyFile
 
 	// Initialize the REST server and listen for REST requests on a separate
 	// goroutine. Report fatal errors via the error channel.
-	go s.startServing()
+	err := s.startServing()
+	if err != nil {
+		return nil, err
+	}
 	caLogger.Info("Started the CA REST service!",
 		zap.Int("Port: ", s.port),
 	)
-
-	// Wait for the REST server to be terminated either in response to a system
-	// event (like service shutdown) or a fatal error.
-	s.awaitTermination()
+	return s, nil
 }
@@ -0,0 +1,107 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Returns a free TCP port on which the REST server can listen.
+func getFreePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestCaRestService_Shutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := newCaRestService(routes{
+		Route{"Slow", http.MethodGet, "/slow",
+			func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				<-release
+				w.WriteHeader(http.StatusOK)
+			}},
+	})
+	s.port = getFreePort(t)
+	if err := s.startServing(); err != nil {
+		t.Fatalf("Failed to start the REST server: %v", err)
+	}
+
+	// Start a request which is in flight when the server is shut down.
+	responses := make(chan error, 1)
+	go func() {
+		response, err := http.Get(fmt.Sprintf("http://localhost:%d/slow", s.port))
+		if err == nil {
+			response.Body.Close()
+		}
+		responses <- err
+	}()
+	<-started
+
+	// Shutdown waits for the in-flight request to complete.
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- s.Shutdown(context.Background())
+	}()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Expected shutdown to wait for the in-flight request, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-shutdown; err != nil {
+		t.Errorf("Failed to shut down the REST server: %v", err)
+	}
+	if err := <-responses; err != nil {
+		t.Errorf("Expected the in-flight request to complete, got %v", err)
+	}
+
+	// Shutting down the server is not reported as a fatal error.
+	select {
+	case err := <-s.Errors():
+		t.Errorf("Unexpected error reported by the REST server: %v", err)
+	default:
+	}
+}
+
+func TestCaRestService_ShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	s := newCaRestService(routes{
+		Route{"Stuck", http.MethodGet, "/stuck",
+			func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				<-release
+			}},
+	})
+	s.port = getFreePort(t)
+	if err := s.startServing(); err != nil {
+		t.Fatalf("Failed to start the REST server: %v", err)
+	}
+
+	go func() {
+		response, err := http.Get(fmt.Sprintf("http://localhost:%d/stuck", s.port))
+		if err == nil {
+			response.Body.Close()
+		}
+	}()
+	<-started
+
+	// Shutdown gives up on the in-flight request at the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected shutdown to exceed its deadline, got %v", err)
+	}
+}
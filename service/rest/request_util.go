@@ -9,8 +9,31 @@ package rest
 
 const (
 	// REST request headers and expected header values.
-	headerContentType         = "Content-Type"
-	headerRequestID           = "request_id"
-	contentTypeFormUrlEncoded = "application/x-www-form-urlencoded"
-	contentTypeJson           = "application/json"
+	headerContentType             = "Content-Type"
+	headerContentTransferEncoding = "Content-Transfer-Encoding"
+	headerRequestID               = "request_id"
+	headerRetryAfter              = "Retry-After"
+	headerWWWAuthenticate         = "WWW-Authenticate"
+	contentTypeFormUrlEncoded     = "application/x-www-form-urlencoded"
+	contentTypeJson               = "application/json"
+
+	// Content types used by the EST enrollment endpoints.
+	contentTypePkcs10         = "application/pkcs10"
+	contentTypePkcs7Mime      = "application/pkcs7-mime"
+	contentTypePkcs7CertsOnly = "application/pkcs7-mime; smime-type=certs-only"
+	contentTypeCsrAttrs       = "application/csrattrs"
+	transferEncodingBase64    = "base64"
+
+	// Content types used by the SCEP responder.
+	contentTypeTextPlain    = "text/plain"
+	contentTypeX509CaRaCert = "application/x-x509-ca-ra-cert"
+	contentTypePkiMessage   = "application/x-pki-message"
+
+	// Headers and content types used by the CA certificate distribution
+	// endpoints.
+	headerCacheControl  = "Cache-Control"
+	headerETag          = "ETag"
+	headerIfNoneMatch   = "If-None-Match"
+	contentTypePemFile  = "application/x-pem-file"
+	contentTypePkixCert = "application/pkix-cert"
 )
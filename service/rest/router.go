@@ -67,12 +67,12 @@ func commonRequestHandler(inner http.Handler, name string) http.Handler {
 	})
 }
 
-// Initializes the REST request router for the DSTS service and registers all
-// routes and their corresponding handler functions.
-func initRequestRouter() *mux.Router {
+// Initializes the REST request router for the DSTS service and registers the
+// specified routes and their corresponding handler functions.
+func initRequestRouter(routeList routes) *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
 
-	for _, route := range registeredRoutes {
+	for _, route := range routeList {
 		var handler http.Handler
 		handler = route.HandlerFunc
 		handler = commonRequestHandler(handler, route.Name)
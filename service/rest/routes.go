@@ -10,6 +10,7 @@ package rest
 import (
 	"net/http"
 
+	"github.com/HPInc/krypton-ca/service/acme"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -26,13 +27,19 @@ type routes []Route
 // List of registered REST routes and corresponding HTTP handler functions
 // used to serve requests at those routes.
 var registeredRoutes = routes{
-	// Health endpoint.
+	// Health endpoints, reporting the liveness and the readiness of the CA.
 	Route{
 		"GetHealth",
 		"GET",
 		"/health",
 		GetHealthHandler,
 	},
+	Route{
+		"GetReadiness",
+		"GET",
+		"/health/ready",
+		GetReadinessHandler,
+	},
 
 	// Metrics endpoint.
 	Route{
@@ -41,4 +48,187 @@ var registeredRoutes = routes{
 		"/metrics",
 		promhttp.Handler().(http.HandlerFunc),
 	},
+
+	// Public CA certificate distribution endpoints, serving the root CA
+	// certificate, the common signing certificate and the signing
+	// certificate chain used within each tenant.
+	Route{
+		"GetCARootCertificate",
+		"GET",
+		"/ca/root.{format:pem|der|p7b}",
+		GetCARootCertificateHandler,
+	},
+	Route{
+		"GetCASigningCertificate",
+		"GET",
+		"/ca/signing.{format:pem|der|p7b}",
+		GetCASigningCertificateHandler,
+	},
+	Route{
+		"GetCAChain",
+		"GET",
+		"/ca/{tenant}/chain.{format:pem|p7b}",
+		GetCAChainHandler,
+	},
+}
+
+// List of REST/JSON gateway routes, exposing each of the RPCs served by the
+// CA gRPC server. These are registered only if the gateway is enabled in the
+// configuration.
+var gatewayRoutes = routes{
+	// Retrieve the signing certificate of the tenant.
+	Route{
+		"GatewayGetTenantSigningCertificate",
+		"GET",
+		"/api/v1/tenants/{tenant}/signing_certificate",
+		GatewayGetTenantSigningCertificateHandler,
+	},
+
+	// Issue a device certificate to a new device within the tenant.
+	Route{
+		"GatewayCreateDeviceCertificate",
+		"POST",
+		"/api/v1/tenants/{tenant}/devices",
+		GatewayCreateDeviceCertificateHandler,
+	},
+
+	// Issue device certificates to a batch of new devices within the tenant.
+	Route{
+		"GatewayCreateDeviceCertificates",
+		"POST",
+		"/api/v1/tenants/{tenant}/devices/batch",
+		GatewayCreateDeviceCertificatesHandler,
+	},
+
+	// Renew the device certificate of a device within the tenant.
+	Route{
+		"GatewayRenewDeviceCertificate",
+		"POST",
+		"/api/v1/tenants/{tenant}/devices/{device}/renew",
+		GatewayRenewDeviceCertificateHandler,
+	},
+
+	// Retrieve the device quota of the tenant.
+	Route{
+		"GatewayGetTenantQuota",
+		"GET",
+		"/api/v1/tenants/{tenant}/quota",
+		GatewayGetTenantQuotaHandler,
+	},
+
+	// Retrieve the CA certificates, optionally along with the signing
+	// certificate used within the tenant.
+	Route{
+		"GatewayGetCACertificates",
+		"GET",
+		"/api/v1/ca_certificates",
+		GatewayGetCACertificatesHandler,
+	},
+	Route{
+		"GatewayGetTenantCACertificates",
+		"GET",
+		"/api/v1/tenants/{tenant}/ca_certificates",
+		GatewayGetCACertificatesHandler,
+	},
+
+	// Health/uptime check.
+	Route{
+		"GatewayPing",
+		"GET",
+		"/api/v1/ping",
+		GatewayPingHandler,
+	},
+}
+
+// List of REST/JSON gateway routes which create or delete tenants and set
+// their device quotas. These are registered only if the gateway is enabled and
+// administrators can be authenticated using TLS client certificates.
+var gatewayAdminRoutes = routes{
+	// Create and delete the signing certificate of the tenant.
+	Route{
+		"GatewayCreateTenantSigningCertificate",
+		"POST",
+		"/api/v1/tenants/{tenant}/signing_certificate",
+		GatewayCreateTenantSigningCertificateHandler,
+	},
+	Route{
+		"GatewayDeleteTenantSigningCertificate",
+		"DELETE",
+		"/api/v1/tenants/{tenant}/signing_certificate",
+		GatewayDeleteTenantSigningCertificateHandler,
+	},
+
+	// Set the device quota of the tenant.
+	Route{
+		"GatewaySetTenantQuota",
+		"PUT",
+		"/api/v1/tenants/{tenant}/quota",
+		GatewaySetTenantQuotaHandler,
+	},
+}
+
+// List of EST (RFC 7030) enrollment routes. These are registered only if EST
+// enrollment is enabled in the configuration.
+var estRoutes = routes{
+	// Retrieve the CA certificates used within the tenant.
+	Route{
+		"GetEstCACerts",
+		"GET",
+		"/.well-known/est/{tenant}/cacerts",
+		GetEstCACertsHandler,
+	},
+
+	// Enroll a new device within the tenant.
+	Route{
+		"EstSimpleEnroll",
+		"POST",
+		"/.well-known/est/{tenant}/simpleenroll",
+		EstSimpleEnrollHandler,
+	},
+
+	// Renew the device certificate of an enrolled device.
+	Route{
+		"EstSimpleReenroll",
+		"POST",
+		"/.well-known/est/{tenant}/simplereenroll",
+		EstSimpleReenrollHandler,
+	},
+
+	// Retrieve the attributes expected in certificate signing requests.
+	Route{
+		"GetEstCsrAttrs",
+		"GET",
+		"/.well-known/est/{tenant}/csrattrs",
+		GetEstCsrAttrsHandler,
+	},
+}
+
+// List of SCEP (RFC 8894) routes. These are registered only if the SCEP
+// responder is enabled in the configuration.
+var scepRoutes = routes{
+	// Serve SCEP operations sent using GET requests.
+	Route{
+		"GetScep",
+		"GET",
+		"/scep/{tenant}",
+		ScepHandler,
+	},
+
+	// Serve PKI operations sent using POST requests.
+	Route{
+		"PostScep",
+		"POST",
+		"/scep/{tenant}",
+		ScepHandler,
+	},
+}
+
+// Returns the ACME (RFC 8555) routes served by the specified ACME server. These
+// are registered only if the ACME server is enabled in the configuration.
+func newAcmeRoutes(server *acme.Server) routes {
+	acmeRoutes := routes{}
+	for _, route := range server.Routes() {
+		acmeRoutes = append(acmeRoutes, Route(route))
+	}
+	return acmeRoutes
 }
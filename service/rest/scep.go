@@ -0,0 +1,515 @@
+// package github.com/HPInc/krypton-ca/service/rest
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the SCEP (Simple Certificate Enrollment Protocol - RFC 8894)
+// responder served at the CA's REST endpoint, for managed devices which only
+// support SCEP through their MDM profile. Devices encrypt their certificate
+// signing requests using the certificate of the CA's registration authority
+// (RA), and authenticate using the challenge password of their tenant. Device
+// certificates are issued within the tenant using the KMS provider, and
+// returned in a response signed by the RA. Enrollment requests are subject to
+// the rate limits of the CA.
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"github.com/gorilla/mux"
+	"go.mozilla.org/pkcs7"
+	"go.uber.org/zap"
+)
+
+const (
+	// Name of the path variable specifying the tenant in SCEP requests.
+	scepTenantVar = "tenant"
+
+	// Query parameters of SCEP requests.
+	scepOperationParam = "operation"
+	scepMessageParam   = "message"
+
+	// SCEP operations.
+	scepOpGetCACaps    = "GetCACaps"
+	scepOpGetCACert    = "GetCACert"
+	scepOpPKIOperation = "PKIOperation"
+	scepOpUnknown      = "unknown"
+
+	// Capabilities of the SCEP responder reported by GetCACaps.
+	scepCapabilities = "POSTPKIOperation\nSHA-256\nAES\nSCEPStandard\n"
+
+	// SCEP message types.
+	scepMessageTypeCertRep = "3"
+	scepMessageTypePKCSReq = "19"
+
+	// SCEP PKI status values.
+	scepPkiStatusSuccess = "0"
+	scepPkiStatusFailure = "2"
+
+	// SCEP failure reasons.
+	scepFailInfoBadMessageCheck = "1"
+	scepFailInfoBadRequest      = "2"
+
+	// Maximum size of a SCEP PKI message.
+	maxScepRequestSize = 64 * 1024
+)
+
+var (
+	// Object identifiers of the SCEP signed attributes.
+	oidScepMessageType    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 2}
+	oidScepPkiStatus      = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 3}
+	oidScepFailInfo       = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 4}
+	oidScepSenderNonce    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 5}
+	oidScepRecipientNonce = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 6}
+	oidScepTransactionID  = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 7}
+
+	// Object identifier of the challenge password attribute of CSRs.
+	oidChallengePassword = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 7}
+
+	// Returned when the PKI message is malformed or its signature is invalid.
+	errScepBadMessage = caerrors.New(caerrors.InvalidInput,
+		"invalid SCEP message")
+
+	// Returned when the challenge password in the CSR is incorrect.
+	errScepBadChallengePassword = caerrors.New(caerrors.PolicyViolation,
+		"invalid challenge password")
+
+	// Certificate and key of the registration authority, and the secret
+	// used to derive challenge passwords.
+	scepRaCert          *x509.Certificate
+	scepRaKey           *rsa.PrivateKey
+	scepChallengeSecret []byte
+)
+
+// A verified SCEP PKI message received from a device.
+type scepRequest struct {
+	// Certificate used by the device to sign the message. Responses are
+	// encrypted using this certificate.
+	signer *x509.Certificate
+
+	// The SCEP message type, transaction ID and sender nonce.
+	messageType   string
+	transactionID string
+	senderNonce   []byte
+}
+
+// Loads the RA certificate and key used by the SCEP responder.
+func initScep(settings *config.Scep) error {
+	keyPair, err := tls.LoadX509KeyPair(settings.RaCertFile, settings.RaKeyFile)
+	if err != nil {
+		return err
+	}
+
+	raKey, ok := keyPair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return errors.New("the SCEP RA key must be an RSA key")
+	}
+	raCert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return err
+	}
+
+	setScepRegistrationAuthority(raCert, raKey, settings.ChallengeSecret)
+	return nil
+}
+
+func setScepRegistrationAuthority(raCert *x509.Certificate,
+	raKey *rsa.PrivateKey, challengeSecret string) {
+	scepRaCert = raCert
+	scepRaKey = raKey
+	scepChallengeSecret = []byte(challengeSecret)
+
+	// Encrypt responses using AES, which is advertised in the CA's
+	// capabilities, rather than the library's default of DES.
+	pkcs7.ContentEncryptionAlgorithm = pkcs7.EncryptionAlgorithmAES128CBC
+}
+
+// ScepChallengePassword - returns the challenge password that devices within
+// the tenant must specify in SCEP enrollment requests. This is configured in
+// the MDM profiles deployed to the tenant's devices. The password is hex
+// encoded, since many SCEP clients encode it as a PrintableString.
+func ScepChallengePassword(challengeSecret string, tenantID string) string {
+	return hex.EncodeToString(
+		deriveScepChallengePassword([]byte(challengeSecret), tenantID))
+}
+
+func deriveScepChallengePassword(challengeSecret []byte, tenantID string) []byte {
+	mac := hmac.New(sha256.New, challengeSecret)
+	mac.Write([]byte(tenantID))
+	return mac.Sum(nil)
+}
+
+// ScepHandler - serves SCEP requests for the tenant. The SCEP operation is
+// specified using the operation query parameter.
+func ScepHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Query().Get(scepOperationParam) {
+	case scepOpGetCACaps:
+		writeScepResponse(w, scepOpGetCACaps, contentTypeTextPlain,
+			[]byte(scepCapabilities))
+
+	case scepOpGetCACert:
+		getScepCACert(w, r)
+
+	case scepOpPKIOperation:
+		scepPKIOperation(w, r)
+
+	default:
+		writeScepError(w, scepOpUnknown, caerrors.New(caerrors.InvalidInput,
+			"unsupported SCEP operation"))
+	}
+}
+
+// Returns the RA certificate followed by the certificates used to sign device
+// certificates issued within the tenant.
+func getScepCACert(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)[scepTenantVar]
+
+	chain, err := kmsProvider.GetSigningCertificateChain(r.Context(), tenantID)
+	if err == nil {
+		var parsedChain *pkcs7.PKCS7
+		parsedChain, err = pkcs7.Parse(chain)
+		if err == nil {
+			certs := append([]byte{}, scepRaCert.Raw...)
+			for _, cert := range parsedChain.Certificates {
+				certs = append(certs, cert.Raw...)
+			}
+			chain, err = pkcs7.DegenerateCertificate(certs)
+		}
+	}
+	if err != nil {
+		caLogger.Error("SCEP: Failed to get the signing certificate chain for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", r.Header.Get(headerRequestID)),
+			zap.Error(err),
+		)
+		writeScepError(w, scepOpGetCACert, err)
+		return
+	}
+
+	writeScepResponse(w, scepOpGetCACert, contentTypeX509CaRaCert, chain)
+}
+
+// Reads the PKI message, which is sent in the body of POST requests or as the
+// base64 encoded message query parameter of GET requests.
+func readScepMessage(r *http.Request) ([]byte, error) {
+	if r.Method == http.MethodGet {
+		message, err := base64.StdEncoding.DecodeString(
+			r.URL.Query().Get(scepMessageParam))
+		if err != nil {
+			return nil, fmt.Errorf("%w: message is not base64 encoded",
+				errScepBadMessage)
+		}
+		return message, nil
+	}
+
+	message, err := io.ReadAll(io.LimitReader(r.Body, maxScepRequestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read the message", errScepBadMessage)
+	}
+	if len(message) > maxScepRequestSize {
+		return nil, fmt.Errorf("%w: message is too large", errScepBadMessage)
+	}
+	return message, nil
+}
+
+// Parses the PKI message and verifies its signature. The certificate signing
+// request is decrypted using the RA key.
+func parseScepMessage(message []byte) (*scepRequest, []byte, error) {
+	p7, err := pkcs7.Parse(message)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", errScepBadMessage, err)
+	}
+	err = p7.Verify()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", errScepBadMessage, err)
+	}
+
+	request := &scepRequest{signer: p7.GetOnlySigner()}
+	if request.signer == nil {
+		return nil, nil, fmt.Errorf("%w: message must have a single signer",
+			errScepBadMessage)
+	}
+	err = p7.UnmarshalSignedAttribute(oidScepMessageType, &request.messageType)
+	if err == nil {
+		err = p7.UnmarshalSignedAttribute(oidScepTransactionID,
+			&request.transactionID)
+	}
+	if err == nil {
+		err = p7.UnmarshalSignedAttribute(oidScepSenderNonce,
+			&request.senderNonce)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", errScepBadMessage, err)
+	}
+
+	envelope, err := pkcs7.Parse(p7.Content)
+	if err != nil {
+		return request, nil, fmt.Errorf("%w: %v", errScepBadMessage, err)
+	}
+	csr, err := envelope.Decrypt(scepRaCert, scepRaKey)
+	if err != nil {
+		return request, nil, fmt.Errorf("%w: failed to decrypt the message: %v",
+			errScepBadMessage, err)
+	}
+	return request, csr, nil
+}
+
+// Returns the challenge password specified in the attributes of the CSR. The
+// attribute is not exposed by the x509 package, so the CSR is parsed here.
+func getChallengePassword(csr []byte) (string, error) {
+	var parsedCsr struct {
+		TBS struct {
+			Version       int
+			Subject       asn1.RawValue
+			PublicKey     asn1.RawValue
+			RawAttributes []asn1.RawValue `asn1:"tag:0"`
+		}
+		SignatureAlgorithm asn1.RawValue
+		Signature          asn1.BitString
+	}
+	_, err := asn1.Unmarshal(csr, &parsedCsr)
+	if err != nil {
+		return "", err
+	}
+
+	for _, rawAttribute := range parsedCsr.TBS.RawAttributes {
+		var attribute struct {
+			Type   asn1.ObjectIdentifier
+			Values []asn1.RawValue `asn1:"set"`
+		}
+		_, err = asn1.Unmarshal(rawAttribute.FullBytes, &attribute)
+		if (err != nil) || !attribute.Type.Equal(oidChallengePassword) ||
+			(len(attribute.Values) != 1) {
+			continue
+		}
+
+		var password string
+		_, err = asn1.Unmarshal(attribute.Values[0].FullBytes, &password)
+		if err != nil {
+			return "", err
+		}
+		return password, nil
+	}
+	return "", nil
+}
+
+// Checks that the CSR specifies the challenge password of the tenant.
+func validateChallengePassword(tenantID string, csr []byte) error {
+	password, err := getChallengePassword(csr)
+	if err != nil {
+		return fmt.Errorf("%w: failed to parse the CSR", errScepBadMessage)
+	}
+
+	decoded, err := hex.DecodeString(password)
+	if (err != nil) || !hmac.Equal(decoded,
+		deriveScepChallengePassword(scepChallengeSecret, tenantID)) {
+		return errScepBadChallengePassword
+	}
+	return nil
+}
+
+// Processes a PKI message. Device certificates are issued in response to
+// PKCSReq messages.
+func scepPKIOperation(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)[scepTenantVar]
+	requestID := r.Header.Get(headerRequestID)
+
+	if !allowEnrollmentRequest(w, r, enrollmentProtocolScep,
+		scepOpPKIOperation, tenantID, metrics.MetricScepRequests) {
+		return
+	}
+
+	message, err := readScepMessage(r)
+	if err != nil {
+		writeScepError(w, scepOpPKIOperation, err)
+		return
+	}
+
+	// Messages whose signature cannot be verified are rejected outright.
+	// Failures after the signer is known are reported in a CertRep message.
+	request, csr, err := parseScepMessage(message)
+	if request == nil {
+		caLogger.Error("SCEP: Invalid PKI message!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		writeScepError(w, scepOpPKIOperation, err)
+		return
+	}
+	if err == nil && request.messageType != scepMessageTypePKCSReq {
+		err = fmt.Errorf("%w: unsupported message type %s", errScepBadMessage,
+			request.messageType)
+	}
+	if err == nil {
+		err = validateChallengePassword(tenantID, csr)
+	}
+	if err != nil {
+		caLogger.Error("SCEP: Rejected the enrollment request!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.String("Transaction ID:", request.transactionID),
+			zap.Error(err),
+		)
+		failInfo := scepFailInfoBadRequest
+		if csr == nil {
+			failInfo = scepFailInfoBadMessageCheck
+		}
+		writeScepFailure(w, request, failInfo)
+		return
+	}
+
+	// Ensure that the tenant has not reached its device cap.
+	reservation, err := quotaManager.ReserveDevice(r.Context(), tenantID)
+	if err != nil {
+		caLogger.Error("SCEP: Device cap check failed for the tenant!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		writeScepFailure(w, request, scepFailInfoBadRequest)
+		return
+	}
+	defer reservation.Release()
+
+	deviceID, deviceCert, _, expiresAt, err := kmsProvider.CreateDeviceCertificate(
+		r.Context(), tenantID, csr)
+	if err != nil {
+		caLogger.Error("SCEP: Failed to generate device certificate!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		if caerrors.Is(err, caerrors.InvalidInput) {
+			writeScepFailure(w, request, scepFailInfoBadRequest)
+			return
+		}
+		writeScepError(w, scepOpPKIOperation, err)
+		return
+	}
+
+	// Track the newly issued device against the tenant's device cap.
+	_ = reservation.RecordDevice(r.Context(), deviceID, expiresAt)
+
+	certs, err := pkcs7.DegenerateCertificate(deviceCert)
+	if err == nil {
+		certs, err = pkcs7.Encrypt(certs, []*x509.Certificate{request.signer})
+	}
+	var response []byte
+	if err == nil {
+		response, err = newScepCertRep(request, scepPkiStatusSuccess, "", certs)
+	}
+	if err != nil {
+		caLogger.Error("SCEP: Failed to build the enrollment response!",
+			zap.String("Tenant ID:", tenantID),
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		writeScepError(w, scepOpPKIOperation, err)
+		return
+	}
+
+	metrics.MetricDeviceCertificatesIssued.Inc()
+	caLogger.Info("SCEP: Issued a device certificate.",
+		zap.String("Tenant ID:", tenantID),
+		zap.String("Device ID:", deviceID),
+		zap.String("Request ID:", requestID),
+		zap.String("Transaction ID:", request.transactionID),
+	)
+	writeScepResponse(w, scepOpPKIOperation, contentTypePkiMessage, response)
+}
+
+// Builds a CertRep message in response to the request, signed using the RA
+// key. The content is only included in successful responses.
+func newScepCertRep(request *scepRequest, pkiStatus string, failInfo string,
+	content []byte) ([]byte, error) {
+	signedData, err := pkcs7.NewSignedData(content)
+	if err != nil {
+		return nil, err
+	}
+	signedData.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+
+	senderNonce := make([]byte, 16)
+	_, err = rand.Read(senderNonce)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := []pkcs7.Attribute{
+		{Type: oidScepTransactionID, Value: request.transactionID},
+		{Type: oidScepMessageType, Value: scepMessageTypeCertRep},
+		{Type: oidScepPkiStatus, Value: pkiStatus},
+		{Type: oidScepRecipientNonce, Value: request.senderNonce},
+		{Type: oidScepSenderNonce, Value: senderNonce},
+	}
+	if failInfo != "" {
+		attributes = append(attributes,
+			pkcs7.Attribute{Type: oidScepFailInfo, Value: failInfo})
+	}
+
+	err = signedData.AddSigner(scepRaCert, scepRaKey,
+		pkcs7.SignerInfoConfig{ExtraSignedAttributes: attributes})
+	if err != nil {
+		return nil, err
+	}
+	return signedData.Finish()
+}
+
+// Write a CertRep message reporting that the request failed.
+func writeScepFailure(w http.ResponseWriter, request *scepRequest,
+	failInfo string) {
+	response, err := newScepCertRep(request, scepPkiStatusFailure, failInfo, nil)
+	if err != nil {
+		writeScepError(w, scepOpPKIOperation, err)
+		return
+	}
+
+	metrics.MetricScepRequests.WithLabelValues(scepOpPKIOperation,
+		"failure").Inc()
+	w.Header().Set(headerContentType, contentTypePkiMessage)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(response)
+}
+
+// Write a successful SCEP response.
+func writeScepResponse(w http.ResponseWriter, operation string,
+	contentType string, body []byte) {
+	metrics.MetricScepRequests.WithLabelValues(operation,
+		strconv.Itoa(http.StatusOK)).Inc()
+
+	w.Header().Set(headerContentType, contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// Write a SCEP error response, with the HTTP status code chosen based on the
+// category of the error.
+func writeScepError(w http.ResponseWriter, operation string, err error) {
+	category := caerrors.CategoryOf(err)
+	statusCode := categoryHttpStatus[category]
+	metrics.MetricScepRequests.WithLabelValues(operation,
+		strconv.Itoa(statusCode)).Inc()
+
+	message := http.StatusText(statusCode)
+	if detail := caerrors.MessageOf(err); (detail != "") &&
+		(category != caerrors.Internal) {
+		message = message + ": " + detail
+	}
+	http.Error(w, message, statusCode)
+}
@@ -0,0 +1,288 @@
+package rest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/HPInc/krypton-ca/service/common"
+	"go.mozilla.org/pkcs7"
+)
+
+const testScepChallengeSecret = "scep-test-challenge-secret"
+
+// Create a self-signed certificate for the specified key.
+func newTestSelfSignedCertificate(key *rsa.PrivateKey,
+	commonName string) (*x509.Certificate, error) {
+	serialNumber, err := common.NewSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template,
+		&key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(certBytes)
+}
+
+// Create the RA certificate and key used by the SCEP responder in tests.
+func initTestScepRegistrationAuthority() error {
+	raKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	raCert, err := newTestSelfSignedCertificate(raKey, "Test SCEP RA")
+	if err != nil {
+		return err
+	}
+	setScepRegistrationAuthority(raCert, raKey, testScepChallengeSecret)
+	return nil
+}
+
+// Create a CSR specifying the challenge password. The x509 package cannot
+// add the challenge password attribute, so the CSR is built here.
+func newTestScepCsr(t *testing.T, key *rsa.PrivateKey, password string) []byte {
+	template, err := x509.CreateCertificateRequest(rand.Reader,
+		&x509.CertificateRequest{SignatureAlgorithm: x509.SHA256WithRSA}, key)
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+	parsedTemplate, err := x509.ParseCertificateRequest(template)
+	if err != nil {
+		t.Fatalf("Failed to parse CSR: %v", err)
+	}
+
+	passwordValue, err := asn1.MarshalWithParams(password, "printable")
+	if err != nil {
+		t.Fatalf("Failed to encode the challenge password: %v", err)
+	}
+	attribute, err := asn1.Marshal(struct {
+		Type   asn1.ObjectIdentifier
+		Values []asn1.RawValue `asn1:"set"`
+	}{oidChallengePassword, []asn1.RawValue{{FullBytes: passwordValue}}})
+	if err != nil {
+		t.Fatalf("Failed to encode the challenge password attribute: %v", err)
+	}
+
+	tbs, err := asn1.Marshal(struct {
+		Version       int
+		Subject       asn1.RawValue
+		PublicKey     asn1.RawValue
+		RawAttributes []asn1.RawValue `asn1:"tag:0"`
+	}{0, asn1.RawValue{FullBytes: parsedTemplate.RawSubject},
+		asn1.RawValue{FullBytes: parsedTemplate.RawSubjectPublicKeyInfo},
+		[]asn1.RawValue{{FullBytes: attribute}}})
+	if err != nil {
+		t.Fatalf("Failed to encode the CSR: %v", err)
+	}
+
+	digest := sha256.Sum256(tbs)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign the CSR: %v", err)
+	}
+	csr, err := asn1.Marshal(struct {
+		TBS                asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		Signature          asn1.BitString
+	}{asn1.RawValue{FullBytes: tbs},
+		pkix.AlgorithmIdentifier{Algorithm: oidSha256WithRsaEncryption,
+			Parameters: asn1.NullRawValue},
+		asn1.BitString{Bytes: signature, BitLength: len(signature) * 8}})
+	if err != nil {
+		t.Fatalf("Failed to encode the CSR: %v", err)
+	}
+	return csr
+}
+
+// Send a SCEP request and return the response status and body.
+func doScepRequest(t *testing.T, method string, operation string,
+	body []byte) (int, []byte) {
+	request, err := http.NewRequest(method, fmt.Sprintf("%s/scep/%s?operation=%s",
+		gTestServer.URL, gTestTenantID, operation), bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	response, err := gTestServer.Client().Do(request)
+	if err != nil {
+		t.Fatalf("SCEP request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("Failed to read the response: %v", err)
+	}
+	return response.StatusCode, responseBody
+}
+
+// Send a PKCSReq message for the CSR, and return the verified CertRep message
+// and the device key and certificate used to sign the request.
+func doScepEnrollment(t *testing.T, password string) (*pkcs7.PKCS7,
+	*rsa.PrivateKey, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate device key: %v", err)
+	}
+	signerCert, err := newTestSelfSignedCertificate(key, "Test SCEP device")
+	if err != nil {
+		t.Fatalf("Failed to create the signer certificate: %v", err)
+	}
+
+	envelope, err := pkcs7.Encrypt(newTestScepCsr(t, key, password),
+		[]*x509.Certificate{scepRaCert})
+	if err != nil {
+		t.Fatalf("Failed to encrypt the CSR: %v", err)
+	}
+	signedData, err := pkcs7.NewSignedData(envelope)
+	if err != nil {
+		t.Fatalf("Failed to create the PKI message: %v", err)
+	}
+	signedData.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	err = signedData.AddSigner(signerCert, key, pkcs7.SignerInfoConfig{
+		ExtraSignedAttributes: []pkcs7.Attribute{
+			{Type: oidScepMessageType, Value: scepMessageTypePKCSReq},
+			{Type: oidScepTransactionID, Value: "test-transaction"},
+			{Type: oidScepSenderNonce, Value: []byte("test-sender-nonce")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to sign the PKI message: %v", err)
+	}
+	message, err := signedData.Finish()
+	if err != nil {
+		t.Fatalf("Failed to create the PKI message: %v", err)
+	}
+
+	status, body := doScepRequest(t, http.MethodPost, scepOpPKIOperation, message)
+	if status != http.StatusOK {
+		t.Fatalf("PKIOperation failed with status %d: %s", status, body)
+	}
+
+	certRep, err := pkcs7.Parse(body)
+	if err != nil {
+		t.Fatalf("Failed to parse the CertRep message: %v", err)
+	}
+	err = certRep.Verify()
+	if err != nil {
+		t.Fatalf("Failed to verify the CertRep message: %v", err)
+	}
+
+	var recipientNonce []byte
+	err = certRep.UnmarshalSignedAttribute(oidScepRecipientNonce, &recipientNonce)
+	if (err != nil) || (string(recipientNonce) != "test-sender-nonce") {
+		t.Errorf("CertRep message does not specify the sender nonce: %v", err)
+	}
+	return certRep, key, signerCert
+}
+
+func TestScepGetCACaps(t *testing.T) {
+	status, body := doScepRequest(t, http.MethodGet, scepOpGetCACaps, nil)
+	if status != http.StatusOK {
+		t.Fatalf("GetCACaps failed with status %d: %s", status, body)
+	}
+	if !bytes.Contains(body, []byte("POSTPKIOperation")) {
+		t.Errorf("Capabilities do not include POSTPKIOperation: %s", body)
+	}
+}
+
+func TestScepGetCACert(t *testing.T) {
+	status, body := doScepRequest(t, http.MethodGet, scepOpGetCACert, nil)
+	if status != http.StatusOK {
+		t.Fatalf("GetCACert failed with status %d: %s", status, body)
+	}
+
+	certs := parseCertsOnlyResponse(t, body)
+	if len(certs) != 3 {
+		t.Fatalf("Expected the RA, signing and CA certificates, got %d certificates",
+			len(certs))
+	}
+	if !certs[0].Equal(scepRaCert) {
+		t.Errorf("The RA certificate was not returned first")
+	}
+}
+
+func TestScepPKIOperation(t *testing.T) {
+	certRep, key, signerCert := doScepEnrollment(t,
+		ScepChallengePassword(testScepChallengeSecret, gTestTenantID))
+
+	var pkiStatus string
+	err := certRep.UnmarshalSignedAttribute(oidScepPkiStatus, &pkiStatus)
+	if (err != nil) || (pkiStatus != scepPkiStatusSuccess) {
+		t.Fatalf("Expected a successful CertRep message, got status %q: %v",
+			pkiStatus, err)
+	}
+
+	envelope, err := pkcs7.Parse(certRep.Content)
+	if err != nil {
+		t.Fatalf("Failed to parse the enveloped response: %v", err)
+	}
+	degenerate, err := envelope.Decrypt(signerCert, key)
+	if err != nil {
+		t.Fatalf("Failed to decrypt the response: %v", err)
+	}
+
+	certs := parseCertsOnlyResponse(t, degenerate)
+	if len(certs) != 1 {
+		t.Fatalf("Expected the device certificate, got %d certificates",
+			len(certs))
+	}
+	if (len(certs[0].Subject.Organization) == 0) ||
+		(certs[0].Subject.Organization[0] != gTestTenantID) {
+		t.Errorf("Device certificate was not issued within the tenant: %v",
+			certs[0].Subject)
+	}
+	if certs[0].PublicKey.(*rsa.PublicKey).N.Cmp(key.N) != 0 {
+		t.Errorf("Device certificate was not issued for the device key")
+	}
+}
+
+func TestScepPKIOperation_BadChallengePassword(t *testing.T) {
+	certRep, _, _ := doScepEnrollment(t,
+		ScepChallengePassword(testScepChallengeSecret, "another-tenant"))
+
+	var pkiStatus, failInfo string
+	err := certRep.UnmarshalSignedAttribute(oidScepPkiStatus, &pkiStatus)
+	if (err != nil) || (pkiStatus != scepPkiStatusFailure) {
+		t.Errorf("Expected a failed CertRep message, got status %q: %v",
+			pkiStatus, err)
+	}
+	err = certRep.UnmarshalSignedAttribute(oidScepFailInfo, &failInfo)
+	if (err != nil) || (failInfo != scepFailInfoBadRequest) {
+		t.Errorf("Expected failure reason %s, got %q: %v",
+			scepFailInfoBadRequest, failInfo, err)
+	}
+}
+
+func TestScepPKIOperation_InvalidMessage(t *testing.T) {
+	status, _ := doScepRequest(t, http.MethodPost, scepOpPKIOperation,
+		[]byte("not a pki message"))
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, status)
+	}
+
+	// Unknown operations are rejected.
+	status, _ = doScepRequest(t, http.MethodGet, "GetNextCACert", nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, status)
+	}
+}
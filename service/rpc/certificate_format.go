@@ -0,0 +1,97 @@
+// package github.com/HPInc/krypton-ca/service/rpc
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Encodes issued device certificates in the format requested by the caller.
+// KMS providers return the device certificate as DER bytes and its parent
+// certificates as a PKCS#7 degenerate "certs only" structure. Callers can
+// instead request the device certificate PEM encoded, or along with its
+// parents as a PKCS#7 structure or a PEM bundle. The individually encoded
+// certificate chain is returned to callers regardless of the format requested.
+// CA certificates are encoded in the same manner.
+package rpc
+
+import (
+	"bytes"
+	"encoding/pem"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"go.mozilla.org/pkcs7"
+)
+
+const (
+	// PEM block type of encoded certificates.
+	pemTypeCertificate = "CERTIFICATE"
+)
+
+// isValidCertificateFormat checks whether the certificate format requested by
+// the caller is supported.
+func isValidCertificateFormat(format pb.CertificateFormat) bool {
+	_, ok := pb.CertificateFormat_name[int32(format)]
+	return ok
+}
+
+// isPemCertificateFormat checks whether certificates are to be PEM encoded
+// in the requested format.
+func isPemCertificateFormat(format pb.CertificateFormat) bool {
+	return (format == pb.CertificateFormat_CERTIFICATE_FORMAT_PEM) ||
+		(format == pb.CertificateFormat_CERTIFICATE_FORMAT_PEM_CHAIN)
+}
+
+// encodeCertificate PEM encodes the DER encoded certificate if a PEM format
+// was requested. Otherwise, the DER encoded certificate is returned.
+func encodeCertificate(format pb.CertificateFormat, cert []byte) []byte {
+	if !isPemCertificateFormat(format) {
+		return cert
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  pemTypeCertificate,
+		Bytes: cert,
+	})
+}
+
+// encodeCertificateChain encodes the DER encoded certificate chain (in leaf to
+// root order) in the requested format. The leaf certificate is returned for
+// the DER and PEM formats, and the whole chain for the PKCS7 and PEM_CHAIN
+// formats. The individually encoded certificates are also returned.
+func encodeCertificateChain(format pb.CertificateFormat,
+	derChain [][]byte) ([]byte, [][]byte, error) {
+	chain := make([][]byte, 0, len(derChain))
+	for _, cert := range derChain {
+		chain = append(chain, encodeCertificate(format, cert))
+	}
+
+	switch format {
+	case pb.CertificateFormat_CERTIFICATE_FORMAT_PKCS7:
+		certs, err := pkcs7.DegenerateCertificate(bytes.Join(derChain, nil))
+		if err != nil {
+			return nil, nil, err
+		}
+		return certs, chain, nil
+
+	case pb.CertificateFormat_CERTIFICATE_FORMAT_PEM_CHAIN:
+		return bytes.Join(chain, nil), chain, nil
+	}
+
+	return chain[0], chain, nil
+}
+
+// formatDeviceCertificate encodes the device certificate in the requested
+// format, and returns it along with the certificate chain - the device
+// certificate followed by its parent certificates in leaf to root order. The
+// certificates in the chain are PEM encoded if a PEM format was requested, and
+// DER encoded otherwise.
+func formatDeviceCertificate(format pb.CertificateFormat, deviceCert []byte,
+	parentCerts []byte) ([]byte, [][]byte, error) {
+	parents, err := pkcs7.Parse(parentCerts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	derChain := [][]byte{deviceCert}
+	for _, cert := range parents.Certificates {
+		derChain = append(derChain, cert.Raw)
+	}
+	return encodeCertificateChain(format, derChain)
+}
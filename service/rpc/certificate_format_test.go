@@ -0,0 +1,187 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/common"
+	"go.mozilla.org/pkcs7"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Parse the certificate chain returned in the response, decoding PEM encoded
+// certificates if requested, and ensure it is in leaf to root order.
+func checkCertificateChain(t *testing.T, chain [][]byte,
+	pemEncoded bool) []*x509.Certificate {
+	certs := []*x509.Certificate{}
+	for _, encoded := range chain {
+		if pemEncoded {
+			block, rest := pem.Decode(encoded)
+			if (block == nil) || (block.Type != pemTypeCertificate) ||
+				(len(rest) != 0) {
+				t.Fatalf("Chain certificate is not PEM encoded: %s", encoded)
+			}
+			encoded = block.Bytes
+		}
+		cert, err := x509.ParseCertificate(encoded)
+		if err != nil {
+			t.Fatalf("Failed to parse chain certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) != 3 {
+		t.Fatalf("Expected the device, tenant signing and CA certificates, got %d certificates",
+			len(certs))
+	}
+	for i := 0; i < len(certs)-1; i++ {
+		err := certs[i].CheckSignatureFrom(certs[i+1])
+		if err != nil {
+			t.Errorf("Chain certificate %d was not issued by certificate %d: %v",
+				i, i+1, err)
+		}
+	}
+	return certs
+}
+
+func TestCreateDeviceCertificate_Formats(t *testing.T) {
+	for _, format := range []pb.CertificateFormat{
+		pb.CertificateFormat_CERTIFICATE_FORMAT_UNSPECIFIED,
+		pb.CertificateFormat_CERTIFICATE_FORMAT_DER,
+		pb.CertificateFormat_CERTIFICATE_FORMAT_PEM,
+		pb.CertificateFormat_CERTIFICATE_FORMAT_PKCS7,
+		pb.CertificateFormat_CERTIFICATE_FORMAT_PEM_CHAIN,
+	} {
+		csr, err := common.CreateDeviceCertificateSigningRequest()
+		if err != nil {
+			t.Fatalf("Error creating CSR: %v", err)
+		}
+
+		response, err := gClient.CreateDeviceCertificate(gCtx,
+			&pb.CreateDeviceCertificateRequest{
+				Header:  newCaV2ProtocolHeader(),
+				Version: CaProtocolVersion,
+				Tid:     testTenantID,
+				Csr:     csr,
+				Format:  format,
+			})
+		if err != nil {
+			caLogger.Error("TestCreateDeviceCertificate_Formats: RPC failed",
+				zap.String("Format:", format.String()),
+				zap.Error(err))
+			t.Fail()
+			continue
+		}
+
+		pemEncoded := (format == pb.CertificateFormat_CERTIFICATE_FORMAT_PEM) ||
+			(format == pb.CertificateFormat_CERTIFICATE_FORMAT_PEM_CHAIN)
+		certs := checkCertificateChain(t, response.Chain, pemEncoded)
+		if certs[0].Subject.CommonName != response.DeviceId {
+			t.Errorf("%s: the chain does not start with the device certificate",
+				format)
+		}
+
+		var expected []byte
+		switch format {
+		case pb.CertificateFormat_CERTIFICATE_FORMAT_PEM:
+			expected = response.Chain[0]
+		case pb.CertificateFormat_CERTIFICATE_FORMAT_PEM_CHAIN:
+			expected = bytes.Join(response.Chain, nil)
+		case pb.CertificateFormat_CERTIFICATE_FORMAT_PKCS7:
+			p7, err := pkcs7.Parse(response.DeviceCertificate)
+			if err != nil {
+				t.Fatalf("%s: failed to parse the device certificate: %v",
+					format, err)
+			}
+			if (len(p7.Certificates) != 3) || !p7.Certificates[0].Equal(certs[0]) {
+				t.Errorf("%s: expected the device certificate and its parents",
+					format)
+			}
+			expected = response.DeviceCertificate
+		default:
+			expected = certs[0].Raw
+		}
+		if !bytes.Equal(response.DeviceCertificate, expected) {
+			t.Errorf("%s: device certificate is not encoded as requested",
+				format)
+		}
+
+		// Parent certificates continue to be returned as a PKCS#7 structure,
+		// in the same order as the chain.
+		parents, err := pkcs7.Parse(response.ParentCertificates)
+		if err != nil {
+			t.Fatalf("%s: failed to parse the parent certificates: %v",
+				format, err)
+		}
+		if (len(parents.Certificates) != 2) ||
+			!parents.Certificates[0].Equal(certs[1]) {
+			t.Errorf("%s: parent certificates are not in leaf to root order",
+				format)
+		}
+	}
+}
+
+func TestCreateDeviceCertificate_V2InvalidFormat(t *testing.T) {
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		t.Fatalf("Error creating CSR: %v", err)
+	}
+
+	_, err = gClient.CreateDeviceCertificate(gCtx,
+		&pb.CreateDeviceCertificateRequest{
+			Header:  newCaV2ProtocolHeader(),
+			Version: CaProtocolVersion,
+			Tid:     testTenantID,
+			Csr:     csr,
+			Format:  pb.CertificateFormat(100),
+		})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected status code %s, got %s", codes.InvalidArgument,
+			status.Code(err))
+	}
+}
+
+func TestRenewDeviceCertificate_PemChain(t *testing.T) {
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		t.Fatalf("Error creating CSR: %v", err)
+	}
+	response, err := gClient.CreateDeviceCertificate(gCtx,
+		&pb.CreateDeviceCertificateRequest{
+			Header:  newCaV2ProtocolHeader(),
+			Version: CaProtocolVersion,
+			Tid:     testTenantID,
+			Csr:     csr,
+		})
+	if err != nil {
+		t.Fatalf("CreateDeviceCertificate RPC failed: %v", err)
+	}
+
+	renewResponse, err := gClient.RenewDeviceCertificate(gCtx,
+		&pb.RenewDeviceCertificateRequest{
+			Header:   newCaV2ProtocolHeader(),
+			Version:  CaProtocolVersion,
+			Tid:      testTenantID,
+			DeviceId: response.DeviceId,
+			Csr:      csr,
+			Format:   pb.CertificateFormat_CERTIFICATE_FORMAT_PEM_CHAIN,
+		})
+	if err != nil {
+		t.Fatalf("RenewDeviceCertificate RPC failed: %v", err)
+	}
+
+	certs := checkCertificateChain(t, renewResponse.Chain, true)
+	if certs[0].Subject.CommonName != response.DeviceId {
+		t.Errorf("Device ID changed on renewal: %s != %s",
+			certs[0].Subject.CommonName, response.DeviceId)
+	}
+	if !bytes.Equal(renewResponse.DeviceCertificate,
+		bytes.Join(renewResponse.Chain, nil)) {
+		t.Errorf("Device certificate is not returned as a PEM bundle")
+	}
+}
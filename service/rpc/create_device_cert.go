@@ -5,14 +5,20 @@
 // Purpose:
 // Implements the CreateDeviceCertificate RPC used to create a new device
 // certificate for the specified device. It assigns a new device identifier for
-// the device and asserts the identifier within the issued certificate.
+// the device and asserts the identifier within the issued certificate. Device
+// certificates are only issued if the tenant has not reached its device cap.
+// Retries of a request specifying the same request ID return the device
+// certificate issued in response to the original request.
 package rpc
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/certmgr/idempotency"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
 	"github.com/HPInc/krypton-ca/service/metrics"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
@@ -38,24 +44,142 @@ func (s *CertificateAuthorityServer) CreateDeviceCertificate(ctx context.Context
 			zap.String("Request ID:", requestID),
 		)
 		response := invalidCreateDeviceCertificateResponse(requestID)
+		return response, badRequestError(request.Header, requestID,
+			"CreateDeviceCertificate RPC failed",
+			missingFieldViolations(
+				requiredField{"tid", request.Tid != ""},
+				requiredField{"csr", request.Csr != nil})...)
+	}
+
+	if !isValidCertificateFormat(request.Format) {
+		caLogger.Error("CreateDeviceCertificate: Unsupported certificate format requested!",
+			zap.String("Request ID:", requestID),
+			zap.Int32("Format:", int32(request.Format)),
+		)
+		response := invalidCreateDeviceCertificateResponse(requestID)
+		return response, badRequestError(request.Header, requestID,
+			"CreateDeviceCertificate RPC failed",
+			fieldViolation("format", "unsupported certificate format"))
+	}
+
+	// Check whether the request is a retry of an earlier request within the
+	// tenant. If so, return the device certificate issued earlier instead of
+	// enrolling a new device.
+	issued, err := s.idempotencyManager.Begin(ctx, request.Tid,
+		request.Header.RequestId, request.Csr)
+	if err != nil {
+		caLogger.Error("CreateDeviceCertificate: Failed to check for a retried request!",
+			zap.String("Request ID:", requestID),
+			zap.String("Tenant ID:", request.Tid),
+			zap.Error(err),
+		)
+		var response *pb.CreateDeviceCertificateResponse
+		switch {
+		case errors.Is(err, idempotency.ErrRequestMismatch):
+			response = requestMismatchCreateDeviceCertificateResponse(requestID)
+		case errors.Is(err, idempotency.ErrRequestInProgress):
+			response = requestInProgressCreateDeviceCertificateResponse(requestID)
+		default:
+			response = internalErrorCreateDeviceCertificateResponse(requestID)
+		}
+		return response, statusErrorFromErr(request.Header, requestID,
+			"CreateDeviceCertificate RPC failed", err)
+	}
+	if issued != nil {
+		certificate, chain, err := formatDeviceCertificate(request.Format,
+			issued.DeviceCertificate, issued.ParentCertificates)
+		if err != nil {
+			caLogger.Error("CreateDeviceCertificate: Failed to encode the device certificate!",
+				zap.String("Request ID:", requestID),
+				zap.String("Tenant ID:", request.Tid),
+				zap.Error(err),
+			)
+			response := internalErrorCreateDeviceCertificateResponse(requestID)
+			return response, statusErrorFromErr(request.Header, requestID,
+				"CreateDeviceCertificate RPC failed", err)
+		}
+
+		response := replayedCreateDeviceCertificateResponse(requestID, issued,
+			certificate, chain)
 		return response, nil
 	}
 
+	// Ensure that the tenant has not reached its device cap.
+	reservation, err := s.quotaManager.ReserveDevice(ctx, request.Tid)
+	if err != nil {
+		s.idempotencyManager.Abandon(ctx, request.Tid,
+			request.Header.RequestId)
+		if errors.Is(err, quota.ErrDeviceCapReached) {
+			caLogger.Error("CreateDeviceCertificate: Tenant has reached its device cap!",
+				zap.String("Request ID:", requestID),
+				zap.String("Tenant ID:", request.Tid),
+			)
+			response := deviceCapReachedCreateDeviceCertificateResponse(requestID)
+			return response, statusErrorFromErr(request.Header, requestID,
+				"CreateDeviceCertificate RPC failed", err)
+		}
+
+		caLogger.Error("CreateDeviceCertificate: Failed to check the device cap for the tenant!",
+			zap.String("Request ID:", requestID),
+			zap.String("Tenant ID:", request.Tid),
+			zap.Error(err),
+		)
+		response := internalErrorCreateDeviceCertificateResponse(requestID)
+		return response, statusErrorFromErr(request.Header, requestID,
+			"CreateDeviceCertificate RPC failed", err)
+	}
+
+	defer reservation.Release()
+
 	// Invoke the certificate store provider to issue a new device certificate.
 	deviceID, deviceCert, parentCerts, expiresAt, err := s.kmsProvider.CreateDeviceCertificate(
-		request.Tid, request.Csr)
+		ctx, request.Tid, request.Csr)
 	if err != nil {
+		s.idempotencyManager.Abandon(ctx, request.Tid,
+			request.Header.RequestId)
 		caLogger.Error("CreateDeviceCertificate: Failed to generate device certificate!",
 			zap.String("Request ID:", requestID),
 			zap.String("Tenant ID:", request.Tid),
 			zap.Error(err),
 		)
 		response := internalErrorCreateDeviceCertificateResponse(requestID)
-		return response, nil
+		return response, statusErrorFromErr(request.Header, requestID,
+			"CreateDeviceCertificate RPC failed", err)
+	}
+
+	// Track the newly issued device against the tenant's device cap. The
+	// device certificate has already been issued, so failures are logged
+	// (by the quota manager) but not returned to the caller.
+	_ = reservation.RecordDevice(ctx, deviceID, expiresAt)
+
+	// Retain the issued device certificate so that it can be returned if the
+	// request is retried. Failures are logged (by the idempotency manager)
+	// but not returned to the caller.
+	_ = s.idempotencyManager.Complete(ctx, request.Tid,
+		request.Header.RequestId, request.Csr, &idempotency.IssuedCertificate{
+			DeviceID:           deviceID,
+			DeviceCertificate:  deviceCert,
+			ParentCertificates: parentCerts,
+			IssuedAt:           time.Now(),
+			ExpiresAt:          expiresAt,
+		})
+
+	// Encode the device certificate in the format requested by the caller.
+	certificate, chain, err := formatDeviceCertificate(request.Format,
+		deviceCert, parentCerts)
+	if err != nil {
+		caLogger.Error("CreateDeviceCertificate: Failed to encode the device certificate!",
+			zap.String("Request ID:", requestID),
+			zap.String("Tenant ID:", request.Tid),
+			zap.Error(err),
+		)
+		response := internalErrorCreateDeviceCertificateResponse(requestID)
+		return response, statusErrorFromErr(request.Header, requestID,
+			"CreateDeviceCertificate RPC failed", err)
 	}
 
 	response := successCreateDeviceCertificateResponse(requestID, deviceID,
-		deviceCert, parentCerts, expiresAt)
+		certificate, parentCerts, chain, expiresAt)
 	return response, nil
 }
 
@@ -77,7 +201,8 @@ func invalidCreateDeviceCertificateResponse(
 
 func successCreateDeviceCertificateResponse(
 	requestID string, deviceID string, deviceCert []byte,
-	parentCerts []byte, expiresAt time.Time) *pb.CreateDeviceCertificateResponse {
+	parentCerts []byte, chain [][]byte,
+	expiresAt time.Time) *pb.CreateDeviceCertificateResponse {
 	response := &pb.CreateDeviceCertificateResponse{
 		Header: &pb.CaResponseHeader{
 			ProtocolVersion: CaProtocolVersion,
@@ -91,12 +216,83 @@ func successCreateDeviceCertificateResponse(
 		DeviceId:           deviceID,
 		DeviceCertificate:  deviceCert,
 		ParentCertificates: parentCerts,
+		Chain:              chain,
 	}
 
 	metrics.MetricDeviceCertificatesIssued.Inc()
 	return response
 }
 
+func replayedCreateDeviceCertificateResponse(requestID string,
+	issued *idempotency.IssuedCertificate, deviceCert []byte,
+	chain [][]byte) *pb.CreateDeviceCertificateResponse {
+	response := &pb.CreateDeviceCertificateResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.OK),
+			StatusMessage:   "CreateDeviceCertificate RPC successful",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+		IssuedTime:         timestamppb.New(issued.IssuedAt),
+		ExpiryTime:         timestamppb.New(issued.ExpiresAt),
+		DeviceId:           issued.DeviceID,
+		DeviceCertificate:  deviceCert,
+		ParentCertificates: issued.ParentCertificates,
+		Chain:              chain,
+	}
+
+	metrics.MetricCreateDeviceCertificateReplays.Inc()
+	return response
+}
+
+func requestMismatchCreateDeviceCertificateResponse(
+	requestID string) *pb.CreateDeviceCertificateResponse {
+	response := &pb.CreateDeviceCertificateResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.AlreadyExists),
+			StatusMessage:   "CreateDeviceCertificate RPC failed: request ID was already used for a different CSR",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	metrics.MetricCreateDeviceCertificateBadRequests.Inc()
+	return response
+}
+
+func requestInProgressCreateDeviceCertificateResponse(
+	requestID string) *pb.CreateDeviceCertificateResponse {
+	response := &pb.CreateDeviceCertificateResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.Aborted),
+			StatusMessage:   "CreateDeviceCertificate RPC failed: a request with the same request ID is being processed",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	return response
+}
+
+func deviceCapReachedCreateDeviceCertificateResponse(
+	requestID string) *pb.CreateDeviceCertificateResponse {
+	response := &pb.CreateDeviceCertificateResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.ResourceExhausted),
+			StatusMessage:   "CreateDeviceCertificate RPC failed: device cap reached for tenant",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	metrics.MetricDeviceCapReached.Inc()
+	return response
+}
+
 func internalErrorCreateDeviceCertificateResponse(
 	requestID string) *pb.CreateDeviceCertificateResponse {
 	response := &pb.CreateDeviceCertificateResponse{
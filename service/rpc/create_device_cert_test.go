@@ -1,13 +1,16 @@
 package rpc
 
 import (
+	"bytes"
 	"testing"
 
 	pb "github.com/HPInc/krypton-ca/caprotos"
 	"github.com/HPInc/krypton-ca/service/common"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestCreateDeviceCertificate(t *testing.T) {
@@ -121,3 +124,193 @@ func TestCreateDeviceCertificate_NoTenantID(t *testing.T) {
 		zap.Any("Response", response),
 	)
 }
+
+func TestCreateDeviceCertificate_V2(t *testing.T) {
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		caLogger.Error("TestCreateDeviceCertificate_V2: Error creating CSR",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+
+	createRequest := &pb.CreateDeviceCertificateRequest{
+		Header:  newCaV2ProtocolHeader(),
+		Version: CaProtocolVersion,
+		Tid:     testTenantID,
+		Csr:     csr,
+	}
+
+	response, err := gClient.CreateDeviceCertificate(gCtx, createRequest)
+	if err != nil {
+		caLogger.Error("TestCreateDeviceCertificate_V2: RPC failed",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+
+	assertEqual(t, response.Header.Status, uint32(codes.OK))
+}
+
+func TestCreateDeviceCertificate_V2NoCsr(t *testing.T) {
+	createRequest := &pb.CreateDeviceCertificateRequest{
+		Header:  newCaV2ProtocolHeader(),
+		Version: CaProtocolVersion,
+		Tid:     testTenantID,
+	}
+
+	_, err := gClient.CreateDeviceCertificate(gCtx, createRequest)
+	st, _ := status.FromError(err)
+	assertEqual(t, st.Code(), codes.InvalidArgument)
+
+	var badRequest *errdetails.BadRequest
+	var errorInfo *errdetails.ErrorInfo
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.BadRequest:
+			badRequest = d
+		case *errdetails.ErrorInfo:
+			errorInfo = d
+		}
+	}
+	if (badRequest == nil) || (errorInfo == nil) {
+		caLogger.Error("TestCreateDeviceCertificate_V2NoCsr: missing error details",
+			zap.Any("Details", st.Details()))
+		t.Fail()
+		return
+	}
+
+	assertEqual(t, len(badRequest.FieldViolations), 1)
+	assertEqual(t, badRequest.FieldViolations[0].Field, "csr")
+	assertEqual(t, errorInfo.Reason, reasonInvalidRequest)
+	assertEqual(t, errorInfo.Metadata[errorInfoRequestIDField],
+		createRequest.Header.RequestId)
+}
+
+func TestCreateDeviceCertificate_V2InvalidCsr(t *testing.T) {
+	createRequest := &pb.CreateDeviceCertificateRequest{
+		Header:  newCaV2ProtocolHeader(),
+		Version: CaProtocolVersion,
+		Tid:     testTenantID,
+		Csr:     []byte("not a certificate signing request"),
+	}
+
+	_, err := gClient.CreateDeviceCertificate(gCtx, createRequest)
+	assertEqual(t, status.Code(err), codes.InvalidArgument)
+
+	// Version 1 callers continue to receive the failure in the response
+	// header.
+	createRequest.Header = newCaProtocolHeader()
+	response, err := gClient.CreateDeviceCertificate(gCtx, createRequest)
+	if err != nil {
+		caLogger.Error("TestCreateDeviceCertificate_V2InvalidCsr: RPC failed",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+	assertEqual(t, response.Header.Status, uint32(codes.Internal))
+}
+
+// Retry a create device certificate request using the same request ID and
+// ensure the same device ID and certificate are returned.
+func TestCreateDeviceCertificate_Retry(t *testing.T) {
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		caLogger.Error("TestCreateDeviceCertificate_Retry: Error creating CSR",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+
+	createRequest := &pb.CreateDeviceCertificateRequest{
+		Header:  newCaProtocolHeader(),
+		Version: CaProtocolVersion,
+		Tid:     testTenantID,
+		Csr:     csr,
+	}
+
+	response, err := gClient.CreateDeviceCertificate(gCtx, createRequest)
+	if err != nil {
+		caLogger.Error("TestCreateDeviceCertificate_Retry: RPC failed",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+	assertEqual(t, response.Header.Status, uint32(codes.OK))
+
+	retryResponse, err := gClient.CreateDeviceCertificate(gCtx, createRequest)
+	if err != nil {
+		caLogger.Error("TestCreateDeviceCertificate_Retry: Retried RPC failed",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+	assertEqual(t, retryResponse.Header.Status, uint32(codes.OK))
+
+	if (retryResponse.DeviceId != response.DeviceId) ||
+		!bytes.Equal(retryResponse.DeviceCertificate, response.DeviceCertificate) {
+		caLogger.Error("TestCreateDeviceCertificate_Retry: Retried request issued a new certificate",
+			zap.String("Device ID:", response.DeviceId),
+			zap.String("Retried device ID:", retryResponse.DeviceId))
+		t.Fail()
+	}
+}
+
+// Retry a create device certificate request using the same request ID but a
+// different CSR and ensure it is rejected.
+func TestCreateDeviceCertificate_RetryDifferentCsr(t *testing.T) {
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		caLogger.Error("TestCreateDeviceCertificate_RetryDifferentCsr: Error creating CSR",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+
+	createRequest := &pb.CreateDeviceCertificateRequest{
+		Header:  newCaV2ProtocolHeader(),
+		Version: CaProtocolVersion,
+		Tid:     testTenantID,
+		Csr:     csr,
+	}
+
+	response, err := gClient.CreateDeviceCertificate(gCtx, createRequest)
+	if err != nil {
+		caLogger.Error("TestCreateDeviceCertificate_RetryDifferentCsr: RPC failed",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+	assertEqual(t, response.Header.Status, uint32(codes.OK))
+
+	createRequest.Csr, err = common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		caLogger.Error("TestCreateDeviceCertificate_RetryDifferentCsr: Error creating CSR",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+
+	_, err = gClient.CreateDeviceCertificate(gCtx, createRequest)
+	if status.Code(err) != codes.AlreadyExists {
+		caLogger.Error("TestCreateDeviceCertificate_RetryDifferentCsr: Retry was not rejected",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+
+	// Version 1 callers receive the failure in the response header.
+	createRequest.Header.ProtocolVersion = CaProtocolVersion
+	response, err = gClient.CreateDeviceCertificate(gCtx, createRequest)
+	if err != nil {
+		caLogger.Error("TestCreateDeviceCertificate_RetryDifferentCsr: RPC failed",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+	if response.Header.Status != uint32(codes.AlreadyExists) {
+		caLogger.Error("TestCreateDeviceCertificate_RetryDifferentCsr: Retry was not rejected",
+			zap.Any("Response", response))
+		t.Fail()
+	}
+}
@@ -0,0 +1,526 @@
+// package github.com/HPInc/krypton-ca/service/rpc
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the CreateDeviceCertificates and StreamDeviceCertificates RPCs
+// used to issue device certificates to a batch of devices within a tenant, for
+// instance by factory provisioning lines. The signing key used within the
+// tenant is resolved once for the batch, and device certificates are signed
+// with bounded concurrency. Each item in the batch is processed in the same
+// manner as a CreateDeviceCertificate request, and its status is reported in
+// its result, so that a bad CSR does not fail the rest of the batch. Retries
+// of an item specifying the same request ID return the device certificate
+// issued in response to the original item.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/certmgr/idempotency"
+	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// Names of the batch RPCs, used in status messages.
+	rpcCreateDeviceCertificates = "CreateDeviceCertificates"
+	rpcStreamDeviceCertificates = "StreamDeviceCertificates"
+)
+
+// CreateDeviceCertificates RPC is used to issue device certificates to a
+// batch of devices within the specified tenant.
+func (s *CertificateAuthorityServer) CreateDeviceCertificates(ctx context.Context,
+	request *pb.CreateDeviceCertificatesRequest) (*pb.CreateDeviceCertificatesResponse, error) {
+
+	// Validate the request header and extract the request identifier for
+	// end-to-end request tracing.
+	requestID, ok := isValidRequestHeader(request.Header)
+	if !ok {
+		caLogger.Error("CreateDeviceCertificates: Invalid request header specified!")
+		response := invalidCreateDeviceCertificatesResponse(
+			rpcCreateDeviceCertificates, requestID)
+		return response, nil
+	}
+
+	if (request.Tid == "") || (len(request.Items) == 0) {
+		caLogger.Error("CreateDeviceCertificates: TenantID or items were not specified",
+			zap.String("Request ID:", requestID),
+		)
+		response := invalidCreateDeviceCertificatesResponse(
+			rpcCreateDeviceCertificates, requestID)
+		return response, badRequestError(request.Header, requestID,
+			"CreateDeviceCertificates RPC failed",
+			missingFieldViolations(
+				requiredField{"tid", request.Tid != ""},
+				requiredField{"items", len(request.Items) != 0})...)
+	}
+
+	if err := s.validateBatchRequest(request, 0); err != nil {
+		caLogger.Error("CreateDeviceCertificates: Invalid batch requested!",
+			zap.String("Request ID:", requestID),
+			zap.String("Tenant ID:", request.Tid),
+			zap.Error(err),
+		)
+		response := invalidCreateDeviceCertificatesResponse(
+			rpcCreateDeviceCertificates, requestID)
+		return response, badRequestError(request.Header, requestID,
+			"CreateDeviceCertificates RPC failed", err.violation)
+	}
+
+	batch, err := s.newDeviceCertificateBatch(ctx, requestID, request)
+	if err != nil {
+		response := internalErrorCreateDeviceCertificatesResponse(
+			rpcCreateDeviceCertificates, requestID)
+		return response, statusErrorFromErr(request.Header, requestID,
+			"CreateDeviceCertificates RPC failed", err)
+	}
+
+	batch.submit(request.Items)
+	response := batch.wait(rpcCreateDeviceCertificates)
+	return response, nil
+}
+
+// StreamDeviceCertificates RPC is used to issue device certificates to a
+// batch of devices within the specified tenant, where the batch is streamed
+// by the caller over several messages. Items are processed as they are
+// received, and the results are returned once the caller closes the stream.
+// If the stream is rejected after some of the items were processed, those
+// items may be retried using the same request IDs.
+func (s *CertificateAuthorityServer) StreamDeviceCertificates(
+	stream pb.CertificateAuthority_StreamDeviceCertificatesServer) error {
+	request, err := stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			caLogger.Error("StreamDeviceCertificates: No requests were received!")
+			metrics.MetricCreateDeviceCertificatesBadRequests.Inc()
+			return status.Error(codes.InvalidArgument,
+				"StreamDeviceCertificates RPC failed: no requests were received")
+		}
+		return err
+	}
+
+	// Validate the request header of the first message and extract the
+	// request identifier for end-to-end request tracing.
+	header := request.Header
+	requestID, ok := isValidRequestHeader(header)
+	if !ok {
+		caLogger.Error("StreamDeviceCertificates: Invalid request header specified!")
+		return stream.SendAndClose(invalidCreateDeviceCertificatesResponse(
+			rpcStreamDeviceCertificates, requestID))
+	}
+
+	// sendResponse returns the response to the caller, reporting failures
+	// using a status error if the caller uses version 2 of the CA protocol.
+	sendResponse := func(response *pb.CreateDeviceCertificatesResponse,
+		statusErr error) error {
+		if statusErr != nil {
+			return statusErr
+		}
+		if useStatusErrors(header) {
+			response.Header.ProtocolVersion = CaProtocolVersionV2
+		}
+		return stream.SendAndClose(response)
+	}
+
+	if request.Tid == "" {
+		caLogger.Error("StreamDeviceCertificates: TenantID was not specified",
+			zap.String("Request ID:", requestID),
+		)
+		return sendResponse(invalidCreateDeviceCertificatesResponse(
+			rpcStreamDeviceCertificates, requestID),
+			badRequestError(header, requestID,
+				"StreamDeviceCertificates RPC failed",
+				missingFieldViolations(requiredField{"tid", false})...))
+	}
+
+	batch, err := s.newDeviceCertificateBatch(stream.Context(), requestID,
+		request)
+	if err != nil {
+		return sendResponse(internalErrorCreateDeviceCertificatesResponse(
+			rpcStreamDeviceCertificates, requestID),
+			statusErrorFromErr(header, requestID,
+				"StreamDeviceCertificates RPC failed", err))
+	}
+
+	for {
+		// Every message in the stream must be issued for the same tenant,
+		// and the stream may not exceed the maximum batch size.
+		err := s.validateBatchRequest(request, batch.size())
+		if (err == nil) && (request.Tid != batch.tenantID) {
+			err = &batchRequestError{fieldViolation("tid",
+				"every message in the stream must specify the same tenant")}
+		}
+		if err != nil {
+			caLogger.Error("StreamDeviceCertificates: Invalid batch requested!",
+				zap.String("Request ID:", requestID),
+				zap.String("Tenant ID:", batch.tenantID),
+				zap.Error(err),
+			)
+			batch.wait(rpcStreamDeviceCertificates)
+			return sendResponse(invalidCreateDeviceCertificatesResponse(
+				rpcStreamDeviceCertificates, requestID),
+				badRequestError(header, requestID,
+					"StreamDeviceCertificates RPC failed", err.violation))
+		}
+		batch.submit(request.Items)
+
+		var recvErr error
+		request, recvErr = stream.Recv()
+		if errors.Is(recvErr, io.EOF) {
+			break
+		}
+		if recvErr != nil {
+			caLogger.Error("StreamDeviceCertificates: Failed to receive a request!",
+				zap.String("Request ID:", requestID),
+				zap.String("Tenant ID:", batch.tenantID),
+				zap.Error(recvErr),
+			)
+			batch.wait(rpcStreamDeviceCertificates)
+			return recvErr
+		}
+	}
+
+	if batch.size() == 0 {
+		caLogger.Error("StreamDeviceCertificates: No items were specified",
+			zap.String("Request ID:", requestID),
+		)
+		return sendResponse(invalidCreateDeviceCertificatesResponse(
+			rpcStreamDeviceCertificates, requestID),
+			badRequestError(header, requestID,
+				"StreamDeviceCertificates RPC failed",
+				missingFieldViolations(requiredField{"items", false})...))
+	}
+
+	return sendResponse(batch.wait(rpcStreamDeviceCertificates), nil)
+}
+
+// batchRequestError describes why a batch request is invalid.
+type batchRequestError struct {
+	violation *errdetails.BadRequest_FieldViolation
+}
+
+func (e *batchRequestError) Error() string {
+	return e.violation.Field + ": " + e.violation.Description
+}
+
+// validateBatchRequest checks that the items in the request, along with the
+// specified number of items already received within the batch, do not exceed
+// the maximum batch size, and that the requested format is supported.
+func (s *CertificateAuthorityServer) validateBatchRequest(
+	request *pb.CreateDeviceCertificatesRequest, received int) *batchRequestError {
+	if (received + len(request.Items)) > s.batchSettings.MaxBatchSize {
+		return &batchRequestError{fieldViolation("items",
+			fmt.Sprintf("at most %d items may be requested in a batch",
+				s.batchSettings.MaxBatchSize))}
+	}
+
+	if !isValidCertificateFormat(request.Format) {
+		return &batchRequestError{fieldViolation("format",
+			"unsupported certificate format")}
+	}
+	return nil
+}
+
+// deviceCertificateBatch tracks the items within a batch of device
+// certificate requests, and the results of the items that were processed.
+type deviceCertificateBatch struct {
+	s   *CertificateAuthorityServer
+	ctx context.Context
+
+	// Request identifier of the batch, used for logging.
+	requestID string
+
+	// Tenant within which device certificates are issued.
+	tenantID string
+
+	// Format in which device certificates are returned.
+	format pb.CertificateFormat
+
+	// Issuer used to sign device certificates within the tenant.
+	issuer *kms_providers.DeviceCertificateIssuer
+
+	// Bounds the number of items processed concurrently.
+	workers chan struct{}
+	wg      sync.WaitGroup
+
+	// Results of the items, in the order in which they were submitted, and the
+	// request IDs of the items submitted so far.
+	lock       sync.Mutex
+	results    []*pb.DeviceCertificateResult
+	requestIDs map[string]bool
+}
+
+// newDeviceCertificateBatch resolves the signing key used within the tenant
+// specified in the request, and returns a batch to which items are submitted.
+func (s *CertificateAuthorityServer) newDeviceCertificateBatch(ctx context.Context,
+	requestID string,
+	request *pb.CreateDeviceCertificatesRequest) (*deviceCertificateBatch, error) {
+	issuer, err := s.kmsProvider.NewDeviceCertificateIssuer(ctx, request.Tid)
+	if err != nil {
+		caLogger.Error("Failed to resolve the signing key for the batch!",
+			zap.String("Request ID:", requestID),
+			zap.String("Tenant ID:", request.Tid),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return &deviceCertificateBatch{
+		s:          s,
+		ctx:        ctx,
+		requestID:  requestID,
+		tenantID:   request.Tid,
+		format:     request.Format,
+		issuer:     issuer,
+		workers:    make(chan struct{}, s.batchSettings.MaxConcurrency),
+		requestIDs: map[string]bool{},
+	}, nil
+}
+
+// size returns the number of items submitted to the batch.
+func (b *deviceCertificateBatch) size() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.results)
+}
+
+// submit schedules the specified items to be processed. Submit blocks while
+// the maximum number of items are being processed concurrently.
+func (b *deviceCertificateBatch) submit(items []*pb.DeviceCertificateRequestItem) {
+	for _, item := range items {
+		b.lock.Lock()
+		index := len(b.results)
+		b.results = append(b.results, nil)
+		duplicate := (item.RequestId != "") && b.requestIDs[item.RequestId]
+		b.requestIDs[item.RequestId] = true
+		b.lock.Unlock()
+
+		if duplicate {
+			b.setResult(index, failedDeviceCertificateResult(item,
+				codes.InvalidArgument, reasonInvalidRequest,
+				"request ID was specified for more than one item in the batch"))
+			continue
+		}
+
+		b.workers <- struct{}{}
+		b.wg.Add(1)
+		go func(index int, item *pb.DeviceCertificateRequestItem) {
+			defer func() {
+				<-b.workers
+				b.wg.Done()
+			}()
+			b.setResult(index, b.issue(item))
+		}(index, item)
+	}
+}
+
+// setResult records the result of the item at the specified index.
+func (b *deviceCertificateBatch) setResult(index int,
+	result *pb.DeviceCertificateResult) {
+	result.Index = uint32(index)
+	metrics.MetricBatchDeviceCertificateItems.WithLabelValues(
+		strconv.Itoa(int(result.Status))).Inc()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.results[index] = result
+}
+
+// wait waits for all of the submitted items to be processed, and returns the
+// response reporting their results.
+func (b *deviceCertificateBatch) wait(rpcName string) *pb.CreateDeviceCertificatesResponse {
+	b.wg.Wait()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	response := &pb.CreateDeviceCertificatesResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.OK),
+			StatusMessage:   rpcName + " RPC successful",
+			RequestId:       b.requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+		Results: b.results,
+	}
+	for _, result := range b.results {
+		if result.Status == uint32(codes.OK) {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+	return response
+}
+
+// issue issues a device certificate for the specified item, in the same
+// manner as the CreateDeviceCertificate RPC, and returns its result.
+func (b *deviceCertificateBatch) issue(
+	item *pb.DeviceCertificateRequestItem) *pb.DeviceCertificateResult {
+	if len(item.Csr) == 0 {
+		return failedDeviceCertificateResult(item, codes.InvalidArgument,
+			reasonInvalidRequest, "csr must be specified")
+	}
+
+	// Stop processing items once the caller has gone away.
+	if err := b.ctx.Err(); err != nil {
+		return failedDeviceCertificateResult(item, status.FromContextError(err).Code(),
+			"", "request was cancelled")
+	}
+
+	// Check whether the item is a retry of an earlier item within the tenant.
+	// If so, return the device certificate issued earlier instead of
+	// enrolling a new device.
+	issued, err := b.s.idempotencyManager.Begin(b.ctx, b.tenantID,
+		item.RequestId, item.Csr)
+	if err != nil {
+		return b.failed(item, "Failed to check for a retried item!", err)
+	}
+	if issued != nil {
+		result := b.issued(item, issued.DeviceID, issued.DeviceCertificate,
+			issued.ParentCertificates, issued.IssuedAt, issued.ExpiresAt)
+		if result.Status == uint32(codes.OK) {
+			metrics.MetricCreateDeviceCertificateReplays.Inc()
+		}
+		return result
+	}
+
+	// Ensure that the tenant has not reached its device cap. Items within a
+	// batch are subject to the cap in the same manner as concurrent requests -
+	// capacity is reserved atomically for each item, so items processed
+	// concurrently cannot exceed the cap.
+	reservation, err := b.s.quotaManager.ReserveDevice(b.ctx, b.tenantID)
+	if err != nil {
+		b.s.idempotencyManager.Abandon(b.ctx, b.tenantID, item.RequestId)
+		return b.failed(item, "Failed to check the device cap for the tenant!",
+			err)
+	}
+	defer reservation.Release()
+
+	// Issue a new device certificate using the signing key resolved for the
+	// batch.
+	deviceID, deviceCert, parentCerts, expiresAt, err :=
+		b.issuer.CreateDeviceCertificate(item.Csr)
+	if err != nil {
+		b.s.idempotencyManager.Abandon(b.ctx, b.tenantID, item.RequestId)
+		return b.failed(item, "Failed to generate device certificate!", err)
+	}
+	issuedAt := time.Now()
+
+	// Track the newly issued device against the tenant's device cap, and
+	// retain the issued device certificate so that it can be returned if the
+	// item is retried. Failures are logged but not returned to the caller.
+	_ = reservation.RecordDevice(b.ctx, deviceID, expiresAt)
+	_ = b.s.idempotencyManager.Complete(b.ctx, b.tenantID,
+		item.RequestId, item.Csr, &idempotency.IssuedCertificate{
+			DeviceID:           deviceID,
+			DeviceCertificate:  deviceCert,
+			ParentCertificates: parentCerts,
+			IssuedAt:           issuedAt,
+			ExpiresAt:          expiresAt,
+		})
+
+	result := b.issued(item, deviceID, deviceCert, parentCerts, issuedAt,
+		expiresAt)
+	if result.Status == uint32(codes.OK) {
+		metrics.MetricDeviceCertificatesIssued.Inc()
+	}
+	return result
+}
+
+// issued returns the result of an item for which a device certificate was
+// issued, encoded in the format requested for the batch.
+func (b *deviceCertificateBatch) issued(item *pb.DeviceCertificateRequestItem,
+	deviceID string, deviceCert []byte, parentCerts []byte,
+	issuedAt time.Time, expiresAt time.Time) *pb.DeviceCertificateResult {
+	certificate, chain, err := formatDeviceCertificate(b.format, deviceCert,
+		parentCerts)
+	if err != nil {
+		return b.failed(item, "Failed to encode the device certificate!", err)
+	}
+
+	return &pb.DeviceCertificateResult{
+		RequestId:          item.RequestId,
+		Status:             uint32(codes.OK),
+		StatusMessage:      "Device certificate issued",
+		IssuedTime:         timestamppb.New(issuedAt),
+		ExpiryTime:         timestamppb.New(expiresAt),
+		DeviceId:           deviceID,
+		DeviceCertificate:  certificate,
+		ParentCertificates: parentCerts,
+		Chain:              chain,
+	}
+}
+
+// failed logs the failure of an item and returns its result, reporting the
+// status and reason codes chosen based on the error.
+func (b *deviceCertificateBatch) failed(item *pb.DeviceCertificateRequestItem,
+	message string, err error) *pb.DeviceCertificateResult {
+	caLogger.Error("Batch: "+message,
+		zap.String("Request ID:", b.requestID),
+		zap.String("Item request ID:", item.RequestId),
+		zap.String("Tenant ID:", b.tenantID),
+		zap.Error(err),
+	)
+
+	st := statusFromErr("Failed to issue the device certificate", err)
+	if st.code == codes.ResourceExhausted {
+		metrics.MetricDeviceCapReached.Inc()
+	}
+	return failedDeviceCertificateResult(item, st.code, st.reason, st.message)
+}
+
+func failedDeviceCertificateResult(item *pb.DeviceCertificateRequestItem,
+	code codes.Code, reason string, message string) *pb.DeviceCertificateResult {
+	return &pb.DeviceCertificateResult{
+		RequestId:     item.RequestId,
+		Status:        uint32(code),
+		StatusMessage: message,
+		Reason:        reason,
+	}
+}
+
+func invalidCreateDeviceCertificatesResponse(rpcName string,
+	requestID string) *pb.CreateDeviceCertificatesResponse {
+	response := &pb.CreateDeviceCertificatesResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.InvalidArgument),
+			StatusMessage:   rpcName + " RPC failed",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	metrics.MetricCreateDeviceCertificatesBadRequests.Inc()
+	return response
+}
+
+func internalErrorCreateDeviceCertificatesResponse(rpcName string,
+	requestID string) *pb.CreateDeviceCertificatesResponse {
+	response := &pb.CreateDeviceCertificatesResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.Internal),
+			StatusMessage:   rpcName + " RPC failed",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	metrics.MetricCreateDeviceCertificatesInternalErrors.Inc()
+	return response
+}
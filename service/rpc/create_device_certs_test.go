@@ -0,0 +1,234 @@
+package rpc
+
+import (
+	"crypto/x509"
+	"testing"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Create batch items with new request IDs, each specifying a new CSR.
+func newTestBatchItems(t *testing.T, count int) []*pb.DeviceCertificateRequestItem {
+	items := []*pb.DeviceCertificateRequestItem{}
+	for i := 0; i < count; i++ {
+		csr, err := common.CreateDeviceCertificateSigningRequest()
+		if err != nil {
+			t.Fatalf("Failed to create CSR: %v", err)
+		}
+		items = append(items, &pb.DeviceCertificateRequestItem{
+			RequestId: uuid.NewString(),
+			Csr:       csr,
+		})
+	}
+	return items
+}
+
+// Check that the results of a batch are reported in order, and that device
+// certificates were issued for the items expected to succeed.
+func assertBatchResults(t *testing.T, response *pb.CreateDeviceCertificatesResponse,
+	items []*pb.DeviceCertificateRequestItem, expected []codes.Code) {
+	if len(response.Results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected),
+			len(response.Results))
+	}
+
+	var succeeded, failed uint32
+	for i, result := range response.Results {
+		if (result.Index != uint32(i)) ||
+			(result.RequestId != items[i].RequestId) {
+			t.Errorf("Result %d is out of order: %+v", i, result)
+		}
+		if codes.Code(result.Status) != expected[i] {
+			t.Errorf("Expected item %d to have status %v, got %v (%s)", i,
+				expected[i], codes.Code(result.Status), result.StatusMessage)
+		}
+		if expected[i] != codes.OK {
+			failed++
+			continue
+		}
+
+		succeeded++
+		cert, err := x509.ParseCertificate(result.DeviceCertificate)
+		if err != nil {
+			t.Errorf("Failed to parse the device certificate of item %d: %v",
+				i, err)
+			continue
+		}
+		if cert.Subject.CommonName != result.DeviceId {
+			t.Errorf("Device certificate of item %d was not issued to the device", i)
+		}
+	}
+	if (response.Succeeded != succeeded) || (response.Failed != failed) {
+		t.Errorf("Expected %d succeeded and %d failed items, got %d and %d",
+			succeeded, failed, response.Succeeded, response.Failed)
+	}
+}
+
+func TestCreateDeviceCertificates(t *testing.T) {
+	items := newTestBatchItems(t, 5)
+
+	// A bad CSR or a missing CSR does not fail the other items.
+	items[1].Csr = []byte("not a csr")
+	items[3].Csr = nil
+
+	request := &pb.CreateDeviceCertificatesRequest{
+		Header:  newCaProtocolHeader(),
+		Version: CaProtocolVersion,
+		Tid:     testTenantID,
+		Items:   items,
+	}
+	response, err := gClient.CreateDeviceCertificates(gCtx, request)
+	if err != nil {
+		t.Fatalf("CreateDeviceCertificates RPC failed: %v", err)
+	}
+	assertEqual(t, response.Header.Status, uint32(codes.OK))
+	assertBatchResults(t, response, items, []codes.Code{codes.OK,
+		codes.InvalidArgument, codes.OK, codes.InvalidArgument, codes.OK})
+	assertEqual(t, response.Results[1].Reason, reasonInvalidCSR)
+
+	// Retrying the batch returns the device certificates issued earlier for
+	// the items that succeeded.
+	request.Header = newCaProtocolHeader()
+	retried, err := gClient.CreateDeviceCertificates(gCtx, request)
+	if err != nil {
+		t.Fatalf("CreateDeviceCertificates RPC failed: %v", err)
+	}
+	for _, i := range []int{0, 2, 4} {
+		assertEqual(t, retried.Results[i].DeviceId, response.Results[i].DeviceId)
+	}
+}
+
+func TestCreateDeviceCertificates_DuplicateRequestID(t *testing.T) {
+	items := newTestBatchItems(t, 2)
+	items[1].RequestId = items[0].RequestId
+
+	response, err := gClient.CreateDeviceCertificates(gCtx,
+		&pb.CreateDeviceCertificatesRequest{
+			Header:  newCaProtocolHeader(),
+			Version: CaProtocolVersion,
+			Tid:     uuid.NewString(),
+			Items:   items,
+		})
+	if err != nil {
+		t.Fatalf("CreateDeviceCertificates RPC failed: %v", err)
+	}
+	assertBatchResults(t, response, items, []codes.Code{codes.OK,
+		codes.InvalidArgument})
+}
+
+func TestCreateDeviceCertificates_DeviceCap(t *testing.T) {
+	tenantID := uuid.NewString()
+	response := setTenantQuota(t, tenantID, 2, false)
+	assertEqual(t, response.Header.Status, uint32(codes.OK))
+
+	// Items processed concurrently within the batch do not exceed the
+	// tenant's device cap.
+	items := newTestBatchItems(t, gTestBatchSettings.MaxConcurrency+2)
+	batchResponse, err := gClient.CreateDeviceCertificates(gCtx,
+		&pb.CreateDeviceCertificatesRequest{
+			Header:  newCaProtocolHeader(),
+			Version: CaProtocolVersion,
+			Tid:     tenantID,
+			Items:   items,
+		})
+	if err != nil {
+		t.Fatalf("CreateDeviceCertificates RPC failed: %v", err)
+	}
+	assertEqual(t, batchResponse.Succeeded, uint32(2))
+	assertEqual(t, batchResponse.Failed, uint32(len(items)-2))
+	for _, result := range batchResponse.Results {
+		if (result.Status != uint32(codes.OK)) &&
+			(result.Status != uint32(codes.ResourceExhausted)) {
+			t.Errorf("Unexpected status for item %d: %v (%s)", result.Index,
+				codes.Code(result.Status), result.StatusMessage)
+		}
+	}
+
+	quotaResponse := setTenantQuota(t, tenantID, 2, false)
+	assertEqual(t, quotaResponse.ActiveDevices, int64(2))
+}
+
+func TestCreateDeviceCertificates_InvalidRequest(t *testing.T) {
+	// Batches must specify a tenant and at least one item.
+	_, err := gClient.CreateDeviceCertificates(gCtx,
+		&pb.CreateDeviceCertificatesRequest{
+			Header:  newCaV2ProtocolHeader(),
+			Version: CaProtocolVersion,
+		})
+	assertEqual(t, status.Code(err), codes.InvalidArgument)
+
+	// Batches may not exceed the maximum batch size.
+	response, err := gClient.CreateDeviceCertificates(gCtx,
+		&pb.CreateDeviceCertificatesRequest{
+			Header:  newCaProtocolHeader(),
+			Version: CaProtocolVersion,
+			Tid:     testTenantID,
+			Items:   newTestBatchItems(t, gTestBatchSettings.MaxBatchSize+1),
+		})
+	if err != nil {
+		t.Fatalf("CreateDeviceCertificates RPC failed: %v", err)
+	}
+	assertEqual(t, response.Header.Status, uint32(codes.InvalidArgument))
+	assertEqual(t, len(response.Results), 0)
+}
+
+func TestStreamDeviceCertificates(t *testing.T) {
+	stream, err := gClient.StreamDeviceCertificates(gCtx)
+	if err != nil {
+		t.Fatalf("StreamDeviceCertificates RPC failed: %v", err)
+	}
+
+	// Items are numbered across all messages in the stream.
+	items := newTestBatchItems(t, 4)
+	for i := 0; i < len(items); i += 2 {
+		err = stream.Send(&pb.CreateDeviceCertificatesRequest{
+			Header:  newCaProtocolHeader(),
+			Version: CaProtocolVersion,
+			Tid:     testTenantID,
+			Items:   items[i : i+2],
+		})
+		if err != nil {
+			t.Fatalf("Failed to send the batch: %v", err)
+		}
+	}
+	response, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("StreamDeviceCertificates RPC failed: %v", err)
+	}
+	assertEqual(t, response.Header.Status, uint32(codes.OK))
+	assertBatchResults(t, response, items, []codes.Code{codes.OK, codes.OK,
+		codes.OK, codes.OK})
+}
+
+func TestStreamDeviceCertificates_TenantMismatch(t *testing.T) {
+	stream, err := gClient.StreamDeviceCertificates(gCtx)
+	if err != nil {
+		t.Fatalf("StreamDeviceCertificates RPC failed: %v", err)
+	}
+
+	for _, tenantID := range []string{testTenantID, uuid.NewString()} {
+		err = stream.Send(&pb.CreateDeviceCertificatesRequest{
+			Header:  newCaV2ProtocolHeader(),
+			Version: CaProtocolVersion,
+			Tid:     tenantID,
+			Items:   newTestBatchItems(t, 1),
+		})
+		if err != nil {
+			t.Fatalf("Failed to send the batch: %v", err)
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	assertEqual(t, status.Code(err), codes.InvalidArgument)
+
+	// The tenant ID is reported as the invalid field.
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			assertEqual(t, badRequest.FieldViolations[0].Field, "tid")
+		}
+	}
+}
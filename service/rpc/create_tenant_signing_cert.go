@@ -9,10 +9,12 @@ package rpc
 
 import (
 	"context"
+	"errors"
 
 	"go.uber.org/zap"
 
 	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/common"
 	"github.com/HPInc/krypton-ca/service/metrics"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -37,21 +39,37 @@ func (s *CertificateAuthorityServer) CreateTenantSigningCertificate(ctx context.
 			zap.String("Request ID:", requestID),
 		)
 		response := invalidCreateTenantSigningCertificateResponse(requestID)
-		return response, nil
+		return response, badRequestError(request.Header, requestID,
+			"CreateTenantSigningCertificate RPC failed",
+			missingFieldViolations(
+				requiredField{"tid", request.Tid != ""},
+				requiredField{"name", request.Name != ""})...)
 	}
 
 	// Invoke the configured KMS provider to create a new tenant signing certificate
-	// for the specified tenant.
-	certID, err := s.kmsProvider.CreateTenantSigningCertificate(request.Tid,
+	// for the specified tenant. Existing tenant signing certificates are not
+	// replaced.
+	certID, err := s.kmsProvider.CreateTenantSigningCertificate(ctx, request.Tid,
 		request.Name)
 	if err != nil {
+		if errors.Is(err, common.ErrTenantExists) {
+			caLogger.Error("Tenant signing certificate already exists!",
+				zap.String("Tenant ID:", request.Tid),
+				zap.String("Request ID:", requestID),
+			)
+			response := alreadyExistsCreateTenantSigningCertificateResponse(requestID)
+			return response, statusErrorFromErr(request.Header, requestID,
+				"CreateTenantSigningCertificate RPC failed", err)
+		}
+
 		caLogger.Error("Failed to create tenant signing certificate!",
 			zap.String("Tenant ID:", request.Tid),
 			zap.String("Request ID:", requestID),
 			zap.Error(err),
 		)
 		response := internalErrorCreateTenantSigningCertificateResponse(requestID)
-		return response, nil
+		return response, statusErrorFromErr(request.Header, requestID,
+			"CreateTenantSigningCertificate RPC failed", err)
 	}
 
 	response := successCreateTenantSigningCertificateResponse(requestID, certID)
@@ -91,6 +109,22 @@ func successCreateTenantSigningCertificateResponse(
 	return response
 }
 
+func alreadyExistsCreateTenantSigningCertificateResponse(
+	requestID string) *pb.CreateTenantSigningCertificateResponse {
+	response := &pb.CreateTenantSigningCertificateResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.AlreadyExists),
+			StatusMessage:   "CreateTenantSigningCertificate RPC failed: tenant signing certificate already exists",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	metrics.MetricCreateTenantCertificateBadRequests.Inc()
+	return response
+}
+
 func internalErrorCreateTenantSigningCertificateResponse(
 	requestID string) *pb.CreateTenantSigningCertificateResponse {
 	response := &pb.CreateTenantSigningCertificateResponse{
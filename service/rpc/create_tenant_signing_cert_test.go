@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"bytes"
 	"reflect"
 	"testing"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -120,3 +122,60 @@ func TestCreateTenantSigningCertificate_InvalidProtocol(t *testing.T) {
 	caLogger.Info("Response from certificate authority",
 		zap.Any("Response", response))
 }
+
+// Attempt to create a tenant signing certificate for a tenant that already
+// has one, and ensure the existing certificate is retained.
+func TestCreateTenantSigningCertificate_AlreadyExists(t *testing.T) {
+	getRequest := &pb.GetTenantSigningCertificateRequest{
+		Header:  newCaProtocolHeader(),
+		Version: CaProtocolVersion,
+		Tid:     testTenantID,
+	}
+	getResponse, err := gClient.GetTenantSigningCertificate(gCtx, getRequest)
+	if err != nil {
+		caLogger.Error("TestCreateTenantSigningCertificate_AlreadyExists: RPC failed",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+
+	createRequest := &pb.CreateTenantSigningCertificateRequest{
+		Header:     newCaProtocolHeader(),
+		Version:    CaProtocolVersion,
+		Tid:        testTenantID,
+		Name:       testTenantName,
+		DomainName: testTenantDomain,
+	}
+	response, err := gClient.CreateTenantSigningCertificate(gCtx, createRequest)
+	if err != nil {
+		caLogger.Error("TestCreateTenantSigningCertificate_AlreadyExists: RPC failed",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+	if response.Header.Status != uint32(codes.AlreadyExists) {
+		caLogger.Error("TestCreateTenantSigningCertificate_AlreadyExists: Existing tenant was overwritten",
+			zap.Any("Response", response))
+		t.Fail()
+		return
+	}
+
+	// Version 2 callers receive an AlreadyExists status error.
+	createRequest.Header = newCaV2ProtocolHeader()
+	_, err = gClient.CreateTenantSigningCertificate(gCtx, createRequest)
+	assertEqual(t, status.Code(err), codes.AlreadyExists)
+
+	getRequest.Header = newCaProtocolHeader()
+	afterResponse, err := gClient.GetTenantSigningCertificate(gCtx, getRequest)
+	if err != nil {
+		caLogger.Error("TestCreateTenantSigningCertificate_AlreadyExists: RPC failed",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+	if !bytes.Equal(afterResponse.SigningCertificate,
+		getResponse.SigningCertificate) {
+		caLogger.Error("TestCreateTenantSigningCertificate_AlreadyExists: Signing certificate was replaced")
+		t.Fail()
+	}
+}
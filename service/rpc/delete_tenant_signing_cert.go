@@ -36,12 +36,14 @@ func (s *CertificateAuthorityServer) DeleteTenantSigningCertificate(ctx context.
 			zap.String("Request ID:", requestID),
 		)
 		response := invalidDeleteTenantSigningCertificateResponse(requestID)
-		return response, nil
+		return response, badRequestError(request.Header, requestID,
+			"DeleteTenantSigningCertificate RPC failed",
+			fieldViolation("tid", "tenant ID must be specified"))
 	}
 
 	// Invoke the corresponding KMS provider to delete the configured tenant
 	// signing certificate.
-	err := s.kmsProvider.DeleteTenantSigningCertificate(request.Tid)
+	err := s.kmsProvider.DeleteTenantSigningCertificate(ctx, request.Tid)
 	if err != nil {
 		caLogger.Error("Failed to delete tenant signing certificate!",
 			zap.String("Tenant ID:", request.Tid),
@@ -49,7 +51,8 @@ func (s *CertificateAuthorityServer) DeleteTenantSigningCertificate(ctx context.
 			zap.Error(err),
 		)
 		response := internalErrorDeleteTenantSigningCertificateResponse(requestID)
-		return response, nil
+		return response, statusErrorFromErr(request.Header, requestID,
+			"DeleteTenantSigningCertificate RPC failed", err)
 	}
 
 	response := successDeleteTenantSigningCertificateResponse(requestID)
@@ -0,0 +1,72 @@
+// package github.com/HPInc/krypton-ca/service/rpc
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Supports invoking the certificate authority's RPC handlers in-process, for
+// use by the REST/JSON gateway. Requests invoked in this manner are processed
+// by the same interceptors as requests received at the gRPC endpoint, so they
+// are subject to the same rate limits and reflected in the same metrics.
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Full names of the RPC methods served by the certificate authority.
+const (
+	MethodCreateTenantSigningCertificate = "/caprotos.CertificateAuthority/CreateTenantSigningCertificate"
+	MethodGetTenantSigningCertificate    = "/caprotos.CertificateAuthority/GetTenantSigningCertificate"
+	MethodDeleteTenantSigningCertificate = "/caprotos.CertificateAuthority/DeleteTenantSigningCertificate"
+	MethodCreateDeviceCertificate        = "/caprotos.CertificateAuthority/CreateDeviceCertificate"
+	MethodRenewDeviceCertificate         = "/caprotos.CertificateAuthority/RenewDeviceCertificate"
+	MethodCreateDeviceCertificates       = "/caprotos.CertificateAuthority/CreateDeviceCertificates"
+	MethodStreamDeviceCertificates       = "/caprotos.CertificateAuthority/StreamDeviceCertificates"
+	MethodGetTenantQuota                 = "/caprotos.CertificateAuthority/GetTenantQuota"
+	MethodSetTenantQuota                 = "/caprotos.CertificateAuthority/SetTenantQuota"
+	MethodGetCACertificates              = "/caprotos.CertificateAuthority/GetCACertificates"
+	MethodPing                           = "/caprotos.CertificateAuthority/Ping"
+)
+
+// Invoke - invokes the handler for the specified RPC method in-process,
+// applying the interceptors used by the gRPC server to the request.
+func (s *CertificateAuthorityServer) Invoke(ctx context.Context, method string,
+	req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+	info := &grpc.UnaryServerInfo{
+		Server:     s,
+		FullMethod: method,
+	}
+	return chainUnaryInterceptors(s.unaryInterceptors(), info, handler)(ctx, req)
+}
+
+// Wrap the handler in the specified interceptors, such that the first
+// interceptor is the outermost.
+func chainUnaryInterceptors(interceptors []grpc.UnaryServerInterceptor,
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) grpc.UnaryHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, info, next)
+		}
+	}
+	return handler
+}
+
+// NewCallerContext - returns a context identifying the caller of a request
+// received over another transport, by the caller's network address and the
+// state of the TLS connection (if any) the request was received on. The caller
+// identity is used to rate limit requests and to detect retried requests.
+func NewCallerContext(ctx context.Context, addr net.Addr,
+	tlsState *tls.ConnectionState) context.Context {
+	p := &peer.Peer{Addr: addr}
+	if tlsState != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *tlsState}
+	}
+	return peer.NewContext(ctx, p)
+}
@@ -0,0 +1,154 @@
+// package github.com/HPInc/krypton-ca/service/rpc
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the GetCACertificates RPC used by relying parties to retrieve the
+// root CA certificate and the signing certificates used to sign device
+// certificates, so that they can be configured with the CA's trust anchors.
+package rpc
+
+import (
+	"bytes"
+	"context"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"go.mozilla.org/pkcs7"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GetCACertificates RPC is used to retrieve the root CA certificate, the common
+// signing certificate and the chain of certificates used to sign device
+// certificates within the specified tenant.
+func (s *CertificateAuthorityServer) GetCACertificates(ctx context.Context,
+	request *pb.GetCACertificatesRequest) (*pb.GetCACertificatesResponse, error) {
+
+	// Validate the request header and extract the request identifier for
+	// end-to-end request tracing.
+	requestID, ok := isValidRequestHeader(request.Header)
+	if !ok {
+		caLogger.Error("GetCACertificates: Invalid request header specified!")
+		response := invalidGetCACertificatesResponse(requestID)
+		return response, nil
+	}
+
+	if !isValidCertificateFormat(request.Format) {
+		caLogger.Error("GetCACertificates: Unsupported certificate format requested!",
+			zap.String("Request ID:", requestID),
+			zap.Int32("Format:", int32(request.Format)),
+		)
+		response := invalidGetCACertificatesResponse(requestID)
+		return response, badRequestError(request.Header, requestID,
+			"GetCACertificates RPC failed",
+			fieldViolation("format", "unsupported certificate format"))
+	}
+
+	rootCert, commonSigningCert, err := s.kmsProvider.GetCACertificates(ctx)
+	if err != nil {
+		caLogger.Error("GetCACertificates: Failed to get the CA certificates!",
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		response := internalErrorGetCACertificatesResponse(requestID)
+		return response, statusErrorFromErr(request.Header, requestID,
+			"GetCACertificates RPC failed", err)
+	}
+
+	// Determine the signing certificate used within the tenant, if one was
+	// specified. Otherwise, return the chain for the common signing
+	// certificate.
+	signingCert := commonSigningCert
+	if request.Tid != "" {
+		signingChain, err := s.kmsProvider.GetSigningCertificateChain(ctx, request.Tid)
+		if err == nil {
+			var parsed *pkcs7.PKCS7
+			parsed, err = pkcs7.Parse(signingChain)
+			if (err == nil) && (len(parsed.Certificates) > 0) {
+				signingCert = parsed.Certificates[0].Raw
+			}
+		}
+		if err != nil {
+			caLogger.Error("GetCACertificates: Failed to get the signing certificate chain for the tenant!",
+				zap.String("Request ID:", requestID),
+				zap.String("Tenant ID:", request.Tid),
+				zap.Error(err),
+			)
+			response := internalErrorGetCACertificatesResponse(requestID)
+			return response, statusErrorFromErr(request.Header, requestID,
+				"GetCACertificates RPC failed", err)
+		}
+	}
+
+	bundle, chain, err := encodeCertificateChain(request.Format,
+		[][]byte{signingCert, rootCert})
+	if err != nil {
+		caLogger.Error("GetCACertificates: Failed to encode the CA certificates!",
+			zap.String("Request ID:", requestID),
+			zap.Error(err),
+		)
+		response := internalErrorGetCACertificatesResponse(requestID)
+		return response, statusErrorFromErr(request.Header, requestID,
+			"GetCACertificates RPC failed", err)
+	}
+
+	response := successGetCACertificatesResponse(requestID)
+	response.RootCertificate = encodeCertificate(request.Format, rootCert)
+	response.CommonSigningCertificate = encodeCertificate(request.Format,
+		commonSigningCert)
+	if !bytes.Equal(signingCert, commonSigningCert) {
+		response.TenantSigningCertificate = chain[0]
+	}
+	response.Chain = chain
+	if (request.Format == pb.CertificateFormat_CERTIFICATE_FORMAT_PKCS7) ||
+		(request.Format == pb.CertificateFormat_CERTIFICATE_FORMAT_PEM_CHAIN) {
+		response.Bundle = bundle
+	}
+	return response, nil
+}
+
+func invalidGetCACertificatesResponse(
+	requestID string) *pb.GetCACertificatesResponse {
+	response := &pb.GetCACertificatesResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.InvalidArgument),
+			StatusMessage:   "GetCACertificates RPC failed",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	return response
+}
+
+func successGetCACertificatesResponse(
+	requestID string) *pb.GetCACertificatesResponse {
+	response := &pb.GetCACertificatesResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.OK),
+			StatusMessage:   "GetCACertificates RPC successful",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	return response
+}
+
+func internalErrorGetCACertificatesResponse(
+	requestID string) *pb.GetCACertificatesResponse {
+	response := &pb.GetCACertificatesResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.Internal),
+			StatusMessage:   "GetCACertificates RPC failed",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	return response
+}
@@ -0,0 +1,141 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/google/uuid"
+	"go.mozilla.org/pkcs7"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Parse a single certificate returned in a GetCACertificates response.
+func parseCACertificate(t *testing.T, encoded []byte,
+	pemEncoded bool) *x509.Certificate {
+	if pemEncoded {
+		block, rest := pem.Decode(encoded)
+		if (block == nil) || (block.Type != pemTypeCertificate) ||
+			(len(rest) != 0) {
+			t.Fatalf("Certificate is not PEM encoded: %s", encoded)
+		}
+		encoded = block.Bytes
+	}
+	cert, err := x509.ParseCertificate(encoded)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestGetCACertificates(t *testing.T) {
+	response, err := gClient.GetCACertificates(gCtx,
+		&pb.GetCACertificatesRequest{
+			Header:  newCaProtocolHeader(),
+			Version: CaProtocolVersion,
+		})
+	if err != nil {
+		t.Fatalf("GetCACertificates RPC failed: %v", err)
+	}
+	if response.Header.Status != uint32(codes.OK) {
+		t.Fatalf("Expected status %d, got %d", codes.OK, response.Header.Status)
+	}
+
+	rootCert := parseCACertificate(t, response.RootCertificate, false)
+	signingCert := parseCACertificate(t, response.CommonSigningCertificate, false)
+	if !rootCert.IsCA || !signingCert.IsCA {
+		t.Errorf("Expected CA certificates to be returned")
+	}
+	if err := signingCert.CheckSignatureFrom(rootCert); err != nil {
+		t.Errorf("Common signing certificate was not issued by the root CA: %v",
+			err)
+	}
+	if len(response.TenantSigningCertificate) != 0 {
+		t.Errorf("Unexpected tenant signing certificate returned")
+	}
+	if (len(response.Chain) != 2) ||
+		!bytes.Equal(response.Chain[0], response.CommonSigningCertificate) ||
+		!bytes.Equal(response.Chain[1], response.RootCertificate) {
+		t.Errorf("Expected the common signing and root CA certificates in the chain")
+	}
+	if len(response.Bundle) != 0 {
+		t.Errorf("Unexpected bundle returned for the default format")
+	}
+}
+
+func TestGetCACertificates_TenantPemChain(t *testing.T) {
+	response, err := gClient.GetCACertificates(gCtx,
+		&pb.GetCACertificatesRequest{
+			Header:  newCaProtocolHeader(),
+			Version: CaProtocolVersion,
+			Tid:     testTenantID,
+			Format:  pb.CertificateFormat_CERTIFICATE_FORMAT_PEM_CHAIN,
+		})
+	if err != nil {
+		t.Fatalf("GetCACertificates RPC failed: %v", err)
+	}
+	if response.Header.Status != uint32(codes.OK) {
+		t.Fatalf("Expected status %d, got %d", codes.OK, response.Header.Status)
+	}
+
+	rootCert := parseCACertificate(t, response.RootCertificate, true)
+	tenantCert := parseCACertificate(t, response.TenantSigningCertificate, true)
+	if err := tenantCert.CheckSignatureFrom(rootCert); err != nil {
+		t.Errorf("Tenant signing certificate was not issued by the root CA: %v",
+			err)
+	}
+	if bytes.Equal(response.TenantSigningCertificate,
+		response.CommonSigningCertificate) {
+		t.Errorf("Expected the tenant signing certificate to be returned")
+	}
+	if !bytes.Equal(response.Bundle, bytes.Join(response.Chain, nil)) {
+		t.Errorf("Expected the bundle to contain the PEM encoded chain")
+	}
+}
+
+func TestGetCACertificates_UnknownTenantPkcs7(t *testing.T) {
+	response, err := gClient.GetCACertificates(gCtx,
+		&pb.GetCACertificatesRequest{
+			Header:  newCaProtocolHeader(),
+			Version: CaProtocolVersion,
+			Tid:     uuid.New().String(),
+			Format:  pb.CertificateFormat_CERTIFICATE_FORMAT_PKCS7,
+		})
+	if err != nil {
+		t.Fatalf("GetCACertificates RPC failed: %v", err)
+	}
+	if response.Header.Status != uint32(codes.OK) {
+		t.Fatalf("Expected status %d, got %d", codes.OK, response.Header.Status)
+	}
+
+	// Tenants without a tenant signing certificate use the common signing
+	// certificate.
+	if len(response.TenantSigningCertificate) != 0 {
+		t.Errorf("Unexpected tenant signing certificate returned")
+	}
+	parsed, err := pkcs7.Parse(response.Bundle)
+	if err != nil {
+		t.Fatalf("Failed to parse the PKCS7 bundle: %v", err)
+	}
+	if (len(parsed.Certificates) != 2) ||
+		!bytes.Equal(parsed.Certificates[0].Raw, response.CommonSigningCertificate) ||
+		!bytes.Equal(parsed.Certificates[1].Raw, response.RootCertificate) {
+		t.Errorf("Expected the common signing and root CA certificates in the bundle")
+	}
+}
+
+func TestGetCACertificates_V2InvalidFormat(t *testing.T) {
+	_, err := gClient.GetCACertificates(gCtx,
+		&pb.GetCACertificatesRequest{
+			Header:  newCaV2ProtocolHeader(),
+			Version: CaProtocolVersion,
+			Format:  pb.CertificateFormat(100),
+		})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected status code %s, got %s", codes.InvalidArgument,
+			status.Code(err))
+	}
+}
@@ -0,0 +1,110 @@
+// package github.com/HPInc/krypton-ca/service/rpc
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the GetTenantQuota RPC used to retrieve the device cap applicable
+// to the specified tenant, along with the number of active devices within the
+// tenant.
+package rpc
+
+import (
+	"context"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GetTenantQuota RPC is used to retrieve the device quota for a tenant.
+func (s *CertificateAuthorityServer) GetTenantQuota(ctx context.Context,
+	request *pb.GetTenantQuotaRequest) (*pb.GetTenantQuotaResponse, error) {
+
+	// Validate the request header and extract the request identifier for
+	// end-to-end request tracing.
+	requestID, ok := isValidRequestHeader(request.Header)
+	if !ok {
+		caLogger.Error("GetTenantQuota: Invalid request header specified!")
+		response := invalidGetTenantQuotaResponse(requestID)
+		return response, nil
+	}
+
+	if request.Tid == "" {
+		caLogger.Error("GetTenantQuota: TenantID was not specified!",
+			zap.String("Request ID:", requestID),
+		)
+		response := invalidGetTenantQuotaResponse(requestID)
+		return response, badRequestError(request.Header, requestID,
+			"GetTenantQuota RPC failed",
+			fieldViolation("tid", "tenant ID must be specified"))
+	}
+
+	tenantQuota, err := s.quotaManager.GetTenantQuota(ctx, request.Tid)
+	if err != nil {
+		caLogger.Error("GetTenantQuota: Failed to get the quota for the tenant!",
+			zap.String("Request ID:", requestID),
+			zap.String("Tenant ID:", request.Tid),
+			zap.Error(err),
+		)
+		response := internalErrorGetTenantQuotaResponse(requestID)
+		return response, statusErrorFromErr(request.Header, requestID,
+			"GetTenantQuota RPC failed", err)
+	}
+
+	response := successGetTenantQuotaResponse(requestID, tenantQuota)
+	return response, nil
+}
+
+func invalidGetTenantQuotaResponse(
+	requestID string) *pb.GetTenantQuotaResponse {
+	response := &pb.GetTenantQuotaResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.InvalidArgument),
+			StatusMessage:   "GetTenantQuota RPC failed",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	metrics.MetricTenantQuotaBadRequests.Inc()
+	return response
+}
+
+func successGetTenantQuotaResponse(requestID string,
+	tenantQuota *quota.TenantQuota) *pb.GetTenantQuotaResponse {
+	response := &pb.GetTenantQuotaResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.OK),
+			StatusMessage:   "GetTenantQuota RPC successful",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+		Tid:           tenantQuota.TenantID,
+		MaxDevices:    int64(tenantQuota.MaxDevices),
+		ActiveDevices: int64(tenantQuota.ActiveDevices),
+		Source:        tenantQuota.Source,
+	}
+
+	return response
+}
+
+func internalErrorGetTenantQuotaResponse(
+	requestID string) *pb.GetTenantQuotaResponse {
+	response := &pb.GetTenantQuotaResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.Internal),
+			StatusMessage:   "GetTenantQuota RPC failed",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	metrics.MetricTenantQuotaInternalErrors.Inc()
+	return response
+}
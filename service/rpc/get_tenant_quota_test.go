@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"testing"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+func TestGetTenantQuota(t *testing.T) {
+	request := &pb.GetTenantQuotaRequest{
+		Header:  newCaProtocolHeader(),
+		Version: CaProtocolVersion,
+		Tid:     uuid.NewString(),
+	}
+
+	response, err := gClient.GetTenantQuota(gCtx, request)
+	if err != nil {
+		caLogger.Error("TestGetTenantQuota: RPC failed",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+
+	assertEqual(t, response.Header.Status, uint32(codes.OK))
+	assertEqual(t, response.Source, quota.QuotaSourceDefault)
+	assertEqual(t, response.ActiveDevices, int64(0))
+	caLogger.Info("Response from certificate authority:",
+		zap.Any("Response", response))
+}
+
+func TestGetTenantQuota_ConfiguredTenant(t *testing.T) {
+	tenantID := uuid.NewString()
+	gTestQuotaSettings.TenantMaxDevices[tenantID] = 25
+	defer delete(gTestQuotaSettings.TenantMaxDevices, tenantID)
+
+	request := &pb.GetTenantQuotaRequest{
+		Header:  newCaProtocolHeader(),
+		Version: CaProtocolVersion,
+		Tid:     tenantID,
+	}
+
+	response, err := gClient.GetTenantQuota(gCtx, request)
+	if err != nil {
+		caLogger.Error("TestGetTenantQuota_ConfiguredTenant: RPC failed",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+
+	assertEqual(t, response.Header.Status, uint32(codes.OK))
+	assertEqual(t, response.Source, quota.QuotaSourceConfig)
+	assertEqual(t, response.MaxDevices, int64(25))
+}
+
+func TestGetTenantQuota_NoTenantID(t *testing.T) {
+	request := &pb.GetTenantQuotaRequest{
+		Header:  newCaProtocolHeader(),
+		Version: CaProtocolVersion,
+	}
+
+	response, err := gClient.GetTenantQuota(gCtx, request)
+	if err != nil {
+		caLogger.Error("TestGetTenantQuota_NoTenantID: RPC failed",
+			zap.Error(err))
+		t.Fail()
+		return
+	}
+
+	assertEqual(t, response.Header.Status, uint32(codes.InvalidArgument))
+}
@@ -33,12 +33,14 @@ func (s *CertificateAuthorityServer) GetTenantSigningCertificate(ctx context.Con
 			zap.String("Request ID:", requestID),
 		)
 		response := invalidGetTenantSigningCertificateResponse(requestID)
-		return response, nil
+		return response, badRequestError(request.Header, requestID,
+			"GetTenantSigningCertificate RPC failed",
+			fieldViolation("tid", "tenant ID must be specified"))
 	}
 
 	// Invoke the configured KMS provider to retrieve the tenant signing
 	// certificate.
-	certBytes, err := s.kmsProvider.GetTenantSigningCertificate(request.Tid)
+	certBytes, err := s.kmsProvider.GetTenantSigningCertificate(ctx, request.Tid)
 	if err != nil {
 		caLogger.Error("Failed to get tenant signing certificate!",
 			zap.String("Tenant ID:", request.Tid),
@@ -46,7 +48,8 @@ func (s *CertificateAuthorityServer) GetTenantSigningCertificate(ctx context.Con
 			zap.Error(err),
 		)
 		response := internalErrorGetTenantSigningCertificateResponse(requestID)
-		return response, nil
+		return response, statusErrorFromErr(request.Header, requestID,
+			"GetTenantSigningCertificate RPC failed", err)
 	}
 
 	response := successGetTenantSigningCertificateResponse(requestID, certBytes)
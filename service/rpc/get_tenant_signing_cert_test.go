@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestGetTenantSigningCertificate(t *testing.T) {
@@ -67,3 +68,14 @@ func TestGetTenantSigningCertificate_NoTenantID(t *testing.T) {
 	caLogger.Info("Response from certificate authority",
 		zap.Any("Response", response))
 }
+
+func TestGetTenantSigningCertificate_V2UnknownTenantID(t *testing.T) {
+	request := &pb.GetTenantSigningCertificateRequest{
+		Header:  newCaV2ProtocolHeader(),
+		Version: CaProtocolVersion,
+		Tid:     uuid.New().String(),
+	}
+
+	_, err := gClient.GetTenantSigningCertificate(gCtx, request)
+	assertEqual(t, status.Code(err), codes.NotFound)
+}
@@ -3,21 +3,35 @@
 // Component: Krypton Certificate Authority
 // (C) HP Development Company, LP
 // Purpose:
-// Implements a common interceptor used to intercept all unary RPC requests
-// received by the CA gRPC server. This interceptor is used to calculate
-// request latencies while processing RPC requests, and track RPC error metrics
-// and RPC served metrics.
+// Implements common interceptors used to intercept all unary and streaming RPC
+// requests received by the CA gRPC server. These interceptors are used to
+// calculate request latencies while processing RPC requests, and track RPC
+// error metrics and RPC served metrics. The unary interceptor also reports the
+// protocol version negotiated by the caller in the response header.
 package rpc
 
 import (
 	"context"
 	"time"
 
+	pb "github.com/HPInc/krypton-ca/caprotos"
 	"github.com/HPInc/krypton-ca/service/metrics"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
 
+// caRequest is implemented by RPC request messages carrying the common CA
+// request header.
+type caRequest interface {
+	GetHeader() *pb.CaRequestHeader
+}
+
+// caResponse is implemented by RPC response messages carrying the common CA
+// response header.
+type caResponse interface {
+	GetHeader() *pb.CaResponseHeader
+}
+
 // Interceptor for unary gRPCs served by the Certificate Authority.
 func unaryInterceptor(ctx context.Context,
 	req interface{},
@@ -35,6 +49,7 @@ func unaryInterceptor(ctx context.Context,
 		metrics.MetricRPCErrors.Inc()
 	} else {
 		metrics.MetricRPCsServed.Inc()
+		setResponseProtocolVersion(req, h)
 	}
 
 	caLogger.Info("Processed gRPC request.",
@@ -44,3 +59,43 @@ func unaryInterceptor(ctx context.Context,
 	)
 	return h, err
 }
+
+// Interceptor for streaming gRPCs served by the Certificate Authority.
+func streamInterceptor(srv interface{},
+	stream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	start := time.Now()
+
+	// Calculate and report RPC latency metric when the interceptor is done.
+	defer metrics.ReportLatencyMetric(metrics.MetricRPCLatency, start,
+		info.FullMethod)
+
+	// Invoke the handler to process the gRPC stream and update RPC metrics.
+	err := handler(srv, stream)
+	if err != nil {
+		metrics.MetricRPCErrors.Inc()
+	} else {
+		metrics.MetricRPCsServed.Inc()
+	}
+
+	caLogger.Info("Processed gRPC stream.",
+		zap.String("Method:", info.FullMethod),
+		zap.String("Duration:", time.Since(start).String()),
+		zap.Error(err),
+	)
+	return err
+}
+
+// Echo the protocol version negotiated by version 2 callers in the response
+// header. Responses to version 1 callers are unchanged.
+func setResponseProtocolVersion(req interface{}, resp interface{}) {
+	request, ok := req.(caRequest)
+	if !ok || !useStatusErrors(request.GetHeader()) {
+		return
+	}
+
+	if response, ok := resp.(caResponse); ok && (response.GetHeader() != nil) {
+		response.GetHeader().ProtocolVersion = CaProtocolVersionV2
+	}
+}
@@ -9,9 +9,10 @@ package rpc
 
 import (
 	"context"
-	"fmt"
 
 	pb "github.com/HPInc/krypton-ca/caprotos"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -26,7 +27,8 @@ func (s *CertificateAuthorityServer) Ping(ctx context.Context,
 
 	// Reject overly long ping requests.
 	if len(request.Message) > maxLengthPingMessage {
-		return nil, fmt.Errorf("invalid ping request - message too long")
+		return nil, status.Error(codes.InvalidArgument,
+			"invalid ping request - message too long")
 	}
 
 	// Respond with the caller's ping message and the current timestamp to
@@ -0,0 +1,403 @@
+// package github.com/HPInc/krypton-ca/service/rpc
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements rate limiting interceptors for RPC requests received by the CA
+// gRPC server. Each message received on a stream is rate limited in the same
+// manner as a unary request. Requests for a batch of device certificates are
+// charged one token for each item in the batch, so that batching does not
+// bypass the limits. Batches with more items than the burst of a limit can
+// never be permitted, and are rejected without a retry delay. Enrollment requests received over EST, SCEP and ACME are
+// subject to the same limits. Token buckets are maintained for each tenant and
+// for each caller identity, so that a runaway client within one tenant cannot
+// starve other tenants of certificate issuance (and of the KMS signing
+// capacity shared by all tenants).
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// Labels used to report which limit was exceeded by a throttled request.
+	rateLimitTenant = "tenant"
+	rateLimitCaller = "caller"
+
+	// Token buckets that have not been used for this long are discarded.
+	rateLimitBucketIdleTimeout = 10 * time.Minute
+
+	// Interval at which idle token buckets are swept.
+	rateLimitSweepInterval = time.Minute
+
+	// Caller identity used when the peer cannot be determined.
+	unknownCallerIdentity = "unknown"
+)
+
+// Methods that are not subject to rate limiting. Health checks are exempt so
+// that a busy CA is not reported as unhealthy.
+var rateLimitExemptMethods = map[string]bool{
+	MethodPing:                           true,
+	healthpb.Health_Check_FullMethodName: true,
+	healthpb.Health_List_FullMethodName:  true,
+	healthpb.Health_Watch_FullMethodName: true,
+}
+
+// tenantScopedRequest is implemented by RPC request messages that specify the
+// tenant the request is issued for.
+type tenantScopedRequest interface {
+	GetTid() string
+}
+
+// batchRequest is implemented by RPC request messages that specify a batch of
+// items, each of which is charged against the rate limits.
+type batchRequest interface {
+	GetItems() []*pb.DeviceCertificateRequestItem
+}
+
+// tokenBucket is a rate limiter for a single tenant or caller, along with the
+// time it was last used so that idle buckets can be discarded.
+type tokenBucket struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// bucketSet tracks the token buckets for a single class of limits (tenant or
+// caller identity).
+type bucketSet struct {
+	lock      sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// requestRateLimiter maintains token buckets for tenants and caller
+// identities and decides whether requests are permitted.
+type requestRateLimiter struct {
+	settings *config.RateLimit
+	tenants  bucketSet
+	callers  bucketSet
+}
+
+// newRequestRateLimiter creates a rate limiter using the specified settings.
+func newRequestRateLimiter(settings *config.RateLimit) *requestRateLimiter {
+	return &requestRateLimiter{
+		settings: settings,
+		tenants:  bucketSet{buckets: map[string]*tokenBucket{}},
+		callers:  bucketSet{buckets: map[string]*tokenBucket{}},
+	}
+}
+
+// reserve attempts to take the specified number of tokens from the bucket for
+// the specified key. If the tokens are available, the reservation is returned
+// so that the tokens can be returned to the bucket if the request is rejected
+// by another limit. Otherwise, the time after which the caller may retry is
+// returned.
+func (b *bucketSet) reserve(key string, quota config.RateLimitQuota,
+	cost int, now time.Time) (*rate.Reservation, time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.sweep(now)
+
+	bucket, ok := b.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{
+			limiter: rate.NewLimiter(rate.Limit(quota.RequestsPerSecond),
+				quota.Burst),
+		}
+		b.buckets[key] = bucket
+	}
+	bucket.lastUsed = now
+
+	reservation := bucket.limiter.ReserveN(now, cost)
+	if !reservation.OK() {
+		return nil, time.Second
+	}
+
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		// Return the tokens - the request is rejected rather than queued.
+		reservation.CancelAt(now)
+		return nil, delay
+	}
+	return reservation, 0
+}
+
+// sweep discards token buckets that have been idle for a while. Must be
+// called with the lock held.
+func (b *bucketSet) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	b.lastSweep = now
+
+	for key, bucket := range b.buckets {
+		if now.Sub(bucket.lastUsed) > rateLimitBucketIdleTimeout {
+			delete(b.buckets, key)
+		}
+	}
+}
+
+// tenantQuota returns the quota applicable to the specified tenant.
+func (l *requestRateLimiter) tenantQuota(tenantID string) config.RateLimitQuota {
+	if quota, ok := l.settings.TenantOverrides[tenantID]; ok {
+		return quota
+	}
+	return l.settings.Tenant
+}
+
+// allow checks whether a request costing the specified number of tokens from
+// the specified caller on behalf of the specified tenant is permitted. If not,
+// the limit that was exceeded and a retry delay are returned. No retry delay
+// is returned for requests costing more than the burst of the limit, since
+// they are never permitted.
+func (l *requestRateLimiter) allow(tenantID string, callerID string,
+	cost int) (bool, string, time.Duration) {
+	now := time.Now()
+
+	if cost > l.settings.Caller.Burst {
+		return false, rateLimitCaller, 0
+	}
+	if (tenantID != "") && (cost > l.tenantQuota(tenantID).Burst) {
+		return false, rateLimitTenant, 0
+	}
+
+	callerReservation, delay := l.callers.reserve(callerID, l.settings.Caller,
+		cost, now)
+	if callerReservation == nil {
+		return false, rateLimitCaller, delay
+	}
+
+	if tenantID != "" {
+		tenantReservation, delay := l.tenants.reserve(tenantID,
+			l.tenantQuota(tenantID), cost, now)
+		if tenantReservation == nil {
+			// Return the tokens taken from the caller's bucket, so that
+			// requests rejected by the tenant limit do not count against
+			// the caller's quota.
+			callerReservation.CancelAt(now)
+			return false, rateLimitTenant, delay
+		}
+	}
+	return true, "", 0
+}
+
+// AllowRequest - checks whether a request received over another enrollment
+// protocol (EST, SCEP or ACME) on behalf of the specified tenant is permitted
+// by the rate limits applied to RPC requests, so that devices enrolling over
+// any protocol share the quotas of the tenant and caller. The caller is
+// identified using the specified context, as returned by NewCallerContext. If
+// the request is not permitted, the time after which the caller may retry is
+// returned.
+func (s *CertificateAuthorityServer) AllowRequest(ctx context.Context,
+	method string, tenantID string) (bool, time.Duration) {
+	if s.rateLimiter == nil {
+		return true, 0
+	}
+
+	callerID := getCallerIdentity(ctx)
+	ok, limit, delay := s.rateLimiter.allow(tenantID, callerID, 1)
+	if ok {
+		return true, 0
+	}
+
+	metrics.MetricRPCRequestsThrottled.WithLabelValues(limit, method).Inc()
+	caLogger.Warn("Rate limit exceeded. Rejecting enrollment request!",
+		zap.String("Method:", method),
+		zap.String("Limit:", limit),
+		zap.String("Tenant ID:", tenantID),
+		zap.String("Caller:", callerID),
+		zap.Duration("Retry after:", delay),
+	)
+	return false, delay
+}
+
+// requestCost returns the number of tokens charged for the specified request
+// message - one for each item in a batch, and one for any other request.
+func requestCost(req interface{}) int {
+	if r, ok := req.(batchRequest); ok && (len(r.GetItems()) > 1) {
+		return len(r.GetItems())
+	}
+	return 1
+}
+
+// Interceptor for unary gRPCs that enforces per-tenant and per-caller rate
+// limits. Requests exceeding their quota are rejected with ResourceExhausted
+// and a RetryInfo detail indicating when the caller may retry.
+func (l *requestRateLimiter) unaryInterceptor(ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	if rateLimitExemptMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	var tenantID string
+	if r, ok := req.(tenantScopedRequest); ok {
+		tenantID = r.GetTid()
+	}
+	callerID := getCallerIdentity(ctx)
+
+	ok, limit, delay := l.allow(tenantID, callerID, requestCost(req))
+	if ok {
+		return handler(ctx, req)
+	}
+
+	metrics.MetricRPCRequestsThrottled.WithLabelValues(limit,
+		info.FullMethod).Inc()
+	caLogger.Warn("Rate limit exceeded. Rejecting request!",
+		zap.String("Method:", info.FullMethod),
+		zap.String("Limit:", limit),
+		zap.String("Tenant ID:", tenantID),
+		zap.String("Caller:", callerID),
+		zap.Duration("Retry after:", delay),
+	)
+	return nil, newRateLimitExceededError(limit, tenantID, callerID, delay)
+}
+
+// Interceptor for streaming gRPCs that enforces per-tenant and per-caller rate
+// limits on each message received on the stream. Messages exceeding their
+// quota fail the stream with ResourceExhausted and a RetryInfo detail.
+func (l *requestRateLimiter) streamInterceptor(srv interface{},
+	stream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	if rateLimitExemptMethods[info.FullMethod] {
+		return handler(srv, stream)
+	}
+
+	return handler(srv, &rateLimitedServerStream{
+		ServerStream: stream,
+		limiter:      l,
+		method:       info.FullMethod,
+		callerID:     getCallerIdentity(stream.Context()),
+	})
+}
+
+// rateLimitedServerStream rate limits the messages received on a stream.
+type rateLimitedServerStream struct {
+	grpc.ServerStream
+	limiter  *requestRateLimiter
+	method   string
+	callerID string
+}
+
+// RecvMsg receives the next message on the stream, and checks whether it is
+// permitted by the rate limits of the tenant and caller.
+func (s *rateLimitedServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err != nil {
+		return err
+	}
+
+	var tenantID string
+	if r, ok := m.(tenantScopedRequest); ok {
+		tenantID = r.GetTid()
+	}
+
+	ok, limit, delay := s.limiter.allow(tenantID, s.callerID, requestCost(m))
+	if ok {
+		return nil
+	}
+
+	metrics.MetricRPCRequestsThrottled.WithLabelValues(limit, s.method).Inc()
+	caLogger.Warn("Rate limit exceeded. Rejecting streamed request!",
+		zap.String("Method:", s.method),
+		zap.String("Limit:", limit),
+		zap.String("Tenant ID:", tenantID),
+		zap.String("Caller:", s.callerID),
+		zap.Duration("Retry after:", delay),
+	)
+	return newRateLimitExceededError(limit, tenantID, s.callerID, delay)
+}
+
+// Build a ResourceExhausted status error with details describing the quota
+// that was exceeded and when the request may be retried. A zero delay
+// indicates that the request costs more than the burst of the quota, and must
+// not be retried.
+func newRateLimitExceededError(limit string, tenantID string, callerID string,
+	delay time.Duration) error {
+	subject := "caller:" + callerID
+	if limit == rateLimitTenant {
+		subject = "tenant:" + tenantID
+	}
+
+	if delay == 0 {
+		st := status.New(codes.ResourceExhausted,
+			"request exceeds the rate limit burst")
+		detailed, err := st.WithDetails(&errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{
+				{
+					Subject:     subject,
+					Description: "batch size exceeds the burst permitted for " + limit,
+				},
+			},
+		})
+		if err != nil {
+			return st.Err()
+		}
+		return detailed.Err()
+	}
+
+	st := status.New(codes.ResourceExhausted, "request rate limit exceeded")
+	detailed, err := st.WithDetails(
+		&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(delay),
+		},
+		&errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{
+				{
+					Subject:     subject,
+					Description: "request rate limit exceeded for " + limit,
+				},
+			},
+		},
+	)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// Determine the identity of the caller issuing the request. If the caller
+// authenticated using a TLS client certificate, the subject common name of
+// the certificate is used. Otherwise, the caller's network address is used.
+func getCallerIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return unknownCallerIdentity
+	}
+
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		certs := tlsInfo.State.PeerCertificates
+		if (len(certs) > 0) && (certs[0].Subject.CommonName != "") {
+			return "cn:" + certs[0].Subject.CommonName
+		}
+	}
+
+	if p.Addr == nil {
+		return unknownCallerIdentity
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
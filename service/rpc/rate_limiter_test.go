@@ -0,0 +1,294 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testCreateDeviceCertificateInfo = &grpc.UnaryServerInfo{
+	FullMethod: "/caprotos.CertificateAuthority/CreateDeviceCertificate",
+}
+
+func newTestRateLimiter() *requestRateLimiter {
+	return newRequestRateLimiter(&config.RateLimit{
+		Enabled: true,
+		Tenant:  config.RateLimitQuota{RequestsPerSecond: 0.01, Burst: 2},
+		Caller:  config.RateLimitQuota{RequestsPerSecond: 0.01, Burst: 5},
+		TenantOverrides: map[string]config.RateLimitQuota{
+			"privileged": {RequestsPerSecond: 0.01, Burst: 4},
+		},
+	})
+}
+
+func okHandler(ctx context.Context, req interface{}) (interface{}, error) {
+	return "ok", nil
+}
+
+func invokeRateLimited(limiter *requestRateLimiter, tenantID string) error {
+	request := &pb.CreateDeviceCertificateRequest{
+		Header: newCaProtocolHeader(),
+		Tid:    tenantID,
+	}
+	_, err := limiter.unaryInterceptor(context.Background(), request,
+		testCreateDeviceCertificateInfo, okHandler)
+	return err
+}
+
+func TestRateLimiter_TenantQuota(t *testing.T) {
+	limiter := newTestRateLimiter()
+	tenantID := uuid.NewString()
+
+	// The tenant burst allows two requests through.
+	for i := 0; i < 2; i++ {
+		if err := invokeRateLimited(limiter, tenantID); err != nil {
+			caLogger.Error("TestRateLimiter_TenantQuota: request was throttled",
+				zap.Error(err))
+			t.Fail()
+			return
+		}
+	}
+
+	// The next request exceeds the tenant quota.
+	err := invokeRateLimited(limiter, tenantID)
+	st, _ := status.FromError(err)
+	assertEqual(t, st.Code(), codes.ResourceExhausted)
+
+	// The response must include a hint about when to retry.
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retryInfo = info
+		}
+	}
+	if (retryInfo == nil) || (retryInfo.RetryDelay.AsDuration() <= 0) {
+		caLogger.Error("TestRateLimiter_TenantQuota: missing retry hint",
+			zap.Any("Details", st.Details()))
+		t.Fail()
+	}
+
+	// Other tenants are unaffected.
+	err = invokeRateLimited(limiter, uuid.NewString())
+	assertEqual(t, err, nil)
+}
+
+func invokeRateLimitedBatch(limiter *requestRateLimiter, tenantID string,
+	count int) error {
+	request := &pb.CreateDeviceCertificatesRequest{
+		Header: newCaProtocolHeader(),
+		Tid:    tenantID,
+	}
+	for i := 0; i < count; i++ {
+		request.Items = append(request.Items, &pb.DeviceCertificateRequestItem{})
+	}
+	_, err := limiter.unaryInterceptor(context.Background(), request,
+		&grpc.UnaryServerInfo{FullMethod: MethodCreateDeviceCertificates},
+		okHandler)
+	return err
+}
+
+func TestRateLimiter_BatchCost(t *testing.T) {
+	limiter := newTestRateLimiter()
+	tenantID := uuid.NewString()
+
+	// Each item in a batch counts against the tenant quota, so the batch
+	// exhausts the tenant burst.
+	err := invokeRateLimitedBatch(limiter, tenantID, 2)
+	assertEqual(t, err, nil)
+	err = invokeRateLimited(limiter, tenantID)
+	assertEqual(t, status.Code(err), codes.ResourceExhausted)
+
+	// Batches larger than the tenant burst are rejected without a retry hint,
+	// and without taking any tokens from the tenant or caller buckets.
+	limiter = newTestRateLimiter()
+	err = invokeRateLimitedBatch(limiter, tenantID, 3)
+	st, _ := status.FromError(err)
+	assertEqual(t, st.Code(), codes.ResourceExhausted)
+	for _, detail := range st.Details() {
+		if _, ok := detail.(*errdetails.RetryInfo); ok {
+			t.Errorf("Unexpected retry hint for a batch exceeding the burst")
+		}
+	}
+	for i := 0; i < 2; i++ {
+		err = invokeRateLimited(limiter, tenantID)
+		assertEqual(t, err, nil)
+	}
+
+	// Every item counts against the caller quota, so a batch of four items
+	// leaves one token of the caller burst for requests from other tenants.
+	limiter = newTestRateLimiter()
+	err = invokeRateLimitedBatch(limiter, "privileged", 4)
+	assertEqual(t, err, nil)
+	err = invokeRateLimited(limiter, uuid.NewString())
+	assertEqual(t, err, nil)
+	err = invokeRateLimited(limiter, uuid.NewString())
+	assertEqual(t, status.Code(err), codes.ResourceExhausted)
+}
+
+func TestRateLimiter_TenantOverride(t *testing.T) {
+	limiter := newTestRateLimiter()
+
+	for i := 0; i < 4; i++ {
+		err := invokeRateLimited(limiter, "privileged")
+		assertEqual(t, err, nil)
+	}
+
+	err := invokeRateLimited(limiter, "privileged")
+	assertEqual(t, status.Code(err), codes.ResourceExhausted)
+}
+
+func TestRateLimiter_CallerQuota(t *testing.T) {
+	limiter := newTestRateLimiter()
+
+	// All requests originate from the same caller, but are spread across
+	// tenants so that only the caller quota is exceeded.
+	for i := 0; i < 5; i++ {
+		err := invokeRateLimited(limiter, uuid.NewString())
+		assertEqual(t, err, nil)
+	}
+
+	err := invokeRateLimited(limiter, uuid.NewString())
+	assertEqual(t, status.Code(err), codes.ResourceExhausted)
+}
+
+func TestRateLimiter_TenantRejectionRefundsCaller(t *testing.T) {
+	limiter := newTestRateLimiter()
+	tenantID := uuid.NewString()
+
+	// Requests rejected by the tenant quota must not consume the caller
+	// quota.
+	for i := 0; i < 2; i++ {
+		err := invokeRateLimited(limiter, tenantID)
+		assertEqual(t, err, nil)
+	}
+	for i := 0; i < 5; i++ {
+		err := invokeRateLimited(limiter, tenantID)
+		assertEqual(t, status.Code(err), codes.ResourceExhausted)
+	}
+
+	// The caller has only used two of its five tokens.
+	for i := 0; i < 3; i++ {
+		err := invokeRateLimited(limiter, uuid.NewString())
+		assertEqual(t, err, nil)
+	}
+	err := invokeRateLimited(limiter, uuid.NewString())
+	assertEqual(t, status.Code(err), codes.ResourceExhausted)
+}
+
+func TestRateLimiter_AllowRequest(t *testing.T) {
+	ctx := NewCallerContext(context.Background(),
+		&net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443}, nil)
+	tenantID := uuid.NewString()
+
+	// Enrollment requests share the quotas applied to RPC requests.
+	s := &CertificateAuthorityServer{rateLimiter: newTestRateLimiter()}
+	for i := 0; i < 2; i++ {
+		ok, _ := s.AllowRequest(ctx, "est/simpleenroll", tenantID)
+		assertEqual(t, ok, true)
+	}
+	ok, delay := s.AllowRequest(ctx, "est/simpleenroll", tenantID)
+	assertEqual(t, ok, false)
+	if delay <= 0 {
+		t.Errorf("Expected a retry delay, got %v", delay)
+	}
+
+	// Requests are not limited if rate limiting is not enabled.
+	s = &CertificateAuthorityServer{}
+	ok, _ = s.AllowRequest(ctx, "est/simpleenroll", tenantID)
+	assertEqual(t, ok, true)
+}
+
+func TestRateLimiter_ExemptMethod(t *testing.T) {
+	limiter := newTestRateLimiter()
+	info := &grpc.UnaryServerInfo{
+		FullMethod: "/caprotos.CertificateAuthority/Ping",
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err := limiter.unaryInterceptor(context.Background(),
+			&pb.PingRequest{Message: "ping"}, info, okHandler)
+		assertEqual(t, err, nil)
+	}
+}
+
+// A server stream which receives the specified requests, in order.
+type testServerStream struct {
+	grpc.ServerStream
+	requests []*pb.CreateDeviceCertificatesRequest
+}
+
+func (s *testServerStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *testServerStream) RecvMsg(m interface{}) error {
+	request := s.requests[0]
+	s.requests = s.requests[1:]
+	m.(*pb.CreateDeviceCertificatesRequest).Tid = request.Tid
+	m.(*pb.CreateDeviceCertificatesRequest).Items = request.Items
+	return nil
+}
+
+func TestRateLimiter_StreamedRequests(t *testing.T) {
+	limiter := newTestRateLimiter()
+	tenantID := uuid.NewString()
+	stream := &testServerStream{}
+	for i := 0; i < 3; i++ {
+		stream.requests = append(stream.requests,
+			&pb.CreateDeviceCertificatesRequest{Tid: tenantID})
+	}
+
+	// Each message received on the stream counts against the tenant quota,
+	// so the third message is throttled.
+	err := limiter.streamInterceptor(nil, stream,
+		&grpc.StreamServerInfo{FullMethod: MethodStreamDeviceCertificates},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			for i := 0; i < 3; i++ {
+				var request pb.CreateDeviceCertificatesRequest
+				if err := stream.RecvMsg(&request); err != nil {
+					if i != 2 {
+						t.Errorf("Message %d was throttled", i)
+					}
+					return err
+				}
+			}
+			return nil
+		})
+	assertEqual(t, status.Code(err), codes.ResourceExhausted)
+}
+
+func TestRateLimiter_StreamedBatchCost(t *testing.T) {
+	limiter := newTestRateLimiter()
+	tenantID := uuid.NewString()
+	stream := &testServerStream{requests: []*pb.CreateDeviceCertificatesRequest{
+		{Tid: tenantID, Items: []*pb.DeviceCertificateRequestItem{{}, {}}},
+		{Tid: tenantID, Items: []*pb.DeviceCertificateRequestItem{{}}},
+	}}
+
+	// The items in the first message exhaust the tenant burst, so the second
+	// message is throttled.
+	err := limiter.streamInterceptor(nil, stream,
+		&grpc.StreamServerInfo{FullMethod: MethodStreamDeviceCertificates},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			for i := 0; i < 2; i++ {
+				var request pb.CreateDeviceCertificatesRequest
+				if err := stream.RecvMsg(&request); err != nil {
+					if i != 1 {
+						t.Errorf("Message %d was throttled", i)
+					}
+					return err
+				}
+			}
+			return nil
+		})
+	assertEqual(t, status.Code(err), codes.ResourceExhausted)
+}
@@ -8,14 +8,20 @@
 // a fresh CSR to obtain a new device certificate. The device ID issued to the
 // device however is unchanged. The expectation is that the caller will verify
 // the device access token and extract the device ID from that token to ensure
-// the device ID is valid.
+// the device ID is valid. Renewals extend the lifetime of the device within the
+// tenant, but do not count against the tenant's device cap. Renewals for
+// devices which are not recorded within the tenant are treated as enrollments
+// of new devices, and are only permitted if the tenant has not reached its
+// device cap.
 package rpc
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
 	"github.com/HPInc/krypton-ca/service/metrics"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
@@ -42,12 +48,58 @@ func (s *CertificateAuthorityServer) RenewDeviceCertificate(ctx context.Context,
 			zap.String("Request ID:", requestID),
 		)
 		response := invalidRenewDeviceCertificateResponse(requestID)
-		return response, nil
+		return response, badRequestError(request.Header, requestID,
+			"RenewDeviceCertificate RPC failed",
+			missingFieldViolations(
+				requiredField{"tid", request.Tid != ""},
+				requiredField{"device_id", request.DeviceId != ""},
+				requiredField{"csr", request.Csr != nil})...)
+	}
+
+	if !isValidCertificateFormat(request.Format) {
+		caLogger.Error("RenewDeviceCertificate: Unsupported certificate format requested!",
+			zap.String("Request ID:", requestID),
+			zap.Int32("Format:", int32(request.Format)),
+		)
+		response := invalidRenewDeviceCertificateResponse(requestID)
+		return response, badRequestError(request.Header, requestID,
+			"RenewDeviceCertificate RPC failed",
+			fieldViolation("format", "unsupported certificate format"))
+	}
+
+	// Devices which are already recorded within the tenant are counted against
+	// the tenant's device cap, so capacity is only reserved for unknown
+	// devices.
+	reservation, err := s.quotaManager.ReserveRenewal(ctx, request.Tid,
+		request.DeviceId)
+	if err != nil {
+		if errors.Is(err, quota.ErrDeviceCapReached) {
+			caLogger.Error("RenewDeviceCertificate: Tenant has reached its device cap!",
+				zap.String("Request ID:", requestID),
+				zap.String("Tenant ID:", request.Tid),
+				zap.String("Device ID:", request.DeviceId),
+			)
+			response := deviceCapReachedRenewDeviceCertificateResponse(requestID)
+			return response, statusErrorFromErr(request.Header, requestID,
+				"RenewDeviceCertificate RPC failed", err)
+		}
+
+		caLogger.Error("RenewDeviceCertificate: Failed to check the device cap for the tenant!",
+			zap.String("Request ID:", requestID),
+			zap.String("Tenant ID:", request.Tid),
+			zap.String("Device ID:", request.DeviceId),
+			zap.Error(err),
+		)
+		response := internalErrorRenewDeviceCertificateResponse(requestID)
+		return response, statusErrorFromErr(request.Header, requestID,
+			"RenewDeviceCertificate RPC failed", err)
 	}
 
+	defer reservation.Release()
+
 	// Invoke the configured KMS provider to renew the device certificate.
 	_, deviceCert, parentCerts, expiresAt, err := s.kmsProvider.RenewDeviceCertificate(
-		request.Tid, request.DeviceId, request.Csr)
+		ctx, request.Tid, request.DeviceId, request.Csr)
 	if err != nil {
 		caLogger.Error("RenewDeviceCertificate: Failed to generate device certificate!",
 			zap.String("Request ID:", requestID),
@@ -56,11 +108,43 @@ func (s *CertificateAuthorityServer) RenewDeviceCertificate(ctx context.Context,
 			zap.Error(err),
 		)
 		response := internalErrorRenewDeviceCertificateResponse(requestID)
-		return response, nil
+		return response, statusErrorFromErr(request.Header, requestID,
+			"RenewDeviceCertificate RPC failed", err)
+	}
+
+	// Extend the lifetime of the device record tracked against the tenant's
+	// device cap. The renewed device certificate is not returned unless the
+	// device is recorded, so that it is counted against the device cap.
+	err = reservation.RecordDevice(ctx, request.DeviceId, expiresAt)
+	if err != nil {
+		caLogger.Error("RenewDeviceCertificate: Failed to record the device within the tenant!",
+			zap.String("Request ID:", requestID),
+			zap.String("Tenant ID:", request.Tid),
+			zap.String("Device ID:", request.DeviceId),
+			zap.Error(err),
+		)
+		response := internalErrorRenewDeviceCertificateResponse(requestID)
+		return response, statusErrorFromErr(request.Header, requestID,
+			"RenewDeviceCertificate RPC failed", err)
+	}
+
+	// Encode the device certificate in the format requested by the caller.
+	certificate, chain, err := formatDeviceCertificate(request.Format,
+		deviceCert, parentCerts)
+	if err != nil {
+		caLogger.Error("RenewDeviceCertificate: Failed to encode the device certificate!",
+			zap.String("Request ID:", requestID),
+			zap.String("Tenant ID:", request.Tid),
+			zap.String("Device ID:", request.DeviceId),
+			zap.Error(err),
+		)
+		response := internalErrorRenewDeviceCertificateResponse(requestID)
+		return response, statusErrorFromErr(request.Header, requestID,
+			"RenewDeviceCertificate RPC failed", err)
 	}
 
 	response := successRenewDeviceCertificateResponse(requestID,
-		request.DeviceId, deviceCert, parentCerts, expiresAt)
+		request.DeviceId, certificate, parentCerts, chain, expiresAt)
 	return response, nil
 }
 
@@ -82,7 +166,8 @@ func invalidRenewDeviceCertificateResponse(
 
 func successRenewDeviceCertificateResponse(
 	requestID string, deviceID string, deviceCert []byte,
-	parentCerts []byte, expiresAt time.Time) *pb.RenewDeviceCertificateResponse {
+	parentCerts []byte, chain [][]byte,
+	expiresAt time.Time) *pb.RenewDeviceCertificateResponse {
 	response := &pb.RenewDeviceCertificateResponse{
 		Header: &pb.CaResponseHeader{
 			ProtocolVersion: CaProtocolVersion,
@@ -96,12 +181,29 @@ func successRenewDeviceCertificateResponse(
 		DeviceId:           deviceID,
 		DeviceCertificate:  deviceCert,
 		ParentCertificates: parentCerts,
+		Chain:              chain,
 	}
 
 	metrics.MetricDeviceCertificatesRenewed.Inc()
 	return response
 }
 
+func deviceCapReachedRenewDeviceCertificateResponse(
+	requestID string) *pb.RenewDeviceCertificateResponse {
+	response := &pb.RenewDeviceCertificateResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.ResourceExhausted),
+			StatusMessage:   "RenewDeviceCertificate RPC failed: device cap reached for tenant",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	metrics.MetricDeviceCapReached.Inc()
+	return response
+}
+
 func internalErrorRenewDeviceCertificateResponse(
 	requestID string) *pb.RenewDeviceCertificateResponse {
 	response := &pb.RenewDeviceCertificateResponse{
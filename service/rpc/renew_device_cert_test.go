@@ -5,6 +5,7 @@ import (
 
 	pb "github.com/HPInc/krypton-ca/caprotos"
 	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 )
@@ -66,3 +67,45 @@ func TestRenewDeviceCertificate(t *testing.T) {
 	caLogger.Info("Response from certificate authority",
 		zap.Any("Response", renewResponse))
 }
+
+func TestRenewDeviceCertificate_DeviceCap(t *testing.T) {
+	tenantID := uuid.NewString()
+	response := setTenantQuota(t, tenantID, 1, false)
+	assertEqual(t, response.Header.Status, uint32(codes.OK))
+
+	createResponse := createTestDeviceCertificate(t, tenantID)
+	assertEqual(t, createResponse.Header.Status, uint32(codes.OK))
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+
+	// Renewals of devices enrolled within the tenant do not count against the
+	// device cap.
+	renewRequest := &pb.RenewDeviceCertificateRequest{
+		Header:   newCaProtocolHeader(),
+		Version:  CaProtocolVersion,
+		Tid:      tenantID,
+		DeviceId: createResponse.DeviceId,
+		Csr:      csr,
+	}
+	renewResponse, err := gClient.RenewDeviceCertificate(gCtx, renewRequest)
+	if err != nil {
+		t.Fatalf("RenewDeviceCertificate RPC failed: %v", err)
+	}
+	assertEqual(t, renewResponse.Header.Status, uint32(codes.OK))
+
+	// Renewals of unknown devices are enrollments of new devices, and are
+	// rejected once the tenant has reached its device cap.
+	renewRequest.Header = newCaProtocolHeader()
+	renewRequest.DeviceId = uuid.NewString()
+	renewResponse, err = gClient.RenewDeviceCertificate(gCtx, renewRequest)
+	if err != nil {
+		t.Fatalf("RenewDeviceCertificate RPC failed: %v", err)
+	}
+	assertEqual(t, renewResponse.Header.Status,
+		uint32(codes.ResourceExhausted))
+
+	quotaResponse := setTenantQuota(t, tenantID, 1, false)
+	assertEqual(t, quotaResponse.ActiveDevices, int64(1))
+}
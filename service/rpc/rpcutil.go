@@ -15,6 +15,10 @@ import (
 const (
 	// CaProtocolVersion - version of the CA's gRPC protocol.
 	CaProtocolVersion = "v1"
+
+	// CaProtocolVersionV2 - version of the CA's gRPC protocol in which failed
+	// requests are also reported using gRPC status errors.
+	CaProtocolVersionV2 = "v2"
 )
 
 // Validate the common gRPC request header attached to RPC messages received
@@ -27,7 +31,8 @@ func isValidRequestHeader(header *pb.CaRequestHeader) (string, bool) {
 	}
 
 	// Ensure the CA protocol being requested is supported by this server.
-	if header.ProtocolVersion != CaProtocolVersion {
+	if (header.ProtocolVersion != CaProtocolVersion) &&
+		(header.ProtocolVersion != CaProtocolVersionV2) {
 		caLogger.Error("Unsupported protocol version requested!")
 		return "", false
 	}
@@ -7,20 +7,24 @@
 package rpc
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
+	"github.com/HPInc/krypton-ca/service/certmgr/certstore"
+	"github.com/HPInc/krypton-ca/service/certmgr/idempotency"
 	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
 	"github.com/HPInc/krypton-ca/service/config"
+	"github.com/HPInc/krypton-ca/service/health"
 	"github.com/HPInc/krypton-ca/service/metrics"
 	"go.uber.org/zap"
 
 	pb "github.com/HPInc/krypton-ca/caprotos"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
 )
 
@@ -46,29 +50,46 @@ type CertificateAuthorityServer struct {
 	// KMS (Key Management Service) provider used to sign certificates.
 	kmsProvider kms_providers.KmsProvider
 
-	// Signal handling to support SIGTERM and SIGINT.
-	errChannel  chan error
-	stopChannel chan os.Signal
+	// Quota manager used to enforce per-tenant device caps.
+	quotaManager *quota.Manager
+
+	// Idempotency manager used to detect retried create device certificate
+	// requests.
+	idempotencyManager *idempotency.Manager
+
+	// Batch issuance settings, limiting the size of batch device certificate
+	// requests and the concurrency with which they are processed.
+	batchSettings *config.BatchIssuance
+
+	// Rate limiter used to enforce per-tenant and per-caller quotas. This is
+	// nil if rate limiting is not enabled.
+	rateLimiter *requestRateLimiter
+
+	// Fatal errors encountered while serving gRPC requests.
+	errChannel chan error
 }
 
-// Init - initialize and start the Krypton Certificate Authority's gRPC server
-func Init(logger *zap.Logger, serverConfig *config.Server,
-	kmsProvider kms_providers.KmsProvider) error {
-	caLogger = logger
-	rpcServerConfig = serverConfig
+// Init - initialize and start the Krypton Certificate Authority's gRPC server,
+// serving requests using the specified certificate authority service. The
+// service is shared with the REST/JSON gateway, so that both are subject to
+// the same rate limits and device caps. Requests are served on a separate
+// goroutine until the server is shut down. The readiness of the CA is
+// reported by the standard gRPC health service, using the specified health
+// checker.
+func Init(cfgMgr *config.ConfigMgr, s *CertificateAuthorityServer,
+	checker *health.Checker) (*CertificateAuthorityServer, error) {
+	rpcServerConfig = cfgMgr.GetServerConfig()
 
 	// Create a new certificate authority gRPC server instance.
-	s := &CertificateAuthorityServer{
-		kmsProvider: kmsProvider,
-	}
 	err := s.NewServer()
 	if err != nil {
 		caLogger.Error("Unable to configure gRPC server. Error!",
 			zap.Error(err),
 		)
 		fmt.Println("Failed to configure gRPC server. Exiting!")
-		return err
+		return nil, err
 	}
+	healthpb.RegisterHealthServer(s.cagRPCServer, checker.GrpcHealthServer())
 
 	// Start serving requests at the gRPC endpoint.
 	err = s.startServing()
@@ -79,19 +100,43 @@ func Init(logger *zap.Logger, serverConfig *config.Server,
 			zap.Error(err),
 		)
 		fmt.Println("Failed to start CA gRPC server. Exiting!")
-		return err
+		return nil, err
 	}
+	return s, nil
+}
 
-	s.awaitTermination()
-	return nil
+// NewCertificateAuthorityService - initialize the state used to serve
+// certificate authority RPCs, without creating a gRPC server. This is used by
+// the gRPC server and by the REST/JSON gateway, which invokes the RPC handlers
+// in-process.
+func NewCertificateAuthorityService(logger *zap.Logger, cfgMgr *config.ConfigMgr,
+	kmsProvider kms_providers.KmsProvider,
+	store certstore.CertStore) *CertificateAuthorityServer {
+	caLogger = logger
+
+	s := &CertificateAuthorityServer{
+		kmsProvider: kmsProvider,
+		quotaManager: quota.NewManager(logger, store,
+			cfgMgr.GetDeviceQuotaConfig()),
+		idempotencyManager: idempotency.NewManager(logger, store,
+			cfgMgr.GetIdempotencyConfig()),
+		batchSettings: cfgMgr.GetBatchIssuanceConfig(),
+	}
+	if cfgMgr.GetRateLimitConfig().Enabled {
+		s.rateLimiter = newRequestRateLimiter(cfgMgr.GetRateLimitConfig())
+	}
+	return s
+}
+
+// QuotaManager - returns the quota manager used to enforce per-tenant device
+// caps, which is shared with other enrollment protocols served by the CA.
+func (s *CertificateAuthorityServer) QuotaManager() *quota.Manager {
+	return s.quotaManager
 }
 
 // NewServer creates and registers a new gRPC server instance for the CA.
 func (s *CertificateAuthorityServer) NewServer() error {
-	// Handle SIGTERM and SIGINT.
-	s.errChannel = make(chan error)
-	s.stopChannel = make(chan os.Signal, 1)
-	signal.Notify(s.stopChannel, syscall.SIGINT, syscall.SIGTERM)
+	s.errChannel = make(chan error, 1)
 
 	var defaultKeepAliveParams = keepalive.ServerParameters{
 		Time:    20 * time.Second,
@@ -113,64 +158,95 @@ func (s *CertificateAuthorityServer) NewServer() error {
 	s.cagRPCServer = grpc.NewServer(
 		//	grpc.Creds(creds),
 		grpc.KeepaliveParams(defaultKeepAliveParams),
-		grpc.UnaryInterceptor(unaryInterceptor),
+		grpc.ChainUnaryInterceptor(s.unaryInterceptors()...),
+		grpc.ChainStreamInterceptor(s.streamInterceptors()...),
 	)
 
 	pb.RegisterCertificateAuthorityServer(s.cagRPCServer, s)
 	return nil
 }
 
+// Returns the interceptors applied to unary RPC requests, in the order in
+// which they are invoked. The common interceptor runs first so that throttled
+// requests are also reflected in the RPC latency and error metrics.
+func (s *CertificateAuthorityServer) unaryInterceptors() []grpc.UnaryServerInterceptor {
+	interceptors := []grpc.UnaryServerInterceptor{unaryInterceptor}
+	if s.rateLimiter != nil {
+		interceptors = append(interceptors, s.rateLimiter.unaryInterceptor)
+	}
+	return interceptors
+}
+
+// Returns the interceptors applied to streaming RPC requests, in the order in
+// which they are invoked.
+func (s *CertificateAuthorityServer) streamInterceptors() []grpc.StreamServerInterceptor {
+	interceptors := []grpc.StreamServerInterceptor{streamInterceptor}
+	if s.rateLimiter != nil {
+		interceptors = append(interceptors, s.rateLimiter.streamInterceptor)
+	}
+	return interceptors
+}
+
 // Start listening on the configured port. Creates a separate goroutine to
 // serve gRPC requests.
 func (s *CertificateAuthorityServer) startServing() error {
 	metrics.RegisterPrometheusMetrics()
 
-	go s.listenAndServe()
-	return nil
-}
-
-// Goroutine to listen for and serve gRPC requests.
-func (s *CertificateAuthorityServer) listenAndServe() {
 	// Start the server and listen to the specified port.
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", rpcServerConfig.RpcPort))
 	if err != nil {
 		caLogger.Error("Failed to initialize a listener for the gRPC server!",
 			zap.Error(err),
 		)
-		s.errChannel <- err
-		return
+		return err
 	}
 
-	// Start accepting incoming connection requests.
-	err = s.cagRPCServer.Serve(listener)
-	if err != nil {
-		caLogger.Error("Failed to start serving incoming gRPC requests!",
-			zap.Error(err),
-		)
-		s.errChannel <- err
-		return
-	}
+	go s.serve(listener)
+	return nil
+}
 
+// Goroutine to serve gRPC requests received by the specified listener.
+func (s *CertificateAuthorityServer) serve(listener net.Listener) {
 	caLogger.Info("HP CA: Serving gRPC requests.",
 		zap.Int("Port", rpcServerConfig.RpcPort),
 	)
-}
 
-// Wait for a signal to shutdown the gRPC server and cleanup.
-func (s *CertificateAuthorityServer) awaitTermination() {
-	// Block until we receive either an OS signal, or encounter a server
-	// fatal error and need to terminate.
-	select {
-	case err := <-s.errChannel:
-		caLogger.Error("HP CA: Shutting down due to a fatal error.",
+	// Start accepting incoming connection requests. Serve returns once the
+	// server is stopped.
+	err := s.cagRPCServer.Serve(listener)
+	if (err != nil) && !errors.Is(err, grpc.ErrServerStopped) {
+		caLogger.Error("Failed to serve incoming gRPC requests!",
 			zap.Error(err),
 		)
-	case sig := <-s.stopChannel:
-		caLogger.Error("HP CA: Received an OS signal and shutting down.",
-			zap.String("Signal:", sig.String()),
-		)
+		s.errChannel <- err
 	}
+}
+
+// Errors - returns a channel on which fatal errors encountered while serving
+// gRPC requests are reported.
+func (s *CertificateAuthorityServer) Errors() <-chan error {
+	return s.errChannel
+}
 
-	// Cleanup.
-	s.cagRPCServer.GracefulStop()
+// Shutdown - stop accepting gRPC requests and wait for in-flight requests to
+// complete. If the specified context is done before in-flight requests have
+// completed, the remaining requests are cancelled and their connections are
+// closed.
+func (s *CertificateAuthorityServer) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.cagRPCServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		caLogger.Info("HP CA: Stopped serving gRPC requests.")
+		return nil
+	case <-ctx.Done():
+		caLogger.Warn("HP CA: Timed out waiting for in-flight gRPC requests to complete!")
+		s.cagRPCServer.Stop()
+		<-stopped
+		return ctx.Err()
+	}
 }
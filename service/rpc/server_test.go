@@ -11,7 +11,9 @@ import (
 	"testing"
 
 	"github.com/HPInc/krypton-ca/service/certmgr"
+	"github.com/HPInc/krypton-ca/service/certmgr/idempotency"
 	"github.com/HPInc/krypton-ca/service/certmgr/kms_providers"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
 	"github.com/HPInc/krypton-ca/service/common"
 	"github.com/HPInc/krypton-ca/service/config"
 	"github.com/google/uuid"
@@ -37,6 +39,18 @@ var (
 	gConnection    *grpc.ClientConn
 	gCtx           context.Context
 	grpcTestServer *grpc.Server
+
+	// Device quota settings used by the test server.
+	gTestQuotaSettings *config.DeviceQuota
+
+	// Idempotency settings used by the test server.
+	gTestIdempotencySettings *config.Idempotency
+
+	// Batch issuance settings used by the test server.
+	gTestBatchSettings = &config.BatchIssuance{
+		MaxBatchSize:   10,
+		MaxConcurrency: 4,
+	}
 )
 
 func newCaProtocolHeader() *pb.CaRequestHeader {
@@ -47,6 +61,14 @@ func newCaProtocolHeader() *pb.CaRequestHeader {
 	}
 }
 
+func newCaV2ProtocolHeader() *pb.CaRequestHeader {
+	return &pb.CaRequestHeader{
+		ProtocolVersion: CaProtocolVersionV2,
+		RequestId:       uuid.New().String(),
+		RequestTime:     timestamppb.Now(),
+	}
+}
+
 func newCaInvalidVersionProtocolHeader() *pb.CaRequestHeader {
 	return &pb.CaRequestHeader{
 		ProtocolVersion: "vx",
@@ -78,14 +100,18 @@ func initConnection() bool {
 }
 
 func initTestRpcServer(logger *zap.Logger,
-	provider kms_providers.KmsProvider) {
+	provider kms_providers.KmsProvider, quotaManager *quota.Manager,
+	idempotencyManager *idempotency.Manager) {
 	caLogger = logger
 
 	gListener = bufconn.Listen(bufSize)
 	grpcTestServer = grpc.NewServer()
 
 	s := &CertificateAuthorityServer{
-		kmsProvider: provider,
+		kmsProvider:        provider,
+		quotaManager:       quotaManager,
+		idempotencyManager: idempotencyManager,
+		batchSettings:      gTestBatchSettings,
 	}
 	err := s.NewServer()
 	if err != nil {
@@ -162,7 +188,7 @@ func TestMain(m *testing.M) {
 	}
 
 	// Initialize the certificate authority.
-	certProvider, err := certmgr.Init(caLogger, cfgMgr)
+	certProvider, certStore, err := certmgr.Init(caLogger, cfgMgr)
 	if err != nil {
 		caLogger.Error("Failed to initialize the certificate authority!",
 			zap.Error(err),
@@ -172,7 +198,16 @@ func TestMain(m *testing.M) {
 	}
 
 	// Initialize a test RPC server using which the unit tests run.
-	initTestRpcServer(caLogger, certProvider)
+	gTestQuotaSettings = &config.DeviceQuota{
+		TenantMaxDevices: map[string]int{},
+	}
+	gTestIdempotencySettings = &config.Idempotency{
+		Enabled:       true,
+		WindowSeconds: 3600,
+	}
+	initTestRpcServer(caLogger, certProvider,
+		quota.NewManager(caLogger, certStore, gTestQuotaSettings),
+		idempotency.NewManager(caLogger, certStore, gTestIdempotencySettings))
 	err = initTestEnvironment()
 	if err != nil {
 		fmt.Println("Failed to initialize test environment.")
@@ -0,0 +1,139 @@
+// package github.com/HPInc/krypton-ca/service/rpc
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements the SetTenantQuota RPC used to set the device cap for the
+// specified tenant. Device caps set using this RPC take precedence over those
+// specified in the CA configuration, until they are reset.
+package rpc
+
+import (
+	"context"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
+	"github.com/HPInc/krypton-ca/service/metrics"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Upper bound on the device cap that can be set for a tenant.
+const maxTenantDeviceCap = int64(^uint32(0) >> 1)
+
+// SetTenantQuota RPC is used to set or reset the device quota for a tenant.
+func (s *CertificateAuthorityServer) SetTenantQuota(ctx context.Context,
+	request *pb.SetTenantQuotaRequest) (*pb.SetTenantQuotaResponse, error) {
+
+	// Validate the request header and extract the request identifier for
+	// end-to-end request tracing.
+	requestID, ok := isValidRequestHeader(request.Header)
+	if !ok {
+		caLogger.Error("SetTenantQuota: Invalid request header specified!")
+		response := invalidSetTenantQuotaResponse(requestID)
+		return response, nil
+	}
+
+	if (request.Tid == "") || (request.MaxDevices < 0) ||
+		(request.MaxDevices > maxTenantDeviceCap) {
+		caLogger.Error("SetTenantQuota: TenantID was not specified or an invalid device cap was specified!",
+			zap.String("Request ID:", requestID),
+			zap.Int64("Max devices:", request.MaxDevices),
+		)
+		response := invalidSetTenantQuotaResponse(requestID)
+		violations := missingFieldViolations(
+			requiredField{"tid", request.Tid != ""})
+		if (request.MaxDevices < 0) || (request.MaxDevices > maxTenantDeviceCap) {
+			violations = append(violations, fieldViolation("max_devices",
+				"device cap is out of range"))
+		}
+		return response, badRequestError(request.Header, requestID,
+			"SetTenantQuota RPC failed", violations...)
+	}
+
+	var err error
+	if request.ResetToDefault {
+		err = s.quotaManager.ResetTenantQuota(ctx, request.Tid)
+	} else {
+		err = s.quotaManager.SetTenantQuota(ctx, request.Tid,
+			int(request.MaxDevices))
+	}
+	if err != nil {
+		caLogger.Error("SetTenantQuota: Failed to set the quota for the tenant!",
+			zap.String("Request ID:", requestID),
+			zap.String("Tenant ID:", request.Tid),
+			zap.Error(err),
+		)
+		response := internalErrorSetTenantQuotaResponse(requestID)
+		return response, statusErrorFromErr(request.Header, requestID,
+			"SetTenantQuota RPC failed", err)
+	}
+
+	// Return the quota now applicable to the tenant.
+	tenantQuota, err := s.quotaManager.GetTenantQuota(ctx, request.Tid)
+	if err != nil {
+		caLogger.Error("SetTenantQuota: Failed to get the quota for the tenant!",
+			zap.String("Request ID:", requestID),
+			zap.String("Tenant ID:", request.Tid),
+			zap.Error(err),
+		)
+		response := internalErrorSetTenantQuotaResponse(requestID)
+		return response, statusErrorFromErr(request.Header, requestID,
+			"SetTenantQuota RPC failed", err)
+	}
+
+	response := successSetTenantQuotaResponse(requestID, tenantQuota)
+	return response, nil
+}
+
+func invalidSetTenantQuotaResponse(
+	requestID string) *pb.SetTenantQuotaResponse {
+	response := &pb.SetTenantQuotaResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.InvalidArgument),
+			StatusMessage:   "SetTenantQuota RPC failed",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	metrics.MetricTenantQuotaBadRequests.Inc()
+	return response
+}
+
+func successSetTenantQuotaResponse(requestID string,
+	tenantQuota *quota.TenantQuota) *pb.SetTenantQuotaResponse {
+	response := &pb.SetTenantQuotaResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.OK),
+			StatusMessage:   "SetTenantQuota RPC successful",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+		Tid:           tenantQuota.TenantID,
+		MaxDevices:    int64(tenantQuota.MaxDevices),
+		ActiveDevices: int64(tenantQuota.ActiveDevices),
+		Source:        tenantQuota.Source,
+	}
+
+	return response
+}
+
+func internalErrorSetTenantQuotaResponse(
+	requestID string) *pb.SetTenantQuotaResponse {
+	response := &pb.SetTenantQuotaResponse{
+		Header: &pb.CaResponseHeader{
+			ProtocolVersion: CaProtocolVersion,
+			Status:          uint32(codes.Internal),
+			StatusMessage:   "SetTenantQuota RPC failed",
+			RequestId:       requestID,
+			ResponseTime:    timestamppb.Now(),
+		},
+	}
+
+	metrics.MetricTenantQuotaInternalErrors.Inc()
+	return response
+}
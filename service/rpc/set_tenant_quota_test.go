@@ -0,0 +1,131 @@
+package rpc
+
+import (
+	"testing"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
+	"github.com/HPInc/krypton-ca/service/common"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+func setTenantQuota(t *testing.T, tenantID string, maxDevices int64,
+	reset bool) *pb.SetTenantQuotaResponse {
+	request := &pb.SetTenantQuotaRequest{
+		Header:         newCaProtocolHeader(),
+		Version:        CaProtocolVersion,
+		Tid:            tenantID,
+		MaxDevices:     maxDevices,
+		ResetToDefault: reset,
+	}
+
+	response, err := gClient.SetTenantQuota(gCtx, request)
+	if err != nil {
+		caLogger.Error("setTenantQuota: RPC failed",
+			zap.Error(err))
+		t.FailNow()
+	}
+	return response
+}
+
+func createTestDeviceCertificate(t *testing.T,
+	tenantID string) *pb.CreateDeviceCertificateResponse {
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		caLogger.Error("createTestDeviceCertificate: Error creating CSR",
+			zap.Error(err))
+		t.FailNow()
+	}
+
+	response, err := gClient.CreateDeviceCertificate(gCtx,
+		&pb.CreateDeviceCertificateRequest{
+			Header:  newCaProtocolHeader(),
+			Version: CaProtocolVersion,
+			Tid:     tenantID,
+			Csr:     csr,
+		})
+	if err != nil {
+		caLogger.Error("createTestDeviceCertificate: RPC failed",
+			zap.Error(err))
+		t.FailNow()
+	}
+	return response
+}
+
+func TestSetTenantQuota(t *testing.T) {
+	tenantID := uuid.NewString()
+
+	response := setTenantQuota(t, tenantID, 10, false)
+	assertEqual(t, response.Header.Status, uint32(codes.OK))
+	assertEqual(t, response.MaxDevices, int64(10))
+	assertEqual(t, response.Source, quota.QuotaSourceTenant)
+
+	// The device cap set using the RPC overrides the configured device cap.
+	gTestQuotaSettings.TenantMaxDevices[tenantID] = 25
+	defer delete(gTestQuotaSettings.TenantMaxDevices, tenantID)
+
+	response = setTenantQuota(t, tenantID, 5, false)
+	assertEqual(t, response.MaxDevices, int64(5))
+	assertEqual(t, response.Source, quota.QuotaSourceTenant)
+
+	// Resetting the quota reverts to the configured device cap.
+	response = setTenantQuota(t, tenantID, 0, true)
+	assertEqual(t, response.Header.Status, uint32(codes.OK))
+	assertEqual(t, response.MaxDevices, int64(25))
+	assertEqual(t, response.Source, quota.QuotaSourceConfig)
+}
+
+func TestSetTenantQuota_InvalidDeviceCap(t *testing.T) {
+	response := setTenantQuota(t, uuid.NewString(), -1, false)
+	assertEqual(t, response.Header.Status, uint32(codes.InvalidArgument))
+
+	response = setTenantQuota(t, "", 10, false)
+	assertEqual(t, response.Header.Status, uint32(codes.InvalidArgument))
+}
+
+func TestSetTenantQuota_DeviceCapEnforced(t *testing.T) {
+	tenantID := uuid.NewString()
+
+	response := setTenantQuota(t, tenantID, 1, false)
+	assertEqual(t, response.Header.Status, uint32(codes.OK))
+
+	// The first device is enrolled.
+	createResponse := createTestDeviceCertificate(t, tenantID)
+	assertEqual(t, createResponse.Header.Status, uint32(codes.OK))
+
+	// The tenant has now reached its device cap.
+	rejectedResponse := createTestDeviceCertificate(t, tenantID)
+	assertEqual(t, rejectedResponse.Header.Status,
+		uint32(codes.ResourceExhausted))
+
+	// Renewing the device certificate for the enrolled device is permitted.
+	csr, err := common.CreateDeviceCertificateSigningRequest()
+	if err != nil {
+		caLogger.Error("TestSetTenantQuota_DeviceCapEnforced: Error creating CSR",
+			zap.Error(err))
+		t.FailNow()
+	}
+	renewResponse, err := gClient.RenewDeviceCertificate(gCtx,
+		&pb.RenewDeviceCertificateRequest{
+			Header:   newCaProtocolHeader(),
+			Version:  CaProtocolVersion,
+			Tid:      tenantID,
+			DeviceId: createResponse.DeviceId,
+			Csr:      csr,
+		})
+	if err != nil {
+		caLogger.Error("TestSetTenantQuota_DeviceCapEnforced: RPC failed",
+			zap.Error(err))
+		t.FailNow()
+	}
+	assertEqual(t, renewResponse.Header.Status, uint32(codes.OK))
+
+	// The renewal did not count against the device cap.
+	quotaResponse := setTenantQuota(t, tenantID, 2, false)
+	assertEqual(t, quotaResponse.ActiveDevices, int64(1))
+
+	createResponse = createTestDeviceCertificate(t, tenantID)
+	assertEqual(t, createResponse.Header.Status, uint32(codes.OK))
+}
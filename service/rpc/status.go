@@ -0,0 +1,206 @@
+// package github.com/HPInc/krypton-ca/service/rpc
+// Author: Mahesh Unnikrishnan
+// Component: Krypton Certificate Authority
+// (C) HP Development Company, LP
+// Purpose:
+// Implements gRPC status errors returned to callers using version 2 of the CA
+// protocol. Version 1 callers only receive the status of a failed request in
+// the CaResponseHeader. Version 2 callers additionally receive a gRPC status
+// error, with details describing why the request failed - field violations for
+// bad requests and a reason code for all failures. The status code is chosen
+// based on the category of the error returned by the KMS provider, certificate
+// store or quota manager.
+package rpc
+
+import (
+	"errors"
+
+	pb "github.com/HPInc/krypton-ca/caprotos"
+	"github.com/HPInc/krypton-ca/service/caerrors"
+	"github.com/HPInc/krypton-ca/service/certmgr/idempotency"
+	"github.com/HPInc/krypton-ca/service/certmgr/quota"
+	"github.com/HPInc/krypton-ca/service/common"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Domain reported in the ErrorInfo details of status errors.
+	errorInfoDomain = "ca.krypton.hp.com"
+
+	// Reason codes reported in the ErrorInfo details of status errors.
+	reasonInvalidRequest        = "INVALID_REQUEST"
+	reasonInvalidCSR            = "INVALID_CSR"
+	reasonNotFound              = "NOT_FOUND"
+	reasonConflict              = "CONFLICT"
+	reasonDependencyUnavailable = "DEPENDENCY_UNAVAILABLE"
+	reasonPolicyViolation       = "POLICY_VIOLATION"
+	reasonKmsThrottled          = "KMS_THROTTLED"
+	reasonDeviceCapReached      = "DEVICE_CAP_REACHED"
+	reasonRequestIDReused       = "REQUEST_ID_REUSED"
+	reasonRequestInProgress     = "REQUEST_IN_PROGRESS"
+	reasonTenantExists          = "TENANT_EXISTS"
+	reasonCanceled              = "CANCELED"
+	reasonInternalError         = "INTERNAL_ERROR"
+
+	// Metadata key used to report the request ID in ErrorInfo details.
+	errorInfoRequestIDField = "request_id"
+)
+
+// useStatusErrors checks whether the caller negotiated version 2 of the CA
+// protocol, and expects failures to be returned as gRPC status errors.
+func useStatusErrors(header *pb.CaRequestHeader) bool {
+	return (header != nil) && (header.ProtocolVersion == CaProtocolVersionV2)
+}
+
+// fieldViolation describes a request field that was missing or invalid.
+func fieldViolation(field string,
+	description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: description,
+	}
+}
+
+// newStatusError builds a gRPC status error with an ErrorInfo detail carrying
+// the reason code and, if specified, a BadRequest detail listing the field
+// violations.
+func newStatusError(code codes.Code, reason string, message string,
+	requestID string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	st := status.New(code, message)
+	errorInfo := &errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: errorInfoDomain,
+		Metadata: map[string]string{
+			errorInfoRequestIDField: requestID,
+		},
+	}
+
+	var (
+		detailed *status.Status
+		err      error
+	)
+	if len(violations) > 0 {
+		detailed, err = st.WithDetails(errorInfo,
+			&errdetails.BadRequest{FieldViolations: violations})
+	} else {
+		detailed, err = st.WithDetails(errorInfo)
+	}
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// badRequestError returns an InvalidArgument status error listing the field
+// violations, if the caller uses version 2 of the CA protocol. Otherwise, nil
+// is returned and the caller relies on the status in the response header.
+func badRequestError(header *pb.CaRequestHeader, requestID string,
+	message string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	if !useStatusErrors(header) {
+		return nil
+	}
+	return newStatusError(codes.InvalidArgument, reasonInvalidRequest, message,
+		requestID, violations...)
+}
+
+// Status codes and reason codes reported for each category of error.
+var categoryStatus = map[caerrors.Category]struct {
+	code   codes.Code
+	reason string
+}{
+	caerrors.InvalidInput:          {codes.InvalidArgument, reasonInvalidRequest},
+	caerrors.NotFound:              {codes.NotFound, reasonNotFound},
+	caerrors.Conflict:              {codes.AlreadyExists, reasonConflict},
+	caerrors.DependencyUnavailable: {codes.Unavailable, reasonDependencyUnavailable},
+	caerrors.PolicyViolation:       {codes.FailedPrecondition, reasonPolicyViolation},
+	caerrors.Canceled:              {codes.Canceled, reasonCanceled},
+	caerrors.Internal:              {codes.Internal, reasonInternalError},
+}
+
+// statusErrorFromErr maps an error returned by the KMS provider, certificate
+// store or quota manager to a status error based on its category, if the
+// caller uses version 2 of the CA protocol. Otherwise, nil is returned and the
+// caller relies on the status in the response header.
+func statusErrorFromErr(header *pb.CaRequestHeader, requestID string,
+	message string, err error) error {
+	if !useStatusErrors(header) {
+		return nil
+	}
+
+	st := statusFromErr(message, err)
+	return newStatusError(st.code, st.reason, st.message, requestID,
+		st.violations...)
+}
+
+// errorStatus describes the status reported to callers for an error.
+type errorStatus struct {
+	code       codes.Code
+	reason     string
+	message    string
+	violations []*errdetails.BadRequest_FieldViolation
+}
+
+// statusFromErr maps an error returned by the KMS provider, certificate store
+// or quota manager to the status code, reason code and message reported to
+// callers, based on the category of the error.
+func statusFromErr(message string, err error) errorStatus {
+	category := caerrors.CategoryOf(err)
+	mapped := categoryStatus[category]
+
+	// Describe the failure to the caller, except for internal errors whose
+	// details are only logged.
+	if detail := caerrors.MessageOf(err); (detail != "") &&
+		(category != caerrors.Internal) {
+		message = message + ": " + detail
+	}
+
+	// Report more specific status and reason codes for some errors.
+	switch {
+	case errors.Is(err, common.ErrInvalidCSR):
+		return errorStatus{codes.InvalidArgument, reasonInvalidCSR, message,
+			[]*errdetails.BadRequest_FieldViolation{
+				fieldViolation("csr", err.Error())}}
+
+	case errors.Is(err, common.ErrKmsThrottled):
+		return errorStatus{codes.Unavailable, reasonKmsThrottled, message, nil}
+
+	case errors.Is(err, quota.ErrDeviceCapReached):
+		return errorStatus{codes.ResourceExhausted, reasonDeviceCapReached,
+			message, nil}
+
+	case errors.Is(err, idempotency.ErrRequestMismatch):
+		return errorStatus{codes.AlreadyExists, reasonRequestIDReused,
+			message, nil}
+
+	case errors.Is(err, idempotency.ErrRequestInProgress):
+		return errorStatus{codes.Aborted, reasonRequestInProgress, message, nil}
+
+	case errors.Is(err, common.ErrTenantExists):
+		return errorStatus{codes.AlreadyExists, reasonTenantExists, message, nil}
+	}
+
+	return errorStatus{mapped.code, mapped.reason, message, nil}
+}
+
+// requiredField describes a request field which must be specified, and
+// whether it was.
+type requiredField struct {
+	name      string
+	specified bool
+}
+
+// missingFieldViolations returns field violations for each of the required
+// request fields that were not specified.
+func missingFieldViolations(
+	fields ...requiredField) []*errdetails.BadRequest_FieldViolation {
+	violations := []*errdetails.BadRequest_FieldViolation{}
+	for _, field := range fields {
+		if !field.specified {
+			violations = append(violations, fieldViolation(field.name,
+				field.name+" must be specified"))
+		}
+	}
+	return violations
+}